@@ -116,10 +116,14 @@ func Backend(conf *logical.BackendConfig) *databaseBackend {
 			pathRoles(&b),
 			pathCredsCreate(&b),
 			pathRotateRootCredentials(&b),
+			pathListLibrarySets(&b),
+			pathLibrarySets(&b),
+			pathLibraryCheckOut(&b),
 		),
 
 		Secrets: []*framework.Secret{
 			secretCreds(&b),
+			secretLibraryCreds(&b),
 		},
 		Clean:             b.clean,
 		Invalidate:        b.invalidate,
@@ -132,6 +136,7 @@ func Backend(conf *logical.BackendConfig) *databaseBackend {
 	b.connections = syncmap.NewSyncMap[string, *dbPluginInstance]()
 	b.queueCtx, b.cancelQueueCtx = context.WithCancel(context.Background())
 	b.roleLocks = locksutil.CreateLocks()
+	b.libraryLocks = locksutil.CreateLocks()
 	b.schedule = &schedule.DefaultSchedule{}
 
 	return &b
@@ -180,6 +185,11 @@ type databaseBackend struct {
 	// issues with the priority queue.
 	roleLocks []*locksutil.LockEntry
 
+	// libraryLocks is used to serialize check-outs and check-ins of the
+	// accounts of a library set. When both are needed, a library set's lock
+	// is taken before a role's lock.
+	libraryLocks []*locksutil.LockEntry
+
 	// the running gauge collection process
 	gaugeCollectionProcess     *metricsutil.GaugeCollectionProcess
 	gaugeCollectionProcessStop sync.Once
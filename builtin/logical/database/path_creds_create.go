@@ -255,6 +255,16 @@ func (b *databaseBackend) pathStaticCredsRead() framework.OperationFunc {
 			return nil, fmt.Errorf("%q is not an allowed role", name)
 		}
 
+		// The credentials of a checked out library account belong to its
+		// borrower until it is checked in.
+		checkOut, err := b.libraryCheckOut(ctx, req.Storage, name)
+		if err != nil {
+			return nil, err
+		}
+		if checkOut != nil {
+			return logical.ErrorResponse("static role %q is checked out of library set %q", name, checkOut.SetName), nil
+		}
+
 		respData := map[string]interface{}{
 			"username":            role.StaticAccount.Username,
 			"ttl":                 role.StaticAccount.CredentialTTL().Seconds(),
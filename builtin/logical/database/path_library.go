@@ -0,0 +1,318 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package database
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hashicorp/go-secure-stdlib/strutil"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/locksutil"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	databaseLibraryPath         = "library/"
+	databaseLibraryCheckOutPath = "library-check-out/"
+)
+
+// librarySet is a set of static roles whose accounts are shared by checking
+// them out exclusively, one caller at a time.
+type librarySet struct {
+	StaticRoleNames           []string      `json:"static_role_names"`
+	TTL                       time.Duration `json:"ttl"`
+	MaxTTL                    time.Duration `json:"max_ttl"`
+	DisableCheckInEnforcement bool          `json:"disable_check_in_enforcement"`
+}
+
+func pathListLibrarySets(b *databaseBackend) []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern: "library/?$",
+
+			DisplayAttrs: &framework.DisplayAttributes{
+				OperationPrefix: operationPrefixDatabase,
+				OperationVerb:   "list",
+				OperationSuffix: "library-sets",
+			},
+
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.ListOperation: b.pathLibrarySetList,
+			},
+
+			HelpSynopsis:    pathLibrarySetHelpSyn,
+			HelpDescription: pathLibrarySetHelpDesc,
+		},
+	}
+}
+
+func pathLibrarySets(b *databaseBackend) []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern: "library/" + framework.GenericNameRegex("name"),
+
+			DisplayAttrs: &framework.DisplayAttributes{
+				OperationPrefix: operationPrefixDatabase,
+				OperationSuffix: "library-set",
+			},
+
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeString,
+					Description: "Name of the library set.",
+				},
+				"static_role_names": {
+					Type: framework.TypeCommaStringSlice,
+					Description: "Names of the static roles whose accounts can be checked out. " +
+						"A static role can only belong to one library set.",
+				},
+				"ttl": {
+					Type: framework.TypeDurationSecond,
+					Description: "Default and maximum time for which an account is checked out " +
+						"before it is automatically checked in. Defaults to the mount's default TTL.",
+				},
+				"max_ttl": {
+					Type: framework.TypeDurationSecond,
+					Description: "Maximum time for which a check-out can be renewed before the " +
+						"account is automatically checked in. Defaults to the mount's max TTL.",
+				},
+				"disable_check_in_enforcement": {
+					Type: framework.TypeBool,
+					Description: "If set, any caller can check in an account, rather than only the " +
+						"caller which checked it out.",
+				},
+			},
+
+			ExistenceCheck: b.pathLibrarySetExistenceCheck,
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.ReadOperation:   b.pathLibrarySetRead,
+				logical.CreateOperation: b.pathLibrarySetCreateUpdate,
+				logical.UpdateOperation: b.pathLibrarySetCreateUpdate,
+				logical.DeleteOperation: b.pathLibrarySetDelete,
+			},
+
+			HelpSynopsis:    pathLibrarySetHelpSyn,
+			HelpDescription: pathLibrarySetHelpDesc,
+		},
+	}
+}
+
+func (b *databaseBackend) LibrarySet(ctx context.Context, s logical.Storage, name string) (*librarySet, error) {
+	entry, err := s.Get(ctx, databaseLibraryPath+name)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var result librarySet
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// librarySetForStaticRole returns the name of the library set which the
+// static role belongs to, or "" if it does not belong to one.
+func (b *databaseBackend) librarySetForStaticRole(ctx context.Context, s logical.Storage, roleName string) (string, error) {
+	names, err := s.List(ctx, databaseLibraryPath)
+	if err != nil {
+		return "", err
+	}
+
+	for _, name := range names {
+		set, err := b.LibrarySet(ctx, s, name)
+		if err != nil {
+			return "", err
+		}
+		if set != nil && strutil.StrListContains(set.StaticRoleNames, roleName) {
+			return name, nil
+		}
+	}
+
+	return "", nil
+}
+
+func (b *databaseBackend) pathLibrarySetExistenceCheck(ctx context.Context, req *logical.Request, data *framework.FieldData) (bool, error) {
+	set, err := b.LibrarySet(ctx, req.Storage, data.Get("name").(string))
+	if err != nil {
+		return false, err
+	}
+	return set != nil, nil
+}
+
+func (b *databaseBackend) pathLibrarySetList(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	entries, err := req.Storage.List(ctx, databaseLibraryPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return logical.ListResponse(entries), nil
+}
+
+func (b *databaseBackend) pathLibrarySetRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	set, err := b.LibrarySet(ctx, req.Storage, data.Get("name").(string))
+	if err != nil {
+		return nil, err
+	}
+	if set == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"static_role_names":            set.StaticRoleNames,
+			"ttl":                          set.TTL.Seconds(),
+			"max_ttl":                      set.MaxTTL.Seconds(),
+			"disable_check_in_enforcement": set.DisableCheckInEnforcement,
+		},
+	}, nil
+}
+
+func (b *databaseBackend) pathLibrarySetCreateUpdate(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := data.Get("name").(string)
+	if name == "" {
+		return logical.ErrorResponse("empty library set name attribute given"), nil
+	}
+
+	lock := locksutil.LockForKey(b.libraryLocks, name)
+	lock.Lock()
+	defer lock.Unlock()
+
+	set, err := b.LibrarySet(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if set == nil {
+		if req.Operation == logical.UpdateOperation {
+			return nil, fmt.Errorf("no library set found for update")
+		}
+		set = &librarySet{}
+	}
+
+	previousRoleNames := set.StaticRoleNames
+
+	if roleNamesRaw, ok := data.GetOk("static_role_names"); ok {
+		set.StaticRoleNames = strutil.RemoveDuplicates(roleNamesRaw.([]string), false)
+	}
+	if ttlRaw, ok := data.GetOk("ttl"); ok {
+		set.TTL = time.Duration(ttlRaw.(int)) * time.Second
+	}
+	if maxTTLRaw, ok := data.GetOk("max_ttl"); ok {
+		set.MaxTTL = time.Duration(maxTTLRaw.(int)) * time.Second
+	}
+	if disableRaw, ok := data.GetOk("disable_check_in_enforcement"); ok {
+		set.DisableCheckInEnforcement = disableRaw.(bool)
+	}
+
+	if len(set.StaticRoleNames) == 0 {
+		return logical.ErrorResponse("static_role_names must contain at least one static role"), nil
+	}
+	if set.MaxTTL > 0 && set.TTL > set.MaxTTL {
+		return logical.ErrorResponse("ttl cannot be greater than max_ttl"), nil
+	}
+
+	for _, roleName := range set.StaticRoleNames {
+		role, err := b.StaticRole(ctx, req.Storage, roleName)
+		if err != nil {
+			return nil, err
+		}
+		if role == nil {
+			return logical.ErrorResponse("static role %q does not exist", roleName), nil
+		}
+
+		setName, err := b.librarySetForStaticRole(ctx, req.Storage, roleName)
+		if err != nil {
+			return nil, err
+		}
+		if setName != "" && setName != name {
+			return logical.ErrorResponse("static role %q already belongs to library set %q", roleName, setName), nil
+		}
+	}
+
+	// Removing a checked out account from the set would leave it checked out
+	// forever.
+	for _, roleName := range previousRoleNames {
+		if strutil.StrListContains(set.StaticRoleNames, roleName) {
+			continue
+		}
+		checkOut, err := b.libraryCheckOut(ctx, req.Storage, roleName)
+		if err != nil {
+			return nil, err
+		}
+		if checkOut != nil {
+			return logical.ErrorResponse("static role %q is checked out; check it in before removing it from the set", roleName), nil
+		}
+	}
+
+	entry, err := logical.StorageEntryJSON(databaseLibraryPath+name, set)
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Put(ctx, entry); err != nil {
+		return nil, err
+	}
+
+	b.dbEvent(ctx, "library-set-write", req.Path, name, true)
+	return nil, nil
+}
+
+func (b *databaseBackend) pathLibrarySetDelete(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := data.Get("name").(string)
+
+	lock := locksutil.LockForKey(b.libraryLocks, name)
+	lock.Lock()
+	defer lock.Unlock()
+
+	set, err := b.LibrarySet(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if set == nil {
+		return nil, nil
+	}
+
+	checkedOut, err := b.libraryCheckOuts(ctx, req.Storage, set)
+	if err != nil {
+		return nil, err
+	}
+	if len(checkedOut) != 0 {
+		roleNames := make([]string, 0, len(checkedOut))
+		for roleName := range checkedOut {
+			roleNames = append(roleNames, roleName)
+		}
+		sort.Strings(roleNames)
+		return logical.ErrorResponse("static roles %v are checked out; check them in before deleting the set", roleNames), nil
+	}
+
+	if err := req.Storage.Delete(ctx, databaseLibraryPath+name); err != nil {
+		return nil, err
+	}
+
+	b.dbEvent(ctx, "library-set-delete", req.Path, name, true)
+	return nil, nil
+}
+
+const pathLibrarySetHelpSyn = `
+Manage library sets of static roles whose accounts are checked out exclusively.
+`
+
+const pathLibrarySetHelpDesc = `
+This path lets you manage library sets. A library set is a pool of static
+roles whose accounts are shared by checking them out: while an account is
+checked out, it is leased exclusively to the caller which checked it out, and
+its credential is not rotated on schedule. When the account is checked in,
+either explicitly or when its lease expires, its credential is rotated before
+it becomes available to the next caller.
+
+The "ttl" and "max_ttl" fields bound how long an account can be checked out.
+Unless "disable_check_in_enforcement" is set, only the caller which checked
+an account out can check it in; operators can always force a check-in at
+"library/manage/<name>/check-in".
+`
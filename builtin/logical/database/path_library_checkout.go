@@ -0,0 +1,561 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package database
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hashicorp/go-secure-stdlib/strutil"
+	"github.com/hashicorp/go-uuid"
+	v5 "github.com/hashicorp/vault/sdk/database/dbplugin/v5"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/locksutil"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const SecretLibraryCredsType = "library_creds"
+
+// libraryCheckOut records that the account of a static role is checked out
+// of a library set.
+type libraryCheckOut struct {
+	// ID identifies this check-out, so that the expiry of an earlier lease
+	// does not check in a later check-out of the same account.
+	ID                          string    `json:"id"`
+	SetName                     string    `json:"set_name"`
+	BorrowerEntityID            string    `json:"borrower_entity_id"`
+	BorrowerClientTokenAccessor string    `json:"borrower_client_token_accessor"`
+	CheckedOutAt                time.Time `json:"checked_out_at"`
+	ExpiresAt                   time.Time `json:"expires_at"`
+}
+
+// isBorrower returns true if the request was made by the caller which
+// checked the account out.
+func (c *libraryCheckOut) isBorrower(req *logical.Request) bool {
+	if c.BorrowerEntityID != "" {
+		return c.BorrowerEntityID == req.EntityID
+	}
+	return c.BorrowerClientTokenAccessor != "" && c.BorrowerClientTokenAccessor == req.ClientTokenAccessor
+}
+
+func secretLibraryCreds(b *databaseBackend) *framework.Secret {
+	return &framework.Secret{
+		Type:   SecretLibraryCredsType,
+		Fields: map[string]*framework.FieldSchema{},
+
+		Renew:  b.secretLibraryCredsRenew(),
+		Revoke: b.secretLibraryCredsRevoke(),
+	}
+}
+
+func pathLibraryCheckOut(b *databaseBackend) []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern: "library/" + framework.GenericNameRegex("name") + "/check-out$",
+
+			DisplayAttrs: &framework.DisplayAttributes{
+				OperationPrefix: operationPrefixDatabase,
+				OperationVerb:   "check-out",
+				OperationSuffix: "library-account",
+			},
+
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeString,
+					Description: "Name of the library set.",
+				},
+				"ttl": {
+					Type: framework.TypeDurationSecond,
+					Description: "Time for which the account is checked out. Defaults to, and " +
+						"cannot exceed, the set's ttl.",
+				},
+			},
+
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.UpdateOperation: b.pathLibraryCheckOut,
+			},
+
+			HelpSynopsis:    pathLibraryCheckOutHelpSyn,
+			HelpDescription: pathLibraryCheckOutHelpDesc,
+		},
+		{
+			Pattern: "library/" + framework.GenericNameRegex("name") + "/check-in$",
+
+			DisplayAttrs: &framework.DisplayAttributes{
+				OperationPrefix: operationPrefixDatabase,
+				OperationVerb:   "check-in",
+				OperationSuffix: "library-accounts",
+			},
+
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeString,
+					Description: "Name of the library set.",
+				},
+				"static_role_names": {
+					Type: framework.TypeCommaStringSlice,
+					Description: "Names of the static roles to check in. May be omitted if the " +
+						"caller has checked out exactly one account of the set.",
+				},
+			},
+
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.UpdateOperation: b.pathLibraryCheckIn(false),
+			},
+
+			HelpSynopsis:    pathLibraryCheckInHelpSyn,
+			HelpDescription: pathLibraryCheckInHelpDesc,
+		},
+		{
+			Pattern: "library/manage/" + framework.GenericNameRegex("name") + "/check-in$",
+
+			DisplayAttrs: &framework.DisplayAttributes{
+				OperationPrefix: operationPrefixDatabase,
+				OperationVerb:   "force-check-in",
+				OperationSuffix: "library-accounts",
+			},
+
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeString,
+					Description: "Name of the library set.",
+				},
+				"static_role_names": {
+					Type:        framework.TypeCommaStringSlice,
+					Description: "Names of the static roles to check in. Defaults to all checked out accounts of the set.",
+				},
+			},
+
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.UpdateOperation: b.pathLibraryCheckIn(true),
+			},
+
+			HelpSynopsis:    pathLibraryForceCheckInHelpSyn,
+			HelpDescription: pathLibraryForceCheckInHelpDesc,
+		},
+		{
+			Pattern: "library/" + framework.GenericNameRegex("name") + "/status$",
+
+			DisplayAttrs: &framework.DisplayAttributes{
+				OperationPrefix: operationPrefixDatabase,
+				OperationVerb:   "read",
+				OperationSuffix: "library-status",
+			},
+
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeString,
+					Description: "Name of the library set.",
+				},
+			},
+
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.ReadOperation: b.pathLibraryStatus,
+			},
+
+			HelpSynopsis:    pathLibraryStatusHelpSyn,
+			HelpDescription: pathLibraryStatusHelpDesc,
+		},
+	}
+}
+
+func (b *databaseBackend) libraryCheckOut(ctx context.Context, s logical.Storage, roleName string) (*libraryCheckOut, error) {
+	entry, err := s.Get(ctx, databaseLibraryCheckOutPath+roleName)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var result libraryCheckOut
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// libraryCheckOuts returns the check-outs of the set's accounts, keyed by
+// static role name.
+func (b *databaseBackend) libraryCheckOuts(ctx context.Context, s logical.Storage, set *librarySet) (map[string]*libraryCheckOut, error) {
+	checkOuts := make(map[string]*libraryCheckOut)
+	for _, roleName := range set.StaticRoleNames {
+		checkOut, err := b.libraryCheckOut(ctx, s, roleName)
+		if err != nil {
+			return nil, err
+		}
+		if checkOut != nil {
+			checkOuts[roleName] = checkOut
+		}
+	}
+
+	return checkOuts, nil
+}
+
+func putLibraryCheckOut(ctx context.Context, s logical.Storage, roleName string, checkOut *libraryCheckOut) error {
+	entry, err := logical.StorageEntryJSON(databaseLibraryCheckOutPath+roleName, checkOut)
+	if err != nil {
+		return err
+	}
+	return s.Put(ctx, entry)
+}
+
+func (b *databaseBackend) pathLibraryCheckOut(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	setName := data.Get("name").(string)
+
+	lock := locksutil.LockForKey(b.libraryLocks, setName)
+	lock.Lock()
+	defer lock.Unlock()
+
+	set, err := b.LibrarySet(ctx, req.Storage, setName)
+	if err != nil {
+		return nil, err
+	}
+	if set == nil {
+		return logical.ErrorResponse("unknown library set: %s", setName), nil
+	}
+
+	requestedTTL := time.Duration(data.Get("ttl").(int)) * time.Second
+	if set.TTL > 0 && requestedTTL > set.TTL {
+		return logical.ErrorResponse("ttl cannot be greater than the set's ttl of %s", set.TTL), nil
+	}
+	if requestedTTL == 0 {
+		requestedTTL = set.TTL
+	}
+	ttl, _, err := framework.CalculateTTL(b.System(), 0, requestedTTL, 0, set.MaxTTL, 0, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+
+	checkOuts, err := b.libraryCheckOuts(ctx, req.Storage, set)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, roleName := range set.StaticRoleNames {
+		if checkOuts[roleName] != nil {
+			continue
+		}
+
+		role, err := b.StaticRole(ctx, req.Storage, roleName)
+		if err != nil {
+			return nil, err
+		}
+		if role == nil || role.StaticAccount == nil {
+			b.Logger().Warn("skipping missing static role in library set", "set", setName, "role", roleName)
+			continue
+		}
+
+		id, err := uuid.GenerateUUID()
+		if err != nil {
+			return nil, err
+		}
+		now := time.Now()
+		checkOut := &libraryCheckOut{
+			ID:                          id,
+			SetName:                     setName,
+			BorrowerEntityID:            req.EntityID,
+			BorrowerClientTokenAccessor: req.ClientTokenAccessor,
+			CheckedOutAt:                now,
+			ExpiresAt:                   now.Add(ttl),
+		}
+		if err := putLibraryCheckOut(ctx, req.Storage, roleName, checkOut); err != nil {
+			return nil, err
+		}
+
+		respData := map[string]interface{}{
+			"static_role_name": roleName,
+			"username":         role.StaticAccount.Username,
+		}
+		switch role.CredentialType {
+		case v5.CredentialTypePassword:
+			respData["password"] = role.StaticAccount.Password
+		case v5.CredentialTypeRSAPrivateKey:
+			respData["rsa_private_key"] = string(role.StaticAccount.PrivateKey)
+		}
+
+		resp := b.Secret(SecretLibraryCredsType).Response(respData, map[string]interface{}{
+			"set_name":         setName,
+			"static_role_name": roleName,
+			"check_out_id":     id,
+		})
+		resp.Secret.TTL = ttl
+		resp.Secret.MaxTTL = set.MaxTTL
+
+		b.dbEvent(ctx, "library-check-out", req.Path, setName, true, "static_role_name", roleName)
+		return resp, nil
+	}
+
+	return logical.ErrorResponse("no accounts are available for check-out in library set %q", setName), nil
+}
+
+func (b *databaseBackend) pathLibraryCheckIn(force bool) framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		setName := data.Get("name").(string)
+
+		lock := locksutil.LockForKey(b.libraryLocks, setName)
+		lock.Lock()
+		defer lock.Unlock()
+
+		set, err := b.LibrarySet(ctx, req.Storage, setName)
+		if err != nil {
+			return nil, err
+		}
+		if set == nil {
+			return logical.ErrorResponse("unknown library set: %s", setName), nil
+		}
+
+		checkOuts, err := b.libraryCheckOuts(ctx, req.Storage, set)
+		if err != nil {
+			return nil, err
+		}
+
+		roleNames := data.Get("static_role_names").([]string)
+		for _, roleName := range roleNames {
+			if !strutil.StrListContains(set.StaticRoleNames, roleName) {
+				return logical.ErrorResponse("static role %q does not belong to library set %q", roleName, setName), nil
+			}
+		}
+
+		enforce := !force && !set.DisableCheckInEnforcement
+		if len(roleNames) == 0 {
+			for roleName, checkOut := range checkOuts {
+				if force || checkOut.isBorrower(req) {
+					roleNames = append(roleNames, roleName)
+				}
+			}
+			sort.Strings(roleNames)
+
+			if !force && len(roleNames) != 1 {
+				return logical.ErrorResponse("the caller has %d accounts of library set %q checked out; "+
+					"specify the static_role_names to check in", len(roleNames), setName), nil
+			}
+		}
+
+		for _, roleName := range roleNames {
+			checkOut := checkOuts[roleName]
+			if checkOut != nil && enforce && !checkOut.isBorrower(req) {
+				return logical.ErrorResponse("static role %q was not checked out by the caller", roleName), nil
+			}
+		}
+
+		checkIns := []string{}
+		for _, roleName := range roleNames {
+			// Checking in an available account is a no-op
+			if checkOuts[roleName] == nil {
+				continue
+			}
+			if err := b.checkInLibraryAccount(ctx, req.Storage, roleName); err != nil {
+				return nil, err
+			}
+			checkIns = append(checkIns, roleName)
+			b.dbEvent(ctx, "library-check-in", req.Path, setName, true, "static_role_name", roleName)
+		}
+
+		return &logical.Response{
+			Data: map[string]interface{}{
+				"check_ins": checkIns,
+			},
+		}, nil
+	}
+}
+
+// checkInLibraryAccount rotates the credential of a checked out static role,
+// so that the borrower can no longer use it, and then makes the account
+// available again. If the rotation fails the account stays checked out, so
+// the check-in can safely be retried. The caller must hold the library set's
+// lock.
+func (b *databaseBackend) checkInLibraryAccount(ctx context.Context, s logical.Storage, roleName string) error {
+	lock := locksutil.LockForKey(b.roleLocks, roleName)
+	lock.Lock()
+	defer lock.Unlock()
+
+	role, err := b.StaticRole(ctx, s, roleName)
+	if err != nil {
+		return err
+	}
+	if role != nil {
+		if err := b.rotateStaticRole(ctx, s, roleName, role); err != nil {
+			return fmt.Errorf("unable to rotate credentials of static role %q on check-in: %w", roleName, err)
+		}
+	}
+
+	return s.Delete(ctx, databaseLibraryCheckOutPath+roleName)
+}
+
+func (b *databaseBackend) pathLibraryStatus(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	setName := data.Get("name").(string)
+
+	set, err := b.LibrarySet(ctx, req.Storage, setName)
+	if err != nil {
+		return nil, err
+	}
+	if set == nil {
+		return nil, nil
+	}
+
+	checkOuts, err := b.libraryCheckOuts(ctx, req.Storage, set)
+	if err != nil {
+		return nil, err
+	}
+
+	respData := make(map[string]interface{}, len(set.StaticRoleNames))
+	for _, roleName := range set.StaticRoleNames {
+		status := map[string]interface{}{
+			"available": true,
+		}
+		if checkOut := checkOuts[roleName]; checkOut != nil {
+			status["available"] = false
+			status["borrower_entity_id"] = checkOut.BorrowerEntityID
+			status["borrower_client_token_accessor"] = checkOut.BorrowerClientTokenAccessor
+			status["checked_out_at"] = checkOut.CheckedOutAt
+			status["expires_at"] = checkOut.ExpiresAt
+		}
+		respData[roleName] = status
+	}
+
+	return &logical.Response{
+		Data: respData,
+	}, nil
+}
+
+// libraryCheckOutFromSecret returns the check-out which the secret was issued
+// for, or nil if the account has since been checked in.
+func (b *databaseBackend) libraryCheckOutFromSecret(ctx context.Context, req *logical.Request) (string, *libraryCheckOut, error) {
+	roleName, ok := req.Secret.InternalData["static_role_name"].(string)
+	if !ok {
+		return "", nil, fmt.Errorf("secret is missing static_role_name internal data")
+	}
+	checkOutID, ok := req.Secret.InternalData["check_out_id"].(string)
+	if !ok {
+		return "", nil, fmt.Errorf("secret is missing check_out_id internal data")
+	}
+
+	checkOut, err := b.libraryCheckOut(ctx, req.Storage, roleName)
+	if err != nil {
+		return "", nil, err
+	}
+	if checkOut == nil || checkOut.ID != checkOutID {
+		return roleName, nil, nil
+	}
+
+	return roleName, checkOut, nil
+}
+
+func (b *databaseBackend) secretLibraryCredsRenew() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		setName, ok := req.Secret.InternalData["set_name"].(string)
+		if !ok {
+			return nil, fmt.Errorf("secret is missing set_name internal data")
+		}
+
+		lock := locksutil.LockForKey(b.libraryLocks, setName)
+		lock.Lock()
+		defer lock.Unlock()
+
+		roleName, checkOut, err := b.libraryCheckOutFromSecret(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		if checkOut == nil {
+			return logical.ErrorResponse("static role %q has already been checked in", roleName), nil
+		}
+
+		set, err := b.LibrarySet(ctx, req.Storage, setName)
+		if err != nil {
+			return nil, err
+		}
+		if set == nil {
+			return nil, fmt.Errorf("error during renew: could not find library set with name %q", setName)
+		}
+
+		ttl, _, err := framework.CalculateTTL(b.System(), req.Secret.Increment, set.TTL, 0, set.MaxTTL, 0, req.Secret.IssueTime)
+		if err != nil {
+			return nil, err
+		}
+
+		checkOut.ExpiresAt = time.Now().Add(ttl)
+		if err := putLibraryCheckOut(ctx, req.Storage, roleName, checkOut); err != nil {
+			return nil, err
+		}
+
+		resp := &logical.Response{Secret: req.Secret}
+		resp.Secret.TTL = ttl
+		resp.Secret.MaxTTL = set.MaxTTL
+		return resp, nil
+	}
+}
+
+func (b *databaseBackend) secretLibraryCredsRevoke() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		setName, ok := req.Secret.InternalData["set_name"].(string)
+		if !ok {
+			return nil, fmt.Errorf("secret is missing set_name internal data")
+		}
+
+		lock := locksutil.LockForKey(b.libraryLocks, setName)
+		lock.Lock()
+		defer lock.Unlock()
+
+		roleName, checkOut, err := b.libraryCheckOutFromSecret(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		// The account was already checked in, and may have been checked out
+		// again since.
+		if checkOut == nil {
+			return nil, nil
+		}
+
+		if err := b.checkInLibraryAccount(ctx, req.Storage, roleName); err != nil {
+			return nil, err
+		}
+		b.dbEvent(ctx, "library-check-in", req.Path, setName, true, "static_role_name", roleName)
+		return nil, nil
+	}
+}
+
+const pathLibraryCheckOutHelpSyn = `
+Check out an account of a library set.
+`
+
+const pathLibraryCheckOutHelpDesc = `
+This path checks out the first available account of the library set, and
+returns its credentials as a lease. The account is exclusively checked out
+until it is checked in, or until the lease expires or is revoked. Its
+credential is rotated when it is checked in.
+`
+
+const pathLibraryCheckInHelpSyn = `
+Check in accounts checked out of a library set.
+`
+
+const pathLibraryCheckInHelpDesc = `
+This path checks in accounts checked out of the library set, rotating their
+credentials. Unless the set disables check-in enforcement, only the caller
+which checked out an account can check it in.
+`
+
+const pathLibraryForceCheckInHelpSyn = `
+Force the check-in of accounts checked out of a library set.
+`
+
+const pathLibraryForceCheckInHelpDesc = `
+This path checks in accounts checked out of the library set regardless of
+which caller checked them out, rotating their credentials. If no
+static_role_names are given, all checked out accounts of the set are checked
+in.
+`
+
+const pathLibraryStatusHelpSyn = `
+Read the check-out status of the accounts of a library set.
+`
+
+const pathLibraryStatusHelpDesc = `
+This path returns, for each static role in the library set, whether its
+account is available and, if it is checked out, by whom and until when.
+`
@@ -0,0 +1,319 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v5 "github.com/hashicorp/vault/sdk/database/dbplugin/v5"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackend_Library_CheckOutCheckIn(t *testing.T) {
+	ctx := context.Background()
+	b, storage, mockDB := getBackend(t)
+	defer b.Cleanup(ctx)
+	configureDBMount(t, storage)
+
+	for _, roleName := range []string{"lib-a", "lib-b"} {
+		createRole(t, b, storage, mockDB, roleName)
+	}
+
+	request := func(req *logical.Request) (*logical.Response, error) {
+		req.Storage = storage
+		return b.HandleRequest(ctx, req)
+	}
+	handle := func(req *logical.Request) *logical.Response {
+		t.Helper()
+		resp, err := request(req)
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatal(resp, err)
+		}
+		return resp
+	}
+	requireError := func(req *logical.Request) {
+		t.Helper()
+		resp, err := request(req)
+		require.NoError(t, err)
+		require.True(t, resp != nil && resp.IsError(), "expected an error response, got %#v", resp)
+	}
+	expectRotation := func() {
+		mockDB.On("UpdateUser", mock.Anything, mock.Anything).
+			Return(v5.UpdateUserResponse{}, nil).
+			Once()
+	}
+	password := func(roleName string) string {
+		role, err := b.StaticRole(ctx, storage, roleName)
+		require.NoError(t, err)
+		return role.StaticAccount.Password
+	}
+
+	handle(&logical.Request{
+		Operation: logical.CreateOperation,
+		Path:      "library/team",
+		Data: map[string]interface{}{
+			"static_role_names": "lib-a,lib-b",
+			"ttl":               "1h",
+			"max_ttl":           "2h",
+		},
+	})
+
+	// A static role can only belong to one set, and can't be deleted while
+	// it does.
+	requireError(&logical.Request{
+		Operation: logical.CreateOperation,
+		Path:      "library/other",
+		Data:      map[string]interface{}{"static_role_names": "lib-a"},
+	})
+	requireError(&logical.Request{
+		Operation: logical.DeleteOperation,
+		Path:      "static-roles/lib-a",
+	})
+
+	// Each caller checks out a different account.
+	checkOut := func(entityID string) *logical.Response {
+		t.Helper()
+		return handle(&logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "library/team/check-out",
+			EntityID:  entityID,
+		})
+	}
+	respA := checkOut("entity-a")
+	require.Equal(t, "lib-a", respA.Data["static_role_name"])
+	require.Equal(t, "lib-a", respA.Data["username"])
+	require.Equal(t, password("lib-a"), respA.Data["password"])
+	require.Equal(t, time.Hour, respA.Secret.TTL)
+	require.Equal(t, 2*time.Hour, respA.Secret.MaxTTL)
+
+	requireError(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "library/team/check-out",
+		Data:      map[string]interface{}{"ttl": "3h"},
+	})
+
+	respB := checkOut("entity-b")
+	require.Equal(t, "lib-b", respB.Data["static_role_name"])
+
+	requireError(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "library/team/check-out",
+		EntityID:  "entity-c",
+	})
+
+	statusResp := handle(&logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "library/team/status",
+	})
+	statusA := statusResp.Data["lib-a"].(map[string]interface{})
+	require.Equal(t, false, statusA["available"])
+	require.Equal(t, "entity-a", statusA["borrower_entity_id"])
+
+	// Checked out accounts aren't rotated on schedule, but once their
+	// check-out is due.
+	item, err := b.popFromRotationQueueByKey("lib-b")
+	require.NoError(t, err)
+	item.Priority = time.Now().Unix()
+	require.NoError(t, b.pushItem(item))
+	require.True(t, b.rotateCredential(ctx, storage))
+	item, err = b.popFromRotationQueueByKey("lib-b")
+	require.NoError(t, err)
+	checkOutB, err := b.libraryCheckOut(ctx, storage, "lib-b")
+	require.NoError(t, err)
+	require.Equal(t, checkOutB.ExpiresAt.Unix(), item.Priority)
+	require.NoError(t, b.pushItem(item))
+
+	// Only the borrower can check an account in, which rotates its password.
+	requireError(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "library/team/check-in",
+		EntityID:  "entity-b",
+		Data:      map[string]interface{}{"static_role_names": "lib-a"},
+	})
+
+	expectRotation()
+	checkInResp := handle(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "library/team/check-in",
+		EntityID:  "entity-a",
+	})
+	require.Equal(t, []string{"lib-a"}, checkInResp.Data["check_ins"])
+	require.NotEqual(t, respA.Data["password"], password("lib-a"))
+
+	// The expiry of the lease no longer checks the account in.
+	handle(&logical.Request{
+		Operation: logical.RevokeOperation,
+		Secret:    respA.Secret,
+	})
+
+	respA = checkOut("entity-c")
+	require.Equal(t, "lib-a", respA.Data["static_role_name"])
+
+	// Renewals extend the check-out.
+	renewResp := handle(&logical.Request{
+		Operation: logical.RenewOperation,
+		Secret:    respB.Secret,
+	})
+	require.Equal(t, time.Hour, renewResp.Secret.TTL)
+
+	// Operators can check in accounts regardless of who checked them out.
+	expectRotation()
+	expectRotation()
+	checkInResp = handle(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "library/manage/team/check-in",
+	})
+	require.Equal(t, []string{"lib-a", "lib-b"}, checkInResp.Data["check_ins"])
+
+	statusResp = handle(&logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "library/team/status",
+	})
+	require.Equal(t, map[string]interface{}{"available": true}, statusResp.Data["lib-b"])
+
+	// A checked in account's lease can no longer be renewed.
+	requireError(&logical.Request{
+		Operation: logical.RenewOperation,
+		Secret:    respB.Secret,
+	})
+
+	handle(&logical.Request{
+		Operation: logical.DeleteOperation,
+		Path:      "library/team",
+	})
+	handle(&logical.Request{
+		Operation: logical.DeleteOperation,
+		Path:      "static-roles/lib-a",
+	})
+	mockDB.AssertNumberOfCalls(t, "UpdateUser", 5)
+}
+
+func TestBackend_Library_DeleteCheckedOut(t *testing.T) {
+	ctx := context.Background()
+	b, storage, mockDB := getBackend(t)
+	defer b.Cleanup(ctx)
+	configureDBMount(t, storage)
+	createRole(t, b, storage, mockDB, "lib-a")
+
+	handle := func(req *logical.Request) *logical.Response {
+		t.Helper()
+		req.Storage = storage
+		resp, err := b.HandleRequest(ctx, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	resp := handle(&logical.Request{
+		Operation: logical.CreateOperation,
+		Path:      "library/team",
+		Data:      map[string]interface{}{"static_role_names": "lib-a"},
+	})
+	require.Nil(t, resp)
+
+	resp = handle(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "library/team/check-out",
+		EntityID:  "entity-a",
+	})
+	require.False(t, resp.IsError())
+
+	// Neither the set nor the checked out account can be removed.
+	resp = handle(&logical.Request{
+		Operation: logical.DeleteOperation,
+		Path:      "library/team",
+	})
+	require.True(t, resp.IsError())
+
+	createRole(t, b, storage, mockDB, "lib-b")
+	resp = handle(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "library/team",
+		Data:      map[string]interface{}{"static_role_names": "lib-b"},
+	})
+	require.True(t, resp.IsError())
+}
+
+func TestBackend_Library_CheckedOutCredentials(t *testing.T) {
+	ctx := context.Background()
+	b, storage, mockDB := getBackend(t)
+	defer b.Cleanup(ctx)
+	configureDBMount(t, storage)
+	createRole(t, b, storage, mockDB, "lib-a")
+
+	handle := func(req *logical.Request) *logical.Response {
+		t.Helper()
+		req.Storage = storage
+		resp, err := b.HandleRequest(ctx, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	resp := handle(&logical.Request{
+		Operation: logical.CreateOperation,
+		Path:      "library/team",
+		Data:      map[string]interface{}{"static_role_names": "lib-a"},
+	})
+	require.Nil(t, resp)
+
+	resp = handle(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "library/team/check-out",
+		EntityID:  "entity-a",
+	})
+	require.False(t, resp.IsError())
+	password := resp.Data["password"]
+
+	// While checked out, the account's credentials can be neither read
+	// through its static role nor rotated.
+	resp = handle(&logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "static-creds/lib-a",
+	})
+	require.True(t, resp.IsError())
+	require.Contains(t, resp.Error().Error(), "checked out")
+
+	resp = handle(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "rotate-role/lib-a",
+	})
+	require.True(t, resp.IsError())
+	require.Contains(t, resp.Error().Error(), "checked out")
+	mockDB.AssertNumberOfCalls(t, "UpdateUser", 1)
+
+	mockDB.On("UpdateUser", mock.Anything, mock.Anything).
+		Return(v5.UpdateUserResponse{}, nil).
+		Once()
+	resp = handle(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "library/team/check-in",
+		EntityID:  "entity-a",
+	})
+	require.False(t, resp.IsError())
+
+	// Once checked in, both are allowed again.
+	resp = handle(&logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "static-creds/lib-a",
+	})
+	require.False(t, resp.IsError())
+	require.NotEqual(t, password, resp.Data["password"])
+
+	mockDB.On("UpdateUser", mock.Anything, mock.Anything).
+		Return(v5.UpdateUserResponse{}, nil).
+		Once()
+	resp = handle(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "rotate-role/lib-a",
+	})
+	require.Nil(t, resp)
+	mockDB.AssertNumberOfCalls(t, "UpdateUser", 3)
+}
@@ -255,10 +255,18 @@ func (b *databaseBackend) pathStaticRoleDelete(ctx context.Context, req *logical
 	lock.Lock()
 	defer lock.Unlock()
 
+	setName, err := b.librarySetForStaticRole(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if setName != "" {
+		return logical.ErrorResponse("static role %q belongs to library set %q; remove it from the set before deleting it", name, setName), nil
+	}
+
 	// Remove the item from the queue
 	_, _ = b.popFromRotationQueueByKey(name)
 
-	err := req.Storage.Delete(ctx, databaseStaticRolePath+name)
+	err = req.Storage.Delete(ctx, databaseStaticRolePath+name)
 	if err != nil {
 		return nil, err
 	}
@@ -6,13 +6,11 @@ package database
 import (
 	"context"
 	"fmt"
-	"time"
 
 	"github.com/hashicorp/vault/helper/versions"
 	v5 "github.com/hashicorp/vault/sdk/database/dbplugin/v5"
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/logical"
-	"github.com/hashicorp/vault/sdk/queue"
 )
 
 func pathRotateRootCredentials(b *databaseBackend) []*framework.Path {
@@ -211,54 +209,24 @@ func (b *databaseBackend) pathRotateRoleCredentialsUpdate() framework.OperationF
 			return logical.ErrorResponse("no static role found for role name"), nil
 		}
 
-		// In create/update of static accounts, we only care if the operation
-		// err'd , and this call does not return credentials
-		item, err := b.popFromRotationQueueByKey(name)
-		if err != nil {
-			item = &queue.Item{
-				Key: name,
-			}
-		}
-
-		input := &setStaticAccountInput{
-			RoleName: name,
-			Role:     role,
-		}
-		if walID, ok := item.Value.(string); ok {
-			input.WALID = walID
-		}
-		resp, err := b.setStaticAccount(ctx, req.Storage, input)
-		// if err is not nil, we need to attempt to update the priority and place
-		// this item back on the queue. The err should still be returned at the end
-		// of this method.
+		// Rotating a checked out library account would lock its borrower
+		// out; it is rotated when checked in instead.
+		checkOut, err := b.libraryCheckOut(ctx, req.Storage, name)
 		if err != nil {
-			b.logger.Warn("unable to rotate credentials in rotate-role", "error", err)
-			// Update the priority to re-try this rotation and re-add the item to
-			// the queue
-			item.Priority = time.Now().Add(10 * time.Second).Unix()
-
-			// Preserve the WALID if it was returned
-			if resp != nil && resp.WALID != "" {
-				item.Value = resp.WALID
-			}
-		} else {
-			item.Priority = role.StaticAccount.NextRotationTimeFromInput(resp.RotationTime).Unix()
-			// Clear any stored WAL ID as we must have successfully deleted our WAL to get here.
-			item.Value = ""
-			modified = true
-		}
-
-		// Add their rotation to the queue
-		if err := b.pushItem(item); err != nil {
 			return nil, err
 		}
+		if checkOut != nil {
+			return logical.ErrorResponse("static role %q is checked out of library set %q; it is rotated when checked in", name, checkOut.SetName), nil
+		}
 
-		if err != nil {
+		// In create/update of static accounts, we only care if the operation
+		// err'd , and this call does not return credentials
+		if err := b.rotateStaticRole(ctx, req.Storage, name, role); err != nil {
 			return nil, fmt.Errorf("unable to finish rotating credentials; retries will "+
 				"continue in the background but it is also safe to retry manually: %w", err)
 		}
+		modified = true
 
-		// return any err from the setStaticAccount call
 		return nil, nil
 	}
 }
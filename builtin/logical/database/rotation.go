@@ -252,6 +252,30 @@ func (b *databaseBackend) rotateCredential(ctx context.Context, s logical.Storag
 		return false
 	}
 
+	// The credential of a checked out library account is rotated when it is
+	// checked in, so defer the rotation until the check-out is due to expire.
+	checkOut, err := b.libraryCheckOut(ctx, s, roleName)
+	if err != nil {
+		logger.Error("unable to load library check-out", "error", err)
+
+		item.Priority = now.Add(10 * time.Second).Unix()
+		if err := b.pushItem(item); err != nil {
+			logger.Error("unable to push item on to queue", "error", err)
+		}
+		return true
+	}
+	if checkOut != nil {
+		next := checkOut.ExpiresAt
+		if !next.After(now) {
+			next = now.Add(10 * time.Second)
+		}
+		item.Priority = next.Unix()
+		if err := b.pushItem(item); err != nil {
+			logger.Error("unable to push item on to queue", "error", err)
+		}
+		return true
+	}
+
 	// send an event indicating if the rotation was a success or failure
 	rotated := false
 	defer func() {
@@ -305,6 +329,53 @@ func (b *databaseBackend) rotateCredential(ctx context.Context, s logical.Storag
 	return true
 }
 
+// rotateStaticRole rotates the credential of a static role immediately,
+// regardless of its rotation schedule, and requeues the role for its next
+// rotation. If the rotation fails, the role is requeued so that the rotation
+// is retried shortly, and the error is returned.
+func (b *databaseBackend) rotateStaticRole(ctx context.Context, s logical.Storage, name string, role *roleEntry) error {
+	item, err := b.popFromRotationQueueByKey(name)
+	if err != nil {
+		item = &queue.Item{
+			Key: name,
+		}
+	}
+
+	input := &setStaticAccountInput{
+		RoleName: name,
+		Role:     role,
+	}
+	if walID, ok := item.Value.(string); ok {
+		input.WALID = walID
+	}
+	resp, err := b.setStaticAccount(ctx, s, input)
+	// if err is not nil, we need to attempt to update the priority and place
+	// this item back on the queue. The err should still be returned at the end
+	// of this method.
+	if err != nil {
+		b.logger.Warn("unable to rotate credentials", "role", name, "error", err)
+		// Update the priority to re-try this rotation and re-add the item to
+		// the queue
+		item.Priority = time.Now().Add(10 * time.Second).Unix()
+
+		// Preserve the WALID if it was returned
+		if resp != nil && resp.WALID != "" {
+			item.Value = resp.WALID
+		}
+	} else {
+		item.Priority = role.StaticAccount.NextRotationTimeFromInput(resp.RotationTime).Unix()
+		// Clear any stored WAL ID as we must have successfully deleted our WAL to get here.
+		item.Value = ""
+	}
+
+	// Add their rotation to the queue
+	if err := b.pushItem(item); err != nil {
+		return err
+	}
+
+	return err
+}
+
 // findStaticWAL loads a WAL entry by ID. If found, only return the WAL if it
 // is of type staticWALKey, otherwise return nil
 func (b *databaseBackend) findStaticWAL(ctx context.Context, s logical.Storage, id string) (*setCredentialsWAL, error) {
@@ -6,6 +6,10 @@ package pki
 import (
 	"fmt"
 	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/builtin/logical/pki/issuing"
+	"github.com/ryanuber/go-glob"
 )
 
 type EabPolicyName string
@@ -67,3 +71,95 @@ func (ep EabPolicy) IsExternalAccountRequired() bool {
 func (ep EabPolicy) OverrideEnvDisablingPublicAcme() bool {
 	return ep.Name == eabPolicyAlwaysRequired
 }
+
+// eabRestrictions are optional limits an EAB key carries; they are copied
+// onto the ACME account created with the key and enforced on all of its
+// orders, so that a key can be handed out to a team without letting them
+// request certificates outside of their own names.
+type eabRestrictions struct {
+	// AllowedIdentifiers are globs which every identifier of an order must
+	// match, ignoring case; when empty, any identifier allowed by the role
+	// is permitted.
+	AllowedIdentifiers []string `json:"allowed-identifiers,omitempty"`
+
+	// Role, when set, is the only role orders may be placed against.
+	Role string `json:"role,omitempty"`
+
+	// ExpiresOn, when set, is the time after which neither the key nor
+	// accounts created with it may be used.
+	ExpiresOn time.Time `json:"expires-on,omitempty"`
+
+	// MaxOrders and MaxCertificates limit the number of orders placed and
+	// certificates issued per QuotaPeriod; a zero QuotaPeriod counts over
+	// the lifetime of the account.
+	MaxOrders       int           `json:"max-orders,omitempty"`
+	MaxCertificates int           `json:"max-certificates,omitempty"`
+	QuotaPeriod     time.Duration `json:"quota-period,omitempty"`
+}
+
+// EnforceExpiry rejects use of an EAB key, or of an account created with
+// it, after its expiry.
+func (er eabRestrictions) EnforceExpiry(now time.Time) error {
+	if !er.ExpiresOn.IsZero() && now.After(er.ExpiresOn) {
+		return fmt.Errorf("%w: external account binding expired on %s", ErrUnauthorized, er.ExpiresOn.Format(time.RFC3339))
+	}
+
+	return nil
+}
+
+// EnforceForOrder checks the role and identifiers of an order against the
+// restrictions of the account's EAB.
+func (er eabRestrictions) EnforceForOrder(role *issuing.RoleEntry, identifiers []*ACMEIdentifier) error {
+	if er.Role != "" && (role == nil || role.Name != er.Role) {
+		return fmt.Errorf("%w: external account binding only permits orders against role %s", ErrUnauthorized, er.Role)
+	}
+
+	if len(er.AllowedIdentifiers) == 0 {
+		return nil
+	}
+
+	for _, identifier := range identifiers {
+		allowed := false
+		for _, pattern := range er.AllowedIdentifiers {
+			if glob.Glob(strings.ToLower(pattern), strings.ToLower(identifier.OriginalValue)) {
+				allowed = true
+				break
+			}
+		}
+
+		if !allowed {
+			return fmt.Errorf("%w: identifier %s is not allowed by the external account binding", ErrRejectedIdentifier, identifier.OriginalValue)
+		}
+	}
+
+	return nil
+}
+
+// acmeEabUsage records when orders were placed and certificates issued by
+// an account, for enforcing its EAB quotas.
+type acmeEabUsage struct {
+	Orders       []time.Time `json:"orders"`
+	Certificates []time.Time `json:"certificates"`
+}
+
+// consumeQuota records one use at now in uses, provided fewer than limit
+// uses were recorded within the period before. Uses outside of the period
+// are dropped.
+func consumeQuota(uses []time.Time, limit int, period time.Duration, now time.Time) ([]time.Time, bool) {
+	if period > 0 {
+		cutoff := now.Add(-period)
+		kept := uses[:0]
+		for _, use := range uses {
+			if use.After(cutoff) {
+				kept = append(kept, use)
+			}
+		}
+		uses = kept
+	}
+
+	if limit > 0 && len(uses) >= limit {
+		return uses, false
+	}
+
+	return append(uses, now), true
+}
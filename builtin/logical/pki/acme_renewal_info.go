@@ -0,0 +1,261 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package pki
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/builtin/logical/pki/issuing"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	acmeEarlyRenewalPrefix       = acmePathPrefix + "early-renewal/"
+	acmeEarlyRenewalIssuerPrefix = acmeEarlyRenewalPrefix + "issuer/"
+	acmeEarlyRenewalCertPrefix   = acmeEarlyRenewalPrefix + "cert/"
+
+	// How long clients should wait before polling renewal information
+	// again; see RFC 9773 Section 4.3.2.
+	acmeRenewalInfoRetryAfter = 6 * time.Hour
+)
+
+// acmeRenewalWindow is the window within which a client should renew a
+// certificate, as returned by the renewalInfo resource (RFC 9773).
+type acmeRenewalWindow struct {
+	Start          time.Time
+	End            time.Time
+	ExplanationURL string
+}
+
+// acmeEarlyRenewalEntry is an operator-requested renewal window, overriding
+// the one Vault would otherwise suggest, for either a single certificate or
+// every certificate of an issuer issued before the entry was created.
+type acmeEarlyRenewalEntry struct {
+	WindowStart    time.Time `json:"window_start"`
+	WindowEnd      time.Time `json:"window_end"`
+	ExplanationURL string    `json:"explanation_url"`
+	CreatedOn      time.Time `json:"created_on"`
+}
+
+// parseAcmeCertID parses an ARI certificate identifier, the base64url
+// encoded key identifier of the certificate's Authority Key Identifier
+// extension and the base64url encoded DER bytes of its serial number,
+// joined by a period.
+func parseAcmeCertID(certID string) ([]byte, *big.Int, error) {
+	rawAKI, rawSerial, ok := strings.Cut(certID, ".")
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: certificate identifier %q is not of the form <aki>.<serial>", ErrMalformed, certID)
+	}
+
+	aki, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(rawAKI, "="))
+	if err != nil || len(aki) == 0 {
+		return nil, nil, fmt.Errorf("%w: certificate identifier %q has an invalid authority key identifier", ErrMalformed, certID)
+	}
+
+	serialBytes, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(rawSerial, "="))
+	if err != nil || len(serialBytes) == 0 || serialBytes[0]&0x80 != 0 {
+		return nil, nil, fmt.Errorf("%w: certificate identifier %q has an invalid serial number", ErrMalformed, certID)
+	}
+
+	return aki, new(big.Int).SetBytes(serialBytes), nil
+}
+
+// acmeCertID builds the ARI certificate identifier of a certificate.
+func acmeCertID(cert *x509.Certificate) string {
+	// The serial is encoded as the contents of its DER INTEGER, which has a
+	// leading zero byte when the high bit would otherwise be set.
+	serialBytes := cert.SerialNumber.Bytes()
+	if len(serialBytes) == 0 || serialBytes[0]&0x80 != 0 {
+		serialBytes = append([]byte{0}, serialBytes...)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(cert.AuthorityKeyId) + "." + base64.RawURLEncoding.EncodeToString(serialBytes)
+}
+
+// fetchCertForAcmeCertID loads the stored certificate an ARI certificate
+// identifier refers to, ensuring its authority key identifier matches.
+func fetchCertForAcmeCertID(sc *storageContext, certID string) (*x509.Certificate, error) {
+	aki, serial, err := parseAcmeCertID(certID)
+	if err != nil {
+		return nil, err
+	}
+
+	entry, err := fetchCertBySerialBigInt(sc, "certs/", serial)
+	if err != nil {
+		return nil, fmt.Errorf("failed loading certificate %s: %w", serialFromBigInt(serial), err)
+	}
+	if entry == nil {
+		return nil, fmt.Errorf("%w: no certificate with serial number %s was issued by this mount", ErrMalformed, serialFromBigInt(serial))
+	}
+
+	cert, err := x509.ParseCertificate(entry.Value)
+	if err != nil {
+		return nil, fmt.Errorf("failed parsing certificate %s: %w", serialFromBigInt(serial), err)
+	}
+
+	if !bytes.Equal(cert.AuthorityKeyId, aki) {
+		return nil, fmt.Errorf("%w: certificate identifier %q does not match the authority key identifier of the certificate", ErrMalformed, certID)
+	}
+
+	return cert, nil
+}
+
+// findIssuerOfCert returns the issuer which signed the certificate, or an
+// empty identifier when none of the mount's issuers did.
+func findIssuerOfCert(sc *storageContext, cert *x509.Certificate) (issuing.IssuerID, error) {
+	issuerIDCertMap, err := fetchIssuerMapForRevocationChecking(sc)
+	if err != nil {
+		return "", err
+	}
+
+	for issuerId, issuerCert := range issuerIDCertMap {
+		if bytes.Equal(cert.RawIssuer, issuerCert.RawSubject) && cert.CheckSignatureFrom(issuerCert) == nil {
+			return issuerId, nil
+		}
+	}
+
+	return "", nil
+}
+
+// issuerHandoverTime returns when an issuer with the given rotation
+// configuration is (or was) replaced by its successor, or the zero time
+// when it is not rotated automatically.
+func issuerHandoverTime(rotation *issuerRotationEntry, issuerCert *x509.Certificate) time.Time {
+	if rotation == nil {
+		return time.Time{}
+	}
+
+	switch rotation.State {
+	case issuerRotationRotated:
+		return rotation.RotationCompleted
+	case issuerRotationOverlap:
+		return rotation.RotationStarted.Add(rotation.OverlapPeriod)
+	default:
+		if !rotation.Enabled {
+			return time.Time{}
+		}
+		return issuerCert.NotAfter.Add(-rotation.LeadTime).Add(rotation.OverlapPeriod)
+	}
+}
+
+// suggestAcmeRenewalWindow computes the renewal window of a certificate.
+//
+// By default, this is the first half of the last third of the certificate's
+// lifetime, leaving clients which miss it time to retry. When the issuer of
+// the certificate is replaced by its successor during that window, the
+// window starts no earlier than the handover, so that renewed certificates
+// chain to the successor. An operator-requested early renewal replaces the
+// window entirely.
+func suggestAcmeRenewalWindow(cert *x509.Certificate, handover time.Time, earlyRenewal *acmeEarlyRenewalEntry) acmeRenewalWindow {
+	if earlyRenewal != nil {
+		return acmeRenewalWindow{
+			Start:          earlyRenewal.WindowStart,
+			End:            earlyRenewal.WindowEnd,
+			ExplanationURL: earlyRenewal.ExplanationURL,
+		}
+	}
+
+	lifetime := cert.NotAfter.Sub(cert.NotBefore)
+	window := acmeRenewalWindow{
+		Start: cert.NotBefore.Add(lifetime * 2 / 3),
+		End:   cert.NotBefore.Add(lifetime * 5 / 6),
+	}
+
+	if handover.After(window.Start) && handover.Before(window.End) {
+		window.Start = handover
+	}
+
+	return window
+}
+
+func (sc *storageContext) fetchAcmeEarlyRenewal(path string) (*acmeEarlyRenewalEntry, error) {
+	entry, err := sc.Storage.Get(sc.Context, path)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var earlyRenewal acmeEarlyRenewalEntry
+	if err := entry.DecodeJSON(&earlyRenewal); err != nil {
+		return nil, fmt.Errorf("failed decoding early renewal entry %s: %w", path, err)
+	}
+
+	return &earlyRenewal, nil
+}
+
+func (sc *storageContext) writeAcmeEarlyRenewal(path string, earlyRenewal *acmeEarlyRenewalEntry) error {
+	entry, err := logical.StorageEntryJSON(path, earlyRenewal)
+	if err != nil {
+		return err
+	}
+
+	return sc.Storage.Put(sc.Context, entry)
+}
+
+// fetchAcmeEarlyRenewalForCert returns the early renewal requested for the
+// certificate itself or, failing that, for its issuer, provided the
+// certificate was issued before that request.
+func (sc *storageContext) fetchAcmeEarlyRenewalForCert(cert *x509.Certificate, issuerId issuing.IssuerID) (*acmeEarlyRenewalEntry, error) {
+	earlyRenewal, err := sc.fetchAcmeEarlyRenewal(acmeEarlyRenewalCertPrefix + normalizeSerialFromBigInt(cert.SerialNumber))
+	if err != nil || earlyRenewal != nil {
+		return earlyRenewal, err
+	}
+
+	if issuerId == "" {
+		return nil, nil
+	}
+
+	earlyRenewal, err = sc.fetchAcmeEarlyRenewal(acmeEarlyRenewalIssuerPrefix + issuerId.String())
+	if err != nil || earlyRenewal == nil {
+		return nil, err
+	}
+
+	// Certificates issued after the request are what clients renewed into,
+	// so they must not be asked to renew again.
+	if !cert.NotBefore.Before(earlyRenewal.CreatedOn) {
+		return nil, nil
+	}
+
+	return earlyRenewal, nil
+}
+
+// getAcmeRenewalWindow computes the renewal window of a stored certificate.
+func getAcmeRenewalWindow(sc *storageContext, cert *x509.Certificate) (acmeRenewalWindow, error) {
+	issuerId, err := findIssuerOfCert(sc, cert)
+	if err != nil {
+		return acmeRenewalWindow{}, err
+	}
+
+	var handover time.Time
+	if issuerId != "" {
+		issuer, err := sc.fetchIssuerById(issuerId)
+		if err != nil {
+			return acmeRenewalWindow{}, err
+		}
+		issuerCert, err := issuer.GetCertificate()
+		if err != nil {
+			return acmeRenewalWindow{}, err
+		}
+		rotation, err := sc.fetchIssuerRotation(issuerId)
+		if err != nil {
+			return acmeRenewalWindow{}, err
+		}
+		handover = issuerHandoverTime(rotation, issuerCert)
+	}
+
+	earlyRenewal, err := sc.fetchAcmeEarlyRenewalForCert(cert, issuerId)
+	if err != nil {
+		return acmeRenewalWindow{}, err
+	}
+
+	return suggestAcmeRenewalWindow(cert, handover, earlyRenewal), nil
+}
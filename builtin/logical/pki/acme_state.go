@@ -47,6 +47,11 @@ type acmeState struct {
 	configDirty *atomic.Bool
 	_config     sync.RWMutex
 	config      acmeConfigEntry
+
+	_eabUsage sync.Mutex
+
+	// Held while claiming the replacement of an issued certificate.
+	_replaces sync.Mutex
 }
 
 type acmeThumbprint struct {
@@ -222,6 +227,8 @@ type acmeOrder struct {
 	CertificateExpiry       time.Time           `json:"cert-expiry"`
 	// The actual issuer UUID that issued the certificate, blank if an order exists but no certificate was issued.
 	IssuerId issuing.IssuerID `json:"issuer-id"`
+	// The ARI certificate identifier of the certificate this order replaces, if any.
+	Replaces string `json:"replaces,omitempty"`
 }
 
 func (o acmeOrder) getIdentifierDNSValues() []string {
@@ -580,6 +587,8 @@ type acmeCertEntry struct {
 	Serial  string `json:"-"`
 	Account string `json:"-"`
 	Order   string `json:"order"`
+	// The order which issued the certificate's replacement, if any.
+	ReplacedBy string `json:"replaced-by,omitempty"`
 }
 
 func (a *acmeState) TrackIssuedCert(ac *acmeContext, accountId string, serial string, orderId string) error {
@@ -609,7 +618,7 @@ func (a *acmeState) GetIssuedCert(ac *acmeContext, accountId string, serial stri
 	}
 
 	if entry == nil {
-		return nil, fmt.Errorf("no certificate with this serial was issued for this account")
+		return nil, fmt.Errorf("%w: no certificate with this serial was issued for this account", ErrStorageItemNotFound)
 	}
 
 	var cert acmeCertEntry
@@ -624,6 +633,62 @@ func (a *acmeState) GetIssuedCert(ac *acmeContext, accountId string, serial stri
 	return &cert, nil
 }
 
+// ClaimIssuedCertReplacement marks the certificate issued to the account
+// as replaced by the order, failing with ErrAlreadyReplaced if another order
+// already replaced it. Checking and marking happen under a lock, so of
+// concurrent orders replacing the same certificate only one succeeds.
+func (a *acmeState) ClaimIssuedCertReplacement(ac *acmeContext, accountId string, serial string, orderId string) error {
+	a._replaces.Lock()
+	defer a._replaces.Unlock()
+
+	cert, err := a.GetIssuedCert(ac, accountId, serial)
+	if err != nil {
+		return err
+	}
+
+	switch cert.ReplacedBy {
+	case orderId:
+		return nil
+	case "":
+	default:
+		return fmt.Errorf("%w: certificate %s was already replaced by order %s", ErrAlreadyReplaced, cert.Serial, cert.ReplacedBy)
+	}
+
+	cert.ReplacedBy = orderId
+	return a.saveIssuedCert(ac, cert)
+}
+
+// ReleaseIssuedCertReplacement undoes a claim made by the order, when it
+// failed to issue the replacement certificate.
+func (a *acmeState) ReleaseIssuedCertReplacement(ac *acmeContext, accountId string, serial string, orderId string) error {
+	a._replaces.Lock()
+	defer a._replaces.Unlock()
+
+	cert, err := a.GetIssuedCert(ac, accountId, serial)
+	if err != nil {
+		return err
+	}
+	if cert.ReplacedBy != orderId {
+		return nil
+	}
+
+	cert.ReplacedBy = ""
+	return a.saveIssuedCert(ac, cert)
+}
+
+func (a *acmeState) saveIssuedCert(ac *acmeContext, cert *acmeCertEntry) error {
+	json, err := logical.StorageEntryJSON(getAcmeSerialToAccountTrackerPath(cert.Account, cert.Serial), cert)
+	if err != nil {
+		return fmt.Errorf("error serializing acme cert entry: %w", err)
+	}
+
+	if err = ac.sc.Storage.Put(ac.sc.Context, json); err != nil {
+		return fmt.Errorf("error writing acme cert entry: %w", err)
+	}
+
+	return nil
+}
+
 func (a *acmeState) SaveEab(sc *storageContext, eab *eabType) error {
 	json, err := logical.StorageEntryJSON(path.Join(acmeEabPrefix, eab.KeyID), eab)
 	if err != nil {
@@ -683,6 +748,109 @@ func (a *acmeState) ListEabIds(sc *storageContext) ([]string, error) {
 	return ids, nil
 }
 
+// ConsumeEabOrderQuota records an order placed by the account against the
+// order quota of the EAB the account was created with, failing with
+// ErrRateLimited once the quota for the current period is exhausted.
+func (a *acmeState) ConsumeEabOrderQuota(sc *storageContext, account *acmeAccount) error {
+	if account.Eab == nil || account.Eab.MaxOrders == 0 {
+		return nil
+	}
+
+	a._eabUsage.Lock()
+	defer a._eabUsage.Unlock()
+
+	usage, err := loadEabUsage(sc, account)
+	if err != nil {
+		return err
+	}
+
+	var ok bool
+	usage.Orders, ok = consumeQuota(usage.Orders, account.Eab.MaxOrders, account.Eab.QuotaPeriod, time.Now())
+	if !ok {
+		return fmt.Errorf("%w: external account binding allows at most %d orders per quota period", ErrRateLimited, account.Eab.MaxOrders)
+	}
+
+	return saveEabUsage(sc, account, usage)
+}
+
+// CheckEabCertificateQuota fails with ErrRateLimited when the certificate
+// quota of the EAB the account was created with is exhausted for the
+// current period. The quota is only consumed once a certificate has been
+// issued, by RecordEabCertificate.
+func (a *acmeState) CheckEabCertificateQuota(sc *storageContext, account *acmeAccount) error {
+	if account.Eab == nil || account.Eab.MaxCertificates == 0 {
+		return nil
+	}
+
+	a._eabUsage.Lock()
+	defer a._eabUsage.Unlock()
+
+	usage, err := loadEabUsage(sc, account)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := consumeQuota(usage.Certificates, account.Eab.MaxCertificates, account.Eab.QuotaPeriod, time.Now()); !ok {
+		return fmt.Errorf("%w: external account binding allows at most %d certificates per quota period", ErrRateLimited, account.Eab.MaxCertificates)
+	}
+
+	return nil
+}
+
+// RecordEabCertificate records a certificate issued to the account against
+// the certificate quota of the EAB the account was created with. As the
+// certificate was already issued, it is recorded even if concurrent
+// issuance took the account over its quota.
+func (a *acmeState) RecordEabCertificate(sc *storageContext, account *acmeAccount) error {
+	if account.Eab == nil || account.Eab.MaxCertificates == 0 {
+		return nil
+	}
+
+	a._eabUsage.Lock()
+	defer a._eabUsage.Unlock()
+
+	usage, err := loadEabUsage(sc, account)
+	if err != nil {
+		return err
+	}
+
+	usage.Certificates, _ = consumeQuota(usage.Certificates, 0, account.Eab.QuotaPeriod, time.Now())
+	return saveEabUsage(sc, account, usage)
+}
+
+// loadEabUsage reads the account's EAB quota usage; the caller must hold
+// _eabUsage.
+func loadEabUsage(sc *storageContext, account *acmeAccount) (*acmeEabUsage, error) {
+	usage := &acmeEabUsage{}
+	entry, err := sc.Storage.Get(sc.Context, getEabUsagePath(account.KeyId))
+	if err != nil {
+		return nil, fmt.Errorf("error loading eab usage: %w", err)
+	}
+	if entry != nil {
+		if err := entry.DecodeJSON(usage); err != nil {
+			return nil, fmt.Errorf("error decoding eab usage: %w", err)
+		}
+	}
+
+	return usage, nil
+}
+
+func saveEabUsage(sc *storageContext, account *acmeAccount, usage *acmeEabUsage) error {
+	json, err := logical.StorageEntryJSON(getEabUsagePath(account.KeyId), usage)
+	if err != nil {
+		return fmt.Errorf("error serializing eab usage: %w", err)
+	}
+	if err := sc.Storage.Put(sc.Context, json); err != nil {
+		return fmt.Errorf("error writing eab usage: %w", err)
+	}
+
+	return nil
+}
+
+func getEabUsagePath(accountId string) string {
+	return acmeAccountPrefix + accountId + "/eab-usage"
+}
+
 func getAcmeSerialToAccountTrackerPath(accountId string, serial string) string {
 	return acmeAccountPrefix + accountId + "/certs/" + normalizeSerial(serial)
 }
@@ -4,7 +4,9 @@
 package pki
 
 import (
+	"context"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -41,3 +43,42 @@ func TestAcmeNonces(t *testing.T) {
 		require.False(t, a.RedeemNonce(nonce))
 	}
 }
+
+// TestAcmeEabQuotas verifies orders are counted when placed, and
+// certificates only once issued, against the quotas of an account's EAB.
+func TestAcmeEabQuotas(t *testing.T) {
+	t.Parallel()
+
+	b, s := CreateBackendWithStorage(t)
+	sc := b.makeStorageContext(context.Background(), s)
+	a := b.GetAcmeState()
+
+	account := &acmeAccount{
+		KeyId: "account",
+		Eab: &eabType{eabRestrictions: eabRestrictions{
+			MaxOrders:       1,
+			MaxCertificates: 1,
+			QuotaPeriod:     time.Hour,
+		}},
+	}
+
+	require.NoError(t, a.ConsumeEabOrderQuota(sc, account))
+	require.ErrorIs(t, a.ConsumeEabOrderQuota(sc, account), ErrRateLimited)
+
+	// Checking, as finalizing an order does before issuing, doesn't
+	// consume the certificate quota; recording the issued certificate does.
+	require.NoError(t, a.CheckEabCertificateQuota(sc, account))
+	require.NoError(t, a.CheckEabCertificateQuota(sc, account))
+	require.NoError(t, a.RecordEabCertificate(sc, account))
+	require.ErrorIs(t, a.CheckEabCertificateQuota(sc, account), ErrRateLimited)
+
+	usage, err := loadEabUsage(sc, account)
+	require.NoError(t, err)
+	require.Len(t, usage.Orders, 1)
+	require.Len(t, usage.Certificates, 1)
+
+	// Identifier globs match regardless of case.
+	restrictions := eabRestrictions{AllowedIdentifiers: []string{"*.Team-A.example.com"}}
+	require.NoError(t, restrictions.EnforceForOrder(nil, []*ACMEIdentifier{{OriginalValue: "WWW.team-a.example.com"}}))
+	require.ErrorIs(t, restrictions.EnforceForOrder(nil, []*ACMEIdentifier{{OriginalValue: "www.team-b.example.com"}}), ErrRejectedIdentifier)
+}
@@ -5,6 +5,8 @@ package pki
 
 import (
 	"context"
+	"crypto/x509"
+	"errors"
 	"fmt"
 	"strings"
 	"sync"
@@ -97,6 +99,12 @@ func Backend(conf *logical.BackendConfig) *backend {
 				"issuer/+/crl/delta/der",
 				"issuer/+/crl/delta/pem",
 				"issuer/+/crl/delta",
+				"issuer/+/crl/partition/+/der",
+				"issuer/+/crl/partition/+/pem",
+				"issuer/+/crl/partition/+",
+				"issuer/+/crl/partition/+/delta/der",
+				"issuer/+/crl/partition/+/delta/pem",
+				"issuer/+/crl/partition/+/delta",
 				"issuer/+/unified-crl/der",
 				"issuer/+/unified-crl/pem",
 				"issuer/+/unified-crl",
@@ -115,6 +123,8 @@ func Backend(conf *logical.BackendConfig) *backend {
 				"unified-crl",
 				"unified-ocsp",   // Unified OCSP POST
 				"unified-ocsp/*", // Unified OCSP GET
+				estPathPrefix + "*",
+				scepPath,
 
 				// ACME paths are added below
 			},
@@ -127,6 +137,10 @@ func Backend(conf *logical.BackendConfig) *backend {
 				"crls/",
 				"certs/",
 				acmePathPrefix,
+				scepChallengePrefix,
+				certIndexPrefix,
+				ocspCachePrefix,
+				expiryNotifiedPrefix,
 			},
 
 			Root: []string{
@@ -156,6 +170,8 @@ func Backend(conf *logical.BackendConfig) *backend {
 				"ocsp/*",         // OCSP GET
 				"unified-ocsp",   // Unified OCSP POST
 				"unified-ocsp/*", // Unified OCSP GET
+				estPathPrefix + "*",
+				scepPath,
 			},
 		},
 
@@ -190,6 +206,7 @@ func Backend(conf *logical.BackendConfig) *backend {
 			pathGetIssuer(&b),
 			pathGetUnauthedIssuer(&b),
 			pathGetIssuerCRL(&b),
+			pathGetIssuerCRLPartition(&b),
 			pathImportIssuer(&b),
 			pathIssuerIssue(&b),
 			pathIssuerSign(&b),
@@ -203,6 +220,7 @@ func Backend(conf *logical.BackendConfig) *backend {
 			pathConfigIssuers(&b),
 			pathReplaceRoot(&b),
 			pathRevokeIssuer(&b),
+			pathIssuerRotation(&b),
 
 			// Key APIs
 			pathListKeys(&b),
@@ -219,8 +237,10 @@ func Backend(conf *logical.BackendConfig) *backend {
 			pathFetchValidRaw(&b),
 			pathFetchValid(&b),
 			pathFetchListCerts(&b),
+			pathSearchCerts(&b),
 
 			// OCSP APIs
+			buildPathOcspBulk(&b), // must precede buildPathOcspGet, which matches everything under ocsp/
 			buildPathOcspGet(&b),
 			buildPathOcspPost(&b),
 
@@ -232,6 +252,31 @@ func Backend(conf *logical.BackendConfig) *backend {
 			pathAcmeConfig(&b),
 			pathAcmeEabList(&b),
 			pathAcmeEabDelete(&b),
+			pathAcmeEarlyRenewalIssuer(&b),
+			pathAcmeEarlyRenewalCert(&b),
+
+			// Certificate transparency
+			pathListCTLogs(&b),
+			pathCTLogs(&b),
+
+			// EST
+			pathConfigEst(&b),
+			pathListEstUsers(&b),
+			pathEstUsers(&b),
+			pathListEstClientCAs(&b),
+			pathEstClientCAs(&b),
+			pathEstCACerts(&b),
+			pathEstSimpleEnroll(&b),
+			pathEstSimpleReenroll(&b),
+			pathEstCSRAttrs(&b),
+
+			// SCEP
+			pathConfigScep(&b),
+			pathScepChallenge(&b),
+			pathScep(&b),
+
+			// Events
+			pathConfigEvents(&b),
 		},
 
 		Secrets: []*framework.Secret{
@@ -280,6 +325,7 @@ func Backend(conf *logical.BackendConfig) *backend {
 	b.tidyStatus = &tidyStatus{state: tidyStatusInactive}
 	b.storage = conf.StorageView
 	b.backendUUID = conf.BackendUUID
+	b.backgroundCtx, b.backgroundCancel = context.WithCancel(context.Background())
 
 	b.pkiStorageVersion.Store(0)
 
@@ -320,6 +366,22 @@ type backend struct {
 
 	unifiedTransferStatus *UnifiedTransferStatus
 
+	certIndexBackfillRunning atomic.Bool
+
+	// backgroundCtx is cancelled on cleanup, stopping long-running
+	// background jobs such as the certificate index backfill.
+	backgroundCtx    context.Context
+	backgroundCancel context.CancelFunc
+
+	ocspPregenerationStatus ocspPregenerationStatus
+
+	expiryScanLock sync.Mutex
+	lastExpiryScan time.Time
+
+	// scepChallengeLock serializes consuming SCEP challenges, so that each
+	// is consumed at most once.
+	scepChallengeLock sync.Mutex
+
 	certificateCounter *CertificateCounter
 
 	pkiStorageVersion atomic.Value
@@ -434,6 +496,10 @@ func (b *backend) initialize(ctx context.Context, ir *logical.InitializationRequ
 		return err
 	}
 
+	// Index any certificates stored before the certificate index existed;
+	// this can take a while, so don't block startup on it.
+	go runCertIndexBackfill(b.makeStorageContext(b.backgroundCtx, b.storage))
+
 	// Initialize also needs to populate our certificate and revoked certificate count
 	err = b.initializeStoredCertificateCounts(ctx)
 	if err != nil {
@@ -448,6 +514,8 @@ func (b *backend) initialize(ctx context.Context, ir *logical.InitializationRequ
 func (b *backend) cleanup(ctx context.Context) {
 	sc := b.makeStorageContext(ctx, b.storage)
 
+	b.backgroundCancel()
+
 	b.GetAcmeState().Shutdown(b)
 
 	b.cleanupEnt(sc)
@@ -741,9 +809,17 @@ func (b *backend) periodicFunc(ctx context.Context, request *logical.Request) er
 	backgroundSc := b.makeStorageContext(context.Background(), b.storage)
 	go runUnifiedTransfer(backgroundSc)
 
+	// Then continue indexing certificates, if not yet done.
+	go runCertIndexBackfill(b.makeStorageContext(b.backgroundCtx, b.storage))
+
+	// Then refresh pre-generated OCSP responses, if due.
+	go runOcspPregeneration(backgroundSc)
+
 	// Then run the CRL rebuild and tidy operation.
 	crlErr := doCRL()
 	tidyErr := doAutoTidy()
+	expiryErr := runExpiryScan(sc)
+	rotationErr := runIssuerRotations(sc)
 
 	// Periodically re-emit gauges so that they don't disappear/go stale
 	b.GetCertificateCounter().EmitCertStoreMetrics()
@@ -757,6 +833,14 @@ func (b *backend) periodicFunc(ctx context.Context, request *logical.Request) er
 		errors = multierror.Append(errors, fmt.Errorf("Error running auto-tidy:\n - %w\n", tidyErr))
 	}
 
+	if expiryErr != nil {
+		errors = multierror.Append(errors, fmt.Errorf("Error scanning for expiring certificates:\n - %w\n", expiryErr))
+	}
+
+	if rotationErr != nil {
+		errors = multierror.Append(errors, fmt.Errorf("Error rotating issuers:\n - %w\n", rotationErr))
+	}
+
 	if errors != nil {
 		return errors
 	}
@@ -771,6 +855,32 @@ func (b *backend) periodicFunc(ctx context.Context, request *logical.Request) er
 	return b.periodicFuncEnt(backgroundSc, request)
 }
 
+// pkiEvent sends a pki/<eventType> event; failing to send it is logged
+// rather than failing the operation which triggered it.
+func (b *backend) pkiEvent(ctx context.Context, eventType string, metadataPairs ...string) {
+	err := logical.SendEvent(ctx, b, "pki/"+eventType, metadataPairs...)
+	if err != nil && !errors.Is(err, framework.ErrNoEvents) {
+		b.Logger().Error("Error sending event", "event_type", "pki/"+eventType, "error", err)
+	}
+}
+
+// issueEvent sends the pki/issue event for a newly issued certificate.
+func (b *backend) issueEvent(ctx context.Context, cert *x509.Certificate, issuerId issuing.IssuerID, role string, stored bool) {
+	serial := serialFromCert(cert)
+	metadata := []string{
+		logical.EventMetadataModified, "true",
+		"serial_number", serial,
+		"issuer_id", issuerId.String(),
+		"role", role,
+		"common_name", cert.Subject.CommonName,
+		"not_after", cert.NotAfter.Format(time.RFC3339),
+	}
+	if stored {
+		metadata = append(metadata, logical.EventMetadataDataPath, "cert/"+serial)
+	}
+	b.pkiEvent(ctx, "issue", metadata...)
+}
+
 func (b *backend) initializeStoredCertificateCounts(ctx context.Context) error {
 	// For performance reasons, we can't lock on issuance/storage of certs until a list operation completes,
 	// but we want to limit possible miscounts / double-counts to over-counting, so we take the tidy lock which
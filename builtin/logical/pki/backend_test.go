@@ -3707,6 +3707,9 @@ func TestReadWriteDeleteRoles(t *testing.T) {
 		"issuer_ref":                         "default",
 		"cn_validations":                     []interface{}{"email", "hostname"},
 		"allowed_user_ids":                   []interface{}{},
+		"ct_logs":                            []interface{}{},
+		"ct_min_scts":                        json.Number("0"),
+		"issuance_policy":                    nil,
 	}
 
 	if diff := deep.Equal(expectedData, resp.Data); len(diff) > 0 {
@@ -6694,18 +6697,63 @@ func pathShouldBeUnauthedWriteOnly(t *testing.T, client *api.Client, path string
 	}
 }
 
+func pathShouldBeUnauthedReadWrite(t *testing.T, client *api.Client, path string, token string) {
+	client.SetToken("")
+	resp, err := client.Logical().ReadWithContext(ctx, path)
+	if err != nil && isPermDenied(err) {
+		t.Fatalf("unexpected failure to read %v while unauthed: %v / %v", path, err, resp)
+	}
+	resp, err = client.Logical().WriteWithContext(ctx, path, map[string]interface{}{})
+	if err != nil && isPermDenied(err) {
+		t.Fatalf("unexpected failure to write %v while unauthed: %v / %v", path, err, resp)
+	}
+
+	// These should all be denied.
+	resp, err = client.Logical().DeleteWithContext(ctx, path)
+	if err == nil || !isDeniedOp(err) {
+		t.Fatalf("unexpected failure during delete on read-write path %v while unauthed: %v / %v", path, err, resp)
+	}
+	resp, err = client.Logical().JSONMergePatch(ctx, path, map[string]interface{}{})
+	if err == nil || !isDeniedOp(err) {
+		t.Fatalf("unexpected failure during patch on read-write path %v while unauthed: %v / %v", path, err, resp)
+	}
+
+	// Retrying with token should allow read/write, but nothing else.
+	client.SetToken(token)
+	resp, err = client.Logical().ReadWithContext(ctx, path)
+	if err != nil && isPermDenied(err) {
+		t.Fatalf("unexpected failure to read %v while authed: %v / %v", path, err, resp)
+	}
+	resp, err = client.Logical().WriteWithContext(ctx, path, map[string]interface{}{})
+	if err != nil && isPermDenied(err) {
+		t.Fatalf("unexpected failure to write %v while authed: %v / %v", path, err, resp)
+	}
+
+	// These should all be denied.
+	resp, err = client.Logical().DeleteWithContext(ctx, path)
+	if err == nil || !isDeniedOp(err) {
+		t.Fatalf("unexpected failure during delete on read-write path %v while authed: %v / %v", path, err, resp)
+	}
+	resp, err = client.Logical().JSONMergePatch(ctx, path, map[string]interface{}{})
+	if err == nil || !isDeniedOp(err) {
+		t.Fatalf("unexpected failure during patch on read-write path %v while authed: %v / %v", path, err, resp)
+	}
+}
+
 type pathAuthChecker int
 
 const (
 	shouldBeAuthed pathAuthChecker = iota
 	shouldBeUnauthedReadList
 	shouldBeUnauthedWriteOnly
+	shouldBeUnauthedReadWrite
 )
 
 var pathAuthChckerMap = map[pathAuthChecker]pathAuthCheckerFunc{
 	shouldBeAuthed:            pathShouldBeAuthed,
 	shouldBeUnauthedReadList:  pathShouldBeUnauthedReadList,
 	shouldBeUnauthedWriteOnly: pathShouldBeUnauthedWriteOnly,
+	shouldBeUnauthedReadWrite: pathShouldBeUnauthedReadWrite,
 }
 
 func TestProperAuthing(t *testing.T) {
@@ -6783,12 +6831,16 @@ func TestProperAuthing(t *testing.T) {
 		"certs/":                                 shouldBeAuthed,
 		"certs/revoked/":                         shouldBeAuthed,
 		"certs/revocation-queue/":                shouldBeAuthed,
+		"certs/search":                           shouldBeAuthed,
 		"certs/unified-revoked/":                 shouldBeAuthed,
 		"config/acme":                            shouldBeAuthed,
 		"config/auto-tidy":                       shouldBeAuthed,
 		"config/ca":                              shouldBeAuthed,
 		"config/cluster":                         shouldBeAuthed,
 		"config/crl":                             shouldBeAuthed,
+		"config/est":                             shouldBeAuthed,
+		"config/scep":                            shouldBeAuthed,
+		"config/events":                          shouldBeAuthed,
 		"config/issuers":                         shouldBeAuthed,
 		"config/keys":                            shouldBeAuthed,
 		"config/urls":                            shouldBeAuthed,
@@ -6798,6 +6850,12 @@ func TestProperAuthing(t *testing.T) {
 		"crl/delta/pem":                          shouldBeUnauthedReadList,
 		"crl/rotate":                             shouldBeAuthed,
 		"crl/rotate-delta":                       shouldBeAuthed,
+		"ct-logs/":                               shouldBeAuthed,
+		"ct-logs/test":                           shouldBeAuthed,
+		"est/client-cas/":                        shouldBeAuthed,
+		"est/client-cas/test":                    shouldBeAuthed,
+		"est/users/":                             shouldBeAuthed,
+		"est/users/test":                         shouldBeAuthed,
 		"intermediate/cross-sign":                shouldBeAuthed,
 		"intermediate/generate/exported":         shouldBeAuthed,
 		"intermediate/generate/internal":         shouldBeAuthed,
@@ -6824,6 +6882,7 @@ func TestProperAuthing(t *testing.T) {
 		"issuer/default/issue/test":              shouldBeAuthed,
 		"issuer/default/resign-crls":             shouldBeAuthed,
 		"issuer/default/revoke":                  shouldBeAuthed,
+		"issuer/default/rotation":                shouldBeAuthed,
 		"issuer/default/sign-intermediate":       shouldBeAuthed,
 		"issuer/default/sign-revocation-list":    shouldBeAuthed,
 		"issuer/default/sign-self-issued":        shouldBeAuthed,
@@ -6849,9 +6908,12 @@ func TestProperAuthing(t *testing.T) {
 		"keys/import":                            shouldBeAuthed,
 		"ocsp":                                   shouldBeUnauthedWriteOnly,
 		"ocsp/dGVzdAo=":                          shouldBeUnauthedReadList,
+		"ocsp/bulk":                              shouldBeUnauthedReadList,
 		"revoke":                                 shouldBeAuthed,
 		"revoke-with-key":                        shouldBeAuthed,
 		"roles/test":                             shouldBeAuthed,
+		"scep":                                   shouldBeUnauthedReadWrite,
+		"scep/challenge":                         shouldBeAuthed,
 		"roles/":                                 shouldBeAuthed,
 		"root":                                   shouldBeAuthed,
 		"root/generate/exported":                 shouldBeAuthed,
@@ -6881,6 +6943,12 @@ func TestProperAuthing(t *testing.T) {
 		"eab/" + eabKid:                          shouldBeAuthed,
 	}
 
+	for _, suffix := range []string{"", "/pem", "/der", "/delta", "/delta/der", "/delta/pem"} {
+		paths["issuer/default/crl/partition/0"+suffix] = shouldBeUnauthedReadList
+	}
+	paths["acme/early-renewal/issuer/default"] = shouldBeAuthed
+	paths["acme/early-renewal/cert/"+serial] = shouldBeAuthed
+
 	entPaths := getEntProperAuthingPaths(serial)
 	maps.Copy(paths, entPaths)
 
@@ -6900,11 +6968,20 @@ func TestProperAuthing(t *testing.T) {
 		paths[acmePrefix+"order/13b80844-e60d-42d2-b7e9-152a8e834b90"] = shouldBeUnauthedWriteOnly
 		paths[acmePrefix+"order/13b80844-e60d-42d2-b7e9-152a8e834b90/finalize"] = shouldBeUnauthedWriteOnly
 		paths[acmePrefix+"order/13b80844-e60d-42d2-b7e9-152a8e834b90/cert"] = shouldBeUnauthedWriteOnly
+		paths[acmePrefix+"renewal-info/aGVsbG8.AQ"] = shouldBeUnauthedReadList
 
 		// Make sure this new-eab path is auth'd
 		paths[acmePrefix+"new-eab"] = shouldBeAuthed
 	}
 
+	// Add EST based paths to the test suite
+	for _, estPrefix := range []string{".well-known/est/", ".well-known/est/test/"} {
+		paths[estPrefix+"cacerts"] = shouldBeUnauthedReadList
+		paths[estPrefix+"csrattrs"] = shouldBeUnauthedReadList
+		paths[estPrefix+"simpleenroll"] = shouldBeUnauthedWriteOnly
+		paths[estPrefix+"simplereenroll"] = shouldBeUnauthedWriteOnly
+	}
+
 	for path, checkerType := range paths {
 		checker := pathAuthChckerMap[checkerType]
 		checker(t, client, "pki/"+path, token)
@@ -6927,7 +7004,7 @@ func TestProperAuthing(t *testing.T) {
 		validatedPath = true
 		// Substitute values in from our testing map.
 		raw_path := openapi_path[5:]
-		if strings.Contains(raw_path, "roles/") && strings.Contains(raw_path, "{name}") {
+		if (strings.Contains(raw_path, "roles/") || strings.Contains(raw_path, "ct-logs/") || strings.Contains(raw_path, "est/client-cas/")) && strings.Contains(raw_path, "{name}") {
 			raw_path = strings.ReplaceAll(raw_path, "{name}", "test")
 		}
 		if strings.Contains(raw_path, "{role}") {
@@ -6939,6 +7016,9 @@ func TestProperAuthing(t *testing.T) {
 		if strings.Contains(raw_path, "{issuer_ref}") {
 			raw_path = strings.ReplaceAll(raw_path, "{issuer_ref}", "default")
 		}
+		if strings.Contains(raw_path, "crl/partition/") && strings.Contains(raw_path, "{partition}") {
+			raw_path = strings.ReplaceAll(raw_path, "{partition}", "0")
+		}
 		if strings.Contains(raw_path, "{key_ref}") {
 			raw_path = strings.ReplaceAll(raw_path, "{key_ref}", "default")
 		}
@@ -6960,9 +7040,18 @@ func TestProperAuthing(t *testing.T) {
 		if strings.Contains(raw_path, "acme/") && strings.Contains(raw_path, "{order_id}") {
 			raw_path = strings.ReplaceAll(raw_path, "{order_id}", "13b80844-e60d-42d2-b7e9-152a8e834b90")
 		}
+		if strings.Contains(raw_path, "acme/") && strings.Contains(raw_path, "{cert_id}") {
+			raw_path = strings.ReplaceAll(raw_path, "{cert_id}", "aGVsbG8.AQ")
+		}
 		if strings.Contains(raw_path, "eab") && strings.Contains(raw_path, "{key_id}") {
 			raw_path = strings.ReplaceAll(raw_path, "{key_id}", eabKid)
 		}
+		if strings.Contains(raw_path, "est/") && strings.Contains(raw_path, "{label}") {
+			raw_path = strings.ReplaceAll(raw_path, "{label}", "test")
+		}
+		if strings.Contains(raw_path, "est/users/") && strings.Contains(raw_path, "{username}") {
+			raw_path = strings.ReplaceAll(raw_path, "{username}", "test")
+		}
 		if strings.Contains(raw_path, "external-policy/") && strings.Contains(raw_path, "{policy}") {
 			raw_path = strings.ReplaceAll(raw_path, "{policy}", "a-policy")
 		}
@@ -6999,6 +7088,10 @@ func TestProperAuthing(t *testing.T) {
 			if hasGet || hasList {
 				t.Fatalf("Unauthed write-only endpoints should not have GET/LIST capabilities: %v->%v", openapi_path, raw_path)
 			}
+		} else if handler == shouldBeUnauthedReadWrite {
+			if hasDelete {
+				t.Fatalf("Unauthed read-write endpoints should not have DELETE capabilities: %v->%v", openapi_path, raw_path)
+			}
 		}
 	}
 
@@ -479,6 +479,57 @@ func signCert(b *backend, data *inputBundle, caSign *certutil.CAInfoBundle, isCA
 	return issuing.SignCert(b.System(), data.role, entityInfo, caSign, signCertInput)
 }
 
+// signEnrollmentCSR signs a CSR received over an enrollment protocol such as
+// EST or SCEP with the role's issuer and stores the result unless the role
+// sets no_store. As with ACME, only the subject and SANs are taken from the
+// CSR, and only as far as the role's use_csr_common_name and use_csr_sans
+// allow. Requests the role rejects return an errutil.UserError.
+func (b *backend) signEnrollmentCSR(sc *storageContext, req *logical.Request, role *issuing.RoleEntry, csr *x509.CertificateRequest) (*certutil.ParsedCertBundle, error) {
+	issuerRef := roleIssuerRef(role)
+	signingBundle, issuerId, err := sc.fetchCAInfoWithIssuer(issuerRef, issuing.IssuanceUsage)
+	if err != nil {
+		return nil, errutil.InternalError{Err: fmt.Sprintf("failed loading CA %s: %v", issuerRef, err)}
+	}
+
+	data := &framework.FieldData{
+		Raw: map[string]interface{}{
+			"csr": string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csr.Raw})),
+		},
+		Schema: getCsrSignVerbatimSchemaFields(),
+	}
+	input := &inputBundle{
+		req:     req,
+		apiData: data,
+		role:    role,
+	}
+
+	parsedBundle, _, err := signCert(b, input, signingBundle, false /* is_ca=false */, false /* use_csr_values */)
+	if err == nil {
+		_, err = issuing.AddSignedCertificateTimestamps(sc.Context, sc.Storage, role, signingBundle, parsedBundle)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !role.NoStore {
+		if err := sc.storeCertificate(parsedBundle, issuerId, role.Name); err != nil {
+			return nil, err
+		}
+	}
+	b.issueEvent(sc.Context, parsedBundle.Certificate, issuerId, role.Name, !role.NoStore)
+
+	return parsedBundle, nil
+}
+
+// roleIssuerRef returns the issuer a role issues from on the legacy
+// sign/:role and issue/:role paths.
+func roleIssuerRef(role *issuing.RoleEntry) string {
+	if role.Issuer == "" {
+		return defaultRef
+	}
+	return role.Issuer
+}
+
 func getOtherSANsFromX509Extensions(exts []pkix.Extension) ([]certutil.OtherNameUtf8, error) {
 	return certutil.GetOtherSANsFromX509Extensions(exts)
 }
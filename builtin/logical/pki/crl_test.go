@@ -5,6 +5,8 @@ package pki
 
 import (
 	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/asn1"
 	"encoding/json"
 	"fmt"
@@ -17,6 +19,7 @@ import (
 	"github.com/hashicorp/vault/builtin/logical/pki/issuing"
 	"github.com/hashicorp/vault/helper/constants"
 	vaulthttp "github.com/hashicorp/vault/http"
+	"github.com/hashicorp/vault/sdk/helper/certutil"
 	"github.com/hashicorp/vault/sdk/helper/testhelpers/schema"
 	"github.com/hashicorp/vault/sdk/logical"
 	"github.com/hashicorp/vault/vault"
@@ -1525,3 +1528,148 @@ func TestCRLIssuerRemoval(t *testing.T) {
 	}
 	require.Equal(t, len(afterUnifiedCRLList), len(unifiedCRLList))
 }
+
+func TestCRLPartitions(t *testing.T) {
+	t.Parallel()
+
+	b, s := CreateBackendWithStorage(t)
+
+	resp, err := CBWrite(b, s, "root/generate/internal", map[string]interface{}{
+		"common_name": "Root R1",
+		"key_type":    "ec",
+		"ttl":         "720h",
+	})
+	requireSuccessNonNilResponse(t, resp, err)
+	issuerId := string(resp.Data["issuer_id"].(issuing.IssuerID))
+
+	resp, err = CBPatch(b, s, "issuer/default", map[string]interface{}{
+		"crl_partitions":            4,
+		"enable_aia_url_templating": true,
+		"crl_distribution_points": []string{
+			"http://localhost/crl",
+			"http://localhost/v1/pki/issuer/{{issuer_id}}/crl/partition/{{crl_partition}}/der",
+		},
+	})
+	requireSuccessNonNilResponse(t, resp, err)
+	require.Equal(t, 4, resp.Data["crl_partitions"])
+
+	_, err = CBWrite(b, s, "roles/test", map[string]interface{}{
+		"allow_any_name": true,
+		"key_type":       "ec",
+	})
+	require.NoError(t, err)
+
+	// Issue enough leaves that every partition is likely to be used, and
+	// check each points at the partition matching its serial number.
+	var serials []string
+	var certs []*x509.Certificate
+	bySerial := map[string]int{}
+	for i := 0; i < 16; i++ {
+		resp, err = CBWrite(b, s, "issue/test", map[string]interface{}{
+			"common_name": fmt.Sprintf("leaf-%d.example.com", i),
+		})
+		requireSuccessNonNilResponse(t, resp, err)
+		cert := parseCert(t, resp.Data["certificate"].(string))
+
+		partition := issuing.CRLPartitionForSerial(cert.SerialNumber, 4)
+		require.Equal(t, []string{
+			"http://localhost/crl",
+			fmt.Sprintf("http://localhost/v1/pki/issuer/%v/crl/partition/%d/der", issuerId, partition),
+		}, cert.CRLDistributionPoints)
+
+		serial := resp.Data["serial_number"].(string)
+		serials = append(serials, serial)
+		certs = append(certs, cert)
+		bySerial[strings.ReplaceAll(serial, ":", "")] = partition
+	}
+
+	for _, serial := range serials {
+		_, err = CBWrite(b, s, "revoke", map[string]interface{}{
+			"serial_number": serial,
+		})
+		require.NoError(t, err)
+	}
+
+	// Each partition should carry its own Issuing Distribution Point and
+	// list only the revoked certificates hashed to it.
+	total := 0
+	for partition := 0; partition < 4; partition++ {
+		resp, err = CBRead(b, s, fmt.Sprintf("issuer/default/crl/partition/%d/der", partition))
+		requireSuccessNonNilResponse(t, resp, err)
+		crl, err := x509.ParseRevocationList(resp.Data["http_raw_body"].([]byte))
+		require.NoError(t, err)
+
+		var idp pkix.Extension
+		for _, ext := range crl.Extensions {
+			if ext.Id.Equal(certutil.IssuingDistributionPointOID) {
+				idp = ext
+			}
+		}
+		expected, err := certutil.CreateIssuingDistributionPointExt([]string{
+			fmt.Sprintf("http://localhost/v1/pki/issuer/%v/crl/partition/%d/der", issuerId, partition),
+		})
+		require.NoError(t, err)
+		require.Equal(t, expected, idp)
+
+		for _, entry := range crl.RevokedCertificateEntries {
+			serial := certutil.GetHexFormatted(entry.SerialNumber.Bytes(), "")
+			require.Equal(t, partition, bySerial[serial], "serial %v listed on the wrong partition", serial)
+			total++
+		}
+	}
+	require.Equal(t, len(serials), total)
+
+	// The full CRL remains available and lists every revocation.
+	resp, err = CBRead(b, s, "issuer/default/crl/der")
+	requireSuccessNonNilResponse(t, resp, err)
+	crl, err := x509.ParseRevocationList(resp.Data["http_raw_body"].([]byte))
+	require.NoError(t, err)
+	require.Len(t, crl.RevokedCertificateEntries, len(serials))
+
+	// The partition count can't be changed once set, as that would move
+	// revoked certificates off the partition their CRL distribution point
+	// refers to; updating other fields leaves it alone.
+	_, err = CBPatch(b, s, "issuer/default", map[string]interface{}{
+		"crl_partitions": 2,
+	})
+	require.ErrorContains(t, err, "cannot be changed once set")
+	_, err = CBPatch(b, s, "issuer/default", map[string]interface{}{
+		"crl_partitions": 0,
+	})
+	require.ErrorContains(t, err, "cannot be changed once set")
+	resp, err = CBWrite(b, s, "issuer/default", map[string]interface{}{
+		"issuer_name":               "root-r1",
+		"enable_aia_url_templating": true,
+		"crl_distribution_points": []string{
+			"http://localhost/crl",
+			"http://localhost/v1/pki/issuer/{{issuer_id}}/crl/partition/{{crl_partition}}/der",
+		},
+	})
+	requireSuccessNonNilResponse(t, resp, err)
+	require.Equal(t, 4, resp.Data["crl_partitions"])
+	_, err = CBWrite(b, s, "issuer/default", map[string]interface{}{
+		"crl_partitions": 8,
+	})
+	require.ErrorContains(t, err, "cannot be changed once set")
+
+	// After a rebuild, every revoked certificate is still listed on the
+	// partition its CRL distribution point names.
+	_, err = CBRead(b, s, "crl/rotate")
+	require.NoError(t, err)
+	prefix := fmt.Sprintf("http://localhost/v1/pki/issuer/%v/", issuerId)
+	for _, cert := range certs {
+		path := strings.TrimPrefix(cert.CRLDistributionPoints[1], prefix)
+		resp, err = CBRead(b, s, "issuer/default/"+path)
+		requireSuccessNonNilResponse(t, resp, err)
+		crl, err := x509.ParseRevocationList(resp.Data["http_raw_body"].([]byte))
+		require.NoError(t, err)
+
+		found := false
+		for _, entry := range crl.RevokedCertificateEntries {
+			if entry.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+				found = true
+			}
+		}
+		require.True(t, found, "serial %v missing from %v", cert.SerialNumber, path)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"crypto/x509/pkix"
 	"fmt"
 	"math/big"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -1063,6 +1064,18 @@ func revokeCert(sc *storageContext, config *crlConfig, cert *x509.Certificate) (
 	}
 	certCounter.IncrementTotalRevokedCertificatesCount(certsCounted, revEntry.Key)
 
+	// The role is only known for certificates in the certificate index.
+	var role string
+	if record, err := sc.fetchCertIndex(hyphenSerial); err == nil && record != nil {
+		role = record.Role
+	}
+	sc.Backend.pkiEvent(sc.Context, "revoke",
+		logical.EventMetadataModified, "true",
+		logical.EventMetadataDataPath, "cert/"+colonSerial,
+		"serial_number", colonSerial,
+		"issuer_id", revInfo.CertificateIssuer.String(),
+		"role", role)
+
 	// From here on out, the certificate has been revoked locally. Any other
 	// persistence issues might still err, but any other failure messages
 	// should be added as warnings to the revocation.
@@ -1356,6 +1369,13 @@ func buildAnyCRLs(sc *storageContext, forceNew bool, isDelta bool) ([]string, er
 		return nil, err
 	}
 
+	if !isDelta || globalCRLConfig.EnableDelta {
+		sc.Backend.pkiEvent(sc.Context, "crl-rebuild",
+			logical.EventMetadataModified, "true",
+			logical.EventMetadataDataPath, "crl",
+			"delta", strconv.FormatBool(isDelta))
+	}
+
 	var warnings []string
 	for _, warning := range localWarnings {
 		warnings = append(warnings, fmt.Sprintf("warning from local CRL rebuild: %v", warning))
@@ -1382,6 +1402,12 @@ func buildAnyCRLs(sc *storageContext, forceNew bool, isDelta bool) ([]string, er
 		for _, warning := range deltaWarnings {
 			warnings = append(warnings, fmt.Sprintf("warning from delta CRL rebuild: %v", warning))
 		}
+
+		// Signing a response for every certificate can take a while, so
+		// pre-generated OCSP responses are refreshed in the background.
+		if globalCRLConfig.OcspPregenerate && !globalCRLConfig.OcspDisable && !wasLegacy {
+			sc.Backend.ocspPregenerationStatus.forceRun()
+		}
 	}
 
 	return warnings, nil
@@ -1861,6 +1887,9 @@ func buildAnyCRLsWithCerts(
 			if err := sc.Storage.Delete(sc.Context, "crls/"+crlId.String()); err != nil {
 				return nil, fmt.Errorf("error building CRLs: unable to clean up deleted issuers' CRL: %w", err)
 			}
+			if err := issuing.DeleteCRLPartitions(sc.Context, sc.Storage, "crls/", crlId, 0); err != nil {
+				return nil, fmt.Errorf("error building CRLs: unable to clean up deleted issuers' CRL partitions: %w", err)
+			}
 		}
 	}
 
@@ -2241,9 +2270,74 @@ WRITE:
 		return nil, errutil.InternalError{Err: fmt.Sprintf("error storing CRL: %s", err)}
 	}
 
+	// Partitions are only built for the local CRLs of non-legacy issuers;
+	// unified CRLs remain whole.
+	if thisIssuerId != legacyBundleShimID && !isUnified {
+		if err := buildCRLPartitions(sc, signingBundle, revocationListTemplate, identifier, isDelta); err != nil {
+			return nil, err
+		}
+	}
+
 	return &nextUpdate, nil
 }
 
+// buildCRLPartitions splits a CRL into the partitions configured on its
+// issuer, by hash of each revoked certificate's serial number. Every
+// partition carries an Issuing Distribution Point extension naming its URLs,
+// so partitions are only built when the issuer's CRL distribution points
+// refer to them.
+func buildCRLPartitions(sc *storageContext, signingBundle *certutil.CAInfoBundle, template *x509.RevocationList, identifier issuing.CrlID, isDelta bool) error {
+	partitions := signingBundle.CRLPartitions
+	if signingBundle.URLs == nil || !issuing.HasCRLPartitionURLs(signingBundle.URLs.CRLDistributionPoints) {
+		partitions = 0
+	}
+
+	// Remove any partitions left over from a larger partition count.
+	if err := issuing.DeleteCRLPartitions(sc.Context, sc.Storage, "crls/", identifier, partitions); err != nil {
+		return errutil.InternalError{Err: fmt.Sprintf("error removing stale CRL partitions: %s", err)}
+	}
+	if partitions == 0 {
+		return nil
+	}
+
+	revokedByPartition := make([][]pkix.RevokedCertificate, partitions)
+	for _, revoked := range template.RevokedCertificates {
+		partition := issuing.CRLPartitionForSerial(revoked.SerialNumber, partitions)
+		revokedByPartition[partition] = append(revokedByPartition[partition], revoked)
+	}
+
+	for partition, revoked := range revokedByPartition {
+		ext, err := certutil.CreateIssuingDistributionPointExt(issuing.PartitionDistributionPoints(signingBundle.URLs.CRLDistributionPoints, partition))
+		if err != nil {
+			return fmt.Errorf("could not create issuing distribution point extension: %w", err)
+		}
+
+		partitionTemplate := *template
+		partitionTemplate.RevokedCertificates = revoked
+		partitionTemplate.ExtraExtensions = append(append([]pkix.Extension{}, template.ExtraExtensions...), ext)
+
+		crlBytes, err := x509.CreateRevocationList(rand.Reader, &partitionTemplate, signingBundle.Certificate, signingBundle.PrivateKey)
+		if err != nil {
+			return errutil.InternalError{Err: fmt.Sprintf("error creating CRL partition %d: %s", partition, err)}
+		}
+
+		writePath := issuing.CRLPartitionPath("crls/", identifier, partition)
+		if isDelta {
+			writePath += deltaCRLPathSuffix
+		}
+
+		err = sc.Storage.Put(sc.Context, &logical.StorageEntry{
+			Key:   writePath,
+			Value: crlBytes,
+		})
+		if err != nil {
+			return errutil.InternalError{Err: fmt.Sprintf("error storing CRL partition %d: %s", partition, err)}
+		}
+	}
+
+	return nil
+}
+
 // shouldLocalPathsUseUnified assuming a legacy path for a CRL/OCSP request, does our
 // configuration say we should be returning the unified response or not
 func shouldLocalPathsUseUnified(cfg *crlConfig) bool {
@@ -5,7 +5,11 @@ package issuing
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/binary"
 	"fmt"
+	"math/big"
+	"strconv"
 	"strings"
 
 	"github.com/asaskevich/govalidator"
@@ -15,6 +19,12 @@ import (
 
 const ClusterConfigPath = "config/cluster"
 
+// CRLPartitionTemplate is replaced in templated CRL distribution points with
+// the index of the CRL partition a certificate is listed on. It is only
+// resolved at issuance time, as it depends on the certificate's serial
+// number.
+const CRLPartitionTemplate = "{{crl_partition}}"
+
 type AiaConfigEntry struct {
 	IssuingCertificates   []string `json:"issuing_certificates"`
 	CRLDistributionPoints []string `json:"crl_distribution_points"`
@@ -46,7 +56,18 @@ func GetAIAURLs(ctx context.Context, s logical.Storage, i *IssuerEntry) (*certut
 		return &certutil.URLEntries{}, nil
 	}
 
-	return ToURLEntries(ctx, s, i.ID, entries)
+	urls, err := ToURLEntries(ctx, s, i.ID, entries)
+	if err != nil {
+		return nil, err
+	}
+
+	// Partitioned distribution points only make sense when this issuer's
+	// CRL is partitioned; elide them otherwise.
+	if i.CRLPartitions == 0 {
+		urls.CRLDistributionPoints = withoutCRLPartitionURLs(urls.CRLDistributionPoints)
+	}
+
+	return urls, nil
 }
 
 func GetGlobalAIAURLs(ctx context.Context, storage logical.Storage) (*AiaConfigEntry, error) {
@@ -107,6 +128,9 @@ func ToURLEntries(ctx context.Context, s logical.Storage, issuer IssuerID, c *Ai
 					// Elide issuer AIA info as we lack an issuer_id.
 					return nil, fmt.Errorf("unable to template AIA URLs as we lack an issuer_id for this operation")
 				}
+				if strings.Contains(uri, CRLPartitionTemplate) && name != "crl_distribution_points" {
+					return nil, fmt.Errorf("unable to template AIA URLs as %v is only supported in crl_distribution_points", CRLPartitionTemplate)
+				}
 
 				uri = strings.ReplaceAll(uri, "{{cluster_path}}", cfg.Path)
 				uri = strings.ReplaceAll(uri, "{{cluster_aia_path}}", cfg.AIAPath)
@@ -114,7 +138,9 @@ func ToURLEntries(ctx context.Context, s logical.Storage, issuer IssuerID, c *Ai
 				templated[index] = uri
 			}
 
-			if uri := ValidateURLs(templated); uri != "" {
+			// The partition is only known at issuance time; validate these
+			// URLs as if they referred to the first partition.
+			if uri := ValidateURLs(resolveCRLPartition(templated, 0)); uri != "" {
 				return nil, fmt.Errorf("error validating templated %v; invalid URI: %v", name, uri)
 			}
 
@@ -145,10 +171,81 @@ func GetClusterConfig(ctx context.Context, s logical.Storage) (*ClusterConfigEnt
 
 func ValidateURLs(urls []string) string {
 	for _, curr := range urls {
-		if !govalidator.IsURL(curr) || strings.Contains(curr, "{{issuer_id}}") || strings.Contains(curr, "{{cluster_path}}") || strings.Contains(curr, "{{cluster_aia_path}}") {
+		if !govalidator.IsURL(curr) || strings.Contains(curr, "{{issuer_id}}") || strings.Contains(curr, "{{cluster_path}}") || strings.Contains(curr, "{{cluster_aia_path}}") || strings.Contains(curr, CRLPartitionTemplate) {
 			return curr
 		}
 	}
 
 	return ""
 }
+
+// CRLPartitionForSerial returns the partition of a CRL split into the given
+// number of partitions on which the certificate with this serial number is
+// listed.
+func CRLPartitionForSerial(serial *big.Int, partitions int) int {
+	if partitions <= 1 {
+		return 0
+	}
+
+	sum := sha256.Sum256(serial.Bytes())
+	return int(binary.BigEndian.Uint64(sum[:8]) % uint64(partitions))
+}
+
+// CRLPartitionURLs returns a copy of urls with the CRL distribution points
+// templated for the partition the certificate with this serial number will
+// be listed on.
+func CRLPartitionURLs(urls *certutil.URLEntries, partitions int, serial *big.Int) *certutil.URLEntries {
+	if urls == nil {
+		return nil
+	}
+
+	result := *urls
+	if partitions == 0 {
+		result.CRLDistributionPoints = withoutCRLPartitionURLs(urls.CRLDistributionPoints)
+	} else {
+		result.CRLDistributionPoints = resolveCRLPartition(urls.CRLDistributionPoints, CRLPartitionForSerial(serial, partitions))
+	}
+	return &result
+}
+
+// HasCRLPartitionURLs returns whether any of the given URLs refer to a
+// specific CRL partition.
+func HasCRLPartitionURLs(urls []string) bool {
+	for _, uri := range urls {
+		if strings.Contains(uri, CRLPartitionTemplate) {
+			return true
+		}
+	}
+	return false
+}
+
+// PartitionDistributionPoints returns the URLs, out of the given CRL
+// distribution points, of the specified CRL partition. These are the URLs
+// to place into the partition's Issuing Distribution Point extension.
+func PartitionDistributionPoints(urls []string, partition int) []string {
+	var result []string
+	for _, uri := range urls {
+		if strings.Contains(uri, CRLPartitionTemplate) {
+			result = append(result, strings.ReplaceAll(uri, CRLPartitionTemplate, strconv.Itoa(partition)))
+		}
+	}
+	return result
+}
+
+func resolveCRLPartition(urls []string, partition int) []string {
+	result := make([]string, len(urls))
+	for index, uri := range urls {
+		result[index] = strings.ReplaceAll(uri, CRLPartitionTemplate, strconv.Itoa(partition))
+	}
+	return result
+}
+
+func withoutCRLPartitionURLs(urls []string) []string {
+	result := make([]string, 0, len(urls))
+	for _, uri := range urls {
+		if !strings.Contains(uri, CRLPartitionTemplate) {
+			result = append(result, uri)
+		}
+	}
+	return result
+}
@@ -6,6 +6,7 @@ package issuing
 import (
 	"context"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
@@ -163,6 +164,9 @@ func _cleanupInternalCRLMapping(ctx context.Context, s logical.Storage, mapping
 		if err := s.Delete(ctx, deltaCRLPath); err != nil {
 			return fmt.Errorf("failed to delete unreferenced delta CRL %v: %w", id, err)
 		}
+		if err := DeleteCRLPartitions(ctx, s, baseCRLPath, id, 0); err != nil {
+			return fmt.Errorf("failed to delete unreferenced CRL %v: %w", id, err)
+		}
 	}
 
 	// Lastly, some CRLs could've been partially removed from the map but
@@ -188,3 +192,32 @@ func _cleanupInternalCRLMapping(ctx context.Context, s logical.Storage, mapping
 
 	return nil
 }
+
+// CRLPartitionPath returns the storage path of a single partition of the
+// CRL with the given identifier, stored under baseCRLPath.
+func CRLPartitionPath(baseCRLPath string, id CrlID, partition int) string {
+	return baseCRLPath + "partitions/" + id.String() + "/" + strconv.Itoa(partition)
+}
+
+// DeleteCRLPartitions removes the stored partitions of the CRL with the given
+// identifier (and their delta CRLs), starting with partition index from.
+func DeleteCRLPartitions(ctx context.Context, s logical.Storage, baseCRLPath string, id CrlID, from int) error {
+	prefix := baseCRLPath + "partitions/" + id.String() + "/"
+	list, err := s.List(ctx, prefix)
+	if err != nil {
+		return fmt.Errorf("failed listing CRL partitions: %w", err)
+	}
+
+	for _, entry := range list {
+		partition, err := strconv.Atoi(strings.TrimSuffix(entry, "-delta"))
+		if err == nil && partition < from {
+			continue
+		}
+
+		if err := s.Delete(ctx, prefix+entry); err != nil {
+			return fmt.Errorf("failed to delete CRL partition %v: %w", entry, err)
+		}
+	}
+
+	return nil
+}
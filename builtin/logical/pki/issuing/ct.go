@@ -0,0 +1,416 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package issuing
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-cleanhttp"
+	"github.com/hashicorp/vault/sdk/helper/certutil"
+	"github.com/hashicorp/vault/sdk/helper/errutil"
+	"github.com/hashicorp/vault/sdk/logical"
+	"golang.org/x/crypto/cryptobyte"
+	cbbasn1 "golang.org/x/crypto/cryptobyte/asn1"
+)
+
+const (
+	StorageCTLogPrefix = "ct-log/"
+
+	// ctLogTimeout bounds each submission so an unresponsive log cannot
+	// stall issuance indefinitely.
+	ctLogTimeout = 30 * time.Second
+
+	// Constants from RFC 6962 Section 3.2.
+	ctSCTVersionV1              = 0
+	ctSignatureTypeCertificate  = 0
+	ctLogEntryTypePrecert       = 1
+	ctHashAlgorithmSHA256       = 4
+	ctSignatureAlgorithmRSA     = 1
+	ctSignatureAlgorithmECDSA   = 3
+	ctMaxAddChainResponseLength = 64 * 1024
+)
+
+var (
+	// ExtensionCTPoisonOID marks a precertificate, see RFC 6962 Section 3.1.
+	ExtensionCTPoisonOID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 3}
+	// ExtensionSCTListOID holds the embedded SCT list, see RFC 6962 Section 3.3.
+	ExtensionSCTListOID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 2}
+)
+
+var ctLogClient = func() *http.Client {
+	client := cleanhttp.DefaultPooledClient()
+	client.Timeout = ctLogTimeout
+	return client
+}()
+
+// CTLogEntry is an RFC 6962 certificate transparency log to which
+// precertificates may be submitted.
+type CTLogEntry struct {
+	URL       string `json:"url"`
+	PublicKey string `json:"public_key"`
+}
+
+// ParsePublicKey returns the log's public key along with its log ID, the
+// SHA-256 hash of the DER encoded key.
+func (e *CTLogEntry) ParsePublicKey() (crypto.PublicKey, [sha256.Size]byte, error) {
+	block, _ := pem.Decode([]byte(e.PublicKey))
+	if block == nil {
+		return nil, [sha256.Size]byte{}, errors.New("public key is not PEM encoded")
+	}
+
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, [sha256.Size]byte{}, fmt.Errorf("failed to parse public key: %w", err)
+	}
+
+	switch pub.(type) {
+	case *ecdsa.PublicKey, *rsa.PublicKey:
+	default:
+		return nil, [sha256.Size]byte{}, fmt.Errorf("unsupported public key type %T; CT logs use ECDSA or RSA keys", pub)
+	}
+
+	return pub, sha256.Sum256(block.Bytes), nil
+}
+
+func GetCTLog(ctx context.Context, s logical.Storage, name string) (*CTLogEntry, error) {
+	entry, err := s.Get(ctx, StorageCTLogPrefix+name)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var log CTLogEntry
+	if err := entry.DecodeJSON(&log); err != nil {
+		return nil, errutil.InternalError{Err: fmt.Sprintf("unable to decode CT log %s: %v", name, err)}
+	}
+
+	return &log, nil
+}
+
+func SetCTLog(ctx context.Context, s logical.Storage, name string, log *CTLogEntry) error {
+	json, err := logical.StorageEntryJSON(StorageCTLogPrefix+name, log)
+	if err != nil {
+		return err
+	}
+
+	return s.Put(ctx, json)
+}
+
+// AddSignedCertificateTimestamps submits a precertificate for the issued
+// certificate to each of the role's CT logs and replaces the certificate in
+// the bundle with one embedding the SCTs obtained. Logs which fail are
+// reported as warnings, provided the role's minimum number of SCTs is met.
+func AddSignedCertificateTimestamps(ctx context.Context, s logical.Storage, role *RoleEntry, caSign *certutil.CAInfoBundle, bundle *certutil.ParsedCertBundle) ([]string, error) {
+	if len(role.CTLogs) == 0 {
+		return nil, nil
+	}
+
+	logs := make([]*CTLogEntry, len(role.CTLogs))
+	for i, name := range role.CTLogs {
+		log, err := GetCTLog(ctx, s, name)
+		if err != nil {
+			return nil, err
+		}
+		if log == nil {
+			return nil, errutil.UserError{Err: fmt.Sprintf("CT log %q configured on the role does not exist", name)}
+		}
+		logs[i] = log
+	}
+
+	precert, err := resignWithExtension(bundle.Certificate, caSign, pkix.Extension{
+		Id:       ExtensionCTPoisonOID,
+		Critical: true,
+		Value:    asn1.NullBytes,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Logs sign the precertificate's TBSCertificate with the poison
+	// extension removed, which the final certificate must match once its
+	// SCT list is removed.
+	tbs, err := TBSWithoutExtension(precert, ExtensionCTPoisonOID)
+	if err != nil {
+		return nil, errutil.InternalError{Err: fmt.Sprintf("unable to parse precertificate: %v", err)}
+	}
+	issuerKeyHash := sha256.Sum256(caSign.Certificate.RawSubjectPublicKeyInfo)
+
+	chain := [][]byte{precert}
+	for _, block := range bundle.CAChain {
+		chain = append(chain, block.Bytes)
+	}
+
+	var scts [][]byte
+	var warnings []string
+	for i, log := range logs {
+		sct, err := submitPrecertificate(ctx, log, chain, issuerKeyHash, tbs)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("failed to obtain an SCT from CT log %q: %v", role.CTLogs[i], err))
+			continue
+		}
+		scts = append(scts, sct)
+	}
+
+	required := role.CTMinSCTs
+	if required == 0 {
+		required = len(logs)
+	}
+	if len(scts) < required {
+		return nil, errutil.InternalError{Err: fmt.Sprintf("obtained %d of the %d SCTs required: %s", len(scts), required, strings.Join(warnings, "; "))}
+	}
+
+	sctList, err := marshalSCTList(scts)
+	if err != nil {
+		return nil, errutil.InternalError{Err: fmt.Sprintf("unable to encode SCT list: %v", err)}
+	}
+	certBytes, err := resignWithExtension(bundle.Certificate, caSign, pkix.Extension{
+		Id:    ExtensionSCTListOID,
+		Value: sctList,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	finalTBS, err := TBSWithoutExtension(certBytes, ExtensionSCTListOID)
+	if err != nil {
+		return nil, errutil.InternalError{Err: fmt.Sprintf("unable to parse certificate: %v", err)}
+	}
+	if !bytes.Equal(finalTBS, tbs) {
+		return nil, errutil.InternalError{Err: "certificate does not match the precertificate submitted to CT logs"}
+	}
+
+	cert, err := x509.ParseCertificate(certBytes)
+	if err != nil {
+		return nil, errutil.InternalError{Err: fmt.Sprintf("unable to parse created certificate: %v", err)}
+	}
+	bundle.Certificate = cert
+	bundle.CertificateBytes = certBytes
+
+	return warnings, nil
+}
+
+// resignWithExtension signs a copy of cert with ext appended to its
+// extensions. All other extensions are carried over verbatim and in order,
+// so copies differing only in the appended extension share the rest of
+// their TBSCertificate.
+func resignWithExtension(cert *x509.Certificate, caSign *certutil.CAInfoBundle, ext pkix.Extension) ([]byte, error) {
+	template := *cert
+	template.ExtraExtensions = append(append([]pkix.Extension{}, cert.Extensions...), ext)
+
+	certBytes, err := x509.CreateCertificate(rand.Reader, &template, caSign.Certificate, cert.PublicKey, caSign.PrivateKey)
+	if err != nil {
+		return nil, errutil.InternalError{Err: fmt.Sprintf("unable to create certificate: %v", err)}
+	}
+	return certBytes, nil
+}
+
+// TBSWithoutExtension returns the DER encoded TBSCertificate of a
+// certificate with the given extension removed.
+func TBSWithoutExtension(certDER []byte, oid asn1.ObjectIdentifier) ([]byte, error) {
+	input := cryptobyte.String(certDER)
+	var cert, tbs cryptobyte.String
+	if !input.ReadASN1(&cert, cbbasn1.SEQUENCE) || !cert.ReadASN1(&tbs, cbbasn1.SEQUENCE) {
+		return nil, errors.New("malformed certificate")
+	}
+
+	extensionsTag := cbbasn1.Tag(3).Constructed().ContextSpecific()
+
+	var b cryptobyte.Builder
+	b.AddASN1(cbbasn1.SEQUENCE, func(b *cryptobyte.Builder) {
+		for !tbs.Empty() {
+			var elem cryptobyte.String
+			var tag cbbasn1.Tag
+			if !tbs.ReadAnyASN1Element(&elem, &tag) {
+				b.SetError(errors.New("malformed TBSCertificate"))
+				return
+			}
+			if tag != extensionsTag {
+				b.AddBytes(elem)
+				continue
+			}
+
+			var wrapper, exts cryptobyte.String
+			if !elem.ReadASN1(&wrapper, extensionsTag) || !wrapper.ReadASN1(&exts, cbbasn1.SEQUENCE) {
+				b.SetError(errors.New("malformed extensions"))
+				return
+			}
+			b.AddASN1(extensionsTag, func(b *cryptobyte.Builder) {
+				b.AddASN1(cbbasn1.SEQUENCE, func(b *cryptobyte.Builder) {
+					for !exts.Empty() {
+						var ext, body cryptobyte.String
+						var id asn1.ObjectIdentifier
+						if !exts.ReadASN1Element(&ext, cbbasn1.SEQUENCE) {
+							b.SetError(errors.New("malformed extension"))
+							return
+						}
+						parsed := ext
+						if !parsed.ReadASN1(&body, cbbasn1.SEQUENCE) || !body.ReadASN1ObjectIdentifier(&id) {
+							b.SetError(errors.New("malformed extension"))
+							return
+						}
+						if !id.Equal(oid) {
+							b.AddBytes(ext)
+						}
+					}
+				})
+			})
+		}
+	})
+
+	return b.Bytes()
+}
+
+// addChainResponse is the response of a log's add-pre-chain endpoint, see
+// RFC 6962 Section 4.1.
+type addChainResponse struct {
+	SCTVersion uint8  `json:"sct_version"`
+	ID         []byte `json:"id"`
+	Timestamp  uint64 `json:"timestamp"`
+	Extensions []byte `json:"extensions"`
+	Signature  []byte `json:"signature"`
+}
+
+// submitPrecertificate submits a precertificate chain to a log, verifies
+// the SCT returned against the log's key and returns it serialized for
+// embedding.
+func submitPrecertificate(ctx context.Context, log *CTLogEntry, chain [][]byte, issuerKeyHash [sha256.Size]byte, tbs []byte) ([]byte, error) {
+	pub, logID, err := log.ParsePublicKey()
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := json.Marshal(map[string][][]byte{"chain": chain})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(log.URL, "/")+"/ct/v1/add-pre-chain", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := ctLogClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, ctMaxAddChainResponseLength))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+
+	var sct addChainResponse
+	if err := json.Unmarshal(respBody, &sct); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if sct.SCTVersion != ctSCTVersionV1 {
+		return nil, fmt.Errorf("unsupported SCT version %d", sct.SCTVersion)
+	}
+	if !bytes.Equal(sct.ID, logID[:]) {
+		return nil, errors.New("SCT log ID does not match the log's public key")
+	}
+
+	var signed cryptobyte.Builder
+	signed.AddUint8(ctSCTVersionV1)
+	signed.AddUint8(ctSignatureTypeCertificate)
+	signed.AddUint64(sct.Timestamp)
+	signed.AddUint16(ctLogEntryTypePrecert)
+	signed.AddBytes(issuerKeyHash[:])
+	signed.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddBytes(tbs)
+	})
+	signed.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddBytes(sct.Extensions)
+	})
+	signedBytes, err := signed.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySCTSignature(pub, signedBytes, sct.Signature); err != nil {
+		return nil, err
+	}
+
+	var serialized cryptobyte.Builder
+	serialized.AddUint8(sct.SCTVersion)
+	serialized.AddBytes(sct.ID)
+	serialized.AddUint64(sct.Timestamp)
+	serialized.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddBytes(sct.Extensions)
+	})
+	serialized.AddBytes(sct.Signature)
+	return serialized.Bytes()
+}
+
+// verifySCTSignature verifies a TLS encoded digitally-signed struct, see
+// RFC 5246 Section 4.7.
+func verifySCTSignature(pub crypto.PublicKey, signed []byte, digitallySigned []byte) error {
+	input := cryptobyte.String(digitallySigned)
+	var hashAlg, sigAlg uint8
+	var sig cryptobyte.String
+	if !input.ReadUint8(&hashAlg) || !input.ReadUint8(&sigAlg) || !input.ReadUint16LengthPrefixed(&sig) || !input.Empty() {
+		return errors.New("malformed SCT signature")
+	}
+	if hashAlg != ctHashAlgorithmSHA256 {
+		return fmt.Errorf("unsupported SCT hash algorithm %d", hashAlg)
+	}
+
+	digest := sha256.Sum256(signed)
+	switch key := pub.(type) {
+	case *ecdsa.PublicKey:
+		if sigAlg != ctSignatureAlgorithmECDSA || !ecdsa.VerifyASN1(key, digest[:], sig) {
+			return errors.New("invalid SCT signature")
+		}
+	case *rsa.PublicKey:
+		if sigAlg != ctSignatureAlgorithmRSA || rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig) != nil {
+			return errors.New("invalid SCT signature")
+		}
+	default:
+		return fmt.Errorf("unsupported public key type %T", pub)
+	}
+
+	return nil
+}
+
+// marshalSCTList encodes the value of the SCT list extension: a
+// SignedCertificateTimestampList wrapped in an OCTET STRING.
+func marshalSCTList(scts [][]byte) ([]byte, error) {
+	var b cryptobyte.Builder
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		for _, sct := range scts {
+			b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+				b.AddBytes(sct)
+			})
+		}
+	})
+	list, err := b.Bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(list)
+}
@@ -0,0 +1,517 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package issuing
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-bexpr"
+	"github.com/hashicorp/go-secure-stdlib/parseutil"
+	"github.com/hashicorp/go-secure-stdlib/strutil"
+	"github.com/hashicorp/vault/builtin/logical/pki/parsing"
+	"github.com/hashicorp/vault/sdk/helper/certutil"
+	"github.com/hashicorp/vault/sdk/helper/errutil"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/ryanuber/go-glob"
+)
+
+const (
+	IssuancePolicyActionAllow  = "allow"
+	IssuancePolicyActionDeny   = "deny"
+	IssuancePolicyActionMutate = "mutate"
+)
+
+// IssuancePolicyRule is a single rule of a role's local issuance policy.
+// Rules are evaluated in order: the first matching allow or deny rule
+// decides issuance, while matching mutate rules modify the certificate and
+// evaluation continues with the next rule. When no rule decides, issuance
+// proceeds as the role otherwise allows.
+type IssuancePolicyRule struct {
+	Name string `json:"name"`
+	// Condition is a go-bexpr expression over an IssuancePolicyInput; an
+	// empty condition always matches.
+	Condition string `json:"condition"`
+	Action    string `json:"action"`
+
+	// The remaining fields are only valid on mutate rules.
+	MaxTTL          string   `json:"max_ttl,omitempty"`
+	AddAltNames     []string `json:"add_alt_names,omitempty"`
+	RemoveAltNames  []string `json:"remove_alt_names,omitempty"`
+	AddIPSANs       []string `json:"add_ip_sans,omitempty"`
+	RemoveIPSANs    []string `json:"remove_ip_sans,omitempty"`
+	AddURISANs      []string `json:"add_uri_sans,omitempty"`
+	RemoveURISANs   []string `json:"remove_uri_sans,omitempty"`
+	KeyUsage        []string `json:"key_usage,omitempty"`
+	ExtKeyUsage     []string `json:"ext_key_usage,omitempty"`
+	ExtKeyUsageOIDs []string `json:"ext_key_usage_oids,omitempty"`
+}
+
+// IssuancePolicyInput is the data issuance policy conditions are evaluated
+// against.
+type IssuancePolicyInput struct {
+	CSR         IssuancePolicyCSR         `bexpr:"csr"`
+	Certificate IssuancePolicyCertificate `bexpr:"certificate"`
+	Entity      IssuancePolicyEntity      `bexpr:"entity"`
+	Role        IssuancePolicyRole        `bexpr:"role"`
+}
+
+// IssuancePolicyCSR describes the CSR being signed; it is empty when Vault
+// generates the key.
+type IssuancePolicyCSR struct {
+	Present            bool     `bexpr:"present"`
+	CommonName         string   `bexpr:"common_name"`
+	Organization       []string `bexpr:"organization"`
+	OrganizationalUnit []string `bexpr:"ou"`
+	DNSNames           []string `bexpr:"dns_names"`
+	EmailAddresses     []string `bexpr:"email_addresses"`
+	IPAddresses        []string `bexpr:"ip_addresses"`
+	URIs               []string `bexpr:"uri_sans"`
+	KeyType            string   `bexpr:"key_type"`
+	KeyBits            int      `bexpr:"key_bits"`
+}
+
+// IssuancePolicyCertificate describes the certificate about to be issued,
+// including the changes of any earlier mutate rules.
+type IssuancePolicyCertificate struct {
+	CommonName     string   `bexpr:"common_name"`
+	DNSNames       []string `bexpr:"dns_names"`
+	EmailAddresses []string `bexpr:"email_addresses"`
+	IPAddresses    []string `bexpr:"ip_addresses"`
+	URIs           []string `bexpr:"uri_sans"`
+}
+
+// IssuancePolicyEntity describes the identity entity of the requester.
+type IssuancePolicyEntity struct {
+	ID          string            `bexpr:"id"`
+	Name        string            `bexpr:"name"`
+	DisplayName string            `bexpr:"display_name"`
+	Metadata    map[string]string `bexpr:"metadata"`
+	Groups      []string          `bexpr:"groups"`
+}
+
+// IssuancePolicyRole describes the role issuing the certificate.
+type IssuancePolicyRole struct {
+	Name      string `bexpr:"name"`
+	IssuerRef string `bexpr:"issuer_ref"`
+	KeyType   string `bexpr:"key_type"`
+}
+
+// ParseIssuancePolicy reads an issuance policy from either a JSON string or
+// an already decoded list of rules, and validates it.
+func ParseIssuancePolicy(raw interface{}) ([]IssuancePolicyRule, error) {
+	var encoded []byte
+	switch typed := raw.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		if strings.TrimSpace(typed) == "" {
+			return nil, nil
+		}
+		encoded = []byte(typed)
+	default:
+		var err error
+		encoded, err = json.Marshal(typed)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var rules []IssuancePolicyRule
+	if err := json.Unmarshal(encoded, &rules); err != nil {
+		return nil, fmt.Errorf("unable to parse issuance policy: %w", err)
+	}
+
+	names := make(map[string]struct{}, len(rules))
+	for index, rule := range rules {
+		if rule.Name == "" {
+			return nil, fmt.Errorf("issuance policy rule %d is missing a name", index)
+		}
+		if _, ok := names[rule.Name]; ok {
+			return nil, fmt.Errorf("issuance policy rule name %q is used more than once", rule.Name)
+		}
+		names[rule.Name] = struct{}{}
+
+		if err := rule.validate(); err != nil {
+			return nil, fmt.Errorf("invalid issuance policy rule %q: %w", rule.Name, err)
+		}
+	}
+
+	return rules, nil
+}
+
+func (r *IssuancePolicyRule) validate() error {
+	// Evaluating against an empty input catches selectors which do not
+	// exist, rather than failing every issuance later on.
+	if _, err := r.matches(&IssuancePolicyInput{}); err != nil {
+		return err
+	}
+
+	switch r.Action {
+	case IssuancePolicyActionAllow, IssuancePolicyActionDeny:
+		if r.hasMutations() {
+			return fmt.Errorf("only %q rules may modify the certificate", IssuancePolicyActionMutate)
+		}
+		return nil
+	case IssuancePolicyActionMutate:
+	default:
+		return fmt.Errorf("unknown action %q; must be one of %q, %q or %q", r.Action,
+			IssuancePolicyActionAllow, IssuancePolicyActionDeny, IssuancePolicyActionMutate)
+	}
+
+	if r.MaxTTL != "" {
+		ttl, err := parseutil.ParseDurationSecond(r.MaxTTL)
+		if err != nil {
+			return fmt.Errorf("invalid max_ttl: %w", err)
+		}
+		if ttl <= 0 {
+			return errors.New("max_ttl must be positive")
+		}
+	}
+	for _, ip := range r.AddIPSANs {
+		if net.ParseIP(ip) == nil {
+			return fmt.Errorf("invalid IP address %q in add_ip_sans", ip)
+		}
+	}
+	for _, ip := range r.RemoveIPSANs {
+		if _, _, err := net.ParseCIDR(ip); err != nil && net.ParseIP(ip) == nil {
+			return fmt.Errorf("invalid IP address or CIDR %q in remove_ip_sans", ip)
+		}
+	}
+	for _, uri := range r.AddURISANs {
+		if _, err := url.Parse(uri); err != nil {
+			return fmt.Errorf("invalid URI %q in add_uri_sans: %w", uri, err)
+		}
+	}
+	for _, oid := range r.ExtKeyUsageOIDs {
+		if _, err := certutil.StringToOid(oid); err != nil {
+			return fmt.Errorf("invalid OID %q in ext_key_usage_oids: %w", oid, err)
+		}
+	}
+
+	return nil
+}
+
+func (r *IssuancePolicyRule) hasMutations() bool {
+	return r.MaxTTL != "" ||
+		len(r.AddAltNames) > 0 || len(r.RemoveAltNames) > 0 ||
+		len(r.AddIPSANs) > 0 || len(r.RemoveIPSANs) > 0 ||
+		len(r.AddURISANs) > 0 || len(r.RemoveURISANs) > 0 ||
+		len(r.KeyUsage) > 0 || len(r.ExtKeyUsage) > 0 || len(r.ExtKeyUsageOIDs) > 0
+}
+
+func (r *IssuancePolicyRule) matches(input *IssuancePolicyInput) (bool, error) {
+	if strings.TrimSpace(r.Condition) == "" {
+		return true, nil
+	}
+
+	eval, err := bexpr.CreateEvaluator(r.Condition)
+	if err != nil {
+		return false, fmt.Errorf("invalid condition: %w", err)
+	}
+
+	return eval.Evaluate(input)
+}
+
+func (r *IssuancePolicyRule) mutate(params *certutil.CreationParameters) error {
+	if r.MaxTTL != "" {
+		ttl, err := parseutil.ParseDurationSecond(r.MaxTTL)
+		if err != nil {
+			return err
+		}
+		if notAfter := time.Now().Add(ttl); notAfter.Before(params.NotAfter) {
+			params.NotAfter = notAfter
+		}
+	}
+
+	if len(r.RemoveAltNames) > 0 {
+		params.DNSNames = removeGlobMatches(params.DNSNames, r.RemoveAltNames)
+		params.EmailAddresses = removeGlobMatches(params.EmailAddresses, r.RemoveAltNames)
+	}
+	for _, name := range r.AddAltNames {
+		if strings.Contains(name, "@") {
+			params.EmailAddresses = append(params.EmailAddresses, name)
+		} else {
+			params.DNSNames = append(params.DNSNames, name)
+		}
+	}
+	params.DNSNames = strutil.RemoveDuplicates(params.DNSNames, false)
+	params.EmailAddresses = strutil.RemoveDuplicates(params.EmailAddresses, false)
+
+	if len(r.RemoveIPSANs) > 0 {
+		var kept []net.IP
+		for _, ip := range params.IPAddresses {
+			if !ipMatchesAny(ip, r.RemoveIPSANs) {
+				kept = append(kept, ip)
+			}
+		}
+		params.IPAddresses = kept
+	}
+	for _, raw := range r.AddIPSANs {
+		ip := net.ParseIP(raw)
+		if !ipMatchesAny(ip, ipStrings(params.IPAddresses)) {
+			params.IPAddresses = append(params.IPAddresses, ip)
+		}
+	}
+
+	if len(r.RemoveURISANs) > 0 {
+		var kept []*url.URL
+		for _, uri := range params.URIs {
+			if !globMatchesAny(uri.String(), r.RemoveURISANs) {
+				kept = append(kept, uri)
+			}
+		}
+		params.URIs = kept
+	}
+	for _, raw := range r.AddURISANs {
+		uri, err := url.Parse(raw)
+		if err != nil {
+			return err
+		}
+		params.URIs = append(params.URIs, uri)
+	}
+
+	if len(r.KeyUsage) > 0 {
+		params.KeyUsage = x509.KeyUsage(parsing.ParseKeyUsages(r.KeyUsage))
+	}
+	if len(r.ExtKeyUsage) > 0 {
+		params.ExtKeyUsage = ParseExtKeyUsagesFromRole(&RoleEntry{ExtKeyUsage: r.ExtKeyUsage})
+	}
+	if len(r.ExtKeyUsageOIDs) > 0 {
+		params.ExtKeyUsageOIDs = r.ExtKeyUsageOIDs
+	}
+
+	return nil
+}
+
+// ApplyIssuancePolicy evaluates the role's issuance policy for the
+// certificate described by creation, applying the changes of matching
+// mutate rules to it. The rules which matched are returned as warnings, so
+// that the decision is visible to the requester and in the audit log.
+// Subject alternative names changed by mutate rules are validated against
+// the role again, so a policy cannot grant names the role does not allow.
+func ApplyIssuancePolicy(b logical.SystemView, role *RoleEntry, entityInfo EntityInfo, creation *certutil.CreationBundle) ([]string, error) {
+	if len(role.IssuancePolicy) == 0 {
+		return nil, nil
+	}
+
+	warnings, mutated, err := evaluateIssuancePolicy(b, role, entityInfo, creation)
+	if err != nil || !mutated {
+		return warnings, err
+	}
+
+	return warnings, validateIssuancePolicySANs(b, role, entityInfo, creation.Params)
+}
+
+// evaluateIssuancePolicy runs the role's issuance policy rules in order,
+// reporting whether any mutate rule matched.
+func evaluateIssuancePolicy(b logical.SystemView, role *RoleEntry, entityInfo EntityInfo, creation *certutil.CreationBundle) ([]string, bool, error) {
+	entity, err := newIssuancePolicyEntity(b, entityInfo)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var warnings []string
+	mutated := false
+	for _, rule := range role.IssuancePolicy {
+		input := &IssuancePolicyInput{
+			CSR:         newIssuancePolicyCSR(creation.CSR),
+			Certificate: newIssuancePolicyCertificate(creation.Params),
+			Entity:      entity,
+			Role: IssuancePolicyRole{
+				Name:      role.Name,
+				IssuerRef: role.Issuer,
+				KeyType:   role.KeyType,
+			},
+		}
+
+		matched, err := rule.matches(input)
+		if err != nil {
+			return warnings, mutated, fmt.Errorf("failed evaluating issuance policy rule %q: %w", rule.Name, err)
+		}
+		if !matched {
+			continue
+		}
+
+		switch rule.Action {
+		case IssuancePolicyActionDeny:
+			return warnings, mutated, errutil.UserError{Err: fmt.Sprintf("issuance denied by issuance policy rule %q", rule.Name)}
+		case IssuancePolicyActionAllow:
+			return append(warnings, fmt.Sprintf("issuance allowed by issuance policy rule %q", rule.Name)), mutated, nil
+		case IssuancePolicyActionMutate:
+			if err := rule.mutate(creation.Params); err != nil {
+				return warnings, mutated, fmt.Errorf("failed applying issuance policy rule %q: %w", rule.Name, err)
+			}
+			mutated = true
+			warnings = append(warnings, fmt.Sprintf("certificate modified by issuance policy rule %q", rule.Name))
+		}
+	}
+
+	return warnings, mutated, nil
+}
+
+// validateIssuancePolicySANs applies the role's subject alternative name
+// restrictions to the names of a certificate modified by issuance policy.
+func validateIssuancePolicySANs(b logical.SystemView, role *RoleEntry, entityInfo EntityInfo, params *certutil.CreationParameters) error {
+	if badName := ValidateNames(b, role, entityInfo, params.DNSNames); badName != "" {
+		return errutil.UserError{Err: fmt.Sprintf(
+			"subject alternate name %s added by issuance policy not allowed by this role", badName)}
+	}
+	if badName := ValidateNames(b, role, entityInfo, params.EmailAddresses); badName != "" {
+		return errutil.UserError{Err: fmt.Sprintf(
+			"email address %s added by issuance policy not allowed by this role", badName)}
+	}
+	if len(params.IPAddresses) > 0 && !role.AllowIPSANs {
+		return errutil.UserError{Err: "IP Subject Alternative Names added by issuance policy are not allowed in this role"}
+	}
+	for _, uri := range params.URIs {
+		if !ValidateURISAN(b, role, entityInfo, uri.String()) {
+			return errutil.UserError{Err: fmt.Sprintf(
+				"URI Subject Alternative Name %s added by issuance policy not allowed by this role", uri)}
+		}
+	}
+	if len(params.OtherSANs) > 0 {
+		badOID, badName, err := ValidateOtherSANs(role, params.OtherSANs)
+		switch {
+		case err != nil:
+			return errutil.UserError{Err: err.Error()}
+		case len(badName) > 0:
+			return errutil.UserError{Err: fmt.Sprintf(
+				"other SAN %s not allowed for OID %s by this role", badName, badOID)}
+		case len(badOID) > 0:
+			return errutil.UserError{Err: fmt.Sprintf(
+				"other SAN OID %s not allowed by this role", badOID)}
+		}
+	}
+
+	return nil
+}
+
+func newIssuancePolicyEntity(b logical.SystemView, entityInfo EntityInfo) (IssuancePolicyEntity, error) {
+	entity := IssuancePolicyEntity{
+		ID:          entityInfo.EntityID,
+		DisplayName: entityInfo.DisplayName,
+		Metadata:    map[string]string{},
+	}
+	if entityInfo.EntityID == "" {
+		return entity, nil
+	}
+
+	info, err := b.EntityInfo(entityInfo.EntityID)
+	if err != nil {
+		return entity, fmt.Errorf("failed looking up entity: %w", err)
+	}
+	if info != nil {
+		entity.Name = info.Name
+		for key, value := range info.Metadata {
+			entity.Metadata[key] = value
+		}
+	}
+
+	groups, err := b.GroupsForEntity(entityInfo.EntityID)
+	if err != nil {
+		return entity, fmt.Errorf("failed looking up entity groups: %w", err)
+	}
+	for _, group := range groups {
+		entity.Groups = append(entity.Groups, group.Name)
+	}
+
+	return entity, nil
+}
+
+func newIssuancePolicyCSR(csr *x509.CertificateRequest) IssuancePolicyCSR {
+	if csr == nil {
+		return IssuancePolicyCSR{}
+	}
+
+	policyCSR := IssuancePolicyCSR{
+		Present:            true,
+		CommonName:         csr.Subject.CommonName,
+		Organization:       csr.Subject.Organization,
+		OrganizationalUnit: csr.Subject.OrganizationalUnit,
+		DNSNames:           csr.DNSNames,
+		EmailAddresses:     csr.EmailAddresses,
+		IPAddresses:        ipStrings(csr.IPAddresses),
+		URIs:               uriStrings(csr.URIs),
+	}
+	switch key := csr.PublicKey.(type) {
+	case *rsa.PublicKey:
+		policyCSR.KeyType = "rsa"
+		policyCSR.KeyBits = key.N.BitLen()
+	case *ecdsa.PublicKey:
+		policyCSR.KeyType = "ec"
+		policyCSR.KeyBits = key.Curve.Params().BitSize
+	case ed25519.PublicKey:
+		policyCSR.KeyType = "ed25519"
+	}
+
+	return policyCSR
+}
+
+func newIssuancePolicyCertificate(params *certutil.CreationParameters) IssuancePolicyCertificate {
+	return IssuancePolicyCertificate{
+		CommonName:     params.Subject.CommonName,
+		DNSNames:       params.DNSNames,
+		EmailAddresses: params.EmailAddresses,
+		IPAddresses:    ipStrings(params.IPAddresses),
+		URIs:           uriStrings(params.URIs),
+	}
+}
+
+func removeGlobMatches(values []string, patterns []string) []string {
+	var kept []string
+	for _, value := range values {
+		if !globMatchesAny(value, patterns) {
+			kept = append(kept, value)
+		}
+	}
+	return kept
+}
+
+func globMatchesAny(value string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if glob.Glob(strings.ToLower(pattern), strings.ToLower(value)) {
+			return true
+		}
+	}
+	return false
+}
+
+func ipMatchesAny(ip net.IP, entries []string) bool {
+	for _, entry := range entries {
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			if network.Contains(ip) {
+				return true
+			}
+		} else if ip.Equal(net.ParseIP(entry)) {
+			return true
+		}
+	}
+	return false
+}
+
+func ipStrings(ips []net.IP) []string {
+	values := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		values = append(values, ip.String())
+	}
+	return values
+}
+
+func uriStrings(uris []*url.URL) []string {
+	values := make([]string, 0, len(uris))
+	for _, uri := range uris {
+		values = append(values, uri.String())
+	}
+	return values
+}
@@ -422,6 +422,12 @@ func GenerateCreationBundle(b logical.SystemView, role *RoleEntry, entityInfo En
 		CSR:           csr,
 	}
 
+	policyWarnings, err := ApplyIssuancePolicy(b, role, entityInfo, creation)
+	warnings = append(warnings, policyWarnings...)
+	if err != nil {
+		return nil, warnings, err
+	}
+
 	// Don't deal with URLs or max path length if it's self-signed, as these
 	// normally come from the signing bundle
 	if caSign == nil {
@@ -431,6 +437,18 @@ func GenerateCreationBundle(b logical.SystemView, role *RoleEntry, entityInfo En
 	// This will have been read in from the getGlobalAIAURLs function
 	creation.Params.URLs = caSign.URLs
 
+	// When the issuer's CRL is partitioned, the CRL distribution points
+	// depend on the serial number, so choose it ahead of signing.
+	if caSign.CRLPartitions > 0 && caSign.URLs != nil && HasCRLPartitionURLs(caSign.URLs.CRLDistributionPoints) {
+		serialNumber, err := certutil.GenerateSerialNumber()
+		if err != nil {
+			return nil, warnings, errutil.InternalError{Err: fmt.Sprintf("unable to generate serial number: %v", err)}
+		}
+
+		creation.Params.SerialNumber = serialNumber
+		creation.Params.URLs = CRLPartitionURLs(caSign.URLs, caSign.CRLPartitions, serialNumber)
+	}
+
 	// If the max path length in the role is not nil, it was specified at
 	// generation time with the max_path_length parameter; otherwise derive it
 	// from the signing certificate
@@ -127,6 +127,7 @@ type IssuerEntry struct {
 	RevocationTime       int64                     `json:"revocation_time"`
 	RevocationTimeUTC    time.Time                 `json:"revocation_time_utc"`
 	AIAURIs              *AiaConfigEntry           `json:"aia_uris,omitempty"`
+	CRLPartitions        int                       `json:"crl_partitions,omitempty"`
 	LastModified         time.Time                 `json:"last_modified"`
 	Version              uint                      `json:"version"`
 }
@@ -402,6 +403,7 @@ func FetchCAInfoByIssuerId(ctx context.Context, s logical.Storage, mkv managed_k
 		URLs:                 nil,
 		LeafNotAfterBehavior: entry.LeafNotAfterBehavior,
 		RevocationSigAlg:     entry.RevocationSigAlg,
+		CRLPartitions:        entry.CRLPartitions,
 	}
 
 	entries, err := GetAIAURLs(ctx, s, entry)
@@ -27,64 +27,67 @@ const (
 )
 
 type RoleEntry struct {
-	LeaseMax                      string        `json:"lease_max"`
-	Lease                         string        `json:"lease"`
-	DeprecatedMaxTTL              string        `json:"max_ttl"`
-	DeprecatedTTL                 string        `json:"ttl"`
-	TTL                           time.Duration `json:"ttl_duration"`
-	MaxTTL                        time.Duration `json:"max_ttl_duration"`
-	AllowLocalhost                bool          `json:"allow_localhost"`
-	AllowedBaseDomain             string        `json:"allowed_base_domain"`
-	AllowedDomainsOld             string        `json:"allowed_domains,omitempty"`
-	AllowedDomains                []string      `json:"allowed_domains_list"`
-	AllowedDomainsTemplate        bool          `json:"allowed_domains_template"`
-	AllowBaseDomain               bool          `json:"allow_base_domain"`
-	AllowBareDomains              bool          `json:"allow_bare_domains"`
-	AllowTokenDisplayName         bool          `json:"allow_token_displayname"`
-	AllowSubdomains               bool          `json:"allow_subdomains"`
-	AllowGlobDomains              bool          `json:"allow_glob_domains"`
-	AllowWildcardCertificates     *bool         `json:"allow_wildcard_certificates,omitempty"`
-	AllowAnyName                  bool          `json:"allow_any_name"`
-	EnforceHostnames              bool          `json:"enforce_hostnames"`
-	AllowIPSANs                   bool          `json:"allow_ip_sans"`
-	ServerFlag                    bool          `json:"server_flag"`
-	ClientFlag                    bool          `json:"client_flag"`
-	CodeSigningFlag               bool          `json:"code_signing_flag"`
-	EmailProtectionFlag           bool          `json:"email_protection_flag"`
-	UseCSRCommonName              bool          `json:"use_csr_common_name"`
-	UseCSRSANs                    bool          `json:"use_csr_sans"`
-	KeyType                       string        `json:"key_type"`
-	KeyBits                       int           `json:"key_bits"`
-	UsePSS                        bool          `json:"use_pss"`
-	SignatureBits                 int           `json:"signature_bits"`
-	MaxPathLength                 *int          `json:",omitempty"`
-	KeyUsageOld                   string        `json:"key_usage,omitempty"`
-	KeyUsage                      []string      `json:"key_usage_list"`
-	ExtKeyUsage                   []string      `json:"extended_key_usage_list"`
-	OUOld                         string        `json:"ou,omitempty"`
-	OU                            []string      `json:"ou_list"`
-	OrganizationOld               string        `json:"organization,omitempty"`
-	Organization                  []string      `json:"organization_list"`
-	Country                       []string      `json:"country"`
-	Locality                      []string      `json:"locality"`
-	Province                      []string      `json:"province"`
-	StreetAddress                 []string      `json:"street_address"`
-	PostalCode                    []string      `json:"postal_code"`
-	GenerateLease                 *bool         `json:"generate_lease,omitempty"`
-	NoStore                       bool          `json:"no_store"`
-	RequireCN                     bool          `json:"require_cn"`
-	CNValidations                 []string      `json:"cn_validations"`
-	AllowedOtherSANs              []string      `json:"allowed_other_sans"`
-	AllowedSerialNumbers          []string      `json:"allowed_serial_numbers"`
-	AllowedUserIDs                []string      `json:"allowed_user_ids"`
-	AllowedURISANs                []string      `json:"allowed_uri_sans"`
-	AllowedURISANsTemplate        bool          `json:"allowed_uri_sans_template"`
-	PolicyIdentifiers             []string      `json:"policy_identifiers"`
-	ExtKeyUsageOIDs               []string      `json:"ext_key_usage_oids"`
-	BasicConstraintsValidForNonCA bool          `json:"basic_constraints_valid_for_non_ca"`
-	NotBeforeDuration             time.Duration `json:"not_before_duration"`
-	NotAfter                      string        `json:"not_after"`
-	Issuer                        string        `json:"issuer"`
+	LeaseMax                      string               `json:"lease_max"`
+	Lease                         string               `json:"lease"`
+	DeprecatedMaxTTL              string               `json:"max_ttl"`
+	DeprecatedTTL                 string               `json:"ttl"`
+	TTL                           time.Duration        `json:"ttl_duration"`
+	MaxTTL                        time.Duration        `json:"max_ttl_duration"`
+	AllowLocalhost                bool                 `json:"allow_localhost"`
+	AllowedBaseDomain             string               `json:"allowed_base_domain"`
+	AllowedDomainsOld             string               `json:"allowed_domains,omitempty"`
+	AllowedDomains                []string             `json:"allowed_domains_list"`
+	AllowedDomainsTemplate        bool                 `json:"allowed_domains_template"`
+	AllowBaseDomain               bool                 `json:"allow_base_domain"`
+	AllowBareDomains              bool                 `json:"allow_bare_domains"`
+	AllowTokenDisplayName         bool                 `json:"allow_token_displayname"`
+	AllowSubdomains               bool                 `json:"allow_subdomains"`
+	AllowGlobDomains              bool                 `json:"allow_glob_domains"`
+	AllowWildcardCertificates     *bool                `json:"allow_wildcard_certificates,omitempty"`
+	AllowAnyName                  bool                 `json:"allow_any_name"`
+	EnforceHostnames              bool                 `json:"enforce_hostnames"`
+	AllowIPSANs                   bool                 `json:"allow_ip_sans"`
+	ServerFlag                    bool                 `json:"server_flag"`
+	ClientFlag                    bool                 `json:"client_flag"`
+	CodeSigningFlag               bool                 `json:"code_signing_flag"`
+	EmailProtectionFlag           bool                 `json:"email_protection_flag"`
+	UseCSRCommonName              bool                 `json:"use_csr_common_name"`
+	UseCSRSANs                    bool                 `json:"use_csr_sans"`
+	KeyType                       string               `json:"key_type"`
+	KeyBits                       int                  `json:"key_bits"`
+	UsePSS                        bool                 `json:"use_pss"`
+	SignatureBits                 int                  `json:"signature_bits"`
+	MaxPathLength                 *int                 `json:",omitempty"`
+	KeyUsageOld                   string               `json:"key_usage,omitempty"`
+	KeyUsage                      []string             `json:"key_usage_list"`
+	ExtKeyUsage                   []string             `json:"extended_key_usage_list"`
+	OUOld                         string               `json:"ou,omitempty"`
+	OU                            []string             `json:"ou_list"`
+	OrganizationOld               string               `json:"organization,omitempty"`
+	Organization                  []string             `json:"organization_list"`
+	Country                       []string             `json:"country"`
+	Locality                      []string             `json:"locality"`
+	Province                      []string             `json:"province"`
+	StreetAddress                 []string             `json:"street_address"`
+	PostalCode                    []string             `json:"postal_code"`
+	GenerateLease                 *bool                `json:"generate_lease,omitempty"`
+	NoStore                       bool                 `json:"no_store"`
+	RequireCN                     bool                 `json:"require_cn"`
+	CNValidations                 []string             `json:"cn_validations"`
+	AllowedOtherSANs              []string             `json:"allowed_other_sans"`
+	AllowedSerialNumbers          []string             `json:"allowed_serial_numbers"`
+	AllowedUserIDs                []string             `json:"allowed_user_ids"`
+	AllowedURISANs                []string             `json:"allowed_uri_sans"`
+	AllowedURISANsTemplate        bool                 `json:"allowed_uri_sans_template"`
+	PolicyIdentifiers             []string             `json:"policy_identifiers"`
+	ExtKeyUsageOIDs               []string             `json:"ext_key_usage_oids"`
+	BasicConstraintsValidForNonCA bool                 `json:"basic_constraints_valid_for_non_ca"`
+	NotBeforeDuration             time.Duration        `json:"not_before_duration"`
+	NotAfter                      string               `json:"not_after"`
+	Issuer                        string               `json:"issuer"`
+	CTLogs                        []string             `json:"ct_logs"`
+	CTMinSCTs                     int                  `json:"ct_min_scts"`
+	IssuancePolicy                []IssuancePolicyRule `json:"issuance_policy"`
 	// Name is only set when the role has been stored, on the fly roles have a blank name
 	Name string `json:"-"`
 	// WasModified indicates to callers if the returned entry is different than the persisted version
@@ -139,6 +142,9 @@ func (r *RoleEntry) ToResponseData() map[string]interface{} {
 		"not_before_duration":                int64(r.NotBeforeDuration.Seconds()),
 		"not_after":                          r.NotAfter,
 		"issuer_ref":                         r.Issuer,
+		"ct_logs":                            r.CTLogs,
+		"ct_min_scts":                        r.CTMinSCTs,
+		"issuance_policy":                    r.IssuancePolicy,
 	}
 	if r.MaxPathLength != nil {
 		responseData["max_path_length"] = r.MaxPathLength
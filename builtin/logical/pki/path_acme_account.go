@@ -270,6 +270,10 @@ func (b *backend) acmeNewAccountCreateHandler(acmeCtx *acmeContext, userCtx *jws
 		if err != nil {
 			return nil, err
 		}
+
+		if err = eab.EnforceExpiry(time.Now()); err != nil {
+			return nil, err
+		}
 	}
 
 	// Verify against our EAB policy
@@ -448,6 +452,12 @@ func (b *backend) tidyAcmeAccountByThumbprint(as *acmeState, sc *storageContext,
 				return err
 			}
 
+			// Along with any usage tracked against its EAB quotas:
+			err = sc.Storage.Delete(sc.Context, getEabUsagePath(thumbprint.Kid))
+			if err != nil {
+				return err
+			}
+
 			// Now we delete the Thumbprint Associated with the Account:
 			err = sc.Storage.Delete(sc.Context, path.Join(acmeThumbprintPrefix, keyThumbprint))
 			if err != nil {
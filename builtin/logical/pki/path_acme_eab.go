@@ -82,6 +82,7 @@ func pathAcmeNewEab(b *backend, baseUrl string) *framework.Path {
 func patternAcmeNewEab(b *backend, pattern string) *framework.Path {
 	fields := map[string]*framework.FieldSchema{}
 	addFieldsForACMEPath(fields, pattern)
+	addFieldsForEabRestrictions(fields)
 
 	opSuffix := getAcmeOperationSuffix(pattern)
 
@@ -129,6 +130,36 @@ func patternAcmeNewEab(b *backend, pattern string) *framework.Path {
 								Description: `An RFC3339 formatted date time when the EAB token was created`,
 								Required:    true,
 							},
+							"allowed_identifiers": {
+								Type:        framework.TypeStringSlice,
+								Description: `Globs which identifiers of orders placed by the bound account must match`,
+								Required:    false,
+							},
+							"bound_role": {
+								Type:        framework.TypeString,
+								Description: `The only role orders placed by the bound account may use`,
+								Required:    false,
+							},
+							"expires_on": {
+								Type:        framework.TypeTime,
+								Description: `An RFC3339 formatted date time after which the EAB token and its bound account may no longer be used`,
+								Required:    false,
+							},
+							"max_orders": {
+								Type:        framework.TypeInt,
+								Description: `The maximum number of orders the bound account may place per quota period`,
+								Required:    false,
+							},
+							"max_certificates": {
+								Type:        framework.TypeInt,
+								Description: `The maximum number of certificates the bound account may be issued per quota period`,
+								Required:    false,
+							},
+							"quota_period": {
+								Type:        framework.TypeDurationSecond,
+								Description: `The period over which max_orders and max_certificates are counted`,
+								Required:    false,
+							},
 						},
 					}},
 				},
@@ -178,6 +209,103 @@ type eabType struct {
 	PrivateBytes  []byte    `json:"private-bytes"`
 	AcmeDirectory string    `json:"acme-directory"`
 	CreatedOn     time.Time `json:"created-on"`
+
+	eabRestrictions
+}
+
+func addFieldsForEabRestrictions(fields map[string]*framework.FieldSchema) {
+	fields["allowed_identifiers"] = &framework.FieldSchema{
+		Type: framework.TypeCommaStringSlice,
+		Description: `Glob patterns which every identifier of an order placed
+by the account bound to this EAB must match. When empty, any identifier the
+directory's role allows may be requested.`,
+	}
+	fields["bound_role"] = &framework.FieldSchema{
+		Type: framework.TypeString,
+		Description: `Role which all orders placed by the account bound to this
+EAB must use. When empty, any role the directory allows may be used.`,
+	}
+	fields["ttl"] = &framework.FieldSchema{
+		Type: framework.TypeDurationSecond,
+		Description: `Lifetime of the EAB; once it has passed, neither the EAB
+nor the account bound to it may be used. Defaults to no expiry.`,
+	}
+	fields["max_orders"] = &framework.FieldSchema{
+		Type: framework.TypeInt,
+		Description: `Maximum number of orders the account bound to this EAB
+may place per quota_period. Defaults to 0, meaning unlimited.`,
+	}
+	fields["max_certificates"] = &framework.FieldSchema{
+		Type: framework.TypeInt,
+		Description: `Maximum number of certificates the account bound to this
+EAB may be issued per quota_period. Defaults to 0, meaning unlimited.`,
+	}
+	fields["quota_period"] = &framework.FieldSchema{
+		Type: framework.TypeDurationSecond,
+		Description: `Sliding window over which max_orders and max_certificates
+are counted. Defaults to 0, meaning the limits apply over the lifetime of
+the account.`,
+	}
+}
+
+func getEabRestrictions(ctx context.Context, b *backend, r *logical.Request, data *framework.FieldData, now time.Time) (eabRestrictions, error) {
+	var restrictions eabRestrictions
+
+	for _, pattern := range data.Get("allowed_identifiers").([]string) {
+		pattern = strings.TrimSpace(pattern)
+		if len(pattern) == 0 {
+			continue
+		}
+		restrictions.AllowedIdentifiers = append(restrictions.AllowedIdentifiers, strings.ToLower(pattern))
+	}
+
+	if roleName := data.Get("bound_role").(string); len(roleName) > 0 {
+		role, err := b.GetRole(ctx, r.Storage, roleName)
+		if err != nil {
+			return restrictions, fmt.Errorf("failed loading role %s: %w", roleName, err)
+		}
+		if role == nil {
+			return restrictions, fmt.Errorf("bound_role %s does not exist", roleName)
+		}
+		restrictions.Role = role.Name
+	}
+
+	if ttl := data.Get("ttl").(int); ttl < 0 {
+		return restrictions, fmt.Errorf("ttl must not be negative")
+	} else if ttl > 0 {
+		restrictions.ExpiresOn = now.Add(time.Duration(ttl) * time.Second)
+	}
+
+	restrictions.MaxOrders = data.Get("max_orders").(int)
+	restrictions.MaxCertificates = data.Get("max_certificates").(int)
+	quotaPeriod := data.Get("quota_period").(int)
+	if restrictions.MaxOrders < 0 || restrictions.MaxCertificates < 0 || quotaPeriod < 0 {
+		return restrictions, fmt.Errorf("max_orders, max_certificates and quota_period must not be negative")
+	}
+	restrictions.QuotaPeriod = time.Duration(quotaPeriod) * time.Second
+
+	return restrictions, nil
+}
+
+func (er eabRestrictions) addToResponseData(data map[string]interface{}) {
+	if len(er.AllowedIdentifiers) > 0 {
+		data["allowed_identifiers"] = er.AllowedIdentifiers
+	}
+	if len(er.Role) > 0 {
+		data["bound_role"] = er.Role
+	}
+	if !er.ExpiresOn.IsZero() {
+		data["expires_on"] = er.ExpiresOn.Format(time.RFC3339)
+	}
+	if er.MaxOrders > 0 {
+		data["max_orders"] = er.MaxOrders
+	}
+	if er.MaxCertificates > 0 {
+		data["max_certificates"] = er.MaxCertificates
+	}
+	if er.QuotaPeriod > 0 {
+		data["quota_period"] = int64(er.QuotaPeriod.Seconds())
+	}
 }
 
 func (b *backend) pathAcmeListEab(ctx context.Context, r *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
@@ -201,11 +329,13 @@ func (b *backend) pathAcmeListEab(ctx context.Context, r *logical.Request, _ *fr
 		}
 
 		keyIds = append(keyIds, eab.KeyID)
-		keyInfos[eab.KeyID] = map[string]interface{}{
+		keyInfo := map[string]interface{}{
 			"key_type":       eab.KeyType,
 			"acme_directory": path.Join(eab.AcmeDirectory, "directory"),
 			"created_on":     eab.CreatedOn.Format(time.RFC3339),
 		}
+		eab.addToResponseData(keyInfo)
+		keyInfos[eab.KeyID] = keyInfo
 	}
 
 	resp := logical.ListResponseWithInfo(keyIds, keyInfos)
@@ -228,12 +358,19 @@ func (b *backend) pathAcmeCreateEab(ctx context.Context, r *logical.Request, dat
 		return nil, err
 	}
 
+	now := time.Now()
+	restrictions, err := getEabRestrictions(ctx, b, r, data, now)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
 	eab := &eabType{
-		KeyID:         kid,
-		KeyType:       "hs",
-		PrivateBytes:  append(decodedTokenPrefix, bytes...), // we do this to avoid generating tokens that start with -
-		AcmeDirectory: acmeDirectory,
-		CreatedOn:     time.Now(),
+		KeyID:           kid,
+		KeyType:         "hs",
+		PrivateBytes:    append(decodedTokenPrefix, bytes...), // we do this to avoid generating tokens that start with -
+		AcmeDirectory:   acmeDirectory,
+		CreatedOn:       now,
+		eabRestrictions: restrictions,
 	}
 
 	sc := b.makeStorageContext(ctx, r.Storage)
@@ -244,14 +381,17 @@ func (b *backend) pathAcmeCreateEab(ctx context.Context, r *logical.Request, dat
 
 	encodedKey := base64.RawURLEncoding.EncodeToString(eab.PrivateBytes)
 
+	respData := map[string]interface{}{
+		"id":             eab.KeyID,
+		"key_type":       eab.KeyType,
+		"key":            encodedKey,
+		"acme_directory": path.Join(eab.AcmeDirectory, "directory"),
+		"created_on":     eab.CreatedOn.Format(time.RFC3339),
+	}
+	eab.addToResponseData(respData)
+
 	return &logical.Response{
-		Data: map[string]interface{}{
-			"id":             eab.KeyID,
-			"key_type":       eab.KeyType,
-			"key":            encodedKey,
-			"acme_directory": path.Join(eab.AcmeDirectory, "directory"),
-			"created_on":     eab.CreatedOn.Format(time.RFC3339),
-		},
+		Data: respData,
 	}, nil
 }
 
@@ -7,6 +7,7 @@ import (
 	"crypto/x509"
 	"encoding/base64"
 	"encoding/pem"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
@@ -258,6 +259,32 @@ func (b *backend) acmeFinalizeOrderHandler(ac *acmeContext, r *logical.Request,
 		return nil, err
 	}
 
+	if err = b.enforceEabRestrictions(ac, account, order.Identifiers, true); err != nil {
+		return nil, err
+	}
+
+	issued := false
+	if order.Replaces != "" {
+		// Another order replacing the same certificate may have been
+		// finalized since this one was created; claim the certificate
+		// before issuing so that only one of them can be.
+		replacedCert, err := b.loadReplacedCert(ac, account, order.Replaces, order.Identifiers)
+		if err != nil {
+			return nil, err
+		}
+		if err := b.GetAcmeState().ClaimIssuedCertReplacement(ac, replacedCert.Account, replacedCert.Serial, order.OrderId); err != nil {
+			return nil, err
+		}
+		defer func() {
+			if issued {
+				return
+			}
+			if err := b.GetAcmeState().ReleaseIssuedCertReplacement(ac, replacedCert.Account, replacedCert.Serial, order.OrderId); err != nil {
+				b.Logger().Warn("failed releasing claim on replaced ACME certificate", "serial_number", replacedCert.Serial, "error", err)
+			}
+		}()
+	}
+
 	var signedCertBundle *certutil.ParsedCertBundle
 	var issuerId issuing.IssuerID
 	if ac.runtimeOpts.isCiepsEnabled {
@@ -273,7 +300,7 @@ func (b *backend) acmeFinalizeOrderHandler(ac *acmeContext, r *logical.Request,
 			return nil, err
 		}
 
-		err = issuing.StoreCertificate(ac.sc.Context, ac.sc.Storage, ac.sc.Backend.GetCertificateCounter(), signedCertBundle)
+		err = ac.sc.storeCertificate(signedCertBundle, issuerId, ac.role.Name)
 		if err != nil {
 			return nil, err
 		}
@@ -284,6 +311,11 @@ func (b *backend) acmeFinalizeOrderHandler(ac *acmeContext, r *logical.Request,
 		b.Logger().Warn("orphaned generated ACME certificate due to error saving account->cert->order reference", "serial_number", hyphenSerialNumber, "error", err)
 		return nil, err
 	}
+	issued = true
+
+	if err := b.GetAcmeState().RecordEabCertificate(ac.sc, account); err != nil {
+		b.Logger().Warn("failed recording ACME certificate against its external account binding quota", "serial_number", hyphenSerialNumber, "error", err)
+	}
 
 	order.Status = ACMEOrderValid
 	order.CertificateSerialNumber = hyphenSerialNumber
@@ -301,6 +333,11 @@ func (b *backend) acmeFinalizeOrderHandler(ac *acmeContext, r *logical.Request,
 		err = nil
 	}
 
+	b.issueEvent(ac.sc.Context, signedCertBundle.Certificate, issuerId, ac.role.Name, true)
+	b.acmeOrderEvent(ac, "finalized", order,
+		"serial_number", serialFromCert(signedCertBundle.Certificate),
+		"issuer_id", issuerId.String())
+
 	return formatOrderResponse(ac, order), nil
 }
 
@@ -439,6 +476,83 @@ func (b *backend) validateIdentifiersAgainstRole(role *issuing.RoleEntry, identi
 	return nil
 }
 
+// loadReplacedCert returns the account's record of the certificate an order
+// replaces, given its ARI certificate identifier, provided the certificate
+// was issued to the account and has not been replaced yet.
+func (b *backend) loadReplacedCert(ac *acmeContext, account *acmeAccount, certID string, identifiers []*ACMEIdentifier) (*acmeCertEntry, error) {
+	cert, err := fetchCertForAcmeCertID(ac.sc, certID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Per RFC 9773 Section 5, the replacement must share an identifier with
+	// the certificate it replaces.
+	if !certSharesIdentifier(cert, identifiers) {
+		return nil, fmt.Errorf("%w: certificate %s to replace shares no identifier with the order", ErrMalformed, certID)
+	}
+
+	replacedCert, err := b.GetAcmeState().GetIssuedCert(ac, account.KeyId, normalizeSerialFromBigInt(cert.SerialNumber))
+	if err != nil {
+		if errors.Is(err, ErrStorageItemNotFound) {
+			return nil, fmt.Errorf("%w: certificate %s to replace was not issued to this account", ErrMalformed, certID)
+		}
+		return nil, err
+	}
+
+	if replacedCert.ReplacedBy != "" {
+		return nil, fmt.Errorf("%w: certificate %s was already replaced by order %s", ErrAlreadyReplaced, certID, replacedCert.ReplacedBy)
+	}
+
+	return replacedCert, nil
+}
+
+// certSharesIdentifier returns whether any of the order's identifiers is
+// one of the certificate's DNS or IP subject alternative names.
+func certSharesIdentifier(cert *x509.Certificate, identifiers []*ACMEIdentifier) bool {
+	for _, identifier := range identifiers {
+		switch identifier.Type {
+		case ACMEDNSIdentifier:
+			for _, name := range cert.DNSNames {
+				if strings.EqualFold(name, identifier.OriginalValue) {
+					return true
+				}
+			}
+		case ACMEIPIdentifier:
+			ip := net.ParseIP(identifier.Value)
+			for _, certIP := range cert.IPAddresses {
+				if certIP.Equal(ip) {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+// enforceEabRestrictions applies the restrictions of the EAB an account was
+// created with to an order. A new order consumes one unit of the order
+// quota; when certificate is true, the certificate quota is only checked,
+// as it is consumed once the certificate has been issued.
+func (b *backend) enforceEabRestrictions(ac *acmeContext, account *acmeAccount, identifiers []*ACMEIdentifier, certificate bool) error {
+	if account.Eab == nil {
+		return nil
+	}
+
+	if err := account.Eab.EnforceExpiry(time.Now()); err != nil {
+		return err
+	}
+
+	if err := account.Eab.EnforceForOrder(ac.role, identifiers); err != nil {
+		return err
+	}
+
+	if certificate {
+		return b.GetAcmeState().CheckEabCertificateQuota(ac.sc, account)
+	}
+	return b.GetAcmeState().ConsumeEabOrderQuota(ac.sc, account)
+}
+
 func getIdentifiersFromCSR(csr *x509.CertificateRequest) ([]string, []net.IP) {
 	dnsIdentifiers := append([]string(nil), csr.DNSNames...)
 	ipIdentifiers := append([]net.IP(nil), csr.IPAddresses...)
@@ -573,6 +687,10 @@ func issueCertFromCsr(ac *acmeContext, csr *x509.CertificateRequest) (*certutil.
 		return nil, "", fmt.Errorf("%w: refusing to sign CSR: %s", ErrBadCSR, err.Error())
 	}
 
+	if _, err = issuing.AddSignedCertificateTimestamps(ac.sc.Context, ac.sc.Storage, ac.role, signingBundle, parsedBundle); err != nil {
+		return nil, "", fmt.Errorf("failed to embed SCTs: %w", err)
+	}
+
 	if err = parsedBundle.Verify(); err != nil {
 		return nil, "", fmt.Errorf("verification of parsed bundle failed: %w", err)
 	}
@@ -737,6 +855,26 @@ func (b *backend) acmeNewOrderHandler(ac *acmeContext, _ *logical.Request, _ *fr
 		return nil, err
 	}
 
+	err = b.enforceEabRestrictions(ac, account, identifiers, false)
+	if err != nil {
+		return nil, err
+	}
+
+	// Per RFC 9773 Section 5. Extensions to the Order Object, an order may
+	// identify the certificate it replaces.
+	var replaces string
+	if rawReplaces, present := data["replaces"]; present {
+		var ok bool
+		replaces, ok = rawReplaces.(string)
+		if !ok {
+			return nil, fmt.Errorf("%w: invalid type (%T; expected string) for field 'replaces'", ErrMalformed, rawReplaces)
+		}
+
+		if _, err = b.loadReplacedCert(ac, account, replaces, identifiers); err != nil {
+			return nil, err
+		}
+	}
+
 	// Per RFC 8555 -> 7.1.3. Order Objects
 	// For pending orders, the authorizations that the client needs to complete before the
 	// requested certificate can be issued (see Section 7.5), including
@@ -770,12 +908,14 @@ func (b *backend) acmeNewOrderHandler(ac *acmeContext, _ *logical.Request, _ *fr
 		Expires:          time.Now().Add(24 * time.Hour), // TODO: Readjust this based on authz and/or config
 		Identifiers:      identifiers,
 		AuthorizationIds: authorizationIds,
+		Replaces:         replaces,
 	}
 
 	err = b.GetAcmeState().SaveOrder(ac, order)
 	if err != nil {
 		return nil, fmt.Errorf("failed storing order: %w", err)
 	}
+	b.acmeOrderEvent(ac, "new", order)
 
 	resp := formatOrderResponse(ac, order)
 
@@ -787,6 +927,24 @@ func (b *backend) acmeNewOrderHandler(ac *acmeContext, _ *logical.Request, _ *fr
 	return resp, nil
 }
 
+// acmeOrderEvent sends a pki/acme-order-<state> event for the given order.
+func (b *backend) acmeOrderEvent(ac *acmeContext, state string, order *acmeOrder, metadataPairs ...string) {
+	var identifiers []string
+	for _, identifier := range order.Identifiers {
+		identifiers = append(identifiers, identifier.OriginalValue)
+	}
+
+	metadata := []string{
+		logical.EventMetadataModified, "true",
+		"order_id", order.OrderId,
+		"account_id", order.AccountId,
+		"role", ac.role.Name,
+		"identifiers", strings.Join(identifiers, ","),
+	}
+	metadata = append(metadata, metadataPairs...)
+	b.pkiEvent(ac.sc.Context, "acme-order-"+state, metadata...)
+}
+
 func validateAcmeProvidedOrderDates(notBefore time.Time, notAfter time.Time) error {
 	if !notBefore.IsZero() && !notAfter.IsZero() {
 		if notBefore.Equal(notAfter) {
@@ -838,6 +996,10 @@ func formatOrderResponse(acmeCtx *acmeContext, order *acmeOrder) *logical.Respon
 		resp.Data["certificate"] = baseOrderUrl + "/cert"
 	}
 
+	if order.Replaces != "" {
+		resp.Data["replaces"] = order.Replaces
+	}
+
 	return resp
 }
 
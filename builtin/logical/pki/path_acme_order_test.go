@@ -4,7 +4,11 @@
 package pki
 
 import (
+	"context"
+	"encoding/base64"
+	"fmt"
 	"net"
+	"sync"
 	"testing"
 
 	"github.com/hashicorp/vault/builtin/logical/pki/issuing"
@@ -141,3 +145,93 @@ func buildTestRole(t *testing.T, config map[string]interface{}) *issuing.RoleEnt
 
 	return role
 }
+
+// TestAcmeReplacedCertClaims validates the checks on the certificate an
+// order replaces, and that only one order can claim to replace it.
+func TestAcmeReplacedCertClaims(t *testing.T) {
+	t.Parallel()
+
+	b, s := CreateBackendWithStorage(t)
+	ac := &acmeContext{sc: b.makeStorageContext(context.Background(), s)}
+	a := b.GetAcmeState()
+
+	resp, err := CBWrite(b, s, "root/generate/internal", map[string]interface{}{
+		"common_name": "Root X1",
+		"key_type":    "ec",
+		"ttl":         "168h",
+	})
+	requireSuccessNonNilResponse(t, resp, err)
+	_, err = CBWrite(b, s, "roles/test", map[string]interface{}{
+		"allow_any_name": true,
+		"key_type":       "ec",
+	})
+	require.NoError(t, err)
+	resp, err = CBWrite(b, s, "issue/test", map[string]interface{}{
+		"common_name": "www.example.com",
+		"alt_names":   "api.example.com",
+		"ip_sans":     "192.0.2.1",
+		"ttl":         "1h",
+	})
+	requireSuccessNonNilResponse(t, resp, err)
+	cert := parseCert(t, resp.Data["certificate"].(string))
+	serial := normalizeSerialFromBigInt(cert.SerialNumber)
+	certID := acmeCertID(cert)
+	require.NoError(t, a.TrackIssuedCert(ac, "account", serial, "order-0"))
+	account := &acmeAccount{KeyId: "account"}
+
+	dns := func(value string) []*ACMEIdentifier {
+		return []*ACMEIdentifier{{Type: ACMEDNSIdentifier, Value: value, OriginalValue: value}}
+	}
+
+	// The order must share an identifier with the certificate it replaces.
+	_, err = b.loadReplacedCert(ac, account, certID, dns("API.example.com"))
+	require.NoError(t, err)
+	_, err = b.loadReplacedCert(ac, account, certID, []*ACMEIdentifier{{Type: ACMEIPIdentifier, Value: "192.0.2.1", OriginalValue: "192.0.2.1"}})
+	require.NoError(t, err)
+	_, err = b.loadReplacedCert(ac, account, certID, dns("other.example.com"))
+	require.ErrorIs(t, err, ErrMalformed)
+	require.ErrorContains(t, err, "shares no identifier")
+
+	// Unknown certificates, and those of other accounts, are malformed
+	// requests rather than internal errors.
+	_, err = b.loadReplacedCert(ac, account, base64.RawURLEncoding.EncodeToString(cert.AuthorityKeyId)+".AQ", dns("www.example.com"))
+	require.ErrorIs(t, err, ErrMalformed)
+	require.ErrorContains(t, err, "no certificate with serial number")
+	_, err = b.loadReplacedCert(ac, &acmeAccount{KeyId: "other"}, certID, dns("www.example.com"))
+	require.ErrorIs(t, err, ErrMalformed)
+	require.ErrorContains(t, err, "was not issued to this account")
+
+	// A claim is idempotent for its order, excludes other orders, and can
+	// only be released by the order holding it.
+	require.NoError(t, a.ClaimIssuedCertReplacement(ac, "account", serial, "order-1"))
+	require.NoError(t, a.ClaimIssuedCertReplacement(ac, "account", serial, "order-1"))
+	require.ErrorIs(t, a.ClaimIssuedCertReplacement(ac, "account", serial, "order-2"), ErrAlreadyReplaced)
+	_, err = b.loadReplacedCert(ac, account, certID, dns("www.example.com"))
+	require.ErrorIs(t, err, ErrAlreadyReplaced)
+	require.NoError(t, a.ReleaseIssuedCertReplacement(ac, "account", serial, "order-2"))
+	require.ErrorIs(t, a.ClaimIssuedCertReplacement(ac, "account", serial, "order-2"), ErrAlreadyReplaced)
+	require.NoError(t, a.ReleaseIssuedCertReplacement(ac, "account", serial, "order-1"))
+	_, err = b.loadReplacedCert(ac, account, certID, dns("www.example.com"))
+	require.NoError(t, err)
+
+	// Of concurrent claims, exactly one succeeds.
+	var wg sync.WaitGroup
+	errs := make([]error, 16)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = a.ClaimIssuedCertReplacement(ac, "account", serial, fmt.Sprintf("order-%d", i+10))
+		}(i)
+	}
+	wg.Wait()
+	succeeded := 0
+	for _, err := range errs {
+		if err == nil {
+			succeeded++
+			continue
+		}
+		require.ErrorIs(t, err, ErrAlreadyReplaced)
+	}
+	require.Equal(t, 1, succeeded)
+}
@@ -0,0 +1,338 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package pki
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/vault/builtin/logical/pki/issuing"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const defaultAcmeEarlyRenewalWindow = 24 * time.Hour
+
+func pathAcmeRenewalInfo(b *backend, baseUrl string, opts acmeWrapperOpts) *framework.Path {
+	return patternAcmeRenewalInfo(b, baseUrl+"/renewal-info/(?P<cert_id>[A-Za-z0-9_=-]+\\.[A-Za-z0-9_=-]+)", opts)
+}
+
+func patternAcmeRenewalInfo(b *backend, pattern string, opts acmeWrapperOpts) *framework.Path {
+	fields := map[string]*framework.FieldSchema{}
+	addFieldsForACMEPath(fields, pattern)
+	fields["cert_id"] = &framework.FieldSchema{
+		Type:        framework.TypeString,
+		Description: `The ARI certificate identifier of the certificate`,
+		Required:    true,
+	}
+
+	return &framework.Path{
+		Pattern: pattern,
+		Fields:  fields,
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ReadOperation: &framework.PathOperation{
+				Callback:                    b.acmeWrapper(opts, b.acmeRenewalInfoHandler),
+				ForwardPerformanceSecondary: false,
+				ForwardPerformanceStandby:   true,
+			},
+		},
+
+		HelpSynopsis:    pathAcmeHelpSync,
+		HelpDescription: pathAcmeHelpDesc,
+	}
+}
+
+func (b *backend) acmeRenewalInfoHandler(acmeCtx *acmeContext, _ *logical.Request, fields *framework.FieldData) (*logical.Response, error) {
+	cert, err := fetchCertForAcmeCertID(acmeCtx.sc, fields.Get("cert_id").(string))
+	if err != nil {
+		return nil, err
+	}
+
+	window, err := getAcmeRenewalWindow(acmeCtx.sc, cert)
+	if err != nil {
+		return nil, fmt.Errorf("failed computing renewal window: %w", err)
+	}
+
+	body := map[string]interface{}{
+		"suggestedWindow": map[string]interface{}{
+			"start": window.Start.UTC().Format(time.RFC3339),
+			"end":   window.End.UTC().Format(time.RFC3339),
+		},
+	}
+	if window.ExplanationURL != "" {
+		body["explanationURL"] = window.ExplanationURL
+	}
+
+	rawBody, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed encoding response: %w", err)
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			logical.HTTPContentType: "application/json",
+			logical.HTTPStatusCode:  http.StatusOK,
+			logical.HTTPRawBody:     rawBody,
+		},
+		Headers: map[string][]string{
+			"Retry-After": {strconv.Itoa(int(acmeRenewalInfoRetryAfter.Seconds()))},
+		},
+	}, nil
+}
+
+func pathAcmeEarlyRenewalIssuer(b *backend) *framework.Path {
+	return buildPathAcmeEarlyRenewal(b,
+		"acme/early-renewal/issuer/"+framework.GenericNameRegex(issuerRefParam),
+		issuerRefParam, `Reference to an existing issuer name or issuer id.`,
+		"issuer_id", `Issuer Id`,
+		"issuer", b.resolveAcmeEarlyRenewalIssuer)
+}
+
+func pathAcmeEarlyRenewalCert(b *backend) *framework.Path {
+	return buildPathAcmeEarlyRenewal(b,
+		`acme/early-renewal/cert/(?P<serial>[0-9A-Fa-f-:]+)`,
+		"serial", `Certificate serial number, in colon- or hyphen-separated hexadecimal`,
+		"serial_number", `Certificate serial number`,
+		"cert", b.resolveAcmeEarlyRenewalCert)
+}
+
+// acmeEarlyRenewalResolver resolves the reference of an early renewal
+// request to its storage path and the value identifying its target in
+// responses, returning an error response when the target does not exist.
+type acmeEarlyRenewalResolver func(sc *storageContext, ref string) (string, string, *logical.Response, error)
+
+func buildPathAcmeEarlyRenewal(b *backend, pattern string, refField string, refDescription string, respField string, respDescription string, opSuffix string, resolve acmeEarlyRenewalResolver) *framework.Path {
+	responseFields := map[string]*framework.FieldSchema{
+		respField: {
+			Type:        framework.TypeString,
+			Description: respDescription,
+			Required:    true,
+		},
+		"window_start": {
+			Type:        framework.TypeTime,
+			Description: `Start of the renewal window suggested to ACME clients`,
+			Required:    true,
+		},
+		"window_end": {
+			Type:        framework.TypeTime,
+			Description: `End of the renewal window suggested to ACME clients`,
+			Required:    true,
+		},
+		"explanation_url": {
+			Type:        framework.TypeString,
+			Description: `URL of a page explaining the early renewal`,
+			Required:    false,
+		},
+		"created_on": {
+			Type:        framework.TypeTime,
+			Description: `When the early renewal was requested`,
+			Required:    true,
+		},
+	}
+
+	return &framework.Path{
+		Pattern: pattern,
+
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixPKI,
+		},
+
+		Fields: map[string]*framework.FieldSchema{
+			refField: {
+				Type:        framework.TypeString,
+				Description: refDescription,
+				Required:    true,
+			},
+			"window_start": {
+				Type: framework.TypeTime,
+				Description: `Start of the renewal window suggested to ACME
+clients; defaults to now.`,
+			},
+			"window_end": {
+				Type: framework.TypeTime,
+				Description: `End of the renewal window suggested to ACME
+clients; defaults to 24 hours after window_start.`,
+			},
+			"explanation_url": {
+				Type: framework.TypeString,
+				Description: `URL of a page explaining the early renewal,
+returned to ACME clients along with the window.`,
+			},
+		},
+
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ReadOperation: &framework.PathOperation{
+				Callback: b.pathAcmeEarlyRenewalRead(refField, respField, resolve),
+				DisplayAttrs: &framework.DisplayAttributes{
+					OperationVerb:   "read",
+					OperationSuffix: "acme-early-renewal-for-" + opSuffix,
+				},
+				Responses: map[int][]framework.Response{
+					http.StatusOK: {{
+						Description: "OK",
+						Fields:      responseFields,
+					}},
+				},
+			},
+			logical.UpdateOperation: &framework.PathOperation{
+				Callback: b.pathAcmeEarlyRenewalWrite(refField, respField, resolve),
+				DisplayAttrs: &framework.DisplayAttributes{
+					OperationVerb:   "request",
+					OperationSuffix: "acme-early-renewal-for-" + opSuffix,
+				},
+				Responses: map[int][]framework.Response{
+					http.StatusOK: {{
+						Description: "OK",
+						Fields:      responseFields,
+					}},
+				},
+				// Read more about why these flags are set in backend.go.
+				ForwardPerformanceStandby:   true,
+				ForwardPerformanceSecondary: true,
+			},
+			logical.DeleteOperation: &framework.PathOperation{
+				Callback: b.pathAcmeEarlyRenewalDelete(refField, resolve),
+				DisplayAttrs: &framework.DisplayAttributes{
+					OperationVerb:   "delete",
+					OperationSuffix: "acme-early-renewal-for-" + opSuffix,
+				},
+				Responses: map[int][]framework.Response{
+					http.StatusNoContent: {{
+						Description: "No Content",
+					}},
+				},
+				// Read more about why these flags are set in backend.go.
+				ForwardPerformanceStandby:   true,
+				ForwardPerformanceSecondary: true,
+			},
+		},
+
+		HelpSynopsis:    pathAcmeEarlyRenewalHelpSyn,
+		HelpDescription: pathAcmeEarlyRenewalHelpDesc,
+	}
+}
+
+func (b *backend) resolveAcmeEarlyRenewalIssuer(sc *storageContext, ref string) (string, string, *logical.Response, error) {
+	if b.UseLegacyBundleCaStorage() {
+		return "", "", logical.ErrorResponse("Can not manage early renewals until migration has completed"), nil
+	}
+
+	issuerId, err := sc.resolveIssuerReference(ref)
+	if err != nil {
+		if issuerId == issuing.IssuerRefNotFound {
+			return "", "", logical.ErrorResponse("unable to find issuer %q", ref), nil
+		}
+		return "", "", nil, err
+	}
+
+	return acmeEarlyRenewalIssuerPrefix + issuerId.String(), issuerId.String(), nil, nil
+}
+
+func (b *backend) resolveAcmeEarlyRenewalCert(sc *storageContext, ref string) (string, string, *logical.Response, error) {
+	entry, err := fetchCertBySerial(sc, "certs/", ref)
+	if err != nil {
+		return "", "", nil, err
+	}
+	if entry == nil {
+		return "", "", logical.ErrorResponse("unable to find certificate %q", ref), nil
+	}
+
+	return acmeEarlyRenewalCertPrefix + normalizeSerial(ref), denormalizeSerial(normalizeSerial(ref)), nil, nil
+}
+
+func (b *backend) pathAcmeEarlyRenewalRead(refField string, respField string, resolve acmeEarlyRenewalResolver) framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		sc := b.makeStorageContext(ctx, req.Storage)
+		path, ref, errResp, err := resolve(sc, data.Get(refField).(string))
+		if errResp != nil || err != nil {
+			return errResp, err
+		}
+
+		earlyRenewal, err := sc.fetchAcmeEarlyRenewal(path)
+		if err != nil {
+			return nil, err
+		}
+		if earlyRenewal == nil {
+			return nil, nil
+		}
+
+		return acmeEarlyRenewalResponse(respField, ref, earlyRenewal), nil
+	}
+}
+
+func (b *backend) pathAcmeEarlyRenewalWrite(refField string, respField string, resolve acmeEarlyRenewalResolver) framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		sc := b.makeStorageContext(ctx, req.Storage)
+		path, ref, errResp, err := resolve(sc, data.Get(refField).(string))
+		if errResp != nil || err != nil {
+			return errResp, err
+		}
+
+		now := time.Now()
+		earlyRenewal := &acmeEarlyRenewalEntry{
+			WindowStart:    now,
+			ExplanationURL: data.Get("explanation_url").(string),
+			CreatedOn:      now,
+		}
+		if start, ok := data.GetOk("window_start"); ok {
+			earlyRenewal.WindowStart = start.(time.Time)
+		}
+		earlyRenewal.WindowEnd = earlyRenewal.WindowStart.Add(defaultAcmeEarlyRenewalWindow)
+		if end, ok := data.GetOk("window_end"); ok {
+			earlyRenewal.WindowEnd = end.(time.Time)
+		}
+
+		if !earlyRenewal.WindowEnd.After(earlyRenewal.WindowStart) {
+			return logical.ErrorResponse("window_end must be after window_start"), nil
+		}
+
+		if err := sc.writeAcmeEarlyRenewal(path, earlyRenewal); err != nil {
+			return nil, err
+		}
+
+		return acmeEarlyRenewalResponse(respField, ref, earlyRenewal), nil
+	}
+}
+
+func (b *backend) pathAcmeEarlyRenewalDelete(refField string, resolve acmeEarlyRenewalResolver) framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		sc := b.makeStorageContext(ctx, req.Storage)
+		path, _, errResp, err := resolve(sc, data.Get(refField).(string))
+		if errResp != nil || err != nil {
+			return errResp, err
+		}
+
+		return nil, sc.Storage.Delete(ctx, path)
+	}
+}
+
+func acmeEarlyRenewalResponse(respField string, ref string, earlyRenewal *acmeEarlyRenewalEntry) *logical.Response {
+	return &logical.Response{
+		Data: map[string]interface{}{
+			respField:         ref,
+			"window_start":    earlyRenewal.WindowStart.Format(time.RFC3339),
+			"window_end":      earlyRenewal.WindowEnd.Format(time.RFC3339),
+			"explanation_url": earlyRenewal.ExplanationURL,
+			"created_on":      earlyRenewal.CreatedOn.Format(time.RFC3339),
+		},
+	}
+}
+
+const pathAcmeEarlyRenewalHelpSyn = `Request early renewal of ACME certificates.`
+
+const pathAcmeEarlyRenewalHelpDesc = `
+This endpoint overrides the renewal window which ACME clients supporting
+renewal information (RFC 9773) are told to renew certificates within, so
+that certificates can be replaced ahead of their usual renewal, for example
+after a key compromise.
+
+An early renewal requested for an issuer applies to all certificates issued
+by it whose validity began before the request was made; certificates issued
+afterwards, including the renewed ones, are unaffected. An early renewal
+requested for a certificate applies to that certificate alone.
+`
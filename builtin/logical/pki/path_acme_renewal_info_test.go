@@ -0,0 +1,288 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package pki
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v3"
+	"github.com/hashicorp/vault/api"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/acme"
+)
+
+func TestAcmeCertID(t *testing.T) {
+	t.Parallel()
+
+	for _, serial := range []int64{1, 0x7f, 0x80, 0xff00} {
+		cert := &x509.Certificate{
+			SerialNumber:   big.NewInt(serial),
+			AuthorityKeyId: []byte{0x69, 0x88, 0x5b, 0x6b, 0x87, 0x46, 0x40, 0x41},
+		}
+
+		certID := acmeCertID(cert)
+		aki, parsedSerial, err := parseAcmeCertID(certID)
+		require.NoError(t, err, "failed parsing %v", certID)
+		require.Equal(t, cert.AuthorityKeyId, aki)
+		require.Equal(t, 0, cert.SerialNumber.Cmp(parsedSerial), "serial mismatch for %v", certID)
+	}
+
+	// Example from RFC 9773 Section 4.1.
+	require.Equal(t, "aYhba4dGQEHhs3uEe6CuLN4ByNQ.AIdlQyE", acmeCertID(&x509.Certificate{
+		SerialNumber: big.NewInt(0x87654321),
+		AuthorityKeyId: []byte{
+			0x69, 0x88, 0x5b, 0x6b, 0x87, 0x46, 0x40, 0x41, 0xe1, 0xb3,
+			0x7b, 0x84, 0x7b, 0xa0, 0xae, 0x2c, 0xde, 0x01, 0xc8, 0xd4,
+		},
+	}))
+
+	for _, invalid := range []string{"", "abc", ".AQ", "aGVsbG8.", "aGVsbG8.gA", "a!b.AQ"} {
+		_, _, err := parseAcmeCertID(invalid)
+		require.ErrorIs(t, err, ErrMalformed, "expected %q to be rejected", invalid)
+	}
+}
+
+func TestSuggestAcmeRenewalWindow(t *testing.T) {
+	t.Parallel()
+
+	notBefore := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cert := &x509.Certificate{
+		NotBefore: notBefore,
+		NotAfter:  notBefore.Add(90 * 24 * time.Hour),
+	}
+
+	// Default: the first half of the last third of the lifetime.
+	window := suggestAcmeRenewalWindow(cert, time.Time{}, nil)
+	require.Equal(t, notBefore.Add(60*24*time.Hour), window.Start)
+	require.Equal(t, notBefore.Add(75*24*time.Hour), window.End)
+
+	// A handover before or after the window doesn't change it.
+	require.Equal(t, window, suggestAcmeRenewalWindow(cert, notBefore.Add(10*24*time.Hour), nil))
+	require.Equal(t, window, suggestAcmeRenewalWindow(cert, notBefore.Add(80*24*time.Hour), nil))
+
+	// A handover within the window delays its start.
+	handover := notBefore.Add(70 * 24 * time.Hour)
+	delayed := suggestAcmeRenewalWindow(cert, handover, nil)
+	require.Equal(t, handover, delayed.Start)
+	require.Equal(t, window.End, delayed.End)
+
+	// Early renewals take precedence.
+	earlyRenewal := &acmeEarlyRenewalEntry{
+		WindowStart:    notBefore.Add(24 * time.Hour),
+		WindowEnd:      notBefore.Add(48 * time.Hour),
+		ExplanationURL: "https://example.com/incident",
+	}
+	early := suggestAcmeRenewalWindow(cert, handover, earlyRenewal)
+	require.Equal(t, earlyRenewal.WindowStart, early.Start)
+	require.Equal(t, earlyRenewal.WindowEnd, early.End)
+	require.Equal(t, earlyRenewal.ExplanationURL, early.ExplanationURL)
+
+	// Rotation handover times follow the state of the rotation.
+	issuerCert := &x509.Certificate{NotAfter: notBefore.Add(365 * 24 * time.Hour)}
+	rotation := &issuerRotationEntry{
+		Enabled:       true,
+		LeadTime:      30 * 24 * time.Hour,
+		OverlapPeriod: 7 * 24 * time.Hour,
+		State:         issuerRotationWaiting,
+	}
+	require.Equal(t, issuerCert.NotAfter.Add(-23*24*time.Hour), issuerHandoverTime(rotation, issuerCert))
+	rotation.State = issuerRotationOverlap
+	rotation.RotationStarted = notBefore
+	require.Equal(t, notBefore.Add(7*24*time.Hour), issuerHandoverTime(rotation, issuerCert))
+	rotation.State = issuerRotationRotated
+	rotation.RotationCompleted = notBefore.Add(time.Hour)
+	require.Equal(t, rotation.RotationCompleted, issuerHandoverTime(rotation, issuerCert))
+	require.True(t, issuerHandoverTime(nil, issuerCert).IsZero())
+}
+
+// TestAcmeRenewalInfo validates the renewalInfo resource, early renewals
+// requested by operators, and orders replacing certificates.
+func TestAcmeRenewalInfo(t *testing.T) {
+	t.Parallel()
+	cluster, client, _ := setupAcmeBackend(t)
+	defer cluster.Cleanup()
+	testCtx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	err := client.Sys().TuneMountWithContext(testCtx, "pki", api.MountConfigInput{
+		AllowedResponseHeaders: []string{"Last-Modified", "Replay-Nonce", "Link", "Location", "Retry-After"},
+	})
+	require.NoError(t, err, "failed tuning mount response headers")
+
+	baseAcmeURL := "/v1/pki/acme/"
+	accountKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err, "failed creating ec key")
+	acmeClient := getAcmeClientForCluster(t, cluster, baseAcmeURL, accountKey)
+
+	acct, err := acmeClient.Register(testCtx, &acme.Account{}, func(tosURL string) bool { return true })
+	require.NoError(t, err, "failed registering account")
+
+	_, certs := doACMEWorkflow(t, client, acmeClient)
+	cert, err := x509.ParseCertificate(certs[0])
+	require.NoError(t, err, "failed parsing acme cert")
+	certID := acmeCertID(cert)
+
+	// The directory advertises the renewalInfo resource.
+	directoryURL := client.Address() + baseAcmeURL + "directory"
+	resp, body := acmeHTTPGet(t, acmeClient, directoryURL)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var directory map[string]interface{}
+	require.NoError(t, json.Unmarshal(body, &directory))
+	renewalInfoURL := client.Address() + baseAcmeURL + "renewal-info"
+	require.Equal(t, renewalInfoURL, directory["renewalInfo"])
+
+	type renewalInfo struct {
+		SuggestedWindow struct {
+			Start time.Time `json:"start"`
+			End   time.Time `json:"end"`
+		} `json:"suggestedWindow"`
+		ExplanationURL string `json:"explanationURL"`
+	}
+	getRenewalInfo := func(t *testing.T) renewalInfo {
+		resp, body := acmeHTTPGet(t, acmeClient, renewalInfoURL+"/"+certID)
+		require.Equal(t, http.StatusOK, resp.StatusCode, "renewal info: %s", body)
+		require.Equal(t, strconv.Itoa(int(acmeRenewalInfoRetryAfter.Seconds())), resp.Header.Get("Retry-After"))
+
+		var info renewalInfo
+		require.NoError(t, json.Unmarshal(body, &info))
+		return info
+	}
+
+	// By default, renew in the last third of the certificate's lifetime.
+	info := getRenewalInfo(t)
+	lifetime := cert.NotAfter.Sub(cert.NotBefore)
+	require.WithinDuration(t, cert.NotBefore.Add(lifetime*2/3), info.SuggestedWindow.Start, time.Second)
+	require.WithinDuration(t, cert.NotBefore.Add(lifetime*5/6), info.SuggestedWindow.End, time.Second)
+	require.Empty(t, info.ExplanationURL)
+
+	// Unknown certificates are rejected.
+	resp, _ = acmeHTTPGet(t, acmeClient, renewalInfoURL+"/"+base64.RawURLEncoding.EncodeToString(cert.AuthorityKeyId)+".AQ")
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	// An early renewal of the certificate's issuer replaces the window.
+	issuerResp, err := client.Logical().ReadWithContext(testCtx, "pki/issuer/default/json")
+	require.NoError(t, err, "failed reading default issuer")
+	issuerCert := parseCert(t, issuerResp.Data["certificate"].(string))
+	require.NoError(t, cert.CheckSignatureFrom(issuerCert), "expected the default issuer to have issued the acme cert")
+	issuerId := issuerResp.Data["issuer_id"].(string)
+	windowStart := time.Now().Truncate(time.Second).UTC()
+	_, err = client.Logical().WriteWithContext(testCtx, "pki/acme/early-renewal/issuer/"+issuerId, map[string]interface{}{
+		"window_start":    windowStart.Format(time.RFC3339),
+		"window_end":      windowStart.Add(time.Hour).Format(time.RFC3339),
+		"explanation_url": "https://example.com/incident",
+	})
+	require.NoError(t, err, "failed requesting early renewal")
+
+	info = getRenewalInfo(t)
+	require.Equal(t, windowStart, info.SuggestedWindow.Start.UTC())
+	require.Equal(t, windowStart.Add(time.Hour), info.SuggestedWindow.End.UTC())
+	require.Equal(t, "https://example.com/incident", info.ExplanationURL)
+
+	_, err = client.Logical().DeleteWithContext(testCtx, "pki/acme/early-renewal/issuer/"+issuerId)
+	require.NoError(t, err, "failed deleting early renewal")
+	info = getRenewalInfo(t)
+	require.Empty(t, info.ExplanationURL)
+
+	// Orders may replace certificates issued to the account, once.
+	newOrderURL := client.Address() + baseAcmeURL + "new-order"
+	newOrder := map[string]interface{}{
+		"identifiers": []map[string]interface{}{{"type": "dns", "value": "*.localdomain"}},
+		"replaces":    certID,
+	}
+	resp, body = acmePostJWS(t, acmeClient, accountKey, acct.URI, newOrderURL, newOrder)
+	require.Equal(t, http.StatusCreated, resp.StatusCode, "new order: %s", body)
+	var orderBody map[string]interface{}
+	require.NoError(t, json.Unmarshal(body, &orderBody))
+	require.Equal(t, certID, orderBody["replaces"])
+
+	order, err := acmeClient.GetOrder(testCtx, resp.Header.Get("Location"))
+	require.NoError(t, err, "failed fetching order")
+	markAuthorizationSuccess(t, client, acmeClient, acct, order)
+
+	csrKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err, "failed generated key for CSR")
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{DNSNames: []string{"*.localdomain"}}, csrKey)
+	require.NoError(t, err, "failed generating csr")
+	_, _, err = acmeClient.CreateOrderCert(testCtx, order.FinalizeURL, csr, true)
+	require.NoError(t, err, "failed finalizing replacing order")
+
+	resp, body = acmePostJWS(t, acmeClient, accountKey, acct.URI, newOrderURL, newOrder)
+	require.Equal(t, http.StatusConflict, resp.StatusCode, "new order: %s", body)
+	require.Contains(t, string(body), "urn:ietf:params:acme:error:alreadyReplaced")
+
+	// Other accounts can't replace the certificate.
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err, "failed creating ec key")
+	otherClient := getAcmeClientForCluster(t, cluster, baseAcmeURL, otherKey)
+	otherAcct, err := otherClient.Register(testCtx, &acme.Account{}, func(tosURL string) bool { return true })
+	require.NoError(t, err, "failed registering account")
+	_, otherCerts := doACMEWorkflow(t, client, acmeClient)
+	otherCert, err := x509.ParseCertificate(otherCerts[0])
+	require.NoError(t, err, "failed parsing acme cert")
+	newOrder["replaces"] = acmeCertID(otherCert)
+	resp, body = acmePostJWS(t, otherClient, otherKey, otherAcct.URI, newOrderURL, newOrder)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode, "new order: %s", body)
+	require.Contains(t, string(body), "urn:ietf:params:acme:error:malformed")
+}
+
+func acmeHTTPGet(t *testing.T, acmeClient *acme.Client, url string) (*http.Response, []byte) {
+	t.Helper()
+
+	resp, err := acmeClient.HTTPClient.Get(url)
+	require.NoError(t, err, "failed fetching %v", url)
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err, "failed reading response of %v", url)
+	return resp, body
+}
+
+// acmePostJWS posts a JWS-signed request to an ACME server, for requests
+// the ACME client library doesn't support.
+func acmePostJWS(t *testing.T, acmeClient *acme.Client, key *ecdsa.PrivateKey, kid string, url string, payload interface{}) (*http.Response, []byte) {
+	t.Helper()
+
+	dir, err := acmeClient.Discover(context.Background())
+	require.NoError(t, err, "failed acme discovery call")
+	nonceResp, err := acmeClient.HTTPClient.Head(dir.NonceURL)
+	require.NoError(t, err, "failed fetching nonce")
+	nonceResp.Body.Close()
+
+	signer, err := jose.NewSigner(jose.SigningKey{
+		Algorithm: jose.ES256,
+		Key:       jose.JSONWebKey{Key: key, KeyID: kid},
+	}, &jose.SignerOptions{
+		ExtraHeaders: map[jose.HeaderKey]interface{}{
+			"nonce": nonceResp.Header.Get("Replay-Nonce"),
+			"url":   url,
+		},
+	})
+	require.NoError(t, err, "failed creating jws signer")
+
+	rawPayload, err := json.Marshal(payload)
+	require.NoError(t, err, "failed encoding payload")
+	jws, err := signer.Sign(rawPayload)
+	require.NoError(t, err, "failed signing payload")
+
+	resp, err := acmeClient.HTTPClient.Post(url, "application/jose+json", bytes.NewBufferString(jws.FullSerialize()))
+	require.NoError(t, err, "failed posting to %v", url)
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err, "failed reading response of %v", url)
+	return resp, body
+}
@@ -649,6 +649,85 @@ func TestAcmeEabCrossingDirectoryPath(t *testing.T) {
 	require.ErrorContains(t, err, "failed to verify eab", "should have failed as EAB is for a different directory")
 }
 
+// TestAcmeEabRestrictions verifies that the identifier, role and quota
+// restrictions carried by an EAB are enforced on the orders of its account.
+func TestAcmeEabRestrictions(t *testing.T) {
+	t.Parallel()
+	cluster, client, _ := setupAcmeBackend(t)
+	defer cluster.Cleanup()
+	testCtx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	_, err := client.Logical().WriteWithContext(testCtx, "pki/config/acme", map[string]interface{}{
+		"enabled":    true,
+		"eab_policy": "always-required",
+	})
+	require.NoError(t, err)
+
+	// Restrictions must reference existing roles.
+	_, err = client.Logical().WriteWithContext(testCtx, "pki/roles/test-role/acme/new-eab", map[string]interface{}{
+		"bound_role": "does-not-exist",
+	})
+	require.ErrorContains(t, err, "does not exist")
+
+	registerWithEab := func(t *testing.T, eabData map[string]interface{}) *acme.Client {
+		resp, err := client.Logical().WriteWithContext(testCtx, "pki/roles/test-role/acme/new-eab", eabData)
+		require.NoError(t, err, "failed getting eab key")
+		kid := resp.Data["id"].(string)
+		eabKeyBytes, err := base64.RawURLEncoding.DecodeString(resp.Data["key"].(string))
+		require.NoError(t, err, "failed decoding eab key")
+
+		accountKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err, "failed creating ec key")
+
+		acmeClient := getAcmeClientForCluster(t, cluster, "/v1/pki/roles/test-role/acme/", accountKey)
+		_, err = acmeClient.Register(testCtx, &acme.Account{
+			ExternalAccountBinding: &acme.ExternalAccountBinding{KID: kid, Key: eabKeyBytes},
+		}, func(tosURL string) bool { return true })
+		require.NoError(t, err, "failed registering new account with eab")
+		return acmeClient
+	}
+
+	t.Run("identifiers-and-quota", func(t *testing.T) {
+		resp, err := client.Logical().WriteWithContext(testCtx, "pki/roles/test-role/acme/new-eab", map[string]interface{}{
+			"allowed_identifiers": "*.team-a.localdomain",
+			"bound_role":          "test-role",
+			"max_orders":          1,
+			"quota_period":        "1h",
+		})
+		require.NoError(t, err)
+		require.Equal(t, []interface{}{"*.team-a.localdomain"}, resp.Data["allowed_identifiers"])
+		require.Equal(t, "test-role", resp.Data["bound_role"])
+		require.EqualValues(t, json.Number("1"), resp.Data["max_orders"])
+		require.EqualValues(t, json.Number("3600"), resp.Data["quota_period"])
+
+		acmeClient := registerWithEab(t, map[string]interface{}{
+			"allowed_identifiers": "*.team-a.localdomain",
+			"max_orders":          1,
+		})
+
+		_, err = acmeClient.AuthorizeOrder(testCtx, acme.DomainIDs("www.team-b.localdomain"))
+		require.ErrorContains(t, err, "urn:ietf:params:acme:error:rejectedIdentifier")
+
+		_, err = acmeClient.AuthorizeOrder(testCtx, acme.DomainIDs("www.team-a.localdomain"))
+		require.NoError(t, err, "order for an allowed identifier should succeed")
+
+		// Don't let the client back off and retry on the expected 429.
+		acmeClient.RetryBackoff = func(n int, r *http.Request, res *http.Response) time.Duration { return -1 }
+		_, err = acmeClient.AuthorizeOrder(testCtx, acme.DomainIDs("api.team-a.localdomain"))
+		require.ErrorContains(t, err, "urn:ietf:params:acme:error:rateLimited")
+	})
+
+	t.Run("bound-role", func(t *testing.T) {
+		acmeClient := registerWithEab(t, map[string]interface{}{
+			"bound_role": "acme",
+		})
+
+		_, err := acmeClient.AuthorizeOrder(testCtx, acme.DomainIDs("www.localdomain"))
+		require.ErrorContains(t, err, "urn:ietf:params:acme:error:unauthorized")
+	})
+}
+
 // TestAcmeDisabledWithEnvVar verifies if VAULT_DISABLE_PUBLIC_ACME is set that we completely
 // disable the ACME service
 func TestAcmeDisabledWithEnvVar(t *testing.T) {
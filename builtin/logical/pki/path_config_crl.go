@@ -31,6 +31,8 @@ type crlConfig struct {
 	UseGlobalQueue            bool   `json:"cross_cluster_revocation"`
 	UnifiedCRL                bool   `json:"unified_crl"`
 	UnifiedCRLOnExistingPaths bool   `json:"unified_crl_on_existing_paths"`
+	OcspPregenerate           bool   `json:"ocsp_pregenerate"`
+	OcspPregeneratedExpiry    string `json:"ocsp_pregenerated_expiry"`
 }
 
 // Implicit default values for the config if it does not exist.
@@ -47,6 +49,8 @@ var defaultCrlConfig = crlConfig{
 	UseGlobalQueue:            false,
 	UnifiedCRL:                false,
 	UnifiedCRLOnExistingPaths: false,
+	OcspPregenerate:           false,
+	OcspPregeneratedExpiry:    "72h",
 }
 
 func pathConfigCRL(b *backend) *framework.Path {
@@ -114,6 +118,18 @@ disable for CRLs and ocsp_disable for OCSP.`,
 existing CRL and OCSP paths will return the unified CRL instead of a response based on cluster-local data`,
 				Default: "false",
 			},
+			"ocsp_pregenerate": {
+				Type: framework.TypeBool,
+				Description: `If set to true, signed OCSP responses for all
+unexpired certificates are generated in the background after each complete
+CRL rebuild, refreshed periodically, and served from storage.`,
+			},
+			"ocsp_pregenerated_expiry": {
+				Type: framework.TypeString,
+				Description: `The amount of time a pre-generated OCSP
+response will be valid (controls the NextUpdate field); defaults to 72 hours`,
+				Default: "72h",
+			},
 		},
 
 		Operations: map[logical.Operation]framework.OperationHandler{
@@ -187,6 +203,19 @@ disable for CRLs and ocsp_disable for OCSP.`,
 existing CRL and OCSP paths will return the unified CRL instead of a response based on cluster-local data`,
 								Required: true,
 							},
+							"ocsp_pregenerate": {
+								Type: framework.TypeBool,
+								Description: `If set to true, signed OCSP responses for all
+unexpired certificates are generated in the background after each complete
+CRL rebuild, refreshed periodically, and served from storage.`,
+								Required: true,
+							},
+							"ocsp_pregenerated_expiry": {
+								Type: framework.TypeString,
+								Description: `The amount of time a pre-generated OCSP
+response will be valid (controls the NextUpdate field); defaults to 72 hours`,
+								Required: true,
+							},
 						},
 					}},
 				},
@@ -258,6 +287,19 @@ disable for CRLs and ocsp_disable for OCSP.`,
 existing CRL and OCSP paths will return the unified CRL instead of a response based on cluster-local data`,
 								Required: false,
 							},
+							"ocsp_pregenerate": {
+								Type: framework.TypeBool,
+								Description: `If set to true, signed OCSP responses for all
+unexpired certificates are generated in the background after each complete
+CRL rebuild, refreshed periodically, and served from storage.`,
+								Required: false,
+							},
+							"ocsp_pregenerated_expiry": {
+								Type: framework.TypeString,
+								Description: `The amount of time a pre-generated OCSP
+response will be valid (controls the NextUpdate field); defaults to 72 hours`,
+								Required: false,
+							},
 						},
 					}},
 				},
@@ -320,6 +362,23 @@ func (b *backend) pathCRLWrite(ctx context.Context, req *logical.Request, d *fra
 		config.OcspExpiry = expiry
 	}
 
+	oldOcspPregenerate := config.OcspPregenerate
+	if ocspPregenerateRaw, ok := d.GetOk("ocsp_pregenerate"); ok {
+		config.OcspPregenerate = ocspPregenerateRaw.(bool)
+	}
+
+	if expiryRaw, ok := d.GetOk("ocsp_pregenerated_expiry"); ok {
+		expiry := expiryRaw.(string)
+		duration, err := parseutil.ParseDurationSecond(expiry)
+		if err != nil {
+			return logical.ErrorResponse(fmt.Sprintf("given ocsp_pregenerated_expiry could not be decoded: %s", err)), nil
+		}
+		if duration <= 0 {
+			return logical.ErrorResponse(fmt.Sprintf("ocsp_pregenerated_expiry must be greater than 0 got: %s", duration)), nil
+		}
+		config.OcspPregeneratedExpiry = expiry
+	}
+
 	oldAutoRebuild := config.AutoRebuild
 	if autoRebuildRaw, ok := d.GetOk("auto_rebuild"); ok {
 		config.AutoRebuild = autoRebuildRaw.(bool)
@@ -419,11 +478,12 @@ func (b *backend) pathCRLWrite(ctx context.Context, req *logical.Request, d *fra
 	// Note this only affects/happens on the main cluster node, if you need to
 	// notify something based on a configuration change on all server types
 	// have a look at CrlBuilder::reloadConfigIfRequired
-	if oldDisable != config.Disable || (oldAutoRebuild && !config.AutoRebuild) || (oldEnableDelta != config.EnableDelta) || (oldUnifiedCRL != config.UnifiedCRL) {
+	if oldDisable != config.Disable || (oldAutoRebuild && !config.AutoRebuild) || (oldEnableDelta != config.EnableDelta) || (oldUnifiedCRL != config.UnifiedCRL) || (!oldOcspPregenerate && config.OcspPregenerate) {
 		// It wasn't disabled but now it is (or equivalently, we were set to
 		// auto-rebuild and we aren't now or equivalently, we changed our
 		// mind about delta CRLs and need a new complete one or equivalently,
-		// we changed our mind about unified CRLs), rotate the CRLs.
+		// we changed our mind about unified CRLs or equivalently, we need
+		// to pre-generate OCSP responses), rotate the CRLs.
 		warnings, crlErr := b.CrlBuilder().rebuild(sc, true)
 		if crlErr != nil {
 			switch crlErr.(type) {
@@ -455,6 +515,8 @@ func genResponseFromCrlConfig(config *crlConfig) *logical.Response {
 			"cross_cluster_revocation":      config.UseGlobalQueue,
 			"unified_crl":                   config.UnifiedCRL,
 			"unified_crl_on_existing_paths": config.UnifiedCRLOnExistingPaths,
+			"ocsp_pregenerate":              config.OcspPregenerate,
+			"ocsp_pregenerated_expiry":      config.OcspPregeneratedExpiry,
 		},
 	}
 }
@@ -0,0 +1,186 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package pki
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/errutil"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	storageEstConfig      = "config/est"
+	pathConfigEstHelpSyn  = "Configuration of EST Endpoints"
+	pathConfigEstHelpDesc = "Here we configure:\n\nenabled=false, whether EST is enabled, defaults to false meaning that clusters will by default not get EST support,\ndefault_role=\"\", the role used to issue certificates requested under /.well-known/est,\nlabel_to_role={}, the role used to issue certificates requested under /.well-known/est/<label>."
+)
+
+var estLabelRegex = regexp.MustCompile(`^` + framework.GenericNameRegex("label") + `$`)
+
+type estConfigEntry struct {
+	Enabled     bool              `json:"enabled"`
+	DefaultRole string            `json:"default_role"`
+	LabelToRole map[string]string `json:"label_to_role"`
+}
+
+func (sc *storageContext) getEstConfig() (*estConfigEntry, error) {
+	entry, err := sc.Storage.Get(sc.Context, storageEstConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &estConfigEntry{LabelToRole: map[string]string{}}
+	if entry == nil {
+		return config, nil
+	}
+
+	if err := entry.DecodeJSON(config); err != nil {
+		return nil, errutil.InternalError{Err: fmt.Sprintf("unable to decode EST configuration: %v", err)}
+	}
+	if config.LabelToRole == nil {
+		config.LabelToRole = map[string]string{}
+	}
+
+	return config, nil
+}
+
+func (sc *storageContext) setEstConfig(config *estConfigEntry) error {
+	json, err := logical.StorageEntryJSON(storageEstConfig, config)
+	if err != nil {
+		return fmt.Errorf("failed creating storage entry: %w", err)
+	}
+
+	if err := sc.Storage.Put(sc.Context, json); err != nil {
+		return fmt.Errorf("failed writing storage entry: %w", err)
+	}
+
+	return nil
+}
+
+func pathConfigEst(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "config/est",
+
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixPKI,
+		},
+
+		Fields: map[string]*framework.FieldSchema{
+			"enabled": {
+				Type:        framework.TypeBool,
+				Description: `whether EST is enabled, defaults to false meaning that clusters will by default not get EST support`,
+				Default:     false,
+			},
+			"default_role": {
+				Type:        framework.TypeString,
+				Description: `the role used to issue certificates requested under /.well-known/est without a label; if empty, only labelled requests are served`,
+			},
+			"label_to_role": {
+				Type:        framework.TypeKVPairs,
+				Description: `a map of EST labels to the roles used to issue certificates requested under /.well-known/est/<label>`,
+			},
+		},
+
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ReadOperation: &framework.PathOperation{
+				DisplayAttrs: &framework.DisplayAttributes{
+					OperationSuffix: "est-configuration",
+				},
+				Callback: b.pathEstConfigRead,
+			},
+			logical.UpdateOperation: &framework.PathOperation{
+				Callback: b.pathEstConfigWrite,
+				DisplayAttrs: &framework.DisplayAttributes{
+					OperationVerb:   "configure",
+					OperationSuffix: "est",
+				},
+				// Read more about why these flags are set in backend.go.
+				ForwardPerformanceStandby:   true,
+				ForwardPerformanceSecondary: true,
+			},
+		},
+
+		HelpSynopsis:    pathConfigEstHelpSyn,
+		HelpDescription: pathConfigEstHelpDesc,
+	}
+}
+
+func (b *backend) pathEstConfigRead(ctx context.Context, req *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
+	sc := b.makeStorageContext(ctx, req.Storage)
+	config, err := sc.getEstConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	return genResponseFromEstConfig(config), nil
+}
+
+func genResponseFromEstConfig(config *estConfigEntry) *logical.Response {
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"enabled":       config.Enabled,
+			"default_role":  config.DefaultRole,
+			"label_to_role": config.LabelToRole,
+		},
+	}
+}
+
+func (b *backend) pathEstConfigWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	sc := b.makeStorageContext(ctx, req.Storage)
+
+	config, err := sc.getEstConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	if enabledRaw, ok := d.GetOk("enabled"); ok {
+		config.Enabled = enabledRaw.(bool)
+	}
+
+	if defaultRoleRaw, ok := d.GetOk("default_role"); ok {
+		config.DefaultRole = defaultRoleRaw.(string)
+	}
+
+	if labelToRoleRaw, ok := d.GetOk("label_to_role"); ok {
+		config.LabelToRole = labelToRoleRaw.(map[string]string)
+	}
+
+	roles := map[string]string{"": config.DefaultRole}
+	for label, role := range config.LabelToRole {
+		if !estLabelRegex.MatchString(label) {
+			return logical.ErrorResponse("invalid EST label %q", label), nil
+		}
+		if _, reserved := estOperations[label]; reserved {
+			return logical.ErrorResponse("EST label %q conflicts with an EST operation", label), nil
+		}
+		if role == "" {
+			return logical.ErrorResponse("EST label %q must map to a role", label), nil
+		}
+		roles[label] = role
+	}
+	for label, name := range roles {
+		if name == "" {
+			continue
+		}
+		role, err := b.GetRole(ctx, req.Storage, name)
+		if err != nil {
+			return nil, err
+		}
+		if role == nil {
+			if label == "" {
+				return logical.ErrorResponse("default_role %q does not exist", name), nil
+			}
+			return logical.ErrorResponse("role %q for EST label %q does not exist", name, label), nil
+		}
+	}
+
+	if err := sc.setEstConfig(config); err != nil {
+		return nil, fmt.Errorf("failed persisting: %w", err)
+	}
+
+	return genResponseFromEstConfig(config), nil
+}
@@ -0,0 +1,180 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package pki
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/errutil"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	storageEventsConfig        = "config/events"
+	defaultExpiryWarningPeriod = 30 * 24 * time.Hour
+	defaultExpiryScanInterval  = 24 * time.Hour
+	pathConfigEventsHelpSyn    = "Configuration of PKI events"
+	pathConfigEventsHelpDesc   = "Here we configure:\n\nexpiry_warning_period=720h, how long before an issuer or stored certificate expires to start sending pki/issuer-expiring and pki/cert-expiring events, zero disables them,\nexpiry_scan_interval=24h, how often to scan for expiring issuers and certificates, sending one event for each the first time it is found."
+)
+
+type eventsConfigEntry struct {
+	ExpiryWarningPeriod time.Duration `json:"expiry_warning_period"`
+	ExpiryScanInterval  time.Duration `json:"expiry_scan_interval"`
+}
+
+func (sc *storageContext) getEventsConfig() (*eventsConfigEntry, error) {
+	entry, err := sc.Storage.Get(sc.Context, storageEventsConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &eventsConfigEntry{
+		ExpiryWarningPeriod: defaultExpiryWarningPeriod,
+		ExpiryScanInterval:  defaultExpiryScanInterval,
+	}
+	if entry == nil {
+		return config, nil
+	}
+
+	if err := entry.DecodeJSON(config); err != nil {
+		return nil, errutil.InternalError{Err: fmt.Sprintf("unable to decode events configuration: %v", err)}
+	}
+
+	return config, nil
+}
+
+func (sc *storageContext) setEventsConfig(config *eventsConfigEntry) error {
+	json, err := logical.StorageEntryJSON(storageEventsConfig, config)
+	if err != nil {
+		return fmt.Errorf("failed creating storage entry: %w", err)
+	}
+
+	if err := sc.Storage.Put(sc.Context, json); err != nil {
+		return fmt.Errorf("failed writing storage entry: %w", err)
+	}
+
+	return nil
+}
+
+var eventsConfigResponseFields = map[string]*framework.FieldSchema{
+	"expiry_warning_period": {
+		Type:        framework.TypeDurationSecond,
+		Description: `how long before an issuer or stored certificate expires to start sending expiry warning events`,
+		Required:    true,
+	},
+	"expiry_scan_interval": {
+		Type:        framework.TypeDurationSecond,
+		Description: `how often to scan for expiring issuers and certificates`,
+		Required:    true,
+	},
+}
+
+func pathConfigEvents(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "config/events",
+
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixPKI,
+		},
+
+		Fields: map[string]*framework.FieldSchema{
+			"expiry_warning_period": {
+				Type:        framework.TypeDurationSecond,
+				Description: `how long before an issuer or stored certificate expires to start sending expiry warning events, defaults to 720h; zero disables them`,
+				Default:     int(defaultExpiryWarningPeriod / time.Second),
+			},
+			"expiry_scan_interval": {
+				Type:        framework.TypeDurationSecond,
+				Description: `how often to scan for expiring issuers and certificates, defaults to 24h`,
+				Default:     int(defaultExpiryScanInterval / time.Second),
+			},
+		},
+
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ReadOperation: &framework.PathOperation{
+				DisplayAttrs: &framework.DisplayAttributes{
+					OperationSuffix: "events-configuration",
+				},
+				Callback: b.pathEventsConfigRead,
+				Responses: map[int][]framework.Response{
+					http.StatusOK: {{
+						Description: "OK",
+						Fields:      eventsConfigResponseFields,
+					}},
+				},
+			},
+			logical.UpdateOperation: &framework.PathOperation{
+				Callback: b.pathEventsConfigWrite,
+				Responses: map[int][]framework.Response{
+					http.StatusOK: {{
+						Description: "OK",
+						Fields:      eventsConfigResponseFields,
+					}},
+				},
+				DisplayAttrs: &framework.DisplayAttributes{
+					OperationVerb:   "configure",
+					OperationSuffix: "events",
+				},
+				// Read more about why these flags are set in backend.go.
+				ForwardPerformanceStandby:   true,
+				ForwardPerformanceSecondary: true,
+			},
+		},
+
+		HelpSynopsis:    pathConfigEventsHelpSyn,
+		HelpDescription: pathConfigEventsHelpDesc,
+	}
+}
+
+func (b *backend) pathEventsConfigRead(ctx context.Context, req *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
+	sc := b.makeStorageContext(ctx, req.Storage)
+	config, err := sc.getEventsConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	return genResponseFromEventsConfig(config), nil
+}
+
+func genResponseFromEventsConfig(config *eventsConfigEntry) *logical.Response {
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"expiry_warning_period": int64(config.ExpiryWarningPeriod.Seconds()),
+			"expiry_scan_interval":  int64(config.ExpiryScanInterval.Seconds()),
+		},
+	}
+}
+
+func (b *backend) pathEventsConfigWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	sc := b.makeStorageContext(ctx, req.Storage)
+
+	config, err := sc.getEventsConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	if periodRaw, ok := d.GetOk("expiry_warning_period"); ok {
+		config.ExpiryWarningPeriod = time.Duration(periodRaw.(int)) * time.Second
+		if config.ExpiryWarningPeriod < 0 {
+			return logical.ErrorResponse("expiry_warning_period must not be negative"), nil
+		}
+	}
+
+	if intervalRaw, ok := d.GetOk("expiry_scan_interval"); ok {
+		config.ExpiryScanInterval = time.Duration(intervalRaw.(int)) * time.Second
+		if config.ExpiryScanInterval <= 0 {
+			return logical.ErrorResponse("expiry_scan_interval must be positive"), nil
+		}
+	}
+
+	if err := sc.setEventsConfig(config); err != nil {
+		return nil, fmt.Errorf("failed persisting: %w", err)
+	}
+
+	return genResponseFromEventsConfig(config), nil
+}
@@ -0,0 +1,163 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package pki
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/vault/builtin/logical/pki/issuing"
+	"github.com/hashicorp/vault/sdk/helper/testhelpers/schema"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+)
+
+func createBackendWithEvents(t *testing.T) (*backend, logical.Storage, *logical.MockEventSender) {
+	t.Helper()
+
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+	events := logical.NewMockEventSender()
+	config.EventsSender = events
+
+	b := Backend(config)
+	err := b.Setup(context.Background(), config)
+	require.NoError(t, err)
+	b.pkiStorageVersion.Store(1)
+
+	return b, config.StorageView, events
+}
+
+// takeEvents returns the metadata of the events of the given type sent so
+// far, and clears all events sent.
+func takeEvents(events *logical.MockEventSender, eventType string) []map[string]interface{} {
+	return takeAllEvents(events)[eventType]
+}
+
+// takeAllEvents returns the metadata of the events sent so far by type, and
+// clears all events sent.
+func takeAllEvents(events *logical.MockEventSender) map[string][]map[string]interface{} {
+	events.Lock()
+	defer events.Unlock()
+
+	found := make(map[string][]map[string]interface{})
+	for _, event := range events.Events {
+		found[string(event.Type)] = append(found[string(event.Type)], event.Event.Metadata.AsMap())
+	}
+	events.Events = nil
+	return found
+}
+
+func TestPki_Events(t *testing.T) {
+	t.Parallel()
+
+	b, s, events := createBackendWithEvents(t)
+
+	resp, err := CBWrite(b, s, "root/generate/internal", map[string]interface{}{
+		"common_name": "Root X1",
+		"key_type":    "ec",
+		"issuer_name": "root",
+		"ttl":         "36h",
+	})
+	requireSuccessNonNilResponse(t, resp, err, "failed generating root")
+	rootId := resp.Data["issuer_id"].(issuing.IssuerID).String()
+
+	_, err = CBWrite(b, s, "roles/example", map[string]interface{}{
+		"allow_any_name": true,
+		"key_type":       "ec",
+	})
+	require.NoError(t, err)
+	takeAllEvents(events)
+
+	issue := func(cn string) string {
+		resp, err := CBWrite(b, s, "issue/example", map[string]interface{}{
+			"common_name": cn,
+			"ttl":         "24h",
+		})
+		requireSuccessNonNilResponse(t, resp, err, "failed issuing "+cn)
+		return resp.Data["serial_number"].(string)
+	}
+	kept := issue("kept.example.com")
+	revoked := issue("revoked.example.com")
+
+	issued := takeEvents(events, "pki/issue")
+	require.Len(t, issued, 2)
+	require.Equal(t, kept, issued[0]["serial_number"])
+	require.Equal(t, rootId, issued[0]["issuer_id"])
+	require.Equal(t, "example", issued[0]["role"])
+	require.Equal(t, "kept.example.com", issued[0]["common_name"])
+	require.Equal(t, "cert/"+kept, issued[0][logical.EventMetadataDataPath])
+
+	_, err = CBWrite(b, s, "revoke", map[string]interface{}{"serial_number": revoked})
+	require.NoError(t, err)
+	revokeEvents := takeEvents(events, "pki/revoke")
+	require.Len(t, revokeEvents, 1)
+	require.Equal(t, revoked, revokeEvents[0]["serial_number"])
+	require.Equal(t, rootId, revokeEvents[0]["issuer_id"])
+	require.Equal(t, "example", revokeEvents[0]["role"])
+
+	_, err = CBRead(b, s, "crl/rotate")
+	require.NoError(t, err)
+	rebuilds := takeEvents(events, "pki/crl-rebuild")
+	require.Len(t, rebuilds, 1)
+	require.Equal(t, "false", rebuilds[0]["delta"])
+
+	// The root and both leaves expire within the default warning period of
+	// 30 days; the revoked leaf is not reported.
+	resp, err = CBRead(b, s, "config/events")
+	requireSuccessNonNilResponse(t, resp, err)
+	schema.ValidateResponse(t, schema.GetResponseSchema(t, b.Route("config/events"), logical.ReadOperation), resp, true)
+	require.Equal(t, int64(30*24*60*60), resp.Data["expiry_warning_period"])
+	require.Equal(t, int64(24*60*60), resp.Data["expiry_scan_interval"])
+
+	sc := b.makeStorageContext(context.Background(), s)
+	require.NoError(t, runExpiryScan(sc))
+
+	expiring := takeAllEvents(events)
+	issuerExpiring := expiring["pki/issuer-expiring"]
+	require.Len(t, issuerExpiring, 1)
+	require.Equal(t, rootId, issuerExpiring[0]["issuer_id"])
+	require.Equal(t, "root", issuerExpiring[0]["issuer_name"])
+	certExpiring := expiring["pki/cert-expiring"]
+	require.Len(t, certExpiring, 1)
+	require.Equal(t, kept, certExpiring[0]["serial_number"])
+	require.Equal(t, "example", certExpiring[0]["role"])
+
+	// Scans are rate limited to the configured interval.
+	require.NoError(t, runExpiryScan(sc))
+	require.Empty(t, takeAllEvents(events))
+
+	// Later scans don't warn again about what was already reported, only
+	// about certificates found expiring since.
+	later := issue("later.example.com")
+	takeAllEvents(events)
+	b.lastExpiryScan = b.lastExpiryScan.AddDate(0, 0, -2)
+	require.NoError(t, runExpiryScan(sc))
+	expiring = takeAllEvents(events)
+	require.Empty(t, expiring["pki/issuer-expiring"])
+	require.Len(t, expiring["pki/cert-expiring"], 1)
+	require.Equal(t, later, expiring["pki/cert-expiring"][0]["serial_number"])
+
+	b.lastExpiryScan = b.lastExpiryScan.AddDate(0, 0, -2)
+	require.NoError(t, runExpiryScan(sc))
+	require.Empty(t, takeAllEvents(events))
+
+	// A warning period shorter than any remaining lifetime sends nothing.
+	resp, err = CBWrite(b, s, "config/events", map[string]interface{}{
+		"expiry_warning_period": "1h",
+		"expiry_scan_interval":  "1s",
+	})
+	requireSuccessNonNilResponse(t, resp, err)
+	schema.ValidateResponse(t, schema.GetResponseSchema(t, b.Route("config/events"), logical.UpdateOperation), resp, true)
+	require.Equal(t, int64(3600), resp.Data["expiry_warning_period"])
+
+	b.lastExpiryScan = b.lastExpiryScan.AddDate(0, 0, -2)
+	require.NoError(t, runExpiryScan(sc))
+	require.Empty(t, takeAllEvents(events))
+
+	_, err = CBWrite(b, s, "config/events", map[string]interface{}{
+		"expiry_scan_interval": "0",
+	})
+	require.Error(t, err)
+}
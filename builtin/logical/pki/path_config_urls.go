@@ -48,7 +48,8 @@ above AIA fields. When templating is enabled the special values '{{issuer_id}}',
 are not checked for URI validity until issuance time. Using '{{cluster_path}}'
 requires /config/cluster's 'path' member to be set on all PR Secondary clusters
 and using '{{cluster_aia_path}}' requires /config/cluster's 'aia_path' member
-to be set on all PR secondary clusters.`,
+to be set on all PR secondary clusters. '{{crl_partition}}' is available in
+crl_distribution_points for issuers with crl_partitions set.`,
 				Default: false,
 			},
 		},
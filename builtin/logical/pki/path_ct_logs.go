@@ -0,0 +1,202 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package pki
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/hashicorp/vault/builtin/logical/pki/issuing"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func pathListCTLogs(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "ct-logs/?$",
+
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixPKI,
+			OperationSuffix: "ct-logs",
+		},
+
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ListOperation: &framework.PathOperation{
+				Callback: b.pathCTLogList,
+			},
+		},
+
+		HelpSynopsis:    pathListCTLogsHelpSyn,
+		HelpDescription: pathListCTLogsHelpDesc,
+	}
+}
+
+func pathCTLogs(b *backend) *framework.Path {
+	ctLogResponseFields := map[string]*framework.FieldSchema{
+		"url": {
+			Type:        framework.TypeString,
+			Description: `Base URL of the log, to which /ct/v1/add-pre-chain is appended.`,
+			Required:    true,
+		},
+		"public_key": {
+			Type:        framework.TypeString,
+			Description: `PEM encoded public key of the log.`,
+			Required:    true,
+		},
+		"log_id": {
+			Type:        framework.TypeString,
+			Description: `Base64 encoded log ID, the SHA-256 hash of the log's public key.`,
+			Required:    true,
+		},
+	}
+
+	return &framework.Path{
+		Pattern: "ct-logs/" + framework.GenericNameRegex("name"),
+
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixPKI,
+			OperationSuffix: "ct-log",
+		},
+
+		Fields: map[string]*framework.FieldSchema{
+			"name": {
+				Type:        framework.TypeString,
+				Description: `Name of the CT log`,
+				Required:    true,
+			},
+			"url": {
+				Type: framework.TypeString,
+				Description: `Base URL of the RFC 6962 log, to which
+/ct/v1/add-pre-chain is appended when submitting precertificates.`,
+			},
+			"public_key": {
+				Type: framework.TypeString,
+				Description: `PEM encoded ECDSA or RSA public key of the log,
+used to verify the SCTs it returns.`,
+			},
+		},
+
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ReadOperation: &framework.PathOperation{
+				Callback: b.pathCTLogRead,
+				Responses: map[int][]framework.Response{
+					http.StatusOK: {{
+						Description: "OK",
+						Fields:      ctLogResponseFields,
+					}},
+				},
+			},
+			logical.UpdateOperation: &framework.PathOperation{
+				Callback: b.pathCTLogWrite,
+				Responses: map[int][]framework.Response{
+					http.StatusOK: {{
+						Description: "OK",
+						Fields:      ctLogResponseFields,
+					}},
+				},
+				// Read more about why these flags are set in backend.go.
+				ForwardPerformanceStandby:   true,
+				ForwardPerformanceSecondary: true,
+			},
+			logical.DeleteOperation: &framework.PathOperation{
+				Callback: b.pathCTLogDelete,
+				Responses: map[int][]framework.Response{
+					http.StatusNoContent: {{
+						Description: "No Content",
+					}},
+				},
+				// Read more about why these flags are set in backend.go.
+				ForwardPerformanceStandby:   true,
+				ForwardPerformanceSecondary: true,
+			},
+		},
+
+		HelpSynopsis:    pathCTLogsHelpSyn,
+		HelpDescription: pathCTLogsHelpDesc,
+	}
+}
+
+func (b *backend) pathCTLogList(ctx context.Context, req *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
+	entries, err := req.Storage.List(ctx, issuing.StorageCTLogPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	return logical.ListResponse(entries), nil
+}
+
+func (b *backend) pathCTLogRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	log, err := issuing.GetCTLog(ctx, req.Storage, data.Get("name").(string))
+	if err != nil {
+		return nil, err
+	}
+	if log == nil {
+		return nil, nil
+	}
+
+	return ctLogResponse(log)
+}
+
+func (b *backend) pathCTLogWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := data.Get("name").(string)
+
+	log := &issuing.CTLogEntry{
+		URL:       data.Get("url").(string),
+		PublicKey: data.Get("public_key").(string),
+	}
+
+	logURL, err := url.Parse(log.URL)
+	if err != nil || (logURL.Scheme != "http" && logURL.Scheme != "https") || logURL.Host == "" {
+		return logical.ErrorResponse("url must be an absolute http or https URL"), nil
+	}
+	if _, _, err := log.ParsePublicKey(); err != nil {
+		return logical.ErrorResponse(fmt.Sprintf("invalid public_key: %v", err)), nil
+	}
+
+	if err := issuing.SetCTLog(ctx, req.Storage, name, log); err != nil {
+		return nil, err
+	}
+
+	return ctLogResponse(log)
+}
+
+func (b *backend) pathCTLogDelete(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	err := req.Storage.Delete(ctx, issuing.StorageCTLogPrefix+data.Get("name").(string))
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func ctLogResponse(log *issuing.CTLogEntry) (*logical.Response, error) {
+	_, logID, err := log.ParsePublicKey()
+	if err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"url":        log.URL,
+			"public_key": log.PublicKey,
+			"log_id":     base64.StdEncoding.EncodeToString(logID[:]),
+		},
+	}, nil
+}
+
+const pathListCTLogsHelpSyn = `List the configured certificate transparency logs.`
+
+const pathListCTLogsHelpDesc = `This endpoint lists the names of the certificate transparency logs which roles may submit precertificates to.`
+
+const pathCTLogsHelpSyn = `Manage a certificate transparency log.`
+
+const pathCTLogsHelpDesc = `
+This endpoint configures an RFC 6962 certificate transparency log. Roles
+listing the log in ct_logs submit a precertificate to it for each leaf they
+issue, verify the signed certificate timestamp (SCT) returned against the
+log's public key, and embed the SCTs obtained in the final certificate.
+`
@@ -0,0 +1,280 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package pki
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/builtin/logical/pki/issuing"
+	"github.com/hashicorp/vault/sdk/helper/testhelpers/schema"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/cryptobyte"
+)
+
+// fakeCTLog is a minimal RFC 6962 log serving add-pre-chain.
+type fakeCTLog struct {
+	server    *httptest.Server
+	key       *ecdsa.PrivateKey
+	logID     [sha256.Size]byte
+	publicKey string
+}
+
+func newFakeCTLog(t *testing.T) *fakeCTLog {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(key.Public())
+	require.NoError(t, err)
+
+	log := &fakeCTLog{
+		key:       key,
+		logID:     sha256.Sum256(der),
+		publicKey: string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})),
+	}
+	log.server = httptest.NewServer(http.HandlerFunc(log.addPreChain))
+	t.Cleanup(log.server.Close)
+	return log
+}
+
+func (l *fakeCTLog) addPreChain(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/ct/v1/add-pre-chain" || r.Method != http.MethodPost {
+		http.NotFound(w, r)
+		return
+	}
+
+	var req struct {
+		Chain [][]byte `json:"chain"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Chain) < 2 {
+		http.Error(w, "bad chain", http.StatusBadRequest)
+		return
+	}
+	precert, err := x509.ParseCertificate(req.Chain[0])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	issuer, err := x509.ParseCertificate(req.Chain[1])
+	if err != nil || precert.CheckSignatureFrom(issuer) != nil {
+		http.Error(w, "precertificate not signed by issuer", http.StatusBadRequest)
+		return
+	}
+	poisoned := false
+	for _, ext := range precert.Extensions {
+		poisoned = poisoned || (ext.Id.Equal(issuing.ExtensionCTPoisonOID) && ext.Critical)
+	}
+	if !poisoned {
+		http.Error(w, "missing poison extension", http.StatusBadRequest)
+		return
+	}
+	tbs, err := issuing.TBSWithoutExtension(req.Chain[0], issuing.ExtensionCTPoisonOID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	timestamp := uint64(time.Now().UnixMilli())
+	digest := sha256.Sum256(ctSignedData(timestamp, sha256.Sum256(issuer.RawSubjectPublicKeyInfo), tbs))
+	sig, err := ecdsa.SignASN1(rand.Reader, l.key, digest[:])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var ds cryptobyte.Builder
+	ds.AddUint8(4) // SHA-256
+	ds.AddUint8(3) // ECDSA
+	ds.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes(sig) })
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"sct_version": 0,
+		"id":          l.logID[:],
+		"timestamp":   timestamp,
+		"extensions":  "",
+		"signature":   ds.BytesOrPanic(),
+	})
+}
+
+func ctSignedData(timestamp uint64, issuerKeyHash [sha256.Size]byte, tbs []byte) []byte {
+	var b cryptobyte.Builder
+	b.AddUint8(0) // v1
+	b.AddUint8(0) // certificate_timestamp
+	b.AddUint64(timestamp)
+	b.AddUint16(1) // precert_entry
+	b.AddBytes(issuerKeyHash[:])
+	b.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes(tbs) })
+	b.AddUint16(0) // no extensions
+	return b.BytesOrPanic()
+}
+
+// requireEmbeddedSCTs checks the certificate carries an SCT from each log,
+// each verifying over the certificate as it was submitted.
+func requireEmbeddedSCTs(t *testing.T, cert *x509.Certificate, issuer *x509.Certificate, logs ...*fakeCTLog) {
+	t.Helper()
+
+	var value []byte
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(issuing.ExtensionSCTListOID) {
+			value = ext.Value
+		}
+	}
+	require.NotNil(t, value, "certificate has no SCT list extension")
+
+	var list []byte
+	_, err := asn1.Unmarshal(value, &list)
+	require.NoError(t, err)
+	tbs, err := issuing.TBSWithoutExtension(cert.Raw, issuing.ExtensionSCTListOID)
+	require.NoError(t, err)
+
+	input := cryptobyte.String(list)
+	var scts cryptobyte.String
+	require.True(t, input.ReadUint16LengthPrefixed(&scts) && input.Empty())
+	for _, log := range logs {
+		var sct, logID, exts, sig cryptobyte.String
+		var version, hashAlg, sigAlg uint8
+		var timestamp uint64
+		require.True(t, scts.ReadUint16LengthPrefixed(&sct))
+		require.True(t, sct.ReadUint8(&version) && sct.ReadBytes((*[]byte)(&logID), sha256.Size) &&
+			sct.ReadUint64(&timestamp) && sct.ReadUint16LengthPrefixed(&exts) &&
+			sct.ReadUint8(&hashAlg) && sct.ReadUint8(&sigAlg) && sct.ReadUint16LengthPrefixed(&sig) && sct.Empty())
+		require.Equal(t, log.logID[:], []byte(logID))
+
+		digest := sha256.Sum256(ctSignedData(timestamp, sha256.Sum256(issuer.RawSubjectPublicKeyInfo), tbs))
+		require.True(t, ecdsa.VerifyASN1(&log.key.PublicKey, digest[:], sig), "SCT signature does not verify")
+	}
+	require.True(t, scts.Empty())
+}
+
+func TestPki_CTLogs(t *testing.T) {
+	t.Parallel()
+	b, s := CreateBackendWithStorage(t)
+
+	resp, err := CBWrite(b, s, "root/generate/internal", map[string]interface{}{
+		"common_name": "root example.com",
+		"key_type":    "ec",
+	})
+	requireSuccessNonNilResponse(t, resp, err)
+	issuer := parseCert(t, resp.Data["certificate"].(string))
+
+	log1 := newFakeCTLog(t)
+	log2 := newFakeCTLog(t)
+
+	resp, err = CBWrite(b, s, "ct-logs/log1", map[string]interface{}{
+		"url":        log1.server.URL,
+		"public_key": log1.publicKey,
+	})
+	requireSuccessNonNilResponse(t, resp, err)
+	schema.ValidateResponse(t, schema.GetResponseSchema(t, b.Route("ct-logs/log1"), logical.UpdateOperation), resp, true)
+	resp, err = CBWrite(b, s, "ct-logs/log2", map[string]interface{}{
+		"url":        log2.server.URL + "/",
+		"public_key": log2.publicKey,
+	})
+	requireSuccessNonNilResponse(t, resp, err)
+
+	resp, err = CBRead(b, s, "ct-logs/log1")
+	requireSuccessNonNilResponse(t, resp, err)
+	require.Equal(t, log1.server.URL, resp.Data["url"])
+	require.NotEmpty(t, resp.Data["log_id"])
+
+	resp, err = CBList(b, s, "ct-logs")
+	requireSuccessNonNilResponse(t, resp, err)
+	require.ElementsMatch(t, []string{"log1", "log2"}, resp.Data["keys"])
+
+	// Invalid logs are rejected.
+	resp, err = CBWrite(b, s, "ct-logs/bad", map[string]interface{}{
+		"url":        "not a url",
+		"public_key": log1.publicKey,
+	})
+	require.Error(t, err)
+	require.True(t, resp.IsError())
+	resp, err = CBWrite(b, s, "ct-logs/bad", map[string]interface{}{
+		"url":        log1.server.URL,
+		"public_key": "garbage",
+	})
+	require.Error(t, err)
+	require.True(t, resp.IsError())
+
+	resp, err = CBWrite(b, s, "roles/ct", map[string]interface{}{
+		"allow_any_name": true,
+		"ttl":            "1h",
+		"key_type":       "ec",
+		"ct_logs":        "log1,log2",
+	})
+	requireSuccessNonNilResponse(t, resp, err)
+	require.Equal(t, []string{"log1", "log2"}, resp.Data["ct_logs"])
+
+	resp, err = CBWrite(b, s, "roles/ct", map[string]interface{}{
+		"allow_any_name": true,
+		"ct_logs":        "log1",
+		"ct_min_scts":    2,
+	})
+	require.Error(t, err)
+	require.True(t, resp.IsError(), "ct_min_scts above the number of logs should be rejected")
+
+	// Both issued and signed leaves embed an SCT from every log.
+	resp, err = CBWrite(b, s, "issue/ct", map[string]interface{}{
+		"common_name": "www.example.com",
+	})
+	requireSuccessNonNilResponse(t, resp, err)
+	cert := parseCert(t, resp.Data["certificate"].(string))
+	requireSignedBy(t, cert, issuer)
+	requireEmbeddedSCTs(t, cert, issuer, log1, log2)
+	require.Equal(t, []string{"www.example.com"}, cert.DNSNames)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{}, crypto.Signer(key))
+	require.NoError(t, err)
+	resp, err = CBWrite(b, s, "sign/ct", map[string]interface{}{
+		"common_name": "api.example.com",
+		"csr":         string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csr})),
+	})
+	requireSuccessNonNilResponse(t, resp, err)
+	cert = parseCert(t, resp.Data["certificate"].(string))
+	requireSignedBy(t, cert, issuer)
+	requireEmbeddedSCTs(t, cert, issuer, log1, log2)
+
+	// The stored certificate is the one with SCTs embedded.
+	resp, err = CBRead(b, s, "cert/"+resp.Data["serial_number"].(string))
+	requireSuccessNonNilResponse(t, resp, err)
+	require.Equal(t, cert.Raw, parseCert(t, resp.Data["certificate"].(string)).Raw)
+
+	// A failing log prevents issuance unless the role tolerates it.
+	log2.server.Close()
+	_, err = CBWrite(b, s, "issue/ct", map[string]interface{}{
+		"common_name": "www.example.com",
+	})
+	require.Error(t, err)
+
+	resp, err = CBPatch(b, s, "roles/ct", map[string]interface{}{
+		"ct_min_scts": 1,
+	})
+	requireSuccessNonNilResponse(t, resp, err)
+	resp, err = CBWrite(b, s, "issue/ct", map[string]interface{}{
+		"common_name": "www.example.com",
+	})
+	requireSuccessNonNilResponse(t, resp, err)
+	require.NotEmpty(t, resp.Warnings)
+	requireEmbeddedSCTs(t, parseCert(t, resp.Data["certificate"].(string)), issuer, log1)
+
+	// Referencing a missing log is an error.
+	_, err = CBDelete(b, s, "ct-logs/log1")
+	require.NoError(t, err)
+	resp, err = CBWrite(b, s, "issue/ct", map[string]interface{}{
+		"common_name": "www.example.com",
+	})
+	require.Error(t, err)
+	require.True(t, resp.IsError())
+}
@@ -0,0 +1,465 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package pki
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/vault/builtin/logical/pki/issuing"
+	"github.com/hashicorp/vault/helper/pkcs7"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/consts"
+	"github.com/hashicorp/vault/sdk/helper/errutil"
+	"github.com/hashicorp/vault/sdk/logical"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/cryptobyte"
+	cryptobyte_asn1 "golang.org/x/crypto/cryptobyte/asn1"
+)
+
+const (
+	estPathPrefix = ".well-known/est/"
+
+	estOperationCACerts        = "cacerts"
+	estOperationSimpleEnroll   = "simpleenroll"
+	estOperationSimpleReenroll = "simplereenroll"
+	estOperationCSRAttrs       = "csrattrs"
+
+	// A PKCS#10 request for a 4096-bit RSA key is a little over 2KiB once
+	// base64 encoded; leave generous room for extensions and attributes.
+	estMaximumRequestSize = 64 * 1024
+
+	estContentTypeCerts    = "application/pkcs7-mime; smime-type=certs-only"
+	estContentTypeCSRAttrs = "application/csrattrs"
+
+	pathEstHelpSyn  = `An endpoint implementing the EST protocol`
+	pathEstHelpDesc = `This API endpoint implements the cacerts, simpleenroll,
+ simplereenroll and csrattrs operations of the EST protocol defined in
+ RFC 7030, with its own authentication and argument syntax that does not
+ follow conventional Vault operations. An EST client should be used to
+ interact with these endpoints.`
+)
+
+// estOperations are reserved and cannot be used as EST labels.
+var estOperations = map[string]struct{}{
+	estOperationCACerts:        {},
+	estOperationSimpleEnroll:   {},
+	estOperationSimpleReenroll: {},
+	estOperationCSRAttrs:       {},
+}
+
+var (
+	oidRSAEncryption   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+	oidECPublicKey     = asn1.ObjectIdentifier{1, 2, 840, 10045, 2, 1}
+	oidEd25519         = asn1.ObjectIdentifier{1, 3, 101, 112}
+	oidSubjectAltName  = asn1.ObjectIdentifier{2, 5, 29, 17}
+	oidNamedCurveByLen = map[int]asn1.ObjectIdentifier{
+		224: {1, 3, 132, 0, 33},
+		256: {1, 2, 840, 10045, 3, 1, 7},
+		384: {1, 3, 132, 0, 34},
+		521: {1, 3, 132, 0, 35},
+	}
+)
+
+// estContext carries the role an EST request was routed to.
+type estContext struct {
+	config   *estConfigEntry
+	roleName string
+	role     *issuing.RoleEntry
+}
+
+type estOperation func(sc *storageContext, req *logical.Request, estCtx *estContext) (*logical.Response, error)
+
+func pathEstCACerts(b *backend) *framework.Path {
+	return patternEst(b, estOperationCACerts, logical.ReadOperation, b.estCACertsHandler)
+}
+
+func pathEstSimpleEnroll(b *backend) *framework.Path {
+	return patternEst(b, estOperationSimpleEnroll, logical.UpdateOperation, b.estSimpleEnrollHandler)
+}
+
+func pathEstSimpleReenroll(b *backend) *framework.Path {
+	return patternEst(b, estOperationSimpleReenroll, logical.UpdateOperation, b.estSimpleReenrollHandler)
+}
+
+func pathEstCSRAttrs(b *backend) *framework.Path {
+	return patternEst(b, estOperationCSRAttrs, logical.ReadOperation, b.estCSRAttrsHandler)
+}
+
+func patternEst(b *backend, operation string, op logical.Operation, handler estOperation) *framework.Path {
+	return &framework.Path{
+		Pattern: regexp.QuoteMeta(estPathPrefix) + "(" + framework.GenericNameRegex("label") + "/)?" + operation,
+		Fields: map[string]*framework.FieldSchema{
+			"label": {
+				Type:        framework.TypeString,
+				Description: `Optional EST label selecting the role to issue against; see label_to_role in config/est.`,
+			},
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			op: &framework.PathOperation{
+				Callback:                    b.estWrapper(handler),
+				ForwardPerformanceSecondary: false,
+				ForwardPerformanceStandby:   true,
+			},
+		},
+
+		HelpSynopsis:    pathEstHelpSyn,
+		HelpDescription: pathEstHelpDesc,
+	}
+}
+
+// estWrapper resolves the role an EST request is for, replying with a bare
+// 404 when EST is disabled or the label is not configured.
+func (b *backend) estWrapper(op estOperation) framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		sc := b.makeStorageContext(ctx, req.Storage)
+
+		config, err := sc.getEstConfig()
+		if err != nil {
+			return nil, err
+		}
+		if !config.Enabled {
+			return estErrorResponse(http.StatusNotFound, "EST is not enabled on this mount"), nil
+		}
+
+		roleName := config.DefaultRole
+		if label := data.Get("label").(string); label != "" {
+			roleName = config.LabelToRole[label]
+		}
+		if roleName == "" {
+			return estErrorResponse(http.StatusNotFound, "unknown EST label"), nil
+		}
+
+		role, err := b.GetRole(ctx, req.Storage, roleName)
+		if err != nil {
+			return nil, err
+		}
+		if role == nil {
+			return estErrorResponse(http.StatusInternalServerError, fmt.Sprintf("role %q configured for EST does not exist", roleName)), nil
+		}
+
+		return op(sc, req, &estContext{
+			config:   config,
+			roleName: roleName,
+			role:     role,
+		})
+	}
+}
+
+func (b *backend) estCACertsHandler(sc *storageContext, _ *logical.Request, estCtx *estContext) (*logical.Response, error) {
+	caInfo, err := sc.fetchCAInfo(roleIssuerRef(estCtx.role), issuing.ReadOnlyUsage)
+	if err != nil {
+		return nil, err
+	}
+
+	var chain []*x509.Certificate
+	for _, cert := range caInfo.GetFullChain() {
+		chain = append(chain, cert.Certificate)
+	}
+
+	return estCertsResponse(chain)
+}
+
+func (b *backend) estCSRAttrsHandler(_ *storageContext, _ *logical.Request, estCtx *estContext) (*logical.Response, error) {
+	var attrs []byte
+	switch estCtx.role.KeyType {
+	case "rsa":
+		attrs = marshalCSRAttrs(func(child *cryptobyte.Builder) {
+			child.AddASN1ObjectIdentifier(oidRSAEncryption)
+		})
+	case "ec":
+		curve, ok := oidNamedCurveByLen[estCtx.role.KeyBits]
+		if !ok {
+			return nil, fmt.Errorf("unsupported EC key size %d on role %q", estCtx.role.KeyBits, estCtx.roleName)
+		}
+		attrs = marshalCSRAttrs(func(child *cryptobyte.Builder) {
+			child.AddASN1(cryptobyte_asn1.SEQUENCE, func(attr *cryptobyte.Builder) {
+				attr.AddASN1ObjectIdentifier(oidECPublicKey)
+				attr.AddASN1(cryptobyte_asn1.SET, func(values *cryptobyte.Builder) {
+					values.AddASN1ObjectIdentifier(curve)
+				})
+			})
+		})
+	case "ed25519":
+		attrs = marshalCSRAttrs(func(child *cryptobyte.Builder) {
+			child.AddASN1ObjectIdentifier(oidEd25519)
+		})
+	default:
+		// RFC 7030 Section 4.5.2: no attributes are required.
+		return &logical.Response{
+			Data: map[string]interface{}{
+				logical.HTTPStatusCode: http.StatusNoContent,
+				logical.HTTPRawBody:    []byte{},
+			},
+		}, nil
+	}
+
+	return estRawResponse(estContentTypeCSRAttrs, attrs), nil
+}
+
+func marshalCSRAttrs(add cryptobyte.BuilderContinuation) []byte {
+	var builder cryptobyte.Builder
+	builder.AddASN1(cryptobyte_asn1.SEQUENCE, add)
+	return builder.BytesOrPanic()
+}
+
+func (b *backend) estSimpleEnrollHandler(sc *storageContext, req *logical.Request, estCtx *estContext) (*logical.Response, error) {
+	if _, failure, err := b.estAuthenticate(sc, req, estCtx, false); err != nil || failure != nil {
+		return failure, err
+	}
+
+	csr, failure := estReadCSR(req)
+	if failure != nil {
+		return failure, nil
+	}
+
+	return b.estIssue(sc, req, estCtx, csr)
+}
+
+func (b *backend) estSimpleReenrollHandler(sc *storageContext, req *logical.Request, estCtx *estContext) (*logical.Response, error) {
+	clientCert, failure, err := b.estAuthenticate(sc, req, estCtx, true)
+	if err != nil || failure != nil {
+		return failure, err
+	}
+	if clientCert == nil {
+		return estUnauthorizedResponse("re-enrollment requires authenticating with the certificate being renewed"), nil
+	}
+
+	csr, failure := estReadCSR(req)
+	if failure != nil {
+		return failure, nil
+	}
+
+	// RFC 7030 Section 4.2.2: the Subject and SubjectAltName must be
+	// identical to those of the certificate being renewed.
+	if !bytes.Equal(csr.RawSubject, clientCert.RawSubject) ||
+		!bytes.Equal(findExtensionValue(csr.Extensions, oidSubjectAltName), findExtensionValue(clientCert.Extensions, oidSubjectAltName)) {
+		return estErrorResponse(http.StatusBadRequest, "CSR subject and subject alternative names must match the certificate being renewed"), nil
+	}
+
+	return b.estIssue(sc, req, estCtx, csr)
+}
+
+func (b *backend) estIssue(sc *storageContext, req *logical.Request, estCtx *estContext, csr *x509.CertificateRequest) (*logical.Response, error) {
+	// If storing the certificate and on a performance standby, forward this request on to the primary
+	// Allow performance secondaries to generate and store certificates locally to them.
+	if !estCtx.role.NoStore && b.System().ReplicationState().HasState(consts.ReplicationPerformanceStandby) {
+		return nil, logical.ErrReadOnly
+	}
+
+	parsedBundle, err := b.signEnrollmentCSR(sc, req, estCtx.role, csr)
+	if err != nil {
+		if _, ok := err.(errutil.UserError); ok {
+			return estErrorResponse(http.StatusBadRequest, err.Error()), nil
+		}
+		return nil, err
+	}
+
+	return estCertsResponse([]*x509.Certificate{parsedBundle.Certificate})
+}
+
+// estDummyPasswordHash is compared against when authenticating an unknown
+// user, so that doing so takes as long as for a known one.
+var estDummyPasswordHash = func() []byte {
+	password := make([]byte, 32)
+	if _, err := rand.Read(password); err != nil {
+		panic(fmt.Sprintf("failed to generate EST dummy password: %v", err))
+	}
+	hash, err := bcrypt.GenerateFromPassword(password, bcrypt.DefaultCost)
+	if err != nil {
+		panic(fmt.Sprintf("failed to hash EST dummy password: %v", err))
+	}
+	return hash
+}()
+
+// estAuthenticate authenticates an enrollment request, preferring a TLS
+// client certificate and falling back to HTTP basic authentication. The
+// client certificate is returned when one was verified; otherwise a non-nil
+// response carries the failure to send to the client.
+func (b *backend) estAuthenticate(sc *storageContext, req *logical.Request, estCtx *estContext, reenroll bool) (*x509.Certificate, *logical.Response, error) {
+	if req.Connection != nil && req.Connection.ConnState != nil && len(req.Connection.ConnState.PeerCertificates) > 0 {
+		clientCert, err := b.estVerifyClientCert(sc, estCtx.roleName, reenroll, req.Connection.ConnState.PeerCertificates)
+		if err != nil {
+			if _, ok := err.(errutil.UserError); !ok {
+				return nil, nil, err
+			}
+			// Don't tell unauthenticated clients why their certificate was
+			// rejected.
+			b.Logger().Debug("rejected EST client certificate", "role", estCtx.roleName, "error", err)
+			return nil, estUnauthorizedResponse("invalid client certificate"), nil
+		}
+		return clientCert, nil, nil
+	}
+
+	username, password, ok := (&http.Request{Header: http.Header(req.Headers)}).BasicAuth()
+	if !ok {
+		return nil, estUnauthorizedResponse("authentication required"), nil
+	}
+
+	user, err := sc.getEstUser(username)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// If the user does not exist, we compare against a fake hash so as not
+	// to leak which usernames exist through timing.
+	hash := estDummyPasswordHash
+	if user != nil {
+		hash = user.PasswordHash
+	}
+	if err := bcrypt.CompareHashAndPassword(hash, []byte(password)); err != nil || user == nil {
+		return nil, estUnauthorizedResponse("invalid username or password"), nil
+	}
+	if !user.allowsRole(estCtx.roleName) {
+		return nil, estUnauthorizedResponse("user is not allowed to enroll against this role"), nil
+	}
+
+	return nil, nil, nil
+}
+
+// estVerifyClientCert checks the presented chain against the client CAs
+// allowed to enroll against the role, rejecting certificates revoked by this
+// mount or not valid for client authentication. Certificates issued by this
+// mount are also trusted for re-enrollment, which keeps their subject.
+func (b *backend) estVerifyClientCert(sc *storageContext, roleName string, reenroll bool, peerCerts []*x509.Certificate) (*x509.Certificate, error) {
+	roots, err := sc.estClientCARoots(roleName)
+	if err != nil {
+		return nil, err
+	}
+	if reenroll {
+		issuerIds, err := sc.listIssuers()
+		if err != nil {
+			return nil, err
+		}
+		for _, issuerId := range issuerIds {
+			issuer, err := sc.fetchIssuerById(issuerId)
+			if err != nil {
+				return nil, err
+			}
+			cert, err := issuer.GetCertificate()
+			if err != nil {
+				return nil, err
+			}
+			roots.AddCert(cert)
+		}
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range peerCerts[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	clientCert := peerCerts[0]
+	if _, err := clientCert.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}); err != nil {
+		return nil, errutil.UserError{Err: fmt.Sprintf("client certificate is not trusted: %v", err)}
+	}
+
+	revInfo, err := sc.fetchRevocationInfo(serialFromCert(clientCert))
+	if err != nil {
+		return nil, err
+	}
+	if revInfo != nil {
+		return nil, errutil.UserError{Err: "client certificate has been revoked"}
+	}
+
+	return clientCert, nil
+}
+
+// estReadCSR decodes the base64 PKCS#10 body of an enrollment request.
+func estReadCSR(req *logical.Request) (*x509.CertificateRequest, *logical.Response) {
+	// NOTE: Writing an empty update request to Vault causes a nil request.HTTPRequest, and that object
+	//       says that it is possible for its Body element to be nil as well, so check both just in case.
+	if req.HTTPRequest == nil || req.HTTPRequest.Body == nil {
+		return nil, estErrorResponse(http.StatusBadRequest, "no CSR provided")
+	}
+
+	body, err := io.ReadAll(io.LimitReader(req.HTTPRequest.Body, estMaximumRequestSize))
+	if err != nil {
+		return nil, estErrorResponse(http.StatusBadRequest, "failed reading request body")
+	}
+	if len(body) >= estMaximumRequestSize {
+		return nil, estErrorResponse(http.StatusRequestEntityTooLarge, "request is too large")
+	}
+
+	der, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(string(body)), ""))
+	if err != nil {
+		return nil, estErrorResponse(http.StatusBadRequest, "CSR is not base64 encoded")
+	}
+	csr, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		return nil, estErrorResponse(http.StatusBadRequest, fmt.Sprintf("failed parsing CSR: %v", err))
+	}
+	if err := csr.CheckSignature(); err != nil {
+		return nil, estErrorResponse(http.StatusBadRequest, "CSR signature is invalid")
+	}
+
+	return csr, nil
+}
+
+func findExtensionValue(exts []pkix.Extension, oid asn1.ObjectIdentifier) []byte {
+	for _, ext := range exts {
+		if ext.Id.Equal(oid) {
+			return ext.Value
+		}
+	}
+	return nil
+}
+
+func estCertsResponse(certs []*x509.Certificate) (*logical.Response, error) {
+	var der []byte
+	for _, cert := range certs {
+		der = append(der, cert.Raw...)
+	}
+	p7, err := pkcs7.DegenerateCertificate(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed encoding certificates: %w", err)
+	}
+
+	return estRawResponse(estContentTypeCerts, p7), nil
+}
+
+func estRawResponse(contentType string, body []byte) *logical.Response {
+	return &logical.Response{
+		Data: map[string]interface{}{
+			logical.HTTPContentType: contentType,
+			logical.HTTPStatusCode:  http.StatusOK,
+			logical.HTTPRawBody:     []byte(base64.StdEncoding.EncodeToString(body)),
+		},
+		Headers: map[string][]string{
+			"Content-Transfer-Encoding": {"base64"},
+		},
+	}
+}
+
+func estErrorResponse(status int, msg string) *logical.Response {
+	return &logical.Response{
+		Data: map[string]interface{}{
+			logical.HTTPContentType: "text/plain",
+			logical.HTTPStatusCode:  status,
+			logical.HTTPRawBody:     []byte(msg + "\n"),
+		},
+	}
+}
+
+func estUnauthorizedResponse(msg string) *logical.Response {
+	resp := estErrorResponse(http.StatusUnauthorized, msg)
+	resp.Headers = map[string][]string{
+		"WWW-Authenticate": {`Basic realm="EST"`},
+	}
+	return resp
+}
@@ -0,0 +1,269 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package pki
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/certutil"
+	"github.com/hashicorp/vault/sdk/helper/strutil"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const estClientCAPrefix = "est/client-ca/"
+
+// estClientCAEntry is a set of certificates trusted to authenticate EST
+// clients, either as the CAs of their client certificates or as the client
+// certificates themselves, for the listed roles only.
+type estClientCAEntry struct {
+	Certificates string   `json:"certificates"`
+	AllowedRoles []string `json:"allowed_roles"`
+}
+
+func (e *estClientCAEntry) allowsRole(role string) bool {
+	return strutil.StrListContains(e.AllowedRoles, role)
+}
+
+func (sc *storageContext) getEstClientCA(name string) (*estClientCAEntry, error) {
+	entry, err := sc.Storage.Get(sc.Context, estClientCAPrefix+name)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var clientCA estClientCAEntry
+	if err := entry.DecodeJSON(&clientCA); err != nil {
+		return nil, fmt.Errorf("unable to decode EST client CA %q: %w", name, err)
+	}
+
+	return &clientCA, nil
+}
+
+// estClientCARoots returns a pool of the client CA certificates allowed to
+// authenticate enrollment against the named role.
+func (sc *storageContext) estClientCARoots(role string) (*x509.CertPool, error) {
+	names, err := sc.Storage.List(sc.Context, estClientCAPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	roots := x509.NewCertPool()
+	for _, name := range names {
+		clientCA, err := sc.getEstClientCA(name)
+		if err != nil {
+			return nil, err
+		}
+		if clientCA == nil || !clientCA.allowsRole(role) {
+			continue
+		}
+
+		certs, err := certutil.ParseCertsPEM([]byte(clientCA.Certificates))
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse EST client CA %q: %w", name, err)
+		}
+		for _, cert := range certs {
+			roots.AddCert(cert)
+		}
+	}
+
+	return roots, nil
+}
+
+func pathListEstClientCAs(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "est/client-cas/?$",
+
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixPKI,
+			OperationSuffix: "est-client-cas",
+		},
+
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ListOperation: &framework.PathOperation{
+				Callback: b.pathEstClientCAList,
+			},
+		},
+
+		HelpSynopsis:    pathListEstClientCAsHelpSyn,
+		HelpDescription: pathListEstClientCAsHelpDesc,
+	}
+}
+
+func pathEstClientCAs(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "est/client-cas/" + framework.GenericNameRegex("name"),
+
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixPKI,
+			OperationSuffix: "est-client-ca",
+		},
+
+		Fields: map[string]*framework.FieldSchema{
+			"name": {
+				Type:        framework.TypeString,
+				Description: `Name of the set of trusted client certificates`,
+				Required:    true,
+			},
+			"certificates": {
+				Type: framework.TypeString,
+				Description: `PEM encoded CA certificates issuing trusted EST
+client certificates, or the trusted client certificates themselves. Required
+when creating the entry.`,
+			},
+			"allowed_roles": {
+				Type: framework.TypeCommaStringSlice,
+				Description: `Roles that clients authenticating with these
+certificates may enroll against. Required when creating the entry.`,
+			},
+		},
+
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ReadOperation: &framework.PathOperation{
+				Callback: b.pathEstClientCARead,
+				Responses: map[int][]framework.Response{
+					http.StatusOK: {{
+						Description: "OK",
+						Fields: map[string]*framework.FieldSchema{
+							"certificates": {
+								Type:        framework.TypeString,
+								Description: `PEM encoded trusted certificates`,
+								Required:    true,
+							},
+							"allowed_roles": {
+								Type:        framework.TypeCommaStringSlice,
+								Description: `Roles clients may enroll against`,
+								Required:    true,
+							},
+						},
+					}},
+				},
+			},
+			logical.UpdateOperation: &framework.PathOperation{
+				Callback: b.pathEstClientCAWrite,
+				Responses: map[int][]framework.Response{
+					http.StatusNoContent: {{
+						Description: "No Content",
+					}},
+				},
+				// Read more about why these flags are set in backend.go.
+				ForwardPerformanceStandby:   true,
+				ForwardPerformanceSecondary: true,
+			},
+			logical.DeleteOperation: &framework.PathOperation{
+				Callback: b.pathEstClientCADelete,
+				Responses: map[int][]framework.Response{
+					http.StatusNoContent: {{
+						Description: "No Content",
+					}},
+				},
+				// Read more about why these flags are set in backend.go.
+				ForwardPerformanceStandby:   true,
+				ForwardPerformanceSecondary: true,
+			},
+		},
+
+		HelpSynopsis:    pathEstClientCAsHelpSyn,
+		HelpDescription: pathEstClientCAsHelpDesc,
+	}
+}
+
+func (b *backend) pathEstClientCAList(ctx context.Context, req *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
+	entries, err := req.Storage.List(ctx, estClientCAPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	return logical.ListResponse(entries), nil
+}
+
+func (b *backend) pathEstClientCARead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	sc := b.makeStorageContext(ctx, req.Storage)
+	clientCA, err := sc.getEstClientCA(data.Get("name").(string))
+	if err != nil {
+		return nil, err
+	}
+	if clientCA == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"certificates":  clientCA.Certificates,
+			"allowed_roles": clientCA.AllowedRoles,
+		},
+	}, nil
+}
+
+func (b *backend) pathEstClientCAWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := data.Get("name").(string)
+
+	sc := b.makeStorageContext(ctx, req.Storage)
+	clientCA, err := sc.getEstClientCA(name)
+	if err != nil {
+		return nil, err
+	}
+	if clientCA == nil {
+		clientCA = &estClientCAEntry{AllowedRoles: []string{}}
+	}
+
+	if certificatesRaw, ok := data.GetOk("certificates"); ok {
+		clientCA.Certificates = certificatesRaw.(string)
+	}
+	if allowedRolesRaw, ok := data.GetOk("allowed_roles"); ok {
+		clientCA.AllowedRoles = allowedRolesRaw.([]string)
+	}
+
+	certs, err := certutil.ParseCertsPEM([]byte(clientCA.Certificates))
+	if err != nil {
+		return logical.ErrorResponse("failed to parse certificates: %v", err), nil
+	}
+	if len(certs) == 0 {
+		return logical.ErrorResponse("missing certificates"), nil
+	}
+	if len(clientCA.AllowedRoles) == 0 {
+		return logical.ErrorResponse("missing allowed_roles"), nil
+	}
+
+	entry, err := logical.StorageEntryJSON(estClientCAPrefix+name, clientCA)
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Put(ctx, entry); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (b *backend) pathEstClientCADelete(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	if err := req.Storage.Delete(ctx, estClientCAPrefix+data.Get("name").(string)); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+const pathListEstClientCAsHelpSyn = `List the certificates trusted to authenticate EST clients.`
+
+const pathListEstClientCAsHelpDesc = `This endpoint lists the named sets of certificates trusted to authenticate EST clients presenting TLS client certificates.`
+
+const pathEstClientCAsHelpSyn = `Manage certificates trusted to authenticate EST clients.`
+
+const pathEstClientCAsHelpDesc = `
+This endpoint manages a named set of CA certificates, or pinned client
+certificates, trusted to authenticate EST clients presenting TLS client
+certificates to the simpleenroll and simplereenroll endpoints. Clients may
+only enroll against the roles listed in allowed_roles, and their client
+certificates must allow client authentication.
+
+Certificates issued by this mount may always re-enroll through
+simplereenroll, which keeps their subject; enrolling new identities with
+them requires listing the issuer here.
+`
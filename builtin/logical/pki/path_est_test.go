@@ -0,0 +1,351 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package pki
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/hashicorp/vault/helper/pkcs7"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// estRequest performs an EST operation, optionally with a base64 CSR body,
+// HTTP basic credentials or a TLS client certificate.
+func estRequest(t *testing.T, b *backend, s logical.Storage, path string, csr []byte, username, password string, clientCert *x509.Certificate) *logical.Response {
+	t.Helper()
+
+	req := &logical.Request{
+		Operation:  logical.ReadOperation,
+		Path:       path,
+		Storage:    s,
+		MountPoint: "pki/",
+		Headers:    map[string][]string{},
+	}
+	if csr != nil {
+		req.Operation = logical.UpdateOperation
+		req.HTTPRequest = &http.Request{
+			Body: io.NopCloser(bytes.NewBufferString(base64.StdEncoding.EncodeToString(csr))),
+		}
+	}
+	if username != "" {
+		httpReq := &http.Request{Header: http.Header{}}
+		httpReq.SetBasicAuth(username, password)
+		req.Headers["Authorization"] = httpReq.Header["Authorization"]
+	}
+	if clientCert != nil {
+		req.Connection = &logical.Connection{
+			ConnState: &tls.ConnectionState{PeerCertificates: []*x509.Certificate{clientCert}},
+		}
+	}
+
+	resp, err := b.HandleRequest(context.Background(), req)
+	require.NoError(t, err)
+	require.NotNil(t, resp)
+	return resp
+}
+
+// estDecode checks the response status and returns its base64 decoded body.
+func estDecode(t *testing.T, resp *logical.Response, status int) []byte {
+	t.Helper()
+
+	require.Equal(t, status, resp.Data[logical.HTTPStatusCode], "unexpected EST response: %s", resp.Data[logical.HTTPRawBody])
+	if status != http.StatusOK {
+		return nil
+	}
+	body, err := base64.StdEncoding.DecodeString(string(resp.Data[logical.HTTPRawBody].([]byte)))
+	require.NoError(t, err)
+	return body
+}
+
+func estCerts(t *testing.T, resp *logical.Response) []*x509.Certificate {
+	t.Helper()
+
+	require.Equal(t, estContentTypeCerts, resp.Data[logical.HTTPContentType])
+	return parseCertsOnlyPKCS7(t, estDecode(t, resp, http.StatusOK))
+}
+
+// parseCertsOnlyPKCS7 returns the certificates of a degenerate PKCS#7
+// structure. pkcs7.Parse rejects the empty CRL set these carry, so the
+// certificates are pulled out directly.
+func parseCertsOnlyPKCS7(t *testing.T, der []byte) []*x509.Certificate {
+	t.Helper()
+
+	var contentInfo struct {
+		ContentType asn1.ObjectIdentifier
+		Content     asn1.RawValue `asn1:"explicit,tag:0"`
+	}
+	_, err := asn1.Unmarshal(der, &contentInfo)
+	require.NoError(t, err)
+	require.True(t, contentInfo.ContentType.Equal(pkcs7.OIDSignedData))
+	var signedData struct {
+		Version          int
+		DigestAlgorithms asn1.RawValue
+		ContentInfo      asn1.RawValue
+		Certificates     asn1.RawValue `asn1:"tag:0"`
+		CRLs             asn1.RawValue `asn1:"optional,tag:1"`
+		SignerInfos      asn1.RawValue
+	}
+	_, err = asn1.Unmarshal(contentInfo.Content.Bytes, &signedData)
+	require.NoError(t, err)
+	certs, err := x509.ParseCertificates(signedData.Certificates.Bytes)
+	require.NoError(t, err)
+	return certs
+}
+
+func estCSR(t *testing.T, template *x509.CertificateRequest) []byte {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	csr, err := x509.CreateCertificateRequest(rand.Reader, template, key)
+	require.NoError(t, err)
+	return csr
+}
+
+func TestPki_EST(t *testing.T) {
+	t.Parallel()
+	b, s := CreateBackendWithStorage(t)
+
+	resp, err := CBWrite(b, s, "root/generate/internal", map[string]interface{}{
+		"common_name": "root example.com",
+		"key_type":    "ec",
+	})
+	requireSuccessNonNilResponse(t, resp, err)
+	issuer := parseCert(t, resp.Data["certificate"].(string))
+
+	resp, err = CBWrite(b, s, "roles/devices", map[string]interface{}{
+		"allowed_domains":  "example.com",
+		"allow_subdomains": true,
+		"ttl":              "1h",
+		"key_type":         "ec",
+	})
+	requireSuccessNonNilResponse(t, resp, err)
+	resp, err = CBWrite(b, s, "roles/other", map[string]interface{}{
+		"allow_any_name": true,
+		"ttl":            "1h",
+		"key_type":       "any",
+	})
+	requireSuccessNonNilResponse(t, resp, err)
+
+	// EST is disabled by default.
+	estDecode(t, estRequest(t, b, s, ".well-known/est/cacerts", nil, "", "", nil), http.StatusNotFound)
+
+	// Labels must not shadow operations and roles must exist.
+	_, err = CBWrite(b, s, "config/est", map[string]interface{}{
+		"label_to_role": map[string]string{"cacerts": "devices"},
+	})
+	require.Error(t, err)
+	_, err = CBWrite(b, s, "config/est", map[string]interface{}{
+		"default_role": "missing",
+	})
+	require.Error(t, err)
+
+	resp, err = CBWrite(b, s, "config/est", map[string]interface{}{
+		"enabled":       true,
+		"default_role":  "devices",
+		"label_to_role": map[string]string{"other": "other"},
+	})
+	requireSuccessNonNilResponse(t, resp, err)
+	resp, err = CBRead(b, s, "config/est")
+	requireSuccessNonNilResponse(t, resp, err)
+	require.Equal(t, true, resp.Data["enabled"])
+	require.Equal(t, map[string]string{"other": "other"}, resp.Data["label_to_role"])
+
+	_, err = CBWrite(b, s, "est/users/router", map[string]interface{}{
+		"allowed_roles": "devices",
+	})
+	require.Error(t, err, "creating a user requires a password")
+	_, err = CBWrite(b, s, "est/users/router", map[string]interface{}{
+		"password":      "hunter2",
+		"allowed_roles": "devices",
+	})
+	require.NoError(t, err)
+	resp, err = CBRead(b, s, "est/users/router")
+	requireSuccessNonNilResponse(t, resp, err)
+	require.Equal(t, []string{"devices"}, resp.Data["allowed_roles"])
+	require.NotContains(t, resp.Data, "password")
+	resp, err = CBList(b, s, "est/users")
+	requireSuccessNonNilResponse(t, resp, err)
+	require.Equal(t, []string{"router"}, resp.Data["keys"])
+
+	// cacerts and csrattrs need no authentication.
+	certs := estCerts(t, estRequest(t, b, s, ".well-known/est/cacerts", nil, "", "", nil))
+	require.Len(t, certs, 1)
+	require.Equal(t, issuer.Raw, certs[0].Raw)
+
+	resp = estRequest(t, b, s, ".well-known/est/csrattrs", nil, "", "", nil)
+	require.Equal(t, estContentTypeCSRAttrs, resp.Data[logical.HTTPContentType])
+	var attrs []asn1.RawValue
+	_, err = asn1.Unmarshal(estDecode(t, resp, http.StatusOK), &attrs)
+	require.NoError(t, err)
+	require.Len(t, attrs, 1)
+	var attr struct {
+		Type   asn1.ObjectIdentifier
+		Values []asn1.ObjectIdentifier `asn1:"set"`
+	}
+	_, err = asn1.Unmarshal(attrs[0].FullBytes, &attr)
+	require.NoError(t, err)
+	require.True(t, attr.Type.Equal(oidECPublicKey))
+	require.Equal(t, []asn1.ObjectIdentifier{oidNamedCurveByLen[256]}, attr.Values)
+
+	// Roles accepting any key type ask for no attributes.
+	estDecode(t, estRequest(t, b, s, ".well-known/est/other/csrattrs", nil, "", "", nil), http.StatusNoContent)
+	estDecode(t, estRequest(t, b, s, ".well-known/est/unknown/csrattrs", nil, "", "", nil), http.StatusNotFound)
+
+	// Enrollment requires authentication.
+	csr := estCSR(t, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: "router1.example.com"},
+		DNSNames: []string{"router1.example.com"},
+	})
+	resp = estRequest(t, b, s, ".well-known/est/simpleenroll", csr, "", "", nil)
+	estDecode(t, resp, http.StatusUnauthorized)
+	require.Equal(t, []string{`Basic realm="EST"`}, resp.Headers["WWW-Authenticate"])
+	estDecode(t, estRequest(t, b, s, ".well-known/est/simpleenroll", csr, "router", "wrong", nil), http.StatusUnauthorized)
+	estDecode(t, estRequest(t, b, s, ".well-known/est/simpleenroll", csr, "nobody", "hunter2", nil), http.StatusUnauthorized)
+
+	// Unknown users are checked against a real bcrypt hash, so that they
+	// take as long to reject as known ones.
+	cost, err := bcrypt.Cost(estDummyPasswordHash)
+	require.NoError(t, err)
+	require.Equal(t, bcrypt.DefaultCost, cost)
+	estDecode(t, estRequest(t, b, s, ".well-known/est/other/simpleenroll", csr, "router", "hunter2", nil), http.StatusUnauthorized)
+
+	certs = estCerts(t, estRequest(t, b, s, ".well-known/est/simpleenroll", csr, "router", "hunter2", nil))
+	require.Len(t, certs, 1)
+	cert := certs[0]
+	requireSignedBy(t, cert, issuer)
+	require.Equal(t, "router1.example.com", cert.Subject.CommonName)
+	require.Equal(t, []string{"router1.example.com"}, cert.DNSNames)
+
+	resp, err = CBRead(b, s, "cert/"+serialFromCert(cert))
+	requireSuccessNonNilResponse(t, resp, err)
+
+	// Role constraints still apply.
+	estDecode(t, estRequest(t, b, s, ".well-known/est/simpleenroll", estCSR(t, &x509.CertificateRequest{
+		Subject: pkix.Name{CommonName: "router1.example.org"},
+	}), "router", "hunter2", nil), http.StatusBadRequest)
+
+	// Re-enrollment authenticates with the certificate being renewed and
+	// keeps its subject.
+	estDecode(t, estRequest(t, b, s, ".well-known/est/simplereenroll", csr, "router", "hunter2", nil), http.StatusUnauthorized)
+	estDecode(t, estRequest(t, b, s, ".well-known/est/simplereenroll", estCSR(t, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: "router2.example.com"},
+		DNSNames: []string{"router2.example.com"},
+	}), "", "", cert), http.StatusBadRequest)
+
+	certs = estCerts(t, estRequest(t, b, s, ".well-known/est/simplereenroll", csr, "", "", cert))
+	require.Len(t, certs, 1)
+	requireSignedBy(t, certs[0], issuer)
+	require.Equal(t, cert.RawSubject, certs[0].RawSubject)
+	require.NotEqual(t, cert.SerialNumber, certs[0].SerialNumber)
+
+	// Client certificates only authenticate initial enrollment against
+	// the roles their CA is explicitly trusted for, and only when they
+	// allow client authentication.
+	otherCSR := estCSR(t, &x509.CertificateRequest{
+		Subject: pkix.Name{CommonName: "anything"},
+	})
+	estDecode(t, estRequest(t, b, s, ".well-known/est/other/simpleenroll", otherCSR, "", "", cert), http.StatusUnauthorized)
+	issuerPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: issuer.Raw}))
+	_, err = CBWrite(b, s, "est/client-cas/mount", map[string]interface{}{
+		"certificates": issuerPEM,
+	})
+	require.Error(t, err, "trusting a client CA requires allowed_roles")
+	_, err = CBWrite(b, s, "est/client-cas/mount", map[string]interface{}{
+		"certificates":  issuerPEM,
+		"allowed_roles": "other",
+	})
+	require.NoError(t, err)
+	resp, err = CBRead(b, s, "est/client-cas/mount")
+	requireSuccessNonNilResponse(t, resp, err)
+	require.Equal(t, []string{"other"}, resp.Data["allowed_roles"])
+	resp, err = CBList(b, s, "est/client-cas")
+	requireSuccessNonNilResponse(t, resp, err)
+	require.Equal(t, []string{"mount"}, resp.Data["keys"])
+
+	certs = estCerts(t, estRequest(t, b, s, ".well-known/est/other/simpleenroll", otherCSR, "", "", cert))
+	require.Equal(t, "anything", certs[0].Subject.CommonName)
+	estDecode(t, estRequest(t, b, s, ".well-known/est/simpleenroll", otherCSR, "", "", cert), http.StatusUnauthorized)
+
+	resp, err = CBWrite(b, s, "roles/server-only", map[string]interface{}{
+		"allowed_domains":  "example.com",
+		"allow_subdomains": true,
+		"ttl":              "1h",
+		"key_type":         "ec",
+		"client_flag":      false,
+	})
+	requireSuccessNonNilResponse(t, resp, err)
+	resp, err = CBWrite(b, s, "issue/server-only", map[string]interface{}{
+		"common_name": "router1.example.com",
+	})
+	requireSuccessNonNilResponse(t, resp, err)
+	serverCert := parseCert(t, resp.Data["certificate"].(string))
+	estDecode(t, estRequest(t, b, s, ".well-known/est/other/simpleenroll", otherCSR, "", "", serverCert), http.StatusUnauthorized)
+	estDecode(t, estRequest(t, b, s, ".well-known/est/simplereenroll", csr, "", "", serverCert), http.StatusUnauthorized)
+
+	// Revoked certificates and those of untrusted CAs are rejected.
+
+	_, err = CBWrite(b, s, "revoke", map[string]interface{}{
+		"serial_number": serialFromCert(cert),
+	})
+	require.NoError(t, err)
+	resp = estRequest(t, b, s, ".well-known/est/simplereenroll", csr, "", "", cert)
+	estDecode(t, resp, http.StatusUnauthorized)
+	require.Contains(t, string(resp.Data[logical.HTTPRawBody].([]byte)), "invalid client certificate")
+	require.NotContains(t, string(resp.Data[logical.HTTPRawBody].([]byte)), "revoked")
+
+	otherB, otherS := CreateBackendWithStorage(t)
+	resp, err = CBWrite(otherB, otherS, "root/generate/internal", map[string]interface{}{
+		"common_name": "other root",
+		"key_type":    "ec",
+	})
+	requireSuccessNonNilResponse(t, resp, err)
+	resp, err = CBWrite(otherB, otherS, "roles/any", map[string]interface{}{
+		"allow_any_name": true,
+		"ttl":            "1h",
+	})
+	requireSuccessNonNilResponse(t, resp, err)
+	resp, err = CBWrite(otherB, otherS, "issue/any", map[string]interface{}{
+		"common_name": "router1.example.com",
+	})
+	requireSuccessNonNilResponse(t, resp, err)
+	foreign := parseCert(t, resp.Data["certificate"].(string))
+	estDecode(t, estRequest(t, b, s, ".well-known/est/simpleenroll", csr, "", "", foreign), http.StatusUnauthorized)
+
+	// Trusting the other CA allows its certificates to authenticate for the
+	// allowed roles.
+	_, err = CBWrite(b, s, "est/client-cas/other", map[string]interface{}{
+		"certificates":  resp.Data["issuing_ca"],
+		"allowed_roles": "devices",
+	})
+	require.NoError(t, err)
+	estCerts(t, estRequest(t, b, s, ".well-known/est/simpleenroll", csr, "", "", foreign))
+	estDecode(t, estRequest(t, b, s, ".well-known/est/other/simpleenroll", csr, "", "", foreign), http.StatusUnauthorized)
+
+	// Re-enrolling with a trusted certificate still keeps its subject.
+	estDecode(t, estRequest(t, b, s, ".well-known/est/simplereenroll", estCSR(t, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: "router2.example.com"},
+		DNSNames: []string{"router2.example.com"},
+	}), "", "", foreign), http.StatusBadRequest)
+
+	// Deleting the user revokes its access.
+	_, err = CBDelete(b, s, "est/users/router")
+	require.NoError(t, err)
+	estDecode(t, estRequest(t, b, s, ".well-known/est/simpleenroll", csr, "router", "hunter2", nil), http.StatusUnauthorized)
+}
@@ -0,0 +1,223 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package pki
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/strutil"
+	"github.com/hashicorp/vault/sdk/logical"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const estUserPrefix = "est/user/"
+
+type estUserEntry struct {
+	PasswordHash []byte   `json:"password_hash"`
+	AllowedRoles []string `json:"allowed_roles"`
+}
+
+// allowsRole reports whether the user may enroll against the named role; an
+// empty allowed_roles list permits every role EST is configured to serve.
+func (u *estUserEntry) allowsRole(role string) bool {
+	return len(u.AllowedRoles) == 0 || strutil.StrListContains(u.AllowedRoles, role)
+}
+
+func (sc *storageContext) getEstUser(username string) (*estUserEntry, error) {
+	entry, err := sc.Storage.Get(sc.Context, estUserPrefix+username)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var user estUserEntry
+	if err := entry.DecodeJSON(&user); err != nil {
+		return nil, fmt.Errorf("unable to decode EST user %q: %w", username, err)
+	}
+
+	return &user, nil
+}
+
+func pathListEstUsers(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "est/users/?$",
+
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixPKI,
+			OperationSuffix: "est-users",
+		},
+
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ListOperation: &framework.PathOperation{
+				Callback: b.pathEstUserList,
+			},
+		},
+
+		HelpSynopsis:    pathListEstUsersHelpSyn,
+		HelpDescription: pathListEstUsersHelpDesc,
+	}
+}
+
+func pathEstUsers(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "est/users/" + framework.GenericNameRegex("username"),
+
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixPKI,
+			OperationSuffix: "est-user",
+		},
+
+		Fields: map[string]*framework.FieldSchema{
+			"username": {
+				Type:        framework.TypeString,
+				Description: `Username the EST client presents in HTTP basic authentication`,
+				Required:    true,
+			},
+			"password": {
+				Type: framework.TypeString,
+				Description: `Password the EST client presents in HTTP basic
+authentication. Required when creating the user; only a bcrypt hash is stored.`,
+				DisplayAttrs: &framework.DisplayAttributes{
+					Sensitive: true,
+				},
+			},
+			"allowed_roles": {
+				Type: framework.TypeCommaStringSlice,
+				Description: `Roles this user may enroll against. If empty,
+the user may enroll against any role served over EST.`,
+			},
+		},
+
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ReadOperation: &framework.PathOperation{
+				Callback: b.pathEstUserRead,
+				Responses: map[int][]framework.Response{
+					http.StatusOK: {{
+						Description: "OK",
+						Fields: map[string]*framework.FieldSchema{
+							"allowed_roles": {
+								Type:        framework.TypeCommaStringSlice,
+								Description: `Roles this user may enroll against`,
+								Required:    true,
+							},
+						},
+					}},
+				},
+			},
+			logical.UpdateOperation: &framework.PathOperation{
+				Callback: b.pathEstUserWrite,
+				Responses: map[int][]framework.Response{
+					http.StatusNoContent: {{
+						Description: "No Content",
+					}},
+				},
+				// Read more about why these flags are set in backend.go.
+				ForwardPerformanceStandby:   true,
+				ForwardPerformanceSecondary: true,
+			},
+			logical.DeleteOperation: &framework.PathOperation{
+				Callback: b.pathEstUserDelete,
+				Responses: map[int][]framework.Response{
+					http.StatusNoContent: {{
+						Description: "No Content",
+					}},
+				},
+				// Read more about why these flags are set in backend.go.
+				ForwardPerformanceStandby:   true,
+				ForwardPerformanceSecondary: true,
+			},
+		},
+
+		HelpSynopsis:    pathEstUsersHelpSyn,
+		HelpDescription: pathEstUsersHelpDesc,
+	}
+}
+
+func (b *backend) pathEstUserList(ctx context.Context, req *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
+	entries, err := req.Storage.List(ctx, estUserPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	return logical.ListResponse(entries), nil
+}
+
+func (b *backend) pathEstUserRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	sc := b.makeStorageContext(ctx, req.Storage)
+	user, err := sc.getEstUser(data.Get("username").(string))
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"allowed_roles": user.AllowedRoles,
+		},
+	}, nil
+}
+
+func (b *backend) pathEstUserWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	username := data.Get("username").(string)
+
+	sc := b.makeStorageContext(ctx, req.Storage)
+	user, err := sc.getEstUser(username)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		user = &estUserEntry{AllowedRoles: []string{}}
+	}
+
+	if allowedRolesRaw, ok := data.GetOk("allowed_roles"); ok {
+		user.AllowedRoles = allowedRolesRaw.([]string)
+	}
+
+	if password := data.Get("password").(string); password != "" {
+		user.PasswordHash, err = bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(user.PasswordHash) == 0 {
+		return logical.ErrorResponse("missing password"), nil
+	}
+
+	entry, err := logical.StorageEntryJSON(estUserPrefix+username, user)
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Put(ctx, entry); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (b *backend) pathEstUserDelete(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	if err := req.Storage.Delete(ctx, estUserPrefix+data.Get("username").(string)); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+const pathListEstUsersHelpSyn = `List the users allowed to authenticate to the EST endpoints.`
+
+const pathListEstUsersHelpDesc = `This endpoint lists the usernames EST clients may present in HTTP basic authentication.`
+
+const pathEstUsersHelpSyn = `Manage a user allowed to authenticate to the EST endpoints.`
+
+const pathEstUsersHelpDesc = `
+This endpoint manages a username and password which EST clients may present
+in HTTP basic authentication to the simpleenroll and simplereenroll
+endpoints, optionally restricted to a set of roles.
+`
@@ -198,6 +198,17 @@ for the CRL distribution points attribute. See also RFC 5280 Section 4.2.1.13.`,
 		Description: `Comma-separated list of URLs to be used
 for the OCSP servers attribute. See also RFC 5280 Section 4.2.2.1.`,
 	}
+	fields["crl_partitions"] = &framework.FieldSchema{
+		Type: framework.TypeInt,
+		Description: `Number of partitions to split this issuer's CRL into,
+by hash of each revoked certificate's serial number, in addition to the full
+CRL. Partitions are only built and referenced when a templated CRL distribution
+point contains '{{crl_partition}}', which is replaced in issued certificates
+with the partition they are listed on. Zero (the default) disables
+partitioning. Once set, this cannot be changed, as issued certificates refer
+to the partition they are listed on.`,
+		Default: 0,
+	}
 	fields["enable_aia_url_templating"] = &framework.FieldSchema{
 		Type: framework.TypeBool,
 		Description: `Whether or not to enabling templating of the
@@ -206,7 +217,8 @@ above AIA fields. When templating is enabled the special values '{{issuer_id}}',
 not checked for URL validity until issuance time. Using '{{cluster_path}}'
 requires /config/cluster's 'path' member to be set on all PR Secondary clusters
 and using '{{cluster_aia_path}}' requires /config/cluster's 'aia_path' member
-to be set on all PR secondary clusters.`,
+to be set on all PR secondary clusters. When crl_partitions is set,
+'{{crl_partition}}' is also available in crl_distribution_points.`,
 		Default: false,
 	}
 
@@ -292,6 +304,11 @@ to be set on all PR secondary clusters.`,
 					Description: `Whether or not templating is enabled for AIA fields`,
 					Required:    false,
 				},
+				"crl_partitions": {
+					Type:        framework.TypeInt,
+					Description: `Number of partitions this issuer's CRL is split into`,
+					Required:    false,
+				},
 			},
 		}},
 	}
@@ -453,6 +470,7 @@ func respondReadIssuer(issuer *issuing.IssuerEntry) (*logical.Response, error) {
 		"issuing_certificates":           []string{},
 		"crl_distribution_points":        []string{},
 		"ocsp_servers":                   []string{},
+		"crl_partitions":                 issuer.CRLPartitions,
 	}
 
 	if issuer.Revoked {
@@ -478,6 +496,10 @@ func respondReadIssuer(issuer *issuing.IssuerEntry) (*logical.Response, error) {
 	return response, nil
 }
 
+// Issued certificates name the CRL partition they are listed on, which
+// depends on the partition count; changing it would move them to another.
+const crlPartitionsImmutableError = "crl_partitions cannot be changed once set, as issued certificates refer to the CRL partition they are listed on"
+
 func (b *backend) pathUpdateIssuer(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
 	// Since we're planning on updating issuers here, grab the lock so we've
 	// got a consistent view.
@@ -575,6 +597,17 @@ func (b *backend) pathUpdateIssuer(ctx context.Context, req *logical.Request, da
 		return logical.ErrorResponse(fmt.Sprintf("invalid URL found in Authority Information Access (AIA) parameter ocsp_servers: %s", badURL)), nil
 	}
 
+	crlPartitions := issuer.CRLPartitions
+	if rawCRLPartitions, ok := data.GetOk("crl_partitions"); ok {
+		crlPartitions = rawCRLPartitions.(int)
+	}
+	if crlPartitions < 0 {
+		return logical.ErrorResponse("crl_partitions must be zero or positive"), nil
+	}
+	if issuer.CRLPartitions != 0 && crlPartitions != issuer.CRLPartitions {
+		return logical.ErrorResponse(crlPartitionsImmutableError), nil
+	}
+
 	modified := false
 
 	var oldName string
@@ -618,6 +651,12 @@ func (b *backend) pathUpdateIssuer(ctx context.Context, req *logical.Request, da
 		modified = true
 	}
 
+	if crlPartitions != issuer.CRLPartitions {
+		issuer.CRLPartitions = crlPartitions
+		b.CrlBuilder().requestRebuildIfActiveNode(b)
+		modified = true
+	}
+
 	if issuer.AIAURIs == nil && (len(issuerCertificates) > 0 || len(crlDistributionPoints) > 0 || len(ocspServers) > 0) {
 		issuer.AIAURIs = &issuing.AiaConfigEntry{}
 	}
@@ -863,6 +902,24 @@ func (b *backend) pathPatchIssuer(ctx context.Context, req *logical.Request, dat
 		}
 	}
 
+	// CRL partition changes
+	rawCRLPartitions, ok := data.GetOk("crl_partitions")
+	if ok {
+		crlPartitions := rawCRLPartitions.(int)
+		if crlPartitions < 0 {
+			return logical.ErrorResponse("crl_partitions must be zero or positive"), nil
+		}
+		if issuer.CRLPartitions != 0 && crlPartitions != issuer.CRLPartitions {
+			return logical.ErrorResponse(crlPartitionsImmutableError), nil
+		}
+
+		if crlPartitions != issuer.CRLPartitions {
+			issuer.CRLPartitions = crlPartitions
+			b.CrlBuilder().requestRebuildIfActiveNode(b)
+			modified = true
+		}
+	}
+
 	// AIA access changes.
 	if issuer.AIAURIs == nil {
 		issuer.AIAURIs = &issuing.AiaConfigEntry{}
@@ -1188,6 +1245,23 @@ func pathGetIssuerUnifiedCRL(b *backend) *framework.Path {
 	return buildPathGetIssuerCRL(b, pattern, displayAttrs)
 }
 
+func pathGetIssuerCRLPartition(b *backend) *framework.Path {
+	pattern := "issuer/" + framework.GenericNameRegex(issuerRefParam) + "/crl/partition/(?P<partition>[0-9]+)(/pem|/der|/delta(/pem|/der)?)?"
+
+	displayAttrs := &framework.DisplayAttributes{
+		OperationPrefix: operationPrefixPKIIssuer,
+		OperationSuffix: "crl-partition|crl-partition-pem|crl-partition-der|crl-partition-delta|crl-partition-delta-pem|crl-partition-delta-der",
+	}
+
+	path := buildPathGetIssuerCRL(b, pattern, displayAttrs)
+	path.Fields["partition"] = &framework.FieldSchema{
+		Type:        framework.TypeInt,
+		Description: `Index of the CRL partition to fetch.`,
+		Required:    true,
+	}
+	return path
+}
+
 func buildPathGetIssuerCRL(b *backend, pattern string, displayAttrs *framework.DisplayAttributes) *framework.Path {
 	fields := map[string]*framework.FieldSchema{}
 	fields = addIssuerRefNameFields(fields)
@@ -1271,7 +1345,12 @@ func (b *backend) pathGetIssuerCRL(ctx context.Context, req *logical.Request, da
 		return response, nil
 	}
 
-	crlPath, err := sc.resolveIssuerCRLPath(issuerName, isUnified)
+	var crlPath string
+	if partition, ok := data.GetOk("partition"); ok {
+		crlPath, err = sc.resolveIssuerCRLPartitionPath(issuerName, partition.(int))
+	} else {
+		crlPath, err = sc.resolveIssuerCRLPath(issuerName, isUnified)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -1347,5 +1426,9 @@ or its assigned name value.
  - /issuer/:ref/crl is JSON encoded and contains a PEM CRL,
  - /issuer/:ref/crl/pem contains the PEM-encoded CRL,
  - /issuer/:ref/crl/DER contains the raw DER-encoded (binary) CRL.
+
+When the issuer's CRL is partitioned (see crl_partitions on /issuer/:ref),
+/issuer/:ref/crl/partition/:partition returns a single partition, in the same
+formats. Partitions are returned empty until the next CRL rebuild.
 `
 )
@@ -378,7 +378,7 @@ func (b *backend) pathIssueSignCert(ctx context.Context, req *logical.Request, d
 
 	var caErr error
 	sc := b.makeStorageContext(ctx, req.Storage)
-	signingBundle, caErr := sc.fetchCAInfo(issuerName, issuing.IssuanceUsage)
+	signingBundle, issuerId, caErr := sc.fetchCAInfoWithIssuer(issuerName, issuing.IssuanceUsage)
 	if caErr != nil {
 		switch caErr.(type) {
 		case errutil.UserError:
@@ -403,6 +403,11 @@ func (b *backend) pathIssueSignCert(ctx context.Context, req *logical.Request, d
 	} else {
 		parsedBundle, warnings, err = generateCert(sc, input, signingBundle, false, rand.Reader)
 	}
+	if err == nil {
+		var ctWarnings []string
+		ctWarnings, err = issuing.AddSignedCertificateTimestamps(ctx, req.Storage, role, signingBundle, parsedBundle)
+		warnings = append(warnings, ctWarnings...)
+	}
 	if err != nil {
 		switch err.(type) {
 		case errutil.UserError:
@@ -425,11 +430,12 @@ func (b *backend) pathIssueSignCert(ctx context.Context, req *logical.Request, d
 	}
 
 	if !role.NoStore {
-		err = issuing.StoreCertificate(ctx, req.Storage, b.GetCertificateCounter(), parsedBundle)
+		err = sc.storeCertificate(parsedBundle, issuerId, role.Name)
 		if err != nil {
 			return nil, err
 		}
 	}
+	b.issueEvent(ctx, parsedBundle.Certificate, issuerId, role.Name, !role.NoStore)
 
 	if useCSR {
 		if role.UseCSRCommonName && data.Get("common_name").(string) != "" {
@@ -0,0 +1,889 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package pki
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/hashicorp/vault/builtin/logical/pki/issuing"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/certutil"
+	"github.com/hashicorp/vault/sdk/helper/errutil"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	issuerRotationPrefix = "config/issuer-rotation/"
+
+	defaultRotationLeadTime      = 30 * 24 * time.Hour
+	defaultRotationOverlapPeriod = 7 * 24 * time.Hour
+
+	// An issuer is waiting until it is due for rotation; creating its
+	// successor until the successor is cross-signed and on the CRLs; in its
+	// overlap period once the successor has been created; and rotated once
+	// the successor has replaced it.
+	issuerRotationWaiting  = "waiting"
+	issuerRotationCreating = "creating"
+	issuerRotationOverlap  = "overlap"
+	issuerRotationRotated  = "rotated"
+)
+
+// issuerRotationEntry is the rotation configuration of an issuer, along with
+// the status of its rotation.
+type issuerRotationEntry struct {
+	Enabled         bool          `json:"enabled"`
+	LeadTime        time.Duration `json:"lead_time"`
+	OverlapPeriod   time.Duration `json:"overlap_period"`
+	KeyType         string        `json:"key_type"`
+	KeyBits         int           `json:"key_bits"`
+	ParentIssuerRef string        `json:"parent_issuer_ref"`
+	TTL             time.Duration `json:"ttl"`
+
+	State               string           `json:"state"`
+	SuccessorIssuerID   issuing.IssuerID `json:"successor_issuer_id"`
+	CrossSignedIssuerID issuing.IssuerID `json:"cross_signed_issuer_id"`
+	RotationStarted     time.Time        `json:"rotation_started"`
+	RotationCompleted   time.Time        `json:"rotation_completed"`
+	LastError           string           `json:"last_error"`
+}
+
+func (sc *storageContext) fetchIssuerRotation(issuerId issuing.IssuerID) (*issuerRotationEntry, error) {
+	entry, err := sc.Storage.Get(sc.Context, issuerRotationPrefix+issuerId.String())
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var rotation issuerRotationEntry
+	if err := entry.DecodeJSON(&rotation); err != nil {
+		return nil, errutil.InternalError{Err: fmt.Sprintf("unable to decode rotation configuration of issuer %v: %v", issuerId, err)}
+	}
+
+	return &rotation, nil
+}
+
+func (sc *storageContext) writeIssuerRotation(issuerId issuing.IssuerID, rotation *issuerRotationEntry) error {
+	entry, err := logical.StorageEntryJSON(issuerRotationPrefix+issuerId.String(), rotation)
+	if err != nil {
+		return err
+	}
+
+	return sc.Storage.Put(sc.Context, entry)
+}
+
+func (sc *storageContext) deleteIssuerRotation(issuerId issuing.IssuerID) error {
+	return sc.Storage.Delete(sc.Context, issuerRotationPrefix+issuerId.String())
+}
+
+func pathIssuerRotation(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "issuer/" + framework.GenericNameRegex(issuerRefParam) + "/rotation$",
+
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixPKIIssuer,
+		},
+
+		Fields: map[string]*framework.FieldSchema{
+			issuerRefParam: {
+				Type:        framework.TypeString,
+				Description: `Reference to an existing issuer name or issuer id.`,
+				Required:    true,
+			},
+			"enabled": {
+				Type:        framework.TypeBool,
+				Description: `Whether the issuer is rotated automatically; defaults to true.`,
+				Default:     true,
+			},
+			"lead_time": {
+				Type: framework.TypeDurationSecond,
+				Description: `How long before the issuer expires to create its
+successor; defaults to 720h.`,
+				Default: int(defaultRotationLeadTime / time.Second),
+			},
+			"overlap_period": {
+				Type: framework.TypeDurationSecond,
+				Description: `How long after creating the successor to
+replace the issuer with it, as the default issuer and under its name;
+defaults to 168h.`,
+				Default: int(defaultRotationOverlapPeriod / time.Second),
+			},
+			"key_type": {
+				Type: framework.TypeString,
+				Description: `The type of key to generate for the successor;
+defaults to the type of the issuer's key.`,
+			},
+			"key_bits": {
+				Type: framework.TypeInt,
+				Description: `The number of bits of the successor's key;
+defaults to the size of the issuer's key when key_type is not set, or to
+the default of key_type.`,
+			},
+			"parent_issuer_ref": {
+				Type: framework.TypeString,
+				Description: `The issuer to sign the successor with. When not
+set, the issuer must be a root, and its successor is a new root which the
+issuer cross-signs.`,
+			},
+			"ttl": {
+				Type: framework.TypeDurationSecond,
+				Description: `The validity period of the successor; defaults
+to that of the issuer.`,
+			},
+		},
+
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ReadOperation: &framework.PathOperation{
+				Callback: b.pathIssuerRotationRead,
+				DisplayAttrs: &framework.DisplayAttributes{
+					OperationVerb:   "read",
+					OperationSuffix: "rotation",
+				},
+				Responses: map[int][]framework.Response{
+					http.StatusOK: {{
+						Description: "OK",
+						Fields:      issuerRotationResponseFields,
+					}},
+				},
+			},
+			logical.UpdateOperation: &framework.PathOperation{
+				Callback: b.pathIssuerRotationWrite,
+				DisplayAttrs: &framework.DisplayAttributes{
+					OperationVerb:   "configure",
+					OperationSuffix: "rotation",
+				},
+				Responses: map[int][]framework.Response{
+					http.StatusOK: {{
+						Description: "OK",
+						Fields:      issuerRotationResponseFields,
+					}},
+				},
+				// Read more about why these flags are set in backend.go.
+				ForwardPerformanceStandby:   true,
+				ForwardPerformanceSecondary: true,
+			},
+			logical.DeleteOperation: &framework.PathOperation{
+				Callback: b.pathIssuerRotationDelete,
+				DisplayAttrs: &framework.DisplayAttributes{
+					OperationVerb:   "delete",
+					OperationSuffix: "rotation",
+				},
+				Responses: map[int][]framework.Response{
+					http.StatusNoContent: {{
+						Description: "No Content",
+					}},
+				},
+				// Read more about why these flags are set in backend.go.
+				ForwardPerformanceStandby:   true,
+				ForwardPerformanceSecondary: true,
+			},
+		},
+
+		HelpSynopsis:    pathIssuerRotationHelpSyn,
+		HelpDescription: pathIssuerRotationHelpDesc,
+	}
+}
+
+var issuerRotationResponseFields = map[string]*framework.FieldSchema{
+	"issuer_id": {
+		Type:        framework.TypeString,
+		Description: `Issuer Id`,
+		Required:    true,
+	},
+	"enabled": {
+		Type:        framework.TypeBool,
+		Description: `Whether the issuer is rotated automatically`,
+		Required:    true,
+	},
+	"lead_time": {
+		Type:        framework.TypeDurationSecond,
+		Description: `How long before the issuer expires to create its successor`,
+		Required:    true,
+	},
+	"overlap_period": {
+		Type:        framework.TypeDurationSecond,
+		Description: `How long after creating the successor to replace the issuer with it`,
+		Required:    true,
+	},
+	"key_type": {
+		Type:        framework.TypeString,
+		Description: `The type of key to generate for the successor`,
+		Required:    true,
+	},
+	"key_bits": {
+		Type:        framework.TypeInt,
+		Description: `The number of bits of the successor's key`,
+		Required:    true,
+	},
+	"parent_issuer_ref": {
+		Type:        framework.TypeString,
+		Description: `The issuer to sign the successor with`,
+		Required:    true,
+	},
+	"ttl": {
+		Type:        framework.TypeDurationSecond,
+		Description: `The validity period of the successor`,
+		Required:    true,
+	},
+	"state": {
+		Type:        framework.TypeString,
+		Description: `The state of the rotation: waiting, creating, overlap or rotated`,
+		Required:    true,
+	},
+	"rotation_due": {
+		Type:        framework.TypeString,
+		Description: `When the successor is due to be created`,
+		Required:    true,
+	},
+	"successor_issuer_id": {
+		Type:        framework.TypeString,
+		Description: `The successor created for the issuer`,
+		Required:    true,
+	},
+	"cross_signed_issuer_id": {
+		Type:        framework.TypeString,
+		Description: `The successor as cross-signed by the issuer, for roots`,
+		Required:    true,
+	},
+	"rotation_started": {
+		Type:        framework.TypeString,
+		Description: `When the successor was created`,
+		Required:    true,
+	},
+	"rotation_completed": {
+		Type:        framework.TypeString,
+		Description: `When the successor replaced the issuer`,
+		Required:    true,
+	},
+	"last_error": {
+		Type:        framework.TypeString,
+		Description: `The error of the last failed rotation step`,
+		Required:    true,
+	},
+}
+
+func (b *backend) pathIssuerRotationRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	if b.UseLegacyBundleCaStorage() {
+		return logical.ErrorResponse("Can not read issuer rotation until migration has completed"), nil
+	}
+
+	sc := b.makeStorageContext(ctx, req.Storage)
+	issuer, errResp, err := sc.fetchIssuerForRotation(GetIssuerRef(data))
+	if errResp != nil || err != nil {
+		return errResp, err
+	}
+
+	rotation, err := sc.fetchIssuerRotation(issuer.ID)
+	if err != nil {
+		return nil, err
+	}
+	if rotation == nil {
+		return nil, nil
+	}
+
+	return issuerRotationResponse(issuer, rotation)
+}
+
+func (b *backend) pathIssuerRotationWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	if b.UseLegacyBundleCaStorage() {
+		return logical.ErrorResponse("Can not configure issuer rotation until migration has completed"), nil
+	}
+
+	sc := b.makeStorageContext(ctx, req.Storage)
+	issuer, errResp, err := sc.fetchIssuerForRotation(GetIssuerRef(data))
+	if errResp != nil || err != nil {
+		return errResp, err
+	}
+	issuerCert, err := issuer.GetCertificate()
+	if err != nil {
+		return nil, err
+	}
+
+	rotation, err := sc.fetchIssuerRotation(issuer.ID)
+	if err != nil {
+		return nil, err
+	}
+	if rotation == nil {
+		rotation = &issuerRotationEntry{
+			Enabled:       true,
+			LeadTime:      defaultRotationLeadTime,
+			OverlapPeriod: defaultRotationOverlapPeriod,
+			State:         issuerRotationWaiting,
+		}
+	}
+
+	if enabledRaw, ok := data.GetOk("enabled"); ok {
+		rotation.Enabled = enabledRaw.(bool)
+	}
+	if leadTimeRaw, ok := data.GetOk("lead_time"); ok {
+		rotation.LeadTime = time.Duration(leadTimeRaw.(int)) * time.Second
+	}
+	if overlapRaw, ok := data.GetOk("overlap_period"); ok {
+		rotation.OverlapPeriod = time.Duration(overlapRaw.(int)) * time.Second
+	}
+	if keyTypeRaw, ok := data.GetOk("key_type"); ok {
+		rotation.KeyType = keyTypeRaw.(string)
+	}
+	if keyBitsRaw, ok := data.GetOk("key_bits"); ok {
+		rotation.KeyBits = keyBitsRaw.(int)
+	}
+	if parentRaw, ok := data.GetOk("parent_issuer_ref"); ok {
+		rotation.ParentIssuerRef = parentRaw.(string)
+	}
+	if ttlRaw, ok := data.GetOk("ttl"); ok {
+		rotation.TTL = time.Duration(ttlRaw.(int)) * time.Second
+	}
+
+	if rotation.LeadTime <= 0 {
+		return logical.ErrorResponse("lead_time must be positive"), nil
+	}
+	if rotation.OverlapPeriod < 0 {
+		return logical.ErrorResponse("overlap_period must not be negative"), nil
+	}
+	if rotation.TTL < 0 {
+		return logical.ErrorResponse("ttl must not be negative"), nil
+	}
+
+	if rotation.KeyType == "" {
+		if rotation.KeyBits != 0 {
+			return logical.ErrorResponse("key_bits requires key_type to be set"), nil
+		}
+	} else if _, _, err := certutil.ValidateDefaultOrValueKeyTypeSignatureLength(rotation.KeyType, rotation.KeyBits, 0); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	validity := rotation.TTL
+	if validity == 0 {
+		validity = issuerCert.NotAfter.Sub(issuerCert.NotBefore)
+	}
+	if validity <= rotation.LeadTime+rotation.OverlapPeriod {
+		return logical.ErrorResponse("the successor's validity period of %v must exceed lead_time and overlap_period combined, or it would be due for rotation before replacing this issuer", validity), nil
+	}
+
+	var warnings []string
+	if rotation.ParentIssuerRef == "" {
+		if !isSelfSigned(issuerCert) {
+			return logical.ErrorResponse("issuer %v is not a root; set parent_issuer_ref to the issuer to sign its successor with", issuer.ID), nil
+		}
+		if issuer.KeyID == "" {
+			warnings = append(warnings, "this root has no key, so it cannot cross-sign its successor")
+		}
+	} else {
+		parentId, err := sc.resolveIssuerReference(rotation.ParentIssuerRef)
+		if err != nil {
+			if parentId == issuing.IssuerRefNotFound {
+				return logical.ErrorResponse("unable to find parent issuer %q", rotation.ParentIssuerRef), nil
+			}
+			return nil, err
+		}
+		if parentId == issuer.ID {
+			return logical.ErrorResponse("parent_issuer_ref must refer to an issuer other than the one being rotated"), nil
+		}
+		if _, err := sc.fetchCAInfoByIssuerId(parentId, issuing.IssuanceUsage); err != nil {
+			return logical.ErrorResponse("unable to sign the successor with parent issuer %q: %v", rotation.ParentIssuerRef, err), nil
+		}
+	}
+
+	rotation.LastError = ""
+	if err := sc.writeIssuerRotation(issuer.ID, rotation); err != nil {
+		return nil, err
+	}
+
+	resp, err := issuerRotationResponse(issuer, rotation)
+	if err != nil {
+		return nil, err
+	}
+	for _, warning := range warnings {
+		resp.AddWarning(warning)
+	}
+	return resp, nil
+}
+
+func (b *backend) pathIssuerRotationDelete(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	if b.UseLegacyBundleCaStorage() {
+		return logical.ErrorResponse("Can not delete issuer rotation until migration has completed"), nil
+	}
+
+	sc := b.makeStorageContext(ctx, req.Storage)
+	issuerId, err := sc.resolveIssuerReference(GetIssuerRef(data))
+	if err != nil {
+		if issuerId == issuing.IssuerRefNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return nil, sc.deleteIssuerRotation(issuerId)
+}
+
+func (sc *storageContext) fetchIssuerForRotation(issuerRef string) (*issuing.IssuerEntry, *logical.Response, error) {
+	if len(issuerRef) == 0 {
+		return nil, logical.ErrorResponse("missing issuer reference"), nil
+	}
+
+	issuerId, err := sc.resolveIssuerReference(issuerRef)
+	if err != nil {
+		if issuerId == issuing.IssuerRefNotFound {
+			return nil, logical.ErrorResponse("unable to find issuer %q", issuerRef), nil
+		}
+		return nil, nil, err
+	}
+
+	issuer, err := sc.fetchIssuerById(issuerId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return issuer, nil, nil
+}
+
+func issuerRotationResponse(issuer *issuing.IssuerEntry, rotation *issuerRotationEntry) (*logical.Response, error) {
+	issuerCert, err := issuer.GetCertificate()
+	if err != nil {
+		return nil, err
+	}
+
+	formatTime := func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.Format(time.RFC3339)
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"issuer_id":              issuer.ID.String(),
+			"enabled":                rotation.Enabled,
+			"lead_time":              int64(rotation.LeadTime.Seconds()),
+			"overlap_period":         int64(rotation.OverlapPeriod.Seconds()),
+			"key_type":               rotation.KeyType,
+			"key_bits":               rotation.KeyBits,
+			"parent_issuer_ref":      rotation.ParentIssuerRef,
+			"ttl":                    int64(rotation.TTL.Seconds()),
+			"state":                  rotation.State,
+			"rotation_due":           formatTime(issuerCert.NotAfter.Add(-rotation.LeadTime)),
+			"successor_issuer_id":    rotation.SuccessorIssuerID.String(),
+			"cross_signed_issuer_id": rotation.CrossSignedIssuerID.String(),
+			"rotation_started":       formatTime(rotation.RotationStarted),
+			"rotation_completed":     formatTime(rotation.RotationCompleted),
+			"last_error":             rotation.LastError,
+		},
+	}, nil
+}
+
+func isSelfSigned(cert *x509.Certificate) bool {
+	return bytes.Equal(cert.RawSubject, cert.RawIssuer) && cert.CheckSignatureFrom(cert) == nil
+}
+
+// stepIssuerRotation advances the rotation of the given issuer as far as is
+// due at the given time: creating its successor once within lead_time of its
+// expiry, and replacing it with the successor once overlap_period has
+// passed. Callers must hold the issuers lock.
+func (sc *storageContext) stepIssuerRotation(issuerId issuing.IssuerID, rotation *issuerRotationEntry, now time.Time) error {
+	issuer, err := sc.fetchIssuerById(issuerId)
+	if err != nil {
+		return err
+	}
+	issuerCert, err := issuer.GetCertificate()
+	if err != nil {
+		return err
+	}
+
+	if rotation.State == issuerRotationWaiting {
+		if now.Before(issuerCert.NotAfter.Add(-rotation.LeadTime)) {
+			return nil
+		}
+
+		successor, err := sc.createSuccessorIssuer(issuer, issuerCert, rotation)
+		if err != nil {
+			return err
+		}
+
+		// Record the successor right away, so that should completing it
+		// fail, the next run resumes with it rather than creating another.
+		rotation.State = issuerRotationCreating
+		rotation.SuccessorIssuerID = successor.ID
+		rotation.CrossSignedIssuerID = ""
+		if err := sc.writeIssuerRotation(issuer.ID, rotation); err != nil {
+			return err
+		}
+	}
+
+	if rotation.State == issuerRotationCreating {
+		successor, err := sc.fetchIssuerById(rotation.SuccessorIssuerID)
+		if _, missing := err.(errutil.UserError); missing {
+			// The successor was removed before it was completed; start
+			// over with a new one.
+			rotation.State = issuerRotationWaiting
+			rotation.SuccessorIssuerID = ""
+			rotation.CrossSignedIssuerID = ""
+			if writeErr := sc.writeIssuerRotation(issuer.ID, rotation); writeErr != nil {
+				return writeErr
+			}
+			return fmt.Errorf("unable to load successor issuer: %w", err)
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := sc.completeSuccessorIssuer(issuer, successor, rotation); err != nil {
+			return err
+		}
+
+		rotation.State = issuerRotationOverlap
+		rotation.RotationStarted = now
+		rotation.LastError = ""
+		if err := sc.writeIssuerRotation(issuer.ID, rotation); err != nil {
+			return err
+		}
+
+		sc.Backend.pkiEvent(sc.Context, "issuer-rotated",
+			logical.EventMetadataModified, "true",
+			logical.EventMetadataDataPath, "issuer/"+successor.ID.String(),
+			"issuer_id", issuer.ID.String(),
+			"successor_issuer_id", successor.ID.String(),
+			"cross_signed_issuer_id", rotation.CrossSignedIssuerID.String())
+	}
+
+	if rotation.State == issuerRotationOverlap {
+		if now.Before(rotation.RotationStarted.Add(rotation.OverlapPeriod)) {
+			return nil
+		}
+
+		successor, err := sc.fetchIssuerById(rotation.SuccessorIssuerID)
+		if err != nil {
+			// The successor was removed during the overlap period; start
+			// over with a new one.
+			rotation.State = issuerRotationWaiting
+			rotation.SuccessorIssuerID = ""
+			rotation.CrossSignedIssuerID = ""
+			if writeErr := sc.writeIssuerRotation(issuer.ID, rotation); writeErr != nil {
+				return writeErr
+			}
+			return fmt.Errorf("unable to load successor issuer: %w", err)
+		}
+
+		if err := sc.replaceIssuerWithSuccessor(issuer, issuerCert, successor); err != nil {
+			return err
+		}
+
+		// The successor takes over the rotation of the issuer.
+		next := *rotation
+		next.State = issuerRotationWaiting
+		next.SuccessorIssuerID = ""
+		next.CrossSignedIssuerID = ""
+		next.RotationStarted = time.Time{}
+		next.RotationCompleted = time.Time{}
+		next.LastError = ""
+		if err := sc.writeIssuerRotation(successor.ID, &next); err != nil {
+			return err
+		}
+
+		rotation.State = issuerRotationRotated
+		rotation.RotationCompleted = now
+		rotation.LastError = ""
+		if err := sc.writeIssuerRotation(issuer.ID, rotation); err != nil {
+			return err
+		}
+
+		sc.Backend.pkiEvent(sc.Context, "issuer-rotation-completed",
+			logical.EventMetadataModified, "true",
+			logical.EventMetadataDataPath, "issuer/"+successor.ID.String(),
+			"issuer_id", issuer.ID.String(),
+			"successor_issuer_id", successor.ID.String())
+	}
+
+	return nil
+}
+
+// createSuccessorIssuer generates a new key and certificate with the subject
+// of the given issuer, signed by the configured parent issuer or, for roots,
+// self-signed.
+func (sc *storageContext) createSuccessorIssuer(issuer *issuing.IssuerEntry, issuerCert *x509.Certificate, rotation *issuerRotationEntry) (*issuing.IssuerEntry, error) {
+	b := sc.Backend
+
+	keyType, keyBits := rotation.KeyType, rotation.KeyBits
+	if keyType == "" {
+		var err error
+		keyType, keyBits, err = publicKeyTypeAndBits(issuerCert.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	successorBundle := &certutil.ParsedCertBundle{}
+	if err := certutil.GeneratePrivateKeyWithRandomSource(keyType, keyBits, successorBundle, b.GetRandomReader()); err != nil {
+		return nil, fmt.Errorf("error generating successor key: %w", err)
+	}
+
+	validity := rotation.TTL
+	if validity == 0 {
+		validity = issuerCert.NotAfter.Sub(issuerCert.NotBefore)
+	}
+	template, err := successorTemplate(issuerCert, successorBundle.PrivateKey, time.Now().Add(validity))
+	if err != nil {
+		return nil, err
+	}
+
+	var parentId issuing.IssuerID
+	var successorDER []byte
+	if rotation.ParentIssuerRef != "" {
+		parentId, err = sc.resolveIssuerReference(rotation.ParentIssuerRef)
+		if err != nil {
+			return nil, fmt.Errorf("unable to resolve parent issuer %q: %w", rotation.ParentIssuerRef, err)
+		}
+		parent, err := sc.fetchCAInfoByIssuerId(parentId, issuing.IssuanceUsage)
+		if err != nil {
+			return nil, fmt.Errorf("unable to sign with parent issuer %q: %w", rotation.ParentIssuerRef, err)
+		}
+
+		setTemplateURLs(template, parent)
+		if template.NotAfter.After(parent.Certificate.NotAfter) {
+			template.NotAfter = parent.Certificate.NotAfter
+		}
+		template.SignatureAlgorithm = parent.RevocationSigAlg
+		successorDER, err = x509.CreateCertificate(b.GetRandomReader(), template, parent.Certificate, successorBundle.PrivateKey.Public(), parent.PrivateKey)
+	} else {
+		successorDER, err = x509.CreateCertificate(b.GetRandomReader(), template, template, successorBundle.PrivateKey.Public(), successorBundle.PrivateKey)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to create successor certificate: %w", err)
+	}
+
+	successorBundle.CertificateBytes = successorDER
+	successorBundle.Certificate, err = x509.ParseCertificate(successorDER)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse successor certificate: %w", err)
+	}
+	cb, err := successorBundle.ToCertBundle()
+	if err != nil {
+		return nil, fmt.Errorf("error converting raw cert bundle to cert bundle: %w", err)
+	}
+
+	successor, _, err := sc.writeCaBundle(cb, "", "")
+	if err != nil {
+		return nil, err
+	}
+	successor.Usage = issuer.Usage
+	successor.LeafNotAfterBehavior = issuer.LeafNotAfterBehavior
+	successor.AIAURIs = issuer.AIAURIs
+	if parentId == "" {
+		successor.RevocationSigAlg = successorBundle.Certificate.SignatureAlgorithm
+		parentId = successor.ID
+	}
+	if err := sc.writeIssuer(successor); err != nil {
+		return nil, err
+	}
+	if err := sc.storeCertificate(successorBundle, parentId, ""); err != nil {
+		return nil, err
+	}
+
+	return successor, nil
+}
+
+// completeSuccessorIssuer has the old root cross-sign its successor, so that
+// clients which only trust the old root can still validate leaves issued by
+// the new one, and rebuilds the CRLs to include the successor's. The
+// cross-signed issuer is recorded on the rotation as soon as it is created,
+// so that retries don't create another.
+func (sc *storageContext) completeSuccessorIssuer(issuer *issuing.IssuerEntry, successor *issuing.IssuerEntry, rotation *issuerRotationEntry) error {
+	if rotation.ParentIssuerRef == "" && issuer.KeyID != "" && rotation.CrossSignedIssuerID == "" {
+		successorCert, err := successor.GetCertificate()
+		if err != nil {
+			return err
+		}
+		crossSigned, err := sc.crossSignSuccessor(issuer, successorCert)
+		if err != nil {
+			return err
+		}
+
+		rotation.CrossSignedIssuerID = crossSigned.ID
+		if err := sc.writeIssuerRotation(issuer.ID, rotation); err != nil {
+			return err
+		}
+	}
+
+	if _, err := sc.Backend.CrlBuilder().rebuild(sc, true); err != nil {
+		return fmt.Errorf("error rebuilding CRLs: %w", err)
+	}
+
+	return nil
+}
+
+// crossSignSuccessor signs the subject and key of a root's successor with
+// the root, up until the root itself expires. As the cross-signed issuer
+// shares its key with the successor, it does not issue leaves itself.
+func (sc *storageContext) crossSignSuccessor(issuer *issuing.IssuerEntry, successorCert *x509.Certificate) (*issuing.IssuerEntry, error) {
+	b := sc.Backend
+
+	signer, err := sc.fetchCAInfoByIssuerId(issuer.ID, issuing.ReadOnlyUsage)
+	if err != nil {
+		return nil, fmt.Errorf("unable to cross-sign with issuer %v: %w", issuer.ID, err)
+	}
+
+	serialNumber, err := certutil.GenerateSerialNumber()
+	if err != nil {
+		return nil, err
+	}
+	template := *successorCert
+	template.SerialNumber = serialNumber
+	template.AuthorityKeyId = nil
+	if template.NotAfter.After(signer.Certificate.NotAfter) {
+		template.NotAfter = signer.Certificate.NotAfter
+	}
+	template.SignatureAlgorithm = signer.RevocationSigAlg
+	setTemplateURLs(&template, signer)
+
+	crossDER, err := x509.CreateCertificate(b.GetRandomReader(), &template, signer.Certificate, successorCert.PublicKey, signer.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("unable to cross-sign successor: %w", err)
+	}
+	crossCert, err := x509.ParseCertificate(crossDER)
+	if err != nil {
+		return nil, err
+	}
+
+	crossSigned, _, err := sc.importIssuer(string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: crossDER})), "")
+	if err != nil {
+		return nil, err
+	}
+	crossSigned.Usage = issuer.Usage &^ issuing.IssuanceUsage
+	crossSigned.AIAURIs = issuer.AIAURIs
+	if err := sc.writeIssuer(crossSigned); err != nil {
+		return nil, err
+	}
+	if err := sc.storeCertificate(&certutil.ParsedCertBundle{Certificate: crossCert, CertificateBytes: crossDER}, issuer.ID, ""); err != nil {
+		return nil, err
+	}
+
+	return crossSigned, nil
+}
+
+// replaceIssuerWithSuccessor makes the successor the default issuer if the
+// issuer was, and moves the issuer's name to the successor, so that roles
+// referencing the issuer by name switch over to the successor as well. The
+// issuer keeps its name suffixed with its expiry date.
+func (sc *storageContext) replaceIssuerWithSuccessor(issuer *issuing.IssuerEntry, issuerCert *x509.Certificate, successor *issuing.IssuerEntry) error {
+	config, err := sc.getIssuersConfig()
+	if err != nil {
+		return err
+	}
+	if config.DefaultIssuerId == issuer.ID {
+		if err := sc.updateDefaultIssuerId(successor.ID); err != nil {
+			return err
+		}
+	}
+
+	if issuer.Name == "" || successor.Name != "" {
+		return nil
+	}
+
+	name := issuer.Name
+	retiredName := name + "-" + issuerCert.NotAfter.UTC().Format("20060102")
+	if _, err := sc.resolveIssuerReference(retiredName); err == nil || !nameMatcher.MatchString(retiredName) {
+		retiredName = ""
+	}
+
+	issuer.Name = retiredName
+	if err := sc.writeIssuer(issuer); err != nil {
+		return err
+	}
+	successor.Name = name
+	return sc.writeIssuer(successor)
+}
+
+func successorTemplate(issuerCert *x509.Certificate, key crypto.Signer, notAfter time.Time) (*x509.Certificate, error) {
+	serialNumber, err := certutil.GenerateSerialNumber()
+	if err != nil {
+		return nil, err
+	}
+	subjKeyID, err := certutil.GetSubjKeyID(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &x509.Certificate{
+		SerialNumber: serialNumber,
+		RawSubject:   issuerCert.RawSubject,
+		SubjectKeyId: subjKeyID,
+		NotBefore:    time.Now().Add(-30 * time.Second),
+		NotAfter:     notAfter,
+
+		KeyUsage:              issuerCert.KeyUsage,
+		ExtKeyUsage:           issuerCert.ExtKeyUsage,
+		UnknownExtKeyUsage:    issuerCert.UnknownExtKeyUsage,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLen:            issuerCert.MaxPathLen,
+		MaxPathLenZero:        issuerCert.MaxPathLenZero,
+
+		DNSNames:       issuerCert.DNSNames,
+		EmailAddresses: issuerCert.EmailAddresses,
+		IPAddresses:    issuerCert.IPAddresses,
+		URIs:           issuerCert.URIs,
+
+		PermittedDNSDomainsCritical: issuerCert.PermittedDNSDomainsCritical,
+		PermittedDNSDomains:         issuerCert.PermittedDNSDomains,
+		ExcludedDNSDomains:          issuerCert.ExcludedDNSDomains,
+		PermittedIPRanges:           issuerCert.PermittedIPRanges,
+		ExcludedIPRanges:            issuerCert.ExcludedIPRanges,
+		PermittedEmailAddresses:     issuerCert.PermittedEmailAddresses,
+		ExcludedEmailAddresses:      issuerCert.ExcludedEmailAddresses,
+		PermittedURIDomains:         issuerCert.PermittedURIDomains,
+		ExcludedURIDomains:          issuerCert.ExcludedURIDomains,
+		PolicyIdentifiers:           issuerCert.PolicyIdentifiers,
+
+		OCSPServer:            issuerCert.OCSPServer,
+		IssuingCertificateURL: issuerCert.IssuingCertificateURL,
+		CRLDistributionPoints: issuerCert.CRLDistributionPoints,
+	}, nil
+}
+
+func setTemplateURLs(template *x509.Certificate, signer *certutil.CAInfoBundle) {
+	if signer.URLs == nil {
+		return
+	}
+	urls := issuing.CRLPartitionURLs(signer.URLs, signer.CRLPartitions, template.SerialNumber)
+	template.OCSPServer = urls.OCSPServers
+	template.IssuingCertificateURL = urls.IssuingCertificates
+	template.CRLDistributionPoints = urls.CRLDistributionPoints
+}
+
+func publicKeyTypeAndBits(pub crypto.PublicKey) (string, int, error) {
+	switch key := pub.(type) {
+	case *rsa.PublicKey:
+		return "rsa", key.N.BitLen(), nil
+	case *ecdsa.PublicKey:
+		return "ec", key.Curve.Params().BitSize, nil
+	case ed25519.PublicKey:
+		return "ed25519", 0, nil
+	default:
+		return "", 0, fmt.Errorf("unsupported issuer key type %T; set key_type", pub)
+	}
+}
+
+const pathIssuerRotationHelpSyn = `Configure the automatic rotation of an issuer.`
+
+const pathIssuerRotationHelpDesc = `
+This endpoint configures the automatic rotation of an issuer, and reports
+the status of its rotation.
+
+Once the issuer is within "lead_time" of expiring, a successor with the same
+subject and a new key is created, signed by the issuer "parent_issuer_ref"
+or, for roots, self-signed and cross-signed by the old root. After the
+"overlap_period", the successor replaces the issuer as the default issuer
+(when it was) and takes over its name, and its own rotation is configured
+the same way.
+`
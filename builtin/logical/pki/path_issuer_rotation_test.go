@@ -0,0 +1,310 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package pki
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/builtin/logical/pki/issuing"
+	"github.com/hashicorp/vault/sdk/helper/testhelpers/schema"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+)
+
+func readIssuerCert(t *testing.T, b *backend, s logical.Storage, ref string) (*issuing.IssuerEntry, string) {
+	t.Helper()
+
+	sc := b.makeStorageContext(context.Background(), s)
+	issuerId, err := sc.resolveIssuerReference(ref)
+	require.NoError(t, err)
+	issuer, err := sc.fetchIssuerById(issuerId)
+	require.NoError(t, err)
+	return issuer, issuer.Certificate
+}
+
+func TestPki_IssuerRotation_Root(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	b, s := CreateBackendWithStorage(t)
+	sc := b.makeStorageContext(ctx, s)
+
+	resp, err := CBWrite(b, s, "root/generate/internal", map[string]interface{}{
+		"common_name": "Root X1",
+		"key_type":    "ec",
+		"key_bits":    384,
+		"issuer_name": "root-x1",
+		"ttl":         "48h",
+	})
+	requireSuccessNonNilResponse(t, resp, err, "failed generating root")
+	rootId := resp.Data["issuer_id"].(issuing.IssuerID)
+	rootCert := parseCert(t, resp.Data["certificate"].(string))
+
+	// The successor must be able to replace the root before it is due for
+	// rotation itself.
+	_, err = CBWrite(b, s, "issuer/root-x1/rotation", map[string]interface{}{
+		"lead_time":      "45h",
+		"overlap_period": "4h",
+	})
+	require.Error(t, err)
+	_, err = CBWrite(b, s, "issuer/root-x1/rotation", map[string]interface{}{
+		"parent_issuer_ref": "root-x1",
+	})
+	require.Error(t, err)
+
+	resp, err = CBWrite(b, s, "issuer/root-x1/rotation", map[string]interface{}{
+		"lead_time":      "49h",
+		"overlap_period": "1h",
+		"ttl":            "100h",
+	})
+	requireSuccessNonNilResponse(t, resp, err, "failed configuring rotation")
+	schema.ValidateResponse(t, schema.GetResponseSchema(t, b.Route("issuer/root-x1/rotation"), logical.UpdateOperation), resp, true)
+	require.Equal(t, issuerRotationWaiting, resp.Data["state"])
+
+	// The root expires within the lead time, so rotation is due right away.
+	require.NoError(t, runIssuerRotations(sc))
+	resp, err = CBRead(b, s, "issuer/root-x1/rotation")
+	requireSuccessNonNilResponse(t, resp, err)
+	schema.ValidateResponse(t, schema.GetResponseSchema(t, b.Route("issuer/root-x1/rotation"), logical.ReadOperation), resp, true)
+	require.Equal(t, issuerRotationOverlap, resp.Data["state"], resp.Data["last_error"])
+	successorId := issuing.IssuerID(resp.Data["successor_issuer_id"].(string))
+	crossId := issuing.IssuerID(resp.Data["cross_signed_issuer_id"].(string))
+	require.NotEmpty(t, successorId)
+	require.NotEmpty(t, crossId)
+
+	successor, err := sc.fetchIssuerById(successorId)
+	require.NoError(t, err)
+	successorCert := parseCert(t, successor.Certificate)
+	require.Equal(t, rootCert.RawSubject, successorCert.RawSubject)
+	require.False(t, bytes.Equal(rootCert.RawSubjectPublicKeyInfo, successorCert.RawSubjectPublicKeyInfo))
+	require.True(t, isSelfSigned(successorCert))
+	require.NotEqual(t, successor.KeyID, issuing.KeyID(""))
+
+	cross, err := sc.fetchIssuerById(crossId)
+	require.NoError(t, err)
+	crossCert := parseCert(t, cross.Certificate)
+	requireSignedBy(t, crossCert, rootCert)
+	require.Equal(t, successorCert.RawSubjectPublicKeyInfo, crossCert.RawSubjectPublicKeyInfo)
+	require.Equal(t, successor.KeyID, cross.KeyID)
+	require.False(t, cross.Usage.HasUsage(issuing.IssuanceUsage))
+
+	// Within the overlap period, the root remains the default.
+	require.NoError(t, runIssuerRotations(sc))
+	config, err := sc.getIssuersConfig()
+	require.NoError(t, err)
+	require.Equal(t, rootId, config.DefaultIssuerId)
+
+	rotation, err := sc.fetchIssuerRotation(rootId)
+	require.NoError(t, err)
+	rotation.RotationStarted = rotation.RotationStarted.Add(-2 * time.Hour)
+	require.NoError(t, sc.writeIssuerRotation(rootId, rotation))
+	require.NoError(t, runIssuerRotations(sc))
+
+	resp, err = CBRead(b, s, "issuer/"+rootId.String()+"/rotation")
+	requireSuccessNonNilResponse(t, resp, err)
+	require.Equal(t, issuerRotationRotated, resp.Data["state"])
+	require.NotEmpty(t, resp.Data["rotation_completed"])
+
+	config, err = sc.getIssuersConfig()
+	require.NoError(t, err)
+	require.Equal(t, successorId, config.DefaultIssuerId)
+
+	renamed, _ := readIssuerCert(t, b, s, "root-x1")
+	require.Equal(t, successorId, renamed.ID)
+	retired, err := sc.fetchIssuerById(rootId)
+	require.NoError(t, err)
+	require.Equal(t, "root-x1-"+rootCert.NotAfter.UTC().Format("20060102"), retired.Name)
+
+	// The successor carries the rotation configuration forward.
+	resp, err = CBRead(b, s, "issuer/root-x1/rotation")
+	requireSuccessNonNilResponse(t, resp, err)
+	require.Equal(t, issuerRotationWaiting, resp.Data["state"])
+	require.Equal(t, int64((49 * time.Hour).Seconds()), resp.Data["lead_time"])
+
+	_, err = CBWrite(b, s, "roles/example", map[string]interface{}{
+		"allow_any_name": true,
+		"issuer_ref":     "root-x1",
+	})
+	require.NoError(t, err)
+	resp, err = CBWrite(b, s, "issue/example", map[string]interface{}{
+		"common_name": "leaf.example.com",
+		"ttl":         "1h",
+	})
+	requireSuccessNonNilResponse(t, resp, err)
+	requireSignedBy(t, parseCert(t, resp.Data["certificate"].(string)), successorCert)
+
+	// Deleting an issuer removes its rotation configuration.
+	_, err = CBDelete(b, s, "issuer/root-x1")
+	require.NoError(t, err)
+	rotation, err = sc.fetchIssuerRotation(successorId)
+	require.NoError(t, err)
+	require.Nil(t, rotation)
+}
+
+func TestPki_IssuerRotation_Intermediate(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	b, s := CreateBackendWithStorage(t)
+	sc := b.makeStorageContext(ctx, s)
+
+	resp, err := CBWrite(b, s, "root/generate/internal", map[string]interface{}{
+		"common_name": "Root X1",
+		"key_type":    "ec",
+		"issuer_name": "root",
+		"ttl":         "720h",
+	})
+	requireSuccessNonNilResponse(t, resp, err, "failed generating root")
+	rootCert := parseCert(t, resp.Data["certificate"].(string))
+
+	resp, err = CBWrite(b, s, "intermediate/generate/internal", map[string]interface{}{
+		"common_name": "Intermediate R1",
+		"key_type":    "rsa",
+		"key_bits":    2048,
+	})
+	requireSuccessNonNilResponse(t, resp, err, "failed generating intermediate CSR")
+	resp, err = CBWrite(b, s, "issuer/root/sign-intermediate", map[string]interface{}{
+		"csr":    resp.Data["csr"],
+		"format": "pem_bundle",
+		"ttl":    "48h",
+	})
+	requireSuccessNonNilResponse(t, resp, err, "failed signing intermediate")
+	resp, err = CBWrite(b, s, "intermediate/set-signed", map[string]interface{}{
+		"certificate": resp.Data["certificate"],
+	})
+	requireSuccessNonNilResponse(t, resp, err, "failed importing intermediate")
+	intId := resp.Data["imported_issuers"].([]string)[0]
+	_, err = CBPatch(b, s, "issuer/"+intId, map[string]interface{}{
+		"issuer_name": "int",
+	})
+	require.NoError(t, err)
+
+	// Intermediates need a parent to sign their successor with.
+	_, err = CBWrite(b, s, "issuer/int/rotation", map[string]interface{}{
+		"lead_time":      "49h",
+		"overlap_period": "0",
+		"ttl":            "100h",
+	})
+	require.Error(t, err)
+
+	resp, err = CBWrite(b, s, "issuer/int/rotation", map[string]interface{}{
+		"lead_time":         "49h",
+		"overlap_period":    "0",
+		"ttl":               "100h",
+		"parent_issuer_ref": "root",
+	})
+	requireSuccessNonNilResponse(t, resp, err, "failed configuring rotation")
+
+	// Without an overlap period, the successor replaces the intermediate
+	// right away.
+	require.NoError(t, runIssuerRotations(sc))
+	resp, err = CBRead(b, s, "issuer/"+intId+"/rotation")
+	requireSuccessNonNilResponse(t, resp, err)
+	require.Equal(t, issuerRotationRotated, resp.Data["state"], resp.Data["last_error"])
+	require.Empty(t, resp.Data["cross_signed_issuer_id"])
+	require.Empty(t, resp.Data["last_error"])
+
+	successor, successorPem := readIssuerCert(t, b, s, "int")
+	require.Equal(t, resp.Data["successor_issuer_id"], successor.ID.String())
+	successorCert := parseCert(t, successorPem)
+	requireSignedBy(t, successorCert, rootCert)
+	require.Equal(t, "Intermediate R1", successorCert.Subject.CommonName)
+	require.Equal(t, 2048, successorCert.PublicKey.(interface{ Size() int }).Size()*8)
+	// The successor's validity is capped to that of its parent.
+	require.Equal(t, rootCert.NotAfter, successorCert.NotAfter)
+
+	// Failures are reported on the rotation status.
+	_, err = CBWrite(b, s, "issuer/int/rotation", map[string]interface{}{
+		"parent_issuer_ref": "root",
+	})
+	require.NoError(t, err)
+	rotation, err := sc.fetchIssuerRotation(successor.ID)
+	require.NoError(t, err)
+	rotation.ParentIssuerRef = "missing"
+	rotation.LeadTime = 200 * time.Hour
+	require.NoError(t, sc.writeIssuerRotation(successor.ID, rotation))
+	require.NoError(t, runIssuerRotations(sc))
+	resp, err = CBRead(b, s, "issuer/int/rotation")
+	requireSuccessNonNilResponse(t, resp, err)
+	require.Equal(t, issuerRotationWaiting, resp.Data["state"])
+	require.Contains(t, resp.Data["last_error"], "missing")
+}
+
+// TestPki_IssuerRotation_ResumeAfterFailure checks that a rotation which fails
+// after creating the successor resumes with it rather than creating another.
+func TestPki_IssuerRotation_ResumeAfterFailure(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	b, s := CreateBackendWithStorage(t)
+
+	resp, err := CBWrite(b, s, "root/generate/internal", map[string]interface{}{
+		"common_name": "Root X1",
+		"key_type":    "ec",
+		"issuer_name": "root-x1",
+		"ttl":         "48h",
+	})
+	requireSuccessNonNilResponse(t, resp, err, "failed generating root")
+	rootId := resp.Data["issuer_id"].(issuing.IssuerID)
+
+	resp, err = CBWrite(b, s, "issuer/root-x1/rotation", map[string]interface{}{
+		"lead_time":      "49h",
+		"overlap_period": "1h",
+		"ttl":            "100h",
+	})
+	requireSuccessNonNilResponse(t, resp, err, "failed configuring rotation")
+
+	failing := &failingCRLStorage{Storage: s, fail: true}
+	sc := b.makeStorageContext(ctx, failing)
+
+	require.NoError(t, runIssuerRotations(sc))
+	rotation, err := sc.fetchIssuerRotation(rootId)
+	require.NoError(t, err)
+	require.Equal(t, issuerRotationCreating, rotation.State)
+	require.Contains(t, rotation.LastError, "error rebuilding CRLs")
+	require.NotEmpty(t, rotation.SuccessorIssuerID)
+	require.NotEmpty(t, rotation.CrossSignedIssuerID)
+	successorId, crossId := rotation.SuccessorIssuerID, rotation.CrossSignedIssuerID
+
+	// The root, its successor and the cross-signed successor.
+	issuers, err := sc.listIssuers()
+	require.NoError(t, err)
+	require.Len(t, issuers, 3)
+
+	// Retrying while the CRLs still can't be written creates no more issuers.
+	require.NoError(t, runIssuerRotations(sc))
+	issuers, err = sc.listIssuers()
+	require.NoError(t, err)
+	require.Len(t, issuers, 3)
+
+	failing.fail = false
+	require.NoError(t, runIssuerRotations(sc))
+	rotation, err = sc.fetchIssuerRotation(rootId)
+	require.NoError(t, err)
+	require.Equal(t, issuerRotationOverlap, rotation.State)
+	require.Empty(t, rotation.LastError)
+	require.Equal(t, successorId, rotation.SuccessorIssuerID)
+	require.Equal(t, crossId, rotation.CrossSignedIssuerID)
+	issuers, err = sc.listIssuers()
+	require.NoError(t, err)
+	require.Len(t, issuers, 3)
+}
+
+// failingCRLStorage fails writes of CRLs while fail is set.
+type failingCRLStorage struct {
+	logical.Storage
+	fail bool
+}
+
+func (s *failingCRLStorage) Put(ctx context.Context, entry *logical.StorageEntry) error {
+	if s.fail && strings.HasPrefix(entry.Key, "crls/") {
+		return errors.New("injected CRL write failure")
+	}
+	return s.Storage.Put(ctx, entry)
+}
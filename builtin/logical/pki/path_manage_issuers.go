@@ -607,6 +607,11 @@ func pathRevokeIssuer(b *backend) *framework.Path {
 								Description: `RFC formatted time of revocation`,
 								Required:    false,
 							},
+							"crl_partitions": {
+								Type:        framework.TypeInt,
+								Description: `Number of partitions this issuer's CRL is split into`,
+								Required:    false,
+							},
 						},
 					}},
 				},
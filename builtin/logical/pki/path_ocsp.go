@@ -16,6 +16,7 @@ import (
 	"io"
 	"math/big"
 	"net/http"
+	"sort"
 	"strings"
 	"time"
 
@@ -32,6 +33,12 @@ const (
 	ocspReqParam            = "req"
 	ocspResponseContentType = "application/ocsp-response"
 	maximumRequestSize      = 2048 // A normal simple request is 87 bytes, so give us some buffer
+	ocspCachePrefix         = "ocsp-cache/"
+	defaultOcspBulkLimit    = 100
+
+	// ocsp/bulk is unauthenticated, so bound the work a single request can
+	// cause.
+	maxOcspBulkLimit = 1000
 )
 
 type ocspRespInfo struct {
@@ -178,6 +185,24 @@ func (b *backend) ocspHandler(ctx context.Context, request *logical.Request, dat
 		return logAndReturnInternalError(b, err), nil
 	}
 
+	if cfg.OcspPregenerate && !useUnifiedStorage {
+		// Pre-generated responses are built from cluster-local revocation
+		// data only, so are never used for unified OCSP.
+		cached, err := sc.fetchCachedOcspResponse(ocspReq, ocspStatus)
+		if err != nil {
+			return logAndReturnInternalError(b, err), nil
+		}
+		if cached != nil {
+			return &logical.Response{
+				Data: map[string]interface{}{
+					logical.HTTPContentType: ocspResponseContentType,
+					logical.HTTPStatusCode:  http.StatusOK,
+					logical.HTTPRawBody:     cached.Response,
+				},
+			}, nil
+		}
+	}
+
 	caBundle, issuer, err := lookupOcspIssuer(sc, ocspReq, ocspStatus.issuerID)
 	if err != nil {
 		if errors.Is(err, ErrUnknownIssuer) {
@@ -454,33 +479,48 @@ func lookupIssuerIds(sc *storageContext, optRevokedIssuer issuing.IssuerID) ([]i
 }
 
 func doesRequestMatchIssuer(parsedBundle *certutil.ParsedCertBundle, req *ocsp.Request) (bool, error) {
+	issuerNameHash, issuerKeyHash, err := issuerHashes(parsedBundle.Certificate, req.HashAlgorithm)
+	if err != nil {
+		return false, err
+	}
+
+	return bytes.Equal(req.IssuerKeyHash, issuerKeyHash) && bytes.Equal(req.IssuerNameHash, issuerNameHash), nil
+}
+
+// issuerHashes returns the hashes identifying the issuer within an OCSP
+// request's CertID.
+func issuerHashes(issuerCert *x509.Certificate, hash crypto.Hash) ([]byte, []byte, error) {
 	// issuer name hashing taken from golang.org/x/crypto/ocsp.
 	var pkInfo struct {
 		Algorithm pkix.AlgorithmIdentifier
 		PublicKey asn1.BitString
 	}
-	if _, err := asn1.Unmarshal(parsedBundle.Certificate.RawSubjectPublicKeyInfo, &pkInfo); err != nil {
-		return false, err
+	if _, err := asn1.Unmarshal(issuerCert.RawSubjectPublicKeyInfo, &pkInfo); err != nil {
+		return nil, nil, err
 	}
 
-	h := req.HashAlgorithm.New()
+	h := hash.New()
 	h.Write(pkInfo.PublicKey.RightAlign())
 	issuerKeyHash := h.Sum(nil)
 
 	h.Reset()
-	h.Write(parsedBundle.Certificate.RawSubject)
+	h.Write(issuerCert.RawSubject)
 	issuerNameHash := h.Sum(nil)
 
-	return bytes.Equal(req.IssuerKeyHash, issuerKeyHash) && bytes.Equal(req.IssuerNameHash, issuerNameHash), nil
+	return issuerNameHash, issuerKeyHash, nil
 }
 
 func genResponse(cfg *crlConfig, caBundle *certutil.ParsedCertBundle, info *ocspRespInfo, reqHash crypto.Hash, revSigAlg x509.SignatureAlgorithm) ([]byte, error) {
-	curTime := time.Now()
 	duration, err := parseutil.ParseDurationSecond(cfg.OcspExpiry)
 	if err != nil {
 		return nil, err
 	}
 
+	return genResponseWithExpiry(time.Now(), duration, caBundle, info, reqHash, revSigAlg)
+}
+
+func genResponseWithExpiry(curTime time.Time, duration time.Duration, caBundle *certutil.ParsedCertBundle, info *ocspRespInfo, reqHash crypto.Hash, revSigAlg x509.SignatureAlgorithm) ([]byte, error) {
+
 	// x/crypto/ocsp lives outside of the standard library's crypto/x509 and includes
 	// ripped-off variants of many internal structures and functions. These
 	// lack support for PSS signatures altogether, so if we have revSigAlg
@@ -527,6 +567,324 @@ func genResponse(cfg *crlConfig, caBundle *certutil.ParsedCertBundle, info *ocsp
 	return ocsp.CreateResponse(caBundle.Certificate, caBundle.Certificate, template, caBundle.PrivateKey)
 }
 
+// ocspCacheEntry is a pre-generated OCSP response for a single certificate,
+// along with what is needed to tell whether it answers a given request.
+// Responses are always generated for requests using SHA-1 to identify the
+// issuer, as RFC 5019 clients must.
+type ocspCacheEntry struct {
+	SerialNumber   string           `json:"serial_number"`
+	IssuerID       issuing.IssuerID `json:"issuer_id"`
+	IssuerNameHash []byte           `json:"issuer_name_hash"`
+	IssuerKeyHash  []byte           `json:"issuer_key_hash"`
+	Status         int              `json:"status"`
+	ThisUpdate     time.Time        `json:"this_update"`
+	NextUpdate     time.Time        `json:"next_update"`
+	Response       []byte           `json:"response"`
+}
+
+func (sc *storageContext) fetchOcspCacheEntry(serial string) (*ocspCacheEntry, error) {
+	entry, err := sc.Storage.Get(sc.Context, ocspCachePrefix+serial)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var cached ocspCacheEntry
+	if err := entry.DecodeJSON(&cached); err != nil {
+		return nil, fmt.Errorf("error decoding pre-generated OCSP response for %v: %w", serial, err)
+	}
+
+	return &cached, nil
+}
+
+func (sc *storageContext) writeOcspCacheEntry(cached *ocspCacheEntry) error {
+	entry, err := logical.StorageEntryJSON(ocspCachePrefix+cached.SerialNumber, cached)
+	if err != nil {
+		return err
+	}
+
+	return sc.Storage.Put(sc.Context, entry)
+}
+
+// fetchCachedOcspResponse returns the pre-generated response answering the
+// request, if there is one which is still valid and agrees with the
+// certificate's current status.
+func (sc *storageContext) fetchCachedOcspResponse(req *ocsp.Request, info *ocspRespInfo) (*ocspCacheEntry, error) {
+	if req.HashAlgorithm != crypto.SHA1 {
+		return nil, nil
+	}
+
+	cached, err := sc.fetchOcspCacheEntry(normalizeSerialFromBigInt(req.SerialNumber))
+	if err != nil || cached == nil {
+		return nil, err
+	}
+
+	if !bytes.Equal(req.IssuerNameHash, cached.IssuerNameHash) || !bytes.Equal(req.IssuerKeyHash, cached.IssuerKeyHash) {
+		return nil, nil
+	}
+	if cached.Status != info.ocspStatus || !time.Now().Before(cached.NextUpdate) {
+		return nil, nil
+	}
+
+	return cached, nil
+}
+
+// pregenerateOcspResponses signs OCSP responses for all unexpired stored
+// certificates whose issuer may sign them, and removes those of certificates
+// which expired or whose issuer went away. Responses which are still valid
+// for more than half their lifetime and whose status is unchanged are kept,
+// so rebuilding the CRL on revocation only signs what changed. It returns
+// the number of responses signed.
+func pregenerateOcspResponses(sc *storageContext, cfg *crlConfig, issuerIDEntryMap map[issuing.IssuerID]*issuing.IssuerEntry) (int, error) {
+	duration, err := parseutil.ParseDurationSecond(cfg.OcspPregeneratedExpiry)
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	kept := make(map[string]bool)
+	signed := 0
+	for issuerId, issuer := range issuerIDEntryMap {
+		if !issuer.Usage.HasUsage(issuing.OCSPSigningUsage) {
+			continue
+		}
+
+		serials, err := sc.Storage.List(sc.Context, certIndexIssuerPrefix+issuerId.String()+"/")
+		if err != nil {
+			return signed, fmt.Errorf("failed listing certificates of issuer %v: %w", issuerId, err)
+		}
+		if len(serials) == 0 {
+			continue
+		}
+
+		caBundle, _, err := getOcspIssuerParsedBundle(sc, issuerId)
+		if err != nil {
+			if errors.Is(err, ErrUnknownIssuer) || errors.Is(err, ErrIssuerHasNoKey) {
+				continue
+			}
+			return signed, err
+		}
+		nameHash, keyHash, err := issuerHashes(caBundle.Certificate, crypto.SHA1)
+		if err != nil {
+			return signed, err
+		}
+
+		for _, serial := range serials {
+			record, err := sc.fetchCertIndex(serial)
+			if err != nil {
+				return signed, err
+			}
+			if record == nil || !now.Before(record.NotAfter) {
+				continue
+			}
+
+			serialNumber, ok := serialToBigInt(serial)
+			if !ok {
+				continue
+			}
+			info := &ocspRespInfo{
+				serialNumber: serialNumber,
+				ocspStatus:   ocsp.Good,
+			}
+			revInfo, err := sc.fetchRevocationInfo(serial)
+			if err != nil {
+				return signed, err
+			}
+			if revInfo != nil {
+				info.ocspStatus = ocsp.Revoked
+				info.revocationTimeUTC = &revInfo.RevocationTimeUTC
+			}
+			kept[serial] = true
+
+			cached, err := sc.fetchOcspCacheEntry(serial)
+			if err != nil {
+				return signed, err
+			}
+			if cached != nil && cached.IssuerID == issuerId && cached.Status == info.ocspStatus && cached.NextUpdate.Sub(now) > duration/2 {
+				continue
+			}
+
+			response, err := genResponseWithExpiry(now, duration, caBundle, info, crypto.SHA1, issuer.RevocationSigAlg)
+			if err != nil {
+				return signed, fmt.Errorf("failed signing OCSP response for %v: %w", serial, err)
+			}
+			err = sc.writeOcspCacheEntry(&ocspCacheEntry{
+				SerialNumber:   serial,
+				IssuerID:       issuerId,
+				IssuerNameHash: nameHash,
+				IssuerKeyHash:  keyHash,
+				Status:         info.ocspStatus,
+				ThisUpdate:     now,
+				NextUpdate:     now.Add(duration),
+				Response:       response,
+			})
+			if err != nil {
+				return signed, fmt.Errorf("failed storing OCSP response for %v: %w", serial, err)
+			}
+			signed++
+		}
+	}
+
+	cachedSerials, err := sc.Storage.List(sc.Context, ocspCachePrefix)
+	if err != nil {
+		return signed, fmt.Errorf("failed listing pre-generated OCSP responses: %w", err)
+	}
+	for _, serial := range cachedSerials {
+		if kept[serial] {
+			continue
+		}
+		if err := sc.Storage.Delete(sc.Context, ocspCachePrefix+serial); err != nil {
+			return signed, fmt.Errorf("failed removing pre-generated OCSP response for %v: %w", serial, err)
+		}
+	}
+
+	return signed, nil
+}
+
+func buildPathOcspBulk(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "ocsp/bulk",
+
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixPKI,
+			OperationVerb:   "read",
+			OperationSuffix: "ocsp-bulk",
+		},
+
+		Fields: map[string]*framework.FieldSchema{
+			issuerRefParam: {
+				Type:        framework.TypeString,
+				Description: `Only return responses signed by this issuer.`,
+			},
+			"after": {
+				Type: framework.TypeString,
+				Description: `Only return responses for serial numbers after
+this one, for paging through results.`,
+			},
+			"limit": {
+				Type:        framework.TypeInt,
+				Description: `The maximum number of responses to return; defaults to 100, and may be at most 1000.`,
+				Default:     defaultOcspBulkLimit,
+			},
+		},
+
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ReadOperation: &framework.PathOperation{
+				Callback: b.pathOcspBulkRead,
+				Responses: map[int][]framework.Response{
+					http.StatusOK: {{
+						Description: "OK",
+						Fields: map[string]*framework.FieldSchema{
+							"keys": {
+								Type:        framework.TypeStringSlice,
+								Description: `The serial numbers of the certificates with pre-generated responses, in order`,
+								Required:    false,
+							},
+							"key_info": {
+								Type:        framework.TypeMap,
+								Description: `The pre-generated response for each certificate, along with its status, issuer and validity`,
+								Required:    false,
+							},
+						},
+					}},
+				},
+			},
+		},
+
+		HelpSynopsis:    pathOcspBulkHelpSyn,
+		HelpDescription: pathOcspBulkHelpDesc,
+	}
+}
+
+func (b *backend) pathOcspBulkRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	sc := b.makeStorageContext(ctx, req.Storage)
+	cfg, err := b.CrlBuilder().getConfigWithUpdate(sc)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.OcspDisable {
+		return logical.ErrorResponse("OCSP is disabled"), nil
+	}
+	if !cfg.OcspPregenerate || shouldLocalPathsUseUnified(cfg) {
+		return logical.ErrorResponse("OCSP responses are not pre-generated; set ocsp_pregenerate on config/crl"), nil
+	}
+
+	var issuerId issuing.IssuerID
+	if issuerRef := data.Get(issuerRefParam).(string); issuerRef != "" {
+		issuerId, err = sc.resolveIssuerReference(issuerRef)
+		if err != nil {
+			if issuerId == issuing.IssuerRefNotFound {
+				return logical.ErrorResponse("unable to find issuer %q", issuerRef), nil
+			}
+			return nil, err
+		}
+	}
+
+	after := normalizeSerial(data.Get("after").(string))
+	limit := data.Get("limit").(int)
+	if limit <= 0 || limit > maxOcspBulkLimit {
+		return logical.ErrorResponse("limit must be between 1 and %d", maxOcspBulkLimit), nil
+	}
+
+	serials, err := sc.Storage.List(ctx, ocspCachePrefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed listing pre-generated OCSP responses: %w", err)
+	}
+	sort.Strings(serials)
+
+	now := time.Now()
+	keys := []string{}
+	keyInfo := map[string]interface{}{}
+	for _, serial := range serials {
+		if len(keys) >= limit {
+			break
+		}
+		if after != "" && serial <= after {
+			continue
+		}
+
+		cached, err := sc.fetchOcspCacheEntry(serial)
+		if err != nil {
+			return nil, err
+		}
+		if cached == nil || !now.Before(cached.NextUpdate) {
+			continue
+		}
+		if issuerId != "" && cached.IssuerID != issuerId {
+			continue
+		}
+
+		// Skip responses made stale by a revocation since the last CRL
+		// rebuild; they are regenerated on the next one.
+		revoked, err := sc.Storage.Get(ctx, revokedPath+serial)
+		if err != nil {
+			return nil, err
+		}
+		if (revoked != nil) != (cached.Status == ocsp.Revoked) {
+			continue
+		}
+
+		status := "good"
+		if cached.Status == ocsp.Revoked {
+			status = "revoked"
+		}
+
+		serialNumber := denormalizeSerial(serial)
+		keys = append(keys, serialNumber)
+		keyInfo[serialNumber] = map[string]interface{}{
+			"issuer_id":   cached.IssuerID.String(),
+			"status":      status,
+			"this_update": cached.ThisUpdate.Format(time.RFC3339),
+			"next_update": cached.NextUpdate.Format(time.RFC3339),
+			"response":    base64.StdEncoding.EncodeToString(cached.Response),
+		}
+	}
+
+	return logical.ListResponseWithInfo(keys, keyInfo), nil
+}
+
 const pathOcspHelpSyn = `
 Query a certificate's revocation status through OCSP'
 `
@@ -534,3 +892,14 @@ Query a certificate's revocation status through OCSP'
 const pathOcspHelpDesc = `
 This endpoint expects DER encoded OCSP requests and returns DER encoded OCSP responses
 `
+
+const pathOcspBulkHelpSyn = `Fetch the pre-generated OCSP responses of this mount.`
+
+const pathOcspBulkHelpDesc = `
+When ocsp_pregenerate is enabled on config/crl, this endpoint returns the
+pre-generated, DER encoded OCSP responses of all unexpired certificates,
+base64 encoded, so that OCSP stapling caches can be warmed ahead of time.
+
+Results are ordered by serial number and limited to "limit" entries; pass the
+last serial number returned as "after" to fetch the next page.
+`
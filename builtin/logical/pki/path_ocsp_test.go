@@ -525,6 +525,144 @@ func TestOcsp_ValidRequests(t *testing.T) {
 	}
 }
 
+// Validate pre-generated OCSP responses are built after CRL rebuilds, served in
+// place of signing SHA-1 requests, refreshed only when needed and returned
+// by ocsp/bulk.
+func TestOcsp_Pregenerated(t *testing.T) {
+	t.Parallel()
+
+	b, s, testEnv := setupOcspEnv(t, "ec")
+	sc := b.makeStorageContext(context.Background(), s)
+	leafSerial := normalizeSerial(serialFromCert(testEnv.leafCertIssuer1))
+
+	resp, err := CBRead(b, s, "ocsp/bulk")
+	require.Error(t, err, "ocsp/bulk should fail without pre-generation")
+
+	// Enabling pre-generation rebuilds the CRLs, which schedules generating
+	// the responses in the background rather than signing them inline.
+	resp, err = CBWrite(b, s, "config/crl", map[string]interface{}{
+		"ocsp_pregenerate":         true,
+		"ocsp_pregenerated_expiry": "48h",
+	})
+	requireSuccessNonNilResponse(t, resp, err, "config/crl failed")
+	require.Equal(t, true, resp.Data["ocsp_pregenerate"])
+	require.Equal(t, "48h", resp.Data["ocsp_pregenerated_expiry"])
+
+	cached, err := sc.fetchOcspCacheEntry(leafSerial)
+	require.NoError(t, err)
+	require.Nil(t, cached, "responses should not be signed during the CRL rebuild")
+	require.True(t, b.ocspPregenerationStatus.forceRerun.Load())
+
+	runOcspPregeneration(sc)
+	require.False(t, b.ocspPregenerationStatus.forceRerun.Load())
+	cached, err = sc.fetchOcspCacheEntry(leafSerial)
+	require.NoError(t, err)
+	require.NotNil(t, cached, "expected a pre-generated response for the leaf")
+	require.Equal(t, testEnv.issuerId1, cached.IssuerID)
+	require.Equal(t, ocsp.Good, cached.Status)
+
+	// SHA-1 requests are answered with the stored response.
+	resp, err = SendOcspRequest(t, b, s, "get", testEnv.leafCertIssuer1, testEnv.issuer1, crypto.SHA1)
+	requireSuccessNonNilResponse(t, resp, err, "ocsp get request")
+	require.Equal(t, cached.Response, resp.Data["http_raw_body"])
+	ocspResp, err := ocsp.ParseResponse(cached.Response, testEnv.issuer1)
+	require.NoError(t, err)
+	require.Equal(t, ocsp.Good, ocspResp.Status)
+	require.Equal(t, testEnv.leafCertIssuer1.SerialNumber, ocspResp.SerialNumber)
+	require.Equal(t, 48*time.Hour, ocspResp.NextUpdate.Sub(ocspResp.ThisUpdate))
+	requireOcspResponseSignedBy(t, ocspResp, testEnv.issuer1)
+
+	// Other hash algorithms are still signed on demand.
+	resp, err = SendOcspRequest(t, b, s, "get", testEnv.leafCertIssuer1, testEnv.issuer1, crypto.SHA256)
+	requireSuccessNonNilResponse(t, resp, err, "ocsp get request")
+	require.NotEqual(t, cached.Response, resp.Data["http_raw_body"])
+
+	resp, err = CBRead(b, s, "ocsp/bulk")
+	requireSuccessNonNilResponse(t, resp, err, "ocsp/bulk failed")
+	schema.ValidateResponse(t, schema.GetResponseSchema(t, b.Route("ocsp/bulk"), logical.ReadOperation), resp, true)
+	keys := resp.Data["keys"].([]string)
+	require.Contains(t, keys, serialFromCert(testEnv.leafCertIssuer1))
+	require.Contains(t, keys, serialFromCert(testEnv.leafCertIssuer2))
+	info := resp.Data["key_info"].(map[string]interface{})[serialFromCert(testEnv.leafCertIssuer1)].(map[string]interface{})
+	require.Equal(t, "good", info["status"])
+	require.Equal(t, testEnv.issuerId1.String(), info["issuer_id"])
+	require.Equal(t, base64.StdEncoding.EncodeToString(cached.Response), info["response"])
+
+	resp, err = CBReq(b, s, logical.ReadOperation, "ocsp/bulk", map[string]interface{}{
+		"issuer_ref": testEnv.issuerId2.String(),
+	})
+	requireSuccessNonNilResponse(t, resp, err, "ocsp/bulk failed")
+	require.NotContains(t, resp.Data["keys"], serialFromCert(testEnv.leafCertIssuer1))
+	require.Contains(t, resp.Data["keys"], serialFromCert(testEnv.leafCertIssuer2))
+
+	resp, err = CBReq(b, s, logical.ReadOperation, "ocsp/bulk", map[string]interface{}{
+		"limit": 1,
+	})
+	requireSuccessNonNilResponse(t, resp, err, "ocsp/bulk failed")
+	require.Len(t, resp.Data["keys"], 1)
+	first := resp.Data["keys"].([]string)[0]
+	resp, err = CBReq(b, s, logical.ReadOperation, "ocsp/bulk", map[string]interface{}{
+		"after": first,
+	})
+	requireSuccessNonNilResponse(t, resp, err, "ocsp/bulk failed")
+	require.Len(t, resp.Data["keys"], len(keys)-1)
+	require.NotContains(t, resp.Data["keys"], first)
+
+	// The endpoint is unauthenticated, so page sizes are bounded.
+	_, err = CBReq(b, s, logical.ReadOperation, "ocsp/bulk", map[string]interface{}{
+		"limit": maxOcspBulkLimit + 1,
+	})
+	require.Error(t, err)
+
+	// Revoking rebuilds the CRLs, after which only the revoked certificate's
+	// response is re-signed; responses for unknown certificates are removed.
+	otherSerial := normalizeSerial(serialFromCert(testEnv.leafCertIssuer2))
+	other, err := sc.fetchOcspCacheEntry(otherSerial)
+	require.NoError(t, err)
+	bogus := *other
+	bogus.SerialNumber = "01-02-03"
+	require.NoError(t, sc.writeOcspCacheEntry(&bogus))
+
+	resp, err = CBWrite(b, s, "revoke", map[string]interface{}{
+		"serial_number": serialFromCert(testEnv.leafCertIssuer1),
+	})
+	requireSuccessNonNilResponse(t, resp, err, "revoke")
+	runOcspPregeneration(sc)
+
+	revoked, err := sc.fetchOcspCacheEntry(leafSerial)
+	require.NoError(t, err)
+	require.Equal(t, ocsp.Revoked, revoked.Status)
+	unchanged, err := sc.fetchOcspCacheEntry(otherSerial)
+	require.NoError(t, err)
+	require.Equal(t, other.Response, unchanged.Response)
+	removed, err := sc.fetchOcspCacheEntry("01-02-03")
+	require.NoError(t, err)
+	require.Nil(t, removed)
+
+	resp, err = SendOcspRequest(t, b, s, "post", testEnv.leafCertIssuer1, testEnv.issuer1, crypto.SHA1)
+	requireSuccessNonNilResponse(t, resp, err, "ocsp post request")
+	require.Equal(t, revoked.Response, resp.Data["http_raw_body"])
+
+	// Expired responses are neither served nor returned in bulk.
+	unchanged.NextUpdate = time.Now().Add(-time.Minute)
+	require.NoError(t, sc.writeOcspCacheEntry(unchanged))
+	resp, err = SendOcspRequest(t, b, s, "get", testEnv.leafCertIssuer2, testEnv.issuer2, crypto.SHA1)
+	requireSuccessNonNilResponse(t, resp, err, "ocsp get request")
+	require.NotEqual(t, unchanged.Response, resp.Data["http_raw_body"])
+	ocspResp, err = ocsp.ParseResponse(resp.Data["http_raw_body"].([]byte), testEnv.issuer2)
+	require.NoError(t, err)
+	require.Equal(t, ocsp.Good, ocspResp.Status)
+
+	resp, err = CBRead(b, s, "ocsp/bulk")
+	requireSuccessNonNilResponse(t, resp, err, "ocsp/bulk failed")
+	require.NotContains(t, resp.Data["keys"], serialFromCert(testEnv.leafCertIssuer2))
+
+	resp, err = CBWrite(b, s, "config/crl", map[string]interface{}{
+		"ocsp_pregenerated_expiry": "0s",
+	})
+	require.Error(t, err)
+}
+
 func runOcspRequestTest(t *testing.T, requestType string, caKeyType string,
 	caKeyBits int, caKeySigBits int, requestHash crypto.Hash, ocspExpiry time.Duration,
 ) {
@@ -385,6 +385,26 @@ The value format should be given in UTC format YYYY-MM-ddTHH:MM:SSZ.`,
 			Description: `Reference to the issuer used to sign requests
 serviced by this role.`,
 		},
+
+		"ct_logs": {
+			Type: framework.TypeCommaStringSlice,
+			Description: `Names of the CT logs, configured under ct-logs/,
+to which precertificates are submitted; the SCTs obtained are embedded in
+issued certificates.`,
+		},
+
+		"ct_min_scts": {
+			Type: framework.TypeInt,
+			Description: `The minimum number of SCTs which must be
+obtained from ct_logs for issuance to succeed. Zero requires an SCT from
+every log.`,
+		},
+
+		"issuance_policy": {
+			Type: framework.TypeSlice,
+			Description: `The ordered rules of the role's local issuance
+policy.`,
+		},
 	}
 
 	return &framework.Path{
@@ -806,6 +826,30 @@ The value format should be given in UTC format YYYY-MM-ddTHH:MM:SSZ.`,
 serviced by this role.`,
 				Default: defaultRef,
 			},
+
+			"ct_logs": {
+				Type: framework.TypeCommaStringSlice,
+				Description: `Names of the CT logs, configured under ct-logs/,
+to which precertificates are submitted; the SCTs obtained are embedded in
+issued certificates. Leave empty to disable certificate transparency.`,
+			},
+
+			"ct_min_scts": {
+				Type: framework.TypeInt,
+				Description: `The minimum number of SCTs which must be
+obtained from ct_logs for issuance to succeed; failures of the remaining logs
+are returned as warnings. Defaults to zero, which requires an SCT from every
+log.`,
+			},
+
+			"issuance_policy": {
+				Type: framework.TypeSlice,
+				Description: `An ordered list of issuance policy rules, as
+objects or a JSON string, evaluated against the CSR, the requesting entity and
+the role on every issuance. Each rule has a name, a go-bexpr condition and an
+action: allow or deny, which decide issuance, or mutate, which modifies the
+certificate's SANs, key usages or TTL.`,
+			},
 		},
 
 		Operations: map[logical.Operation]framework.OperationHandler{
@@ -983,9 +1027,17 @@ func (b *backend) pathRoleCreate(ctx context.Context, req *logical.Request, data
 		NotBeforeDuration:             time.Duration(data.Get("not_before_duration").(int)) * time.Second,
 		NotAfter:                      data.Get("not_after").(string),
 		Issuer:                        data.Get("issuer_ref").(string),
+		CTLogs:                        data.Get("ct_logs").([]string),
+		CTMinSCTs:                     data.Get("ct_min_scts").(int),
 		Name:                          name,
 	}
 
+	issuancePolicy, err := issuing.ParseIssuancePolicy(data.Raw["issuance_policy"])
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+	entry.IssuancePolicy = issuancePolicy
+
 	allowedOtherSANs := data.Get("allowed_other_sans").([]string)
 	switch {
 	case len(allowedOtherSANs) == 0:
@@ -1100,6 +1152,10 @@ func validateRole(b *backend, entry *issuing.RoleEntry, ctx context.Context, s l
 
 	}
 
+	if entry.CTMinSCTs < 0 || entry.CTMinSCTs > len(entry.CTLogs) {
+		return logical.ErrorResponse(`"ct_min_scts" must be between zero and the number of "ct_logs"`), nil
+	}
+
 	// Ensures CNValidations are alright
 	entry.CNValidations, err = checkCNValidations(entry.CNValidations)
 	if err != nil {
@@ -1183,6 +1239,18 @@ func (b *backend) pathRolePatch(ctx context.Context, req *logical.Request, data
 		NotBeforeDuration:             getTimeWithExplicitDefault(data, "not_before_duration", oldEntry.NotBeforeDuration),
 		NotAfter:                      getWithExplicitDefault(data, "not_after", oldEntry.NotAfter).(string),
 		Issuer:                        getWithExplicitDefault(data, "issuer_ref", oldEntry.Issuer).(string),
+		CTLogs:                        getWithExplicitDefault(data, "ct_logs", oldEntry.CTLogs).([]string),
+		CTMinSCTs:                     getWithExplicitDefault(data, "ct_min_scts", oldEntry.CTMinSCTs).(int),
+	}
+
+	if issuancePolicyRaw, ok := data.Raw["issuance_policy"]; ok {
+		issuancePolicy, err := issuing.ParseIssuancePolicy(issuancePolicyRaw)
+		if err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+		entry.IssuancePolicy = issuancePolicy
+	} else {
+		entry.IssuancePolicy = oldEntry.IssuancePolicy
 	}
 
 	allowedOtherSANsData, wasSet := data.GetOk("allowed_other_sans")
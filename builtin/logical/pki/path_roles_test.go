@@ -6,11 +6,13 @@ package pki
 import (
 	"context"
 	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/asn1"
 	"encoding/base64"
 	"encoding/pem"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/go-errors/errors"
 	"github.com/hashicorp/go-secure-stdlib/strutil"
@@ -1161,3 +1163,167 @@ func getPolicyInformationExtensionOffCertificate(resp logical.Response) ([]byte,
 	}
 	return *new([]byte), errors.New("No Policy Information Extension Found")
 }
+
+func TestPki_RoleIssuancePolicy(t *testing.T) {
+	t.Parallel()
+	b, s := CreateBackendWithStorage(t)
+
+	resp, err := CBWrite(b, s, "root/generate/internal", map[string]interface{}{
+		"common_name": "root example.com",
+		"key_type":    "ec",
+	})
+	requireSuccessNonNilResponse(t, resp, err)
+
+	// Invalid rules are rejected when the role is written.
+	for name, policy := range map[string]interface{}{
+		"missing name":      []interface{}{map[string]interface{}{"action": "allow"}},
+		"duplicate name":    []interface{}{map[string]interface{}{"name": "a", "action": "allow"}, map[string]interface{}{"name": "a", "action": "deny"}},
+		"unknown action":    []interface{}{map[string]interface{}{"name": "a", "action": "maybe"}},
+		"unknown selector":  []interface{}{map[string]interface{}{"name": "a", "action": "deny", "condition": `csr.subject == "x"`}},
+		"invalid condition": []interface{}{map[string]interface{}{"name": "a", "action": "deny", "condition": `csr.common_name ==`}},
+		"deny mutation":     []interface{}{map[string]interface{}{"name": "a", "action": "deny", "max_ttl": "1h"}},
+		"invalid ip":        []interface{}{map[string]interface{}{"name": "a", "action": "mutate", "add_ip_sans": []string{"nope"}}},
+		"invalid json":      `[{"name": "a",`,
+	} {
+		_, err = CBWrite(b, s, "roles/policy", map[string]interface{}{
+			"allow_any_name":  true,
+			"issuance_policy": policy,
+		})
+		require.Error(t, err, name)
+	}
+
+	resp, err = CBWrite(b, s, "roles/policy", map[string]interface{}{
+		"allowed_domains":  "example.com",
+		"allow_subdomains": true,
+		"key_type":         "ec",
+		"ttl":              "1h",
+		"issuance_policy": []interface{}{
+			map[string]interface{}{
+				"name":      "deny-blocked",
+				"condition": `"blocked.example.com" in certificate.dns_names`,
+				"action":    "deny",
+			},
+			map[string]interface{}{
+				"name":             "ops-short-lived",
+				"condition":        `entity.metadata.team == "ops"`,
+				"action":           "mutate",
+				"max_ttl":          "10m",
+				"add_alt_names":    []string{"ops.example.com"},
+				"remove_alt_names": []string{"*.internal.example.com"},
+				"ext_key_usage":    []string{"ClientAuth"},
+			},
+			map[string]interface{}{
+				"name":      "allow-csr",
+				"condition": `csr.present == true and csr.key_type == "ec"`,
+				"action":    "allow",
+			},
+			map[string]interface{}{
+				"name":      "default-deny",
+				"condition": `entity.metadata.team != "ops"`,
+				"action":    "deny",
+			},
+		},
+	})
+	requireSuccessNonNilResponse(t, resp, err)
+	require.Len(t, resp.Data["issuance_policy"], 4)
+
+	// Patching other fields keeps the policy.
+	resp, err = CBPatch(b, s, "roles/policy", map[string]interface{}{"ttl": "2h"})
+	requireSuccessNonNilResponse(t, resp, err)
+	require.Len(t, resp.Data["issuance_policy"], 4)
+
+	issue := func(entityID string, data map[string]interface{}) (*logical.Response, error) {
+		return b.HandleRequest(context.Background(), &logical.Request{
+			Operation:  logical.UpdateOperation,
+			Path:       "issue/policy",
+			Data:       data,
+			Storage:    s,
+			MountPoint: "pki/",
+			EntityID:   entityID,
+		})
+	}
+
+	// Deny rules reject issuance and name the rule.
+	_, err = CBWrite(b, s, "issue/policy", map[string]interface{}{
+		"common_name": "host.example.com",
+		"alt_names":   "blocked.example.com",
+	})
+	require.ErrorContains(t, err, `"deny-blocked"`)
+	_, err = CBWrite(b, s, "issue/policy", map[string]interface{}{
+		"common_name": "host.example.com",
+	})
+	require.ErrorContains(t, err, `"default-deny"`)
+
+	// Allow rules stop evaluation before the default deny.
+	_, _, csr := generateCSR(t, &x509.CertificateRequest{
+		Subject: pkix.Name{CommonName: "host.example.com"},
+	}, "ec", 256)
+	resp, err = CBWrite(b, s, "sign/policy", map[string]interface{}{
+		"csr": csr,
+	})
+	requireSuccessNonNilResponse(t, resp, err)
+	require.Contains(t, resp.Warnings, `issuance allowed by issuance policy rule "allow-csr"`)
+
+	// Mutate rules act on the requesting entity's metadata.
+	b.System().(*logical.StaticSystemView).EntityVal = &logical.Entity{
+		ID:       "ops-entity",
+		Name:     "ops-user",
+		Metadata: map[string]string{"team": "ops"},
+	}
+	resp, err = issue("ops-entity", map[string]interface{}{
+		"common_name": "host.example.com",
+		"alt_names":   "db.internal.example.com",
+	})
+	requireSuccessNonNilResponse(t, resp, err)
+	require.Contains(t, resp.Warnings, `certificate modified by issuance policy rule "ops-short-lived"`)
+	cert := parseCert(t, resp.Data["certificate"].(string))
+	require.ElementsMatch(t, []string{"host.example.com", "ops.example.com"}, cert.DNSNames)
+	require.Equal(t, []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}, cert.ExtKeyUsage)
+	require.WithinDuration(t, time.Now().Add(10*time.Minute), cert.NotAfter, time.Minute)
+
+	// Names added by mutate rules must still be allowed by the role.
+	resp, err = CBWrite(b, s, "roles/policy", map[string]interface{}{
+		"allowed_domains":  "example.com",
+		"allow_subdomains": true,
+		"ttl":              "1h",
+		"issuance_policy": []interface{}{
+			map[string]interface{}{
+				"name":          "add-foreign",
+				"action":        "mutate",
+				"add_alt_names": []string{"host.example.org"},
+			},
+		},
+	})
+	requireSuccessNonNilResponse(t, resp, err)
+	_, err = CBWrite(b, s, "issue/policy", map[string]interface{}{
+		"common_name": "host.example.com",
+	})
+	require.ErrorContains(t, err, "host.example.org")
+	_, err = CBPatch(b, s, "roles/policy", map[string]interface{}{
+		"allow_ip_sans": false,
+		"issuance_policy": []interface{}{
+			map[string]interface{}{
+				"name":        "add-ip",
+				"action":      "mutate",
+				"add_ip_sans": []string{"10.0.0.1"},
+			},
+		},
+	})
+	require.NoError(t, err)
+	_, err = CBWrite(b, s, "issue/policy", map[string]interface{}{
+		"common_name": "host.example.com",
+	})
+	require.ErrorContains(t, err, "IP Subject Alternative Names")
+
+	// Policies may also be given as a JSON string.
+	resp, err = CBWrite(b, s, "roles/policy", map[string]interface{}{
+		"allow_any_name":  true,
+		"ttl":             "1h",
+		"issuance_policy": `[{"name": "deny-all", "action": "deny"}]`,
+	})
+	requireSuccessNonNilResponse(t, resp, err)
+	_, err = CBWrite(b, s, "issue/policy", map[string]interface{}{
+		"common_name": "host.example.com",
+	})
+	require.ErrorContains(t, err, `"deny-all"`)
+}
@@ -285,7 +285,7 @@ func (b *backend) pathCAGenerateRoot(ctx context.Context, req *logical.Request,
 
 	// Also store it as just the certificate identified by serial number, so it
 	// can be revoked
-	err = issuing.StoreCertificate(ctx, req.Storage, b.GetCertificateCounter(), parsedBundle)
+	err = sc.storeCertificate(parsedBundle, myIssuer.ID, "")
 	if err != nil {
 		return nil, err
 	}
@@ -363,7 +363,7 @@ func (b *backend) pathIssuerSignIntermediate(ctx context.Context, req *logical.R
 
 	var caErr error
 	sc := b.makeStorageContext(ctx, req.Storage)
-	signingBundle, caErr := sc.fetchCAInfo(issuerName, issuing.IssuanceUsage)
+	signingBundle, issuerId, caErr := sc.fetchCAInfoWithIssuer(issuerName, issuing.IssuanceUsage)
 	if caErr != nil {
 		switch caErr.(type) {
 		case errutil.UserError:
@@ -413,7 +413,7 @@ func (b *backend) pathIssuerSignIntermediate(ctx context.Context, req *logical.R
 		return nil, err
 	}
 
-	err = issuing.StoreCertificate(ctx, req.Storage, b.GetCertificateCounter(), parsedBundle)
+	err = sc.storeCertificate(parsedBundle, issuerId, "")
 	if err != nil {
 		return nil, err
 	}
@@ -541,7 +541,7 @@ func (b *backend) pathIssuerSignSelfIssued(ctx context.Context, req *logical.Req
 
 	urls := &certutil.URLEntries{}
 	if signingBundle.URLs != nil {
-		urls = signingBundle.URLs
+		urls = issuing.CRLPartitionURLs(signingBundle.URLs, signingBundle.CRLPartitions, cert.SerialNumber)
 	}
 	cert.IssuingCertificateURL = urls.IssuingCertificates
 	cert.CRLDistributionPoints = urls.CRLDistributionPoints
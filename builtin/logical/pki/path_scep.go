@@ -0,0 +1,421 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package pki
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/builtin/logical/pki/issuing"
+	"github.com/hashicorp/vault/helper/pkcs7"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/certutil"
+	"github.com/hashicorp/vault/sdk/helper/consts"
+	"github.com/hashicorp/vault/sdk/helper/errutil"
+	"github.com/hashicorp/vault/sdk/logical"
+	"golang.org/x/crypto/cryptobyte"
+	cryptobyte_asn1 "golang.org/x/crypto/cryptobyte/asn1"
+)
+
+const (
+	scepPath = "scep"
+
+	scepOperationGetCACert    = "GetCACert"
+	scepOperationGetCACaps    = "GetCACaps"
+	scepOperationPKIOperation = "PKIOperation"
+
+	// SCEP messages wrap a single CSR; leave generous room for large RSA
+	// keys and certificate chains in the signing envelope.
+	scepMaximumRequestSize = 64 * 1024
+
+	// RFC 8894 Section 3.2.1.2: messageType values.
+	scepMessageTypeCertRep = "3"
+	scepMessageTypePKCSReq = "19"
+
+	// RFC 8894 Section 3.2.1.3: pkiStatus values.
+	scepStatusSuccess = "0"
+	scepStatusFailure = "2"
+
+	// RFC 8894 Section 3.2.1.4: failInfo values.
+	scepFailBadAlg          = "0"
+	scepFailBadMessageCheck = "1"
+	scepFailBadRequest      = "2"
+
+	// RFC 8894 Section 3.5.2: we support everything SCEPStandard implies.
+	scepCACaps = "POSTPKIOperation\nSHA-256\nAES\nSCEPStandard\n"
+
+	pathScepHelpSyn  = `An endpoint implementing the SCEP protocol`
+	pathScepHelpDesc = `This API endpoint implements the GetCACert, GetCACaps and
+ PKIOperation operations of the SCEP protocol defined in RFC 8894, with its
+ own authentication and argument syntax that does not follow conventional
+ Vault operations. A SCEP client should be used to interact with this
+ endpoint; certificate requests are authorized by one-time challenge
+ passwords generated through the scep/challenge endpoint.`
+)
+
+var (
+	oidScepMessageType    = asn1.ObjectIdentifier{2, 16, 840, 1, 113733, 1, 9, 2}
+	oidScepPKIStatus      = asn1.ObjectIdentifier{2, 16, 840, 1, 113733, 1, 9, 3}
+	oidScepFailInfo       = asn1.ObjectIdentifier{2, 16, 840, 1, 113733, 1, 9, 4}
+	oidScepSenderNonce    = asn1.ObjectIdentifier{2, 16, 840, 1, 113733, 1, 9, 5}
+	oidScepRecipientNonce = asn1.ObjectIdentifier{2, 16, 840, 1, 113733, 1, 9, 6}
+	oidScepTransactionID  = asn1.ObjectIdentifier{2, 16, 840, 1, 113733, 1, 9, 7}
+	oidChallengePassword  = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 7}
+)
+
+func pathScep(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: scepPath,
+		Fields: map[string]*framework.FieldSchema{
+			"operation": {
+				Type:        framework.TypeString,
+				Description: `The SCEP operation: GetCACert, GetCACaps or PKIOperation.`,
+			},
+			"message": {
+				Type:        framework.TypeString,
+				Description: `The base64 encoded PKIOperation message, when sent with GET.`,
+			},
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ReadOperation: &framework.PathOperation{
+				Callback:                    b.pathScepHandler,
+				ForwardPerformanceSecondary: false,
+				ForwardPerformanceStandby:   true,
+			},
+			logical.UpdateOperation: &framework.PathOperation{
+				Callback:                    b.pathScepHandler,
+				ForwardPerformanceSecondary: false,
+				ForwardPerformanceStandby:   true,
+			},
+		},
+
+		HelpSynopsis:    pathScepHelpSyn,
+		HelpDescription: pathScepHelpDesc,
+	}
+}
+
+func (b *backend) pathScepHandler(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	sc := b.makeStorageContext(ctx, req.Storage)
+
+	config, err := sc.getScepConfig()
+	if err != nil {
+		return nil, err
+	}
+	if !config.Enabled {
+		return scepErrorResponse(http.StatusNotFound, "SCEP is not enabled on this mount"), nil
+	}
+
+	// As a binary path, POST bodies are not parsed and the operation must
+	// be read from the query string directly.
+	operation := data.Get("operation").(string)
+	if operation == "" && req.HTTPRequest != nil && req.HTTPRequest.URL != nil {
+		operation = req.HTTPRequest.URL.Query().Get("operation")
+	}
+
+	switch operation {
+	case scepOperationGetCACaps:
+		return scepRawResponse("text/plain", []byte(scepCACaps)), nil
+	case scepOperationGetCACert:
+		return b.scepGetCACert(sc, config)
+	case scepOperationPKIOperation:
+		var message []byte
+		if req.Operation == logical.UpdateOperation {
+			message, err = scepReadBody(req)
+		} else {
+			// Query string decoding turns an unescaped '+' into a space.
+			message, err = base64.StdEncoding.DecodeString(strings.ReplaceAll(data.Get("message").(string), " ", "+"))
+		}
+		if err != nil {
+			return scepErrorResponse(http.StatusBadRequest, err.Error()), nil
+		}
+		return b.scepPKIOperation(sc, req, config, message)
+	default:
+		return scepErrorResponse(http.StatusBadRequest, fmt.Sprintf("unsupported SCEP operation %q", operation)), nil
+	}
+}
+
+// fetchScepCA loads the issuer SCEP requests are encrypted to. PKCS#7
+// enveloped data is only decryptable with RSA keys held by Vault.
+func (sc *storageContext) fetchScepCA(config *scepConfigEntry) (*certutil.CAInfoBundle, error) {
+	caInfo, err := sc.fetchCAInfo(config.IssuerRef, issuing.IssuanceUsage)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := caInfo.PrivateKey.(*rsa.PrivateKey); !ok {
+		return nil, errutil.UserError{Err: "SCEP requires an issuer with an RSA key"}
+	}
+
+	return caInfo, nil
+}
+
+func (b *backend) scepGetCACert(sc *storageContext, config *scepConfigEntry) (*logical.Response, error) {
+	caInfo, err := sc.fetchScepCA(config)
+	if err != nil {
+		return nil, err
+	}
+
+	// RFC 8894 Section 4.2.1: a lone CA is returned as a DER certificate,
+	// otherwise the CA comes first in a degenerate PKCS#7 with its chain.
+	chain := caInfo.GetFullChain()
+	if len(chain) == 1 {
+		return scepRawResponse("application/x-x509-ca-cert", chain[0].Bytes), nil
+	}
+
+	var der []byte
+	for _, cert := range chain {
+		der = append(der, cert.Bytes...)
+	}
+	p7, err := pkcs7.DegenerateCertificate(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed encoding certificates: %w", err)
+	}
+
+	return scepRawResponse("application/x-x509-ca-ra-cert", p7), nil
+}
+
+// scepPKIOperation handles a PKCSReq: the client's CSR, encrypted to the CA
+// and signed with the client's (usually self-signed) certificate. Failures
+// after the message is authenticated are reported in a signed CertRep.
+func (b *backend) scepPKIOperation(sc *storageContext, req *logical.Request, config *scepConfigEntry, message []byte) (*logical.Response, error) {
+	// Challenges are consumed and certificates stored, so forward this
+	// request on to the primary.
+	if b.System().ReplicationState().HasState(consts.ReplicationPerformanceStandby) {
+		return nil, logical.ErrReadOnly
+	}
+
+	caInfo, err := sc.fetchScepCA(config)
+	if err != nil {
+		return nil, err
+	}
+
+	p7, err := pkcs7.Parse(message)
+	if err != nil {
+		return scepErrorResponse(http.StatusBadRequest, fmt.Sprintf("failed parsing SCEP message: %v", err)), nil
+	}
+	if err := p7.Verify(); err != nil {
+		return scepErrorResponse(http.StatusBadRequest, fmt.Sprintf("failed verifying SCEP message: %v", err)), nil
+	}
+	signer := p7.GetOnlySigner()
+	if signer == nil {
+		return scepErrorResponse(http.StatusBadRequest, "SCEP message must have exactly one signer"), nil
+	}
+
+	var messageType, transactionID string
+	var senderNonce []byte
+	if err := errors.Join(
+		p7.UnmarshalSignedAttribute(oidScepMessageType, &messageType),
+		p7.UnmarshalSignedAttribute(oidScepTransactionID, &transactionID),
+		p7.UnmarshalSignedAttribute(oidScepSenderNonce, &senderNonce),
+	); err != nil {
+		return scepErrorResponse(http.StatusBadRequest, fmt.Sprintf("SCEP message is missing required attributes: %v", err)), nil
+	}
+
+	rep := &scepCertRep{
+		caInfo:         caInfo,
+		recipient:      signer,
+		transactionID:  transactionID,
+		recipientNonce: senderNonce,
+	}
+
+	// The response is encrypted to the signer, which PKCS#7 only supports
+	// for RSA keys.
+	if _, ok := signer.PublicKey.(*rsa.PublicKey); !ok {
+		return rep.failure(scepFailBadAlg)
+	}
+	if messageType != scepMessageTypePKCSReq {
+		return rep.failure(scepFailBadRequest)
+	}
+
+	envelope, err := pkcs7.Parse(p7.Content)
+	if err != nil {
+		return rep.failure(scepFailBadMessageCheck)
+	}
+	csrBytes, err := envelope.Decrypt(caInfo.Certificate, caInfo.PrivateKey)
+	if err != nil {
+		return rep.failure(scepFailBadMessageCheck)
+	}
+	csr, err := x509.ParseCertificateRequest(csrBytes)
+	if err != nil || csr.CheckSignature() != nil {
+		return rep.failure(scepFailBadMessageCheck)
+	}
+
+	challenge, err := scepChallengePassword(csr)
+	if err != nil {
+		return rep.failure(scepFailBadRequest)
+	}
+	challengeEntry, err := sc.consumeScepChallenge(challenge)
+	if err != nil {
+		return nil, err
+	}
+	if challengeEntry == nil {
+		return rep.failure(scepFailBadRequest)
+	}
+
+	role, err := b.GetRole(sc.Context, sc.Storage, challengeEntry.Role)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return rep.failure(scepFailBadRequest)
+	}
+
+	parsedBundle, err := b.signEnrollmentCSR(sc, req, role, csr)
+	if err != nil {
+		if _, ok := err.(errutil.UserError); ok {
+			b.Logger().Debug("refusing to sign SCEP request", "transaction_id", transactionID, "error", err)
+			return rep.failure(scepFailBadRequest)
+		}
+		return nil, err
+	}
+
+	return rep.success(parsedBundle.Certificate)
+}
+
+// scepCertRep builds the signed CertRep responses to a PKIOperation.
+type scepCertRep struct {
+	caInfo         *certutil.CAInfoBundle
+	recipient      *x509.Certificate
+	transactionID  string
+	recipientNonce []byte
+}
+
+func (r *scepCertRep) success(cert *x509.Certificate) (*logical.Response, error) {
+	degenerate, err := pkcs7.DegenerateCertificate(cert.Raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed encoding certificate: %w", err)
+	}
+	envelope, err := pkcs7.EncryptUsingAlgorithm(degenerate, []*x509.Certificate{r.recipient}, pkcs7.EncryptionAlgorithmAES128CBC)
+	if err != nil {
+		return nil, fmt.Errorf("failed encrypting certificate: %w", err)
+	}
+
+	return r.sign(envelope, []pkcs7.Attribute{
+		{Type: oidScepPKIStatus, Value: scepStatusSuccess},
+	})
+}
+
+func (r *scepCertRep) failure(failInfo string) (*logical.Response, error) {
+	return r.sign(nil, []pkcs7.Attribute{
+		{Type: oidScepPKIStatus, Value: scepStatusFailure},
+		{Type: oidScepFailInfo, Value: failInfo},
+	})
+}
+
+func (r *scepCertRep) sign(content []byte, attrs []pkcs7.Attribute) (*logical.Response, error) {
+	senderNonce, err := uuid.GenerateRandomBytesWithReader(16, rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	signedData, err := pkcs7.NewSignedData(content)
+	if err != nil {
+		return nil, err
+	}
+	attrs = append(attrs,
+		pkcs7.Attribute{Type: oidScepMessageType, Value: scepMessageTypeCertRep},
+		pkcs7.Attribute{Type: oidScepTransactionID, Value: r.transactionID},
+		pkcs7.Attribute{Type: oidScepSenderNonce, Value: senderNonce},
+		pkcs7.Attribute{Type: oidScepRecipientNonce, Value: r.recipientNonce},
+	)
+	if err := signedData.AddSigner(r.caInfo.Certificate, r.caInfo.PrivateKey, pkcs7.SignerInfoConfig{
+		ExtraSignedAttributes: attrs,
+	}); err != nil {
+		return nil, fmt.Errorf("failed signing SCEP response: %w", err)
+	}
+	der, err := signedData.Finish()
+	if err != nil {
+		return nil, fmt.Errorf("failed signing SCEP response: %w", err)
+	}
+
+	return scepRawResponse("application/x-pki-message", der), nil
+}
+
+// scepChallengePassword returns the challengePassword attribute of the CSR,
+// which crypto/x509 does not expose.
+func scepChallengePassword(csr *x509.CertificateRequest) (string, error) {
+	input := cryptobyte.String(csr.RawTBSCertificateRequest)
+	var tbs, attrs cryptobyte.String
+	if !input.ReadASN1(&tbs, cryptobyte_asn1.SEQUENCE) ||
+		!tbs.SkipASN1(cryptobyte_asn1.INTEGER) ||
+		!tbs.SkipASN1(cryptobyte_asn1.SEQUENCE) ||
+		!tbs.SkipASN1(cryptobyte_asn1.SEQUENCE) ||
+		!tbs.ReadASN1(&attrs, cryptobyte_asn1.Tag(0).ContextSpecific().Constructed()) {
+		return "", errors.New("malformed CSR attributes")
+	}
+
+	for !attrs.Empty() {
+		var attr, values cryptobyte.String
+		var oid asn1.ObjectIdentifier
+		if !attrs.ReadASN1(&attr, cryptobyte_asn1.SEQUENCE) ||
+			!attr.ReadASN1ObjectIdentifier(&oid) ||
+			!attr.ReadASN1(&values, cryptobyte_asn1.SET) {
+			return "", errors.New("malformed CSR attributes")
+		}
+		if !oid.Equal(oidChallengePassword) {
+			continue
+		}
+
+		var value cryptobyte.String
+		var tag cryptobyte_asn1.Tag
+		if !values.ReadAnyASN1(&value, &tag) {
+			return "", errors.New("malformed challenge password")
+		}
+		switch tag {
+		case cryptobyte_asn1.PrintableString, cryptobyte_asn1.UTF8String, cryptobyte_asn1.IA5String:
+			return string(value), nil
+		default:
+			return "", fmt.Errorf("unsupported challenge password encoding %v", tag)
+		}
+	}
+
+	return "", errors.New("CSR has no challenge password")
+}
+
+func scepReadBody(req *logical.Request) ([]byte, error) {
+	// NOTE: Writing an empty update request to Vault causes a nil request.HTTPRequest, and that object
+	//       says that it is possible for its Body element to be nil as well, so check both just in case.
+	if req.HTTPRequest == nil || req.HTTPRequest.Body == nil {
+		return nil, errors.New("no SCEP message provided")
+	}
+
+	body, err := io.ReadAll(io.LimitReader(req.HTTPRequest.Body, scepMaximumRequestSize))
+	if err != nil {
+		return nil, errors.New("failed reading request body")
+	}
+	if len(body) >= scepMaximumRequestSize {
+		return nil, errors.New("request is too large")
+	}
+
+	return body, nil
+}
+
+func scepRawResponse(contentType string, body []byte) *logical.Response {
+	return &logical.Response{
+		Data: map[string]interface{}{
+			logical.HTTPContentType: contentType,
+			logical.HTTPStatusCode:  http.StatusOK,
+			logical.HTTPRawBody:     body,
+		},
+	}
+}
+
+func scepErrorResponse(status int, msg string) *logical.Response {
+	return &logical.Response{
+		Data: map[string]interface{}{
+			logical.HTTPContentType: "text/plain",
+			logical.HTTPStatusCode:  status,
+			logical.HTTPRawBody:     []byte(msg + "\n"),
+		},
+	}
+}
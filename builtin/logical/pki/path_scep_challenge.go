@@ -0,0 +1,181 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package pki
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	scepChallengePrefix     = "scep/challenge/"
+	defaultScepChallengeTTL = 24 * time.Hour
+)
+
+// scepChallengeEntry is stored under the hash of the challenge password, so
+// the password itself is only ever returned to the operator creating it.
+type scepChallengeEntry struct {
+	Role       string    `json:"role"`
+	Expiration time.Time `json:"expiration"`
+}
+
+func scepChallengePath(challenge string) string {
+	hash := sha256.Sum256([]byte(challenge))
+	return scepChallengePrefix + hex.EncodeToString(hash[:])
+}
+
+// consumeScepChallenge returns the entry for the challenge password and
+// deletes it, so that each challenge enrolls at most once. Expired and
+// unknown challenges return nil.
+func (sc *storageContext) consumeScepChallenge(challenge string) (*scepChallengeEntry, error) {
+	sc.Backend.scepChallengeLock.Lock()
+	defer sc.Backend.scepChallengeLock.Unlock()
+
+	path := scepChallengePath(challenge)
+	entry, err := sc.Storage.Get(sc.Context, path)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+	if err := sc.Storage.Delete(sc.Context, path); err != nil {
+		return nil, err
+	}
+
+	var challengeEntry scepChallengeEntry
+	if err := entry.DecodeJSON(&challengeEntry); err != nil {
+		return nil, fmt.Errorf("unable to decode SCEP challenge: %w", err)
+	}
+	if time.Now().After(challengeEntry.Expiration) {
+		return nil, nil
+	}
+
+	return &challengeEntry, nil
+}
+
+func pathScepChallenge(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "scep/challenge",
+
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixPKI,
+			OperationVerb:   "generate",
+			OperationSuffix: "scep-challenge",
+		},
+
+		Fields: map[string]*framework.FieldSchema{
+			"role": {
+				Type:        framework.TypeString,
+				Description: `The role to issue the certificate requested with this challenge against`,
+				Required:    true,
+			},
+			"ttl": {
+				Type: framework.TypeDurationSecond,
+				Description: `How long the challenge may be used for;
+defaults to 24 hours.`,
+			},
+		},
+
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.UpdateOperation: &framework.PathOperation{
+				Callback: b.pathScepChallengeWrite,
+				Responses: map[int][]framework.Response{
+					http.StatusOK: {{
+						Description: "OK",
+						Fields: map[string]*framework.FieldSchema{
+							"challenge": {
+								Type:        framework.TypeString,
+								Description: `The one-time challenge password`,
+								Required:    true,
+							},
+							"role": {
+								Type:        framework.TypeString,
+								Description: `The role the challenge issues against`,
+								Required:    true,
+							},
+							"expiration": {
+								Type:        framework.TypeString,
+								Description: `When the challenge expires`,
+								Required:    true,
+							},
+						},
+					}},
+				},
+				// Challenges are consumed by the cluster they were created
+				// on, so they are kept in local storage.
+				ForwardPerformanceStandby:   true,
+				ForwardPerformanceSecondary: false,
+			},
+		},
+
+		HelpSynopsis:    pathScepChallengeHelpSyn,
+		HelpDescription: pathScepChallengeHelpDesc,
+	}
+}
+
+func (b *backend) pathScepChallengeWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	roleName := data.Get("role").(string)
+	if roleName == "" {
+		return logical.ErrorResponse("missing role"), nil
+	}
+	role, err := b.GetRole(ctx, req.Storage, roleName)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return logical.ErrorResponse("role %q does not exist", roleName), nil
+	}
+
+	ttl := defaultScepChallengeTTL
+	if ttlRaw, ok := data.GetOk("ttl"); ok {
+		ttl = time.Duration(ttlRaw.(int)) * time.Second
+	}
+	if ttl <= 0 {
+		return logical.ErrorResponse("ttl must be positive"), nil
+	}
+
+	random, err := uuid.GenerateRandomBytesWithReader(16, rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed generating SCEP challenge: %w", err)
+	}
+	challenge := hex.EncodeToString(random)
+
+	challengeEntry := &scepChallengeEntry{
+		Role:       roleName,
+		Expiration: time.Now().Add(ttl),
+	}
+	entry, err := logical.StorageEntryJSON(scepChallengePath(challenge), challengeEntry)
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Put(ctx, entry); err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"challenge":  challenge,
+			"role":       challengeEntry.Role,
+			"expiration": challengeEntry.Expiration.Format(time.RFC3339),
+		},
+	}, nil
+}
+
+const pathScepChallengeHelpSyn = `Generate a one-time SCEP challenge password.`
+
+const pathScepChallengeHelpDesc = `
+This endpoint generates a challenge password which a SCEP client includes in
+its certificate request to enroll once against the given role. The challenge
+is consumed by its first use, successful or not, and expires after its ttl.
+`
@@ -0,0 +1,353 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package pki
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/helper/pkcs7"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/cryptobyte"
+	cryptobyte_asn1 "golang.org/x/crypto/cryptobyte/asn1"
+)
+
+// scepRequest performs a SCEP operation. PKIOperation messages are POSTed
+// as the raw body unless message is set for a GET.
+func scepRequest(t *testing.T, b *backend, s logical.Storage, operation string, body []byte, message string) *logical.Response {
+	t.Helper()
+
+	req := &logical.Request{
+		Operation:  logical.ReadOperation,
+		Path:       "scep",
+		Storage:    s,
+		MountPoint: "pki/",
+		Data: map[string]interface{}{
+			"operation": operation,
+		},
+	}
+	if message != "" {
+		req.Data["message"] = message
+	}
+	if body != nil {
+		req.Operation = logical.UpdateOperation
+		req.Data = nil
+		req.HTTPRequest = &http.Request{
+			URL:  &url.URL{RawQuery: url.Values{"operation": {operation}}.Encode()},
+			Body: io.NopCloser(bytes.NewReader(body)),
+		}
+	}
+
+	resp, err := b.HandleRequest(context.Background(), req)
+	require.NoError(t, err)
+	require.NotNil(t, resp)
+	return resp
+}
+
+func scepBody(t *testing.T, resp *logical.Response, status int, contentType string) []byte {
+	t.Helper()
+
+	require.Equal(t, status, resp.Data[logical.HTTPStatusCode], "unexpected SCEP response: %s", resp.Data[logical.HTTPRawBody])
+	if status != http.StatusOK {
+		return nil
+	}
+	require.Equal(t, contentType, resp.Data[logical.HTTPContentType])
+	return resp.Data[logical.HTTPRawBody].([]byte)
+}
+
+// scepTestClient is a SCEP client with the self-signed certificate clients
+// use to sign their requests before they are enrolled.
+type scepTestClient struct {
+	key  *rsa.PrivateKey
+	cert *x509.Certificate
+}
+
+func newScepTestClient(t *testing.T) *scepTestClient {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "scep client"},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return &scepTestClient{key: key, cert: cert}
+}
+
+// csr returns a CSR carrying the challenge password. crypto/x509 cannot
+// encode the attribute, so the request is rebuilt around its subject and
+// public key and signed again.
+func (c *scepTestClient) csr(t *testing.T, commonName, challenge string) []byte {
+	t.Helper()
+
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject: pkix.Name{CommonName: commonName},
+	}, c.key)
+	require.NoError(t, err)
+	csr, err := x509.ParseCertificateRequest(der)
+	require.NoError(t, err)
+
+	var tbs cryptobyte.Builder
+	tbs.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+		b.AddASN1Int64(0)
+		b.AddBytes(csr.RawSubject)
+		b.AddBytes(csr.RawSubjectPublicKeyInfo)
+		b.AddASN1(cryptobyte_asn1.Tag(0).ContextSpecific().Constructed(), func(b *cryptobyte.Builder) {
+			b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+				b.AddASN1ObjectIdentifier(oidChallengePassword)
+				b.AddASN1(cryptobyte_asn1.SET, func(b *cryptobyte.Builder) {
+					b.AddASN1(cryptobyte_asn1.PrintableString, func(b *cryptobyte.Builder) {
+						b.AddBytes([]byte(challenge))
+					})
+				})
+			})
+		})
+	})
+	tbsDER := tbs.BytesOrPanic()
+	digest := sha256.Sum256(tbsDER)
+	signature, err := rsa.SignPKCS1v15(rand.Reader, c.key, crypto.SHA256, digest[:])
+	require.NoError(t, err)
+
+	var out cryptobyte.Builder
+	out.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+		b.AddBytes(tbsDER)
+		b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+			b.AddASN1ObjectIdentifier(asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 11})
+			b.AddASN1NULL()
+		})
+		b.AddASN1BitString(signature)
+	})
+	return out.BytesOrPanic()
+}
+
+// pkcsReq encrypts the CSR to the CA and signs it into a PKCSReq message.
+func (c *scepTestClient) pkcsReq(t *testing.T, ca *x509.Certificate, csr []byte, transactionID string, nonce []byte) []byte {
+	t.Helper()
+
+	envelope, err := pkcs7.EncryptUsingAlgorithm(csr, []*x509.Certificate{ca}, pkcs7.EncryptionAlgorithmAES128CBC)
+	require.NoError(t, err)
+	signedData, err := pkcs7.NewSignedData(envelope)
+	require.NoError(t, err)
+	require.NoError(t, signedData.AddSigner(c.cert, c.key, pkcs7.SignerInfoConfig{
+		ExtraSignedAttributes: []pkcs7.Attribute{
+			{Type: oidScepMessageType, Value: scepMessageTypePKCSReq},
+			{Type: oidScepTransactionID, Value: transactionID},
+			{Type: oidScepSenderNonce, Value: nonce},
+		},
+	}))
+	der, err := signedData.Finish()
+	require.NoError(t, err)
+	return der
+}
+
+// certRep verifies a CertRep from the CA and returns its status, failInfo
+// and, on success, the issued certificate.
+func (c *scepTestClient) certRep(t *testing.T, resp *logical.Response, ca *x509.Certificate, transactionID string, nonce []byte) (string, string, *x509.Certificate) {
+	t.Helper()
+
+	p7, err := pkcs7.Parse(scepBody(t, resp, http.StatusOK, "application/x-pki-message"))
+	require.NoError(t, err)
+	require.NoError(t, p7.Verify())
+	require.Equal(t, ca.Raw, p7.GetOnlySigner().Raw)
+
+	var messageType, status, gotTransactionID string
+	var recipientNonce []byte
+	require.NoError(t, p7.UnmarshalSignedAttribute(oidScepMessageType, &messageType))
+	require.NoError(t, p7.UnmarshalSignedAttribute(oidScepPKIStatus, &status))
+	require.NoError(t, p7.UnmarshalSignedAttribute(oidScepTransactionID, &gotTransactionID))
+	require.NoError(t, p7.UnmarshalSignedAttribute(oidScepRecipientNonce, &recipientNonce))
+	require.Equal(t, scepMessageTypeCertRep, messageType)
+	require.Equal(t, transactionID, gotTransactionID)
+	require.Equal(t, nonce, recipientNonce)
+
+	if status != scepStatusSuccess {
+		var failInfo string
+		require.NoError(t, p7.UnmarshalSignedAttribute(oidScepFailInfo, &failInfo))
+		return status, failInfo, nil
+	}
+
+	envelope, err := pkcs7.Parse(p7.Content)
+	require.NoError(t, err)
+	degenerate, err := envelope.Decrypt(c.cert, c.key)
+	require.NoError(t, err)
+	certs := parseCertsOnlyPKCS7(t, degenerate)
+	require.Len(t, certs, 1)
+	return status, "", certs[0]
+}
+
+func TestPki_SCEP(t *testing.T) {
+	t.Parallel()
+	b, s := CreateBackendWithStorage(t)
+
+	resp, err := CBWrite(b, s, "root/generate/internal", map[string]interface{}{
+		"common_name": "root example.com",
+		"key_type":    "rsa",
+	})
+	requireSuccessNonNilResponse(t, resp, err)
+	ca := parseCert(t, resp.Data["certificate"].(string))
+
+	resp, err = CBWrite(b, s, "issuers/generate/root/internal", map[string]interface{}{
+		"common_name": "ec root example.com",
+		"key_type":    "ec",
+		"issuer_name": "ec-root",
+	})
+	requireSuccessNonNilResponse(t, resp, err)
+
+	resp, err = CBWrite(b, s, "roles/devices", map[string]interface{}{
+		"allowed_domains":  "example.com",
+		"allow_subdomains": true,
+		"ttl":              "1h",
+		"key_type":         "rsa",
+	})
+	requireSuccessNonNilResponse(t, resp, err)
+
+	// SCEP is disabled by default.
+	scepBody(t, scepRequest(t, b, s, scepOperationGetCACaps, nil, ""), http.StatusNotFound, "")
+
+	// Only RSA issuers can decrypt SCEP requests.
+	_, err = CBWrite(b, s, "config/scep", map[string]interface{}{
+		"enabled":    true,
+		"issuer_ref": "ec-root",
+	})
+	require.Error(t, err)
+
+	resp, err = CBWrite(b, s, "config/scep", map[string]interface{}{
+		"enabled": true,
+	})
+	requireSuccessNonNilResponse(t, resp, err)
+	require.Equal(t, defaultRef, resp.Data["issuer_ref"])
+
+	caps := scepBody(t, scepRequest(t, b, s, scepOperationGetCACaps, nil, ""), http.StatusOK, "text/plain")
+	require.Contains(t, string(caps), "POSTPKIOperation")
+	caCert := scepBody(t, scepRequest(t, b, s, scepOperationGetCACert, nil, ""), http.StatusOK, "application/x-x509-ca-cert")
+	require.Equal(t, ca.Raw, caCert)
+	scepBody(t, scepRequest(t, b, s, "GetNextCACert", nil, ""), http.StatusBadRequest, "")
+
+	// Challenges are only issued for existing roles.
+	_, err = CBWrite(b, s, "scep/challenge", map[string]interface{}{"role": "missing"})
+	require.Error(t, err)
+
+	newChallenge := func() string {
+		resp, err := CBWrite(b, s, "scep/challenge", map[string]interface{}{"role": "devices"})
+		requireSuccessNonNilResponse(t, resp, err)
+		require.Equal(t, "devices", resp.Data["role"])
+		return resp.Data["challenge"].(string)
+	}
+
+	client := newScepTestClient(t)
+	nonce := []byte("0123456789abcdef")
+
+	// Enroll over POST.
+	challenge := newChallenge()
+	message := client.pkcsReq(t, ca, client.csr(t, "router.example.com", challenge), "txn-1", nonce)
+	status, _, cert := client.certRep(t, scepRequest(t, b, s, scepOperationPKIOperation, message, ""), ca, "txn-1", nonce)
+	require.Equal(t, scepStatusSuccess, status)
+	require.Equal(t, "router.example.com", cert.Subject.CommonName)
+	requireSignedBy(t, cert, ca)
+
+	resp, err = CBRead(b, s, "cert/"+serialFromCert(cert))
+	requireSuccessNonNilResponse(t, resp, err)
+
+	// Challenges are single use.
+	status, failInfo, _ := client.certRep(t, scepRequest(t, b, s, scepOperationPKIOperation, message, ""), ca, "txn-1", nonce)
+	require.Equal(t, scepStatusFailure, status)
+	require.Equal(t, scepFailBadRequest, failInfo)
+
+	// Unknown challenges are refused.
+	message = client.pkcsReq(t, ca, client.csr(t, "router.example.com", "not-a-challenge"), "txn-2", nonce)
+	status, failInfo, _ = client.certRep(t, scepRequest(t, b, s, scepOperationPKIOperation, message, ""), ca, "txn-2", nonce)
+	require.Equal(t, scepStatusFailure, status)
+	require.Equal(t, scepFailBadRequest, failInfo)
+
+	// The role still applies.
+	message = client.pkcsReq(t, ca, client.csr(t, "router.example.org", newChallenge()), "txn-3", nonce)
+	status, failInfo, _ = client.certRep(t, scepRequest(t, b, s, scepOperationPKIOperation, message, ""), ca, "txn-3", nonce)
+	require.Equal(t, scepStatusFailure, status)
+	require.Equal(t, scepFailBadRequest, failInfo)
+
+	// Enroll over GET.
+	message = client.pkcsReq(t, ca, client.csr(t, "switch.example.com", newChallenge()), "txn-4", nonce)
+	status, _, cert = client.certRep(t, scepRequest(t, b, s, scepOperationPKIOperation, nil, base64.StdEncoding.EncodeToString(message)), ca, "txn-4", nonce)
+	require.Equal(t, scepStatusSuccess, status)
+	require.Equal(t, "switch.example.com", cert.Subject.CommonName)
+
+	// Garbage is rejected before a CertRep can be built.
+	scepBody(t, scepRequest(t, b, s, scepOperationPKIOperation, []byte("garbage"), ""), http.StatusBadRequest, "")
+}
+
+// TestPki_SCEPChallengeConcurrentConsume verifies that of concurrent
+// enrollments with the same challenge, only one consumes it.
+func TestPki_SCEPChallengeConcurrentConsume(t *testing.T) {
+	t.Parallel()
+
+	b, s := CreateBackendWithStorage(t)
+	sc := b.makeStorageContext(context.Background(), s)
+
+	_, err := CBWrite(b, s, "roles/devices", map[string]interface{}{
+		"allow_any_name": true,
+	})
+	require.NoError(t, err)
+	resp, err := CBWrite(b, s, "scep/challenge", map[string]interface{}{"role": "devices"})
+	requireSuccessNonNilResponse(t, resp, err)
+	challenge := resp.Data["challenge"].(string)
+
+	// Slow reads widen the window between reading and deleting the
+	// challenge.
+	sc = b.makeStorageContext(context.Background(), &slowGetStorage{Storage: s})
+
+	var wg sync.WaitGroup
+	entries := make([]*scepChallengeEntry, 16)
+	errs := make([]error, len(entries))
+	for i := range entries {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			entries[i], errs[i] = sc.consumeScepChallenge(challenge)
+		}(i)
+	}
+	wg.Wait()
+
+	consumed := 0
+	for i, entry := range entries {
+		require.NoError(t, errs[i])
+		if entry != nil {
+			require.Equal(t, "devices", entry.Role)
+			consumed++
+		}
+	}
+	require.Equal(t, 1, consumed)
+}
+
+type slowGetStorage struct {
+	logical.Storage
+}
+
+func (s *slowGetStorage) Get(ctx context.Context, key string) (*logical.StorageEntry, error) {
+	entry, err := s.Storage.Get(ctx, key)
+	time.Sleep(10 * time.Millisecond)
+	return entry, err
+}
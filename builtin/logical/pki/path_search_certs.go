@@ -0,0 +1,251 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package pki
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-secure-stdlib/parseutil"
+	"github.com/hashicorp/vault/builtin/logical/pki/issuing"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/ryanuber/go-glob"
+)
+
+const defaultCertSearchLimit = 100
+
+func pathSearchCerts(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "certs/search",
+
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixPKI,
+			OperationVerb:   "search",
+			OperationSuffix: "certs",
+		},
+
+		Fields: map[string]*framework.FieldSchema{
+			"expiring_before": {
+				Type: framework.TypeString,
+				Description: `Only return certificates expiring before this
+time, given as an RFC 3339 timestamp or as a duration from now.`,
+			},
+			issuerRefParam: {
+				Type:        framework.TypeString,
+				Description: `Only return certificates issued by this issuer.`,
+			},
+			"role": {
+				Type:        framework.TypeString,
+				Description: `Only return certificates issued through this role.`,
+			},
+			"common_name": {
+				Type: framework.TypeString,
+				Description: `Only return certificates whose common name
+matches this case-insensitive glob pattern.`,
+			},
+			"revoked": {
+				Type: framework.TypeBool,
+				Description: `When set, only return certificates which are
+(true) or are not (false) revoked.`,
+			},
+			"after": {
+				Type: framework.TypeString,
+				Description: `Only return certificates with a serial number
+after this one, for paging through results.`,
+			},
+			"limit": {
+				Type:        framework.TypeInt,
+				Description: `The maximum number of certificates to return; defaults to 100.`,
+				Default:     defaultCertSearchLimit,
+			},
+		},
+
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ReadOperation: &framework.PathOperation{
+				Callback: b.pathSearchCertsRead,
+				Responses: map[int][]framework.Response{
+					http.StatusOK: {{
+						Description: "OK",
+						Fields: map[string]*framework.FieldSchema{
+							"keys": {
+								Type:        framework.TypeStringSlice,
+								Description: `The serial numbers of the matching certificates, in order`,
+								Required:    false,
+							},
+							"key_info": {
+								Type:        framework.TypeMap,
+								Description: `Index information about each matching certificate`,
+								Required:    false,
+							},
+						},
+					}},
+				},
+			},
+		},
+
+		HelpSynopsis:    pathSearchCertsHelpSyn,
+		HelpDescription: pathSearchCertsHelpDesc,
+	}
+}
+
+func (b *backend) pathSearchCertsRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	sc := b.makeStorageContext(ctx, req.Storage)
+
+	// Until every certificate stored before the index existed has been
+	// indexed, a search would silently miss them.
+	status, err := sc.getCertIndexStatus()
+	if err != nil {
+		return nil, err
+	}
+	if !status.Complete {
+		return logical.ErrorResponse("the certificate index is still being built; certs/search is unavailable until it completes"), nil
+	}
+
+	var expiringBefore time.Time
+	if raw := data.Get("expiring_before").(string); raw != "" {
+		var err error
+		expiringBefore, err = time.Parse(time.RFC3339, raw)
+		if err != nil {
+			duration, durationErr := parseutil.ParseDurationSecond(raw)
+			if durationErr != nil {
+				return logical.ErrorResponse("expiring_before must be an RFC 3339 timestamp or a duration: %v", raw), nil
+			}
+			expiringBefore = time.Now().Add(duration)
+		}
+	}
+
+	var issuerId issuing.IssuerID
+	if issuerRef := data.Get(issuerRefParam).(string); issuerRef != "" {
+		var err error
+		issuerId, err = sc.resolveIssuerReference(issuerRef)
+		if err != nil {
+			if issuerId == issuing.IssuerRefNotFound {
+				return logical.ErrorResponse("unable to find issuer %q", issuerRef), nil
+			}
+			return nil, err
+		}
+	}
+
+	role := data.Get("role").(string)
+	commonName := strings.ToLower(data.Get("common_name").(string))
+	revokedRaw, filterRevoked := data.GetOk("revoked")
+	after := normalizeSerial(data.Get("after").(string))
+	limit := data.Get("limit").(int)
+	if limit <= 0 {
+		return logical.ErrorResponse("limit must be positive"), nil
+	}
+
+	// Walk only the secondary index which narrows the search the most;
+	// the remaining filters are checked against each index record.
+	var candidates []string
+	switch {
+	case role != "":
+		candidates, err = sc.Storage.List(ctx, certIndexRolePrefix+role+"/")
+	case issuerId != "":
+		candidates, err = sc.Storage.List(ctx, certIndexIssuerPrefix+issuerId.String()+"/")
+	case commonName != "" && !strings.Contains(commonName, glob.GLOB):
+		candidates, err = sc.Storage.List(ctx, certIndexCNPrefix+certIndexCNKey(commonName)+"/")
+	case !expiringBefore.IsZero():
+		candidates, err = sc.listCertIndexExpiringBefore(expiringBefore)
+	default:
+		candidates, err = sc.Storage.List(ctx, certIndexSerialPrefix)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed listing certificate index: %w", err)
+	}
+	sort.Strings(candidates)
+
+	keys := []string{}
+	keyInfo := map[string]interface{}{}
+	for _, serial := range candidates {
+		if len(keys) >= limit {
+			break
+		}
+		if after != "" && serial <= after {
+			continue
+		}
+
+		record, err := sc.fetchCertIndex(serial)
+		if err != nil {
+			return nil, err
+		}
+		if record == nil {
+			continue
+		}
+
+		switch {
+		case !expiringBefore.IsZero() && !record.NotAfter.Before(expiringBefore):
+			continue
+		case issuerId != "" && record.IssuerID != issuerId:
+			continue
+		case role != "" && record.Role != role:
+			continue
+		case commonName != "" && !glob.Glob(commonName, strings.ToLower(record.CommonName)):
+			continue
+		}
+
+		revokedEntry, err := sc.Storage.Get(ctx, revokedPath+serial)
+		if err != nil {
+			return nil, err
+		}
+		revoked := revokedEntry != nil
+		if filterRevoked && revoked != revokedRaw.(bool) {
+			continue
+		}
+
+		serialNumber := denormalizeSerial(serial)
+		keys = append(keys, serialNumber)
+		keyInfo[serialNumber] = map[string]interface{}{
+			"not_after":   record.NotAfter.Format(time.RFC3339),
+			"issuer_id":   record.IssuerID.String(),
+			"role":        record.Role,
+			"common_name": record.CommonName,
+			"revoked":     revoked,
+		}
+	}
+
+	return logical.ListResponseWithInfo(keys, keyInfo), nil
+}
+
+// listCertIndexExpiringBefore returns the serials of the expiry index's
+// daily buckets up to and including the one holding the given time.
+func (sc *storageContext) listCertIndexExpiringBefore(before time.Time) ([]string, error) {
+	days, err := sc.Storage.List(sc.Context, certIndexExpiryPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	last := before.UTC().Format(certIndexExpiryLayout)
+	var serials []string
+	for _, day := range days {
+		if strings.TrimSuffix(day, "/") > last {
+			continue
+		}
+		bucket, err := sc.Storage.List(sc.Context, certIndexExpiryPrefix+day)
+		if err != nil {
+			return nil, err
+		}
+		serials = append(serials, bucket...)
+	}
+
+	return serials, nil
+}
+
+const pathSearchCertsHelpSyn = `Search the certificates issued by this mount.`
+
+const pathSearchCertsHelpDesc = `
+This endpoint searches the certificate index for certificates matching all
+of the given filters, returning their serial numbers along with when they
+expire, their issuer, role, common name and whether they were revoked.
+
+Results are ordered by serial number and limited to "limit" entries; pass the
+last serial number returned as "after" to fetch the next page. Certificates
+issued before the index existed have no role recorded; they are indexed in
+the background after upgrading, and searches fail until that completes.
+`
@@ -0,0 +1,224 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package pki
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/builtin/logical/pki/issuing"
+	"github.com/hashicorp/vault/sdk/helper/testhelpers/schema"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPki_SearchCerts(t *testing.T) {
+	t.Parallel()
+
+	b, s := CreateBackendWithStorage(t)
+
+	resp, err := CBWrite(b, s, "root/generate/internal", map[string]interface{}{
+		"common_name": "Root X1",
+		"key_type":    "ec",
+		"issuer_name": "root",
+		"ttl":         "72h",
+	})
+	requireSuccessNonNilResponse(t, resp, err, "failed generating root")
+	rootId := resp.Data["issuer_id"].(issuing.IssuerID).String()
+
+	for _, role := range []string{"web", "db"} {
+		_, err = CBWrite(b, s, "roles/"+role, map[string]interface{}{
+			"allow_any_name": true,
+			"key_type":       "ec",
+			"max_ttl":        "48h",
+		})
+		require.NoError(t, err)
+	}
+
+	issue := func(role, cn, ttl string) string {
+		resp, err := CBWrite(b, s, "issue/"+role, map[string]interface{}{
+			"common_name": cn,
+			"ttl":         ttl,
+		})
+		requireSuccessNonNilResponse(t, resp, err, "failed issuing "+cn)
+		return resp.Data["serial_number"].(string)
+	}
+	webShort := issue("web", "www.example.com", "2h")
+	webLong := issue("web", "api.example.com", "40h")
+	dbShort := issue("db", "DB1.internal", "3h")
+
+	search := func(data map[string]interface{}) []string {
+		resp, err := CBReq(b, s, logical.ReadOperation, "certs/search", data)
+		requireSuccessNonNilResponse(t, resp, err, "failed searching certs")
+		schema.ValidateResponse(t, schema.GetResponseSchema(t, b.Route("certs/search"), logical.ReadOperation), resp, true)
+		keys, ok := resp.Data["keys"].([]string)
+		if !ok {
+			return nil
+		}
+		return keys
+	}
+
+	all := search(nil)
+	require.Len(t, all, 4, "expected root and three leaf certificates")
+
+	require.ElementsMatch(t, []string{webShort, webLong}, search(map[string]interface{}{"role": "web"}))
+	require.ElementsMatch(t, []string{dbShort}, search(map[string]interface{}{"role": "db"}))
+	require.ElementsMatch(t, []string{webShort, dbShort}, search(map[string]interface{}{"expiring_before": "24h"}))
+	require.ElementsMatch(t, []string{webShort}, search(map[string]interface{}{"expiring_before": "24h", "role": "web"}))
+	require.ElementsMatch(t, []string{webShort, webLong}, search(map[string]interface{}{"common_name": "*.EXAMPLE.com"}))
+	require.ElementsMatch(t, []string{dbShort}, search(map[string]interface{}{"common_name": "db1.internal"}))
+	require.Len(t, search(map[string]interface{}{"issuer_ref": "root"}), 4)
+
+	_, err = CBReq(b, s, logical.ReadOperation, "certs/search", map[string]interface{}{"issuer_ref": "missing"})
+	require.Error(t, err)
+
+	// Root certificates are indexed without a role.
+	rootResp, err := CBReq(b, s, logical.ReadOperation, "certs/search", map[string]interface{}{"common_name": "root x1"})
+	requireSuccessNonNilResponse(t, rootResp, err)
+	info := rootResp.Data["key_info"].(map[string]interface{})
+	require.Len(t, info, 1)
+	for _, raw := range info {
+		entry := raw.(map[string]interface{})
+		require.Equal(t, rootId, entry["issuer_id"])
+		require.Equal(t, "", entry["role"])
+		require.Equal(t, false, entry["revoked"])
+	}
+
+	_, err = CBWrite(b, s, "revoke", map[string]interface{}{"serial_number": webLong})
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{webLong}, search(map[string]interface{}{"revoked": true}))
+	require.ElementsMatch(t, []string{webShort}, search(map[string]interface{}{"revoked": false, "role": "web"}))
+
+	// Page through every certificate two at a time.
+	var paged []string
+	var after string
+	for {
+		page := search(map[string]interface{}{"limit": 2, "after": after})
+		if len(page) == 0 {
+			break
+		}
+		require.LessOrEqual(t, len(page), 2)
+		paged = append(paged, page...)
+		after = page[len(page)-1]
+	}
+	require.Equal(t, all, paged)
+}
+
+func TestPki_BackfillCertIndex(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	b, s := CreateBackendWithStorage(t)
+
+	resp, err := CBWrite(b, s, "root/generate/internal", map[string]interface{}{
+		"common_name": "Root X1",
+		"key_type":    "ec",
+	})
+	requireSuccessNonNilResponse(t, resp, err, "failed generating root")
+	rootId := resp.Data["issuer_id"].(issuing.IssuerID).String()
+
+	_, err = CBWrite(b, s, "roles/example", map[string]interface{}{
+		"allow_any_name": true,
+		"key_type":       "ec",
+	})
+	require.NoError(t, err)
+	resp, err = CBWrite(b, s, "issue/example", map[string]interface{}{
+		"common_name": "leaf.example.com",
+		"ttl":         "1h",
+	})
+	requireSuccessNonNilResponse(t, resp, err, "failed issuing leaf")
+	serial := resp.Data["serial_number"].(string)
+
+	// Drop the index, as though the certificates predated it.
+	sc := b.makeStorageContext(ctx, s)
+	serials, err := s.List(ctx, certIndexSerialPrefix)
+	require.NoError(t, err)
+	require.Len(t, serials, 2)
+	sort.Strings(serials)
+	for _, indexed := range serials {
+		require.NoError(t, sc.deleteCertIndex(indexed))
+	}
+	require.NoError(t, s.Delete(ctx, certIndexStatusPath))
+
+	// Searching fails rather than missing certificates until the index
+	// has been built.
+	_, err = CBRead(b, s, "certs/search")
+	require.ErrorContains(t, err, "still being built")
+
+	// Resume after the first certificate, as though an earlier run had
+	// been interrupted after saving its progress.
+	require.NoError(t, sc.setCertIndexStatus(&certIndexStatus{Cursor: serials[0]}))
+	count, err := sc.backfillCertIndex()
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+	record, err := sc.fetchCertIndex(serials[0])
+	require.NoError(t, err)
+	require.Nil(t, record)
+
+	status, err := sc.getCertIndexStatus()
+	require.NoError(t, err)
+	require.True(t, status.Complete)
+	require.Equal(t, serials[1], status.Cursor)
+
+	// Starting over only indexes what is missing, and a completed index
+	// is left alone.
+	require.NoError(t, sc.setCertIndexStatus(&certIndexStatus{}))
+	count, err = sc.backfillCertIndex()
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+	count, err = sc.backfillCertIndex()
+	require.NoError(t, err)
+	require.Equal(t, 0, count)
+
+	record, err = sc.fetchCertIndex(normalizeSerial(serial))
+	require.NoError(t, err)
+	require.NotNil(t, record)
+	require.Equal(t, rootId, record.IssuerID.String())
+	require.Equal(t, "leaf.example.com", record.CommonName)
+	require.Empty(t, record.Role)
+	require.True(t, record.NotAfter.Before(time.Now().Add(2*time.Hour)))
+
+	resp, err = CBReq(b, s, logical.ReadOperation, "certs/search", map[string]interface{}{"issuer_ref": "default"})
+	requireSuccessNonNilResponse(t, resp, err)
+	require.Len(t, resp.Data["keys"], 2)
+}
+
+// TestPki_BackfillCertIndexStopsOnCleanup checks that the background backfill
+// started by the backend does not outlive it.
+func TestPki_BackfillCertIndexStopsOnCleanup(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	b, s := CreateBackendWithStorage(t)
+
+	resp, err := CBWrite(b, s, "root/generate/internal", map[string]interface{}{
+		"common_name": "Root X1",
+		"key_type":    "ec",
+	})
+	requireSuccessNonNilResponse(t, resp, err, "failed generating root")
+
+	// Wait for the backfill started on initialization, then drop the index,
+	// as though the certificate predated it.
+	require.Eventually(t, func() bool { return !b.certIndexBackfillRunning.Load() }, 5*time.Second, 10*time.Millisecond)
+	sc := b.makeStorageContext(ctx, s)
+	serials, err := s.List(ctx, certIndexSerialPrefix)
+	require.NoError(t, err)
+	for _, indexed := range serials {
+		require.NoError(t, sc.deleteCertIndex(indexed))
+	}
+	require.NoError(t, s.Delete(ctx, certIndexStatusPath))
+
+	b.Cleanup(ctx)
+	require.ErrorIs(t, b.backgroundCtx.Err(), context.Canceled)
+
+	runCertIndexBackfill(b.makeStorageContext(b.backgroundCtx, s))
+	status, err := sc.getCertIndexStatus()
+	require.NoError(t, err)
+	require.False(t, status.Complete)
+	serials, err = s.List(ctx, certIndexSerialPrefix)
+	require.NoError(t, err)
+	require.Empty(t, serials)
+}
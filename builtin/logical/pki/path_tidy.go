@@ -949,6 +949,7 @@ func (b *backend) startTidyOperation(req *logical.Request, config *tidyConfig) {
 }
 
 func (b *backend) doTidyCertStore(ctx context.Context, req *logical.Request, logger hclog.Logger, config *tidyConfig) error {
+	sc := b.makeStorageContext(ctx, req.Storage)
 	serials, err := req.Storage.List(ctx, "certs/")
 	if err != nil {
 		return fmt.Errorf("error fetching list of certs: %w", err)
@@ -980,6 +981,9 @@ func (b *backend) doTidyCertStore(ctx context.Context, req *logical.Request, log
 			if err := req.Storage.Delete(ctx, "certs/"+serial); err != nil {
 				return fmt.Errorf("error deleting nil entry with serial %s: %w", serial, err)
 			}
+			if err := sc.deleteCertIndex(serial); err != nil {
+				return fmt.Errorf("error removing serial %s from certificate index: %w", serial, err)
+			}
 			b.tidyStatusIncCertStoreCount()
 			continue
 		}
@@ -989,6 +993,9 @@ func (b *backend) doTidyCertStore(ctx context.Context, req *logical.Request, log
 			if err := req.Storage.Delete(ctx, "certs/"+serial); err != nil {
 				return fmt.Errorf("error deleting entry with nil value with serial %s: %w", serial, err)
 			}
+			if err := sc.deleteCertIndex(serial); err != nil {
+				return fmt.Errorf("error removing serial %s from certificate index: %w", serial, err)
+			}
 			b.tidyStatusIncCertStoreCount()
 			continue
 		}
@@ -1002,6 +1009,9 @@ func (b *backend) doTidyCertStore(ctx context.Context, req *logical.Request, log
 			if err := req.Storage.Delete(ctx, "certs/"+serial); err != nil {
 				return fmt.Errorf("error deleting serial %q from storage: %w", serial, err)
 			}
+			if err := sc.deleteCertIndex(serial); err != nil {
+				return fmt.Errorf("error removing serial %q from certificate index: %w", serial, err)
+			}
 			b.tidyStatusIncCertStoreCount()
 		}
 	}
@@ -1113,6 +1123,9 @@ func (b *backend) doTidyRevocationStore(ctx context.Context, req *logical.Reques
 				if err := req.Storage.Delete(ctx, "certs/"+serial); err != nil {
 					return fmt.Errorf("error deleting serial %q from store when tidying revoked: %w", serial, err)
 				}
+				if err := sc.deleteCertIndex(serial); err != nil {
+					return fmt.Errorf("error removing serial %q from certificate index: %w", serial, err)
+				}
 				rebuildCRL = true
 				storeCert = false
 				b.tidyStatusIncRevokedCertCount()
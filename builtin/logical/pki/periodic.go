@@ -4,12 +4,15 @@
 package pki
 
 import (
+	"context"
 	"crypto/x509"
 	"errors"
 	"fmt"
 	"sync/atomic"
 	"time"
 
+	"github.com/hashicorp/go-secure-stdlib/parseutil"
+	"github.com/hashicorp/vault/builtin/logical/pki/issuing"
 	"github.com/hashicorp/vault/sdk/helper/consts"
 	"github.com/hashicorp/vault/sdk/logical"
 )
@@ -335,3 +338,312 @@ func readRevocationEntryAndTransfer(sc *storageContext, serial string) error {
 
 	return writeUnifiedRevocationEntry(sc, entry)
 }
+
+// runCertIndexBackfill meant to run as a background, this indexes the
+// certificates stored before the certificate index existed. Certificates are
+// local to each cluster, so this runs on the active node of every cluster,
+// performance secondaries included.
+func runCertIndexBackfill(sc *storageContext) {
+	b := sc.Backend
+
+	if b.System().ReplicationState().HasState(consts.ReplicationDRSecondary|consts.ReplicationPerformanceStandby) ||
+		b.UseLegacyBundleCaStorage() {
+		return
+	}
+
+	if !b.certIndexBackfillRunning.CompareAndSwap(false, true) {
+		return
+	}
+	defer b.certIndexBackfillRunning.Store(false)
+
+	indexed, err := sc.backfillCertIndex()
+	if errors.Is(err, context.Canceled) {
+		// The backend is being cleaned up.
+		return
+	}
+	if err != nil {
+		// Progress is saved, so the next periodic run resumes from here.
+		b.Logger().Error("an error occurred building the certificate index", "error", err)
+		return
+	}
+	if indexed > 0 {
+		b.Logger().Info(fmt.Sprintf("%v: indexed %d certificates", b.backendUUID, indexed))
+	}
+}
+
+// ocspPregenerationStatus tracks the background pre-generation of OCSP
+// responses; see runOcspPregeneration.
+type ocspPregenerationStatus struct {
+	isRunning  atomic.Bool
+	lastRun    time.Time
+	forceRerun atomic.Bool
+}
+
+func (ops *ocspPregenerationStatus) forceRun() {
+	ops.forceRerun.Store(true)
+}
+
+// runOcspPregeneration meant to run as a background, this refreshes the
+// pre-generated OCSP responses of this cluster's certificates after a
+// complete CRL rebuild, and otherwise every quarter of the configured
+// response lifetime, so that served responses stay fresh.
+func runOcspPregeneration(sc *storageContext) {
+	b := sc.Backend
+	status := &b.ocspPregenerationStatus
+
+	if b.System().ReplicationState().HasState(consts.ReplicationDRSecondary|consts.ReplicationPerformanceStandby) ||
+		b.UseLegacyBundleCaStorage() {
+		return
+	}
+
+	cfg, err := b.CrlBuilder().getConfigWithUpdate(sc)
+	if err != nil {
+		b.Logger().Error("failed to retrieve crl config from storage for OCSP pre-generation", "error", err)
+		return
+	}
+	if !cfg.OcspPregenerate || cfg.OcspDisable {
+		return
+	}
+	duration, err := parseutil.ParseDurationSecond(cfg.OcspPregeneratedExpiry)
+	if err != nil {
+		b.Logger().Error("invalid ocsp_pregenerated_expiry", "error", err)
+		return
+	}
+
+	if !status.isRunning.CompareAndSwap(false, true) {
+		return
+	}
+	defer status.isRunning.Store(false)
+
+	// Because access to lastRun is not locked, we need to delay this check
+	// until after we grab the isRunning CAS lock.
+	if !status.lastRun.IsZero() && !status.forceRerun.Load() && time.Since(status.lastRun) < duration/4 {
+		return
+	}
+	status.forceRerun.Store(false)
+	status.lastRun = time.Now()
+
+	issuerIds, err := sc.listIssuers()
+	if err != nil {
+		b.Logger().Error("failed listing issuers for OCSP pre-generation", "error", err)
+		status.forceRerun.Store(true)
+		return
+	}
+	issuerIDEntryMap := make(map[issuing.IssuerID]*issuing.IssuerEntry, len(issuerIds))
+	for _, issuerId := range issuerIds {
+		issuer, err := sc.fetchIssuerById(issuerId)
+		if err != nil {
+			b.Logger().Error("failed fetching issuer for OCSP pre-generation", "issuer_id", issuerId, "error", err)
+			status.forceRerun.Store(true)
+			return
+		}
+		issuerIDEntryMap[issuerId] = issuer
+	}
+
+	// OCSP requests fall back to signing responses on demand, so a failure
+	// here is only retried on the next periodic run.
+	if _, err := pregenerateOcspResponses(sc, cfg, issuerIDEntryMap); err != nil {
+		b.Logger().Warn("failed to pre-generate OCSP responses", "error", err)
+		status.forceRerun.Store(true)
+	}
+}
+
+// runExpiryScan sends pki/issuer-expiring and pki/cert-expiring events for
+// the issuers and stored certificates expiring within the configured warning
+// period, scanning at most once per configured interval.
+func runExpiryScan(sc *storageContext) error {
+	b := sc.Backend
+
+	// Only the active node of each cluster scans, so that every warning is
+	// sent once per cluster.
+	if b.System().ReplicationState().HasState(consts.ReplicationPerformanceStandby) ||
+		b.System().ReplicationState().HasState(consts.ReplicationDRSecondary) {
+		return nil
+	}
+
+	config, err := sc.getEventsConfig()
+	if err != nil {
+		return err
+	}
+	if config.ExpiryWarningPeriod <= 0 {
+		return nil
+	}
+
+	now := time.Now()
+	b.expiryScanLock.Lock()
+	if now.Before(b.lastExpiryScan.Add(config.ExpiryScanInterval)) {
+		b.expiryScanLock.Unlock()
+		return nil
+	}
+	b.lastExpiryScan = now
+	b.expiryScanLock.Unlock()
+
+	return doExpiryScan(sc, now, now.Add(config.ExpiryWarningPeriod))
+}
+
+func doExpiryScan(sc *storageContext, now time.Time, warnBefore time.Time) error {
+	b := sc.Backend
+
+	// Issuers are in the certificate store as well when generated here; only
+	// send the issuer event for them.
+	issuerSerials := make(map[string]struct{})
+	if !b.UseLegacyBundleCaStorage() {
+		issuerIds, err := sc.listIssuers()
+		if err != nil {
+			return fmt.Errorf("failed listing issuers: %w", err)
+		}
+
+		for _, issuerId := range issuerIds {
+			issuer, err := sc.fetchIssuerById(issuerId)
+			if err != nil {
+				return err
+			}
+			cert, err := issuer.GetCertificate()
+			if err != nil {
+				return err
+			}
+
+			serial := normalizeSerialFromBigInt(cert.SerialNumber)
+			issuerSerials[serial] = struct{}{}
+			if cert.NotAfter.Before(now) || !cert.NotAfter.Before(warnBefore) {
+				continue
+			}
+			notified, err := sc.expiryNotified(serial)
+			if err != nil {
+				return err
+			}
+			if notified {
+				continue
+			}
+
+			b.pkiEvent(sc.Context, "issuer-expiring",
+				logical.EventMetadataModified, "false",
+				logical.EventMetadataDataPath, "issuer/"+issuerId.String(),
+				"issuer_id", issuerId.String(),
+				"issuer_name", issuer.Name,
+				"serial_number", serialFromCert(cert),
+				"not_after", cert.NotAfter.Format(time.RFC3339))
+			if err := sc.markExpiryNotified(serial); err != nil {
+				return err
+			}
+		}
+	}
+
+	serials, err := sc.listCertIndexExpiringBefore(warnBefore)
+	if err != nil {
+		return fmt.Errorf("failed listing certificate index: %w", err)
+	}
+
+	for _, serial := range serials {
+		if _, isIssuer := issuerSerials[serial]; isIssuer {
+			continue
+		}
+
+		record, err := sc.fetchCertIndex(serial)
+		if err != nil {
+			return err
+		}
+		if record == nil || record.NotAfter.Before(now) || !record.NotAfter.Before(warnBefore) {
+			continue
+		}
+
+		// Revoked certificates are not expected to be renewed.
+		revokedEntry, err := sc.Storage.Get(sc.Context, revokedPath+serial)
+		if err != nil {
+			return err
+		}
+		if revokedEntry != nil {
+			continue
+		}
+		notified, err := sc.expiryNotified(serial)
+		if err != nil {
+			return err
+		}
+		if notified {
+			continue
+		}
+
+		colonSerial := denormalizeSerial(serial)
+		b.pkiEvent(sc.Context, "cert-expiring",
+			logical.EventMetadataModified, "false",
+			logical.EventMetadataDataPath, "cert/"+colonSerial,
+			"serial_number", colonSerial,
+			"issuer_id", record.IssuerID.String(),
+			"role", record.Role,
+			"common_name", record.CommonName,
+			"not_after", record.NotAfter.Format(time.RFC3339))
+		if err := sc.markExpiryNotified(serial); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// expiryNotified returns whether this cluster has already sent the expiry
+// warning for the issuer or certificate with the given serial number.
+func (sc *storageContext) expiryNotified(serial string) (bool, error) {
+	entry, err := sc.Storage.Get(sc.Context, expiryNotifiedPrefix+serial)
+	if err != nil {
+		return false, fmt.Errorf("failed reading expiry warning state: %w", err)
+	}
+	return entry != nil, nil
+}
+
+func (sc *storageContext) markExpiryNotified(serial string) error {
+	if err := sc.Storage.Put(sc.Context, &logical.StorageEntry{Key: expiryNotifiedPrefix + serial, Value: []byte{}}); err != nil {
+		return fmt.Errorf("failed saving expiry warning state: %w", err)
+	}
+	return nil
+}
+
+// runIssuerRotations advances the rotation of every issuer with rotation
+// configured; see stepIssuerRotation. Failed steps are recorded on the
+// issuer's rotation status and retried on the next run.
+func runIssuerRotations(sc *storageContext) error {
+	b := sc.Backend
+
+	// Issuers are shared across performance replication clusters, so only
+	// the active node of the primary rotates them.
+	if b.System().ReplicationState().HasState(consts.ReplicationPerformanceStandby) ||
+		b.System().ReplicationState().HasState(consts.ReplicationPerformanceSecondary) ||
+		b.System().ReplicationState().HasState(consts.ReplicationDRSecondary) {
+		return nil
+	}
+	if b.UseLegacyBundleCaStorage() {
+		return nil
+	}
+
+	issuerIds, err := sc.Storage.List(sc.Context, issuerRotationPrefix)
+	if err != nil {
+		return fmt.Errorf("failed listing issuer rotations: %w", err)
+	}
+	if len(issuerIds) == 0 {
+		return nil
+	}
+
+	b.issuersLock.Lock()
+	defer b.issuersLock.Unlock()
+
+	now := time.Now()
+	for _, id := range issuerIds {
+		issuerId := issuing.IssuerID(id)
+		rotation, err := sc.fetchIssuerRotation(issuerId)
+		if err != nil {
+			return err
+		}
+		if rotation == nil || !rotation.Enabled || rotation.State == issuerRotationRotated {
+			continue
+		}
+
+		if err := sc.stepIssuerRotation(issuerId, rotation, now); err != nil {
+			b.Logger().Error("failed rotating issuer", "issuer_id", issuerId, "error", err)
+			rotation.LastError = err.Error()
+			if err := sc.writeIssuerRotation(issuerId, rotation); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -10,6 +10,8 @@ import (
 	"crypto/x509"
 	"errors"
 	"fmt"
+	"net/url"
+	"sort"
 	"strings"
 	"time"
 
@@ -45,6 +47,24 @@ const (
 	autoTidyConfigPath = "config/auto-tidy"
 	clusterConfigPath  = "config/cluster"
 
+	// The certificate index backs certs/search: a record per serial, plus
+	// empty marker entries under each secondary key pointing back at it.
+	certIndexPrefix       = "cert-index/"
+	certIndexSerialPrefix = certIndexPrefix + "serial/"
+	certIndexExpiryPrefix = certIndexPrefix + "expiry/"
+	certIndexIssuerPrefix = certIndexPrefix + "issuer/"
+	certIndexRolePrefix   = certIndexPrefix + "role/"
+	certIndexCNPrefix     = certIndexPrefix + "cn/"
+	certIndexExpiryLayout = "2006-01-02"
+
+	// Certificates stored before the index existed are indexed in the
+	// background; the status entry records how far this has got.
+	certIndexStatusPath        = certIndexPrefix + "status"
+	certIndexBackfillBatchSize = 500
+
+	// Expiry warnings sent by this cluster, by serial number.
+	expiryNotifiedPrefix = "expiry-notified/"
+
 	maxRolesToScanOnIssuerChange = 100
 	maxRolesToFindOnIssuerChange = 10
 )
@@ -265,7 +285,12 @@ func (sc *storageContext) writeIssuer(issuer *issuing.IssuerEntry) error {
 }
 
 func (sc *storageContext) deleteIssuer(id issuing.IssuerID) (bool, error) {
-	return issuing.DeleteIssuer(sc.Context, sc.Storage, id)
+	wasDefault, err := issuing.DeleteIssuer(sc.Context, sc.Storage, id)
+	if err != nil {
+		return wasDefault, err
+	}
+
+	return wasDefault, sc.deleteIssuerRotation(id)
 }
 
 func (sc *storageContext) importIssuer(certValue string, issuerName string) (*issuing.IssuerEntry, bool, error) {
@@ -502,6 +527,30 @@ func (sc *storageContext) resolveIssuerCRLPath(reference string, unified bool) (
 	return legacyCRLPath, fmt.Errorf("unable to find CRL for issuer: id:%v/ref:%v", issuer, reference)
 }
 
+// resolveIssuerCRLPartitionPath returns the storage path of a single
+// partition of the issuer's local CRL.
+func (sc *storageContext) resolveIssuerCRLPartitionPath(reference string, partition int) (string, error) {
+	if sc.Backend.UseLegacyBundleCaStorage() {
+		return "", fmt.Errorf("CRL partitions are not available until migration has completed")
+	}
+
+	issuer, err := sc.resolveIssuerReference(reference)
+	if err != nil {
+		return "", err
+	}
+
+	crlConfig, err := issuing.GetLocalCRLConfig(sc.Context, sc.Storage)
+	if err != nil {
+		return "", err
+	}
+
+	if crlId, ok := crlConfig.IssuerIDCRLMap[issuer]; ok && len(crlId) > 0 {
+		return issuing.CRLPartitionPath("crls/", crlId, partition), nil
+	}
+
+	return "", fmt.Errorf("unable to find CRL for issuer: id:%v/ref:%v", issuer, reference)
+}
+
 // Builds a certutil.CertBundle from the specified issuer identifier,
 // optionally loading the key or not. This method supports loading legacy
 // bundles using the legacyBundleShimID issuerId, and if no entry is found will return an error.
@@ -649,6 +698,9 @@ func (sc *storageContext) getRevocationConfig() (*crlConfig, error) {
 	if result.Expiry == "" {
 		result.Expiry = defaultCrlConfig.Expiry
 	}
+	if result.OcspPregeneratedExpiry == "" {
+		result.OcspPregeneratedExpiry = defaultCrlConfig.OcspPregeneratedExpiry
+	}
 
 	isLocalMount := sc.Backend.System().LocalMount()
 	if (!constants.IsEnterprise || isLocalMount) && (result.UnifiedCRLOnExistingPaths || result.UnifiedCRL || result.UseGlobalQueue) {
@@ -768,3 +820,246 @@ func (sc *storageContext) fetchRevocationInfo(serial string) (*revocationInfo, e
 
 	return revInfo, nil
 }
+
+// certIndexEntry is the certificate index record of a stored certificate.
+type certIndexEntry struct {
+	SerialNumber string           `json:"serial_number"`
+	NotAfter     time.Time        `json:"not_after"`
+	IssuerID     issuing.IssuerID `json:"issuer_id"`
+	Role         string           `json:"role"`
+	CommonName   string           `json:"common_name"`
+}
+
+// secondaryKeys returns the marker entries pointing at this record, one for
+// each of the index's secondary keys the certificate has a value for.
+func (e *certIndexEntry) secondaryKeys() []string {
+	keys := []string{
+		certIndexExpiryPrefix + e.NotAfter.UTC().Format(certIndexExpiryLayout) + "/" + e.SerialNumber,
+	}
+	if e.IssuerID != "" {
+		keys = append(keys, certIndexIssuerPrefix+e.IssuerID.String()+"/"+e.SerialNumber)
+	}
+	if e.Role != "" {
+		keys = append(keys, certIndexRolePrefix+e.Role+"/"+e.SerialNumber)
+	}
+	if e.CommonName != "" {
+		keys = append(keys, certIndexCNPrefix+certIndexCNKey(e.CommonName)+"/"+e.SerialNumber)
+	}
+	return keys
+}
+
+// certIndexCNKey escapes a common name for use as a single storage path
+// segment; lookups by common name are case-insensitive.
+func certIndexCNKey(cn string) string {
+	return url.PathEscape(strings.ToLower(cn))
+}
+
+// storeCertificate persists a newly issued certificate and adds it to the
+// certificate index.
+func (sc *storageContext) storeCertificate(certBundle *certutil.ParsedCertBundle, issuerId issuing.IssuerID, role string) error {
+	if err := issuing.StoreCertificate(sc.Context, sc.Storage, sc.Backend.GetCertificateCounter(), certBundle); err != nil {
+		return err
+	}
+
+	return sc.writeCertIndex(certBundle.Certificate, issuerId, role)
+}
+
+func (sc *storageContext) writeCertIndex(cert *x509.Certificate, issuerId issuing.IssuerID, role string) error {
+	record := &certIndexEntry{
+		SerialNumber: normalizeSerialFromBigInt(cert.SerialNumber),
+		NotAfter:     cert.NotAfter,
+		IssuerID:     issuerId,
+		Role:         role,
+		CommonName:   cert.Subject.CommonName,
+	}
+
+	entry, err := logical.StorageEntryJSON(certIndexSerialPrefix+record.SerialNumber, record)
+	if err != nil {
+		return err
+	}
+	if err := sc.Storage.Put(sc.Context, entry); err != nil {
+		return fmt.Errorf("unable to index certificate: %w", err)
+	}
+
+	for _, key := range record.secondaryKeys() {
+		if err := sc.Storage.Put(sc.Context, &logical.StorageEntry{Key: key, Value: []byte{}}); err != nil {
+			return fmt.Errorf("unable to index certificate: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (sc *storageContext) fetchCertIndex(serial string) (*certIndexEntry, error) {
+	entry, err := sc.Storage.Get(sc.Context, certIndexSerialPrefix+serial)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var record certIndexEntry
+	if err := entry.DecodeJSON(&record); err != nil {
+		return nil, fmt.Errorf("unable to decode certificate index entry for %v: %w", serial, err)
+	}
+
+	return &record, nil
+}
+
+// deleteCertIndex removes a certificate from the index, when it is tidied
+// from the certificate store.
+func (sc *storageContext) deleteCertIndex(serial string) error {
+	record, err := sc.fetchCertIndex(serial)
+	if err != nil {
+		return err
+	}
+	if record == nil {
+		return nil
+	}
+
+	for _, key := range record.secondaryKeys() {
+		if err := sc.Storage.Delete(sc.Context, key); err != nil {
+			return err
+		}
+	}
+
+	if err := sc.Storage.Delete(sc.Context, expiryNotifiedPrefix+serial); err != nil {
+		return err
+	}
+
+	return sc.Storage.Delete(sc.Context, certIndexSerialPrefix+serial)
+}
+
+// certIndexStatus records the progress of indexing the certificates stored
+// before the index existed. Cursor is the last serial indexed.
+type certIndexStatus struct {
+	Complete bool   `json:"complete"`
+	Cursor   string `json:"cursor"`
+}
+
+func (sc *storageContext) getCertIndexStatus() (*certIndexStatus, error) {
+	entry, err := sc.Storage.Get(sc.Context, certIndexStatusPath)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &certIndexStatus{}
+	if entry == nil {
+		return status, nil
+	}
+	if err := entry.DecodeJSON(status); err != nil {
+		return nil, fmt.Errorf("unable to decode certificate index status: %w", err)
+	}
+
+	return status, nil
+}
+
+func (sc *storageContext) setCertIndexStatus(status *certIndexStatus) error {
+	entry, err := logical.StorageEntryJSON(certIndexStatusPath, status)
+	if err != nil {
+		return err
+	}
+
+	return sc.Storage.Put(sc.Context, entry)
+}
+
+// backfillCertIndex indexes the certificates in the certificate store which
+// are not yet indexed, resuming after the persisted cursor and saving it after
+// every batch. The role a certificate was issued through was not recorded
+// before the index existed, so it is left empty.
+func (sc *storageContext) backfillCertIndex() (int, error) {
+	status, err := sc.getCertIndexStatus()
+	if err != nil {
+		return 0, err
+	}
+	if status.Complete {
+		return 0, nil
+	}
+
+	issuerIds, err := sc.listIssuers()
+	if err != nil {
+		return 0, err
+	}
+	issuers := make(map[issuing.IssuerID]*x509.Certificate, len(issuerIds))
+	for _, issuerId := range issuerIds {
+		issuer, err := sc.fetchIssuerById(issuerId)
+		if err != nil {
+			return 0, err
+		}
+		issuerCert, err := issuer.GetCertificate()
+		if err != nil {
+			return 0, err
+		}
+		issuers[issuerId] = issuerCert
+	}
+
+	serials, err := sc.Storage.List(sc.Context, "certs/")
+	if err != nil {
+		return 0, fmt.Errorf("failed listing certificates: %w", err)
+	}
+	sort.Strings(serials)
+
+	indexed := 0
+	sinceCursor := 0
+	for _, serial := range serials {
+		if serial <= status.Cursor {
+			continue
+		}
+		if err := sc.Context.Err(); err != nil {
+			return indexed, err
+		}
+
+		if sinceCursor >= certIndexBackfillBatchSize {
+			if err := sc.setCertIndexStatus(status); err != nil {
+				return indexed, fmt.Errorf("failed saving certificate index progress: %w", err)
+			}
+			sinceCursor = 0
+		}
+		status.Cursor = serial
+		sinceCursor++
+
+		// Certificates issued since the index was introduced are already
+		// indexed, with their role.
+		record, err := sc.fetchCertIndex(serial)
+		if err != nil {
+			return indexed, err
+		}
+		if record != nil {
+			continue
+		}
+
+		entry, err := sc.Storage.Get(sc.Context, "certs/"+serial)
+		if err != nil {
+			return indexed, fmt.Errorf("error fetching certificate %q: %w", serial, err)
+		}
+		if entry == nil || len(entry.Value) == 0 {
+			continue
+		}
+		cert, err := x509.ParseCertificate(entry.Value)
+		if err != nil {
+			sc.Backend.Logger().Warn("skipping unparsable certificate while indexing", "serial", serial, "error", err)
+			continue
+		}
+
+		var issuerId issuing.IssuerID
+		for candidateId, issuerCert := range issuers {
+			if bytes.Equal(cert.RawIssuer, issuerCert.RawSubject) && cert.CheckSignatureFrom(issuerCert) == nil {
+				issuerId = candidateId
+				break
+			}
+		}
+
+		if err := sc.writeCertIndex(cert, issuerId, ""); err != nil {
+			return indexed, err
+		}
+		indexed++
+	}
+
+	status.Complete = true
+	if err := sc.setCertIndexStatus(status); err != nil {
+		return indexed, fmt.Errorf("failed saving certificate index progress: %w", err)
+	}
+
+	return indexed, nil
+}
@@ -43,6 +43,11 @@ func CreateBackendWithStorage(t testing.TB) (*backend, logical.Storage) {
 	}
 	// Assume for our tests we have performed the migration already.
 	b.pkiStorageVersion.Store(1)
+	// Likewise assume the certificate index has been built.
+	sc := b.makeStorageContext(context.Background(), config.StorageView)
+	if err := sc.setCertIndexStatus(&certIndexStatus{Complete: true}); err != nil {
+		t.Fatal(err)
+	}
 	return b, config.StorageView
 }
 
@@ -20,6 +20,18 @@ type backend struct {
 	view      logical.Storage
 	salt      *salt.Salt
 	saltMutex sync.RWMutex
+
+	// issuersLock serializes changes to the issuers and their configuration.
+	issuersLock sync.Mutex
+
+	// revokeLock serializes updates to the certificate inventory made when
+	// revoking or tidying certificates.
+	revokeLock sync.Mutex
+
+	// krlLock guards krl, the last key revocation list built, which is
+	// served until revocations change or one of its certificates expires.
+	krlLock sync.Mutex
+	krl     *cachedKRL
 }
 
 func Factory(ctx context.Context, conf *logical.BackendConfig) (logical.Backend, error) {
@@ -43,6 +55,8 @@ func Backend(conf *logical.BackendConfig) (*backend, error) {
 			Unauthenticated: []string{
 				"verify",
 				"public_key",
+				"krl",
+				"issuer/+/public_key",
 			},
 
 			LocalStorage: []string{
@@ -53,6 +67,7 @@ func Backend(conf *logical.BackendConfig) (*backend, error) {
 				caPrivateKey,
 				caPrivateKeyStoragePath,
 				keysStoragePrefix,
+				issuerStoragePrefix,
 			},
 		},
 
@@ -68,14 +83,26 @@ func Backend(conf *logical.BackendConfig) (*backend, error) {
 			pathIssue(&b),
 			pathFetchPublicKey(&b),
 			pathCleanupKeys(&b),
+			pathListCerts(&b),
+			pathReadCert(&b),
+			pathRevoke(&b),
+			pathFetchKRL(&b),
+			pathTidyCerts(&b),
+			pathListIssuers(&b),
+			pathGenerateIssuer(&b),
+			pathImportIssuer(&b),
+			pathIssuer(&b),
+			pathFetchIssuerPublicKey(&b),
+			pathConfigIssuers(&b),
 		},
 
 		Secrets: []*framework.Secret{
 			secretOTP(&b),
 		},
 
-		Invalidate:  b.invalidate,
-		BackendType: logical.TypeLogical,
+		InitializeFunc: b.initialize,
+		Invalidate:     b.invalidate,
+		BackendType:    logical.TypeLogical,
 	}
 	return &b, nil
 }
@@ -103,12 +130,18 @@ func (b *backend) Salt(ctx context.Context) (*salt.Salt, error) {
 	return salt, nil
 }
 
+func (b *backend) initialize(ctx context.Context, req *logical.InitializationRequest) error {
+	return b.initializeIssuers(ctx, req.Storage)
+}
+
 func (b *backend) invalidate(_ context.Context, key string) {
-	switch key {
-	case salt.DefaultLocation:
+	switch {
+	case key == salt.DefaultLocation:
 		b.saltMutex.Lock()
 		defer b.saltMutex.Unlock()
 		b.salt = nil
+	case strings.HasPrefix(key, revokedStoragePrefix):
+		b.invalidateKRL()
 	}
 }
 
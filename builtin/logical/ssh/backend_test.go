@@ -6,7 +6,12 @@ package ssh
 import (
 	"bytes"
 	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
 	"encoding/base64"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"net"
@@ -448,6 +453,117 @@ func TestBackend_DefaultUserTemplateFalse_AllowedUsersTemplateTrue(t *testing.T)
 	}
 }
 
+func TestBackend_IdentityTemplates(t *testing.T) {
+	cluster, userpassToken := getSshCaTestCluster(t, testUserName)
+	defer cluster.Cleanup()
+	client := cluster.Cores[0].Client
+
+	tokenLookupResponse, err := client.Logical().Write("/auth/token/lookup", map[string]interface{}{
+		"token": userpassToken,
+	})
+	require.NoError(t, err)
+	entityID := tokenLookupResponse.Data["entity_id"].(string)
+	_, err = client.Logical().Write("/identity/entity/id/"+entityID, map[string]interface{}{
+		"metadata": map[string]string{
+			"bastion": "10.0.0.0/8",
+		},
+	})
+	require.NoError(t, err)
+	entity, err := client.Logical().Read("/identity/entity/id/" + entityID)
+	require.NoError(t, err)
+	entityName := entity.Data["name"].(string)
+
+	for _, group := range []string{"web", "db"} {
+		_, err = client.Logical().Write("/identity/group/name/"+group, map[string]interface{}{
+			"member_entity_ids": []string{entityID},
+		})
+		require.NoError(t, err)
+	}
+
+	auths, err := client.Sys().ListAuth()
+	require.NoError(t, err)
+	userpassAccessor := auths["userpass/"].Accessor
+
+	_, err = client.Logical().Write("ssh/roles/my-role", map[string]interface{}{
+		"key_type":                testCaKeyType,
+		"allow_user_certificates": true,
+		"allowed_users":           "{{identity.entity.groups.names}}-admin,static",
+		"allowed_users_template":  true,
+		"key_id_format":           "{{identity.entity.name}}-{{role_name}}",
+		"default_critical_options": map[string]interface{}{
+			"force-command":  "/usr/bin/record-session --user {{identity.entity.aliases." + userpassAccessor + ".name}}",
+			"source-address": "{{identity.entity.metadata.bastion}}",
+		},
+		"default_critical_options_template": true,
+	})
+	require.NoError(t, err)
+
+	// Templated critical options can't be rendered without an entity.
+	_, err = client.Logical().Write("ssh/sign/my-role", map[string]interface{}{
+		"public_key":       testCAPublicKey,
+		"valid_principals": "static",
+	})
+	require.Error(t, err)
+
+	// Principals are allowed per group of the entity.
+	client.SetToken(userpassToken)
+	resp, err := client.Logical().Write("ssh/sign/my-role", map[string]interface{}{
+		"public_key":       testCAPublicKey,
+		"valid_principals": "web-admin,db-admin,static",
+	})
+	require.NoError(t, err)
+
+	certificate, err := parseStoredCertificate(resp.Data["signed_key"].(string))
+	require.NoError(t, err)
+	require.Equal(t, entityName+"-my-role", certificate.KeyId)
+	require.ElementsMatch(t, []string{"web-admin", "db-admin", "static"}, certificate.ValidPrincipals)
+	require.Equal(t, map[string]string{
+		"force-command":  "/usr/bin/record-session --user " + testUserName,
+		"source-address": "10.0.0.0/8",
+	}, certificate.CriticalOptions)
+
+	_, err = client.Logical().Write("ssh/sign/my-role", map[string]interface{}{
+		"public_key":       testCAPublicKey,
+		"valid_principals": "ops-admin",
+	})
+	require.Error(t, err)
+
+	// A group name containing a comma can't inject principals, and rendered
+	// values are not interpreted as key ID variables.
+	client.SetToken(cluster.RootToken)
+	for _, group := range []string{"ops,root", "{{role_name}}"} {
+		_, err = client.Logical().Write("/identity/group", map[string]interface{}{
+			"name":              group,
+			"member_entity_ids": []string{entityID},
+		})
+		require.NoError(t, err)
+	}
+	_, err = client.Logical().Write("ssh/roles/my-role", map[string]interface{}{
+		"key_type":                testCaKeyType,
+		"allow_user_certificates": true,
+		"allowed_users":           "{{identity.entity.groups.names}}-admin",
+		"allowed_users_template":  true,
+		"key_id_format":           "{{identity.entity.groups.names}}",
+	})
+	require.NoError(t, err)
+
+	client.SetToken(userpassToken)
+	_, err = client.Logical().Write("ssh/sign/my-role", map[string]interface{}{
+		"public_key":       testCAPublicKey,
+		"valid_principals": "root-admin",
+	})
+	require.Error(t, err)
+
+	resp, err = client.Logical().Write("ssh/sign/my-role", map[string]interface{}{
+		"public_key":       testCAPublicKey,
+		"valid_principals": "web-admin",
+	})
+	require.NoError(t, err)
+	certificate, err = parseStoredCertificate(resp.Data["signed_key"].(string))
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"web", "db", "{{role_name}}"}, strings.Split(certificate.KeyId, ","))
+}
+
 func TestBackend_DefaultUserTemplateFalse_AllowedUsersTemplateFalse(t *testing.T) {
 	cluster, userpassToken := getSshCaTestCluster(t, testUserName)
 	defer cluster.Cleanup()
@@ -1911,6 +2027,245 @@ func TestSSHBackend_IssueSign(t *testing.T) {
 	logicaltest.Test(t, testCase)
 }
 
+func TestSSHBackend_CertificateInventory(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+
+	b, err := Factory(context.Background(), config)
+	if err != nil {
+		t.Fatalf("Cannot create backend: %s", err)
+	}
+
+	handle := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: op,
+			Path:      path,
+			Storage:   config.StorageView,
+			Data:      data,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("%s %s failed: err=%v resp=%#v", op, path, err, resp)
+		}
+		return resp
+	}
+
+	handle(logical.UpdateOperation, "config/ca", map[string]interface{}{
+		"public_key":  testCAPublicKey,
+		"private_key": testCAPrivateKey,
+	})
+	handle(logical.UpdateOperation, "roles/hosts", map[string]interface{}{
+		"key_type":                "ca",
+		"allow_host_certificates": true,
+		"allowed_domains":         "example.com",
+		"allow_subdomains":        true,
+		"store_certificates":      true,
+	})
+	handle(logical.UpdateOperation, "roles/users", map[string]interface{}{
+		"key_type":                "ca",
+		"allow_user_certificates": true,
+		"allowed_users":           "*",
+		"ttl":                     "1h",
+	})
+
+	roleResp := handle(logical.ReadOperation, "roles/hosts", nil)
+	require.Equal(t, true, roleResp.Data["store_certificates"])
+
+	signHost := func(principal, ttl string) string {
+		resp := handle(logical.UpdateOperation, "sign/hosts", map[string]interface{}{
+			"public_key":       publicKey2,
+			"cert_type":        "host",
+			"valid_principals": principal,
+			"ttl":              ttl,
+		})
+		return resp.Data["serial_number"].(string)
+	}
+	webSerial := signHost("web.example.com", "2h")
+	dbSerial := signHost("db.example.com", "48h")
+
+	// Roles without store_certificates do not record their certificates.
+	handle(logical.UpdateOperation, "sign/users", map[string]interface{}{
+		"public_key":       publicKey2,
+		"valid_principals": "alice",
+	})
+
+	listResp := handle(logical.ListOperation, "certs/", nil)
+	require.ElementsMatch(t, []string{webSerial, dbSerial}, listResp.Data["keys"])
+	webInfo := listResp.Data["key_info"].(map[string]interface{})[webSerial].(map[string]interface{})
+	require.Equal(t, "hosts", webInfo["role"])
+	require.Equal(t, "host", webInfo["cert_type"])
+	require.Equal(t, []string{"web.example.com"}, webInfo["valid_principals"])
+
+	listResp = handle(logical.ListOperation, "certs/", map[string]interface{}{"principal": "db.*"})
+	require.Equal(t, []string{dbSerial}, listResp.Data["keys"])
+	listResp = handle(logical.ListOperation, "certs/", map[string]interface{}{"expiring_within": "24h"})
+	require.Equal(t, []string{webSerial}, listResp.Data["keys"])
+	listResp = handle(logical.ListOperation, "certs/", map[string]interface{}{"cert_type": "user"})
+	require.Empty(t, listResp.Data["keys"])
+
+	certResp := handle(logical.ReadOperation, "cert/"+webSerial, nil)
+	require.Equal(t, webSerial, certResp.Data["serial_number"])
+	require.Equal(t, "", certResp.Data["revocation_time"])
+	signedKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(certResp.Data["signed_key"].(string)))
+	require.NoError(t, err)
+	webCert := signedKey.(*ssh.Certificate)
+
+	// Nothing has been revoked yet.
+	krlResp := handle(logical.ReadOperation, "krl", nil)
+	require.Empty(t, parseTestKRL(t, krlResp.Data[logical.HTTPRawBody].([]byte)))
+
+	revokeResp := handle(logical.UpdateOperation, "revoke", map[string]interface{}{
+		"serial_number": webSerial,
+	})
+	require.NotEmpty(t, revokeResp.Data["revocation_time"])
+
+	caKey, err := parsePublicSSHKey(testCAPublicKey)
+	require.NoError(t, err)
+	krlResp = handle(logical.ReadOperation, "krl", nil)
+	require.Equal(t, map[string][]uint64{
+		string(caKey.Marshal()): {webCert.Serial},
+	}, parseTestKRL(t, krlResp.Data[logical.HTTPRawBody].([]byte)))
+
+	// The KRL is cached until it expires with the certificate it revokes.
+	krl := b.(*backend).krl
+	require.NotNil(t, krl)
+	require.Equal(t, time.Unix(int64(webCert.ValidBefore), 0).UTC(), krl.nextExpiry.UTC())
+	krlResp = handle(logical.ReadOperation, "krl", nil)
+	require.Same(t, krl, b.(*backend).krl)
+
+	// Revocations written elsewhere in the cluster invalidate the cache.
+	require.NoError(t, config.StorageView.Put(context.Background(), &logical.StorageEntry{Key: revokedStoragePrefix + dbSerial}))
+	b.(*backend).invalidate(context.Background(), revokedStoragePrefix+dbSerial)
+	require.Nil(t, b.(*backend).krl)
+	require.NoError(t, config.StorageView.Delete(context.Background(), revokedStoragePrefix+dbSerial))
+	b.(*backend).invalidate(context.Background(), revokedStoragePrefix+dbSerial)
+
+	listResp = handle(logical.ListOperation, "certs/", map[string]interface{}{"include_revoked": false})
+	require.Equal(t, []string{dbSerial}, listResp.Data["keys"])
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "revoke",
+		Storage:   config.StorageView,
+		Data:      map[string]interface{}{"serial_number": "abcdef"},
+	})
+	require.NoError(t, err)
+	require.True(t, resp.IsError(), "expected revoking an unknown certificate to fail")
+}
+
+func TestSSHBackend_TidyCertificates(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+
+	b, err := Backend(config)
+	require.NoError(t, err)
+	require.NoError(t, b.Setup(context.Background(), config))
+
+	handle := func(path string, data map[string]interface{}) *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      path,
+			Storage:   config.StorageView,
+			Data:      data,
+		})
+		require.NoError(t, err)
+		require.False(t, resp != nil && resp.IsError(), "%s failed: %#v", path, resp)
+		return resp
+	}
+
+	handle("config/ca", map[string]interface{}{
+		"public_key":  testCAPublicKey,
+		"private_key": testCAPrivateKey,
+	})
+
+	now := time.Now().UTC()
+	putCert := func(serial string, validBefore time.Time, revoked bool) {
+		certEntry := &sshCertEntry{
+			SerialNumber: serial,
+			CertType:     "user",
+			ValidBefore:  validBefore,
+		}
+		if revoked {
+			certEntry.RevocationTime = validBefore.Add(-time.Hour)
+			require.NoError(t, config.StorageView.Put(context.Background(), &logical.StorageEntry{Key: revokedStoragePrefix + serial}))
+		}
+		require.NoError(t, putCertEntry(context.Background(), config.StorageView, certEntry))
+	}
+	putCert("a1", now.Add(-96*time.Hour), true)
+	putCert("a2", now.Add(-96*time.Hour), false)
+	putCert("b1", now.Add(-time.Hour), true)
+	putCert("c1", now.Add(time.Hour), false)
+
+	// By default, certificates are kept for 72 hours after they expire.
+	b.krl = &cachedKRL{}
+	resp := handle("tidy/certs", nil)
+	require.Equal(t, 2, resp.Data["certificates_removed"])
+	require.Equal(t, 1, resp.Data["revocations_removed"])
+	require.Nil(t, b.krl)
+
+	serials, err := config.StorageView.List(context.Background(), certsStoragePrefix)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"b1", "c1"}, serials)
+
+	resp = handle("tidy/certs", map[string]interface{}{"safety_buffer": "0s"})
+	require.Equal(t, 1, resp.Data["certificates_removed"])
+	require.Equal(t, 1, resp.Data["revocations_removed"])
+
+	serials, err = config.StorageView.List(context.Background(), certsStoragePrefix)
+	require.NoError(t, err)
+	require.Equal(t, []string{"c1"}, serials)
+	revoked, err := config.StorageView.List(context.Background(), revokedStoragePrefix)
+	require.NoError(t, err)
+	require.Empty(t, revoked)
+}
+
+// parseTestKRL returns the revoked serial numbers of a KRL, keyed by the
+// wire encoding of the CA key which signed them.
+func parseTestKRL(t *testing.T, krl []byte) map[string][]uint64 {
+	t.Helper()
+
+	var header struct {
+		Magic         uint64
+		FormatVersion uint32
+		Version       uint64
+		Generated     uint64
+		Flags         uint64
+		Reserved      string
+		Comment       string
+		Rest          []byte `ssh:"rest"`
+	}
+	require.NoError(t, ssh.Unmarshal(krl, &header))
+	require.Equal(t, krlMagic, header.Magic)
+	require.Equal(t, krlFormatVersion, header.FormatVersion)
+
+	serials := map[string][]uint64{}
+	for rest := header.Rest; len(rest) > 0; {
+		var section struct {
+			Type byte
+			Data []byte
+			Rest []byte `ssh:"rest"`
+		}
+		require.NoError(t, ssh.Unmarshal(rest, &section))
+		require.Equal(t, krlSectionCertificates, section.Type)
+		rest = section.Rest
+
+		var certs struct {
+			CAKey    []byte
+			Reserved []byte
+			Type     byte
+			Serials  []byte
+		}
+		require.NoError(t, ssh.Unmarshal(section.Data, &certs))
+		require.Equal(t, krlSectionCertSerialList, certs.Type)
+		for i := 0; i+8 <= len(certs.Serials); i += 8 {
+			serials[string(certs.CAKey)] = append(serials[string(certs.CAKey)], binary.BigEndian.Uint64(certs.Serials[i:i+8]))
+		}
+	}
+
+	return serials
+}
+
 func getSshCaTestCluster(t *testing.T, userIdentity string) (*vault.TestCluster, string) {
 	coreConfig := &vault.CoreConfig{
 		CredentialBackends: map[string]logical.Factory{
@@ -2757,19 +3112,30 @@ func TestProperAuthing(t *testing.T) {
 	// key := resp.Data["key"].(string)
 
 	paths := map[string]pathAuthChecker{
+		"cert/0":             shouldBeAuthed,
+		"certs/":             shouldBeAuthed,
 		"config/ca":          shouldBeAuthed,
+		"config/issuers":     shouldBeAuthed,
 		"config/zeroaddress": shouldBeAuthed,
 		"creds/test-otp":     shouldBeAuthed,
 		"issue/test-ca":      shouldBeAuthed,
+		"issuer/default":     shouldBeAuthed,
+		"issuers/":           shouldBeAuthed,
+		"issuers/generate":   shouldBeAuthed,
+		"issuers/import":     shouldBeAuthed,
+		"krl":                shouldBeUnauthedReadList,
 		"lookup":             shouldBeAuthed,
 		"public_key":         shouldBeUnauthedReadList,
+		"revoke":             shouldBeAuthed,
 		"roles/test-ca":      shouldBeAuthed,
 		"roles/test-otp":     shouldBeAuthed,
 		"roles/":             shouldBeAuthed,
 		"sign/test-ca":       shouldBeAuthed,
 		"tidy/dynamic-keys":  shouldBeAuthed,
+		"tidy/certs":         shouldBeAuthed,
 		"verify":             shouldBeUnauthedWriteOnly,
 	}
+	paths["issuer/default/public_key"] = shouldBeUnauthedReadList
 	for path, checkerType := range paths {
 		checker := pathAuthChckerMap[checkerType]
 		checker(t, client, "ssh/"+path, token)
@@ -2806,6 +3172,12 @@ func TestProperAuthing(t *testing.T) {
 		if strings.Contains(raw_path, "{role}") && strings.Contains(raw_path, "creds") {
 			raw_path = strings.ReplaceAll(raw_path, "{role}", "test-otp")
 		}
+		if strings.Contains(raw_path, "{issuer_ref}") {
+			raw_path = strings.ReplaceAll(raw_path, "{issuer_ref}", "default")
+		}
+		if strings.Contains(raw_path, "{serial}") {
+			raw_path = strings.ReplaceAll(raw_path, "{serial}", "0")
+		}
 
 		handler, present := paths[raw_path]
 		if !present {
@@ -2840,3 +3212,156 @@ func TestProperAuthing(t *testing.T) {
 		t.Fatalf("Expected to have validated at least one path.")
 	}
 }
+
+// testSecurityKey returns a security key public key of the given type in
+// authorized_keys format. Only the public half is needed, so the key is
+// assembled from a freshly generated software key.
+func testSecurityKey(t *testing.T, keyType string) string {
+	t.Helper()
+
+	var wire []byte
+	switch keyType {
+	case ssh.KeyAlgoSKED25519:
+		public, _, err := ed25519.GenerateKey(rand.Reader)
+		require.NoError(t, err)
+		wire = ssh.Marshal(struct {
+			Name        string
+			KeyBytes    []byte
+			Application string
+		}{keyType, public, "ssh:"})
+	case ssh.KeyAlgoSKECDSA256:
+		private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		wire = ssh.Marshal(struct {
+			Name        string
+			ID          string
+			Key         []byte
+			Application string
+		}{keyType, "nistp256", elliptic.Marshal(elliptic.P256(), private.X, private.Y), "ssh:"})
+	default:
+		t.Fatalf("unsupported security key type %q", keyType)
+	}
+
+	publicKey, err := ssh.ParsePublicKey(wire)
+	require.NoError(t, err)
+	return string(ssh.MarshalAuthorizedKey(publicKey))
+}
+
+func TestSSHBackend_SecurityKeys(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+
+	b, err := Factory(context.Background(), config)
+	if err != nil {
+		t.Fatalf("Cannot create backend: %s", err)
+	}
+
+	request := func(op logical.Operation, path string, data map[string]interface{}) (*logical.Response, error) {
+		return b.HandleRequest(context.Background(), &logical.Request{
+			Operation: op,
+			Path:      path,
+			Storage:   config.StorageView,
+			Data:      data,
+		})
+	}
+	handle := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		t.Helper()
+		resp, err := request(op, path, data)
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("%s %s failed: err=%v resp=%#v", op, path, err, resp)
+		}
+		return resp
+	}
+	requireError := func(op logical.Operation, path string, data map[string]interface{}) {
+		t.Helper()
+		resp, err := request(op, path, data)
+		require.NoError(t, err)
+		require.True(t, resp != nil && resp.IsError(), "expected %s %s to fail, got %#v", op, path, resp)
+	}
+	sign := func(role, publicKey string, extensions map[string]interface{}) *ssh.Certificate {
+		t.Helper()
+		resp := handle(logical.UpdateOperation, "sign/"+role, map[string]interface{}{
+			"public_key":       publicKey,
+			"valid_principals": "ubuntu",
+			"extensions":       extensions,
+		})
+		certificate, err := parseStoredCertificate(resp.Data["signed_key"].(string))
+		require.NoError(t, err)
+		return certificate
+	}
+
+	skEd25519 := testSecurityKey(t, ssh.KeyAlgoSKED25519)
+	skECDSA := testSecurityKey(t, ssh.KeyAlgoSKECDSA256)
+
+	handle(logical.UpdateOperation, "config/ca", map[string]interface{}{
+		"public_key":  testCAPublicKey,
+		"private_key": testCAPrivateKey,
+	})
+
+	// Security keys are matched by their own names, or by their underlying
+	// key type.
+	lengthsRole := func(lengths map[string]interface{}) map[string]interface{} {
+		return map[string]interface{}{
+			"key_type":                 "ca",
+			"allow_user_certificates":  true,
+			"allowed_users":            "*",
+			"allowed_user_key_lengths": lengths,
+		}
+	}
+	handle(logical.UpdateOperation, "roles/lengths", lengthsRole(map[string]interface{}{"sk-ed25519": 0}))
+	sign("lengths", skEd25519, nil)
+	requireError(logical.UpdateOperation, "sign/lengths", map[string]interface{}{"public_key": skECDSA})
+	requireError(logical.UpdateOperation, "sign/lengths", map[string]interface{}{"public_key": testCAPublicKeyEd25519})
+
+	handle(logical.UpdateOperation, "roles/lengths", lengthsRole(map[string]interface{}{"ecdsa": 256}))
+	sign("lengths", skECDSA, nil)
+
+	// Touch and verification can only be required of security keys.
+	requireError(logical.UpdateOperation, "roles/hardware", map[string]interface{}{
+		"key_type":                "ca",
+		"allow_user_certificates": true,
+		"require_touch":           true,
+	})
+	requireError(logical.UpdateOperation, "roles/hardware", map[string]interface{}{
+		"key_type":                "ca",
+		"allow_user_certificates": true,
+		"require_security_key":    true,
+		"require_touch":           true,
+		"default_extensions":      map[string]interface{}{"no-touch-required": ""},
+	})
+
+	handle(logical.UpdateOperation, "roles/hardware", map[string]interface{}{
+		"key_type":                 "ca",
+		"allow_user_certificates":  true,
+		"allowed_users":            "*",
+		"allowed_extensions":       "*",
+		"default_critical_options": map[string]interface{}{"force-command": "/bin/true"},
+		"require_security_key":     true,
+		"require_touch":            true,
+		"require_verify":           true,
+	})
+	roleResp := handle(logical.ReadOperation, "roles/hardware", nil)
+	require.Equal(t, true, roleResp.Data["require_security_key"])
+	require.Equal(t, true, roleResp.Data["require_touch"])
+	require.Equal(t, true, roleResp.Data["require_verify"])
+
+	for _, publicKey := range []string{skEd25519, skECDSA} {
+		certificate := sign("hardware", publicKey, map[string]interface{}{"permit-pty": ""})
+		require.Equal(t, map[string]string{
+			"force-command":   "/bin/true",
+			"verify-required": "",
+		}, certificate.CriticalOptions)
+		require.Equal(t, map[string]string{"permit-pty": ""}, certificate.Extensions)
+	}
+
+	// The role's default critical options are left untouched.
+	roleResp = handle(logical.ReadOperation, "roles/hardware", nil)
+	require.Equal(t, map[string]string{"force-command": "/bin/true"}, roleResp.Data["default_critical_options"])
+
+	requireError(logical.UpdateOperation, "sign/hardware", map[string]interface{}{"public_key": testCAPublicKeyEd25519})
+	requireError(logical.UpdateOperation, "sign/hardware", map[string]interface{}{
+		"public_key": skEd25519,
+		"extensions": map[string]interface{}{"no-touch-required": ""},
+	})
+	requireError(logical.UpdateOperation, "issue/hardware", map[string]interface{}{"key_type": "ed25519"})
+}
@@ -0,0 +1,111 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package ssh
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/vault/sdk/helper/identitytpl"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+var (
+	// identityTemplateRegex matches a single identity template, such as
+	// {{identity.entity.name}}, within a larger string.
+	identityTemplateRegex = regexp.MustCompile(`{{\s*identity\.[^{}]*}}`)
+
+	// groupListTemplateRegex matches the identity templates which refer to
+	// all of the entity's groups. ACL templating can't render lists, so these
+	// are expanded into one rendering per group.
+	groupListTemplateRegex = regexp.MustCompile(`{{\s*identity\.entity\.groups\.(names|ids)\s*}}`)
+)
+
+// renderIdentityTemplate renders an identity template for the given entity,
+// including its group memberships and alias metadata. A template referring
+// to {{identity.entity.groups.names}} or {{identity.entity.groups.ids}}
+// renders to one value per group the entity belongs to, and so may render to
+// no values at all; any other template renders to a single value.
+func (b *backend) renderIdentityTemplate(tpl string, entityID string) ([]string, error) {
+	entity, err := b.System().EntityInfo(entityID)
+	if err != nil {
+		return nil, err
+	}
+	if entity == nil {
+		return nil, errors.New("no entity found")
+	}
+
+	groups, err := b.System().GroupsForEntity(entityID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Group list templates split tpl into segments, each rendered on its
+	// own, so that group names and IDs are never interpreted as templates.
+	locs := groupListTemplateRegex.FindAllStringSubmatchIndex(tpl, -1)
+	start := 0
+	var rendered []string
+	for i := 0; i <= len(locs); i++ {
+		end := len(tpl)
+		if i < len(locs) {
+			end = locs[i][0]
+		}
+		segment, err := populateIdentityTemplate(tpl[start:end], entity, groups)
+		if err != nil {
+			return nil, err
+		}
+
+		if i == 0 {
+			rendered = []string{segment}
+		} else {
+			useIDs := tpl[locs[i-1][2]:locs[i-1][3]] == "ids"
+			rendered = appendGroupValues(rendered, groups, useIDs, segment)
+		}
+		if i < len(locs) {
+			start = locs[i][1]
+		}
+	}
+
+	return rendered, nil
+}
+
+func populateIdentityTemplate(tpl string, entity *logical.Entity, groups []*logical.Group) (string, error) {
+	if tpl == "" {
+		return "", nil
+	}
+
+	_, out, err := identitytpl.PopulateString(identitytpl.PopulateStringInput{
+		String: tpl,
+		Entity: entity,
+		Groups: groups,
+		Mode:   identitytpl.ACLTemplating,
+	})
+	return out, err
+}
+
+// appendGroupValues returns every combination of a prefix with the name or
+// ID of a group, followed by suffix.
+//
+// Rendered values are joined and split on commas by their callers, so groups
+// whose name or ID contains one are left out rather than rendering to more
+// than one value.
+func appendGroupValues(prefixes []string, groups []*logical.Group, useIDs bool, suffix string) []string {
+	var expanded []string
+	for _, prefix := range prefixes {
+		for _, group := range groups {
+			value := group.Name
+			if useIDs {
+				value = group.ID
+			}
+			if value == "" || strings.Contains(value, ",") {
+				continue
+			}
+
+			expanded = append(expanded, prefix+value+suffix)
+		}
+	}
+
+	return expanded
+}
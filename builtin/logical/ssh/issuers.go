@@ -0,0 +1,360 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package ssh
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	uuid "github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/sdk/helper/consts"
+	"github.com/hashicorp/vault/sdk/logical"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	issuerStoragePrefix     = "config/issuer/"
+	issuersConfigStorageKey = "config/issuers"
+
+	// defaultRef refers to whichever issuer is currently the default one.
+	defaultRef = "default"
+)
+
+// sshIssuerEntry is a named CA key pair which can sign certificates.
+type sshIssuerEntry struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	PublicKey  string `json:"public_key"`
+	PrivateKey string `json:"private_key"`
+}
+
+// sshIssuersConfig holds mount-wide issuer configuration. Its presence in
+// storage also marks the legacy single CA key pair as migrated.
+type sshIssuersConfig struct {
+	DefaultIssuerID string `json:"default"`
+}
+
+func (i *sshIssuerEntry) signer() (ssh.Signer, error) {
+	signer, err := ssh.ParsePrivateKey([]byte(i.PrivateKey))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse stored CA private key: %w", err)
+	}
+	return signer, nil
+}
+
+// validateIssuerKeys ensures the key pair parses and both halves belong
+// together.
+func validateIssuerKeys(publicKey, privateKey string) error {
+	signer, err := ssh.ParsePrivateKey([]byte(privateKey))
+	if err != nil {
+		return fmt.Errorf("Unable to parse private_key as an SSH private key: %v", err)
+	}
+
+	parsedPublicKey, err := parsePublicSSHKey(publicKey)
+	if err != nil {
+		return fmt.Errorf("Unable to parse public_key as an SSH public key: %v", err)
+	}
+
+	if !bytes.Equal(signer.PublicKey().Marshal(), parsedPublicKey.Marshal()) {
+		return errors.New("public_key does not match private_key")
+	}
+
+	return nil
+}
+
+func getIssuersConfig(ctx context.Context, s logical.Storage) (*sshIssuersConfig, error) {
+	entry, err := s.Get(ctx, issuersConfigStorageKey)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var config sshIssuersConfig
+	if err := entry.DecodeJSON(&config); err != nil {
+		return nil, fmt.Errorf("failed to decode issuers configuration: %w", err)
+	}
+	return &config, nil
+}
+
+func putIssuersConfig(ctx context.Context, s logical.Storage, config *sshIssuersConfig) error {
+	entry, err := logical.StorageEntryJSON(issuersConfigStorageKey, config)
+	if err != nil {
+		return err
+	}
+	return s.Put(ctx, entry)
+}
+
+func listIssuers(ctx context.Context, s logical.Storage) ([]string, error) {
+	return s.List(ctx, issuerStoragePrefix)
+}
+
+func fetchIssuerByID(ctx context.Context, s logical.Storage, id string) (*sshIssuerEntry, error) {
+	entry, err := s.Get(ctx, issuerStoragePrefix+id)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var issuer sshIssuerEntry
+	if err := entry.DecodeJSON(&issuer); err != nil {
+		return nil, fmt.Errorf("failed to decode issuer %s: %w", id, err)
+	}
+	return &issuer, nil
+}
+
+func putIssuer(ctx context.Context, s logical.Storage, issuer *sshIssuerEntry) error {
+	entry, err := logical.StorageEntryJSON(issuerStoragePrefix+issuer.ID, issuer)
+	if err != nil {
+		return err
+	}
+	return s.Put(ctx, entry)
+}
+
+// fetchIssuers returns every issuer of the mount, the default one first.
+func fetchIssuers(ctx context.Context, s logical.Storage) ([]*sshIssuerEntry, error) {
+	config, err := getIssuersConfig(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		legacy, err := fetchLegacyIssuer(ctx, s)
+		if err != nil || legacy == nil {
+			return nil, err
+		}
+		return []*sshIssuerEntry{legacy}, nil
+	}
+
+	ids, err := listIssuers(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(ids)
+
+	var issuers []*sshIssuerEntry
+	for _, id := range ids {
+		issuer, err := fetchIssuerByID(ctx, s, id)
+		if err != nil {
+			return nil, err
+		}
+		if issuer == nil {
+			continue
+		}
+		if id == config.DefaultIssuerID {
+			issuers = append([]*sshIssuerEntry{issuer}, issuers...)
+		} else {
+			issuers = append(issuers, issuer)
+		}
+	}
+	return issuers, nil
+}
+
+// resolveIssuerReference maps an issuer ID, name, or "default" to an issuer
+// ID, returning an empty ID when no such issuer exists.
+func resolveIssuerReference(ctx context.Context, s logical.Storage, ref string) (string, error) {
+	if ref == defaultRef {
+		config, err := getIssuersConfig(ctx, s)
+		if err != nil || config == nil {
+			return "", err
+		}
+		return config.DefaultIssuerID, nil
+	}
+
+	issuer, err := fetchIssuerByID(ctx, s, ref)
+	if err != nil {
+		return "", err
+	}
+	if issuer != nil {
+		return issuer.ID, nil
+	}
+
+	ids, err := listIssuers(ctx, s)
+	if err != nil {
+		return "", err
+	}
+	for _, id := range ids {
+		issuer, err := fetchIssuerByID(ctx, s, id)
+		if err != nil {
+			return "", err
+		}
+		if issuer != nil && issuer.Name != "" && issuer.Name == ref {
+			return issuer.ID, nil
+		}
+	}
+
+	return "", nil
+}
+
+// fetchIssuerByRef loads the issuer an issuer ID, name, or "default" refers
+// to, or nil when there is none. Until the legacy CA key pair is migrated,
+// it is the default issuer.
+func fetchIssuerByRef(ctx context.Context, s logical.Storage, ref string) (*sshIssuerEntry, error) {
+	if ref == "" {
+		ref = defaultRef
+	}
+
+	if ref == defaultRef {
+		config, err := getIssuersConfig(ctx, s)
+		if err != nil {
+			return nil, err
+		}
+		if config == nil {
+			return fetchLegacyIssuer(ctx, s)
+		}
+	}
+
+	id, err := resolveIssuerReference(ctx, s, ref)
+	if err != nil || id == "" {
+		return nil, err
+	}
+	return fetchIssuerByID(ctx, s, id)
+}
+
+// fetchLegacyIssuer loads the CA key pair configured before issuers were
+// introduced, if any.
+func fetchLegacyIssuer(ctx context.Context, s logical.Storage) (*sshIssuerEntry, error) {
+	publicKeyEntry, err := caKey(ctx, s, caPublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA public key: %w", err)
+	}
+	privateKeyEntry, err := caKey(ctx, s, caPrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA private key: %w", err)
+	}
+	if publicKeyEntry == nil || publicKeyEntry.Key == "" || privateKeyEntry == nil || privateKeyEntry.Key == "" {
+		return nil, nil
+	}
+
+	return &sshIssuerEntry{
+		PublicKey:  publicKeyEntry.Key,
+		PrivateKey: privateKeyEntry.Key,
+	}, nil
+}
+
+// createIssuer stores a new issuer, making it the default when the mount
+// has none.
+func createIssuer(ctx context.Context, s logical.Storage, name, publicKey, privateKey string) (*sshIssuerEntry, error) {
+	config, err := getIssuersConfig(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		config = &sshIssuersConfig{}
+	}
+
+	id, err := uuid.GenerateUUID()
+	if err != nil {
+		return nil, err
+	}
+
+	issuer := &sshIssuerEntry{
+		ID:         id,
+		Name:       name,
+		PublicKey:  publicKey,
+		PrivateKey: privateKey,
+	}
+	if err := putIssuer(ctx, s, issuer); err != nil {
+		return nil, err
+	}
+
+	if config.DefaultIssuerID == "" {
+		config.DefaultIssuerID = id
+	}
+	if err := putIssuersConfig(ctx, s, config); err != nil {
+		return nil, err
+	}
+
+	return issuer, nil
+}
+
+// validateIssuerName ensures a new issuer name can be told apart from
+// issuer IDs and the default reference, and is not already taken by
+// another issuer.
+func validateIssuerName(ctx context.Context, s logical.Storage, name, id string) error {
+	if name == "" {
+		return nil
+	}
+	if strings.ToLower(name) == defaultRef {
+		return fmt.Errorf("issuer name %q is reserved", name)
+	}
+	if _, err := uuid.ParseUUID(name); err == nil {
+		return fmt.Errorf("issuer name %q must not be a UUID", name)
+	}
+
+	existing, err := resolveIssuerReference(ctx, s, name)
+	if err != nil {
+		return err
+	}
+	if existing != "" && existing != id {
+		return fmt.Errorf("issuer name %q is already in use", name)
+	}
+	return nil
+}
+
+// initializeIssuers migrates the legacy CA key pair on startup.
+func (b *backend) initializeIssuers(ctx context.Context, s logical.Storage) error {
+	b.issuersLock.Lock()
+	defer b.issuersLock.Unlock()
+
+	// Only migrate where storage is writable; replicated mounts receive the
+	// migrated issuers from their primary.
+	if b.System().ReplicationState().HasState(consts.ReplicationDRSecondary|consts.ReplicationPerformanceStandby) ||
+		(!b.System().LocalMount() && b.System().ReplicationState().HasState(consts.ReplicationPerformanceSecondary)) {
+		return nil
+	}
+
+	return migrateLegacyCA(ctx, s)
+}
+
+// migrateLegacyCA copies the legacy CA key pair, if any, into the default
+// issuer. Callers must hold the issuers lock.
+//
+// Like the PKI engine's legacy CA bundle, the legacy key pair is left in
+// storage, so that it remains readable should the mount be downgraded; it is
+// ignored once the issuers configuration exists, and deleted along with the
+// issuer it was migrated to.
+func migrateLegacyCA(ctx context.Context, s logical.Storage) error {
+	config, err := getIssuersConfig(ctx, s)
+	if err != nil || config != nil {
+		return err
+	}
+
+	legacy, err := fetchLegacyIssuer(ctx, s)
+	if err != nil {
+		return err
+	}
+
+	if legacy == nil {
+		return putIssuersConfig(ctx, s, &sshIssuersConfig{})
+	}
+
+	if _, err := createIssuer(ctx, s, "", legacy.PublicKey, legacy.PrivateKey); err != nil {
+		return fmt.Errorf("failed to migrate CA key pair to an issuer: %w", err)
+	}
+
+	return nil
+}
+
+// deleteLegacyCAKeys removes the legacy CA key pair, including from the
+// paths it was stored at by older versions.
+func deleteLegacyCAKeys(ctx context.Context, s logical.Storage) error {
+	for _, path := range []string{
+		caPrivateKeyStoragePath,
+		caPrivateKeyStoragePathDeprecated,
+		caPublicKeyStoragePath,
+		caPublicKeyStoragePathDeprecated,
+	} {
+		if err := s.Delete(ctx, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package ssh
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sort"
+	"time"
+)
+
+// Constants of the OpenSSH key revocation list format; see PROTOCOL.krl in
+// the OpenSSH sources.
+const (
+	krlMagic         uint64 = 0x5353484b524c0a00
+	krlFormatVersion uint32 = 1
+
+	krlSectionCertificates   byte = 1
+	krlSectionCertSerialList byte = 0x20
+)
+
+// krlWriter accumulates the SSH wire encoding of a KRL.
+type krlWriter struct {
+	bytes.Buffer
+}
+
+func (w *krlWriter) writeUint32(v uint32) {
+	_ = binary.Write(w, binary.BigEndian, v)
+}
+
+func (w *krlWriter) writeUint64(v uint64) {
+	_ = binary.Write(w, binary.BigEndian, v)
+}
+
+func (w *krlWriter) writeString(s []byte) {
+	w.writeUint32(uint32(len(s)))
+	w.Write(s)
+}
+
+func (w *krlWriter) writeSection(sectionType byte, data []byte) {
+	w.WriteByte(sectionType)
+	w.writeString(data)
+}
+
+// buildKRL encodes an OpenSSH KRL revoking, for each CA public key in SSH
+// wire encoding, the certificates it signed with the given serial numbers.
+//
+// The KRL version is the generation time, which increases each time the
+// KRL is regenerated and thus whenever revocations change.
+func buildKRL(revoked map[string][]uint64, generated time.Time, comment string) []byte {
+	var krl krlWriter
+	krl.writeUint64(krlMagic)
+	krl.writeUint32(krlFormatVersion)
+	krl.writeUint64(uint64(generated.Unix()))
+	krl.writeUint64(uint64(generated.Unix()))
+	krl.writeUint64(0)   // flags
+	krl.writeString(nil) // reserved
+	krl.writeString([]byte(comment))
+
+	caKeys := make([]string, 0, len(revoked))
+	for caKey := range revoked {
+		caKeys = append(caKeys, caKey)
+	}
+	sort.Strings(caKeys)
+
+	for _, caKey := range caKeys {
+		serials := make([]uint64, len(revoked[caKey]))
+		copy(serials, revoked[caKey])
+		sort.Slice(serials, func(i, j int) bool { return serials[i] < serials[j] })
+
+		var serialList krlWriter
+		for _, serial := range serials {
+			serialList.writeUint64(serial)
+		}
+
+		var certs krlWriter
+		certs.writeString([]byte(caKey))
+		certs.writeString(nil) // reserved
+		certs.writeSection(krlSectionCertSerialList, serialList.Bytes())
+
+		krl.writeSection(krlSectionCertificates, certs.Bytes())
+	}
+
+	return krl.Bytes()
+}
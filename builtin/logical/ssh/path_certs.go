@@ -0,0 +1,495 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package ssh
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/ryanuber/go-glob"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	certsStoragePrefix   = "certs/"
+	revokedStoragePrefix = "revoked/"
+)
+
+// sshCertEntry is the inventory record of a certificate signed or issued by
+// a role with store_certificates set.
+type sshCertEntry struct {
+	SerialNumber    string    `json:"serial_number"`
+	Role            string    `json:"role"`
+	IssuerID        string    `json:"issuer_id"`
+	KeyID           string    `json:"key_id"`
+	CertType        string    `json:"cert_type"`
+	ValidPrincipals []string  `json:"valid_principals"`
+	ValidAfter      time.Time `json:"valid_after"`
+	ValidBefore     time.Time `json:"valid_before"`
+	SignedKey       string    `json:"signed_key"`
+	RevocationTime  time.Time `json:"revocation_time"`
+}
+
+func (e *sshCertEntry) revoked() bool {
+	return !e.RevocationTime.IsZero()
+}
+
+func (e *sshCertEntry) responseData() map[string]interface{} {
+	data := map[string]interface{}{
+		"serial_number":    e.SerialNumber,
+		"role":             e.Role,
+		"issuer_id":        e.IssuerID,
+		"key_id":           e.KeyID,
+		"cert_type":        e.CertType,
+		"valid_principals": e.ValidPrincipals,
+		"valid_after":      e.ValidAfter.Format(time.RFC3339),
+		"valid_before":     e.ValidBefore.Format(time.RFC3339),
+		"revocation_time":  "",
+	}
+	if e.revoked() {
+		data["revocation_time"] = e.RevocationTime.Format(time.RFC3339)
+	}
+	return data
+}
+
+// cachedKRL is a built key revocation list, valid until its first revoked
+// certificate expires; a zero nextExpiry means it revokes nothing.
+type cachedKRL struct {
+	krl        []byte
+	nextExpiry time.Time
+}
+
+func (c *cachedKRL) valid(now time.Time) bool {
+	return c != nil && (c.nextExpiry.IsZero() || now.Before(c.nextExpiry))
+}
+
+func (b *backend) invalidateKRL() {
+	b.krlLock.Lock()
+	defer b.krlLock.Unlock()
+	b.krl = nil
+}
+
+func certificateTypeName(certType uint32) string {
+	if certType == ssh.HostCert {
+		return "host"
+	}
+	return "user"
+}
+
+func serialNumberString(serial uint64) string {
+	return strconv.FormatUint(serial, 16)
+}
+
+func storeCertificate(ctx context.Context, s logical.Storage, roleName, issuerID string, certificate *ssh.Certificate, signedKey string) error {
+	certEntry := &sshCertEntry{
+		SerialNumber:    serialNumberString(certificate.Serial),
+		Role:            roleName,
+		IssuerID:        issuerID,
+		KeyID:           certificate.KeyId,
+		CertType:        certificateTypeName(certificate.CertType),
+		ValidPrincipals: certificate.ValidPrincipals,
+		ValidAfter:      time.Unix(int64(certificate.ValidAfter), 0).UTC(),
+		ValidBefore:     time.Unix(int64(certificate.ValidBefore), 0).UTC(),
+		SignedKey:       signedKey,
+	}
+
+	return putCertEntry(ctx, s, certEntry)
+}
+
+func putCertEntry(ctx context.Context, s logical.Storage, certEntry *sshCertEntry) error {
+	entry, err := logical.StorageEntryJSON(certsStoragePrefix+certEntry.SerialNumber, certEntry)
+	if err != nil {
+		return err
+	}
+	return s.Put(ctx, entry)
+}
+
+func getCertEntry(ctx context.Context, s logical.Storage, serial string) (*sshCertEntry, error) {
+	entry, err := s.Get(ctx, certsStoragePrefix+serial)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var certEntry sshCertEntry
+	if err := entry.DecodeJSON(&certEntry); err != nil {
+		return nil, fmt.Errorf("failed to decode certificate %s: %w", serial, err)
+	}
+	return &certEntry, nil
+}
+
+// normalizeSerialNumber accepts serial numbers as returned by the sign and
+// issue endpoints, optionally zero-padded or colon separated.
+func normalizeSerialNumber(serial string) (string, error) {
+	parsed, err := strconv.ParseUint(strings.ReplaceAll(strings.ToLower(serial), ":", ""), 16, 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid serial number %q", serial)
+	}
+	return serialNumberString(parsed), nil
+}
+
+func pathListCerts(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "certs/?$",
+
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixSSH,
+			OperationSuffix: "certificates",
+		},
+
+		Fields: map[string]*framework.FieldSchema{
+			"role": {
+				Type:        framework.TypeString,
+				Description: `Only list certificates issued by this role.`,
+				Query:       true,
+			},
+			"cert_type": {
+				Type:          framework.TypeString,
+				Description:   `Only list certificates of this type; either "user" or "host".`,
+				AllowedValues: []interface{}{"", "user", "host"},
+				Query:         true,
+			},
+			"key_id": {
+				Type:        framework.TypeString,
+				Description: `Only list certificates whose key ID matches this glob pattern.`,
+				Query:       true,
+			},
+			"principal": {
+				Type:        framework.TypeString,
+				Description: `Only list certificates with a valid principal matching this glob pattern.`,
+				Query:       true,
+			},
+			"expiring_within": {
+				Type:        framework.TypeDurationSecond,
+				Description: `Only list unexpired certificates which expire within this duration.`,
+				Query:       true,
+			},
+			"include_revoked": {
+				Type:        framework.TypeBool,
+				Description: `Whether to list revoked certificates.`,
+				Default:     true,
+				Query:       true,
+			},
+		},
+
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ListOperation: &framework.PathOperation{
+				Callback: b.pathCertsList,
+			},
+		},
+
+		HelpSynopsis:    pathCertsHelpSyn,
+		HelpDescription: pathCertsHelpDesc,
+	}
+}
+
+func pathReadCert(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "cert/(?P<serial>[0-9A-Fa-f:]+)",
+
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixSSH,
+			OperationSuffix: "certificate",
+		},
+
+		Fields: map[string]*framework.FieldSchema{
+			"serial": {
+				Type:        framework.TypeString,
+				Description: `Serial number of the certificate, as returned when it was signed.`,
+			},
+		},
+
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ReadOperation: &framework.PathOperation{
+				Callback: b.pathCertRead,
+			},
+		},
+
+		HelpSynopsis:    pathCertsHelpSyn,
+		HelpDescription: pathCertsHelpDesc,
+	}
+}
+
+func pathRevoke(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "revoke",
+
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixSSH,
+			OperationVerb:   "revoke",
+			OperationSuffix: "certificate",
+		},
+
+		Fields: map[string]*framework.FieldSchema{
+			"serial_number": {
+				Type:        framework.TypeString,
+				Description: `Serial number of the certificate to revoke, as returned when it was signed.`,
+				Required:    true,
+			},
+		},
+
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.UpdateOperation: &framework.PathOperation{
+				Callback: b.pathRevokeWrite,
+			},
+		},
+
+		HelpSynopsis:    `Revoke a certificate recorded in the certificate inventory.`,
+		HelpDescription: `Revoked certificates are added to the key revocation list served at the "krl" endpoint. Only certificates issued by roles with store_certificates set can be revoked.`,
+	}
+}
+
+func pathFetchKRL(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "krl",
+
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixSSH,
+			OperationSuffix: "krl",
+		},
+
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ReadOperation: &framework.PathOperation{
+				Callback: b.pathFetchKRL,
+			},
+		},
+
+		HelpSynopsis:    `Retrieve the key revocation list.`,
+		HelpDescription: `This returns an OpenSSH key revocation list (KRL) of the unexpired revoked certificates of the certificate inventory, suitable for the RevokedKeys option of sshd. This is a raw response endpoint without JSON encoding; use -format=raw or an external tool (e.g., curl) to fetch this value.`,
+	}
+}
+
+func (b *backend) pathCertsList(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	roleName := d.Get("role").(string)
+	certType := d.Get("cert_type").(string)
+	keyIDPattern := d.Get("key_id").(string)
+	principalPattern := d.Get("principal").(string)
+	includeRevoked := d.Get("include_revoked").(bool)
+
+	var expiringBefore time.Time
+	now := time.Now()
+	if expiringWithin := d.Get("expiring_within").(int); expiringWithin > 0 {
+		expiringBefore = now.Add(time.Duration(expiringWithin) * time.Second)
+	}
+
+	serials, err := req.Storage.List(ctx, certsStoragePrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	keyInfo := map[string]interface{}{}
+	for _, serial := range serials {
+		certEntry, err := getCertEntry(ctx, req.Storage, serial)
+		if err != nil {
+			return nil, err
+		}
+		if certEntry == nil {
+			continue
+		}
+
+		if roleName != "" && certEntry.Role != roleName {
+			continue
+		}
+		if certType != "" && certEntry.CertType != certType {
+			continue
+		}
+		if keyIDPattern != "" && !glob.Glob(keyIDPattern, certEntry.KeyID) {
+			continue
+		}
+		if principalPattern != "" && !principalsMatch(principalPattern, certEntry.ValidPrincipals) {
+			continue
+		}
+		if !expiringBefore.IsZero() && (certEntry.ValidBefore.Before(now) || certEntry.ValidBefore.After(expiringBefore)) {
+			continue
+		}
+		if !includeRevoked && certEntry.revoked() {
+			continue
+		}
+
+		keys = append(keys, serial)
+		keyInfo[serial] = certEntry.responseData()
+	}
+
+	return logical.ListResponseWithInfo(keys, keyInfo), nil
+}
+
+func principalsMatch(pattern string, principals []string) bool {
+	for _, principal := range principals {
+		if glob.Glob(pattern, principal) {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *backend) pathCertRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	serial, err := normalizeSerialNumber(d.Get("serial").(string))
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	certEntry, err := getCertEntry(ctx, req.Storage, serial)
+	if err != nil {
+		return nil, err
+	}
+	if certEntry == nil {
+		return nil, nil
+	}
+
+	data := certEntry.responseData()
+	data["signed_key"] = certEntry.SignedKey
+
+	return &logical.Response{
+		Data: data,
+	}, nil
+}
+
+func (b *backend) pathRevokeWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	rawSerial := d.Get("serial_number").(string)
+	if rawSerial == "" {
+		return logical.ErrorResponse("missing serial_number"), nil
+	}
+
+	serial, err := normalizeSerialNumber(rawSerial)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	b.revokeLock.Lock()
+	defer b.revokeLock.Unlock()
+
+	certEntry, err := getCertEntry(ctx, req.Storage, serial)
+	if err != nil {
+		return nil, err
+	}
+	if certEntry == nil {
+		return logical.ErrorResponse(fmt.Sprintf("certificate with serial number %s not found in the certificate inventory", serial)), nil
+	}
+
+	if !certEntry.revoked() {
+		certEntry.RevocationTime = time.Now().UTC()
+		if err := putCertEntry(ctx, req.Storage, certEntry); err != nil {
+			return nil, err
+		}
+		if err := req.Storage.Put(ctx, &logical.StorageEntry{Key: revokedStoragePrefix + serial}); err != nil {
+			return nil, err
+		}
+		b.invalidateKRL()
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"serial_number":   serial,
+			"revocation_time": certEntry.RevocationTime.Format(time.RFC3339),
+		},
+	}, nil
+}
+
+func (b *backend) pathFetchKRL(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	issuers, err := fetchIssuers(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if len(issuers) == 0 {
+		return nil, nil
+	}
+
+	// The KRL is served without authentication, so it is only rebuilt when
+	// revocations change or a revoked certificate expires. Holding the lock
+	// while building also keeps concurrent requests from rebuilding it.
+	b.krlLock.Lock()
+	defer b.krlLock.Unlock()
+
+	now := time.Now()
+	if !b.krl.valid(now) {
+		krl, err := buildInventoryKRL(ctx, req.Storage, now)
+		if err != nil {
+			return nil, err
+		}
+		b.krl = krl
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			logical.HTTPContentType: "application/octet-stream",
+			logical.HTTPRawBody:     b.krl.krl,
+			logical.HTTPStatusCode:  http.StatusOK,
+		},
+	}, nil
+}
+
+func buildInventoryKRL(ctx context.Context, s logical.Storage, now time.Time) (*cachedKRL, error) {
+	revoked, err := s.List(ctx, revokedStoragePrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	// Expired certificates are rejected regardless of revocation, so they
+	// are left out of the KRL to keep it small. Revocations are grouped by
+	// the CA key which signed each certificate, as issuers may have been
+	// rotated since.
+	var nextExpiry time.Time
+	serialsByCA := map[string][]uint64{}
+	for _, serial := range revoked {
+		certEntry, err := getCertEntry(ctx, s, serial)
+		if err != nil {
+			return nil, err
+		}
+		if certEntry == nil || certEntry.ValidBefore.Before(now) {
+			continue
+		}
+
+		certificate, err := parseStoredCertificate(certEntry.SignedKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse revoked certificate %s: %w", serial, err)
+		}
+
+		caKey := string(certificate.SignatureKey.Marshal())
+		serialsByCA[caKey] = append(serialsByCA[caKey], certificate.Serial)
+		if nextExpiry.IsZero() || certEntry.ValidBefore.Before(nextExpiry) {
+			nextExpiry = certEntry.ValidBefore
+		}
+	}
+
+	return &cachedKRL{
+		krl:        buildKRL(serialsByCA, now, ""),
+		nextExpiry: nextExpiry,
+	}, nil
+}
+
+func parseStoredCertificate(signedKey string) (*ssh.Certificate, error) {
+	publicKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(signedKey))
+	if err != nil {
+		return nil, err
+	}
+
+	certificate, ok := publicKey.(*ssh.Certificate)
+	if !ok {
+		return nil, fmt.Errorf("stored key is not a certificate")
+	}
+	return certificate, nil
+}
+
+const pathCertsHelpSyn = `
+List and read certificates recorded in the certificate inventory.
+`
+
+const pathCertsHelpDesc = `
+Roles with 'store_certificates' set record every certificate they sign or
+issue in the certificate inventory. The inventory can be listed at 'certs/',
+optionally filtered by role, certificate type, key ID, principal, or upcoming
+expiry, and individual certificates read at 'cert/<serial>'. Recorded
+certificates can be revoked at 'revoke', which adds them to the key
+revocation list served at 'krl'. Expired certificates are removed from the
+inventory at 'tidy/certs'.
+`
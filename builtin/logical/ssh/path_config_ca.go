@@ -17,7 +17,6 @@ import (
 	"fmt"
 	"io"
 
-	multierror "github.com/hashicorp/go-multierror"
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/logical"
 	"github.com/mikesmitty/edkey"
@@ -99,23 +98,26 @@ by this mount. The fields must be in the standard private and public SSH format.
 
 For security reasons, the private key cannot be retrieved later.
 
-Read operations will return the public key, if already stored/generated.`,
+Read operations will return the public key, if already stored/generated.
+
+The key pair is stored as the default issuer; use the issuers/ endpoints to
+manage additional issuers.`,
 	}
 }
 
 func (b *backend) pathConfigCARead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	publicKeyEntry, err := caKey(ctx, req.Storage, caPublicKey)
+	issuer, err := fetchIssuerByRef(ctx, req.Storage, defaultRef)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read CA public key: %w", err)
 	}
 
-	if publicKeyEntry == nil {
+	if issuer == nil {
 		return logical.ErrorResponse("keys haven't been configured yet"), nil
 	}
 
 	response := &logical.Response{
 		Data: map[string]interface{}{
-			"public_key": publicKeyEntry.Key,
+			"public_key": issuer.PublicKey,
 		},
 	}
 
@@ -123,10 +125,28 @@ func (b *backend) pathConfigCARead(ctx context.Context, req *logical.Request, da
 }
 
 func (b *backend) pathConfigCADelete(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	if err := req.Storage.Delete(ctx, caPrivateKeyStoragePath); err != nil {
+	b.issuersLock.Lock()
+	defer b.issuersLock.Unlock()
+
+	if err := migrateLegacyCA(ctx, req.Storage); err != nil {
+		return nil, err
+	}
+
+	// As before issuers were introduced, this always removes the legacy
+	// key pair.
+	if err := deleteLegacyCAKeys(ctx, req.Storage); err != nil {
 		return nil, err
 	}
-	if err := req.Storage.Delete(ctx, caPublicKeyStoragePath); err != nil {
+
+	issuer, err := fetchIssuerByRef(ctx, req.Storage, defaultRef)
+	if err != nil {
+		return nil, err
+	}
+	if issuer == nil {
+		return nil, nil
+	}
+
+	if _, err := b.deleteIssuer(ctx, req.Storage, issuer); err != nil {
 		return nil, err
 	}
 	return nil, nil
@@ -211,14 +231,8 @@ func (b *backend) pathConfigCAUpdate(ctx context.Context, req *logical.Request,
 			return logical.ErrorResponse("missing private_key"), nil
 		}
 
-		_, err := ssh.ParsePrivateKey([]byte(privateKey))
-		if err != nil {
-			return logical.ErrorResponse(fmt.Sprintf("Unable to parse private_key as an SSH private key: %v", err)), nil
-		}
-
-		_, err = parsePublicSSHKey(publicKey)
-		if err != nil {
-			return logical.ErrorResponse(fmt.Sprintf("Unable to parse public_key as an SSH public key: %v", err)), nil
+		if err := validateIssuerKeys(publicKey, privateKey); err != nil {
+			return logical.ErrorResponse(err.Error()), nil
 		}
 
 	// not set and no public/private key provided so generate
@@ -244,54 +258,24 @@ func (b *backend) pathConfigCAUpdate(ctx context.Context, req *logical.Request,
 		return nil, fmt.Errorf("failed to generate or parse the keys")
 	}
 
-	publicKeyEntry, err := caKey(ctx, req.Storage, caPublicKey)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read CA public key: %w", err)
-	}
+	b.issuersLock.Lock()
+	defer b.issuersLock.Unlock()
 
-	privateKeyEntry, err := caKey(ctx, req.Storage, caPrivateKey)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read CA private key: %w", err)
-	}
-
-	if (publicKeyEntry != nil && publicKeyEntry.Key != "") || (privateKeyEntry != nil && privateKeyEntry.Key != "") {
-		return logical.ErrorResponse("keys are already configured; delete them before reconfiguring"), nil
-	}
-
-	entry, err := logical.StorageEntryJSON(caPublicKeyStoragePath, &keyStorageEntry{
-		Key: publicKey,
-	})
-	if err != nil {
+	if err := migrateLegacyCA(ctx, req.Storage); err != nil {
 		return nil, err
 	}
 
-	// Save the public key
-	err = req.Storage.Put(ctx, entry)
+	// This endpoint manages the default issuer; other issuers are managed
+	// at issuers/.
+	existing, err := fetchIssuerByRef(ctx, req.Storage, defaultRef)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to read CA public key: %w", err)
 	}
-
-	entry, err = logical.StorageEntryJSON(caPrivateKeyStoragePath, &keyStorageEntry{
-		Key: privateKey,
-	})
-	if err != nil {
-		return nil, err
+	if existing != nil {
+		return logical.ErrorResponse("keys are already configured; delete them before reconfiguring"), nil
 	}
 
-	// Save the private key
-	err = req.Storage.Put(ctx, entry)
-	if err != nil {
-		var mErr *multierror.Error
-
-		mErr = multierror.Append(mErr, fmt.Errorf("failed to store CA private key: %w", err))
-
-		// If storing private key fails, the corresponding public key should be
-		// removed
-		if delErr := req.Storage.Delete(ctx, caPublicKeyStoragePath); delErr != nil {
-			mErr = multierror.Append(mErr, fmt.Errorf("failed to cleanup CA public key: %w", delErr))
-			return nil, mErr
-		}
-
+	if _, err := createIssuer(ctx, req.Storage, "", publicKey, privateKey); err != nil {
 		return nil, err
 	}
 
@@ -5,6 +5,7 @@ package ssh
 
 import (
 	"context"
+	"strings"
 
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/logical"
@@ -24,23 +25,32 @@ func pathFetchPublicKey(b *backend) *framework.Path {
 		},
 
 		HelpSynopsis:    `Retrieve the public key.`,
-		HelpDescription: `This allows the public key of the SSH CA certificate that this backend has been configured with to be fetched. This is a raw response endpoint without JSON encoding; use -format=raw or an external tool (e.g., curl) to fetch this value.`,
+		HelpDescription: `This allows the public keys of the SSH CA issuers that this backend has been configured with to be fetched, one per line with the default issuer first. This is a raw response endpoint without JSON encoding; use -format=raw or an external tool (e.g., curl) to fetch this value.`,
 	}
 }
 
 func (b *backend) pathFetchPublicKey(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	publicKeyEntry, err := caKey(ctx, req.Storage, caPublicKey)
+	issuers, err := fetchIssuers(ctx, req.Storage)
 	if err != nil {
 		return nil, err
 	}
-	if publicKeyEntry == nil || publicKeyEntry.Key == "" {
+	if len(issuers) == 0 {
 		return nil, nil
 	}
 
+	// Serve the public keys of every issuer, one per line and the default
+	// issuer first, so that hosts trusting this bundle accept certificates
+	// of both the old and new issuers while rotating.
+	var publicKeys strings.Builder
+	for _, issuer := range issuers {
+		publicKeys.WriteString(strings.TrimSpace(issuer.PublicKey))
+		publicKeys.WriteString("\n")
+	}
+
 	response := &logical.Response{
 		Data: map[string]interface{}{
 			logical.HTTPContentType: "text/plain",
-			logical.HTTPRawBody:     []byte(publicKeyEntry.Key),
+			logical.HTTPRawBody:     []byte(publicKeys.String()),
 			logical.HTTPStatusCode:  200,
 		},
 	}
@@ -98,6 +98,11 @@ func (b *backend) pathIssue(ctx context.Context, req *logical.Request, data *fra
 		return logical.ErrorResponse("role key type '%s' not allowed to issue key pairs", role.KeyType), nil
 	}
 
+	// Key pairs generated by Vault are never backed by a security key.
+	if role.RequireSecurityKey {
+		return logical.ErrorResponse("role requires security keys, so key pairs cannot be issued; sign the public key of a security key instead"), nil
+	}
+
 	// Validate and extract key specifications
 	keySpecs, err := extractKeySpecs(role, data)
 	if err != nil {
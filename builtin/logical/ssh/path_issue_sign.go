@@ -29,6 +29,17 @@ import (
 
 var containsTemplateRegex = regexp.MustCompile(`{{.+?}}`)
 
+// keyIDTemplateRegex matches the variables and identity templates of a
+// key_id_format.
+var keyIDTemplateRegex = regexp.MustCompile(`{{[^{}]*}}`)
+
+// Certificate options controlling security key user presence and
+// verification; see PROTOCOL.certkeys in the OpenSSH sources.
+const (
+	extensionNoTouchRequired     = "no-touch-required"
+	criticalOptionVerifyRequired = "verify-required"
+)
+
 var ecCurveBitsToAlgoName = map[int]string{
 	256: ssh.KeyAlgoECDSA256,
 	384: ssh.KeyAlgoECDSA384,
@@ -75,10 +86,11 @@ func (b *backend) pathSignIssueCertificateHelper(ctx context.Context, req *logic
 	} else {
 		defaultPrincipal := role.DefaultUser
 		if role.DefaultUserTemplate {
-			defaultPrincipal, err = b.renderPrincipal(role.DefaultUser, req)
+			renderedPrincipals, err := b.renderPrincipal(role.DefaultUser, req)
 			if err != nil {
 				return nil, err
 			}
+			defaultPrincipal = strings.Join(renderedPrincipals, ",")
 		}
 		parsedPrincipals, err = b.calculateValidPrincipals(data, req, role, defaultPrincipal, role.AllowedUsers, role.AllowedUsersTemplate, strutil.StrListContains)
 		if err != nil {
@@ -91,7 +103,7 @@ func (b *backend) pathSignIssueCertificateHelper(ctx context.Context, req *logic
 		return logical.ErrorResponse(err.Error()), nil
 	}
 
-	criticalOptions, err := b.calculateCriticalOptions(data, role)
+	criticalOptions, err := b.calculateCriticalOptions(data, req, role)
 	if err != nil {
 		return logical.ErrorResponse(err.Error()), nil
 	}
@@ -101,17 +113,25 @@ func (b *backend) pathSignIssueCertificateHelper(ctx context.Context, req *logic
 		return logical.ErrorResponse(err.Error()), nil
 	}
 
-	privateKeyEntry, err := caKey(ctx, req.Storage, caPrivateKey)
+	criticalOptions, extensions, err = b.applySecurityKeyRequirements(criticalOptions, extensions, role)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	issuer, err := fetchIssuerByRef(ctx, req.Storage, role.IssuerRef)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read CA private key: %w", err)
 	}
-	if privateKeyEntry == nil || privateKeyEntry.Key == "" {
-		return nil, errors.New("failed to read CA private key")
+	if issuer == nil {
+		if role.IssuerRef == "" || role.IssuerRef == defaultRef {
+			return nil, errors.New("failed to read CA private key")
+		}
+		return logical.ErrorResponse(fmt.Sprintf("role references unknown issuer %q", role.IssuerRef)), nil
 	}
 
-	signer, err := ssh.ParsePrivateKey([]byte(privateKeyEntry.Key))
+	signer, err := issuer.signer()
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse stored CA private key: %w", err)
+		return nil, err
 	}
 
 	cBundle := creationBundle{
@@ -136,6 +156,12 @@ func (b *backend) pathSignIssueCertificateHelper(ctx context.Context, req *logic
 		return nil, errors.New("error marshaling signed certificate")
 	}
 
+	if role.StoreCertificates {
+		if err := storeCertificate(ctx, req.Storage, data.Get("role").(string), issuer.ID, certificate, string(signedSSHCertificate)); err != nil {
+			return nil, fmt.Errorf("failed to store certificate: %w", err)
+		}
+	}
+
 	response := &logical.Response{
 		Data: map[string]interface{}{
 			"serial_number": strconv.FormatUint(certificate.Serial, 16),
@@ -150,21 +176,23 @@ func (b *backend) pathSignIssueCertificateHelper(ctx context.Context, req *logic
 	return response, nil
 }
 
-func (b *backend) renderPrincipal(principal string, req *logical.Request) (string, error) {
+// renderPrincipal renders a principal template, which may render to any
+// number of principals when it refers to the entity's groups.
+func (b *backend) renderPrincipal(principal string, req *logical.Request) ([]string, error) {
 	// Look for templating markers {{ .* }}
 	matched := containsTemplateRegex.MatchString(principal)
 	if matched {
 		if req.EntityID != "" {
 			// Retrieve principal based on template + entityID from request.
-			renderedPrincipal, err := framework.PopulateIdentityTemplate(principal, req.EntityID, b.System())
+			renderedPrincipals, err := b.renderIdentityTemplate(principal, req.EntityID)
 			if err != nil {
-				return "", fmt.Errorf("template '%s' could not be rendered -> %s", principal, err)
+				return nil, fmt.Errorf("template '%s' could not be rendered -> %s", principal, err)
 			}
-			return renderedPrincipal, nil
+			return renderedPrincipals, nil
 		}
 	}
 	// Static principal
-	return principal, nil
+	return []string{principal}, nil
 }
 
 func (b *backend) calculateValidPrincipals(data *framework.FieldData, req *logical.Request, role *sshRole, defaultPrincipal, principalsAllowedByRole string, enableTemplating bool, validatePrincipal func([]string, string) bool) ([]string, error) {
@@ -180,11 +208,18 @@ func (b *backend) calculateValidPrincipals(data *framework.FieldData, req *logic
 	// Build list of allowed Principals from template and static principalsAllowedByRole
 	var allowedPrincipals []string
 	if enableTemplating {
-		rendered, err := b.renderPrincipal(principalsAllowedByRole, req)
-		if err != nil {
-			return nil, err
+		// Render each entry separately, so that a template rendering to no
+		// principals doesn't affect the others.
+		for _, principal := range strutil.ParseStringSlice(principalsAllowedByRole, ",") {
+			rendered, err := b.renderPrincipal(principal, req)
+			if err != nil {
+				return nil, err
+			}
+			for _, renderedPrincipal := range rendered {
+				allowedPrincipals = append(allowedPrincipals, strutil.ParseStringSlice(renderedPrincipal, ",")...)
+			}
 		}
-		allowedPrincipals = strutil.RemoveDuplicates(strutil.ParseStringSlice(rendered, ","), false)
+		allowedPrincipals = strutil.RemoveDuplicates(allowedPrincipals, false)
 	} else {
 		allowedPrincipals = strutil.RemoveDuplicates(strutil.ParseStringSlice(principalsAllowedByRole, ","), false)
 	}
@@ -268,19 +303,70 @@ func (b *backend) calculateKeyID(data *framework.FieldData, req *logical.Request
 		keyIDFormat = role.KeyIDFormat
 	}
 
-	keyID := substQuery(keyIDFormat, map[string]string{
+	// Identity templates and the other variables are substituted in a
+	// single pass, so that their values are never interpreted as templates.
+	if identityTemplateRegex.MatchString(keyIDFormat) && req.EntityID == "" {
+		return "", fmt.Errorf("key_id_format contains identity templates, but the request has no entity")
+	}
+
+	variables := map[string]string{
 		"token_display_name": req.DisplayName,
 		"role_name":          data.Get("role").(string),
 		"public_key_hash":    fmt.Sprintf("%x", sha256.Sum256(pubKey.Marshal())),
+	}
+
+	var renderErr error
+	keyID := keyIDTemplateRegex.ReplaceAllStringFunc(keyIDFormat, func(tpl string) string {
+		if !identityTemplateRegex.MatchString(tpl) {
+			if value, ok := variables[tpl[2:len(tpl)-2]]; ok {
+				return value
+			}
+			return tpl
+		}
+
+		rendered, err := b.renderIdentityTemplate(tpl, req.EntityID)
+		if err != nil && renderErr == nil {
+			renderErr = fmt.Errorf("template '%s' could not be rendered -> %s", tpl, err)
+		}
+		return strings.Join(rendered, ",")
 	})
+	if renderErr != nil {
+		return "", renderErr
+	}
 
 	return keyID, nil
 }
 
-func (b *backend) calculateCriticalOptions(data *framework.FieldData, role *sshRole) (map[string]string, error) {
+func (b *backend) calculateCriticalOptions(data *framework.FieldData, req *logical.Request, role *sshRole) (map[string]string, error) {
 	unparsedCriticalOptions := data.Get("critical_options").(map[string]interface{})
 	if len(unparsedCriticalOptions) == 0 {
-		return role.DefaultCriticalOptions, nil
+		if !role.DefaultCriticalOptionsTemplate {
+			return role.DefaultCriticalOptions, nil
+		}
+
+		criticalOptions := make(map[string]string, len(role.DefaultCriticalOptions))
+		for option, value := range role.DefaultCriticalOptions {
+			if !containsTemplateRegex.MatchString(value) {
+				criticalOptions[option] = value
+				continue
+			}
+
+			// Unlike extensions, critical options restrict the certificate,
+			// so one which can't be rendered must fail the request rather
+			// than be left out.
+			if req.EntityID == "" {
+				return nil, fmt.Errorf("critical option %q is templated, but the request has no entity", option)
+			}
+			rendered, err := b.renderIdentityTemplate(value, req.EntityID)
+			if err != nil {
+				return nil, fmt.Errorf("template '%s' could not be rendered -> %s", value, err)
+			}
+			if len(rendered) == 0 {
+				return nil, fmt.Errorf("template '%s' rendered no values for critical option %q", value, option)
+			}
+			criticalOptions[option] = strings.Join(rendered, ",")
+		}
+		return criticalOptions, nil
 	}
 
 	criticalOptions := convertMapToStringValue(unparsedCriticalOptions)
@@ -337,10 +423,10 @@ func (b *backend) calculateExtensions(data *framework.FieldData, req *logical.Re
 			if matched {
 				if req.EntityID != "" {
 					// Retrieve extension value based on template + entityID from request.
-					templateExtensionValue, err := framework.PopulateIdentityTemplate(extensionValue, req.EntityID, b.System())
+					templateExtensionValues, err := b.renderIdentityTemplate(extensionValue, req.EntityID)
 					if err == nil {
 						// Template returned an extension value that we can use
-						extensions[extensionKey] = templateExtensionValue
+						extensions[extensionKey] = strings.Join(templateExtensionValues, ",")
 					} else {
 						return nil, false, fmt.Errorf("template '%s' could not be rendered -> %s", extensionValue, err)
 					}
@@ -359,6 +445,30 @@ func (b *backend) calculateExtensions(data *framework.FieldData, req *logical.Re
 	return extensions, haveMissingEntityInfoWithTemplatedExt, nil
 }
 
+// applySecurityKeyRequirements enforces the role's user presence and user
+// verification requirements for security keys. Touch is required unless the
+// certificate carries the no-touch-required extension, while user
+// verification is only required with the verify-required critical option.
+func (b *backend) applySecurityKeyRequirements(criticalOptions, extensions map[string]string, role *sshRole) (map[string]string, map[string]string, error) {
+	if role.RequireTouch {
+		if _, ok := extensions[extensionNoTouchRequired]; ok {
+			return nil, nil, fmt.Errorf("extension %q is not allowed by role, which requires touch", extensionNoTouchRequired)
+		}
+	}
+
+	if role.RequireVerify {
+		// Copy the options, as they may be the role's defaults.
+		withVerify := make(map[string]string, len(criticalOptions)+1)
+		for option, value := range criticalOptions {
+			withVerify[option] = value
+		}
+		withVerify[criticalOptionVerifyRequired] = ""
+		criticalOptions = withVerify
+	}
+
+	return criticalOptions, extensions, nil
+}
+
 func (b *backend) calculateTTL(data *framework.FieldData, role *sshRole) (time.Duration, error) {
 	var ttl, maxTTL time.Duration
 	var err error
@@ -397,7 +507,22 @@ func (b *backend) calculateTTL(data *framework.FieldData, role *sshRole) (time.D
 	return ttl, nil
 }
 
+// isSecurityKey reports whether the public key is backed by a FIDO/U2F
+// security key.
+func isSecurityKey(publicKey ssh.PublicKey) bool {
+	switch publicKey.Type() {
+	case ssh.KeyAlgoSKECDSA256, ssh.KeyAlgoSKED25519:
+		return true
+	default:
+		return false
+	}
+}
+
 func (b *backend) validateSignedKeyRequirements(publickey ssh.PublicKey, role *sshRole) error {
+	if role.RequireSecurityKey && !isSecurityKey(publickey) {
+		return fmt.Errorf("role requires a security key, but the key is of type %s", publickey.Type())
+	}
+
 	if len(role.AllowedUserKeyTypesLengths) != 0 {
 		var keyType string
 		var keyBits int
@@ -420,6 +545,15 @@ func (b *backend) validateSignedKeyRequirements(publickey ssh.PublicKey, role *s
 			default:
 				return fmt.Errorf("public key type of %s is not allowed", keyType)
 			}
+
+			// Security keys are matched by their own names, as well as by
+			// the names of the underlying key type.
+			switch publickey.Type() {
+			case ssh.KeyAlgoSKECDSA256:
+				keyType = "sk-ecdsa"
+			case ssh.KeyAlgoSKED25519:
+				keyType = "sk-ed25519"
+			}
 		default:
 			return fmt.Errorf("pubkey not suitable for crypto (expected ssh.CryptoPublicKey but found %T)", k)
 		}
@@ -549,6 +683,9 @@ func createKeyTypeToMapKey(keyType string, keyBits int) map[string][]string {
 		"dsa":     {"dsa", ssh.KeyAlgoDSA},
 		"ecdsa":   {"ecdsa", "ec"},
 		"ed25519": {"ed25519", ssh.KeyAlgoED25519},
+
+		"sk-ecdsa":   {"sk-ecdsa", ssh.KeyAlgoSKECDSA256, "ecdsa", "ec", ssh.KeyAlgoECDSA256},
+		"sk-ed25519": {"sk-ed25519", ssh.KeyAlgoSKED25519, "ed25519", ssh.KeyAlgoED25519},
 	}
 
 	if keyType == "ecdsa" {
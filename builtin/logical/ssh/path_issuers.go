@@ -0,0 +1,497 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package ssh
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func addIssuerRefField(fields map[string]*framework.FieldSchema) map[string]*framework.FieldSchema {
+	fields["issuer_ref"] = &framework.FieldSchema{
+		Type:        framework.TypeString,
+		Description: `Reference to an existing issuer, either by ID or name, or "default" for the default issuer.`,
+		Default:     defaultRef,
+	}
+	return fields
+}
+
+func pathListIssuers(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "issuers/?$",
+
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixSSH,
+			OperationSuffix: "issuers",
+		},
+
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ListOperation: &framework.PathOperation{
+				Callback: b.pathIssuersList,
+			},
+		},
+
+		HelpSynopsis:    pathIssuersHelpSyn,
+		HelpDescription: pathIssuersHelpDesc,
+	}
+}
+
+func pathGenerateIssuer(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "issuers/generate",
+
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixSSH,
+			OperationVerb:   "generate",
+			OperationSuffix: "issuer",
+		},
+
+		Fields: map[string]*framework.FieldSchema{
+			"issuer_name": {
+				Type:        framework.TypeString,
+				Description: `Optional name of the issuer; must be unique within the mount.`,
+			},
+			"key_type": {
+				Type:        framework.TypeString,
+				Description: `Specifies the desired key type; could be a OpenSSH key type identifier (ssh-rsa, ecdsa-sha2-nistp256, ecdsa-sha2-nistp384, ecdsa-sha2-nistp521, or ssh-ed25519) or an algorithm (rsa, ec, ed25519).`,
+				Default:     "ssh-rsa",
+			},
+			"key_bits": {
+				Type:        framework.TypeInt,
+				Description: `Specifies the desired key bits for variable-length keys (such as when key_type="ssh-rsa") or which NIST P-curve to use when key_type="ec" (256, 384, or 521).`,
+				Default:     0,
+			},
+		},
+
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.UpdateOperation: &framework.PathOperation{
+				Callback: b.pathIssuerGenerate,
+			},
+		},
+
+		HelpSynopsis:    `Generate a new issuer.`,
+		HelpDescription: `This generates a new CA key pair as an issuer. If the mount has no default issuer, it becomes the default.`,
+	}
+}
+
+func pathImportIssuer(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "issuers/import",
+
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixSSH,
+			OperationVerb:   "import",
+			OperationSuffix: "issuer",
+		},
+
+		Fields: map[string]*framework.FieldSchema{
+			"issuer_name": {
+				Type:        framework.TypeString,
+				Description: `Optional name of the issuer; must be unique within the mount.`,
+			},
+			"private_key": {
+				Type:        framework.TypeString,
+				Description: `Private half of the SSH key that will be used to sign certificates.`,
+				Required:    true,
+			},
+			"public_key": {
+				Type:        framework.TypeString,
+				Description: `Public half of the SSH key that will be used to sign certificates.`,
+				Required:    true,
+			},
+		},
+
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.UpdateOperation: &framework.PathOperation{
+				Callback: b.pathIssuerImport,
+			},
+		},
+
+		HelpSynopsis:    `Import an existing CA key pair as a new issuer.`,
+		HelpDescription: `This imports an existing CA key pair as an issuer. If the mount has no default issuer, it becomes the default.`,
+	}
+}
+
+func pathIssuer(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "issuer/" + framework.GenericNameRegex("issuer_ref"),
+
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixSSH,
+			OperationSuffix: "issuer",
+		},
+
+		Fields: addIssuerRefField(map[string]*framework.FieldSchema{
+			"issuer_name": {
+				Type:        framework.TypeString,
+				Description: `New name of the issuer; must be unique within the mount.`,
+			},
+		}),
+
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ReadOperation: &framework.PathOperation{
+				Callback: b.pathIssuerRead,
+			},
+			logical.UpdateOperation: &framework.PathOperation{
+				Callback: b.pathIssuerUpdate,
+			},
+			logical.DeleteOperation: &framework.PathOperation{
+				Callback: b.pathIssuerDelete,
+			},
+		},
+
+		HelpSynopsis:    pathIssuersHelpSyn,
+		HelpDescription: pathIssuersHelpDesc,
+	}
+}
+
+func pathFetchIssuerPublicKey(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "issuer/" + framework.GenericNameRegex("issuer_ref") + "/public_key",
+
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixSSH,
+			OperationSuffix: "issuer-public-key",
+		},
+
+		Fields: addIssuerRefField(map[string]*framework.FieldSchema{}),
+
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ReadOperation: &framework.PathOperation{
+				Callback: b.pathFetchIssuerPublicKey,
+			},
+		},
+
+		HelpSynopsis:    `Retrieve the public key of an issuer.`,
+		HelpDescription: `This is a raw response endpoint without JSON encoding; use -format=raw or an external tool (e.g., curl) to fetch this value.`,
+	}
+}
+
+func pathConfigIssuers(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "config/issuers",
+
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixSSH,
+		},
+
+		Fields: map[string]*framework.FieldSchema{
+			"default": {
+				Type:        framework.TypeString,
+				Description: `Reference (ID or name) of the issuer used by roles which do not pin one.`,
+			},
+		},
+
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ReadOperation: &framework.PathOperation{
+				Callback: b.pathConfigIssuersRead,
+				DisplayAttrs: &framework.DisplayAttributes{
+					OperationSuffix: "issuers-configuration",
+				},
+			},
+			logical.UpdateOperation: &framework.PathOperation{
+				Callback: b.pathConfigIssuersWrite,
+				DisplayAttrs: &framework.DisplayAttributes{
+					OperationVerb:   "configure",
+					OperationSuffix: "issuers",
+				},
+			},
+		},
+
+		HelpSynopsis:    `Read and set the default issuer.`,
+		HelpDescription: `Roles which do not pin an issuer with issuer_ref sign certificates with the default issuer.`,
+	}
+}
+
+func issuerResponse(issuer *sshIssuerEntry, defaultIssuerID string) *logical.Response {
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"issuer_id":   issuer.ID,
+			"issuer_name": issuer.Name,
+			"public_key":  issuer.PublicKey,
+			"is_default":  issuer.ID == defaultIssuerID,
+		},
+	}
+}
+
+func (b *backend) pathIssuersList(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	config, err := getIssuersConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	var defaultIssuerID string
+	if config != nil {
+		defaultIssuerID = config.DefaultIssuerID
+	}
+
+	ids, err := listIssuers(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	keyInfo := map[string]interface{}{}
+	for _, id := range ids {
+		issuer, err := fetchIssuerByID(ctx, req.Storage, id)
+		if err != nil {
+			return nil, err
+		}
+		if issuer == nil {
+			continue
+		}
+		keyInfo[id] = map[string]interface{}{
+			"issuer_name": issuer.Name,
+			"is_default":  id == defaultIssuerID,
+		}
+	}
+
+	return logical.ListResponseWithInfo(ids, keyInfo), nil
+}
+
+func (b *backend) pathIssuerGenerate(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	publicKey, privateKey, err := generateSSHKeyPair(b.Backend.GetRandomReader(), data.Get("key_type").(string), data.Get("key_bits").(int))
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	return b.storeNewIssuer(ctx, req, data.Get("issuer_name").(string), publicKey, privateKey)
+}
+
+func (b *backend) pathIssuerImport(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	publicKey := data.Get("public_key").(string)
+	privateKey := data.Get("private_key").(string)
+	if publicKey == "" || privateKey == "" {
+		return logical.ErrorResponse("both public_key and private_key must be set"), nil
+	}
+
+	if err := validateIssuerKeys(publicKey, privateKey); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	return b.storeNewIssuer(ctx, req, data.Get("issuer_name").(string), publicKey, privateKey)
+}
+
+func (b *backend) storeNewIssuer(ctx context.Context, req *logical.Request, name, publicKey, privateKey string) (*logical.Response, error) {
+	b.issuersLock.Lock()
+	defer b.issuersLock.Unlock()
+
+	if err := migrateLegacyCA(ctx, req.Storage); err != nil {
+		return nil, err
+	}
+
+	if err := validateIssuerName(ctx, req.Storage, name, ""); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	issuer, err := createIssuer(ctx, req.Storage, name, publicKey, privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := getIssuersConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	return issuerResponse(issuer, config.DefaultIssuerID), nil
+}
+
+func (b *backend) pathIssuerRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	issuer, err := fetchIssuerByRef(ctx, req.Storage, data.Get("issuer_ref").(string))
+	if err != nil {
+		return nil, err
+	}
+	if issuer == nil || issuer.ID == "" {
+		return nil, nil
+	}
+
+	config, err := getIssuersConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	return issuerResponse(issuer, config.DefaultIssuerID), nil
+}
+
+func (b *backend) pathIssuerUpdate(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	b.issuersLock.Lock()
+	defer b.issuersLock.Unlock()
+
+	if err := migrateLegacyCA(ctx, req.Storage); err != nil {
+		return nil, err
+	}
+
+	issuerRef := data.Get("issuer_ref").(string)
+	issuer, err := fetchIssuerByRef(ctx, req.Storage, issuerRef)
+	if err != nil {
+		return nil, err
+	}
+	if issuer == nil {
+		return logical.ErrorResponse(fmt.Sprintf("unable to find issuer %q", issuerRef)), nil
+	}
+
+	if nameRaw, ok := data.GetOk("issuer_name"); ok {
+		name := nameRaw.(string)
+		if err := validateIssuerName(ctx, req.Storage, name, issuer.ID); err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+		issuer.Name = name
+	}
+
+	if err := putIssuer(ctx, req.Storage, issuer); err != nil {
+		return nil, err
+	}
+
+	config, err := getIssuersConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	return issuerResponse(issuer, config.DefaultIssuerID), nil
+}
+
+func (b *backend) pathIssuerDelete(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	b.issuersLock.Lock()
+	defer b.issuersLock.Unlock()
+
+	if err := migrateLegacyCA(ctx, req.Storage); err != nil {
+		return nil, err
+	}
+
+	issuer, err := fetchIssuerByRef(ctx, req.Storage, data.Get("issuer_ref").(string))
+	if err != nil {
+		return nil, err
+	}
+	if issuer == nil {
+		return nil, nil
+	}
+
+	return b.deleteIssuer(ctx, req.Storage, issuer)
+}
+
+// deleteIssuer removes an issuer, leaving the mount without a default
+// issuer when it was the default one. Callers must hold the issuers lock.
+func (b *backend) deleteIssuer(ctx context.Context, s logical.Storage, issuer *sshIssuerEntry) (*logical.Response, error) {
+	if err := s.Delete(ctx, issuerStoragePrefix+issuer.ID); err != nil {
+		return nil, err
+	}
+
+	// The legacy key pair kept after migrating it must not outlive the
+	// issuer it was migrated to.
+	legacy, err := fetchLegacyIssuer(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	if legacy != nil && legacy.PrivateKey == issuer.PrivateKey {
+		if err := deleteLegacyCAKeys(ctx, s); err != nil {
+			return nil, err
+		}
+	}
+
+	config, err := getIssuersConfig(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	if config.DefaultIssuerID != issuer.ID {
+		return nil, nil
+	}
+
+	config.DefaultIssuerID = ""
+	if err := putIssuersConfig(ctx, s, config); err != nil {
+		return nil, err
+	}
+
+	resp := &logical.Response{}
+	resp.AddWarning("Deleted the default issuer; roles which do not pin an issuer cannot sign certificates until a new default issuer is set.")
+	return resp, nil
+}
+
+func (b *backend) pathFetchIssuerPublicKey(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	issuer, err := fetchIssuerByRef(ctx, req.Storage, data.Get("issuer_ref").(string))
+	if err != nil {
+		return nil, err
+	}
+	if issuer == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			logical.HTTPContentType: "text/plain",
+			logical.HTTPRawBody:     []byte(issuer.PublicKey),
+			logical.HTTPStatusCode:  http.StatusOK,
+		},
+	}, nil
+}
+
+func (b *backend) pathConfigIssuersRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	config, err := getIssuersConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		config = &sshIssuersConfig{}
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"default": config.DefaultIssuerID,
+		},
+	}, nil
+}
+
+func (b *backend) pathConfigIssuersWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	b.issuersLock.Lock()
+	defer b.issuersLock.Unlock()
+
+	if err := migrateLegacyCA(ctx, req.Storage); err != nil {
+		return nil, err
+	}
+
+	ref := strings.TrimSpace(data.Get("default").(string))
+	if ref == "" || ref == defaultRef {
+		return logical.ErrorResponse("a reference to an issuer must be provided in default"), nil
+	}
+
+	id, err := resolveIssuerReference(ctx, req.Storage, ref)
+	if err != nil {
+		return nil, err
+	}
+	if id == "" {
+		return logical.ErrorResponse(fmt.Sprintf("unable to find issuer %q", ref)), nil
+	}
+
+	config, err := getIssuersConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	config.DefaultIssuerID = id
+	if err := putIssuersConfig(ctx, req.Storage, config); err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"default": id,
+		},
+	}, nil
+}
+
+const pathIssuersHelpSyn = `
+Manage the issuers (CA key pairs) of this mount.
+`
+
+const pathIssuersHelpDesc = `
+A mount may have multiple issuers, each a CA key pair which can sign
+certificates. Roles sign with the issuer they pin with 'issuer_ref', or the
+default issuer set at 'config/issuers'. The 'public_key' endpoint serves the
+public keys of all issuers, allowing the CA to be rotated without a hard
+cutover: generate a new issuer, distribute the combined public keys, make the
+new issuer the default, and delete the old issuer once its certificates have
+expired.
+`
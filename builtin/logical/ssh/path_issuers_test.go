@@ -0,0 +1,333 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package ssh
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestSSH_IssuerRotation(t *testing.T) {
+	t.Parallel()
+
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+
+	b, err := Factory(context.Background(), config)
+	require.NoError(t, err)
+
+	request := func(op logical.Operation, path string, data map[string]interface{}) (*logical.Response, error) {
+		return b.HandleRequest(context.Background(), &logical.Request{
+			Operation: op,
+			Path:      path,
+			Storage:   config.StorageView,
+			Data:      data,
+		})
+	}
+	handle := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		t.Helper()
+		resp, err := request(op, path, data)
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("%s %s failed: err=%v resp=%#v", op, path, err, resp)
+		}
+		return resp
+	}
+	requireError := func(op logical.Operation, path string, data map[string]interface{}) {
+		t.Helper()
+		resp, err := request(op, path, data)
+		require.NoError(t, err)
+		require.True(t, resp != nil && resp.IsError(), "expected %s %s to fail, got %#v", op, path, resp)
+	}
+	signWith := func(role string) *ssh.Certificate {
+		t.Helper()
+		resp := handle(logical.UpdateOperation, "sign/"+role, map[string]interface{}{
+			"public_key":       testCAPublicKeyEd25519,
+			"valid_principals": "ubuntu",
+		})
+		certificate, err := parseStoredCertificate(resp.Data["signed_key"].(string))
+		require.NoError(t, err)
+		return certificate
+	}
+
+	// The first issuer becomes the default.
+	oldResp := handle(logical.UpdateOperation, "issuers/generate", map[string]interface{}{
+		"issuer_name": "old",
+		"key_type":    "ed25519",
+	})
+	oldID := oldResp.Data["issuer_id"].(string)
+	oldKey, err := parsePublicSSHKey(oldResp.Data["public_key"].(string))
+	require.NoError(t, err)
+	require.Equal(t, true, oldResp.Data["is_default"])
+
+	newResp := handle(logical.UpdateOperation, "issuers/import", map[string]interface{}{
+		"issuer_name": "new",
+		"public_key":  testCAPublicKey,
+		"private_key": testCAPrivateKey,
+	})
+	newID := newResp.Data["issuer_id"].(string)
+	newKey, err := parsePublicSSHKey(testCAPublicKey)
+	require.NoError(t, err)
+	require.Equal(t, false, newResp.Data["is_default"])
+
+	// Names must be unique and distinguishable from references.
+	requireError(logical.UpdateOperation, "issuers/generate", map[string]interface{}{"issuer_name": "old"})
+	requireError(logical.UpdateOperation, "issuers/generate", map[string]interface{}{"issuer_name": "default"})
+	requireError(logical.UpdateOperation, "issuers/import", map[string]interface{}{
+		"public_key":  oldResp.Data["public_key"],
+		"private_key": testCAPrivateKey,
+	})
+
+	listResp := handle(logical.ListOperation, "issuers/", nil)
+	require.ElementsMatch(t, []string{oldID, newID}, listResp.Data["keys"])
+
+	// The bundle contains both issuers, the default one first.
+	bundleResp := handle(logical.ReadOperation, "public_key", nil)
+	bundle := strings.Split(strings.TrimSpace(string(bundleResp.Data[logical.HTTPRawBody].([]byte))), "\n")
+	require.Equal(t, []string{
+		strings.TrimSpace(oldResp.Data["public_key"].(string)),
+		strings.TrimSpace(testCAPublicKey),
+	}, bundle)
+
+	issuerKeyResp := handle(logical.ReadOperation, "issuer/new/public_key", nil)
+	require.Equal(t, testCAPublicKey, string(issuerKeyResp.Data[logical.HTTPRawBody].([]byte)))
+
+	roleData := func(issuerRef string) map[string]interface{} {
+		return map[string]interface{}{
+			"key_type":                "ca",
+			"allow_user_certificates": true,
+			"allowed_users":           "*",
+			"store_certificates":      true,
+			"issuer_ref":              issuerRef,
+		}
+	}
+	requireError(logical.UpdateOperation, "roles/missing", roleData("missing"))
+	handle(logical.UpdateOperation, "roles/floating", roleData("default"))
+	handle(logical.UpdateOperation, "roles/pinned", roleData("new"))
+
+	roleResp := handle(logical.ReadOperation, "roles/pinned", nil)
+	require.Equal(t, "new", roleResp.Data["issuer_ref"])
+
+	oldCert := signWith("floating")
+	require.Equal(t, oldKey.Marshal(), oldCert.SignatureKey.Marshal())
+	require.Equal(t, newKey.Marshal(), signWith("pinned").SignatureKey.Marshal())
+
+	// Rotate: the default role now signs with the new issuer.
+	configResp := handle(logical.UpdateOperation, "config/issuers", map[string]interface{}{"default": "new"})
+	require.Equal(t, newID, configResp.Data["default"])
+	newCert := signWith("floating")
+	require.Equal(t, newKey.Marshal(), newCert.SignatureKey.Marshal())
+
+	caResp := handle(logical.ReadOperation, "config/ca", nil)
+	require.Equal(t, testCAPublicKey, caResp.Data["public_key"])
+
+	// Revocations are grouped by the issuer which signed each certificate.
+	handle(logical.UpdateOperation, "revoke", map[string]interface{}{"serial_number": serialNumberString(oldCert.Serial)})
+	handle(logical.UpdateOperation, "revoke", map[string]interface{}{"serial_number": serialNumberString(newCert.Serial)})
+	krlResp := handle(logical.ReadOperation, "krl", nil)
+	require.Equal(t, map[string][]uint64{
+		string(oldKey.Marshal()): {oldCert.Serial},
+		string(newKey.Marshal()): {newCert.Serial},
+	}, parseTestKRL(t, krlResp.Data[logical.HTTPRawBody].([]byte)))
+
+	certResp := handle(logical.ReadOperation, "cert/"+serialNumberString(oldCert.Serial), nil)
+	require.Equal(t, oldID, certResp.Data["issuer_id"])
+
+	// Retire the old issuer.
+	handle(logical.UpdateOperation, "issuer/old", map[string]interface{}{"issuer_name": "retired"})
+	issuerResp := handle(logical.ReadOperation, "issuer/retired", nil)
+	require.Equal(t, oldID, issuerResp.Data["issuer_id"])
+	handle(logical.DeleteOperation, "issuer/retired", nil)
+
+	bundleResp = handle(logical.ReadOperation, "public_key", nil)
+	require.Equal(t, testCAPublicKey, string(bundleResp.Data[logical.HTTPRawBody].([]byte)))
+
+	// Deleting the default issuer leaves the mount without one.
+	deleteResp := handle(logical.DeleteOperation, "issuer/default", nil)
+	require.NotEmpty(t, deleteResp.Warnings)
+	configResp = handle(logical.ReadOperation, "config/issuers", nil)
+	require.Equal(t, "", configResp.Data["default"])
+	_, err = request(logical.UpdateOperation, "sign/floating", map[string]interface{}{
+		"public_key": testCAPublicKeyEd25519,
+	})
+	require.Error(t, err)
+}
+
+func TestSSH_IssuerLegacyMigration(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+
+	b, err := Backend(config)
+	require.NoError(t, err)
+	require.NoError(t, b.Setup(ctx, config))
+
+	for path, key := range map[string]string{
+		caPublicKeyStoragePath:  testCAPublicKey,
+		caPrivateKeyStoragePath: testCAPrivateKey,
+	} {
+		entry, err := logical.StorageEntryJSON(path, &keyStorageEntry{Key: key})
+		require.NoError(t, err)
+		require.NoError(t, config.StorageView.Put(ctx, entry))
+	}
+
+	// Before migrating, the legacy key pair acts as the default issuer.
+	issuer, err := fetchIssuerByRef(ctx, config.StorageView, defaultRef)
+	require.NoError(t, err)
+	require.NotNil(t, issuer)
+	require.Equal(t, testCAPublicKey, issuer.PublicKey)
+
+	require.NoError(t, b.Initialize(ctx, &logical.InitializationRequest{Storage: config.StorageView}))
+
+	ids, err := listIssuers(ctx, config.StorageView)
+	require.NoError(t, err)
+	require.Len(t, ids, 1)
+
+	issuer, err = fetchIssuerByRef(ctx, config.StorageView, defaultRef)
+	require.NoError(t, err)
+	require.Equal(t, ids[0], issuer.ID)
+	require.Equal(t, testCAPublicKey, issuer.PublicKey)
+	require.Equal(t, testCAPrivateKey, issuer.PrivateKey)
+
+	// The legacy key pair is kept, and still readable, for downgrades.
+	legacy, err := fetchLegacyIssuer(ctx, config.StorageView)
+	require.NoError(t, err)
+	require.NotNil(t, legacy)
+	require.Equal(t, testCAPublicKey, legacy.PublicKey)
+	require.Equal(t, testCAPrivateKey, legacy.PrivateKey)
+
+	// Initializing again is a no-op.
+	require.NoError(t, b.Initialize(ctx, &logical.InitializationRequest{Storage: config.StorageView}))
+	ids, err = listIssuers(ctx, config.StorageView)
+	require.NoError(t, err)
+	require.Len(t, ids, 1)
+
+	// Deleting the CA removes the legacy key pair from storage too.
+	_, err = b.HandleRequest(ctx, &logical.Request{
+		Operation: logical.DeleteOperation,
+		Path:      "config/ca",
+		Storage:   config.StorageView,
+	})
+	require.NoError(t, err)
+	issuer, err = fetchIssuerByRef(ctx, config.StorageView, defaultRef)
+	require.NoError(t, err)
+	require.Nil(t, issuer)
+	requireNoLegacyCAKeys(t, config.StorageView)
+}
+
+func TestSSH_IssuerLegacyMigrationDeleteIssuer(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+
+	b, err := Backend(config)
+	require.NoError(t, err)
+	require.NoError(t, b.Setup(ctx, config))
+
+	for path, key := range map[string]string{
+		caPublicKeyStoragePath:  testCAPublicKey,
+		caPrivateKeyStoragePath: testCAPrivateKey,
+	} {
+		entry, err := logical.StorageEntryJSON(path, &keyStorageEntry{Key: key})
+		require.NoError(t, err)
+		require.NoError(t, config.StorageView.Put(ctx, entry))
+	}
+	require.NoError(t, b.Initialize(ctx, &logical.InitializationRequest{Storage: config.StorageView}))
+
+	request := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: op,
+			Path:      path,
+			Storage:   config.StorageView,
+			Data:      data,
+		})
+		require.NoError(t, err)
+		require.False(t, resp != nil && resp.IsError(), "%s %s failed: %#v", op, path, resp)
+		return resp
+	}
+
+	// Deleting another issuer keeps the legacy key pair.
+	resp := request(logical.UpdateOperation, "issuers/generate", map[string]interface{}{"key_type": "ed25519"})
+	request(logical.DeleteOperation, "issuer/"+resp.Data["issuer_id"].(string), nil)
+	legacy, err := fetchLegacyIssuer(ctx, config.StorageView)
+	require.NoError(t, err)
+	require.NotNil(t, legacy)
+
+	// Deleting the issuer it was migrated to removes it.
+	request(logical.DeleteOperation, "issuer/default", nil)
+	requireNoLegacyCAKeys(t, config.StorageView)
+}
+
+func requireNoLegacyCAKeys(t *testing.T, s logical.Storage) {
+	t.Helper()
+
+	for _, path := range []string{
+		caPublicKeyStoragePath,
+		caPublicKeyStoragePathDeprecated,
+		caPrivateKeyStoragePath,
+		caPrivateKeyStoragePathDeprecated,
+	} {
+		entry, err := s.Get(context.Background(), path)
+		require.NoError(t, err)
+		require.Nil(t, entry, "expected legacy key at %s to be removed", path)
+	}
+}
+
+func TestSSH_IssuerLegacyMigrationDeprecatedPaths(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+
+	b, err := Backend(config)
+	require.NoError(t, err)
+	require.NoError(t, b.Setup(ctx, config))
+
+	for path, key := range map[string]string{
+		caPublicKeyStoragePathDeprecated:  testCAPublicKey,
+		caPrivateKeyStoragePathDeprecated: testCAPrivateKey,
+	} {
+		require.NoError(t, config.StorageView.Put(ctx, &logical.StorageEntry{Key: path, Value: []byte(key)}))
+	}
+
+	require.NoError(t, b.Initialize(ctx, &logical.InitializationRequest{Storage: config.StorageView}))
+
+	resp, err := b.HandleRequest(ctx, &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "config/ca",
+		Storage:   config.StorageView,
+	})
+	require.NoError(t, err)
+	require.False(t, resp.IsError(), "unexpected error: %#v", resp)
+	require.Equal(t, testCAPublicKey, resp.Data["public_key"])
+
+	// The legacy key pair was moved off its deprecated paths by the upgrade,
+	// and kept at the current legacy paths.
+	for path, key := range map[string]string{
+		caPublicKeyStoragePath:  testCAPublicKey,
+		caPrivateKeyStoragePath: testCAPrivateKey,
+	} {
+		entry, err := config.StorageView.Get(ctx, path)
+		require.NoError(t, err)
+		require.NotNil(t, entry, "expected legacy key at %s to be kept", path)
+		var keyEntry keyStorageEntry
+		require.NoError(t, entry.DecodeJSON(&keyEntry))
+		require.Equal(t, key, keyEntry.Key)
+	}
+	for _, path := range []string{caPublicKeyStoragePathDeprecated, caPrivateKeyStoragePathDeprecated} {
+		entry, err := config.StorageView.Get(ctx, path)
+		require.NoError(t, err)
+		require.Nil(t, entry)
+	}
+}
@@ -31,41 +31,47 @@ const (
 	// Present version of the sshRole struct; when adding a new field or are
 	// needing to perform a migration, increment this struct and read the note
 	// in checkUpgrade(...).
-	roleEntryVersion = 3
+	roleEntryVersion = 4
 )
 
 // Structure that represents a role in SSH backend. This is a common role structure
 // for both OTP and CA roles. Not all the fields are mandatory for both type.
 // Some are applicable for one and not for other. It doesn't matter.
 type sshRole struct {
-	KeyType                    string            `mapstructure:"key_type" json:"key_type"`
-	DefaultUser                string            `mapstructure:"default_user" json:"default_user"`
-	DefaultUserTemplate        bool              `mapstructure:"default_user_template" json:"default_user_template"`
-	CIDRList                   string            `mapstructure:"cidr_list" json:"cidr_list"`
-	ExcludeCIDRList            string            `mapstructure:"exclude_cidr_list" json:"exclude_cidr_list"`
-	Port                       int               `mapstructure:"port" json:"port"`
-	AllowedUsers               string            `mapstructure:"allowed_users" json:"allowed_users"`
-	AllowedUsersTemplate       bool              `mapstructure:"allowed_users_template" json:"allowed_users_template"`
-	AllowedDomains             string            `mapstructure:"allowed_domains" json:"allowed_domains"`
-	AllowedDomainsTemplate     bool              `mapstructure:"allowed_domains_template" json:"allowed_domains_template"`
-	MaxTTL                     string            `mapstructure:"max_ttl" json:"max_ttl"`
-	TTL                        string            `mapstructure:"ttl" json:"ttl"`
-	DefaultCriticalOptions     map[string]string `mapstructure:"default_critical_options" json:"default_critical_options"`
-	DefaultExtensions          map[string]string `mapstructure:"default_extensions" json:"default_extensions"`
-	DefaultExtensionsTemplate  bool              `mapstructure:"default_extensions_template" json:"default_extensions_template"`
-	AllowedCriticalOptions     string            `mapstructure:"allowed_critical_options" json:"allowed_critical_options"`
-	AllowedExtensions          string            `mapstructure:"allowed_extensions" json:"allowed_extensions"`
-	AllowUserCertificates      bool              `mapstructure:"allow_user_certificates" json:"allow_user_certificates"`
-	AllowHostCertificates      bool              `mapstructure:"allow_host_certificates" json:"allow_host_certificates"`
-	AllowBareDomains           bool              `mapstructure:"allow_bare_domains" json:"allow_bare_domains"`
-	AllowSubdomains            bool              `mapstructure:"allow_subdomains" json:"allow_subdomains"`
-	AllowUserKeyIDs            bool              `mapstructure:"allow_user_key_ids" json:"allow_user_key_ids"`
-	KeyIDFormat                string            `mapstructure:"key_id_format" json:"key_id_format"`
-	OldAllowedUserKeyLengths   map[string]int    `mapstructure:"allowed_user_key_lengths" json:"allowed_user_key_lengths,omitempty"`
-	AllowedUserKeyTypesLengths map[string][]int  `mapstructure:"allowed_user_key_types_lengths" json:"allowed_user_key_types_lengths"`
-	AlgorithmSigner            string            `mapstructure:"algorithm_signer" json:"algorithm_signer"`
-	Version                    int               `mapstructure:"role_version" json:"role_version"`
-	NotBeforeDuration          time.Duration     `mapstructure:"not_before_duration" json:"not_before_duration"`
+	KeyType                        string            `mapstructure:"key_type" json:"key_type"`
+	DefaultUser                    string            `mapstructure:"default_user" json:"default_user"`
+	DefaultUserTemplate            bool              `mapstructure:"default_user_template" json:"default_user_template"`
+	CIDRList                       string            `mapstructure:"cidr_list" json:"cidr_list"`
+	ExcludeCIDRList                string            `mapstructure:"exclude_cidr_list" json:"exclude_cidr_list"`
+	Port                           int               `mapstructure:"port" json:"port"`
+	AllowedUsers                   string            `mapstructure:"allowed_users" json:"allowed_users"`
+	AllowedUsersTemplate           bool              `mapstructure:"allowed_users_template" json:"allowed_users_template"`
+	AllowedDomains                 string            `mapstructure:"allowed_domains" json:"allowed_domains"`
+	AllowedDomainsTemplate         bool              `mapstructure:"allowed_domains_template" json:"allowed_domains_template"`
+	MaxTTL                         string            `mapstructure:"max_ttl" json:"max_ttl"`
+	TTL                            string            `mapstructure:"ttl" json:"ttl"`
+	DefaultCriticalOptions         map[string]string `mapstructure:"default_critical_options" json:"default_critical_options"`
+	DefaultExtensions              map[string]string `mapstructure:"default_extensions" json:"default_extensions"`
+	DefaultExtensionsTemplate      bool              `mapstructure:"default_extensions_template" json:"default_extensions_template"`
+	DefaultCriticalOptionsTemplate bool              `mapstructure:"default_critical_options_template" json:"default_critical_options_template"`
+	AllowedCriticalOptions         string            `mapstructure:"allowed_critical_options" json:"allowed_critical_options"`
+	AllowedExtensions              string            `mapstructure:"allowed_extensions" json:"allowed_extensions"`
+	AllowUserCertificates          bool              `mapstructure:"allow_user_certificates" json:"allow_user_certificates"`
+	AllowHostCertificates          bool              `mapstructure:"allow_host_certificates" json:"allow_host_certificates"`
+	AllowBareDomains               bool              `mapstructure:"allow_bare_domains" json:"allow_bare_domains"`
+	AllowSubdomains                bool              `mapstructure:"allow_subdomains" json:"allow_subdomains"`
+	AllowUserKeyIDs                bool              `mapstructure:"allow_user_key_ids" json:"allow_user_key_ids"`
+	KeyIDFormat                    string            `mapstructure:"key_id_format" json:"key_id_format"`
+	OldAllowedUserKeyLengths       map[string]int    `mapstructure:"allowed_user_key_lengths" json:"allowed_user_key_lengths,omitempty"`
+	AllowedUserKeyTypesLengths     map[string][]int  `mapstructure:"allowed_user_key_types_lengths" json:"allowed_user_key_types_lengths"`
+	AlgorithmSigner                string            `mapstructure:"algorithm_signer" json:"algorithm_signer"`
+	Version                        int               `mapstructure:"role_version" json:"role_version"`
+	NotBeforeDuration              time.Duration     `mapstructure:"not_before_duration" json:"not_before_duration"`
+	StoreCertificates              bool              `mapstructure:"store_certificates" json:"store_certificates"`
+	IssuerRef                      string            `mapstructure:"issuer_ref" json:"issuer_ref"`
+	RequireSecurityKey             bool              `mapstructure:"require_security_key" json:"require_security_key"`
+	RequireTouch                   bool              `mapstructure:"require_touch" json:"require_touch"`
+	RequireVerify                  bool              `mapstructure:"require_verify" json:"require_verify"`
 }
 
 func pathListRoles(b *backend) *framework.Path {
@@ -276,6 +282,16 @@ func pathRoles(b *backend) *framework.Path {
 				`,
 				Default: false,
 			},
+			"default_critical_options_template": {
+				Type: framework.TypeBool,
+				Description: `
+				[Not applicable for OTP type] [Optional for CA type]
+				If set, Default critical option values, such as "force-command" or "source-address",
+				can be specified using identity template policies. Non-templated values are also
+				permitted. Signing fails if a templated value cannot be rendered.
+				`,
+				Default: false,
+			},
 			"allow_user_certificates": {
 				Type: framework.TypeBool,
 				Description: `
@@ -328,6 +344,7 @@ func pathRoles(b *backend) *framework.Path {
 				The following variables are available for use: '{{token_display_name}}' - The display name of
 				the token used to make the request. '{{role_name}}' - The name of the role signing the request.
 				'{{public_key_hash}}' - A SHA256 checksum of the public key that is being signed.
+				Identity templates, such as '{{identity.entity.name}}', are also available.
 				`,
 				DisplayAttrs: &framework.DisplayAttributes{
 					Name: "Key ID Format",
@@ -363,6 +380,55 @@ func pathRoles(b *backend) *framework.Path {
 					Value: 30,
 				},
 			},
+			"store_certificates": {
+				Type: framework.TypeBool,
+				Description: `
+				[Not applicable for OTP type] [Optional for CA type]
+				If set, certificates signed or issued by this role are recorded in the
+				certificate inventory, where they can be listed and revoked.
+				`,
+				Default: false,
+			},
+			"issuer_ref": {
+				Type: framework.TypeString,
+				Description: `
+				[Not applicable for OTP type] [Optional for CA type]
+				Reference to the issuer used to sign certificates, either by ID or
+				name. Defaults to "default", the mount's default issuer.
+				`,
+				Default: defaultRef,
+				DisplayAttrs: &framework.DisplayAttributes{
+					Name: "Issuer Reference",
+				},
+			},
+			"require_security_key": {
+				Type: framework.TypeBool,
+				Description: `
+				[Not applicable for OTP type] [Optional for CA type]
+				If set, only FIDO/U2F security key public keys (sk-ecdsa-sha2-nistp256@openssh.com
+				or sk-ssh-ed25519@openssh.com) can be signed, and key pairs cannot be issued.
+				`,
+				Default: false,
+			},
+			"require_touch": {
+				Type: framework.TypeBool,
+				Description: `
+				[Not applicable for OTP type] [Optional for CA type]
+				If set, certificates cannot carry the "no-touch-required" extension, so that
+				the security key must be touched on every use. Requires "require_security_key".
+				`,
+				Default: false,
+			},
+			"require_verify": {
+				Type: framework.TypeBool,
+				Description: `
+				[Not applicable for OTP type] [Optional for CA type]
+				If set, certificates carry the "verify-required" critical option, so that
+				the security key must verify the user, e.g. by PIN or biometrics, on every
+				use. Requires "require_security_key".
+				`,
+				Default: false,
+			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -460,6 +526,18 @@ func (b *backend) pathRoleWrite(ctx context.Context, req *logical.Request, d *fr
 		if errorResponse != nil {
 			return errorResponse, nil
 		}
+
+		// Like PKI, the default issuer need not exist yet, but a pinned
+		// issuer must.
+		if role.IssuerRef != defaultRef {
+			issuerID, err := resolveIssuerReference(ctx, req.Storage, role.IssuerRef)
+			if err != nil {
+				return nil, err
+			}
+			if issuerID == "" {
+				return logical.ErrorResponse(fmt.Sprintf("unable to find issuer %q", role.IssuerRef)), nil
+			}
+		}
 		roleEntry = *role
 	} else {
 		return logical.ErrorResponse("invalid key type"), nil
@@ -480,25 +558,39 @@ func (b *backend) createCARole(allowedUsers, defaultUser, signer string, data *f
 	ttl := time.Duration(data.Get("ttl").(int)) * time.Second
 	maxTTL := time.Duration(data.Get("max_ttl").(int)) * time.Second
 	role := &sshRole{
-		AllowedCriticalOptions:    data.Get("allowed_critical_options").(string),
-		AllowedExtensions:         data.Get("allowed_extensions").(string),
-		AllowUserCertificates:     data.Get("allow_user_certificates").(bool),
-		AllowHostCertificates:     data.Get("allow_host_certificates").(bool),
-		AllowedUsers:              allowedUsers,
-		AllowedUsersTemplate:      data.Get("allowed_users_template").(bool),
-		AllowedDomains:            data.Get("allowed_domains").(string),
-		AllowedDomainsTemplate:    data.Get("allowed_domains_template").(bool),
-		DefaultUser:               defaultUser,
-		DefaultUserTemplate:       data.Get("default_user_template").(bool),
-		AllowBareDomains:          data.Get("allow_bare_domains").(bool),
-		AllowSubdomains:           data.Get("allow_subdomains").(bool),
-		AllowUserKeyIDs:           data.Get("allow_user_key_ids").(bool),
-		DefaultExtensionsTemplate: data.Get("default_extensions_template").(bool),
-		KeyIDFormat:               data.Get("key_id_format").(string),
-		KeyType:                   KeyTypeCA,
-		AlgorithmSigner:           signer,
-		Version:                   roleEntryVersion,
-		NotBeforeDuration:         time.Duration(data.Get("not_before_duration").(int)) * time.Second,
+		AllowedCriticalOptions:         data.Get("allowed_critical_options").(string),
+		AllowedExtensions:              data.Get("allowed_extensions").(string),
+		AllowUserCertificates:          data.Get("allow_user_certificates").(bool),
+		AllowHostCertificates:          data.Get("allow_host_certificates").(bool),
+		AllowedUsers:                   allowedUsers,
+		AllowedUsersTemplate:           data.Get("allowed_users_template").(bool),
+		AllowedDomains:                 data.Get("allowed_domains").(string),
+		AllowedDomainsTemplate:         data.Get("allowed_domains_template").(bool),
+		DefaultUser:                    defaultUser,
+		DefaultUserTemplate:            data.Get("default_user_template").(bool),
+		AllowBareDomains:               data.Get("allow_bare_domains").(bool),
+		AllowSubdomains:                data.Get("allow_subdomains").(bool),
+		AllowUserKeyIDs:                data.Get("allow_user_key_ids").(bool),
+		DefaultExtensionsTemplate:      data.Get("default_extensions_template").(bool),
+		DefaultCriticalOptionsTemplate: data.Get("default_critical_options_template").(bool),
+		KeyIDFormat:                    data.Get("key_id_format").(string),
+		KeyType:                        KeyTypeCA,
+		AlgorithmSigner:                signer,
+		Version:                        roleEntryVersion,
+		NotBeforeDuration:              time.Duration(data.Get("not_before_duration").(int)) * time.Second,
+		StoreCertificates:              data.Get("store_certificates").(bool),
+		IssuerRef:                      data.Get("issuer_ref").(string),
+		RequireSecurityKey:             data.Get("require_security_key").(bool),
+		RequireTouch:                   data.Get("require_touch").(bool),
+		RequireVerify:                  data.Get("require_verify").(bool),
+	}
+
+	if role.IssuerRef == "" {
+		role.IssuerRef = defaultRef
+	}
+
+	if (role.RequireTouch || role.RequireVerify) && !role.RequireSecurityKey {
+		return nil, logical.ErrorResponse(`"require_touch" and "require_verify" can only be set with "require_security_key"`)
 	}
 
 	if !role.AllowUserCertificates && !role.AllowHostCertificates {
@@ -524,6 +616,10 @@ func (b *backend) createCARole(allowedUsers, defaultUser, signer string, data *f
 	role.DefaultExtensions = defaultExtensions
 	role.AllowedUserKeyTypesLengths = allowedUserKeyLengths
 
+	if _, ok := defaultExtensions[extensionNoTouchRequired]; ok && role.RequireTouch {
+		return nil, logical.ErrorResponse(fmt.Sprintf(`"default_extensions" cannot contain %q when "require_touch" is set`, extensionNoTouchRequired))
+	}
+
 	return role, nil
 }
 
@@ -587,17 +683,17 @@ func (b *backend) checkUpgrade(ctx context.Context, s logical.Storage, n string,
 		// signing key type as we want to make ssh-rsa an explicitly notated
 		// algorithm choice.
 		var publicKey ssh.PublicKey
-		publicKeyEntry, err := caKey(ctx, s, caPublicKey)
+		issuer, err := fetchIssuerByRef(ctx, s, defaultRef)
 		if err != nil {
 			b.Logger().Debug(fmt.Sprintf("failed to load public key entry while attempting to migrate: %v", err))
 			goto SKIPVERSION2
 		}
-		if publicKeyEntry == nil || publicKeyEntry.Key == "" {
+		if issuer == nil || issuer.PublicKey == "" {
 			b.Logger().Debug(fmt.Sprintf("got empty public key entry while attempting to migrate"))
 			goto SKIPVERSION2
 		}
 
-		publicKey, err = parsePublicSSHKey(publicKeyEntry.Key)
+		publicKey, err = parsePublicSSHKey(issuer.PublicKey)
 		if err == nil {
 			// Move an empty signing algorithm to an explicit ssh-rsa (SHA-1)
 			// if this key is of type RSA. This isn't a secure default but
@@ -622,6 +718,16 @@ func (b *backend) checkUpgrade(ctx context.Context, s logical.Storage, n string,
 		result.Version = 3
 	}
 
+	// Role version 4 pins roles created before issuers were introduced to
+	// the default issuer.
+	if result.Version < 4 {
+		modified = true
+		if result.KeyType == KeyTypeCA && result.IssuerRef == "" {
+			result.IssuerRef = defaultRef
+		}
+		result.Version = 4
+	}
+
 	// Add new migrations just before here.
 	//
 	// Condition copied from PKI builtin.
@@ -668,29 +774,35 @@ func (b *backend) parseRole(role *sshRole) (map[string]interface{}, error) {
 		}
 
 		result = map[string]interface{}{
-			"allowed_users":               role.AllowedUsers,
-			"allowed_users_template":      role.AllowedUsersTemplate,
-			"allowed_domains":             role.AllowedDomains,
-			"allowed_domains_template":    role.AllowedDomainsTemplate,
-			"default_user":                role.DefaultUser,
-			"default_user_template":       role.DefaultUserTemplate,
-			"ttl":                         int64(ttl.Seconds()),
-			"max_ttl":                     int64(maxTTL.Seconds()),
-			"allowed_critical_options":    role.AllowedCriticalOptions,
-			"allowed_extensions":          role.AllowedExtensions,
-			"allow_user_certificates":     role.AllowUserCertificates,
-			"allow_host_certificates":     role.AllowHostCertificates,
-			"allow_bare_domains":          role.AllowBareDomains,
-			"allow_subdomains":            role.AllowSubdomains,
-			"allow_user_key_ids":          role.AllowUserKeyIDs,
-			"key_id_format":               role.KeyIDFormat,
-			"key_type":                    role.KeyType,
-			"default_critical_options":    role.DefaultCriticalOptions,
-			"default_extensions":          role.DefaultExtensions,
-			"default_extensions_template": role.DefaultExtensionsTemplate,
-			"allowed_user_key_lengths":    role.AllowedUserKeyTypesLengths,
-			"algorithm_signer":            role.AlgorithmSigner,
-			"not_before_duration":         int64(role.NotBeforeDuration.Seconds()),
+			"allowed_users":                     role.AllowedUsers,
+			"allowed_users_template":            role.AllowedUsersTemplate,
+			"allowed_domains":                   role.AllowedDomains,
+			"allowed_domains_template":          role.AllowedDomainsTemplate,
+			"default_user":                      role.DefaultUser,
+			"default_user_template":             role.DefaultUserTemplate,
+			"ttl":                               int64(ttl.Seconds()),
+			"max_ttl":                           int64(maxTTL.Seconds()),
+			"allowed_critical_options":          role.AllowedCriticalOptions,
+			"allowed_extensions":                role.AllowedExtensions,
+			"allow_user_certificates":           role.AllowUserCertificates,
+			"allow_host_certificates":           role.AllowHostCertificates,
+			"allow_bare_domains":                role.AllowBareDomains,
+			"allow_subdomains":                  role.AllowSubdomains,
+			"allow_user_key_ids":                role.AllowUserKeyIDs,
+			"key_id_format":                     role.KeyIDFormat,
+			"key_type":                          role.KeyType,
+			"default_critical_options":          role.DefaultCriticalOptions,
+			"default_extensions":                role.DefaultExtensions,
+			"default_extensions_template":       role.DefaultExtensionsTemplate,
+			"default_critical_options_template": role.DefaultCriticalOptionsTemplate,
+			"allowed_user_key_lengths":          role.AllowedUserKeyTypesLengths,
+			"algorithm_signer":                  role.AlgorithmSigner,
+			"not_before_duration":               int64(role.NotBeforeDuration.Seconds()),
+			"store_certificates":                role.StoreCertificates,
+			"issuer_ref":                        role.IssuerRef,
+			"require_security_key":              role.RequireSecurityKey,
+			"require_touch":                     role.RequireTouch,
+			"require_verify":                    role.RequireVerify,
 		}
 	case KeyTypeDynamic:
 		return nil, fmt.Errorf("dynamic key type roles are no longer supported")
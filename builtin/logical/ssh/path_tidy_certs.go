@@ -0,0 +1,95 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package ssh
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const defaultTidySafetyBuffer = 72 * time.Hour
+
+func pathTidyCerts(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "tidy/certs",
+
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixSSH,
+			OperationVerb:   "tidy",
+			OperationSuffix: "certificates",
+		},
+
+		Fields: map[string]*framework.FieldSchema{
+			"safety_buffer": {
+				Type: framework.TypeDurationSecond,
+				Description: `The amount of extra time that must have passed
+beyond certificate expiration before it is removed from the certificate
+inventory. Defaults to 72 hours.`,
+				Default: int(defaultTidySafetyBuffer / time.Second),
+			},
+		},
+
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.UpdateOperation: &framework.PathOperation{
+				Callback: b.pathTidyCertsWrite,
+			},
+		},
+
+		HelpSynopsis:    `Remove expired certificates from the certificate inventory.`,
+		HelpDescription: `This removes certificates which expired more than safety_buffer ago from the certificate inventory, along with their revocations. Expired certificates are already left out of the key revocation list, as they are rejected regardless of revocation.`,
+	}
+}
+
+func (b *backend) pathTidyCertsWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	safetyBuffer := time.Duration(d.Get("safety_buffer").(int)) * time.Second
+	if safetyBuffer < 0 {
+		return logical.ErrorResponse("safety_buffer must be non-negative"), nil
+	}
+	cutoff := time.Now().Add(-safetyBuffer)
+
+	b.revokeLock.Lock()
+	defer b.revokeLock.Unlock()
+
+	serials, err := req.Storage.List(ctx, certsStoragePrefix)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list certificates: %w", err)
+	}
+
+	var certsRemoved, revocationsRemoved int
+	for _, serial := range serials {
+		certEntry, err := getCertEntry(ctx, req.Storage, serial)
+		if err != nil {
+			return nil, err
+		}
+		if certEntry == nil || !certEntry.ValidBefore.Before(cutoff) {
+			continue
+		}
+
+		if certEntry.revoked() {
+			if err := req.Storage.Delete(ctx, revokedStoragePrefix+serial); err != nil {
+				return nil, fmt.Errorf("unable to delete revocation of certificate %s: %w", serial, err)
+			}
+			revocationsRemoved++
+		}
+		if err := req.Storage.Delete(ctx, certsStoragePrefix+serial); err != nil {
+			return nil, fmt.Errorf("unable to delete certificate %s: %w", serial, err)
+		}
+		certsRemoved++
+	}
+
+	if revocationsRemoved > 0 {
+		b.invalidateKRL()
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"certificates_removed": certsRemoved,
+			"revocations_removed":  revocationsRemoved,
+		},
+	}, nil
+}
@@ -125,12 +125,3 @@ func convertMapToIntSlice(initial map[string]interface{}) (map[string][]int, err
 
 	return result, nil
 }
-
-// Serve a template processor for custom format inputs
-func substQuery(tpl string, data map[string]string) string {
-	for k, v := range data {
-		tpl = strings.ReplaceAll(tpl, fmt.Sprintf("{{%s}}", k), v)
-	}
-
-	return tpl
-}
@@ -10,6 +10,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/hashicorp/go-multierror"
@@ -41,6 +42,10 @@ func Backend(ctx context.Context, conf *logical.BackendConfig) (*backend, error)
 	var b backend
 	b.Backend = &framework.Backend{
 		PathsSpecial: &logical.Paths{
+			Unauthenticated: []string{
+				"jws/jwks/*",
+			},
+
 			SealWrapStorage: []string{
 				"archive/",
 				"policy/",
@@ -62,15 +67,25 @@ func Backend(ctx context.Context, conf *logical.BackendConfig) (*backend, error)
 			b.pathKeysConfig(),
 			b.pathEncrypt(),
 			b.pathDecrypt(),
+			b.pathEncryptStream(),
+			b.pathDecryptStream(),
+			b.pathListFPETemplates(),
+			b.pathFPETemplates(),
 			b.pathDatakey(),
 			b.pathRandom(),
 			b.pathHash(),
 			b.pathHMAC(),
+			b.pathCMAC(),
 			b.pathSign(),
 			b.pathVerify(),
+			b.pathJWSSign(),
+			b.pathJWSVerify(),
+			b.pathJWKS(),
+			b.pathDeriveSharedSecret(),
 			b.pathBackup(),
 			b.pathRestore(),
 			b.pathTrim(),
+			b.pathKeysVersionUsage(),
 			b.pathCacheConfig(),
 			b.pathConfigKeys(),
 			b.pathCreateCsr(),
@@ -119,6 +134,10 @@ type backend struct {
 	checkAutoRotateAfter time.Time
 	autoRotateOnce       sync.Once
 	backendUUID          string
+	// usageRotationPending is set when a key reaches its
+	// auto_rotate_after_operations limit, so the next periodic run checks for
+	// rotation without waiting for the hourly interval.
+	usageRotationPending atomic.Bool
 }
 
 func GetCacheSizeFromStorage(ctx context.Context, s logical.Storage) (int, error) {
@@ -179,6 +198,9 @@ func (b *backend) invalidate(ctx context.Context, key string) {
 	case strings.HasPrefix(key, "policy/"):
 		name := strings.TrimPrefix(key, "policy/")
 		b.lm.InvalidatePolicy(name)
+	case strings.HasPrefix(key, "usage/"):
+		name := strings.TrimPrefix(key, "usage/")
+		b.lm.InvalidateKeyUsage(name)
 	case strings.HasPrefix(key, "cache-config/"):
 		// Acquire the lock to set the flag to indicate that cache size needs to be refreshed from storage
 		b.configMutex.Lock()
@@ -211,15 +233,14 @@ func (b *backend) periodicFunc(ctx context.Context, req *logical.Request) error
 // on primary nodes and performance secondary nodes which have a local mount.
 func (b *backend) autoRotateKeys(ctx context.Context, req *logical.Request) error {
 	// Only check for autorotation once an hour to avoid unnecessarily iterating
-	// over all keys too frequently.
-	if time.Now().Before(b.checkAutoRotateAfter) {
+	// over all keys too frequently, unless a key has reached its usage limit.
+	if !b.usageRotationPending.Swap(false) && time.Now().Before(b.checkAutoRotateAfter) {
 		return nil
 	}
 	b.checkAutoRotateAfter = time.Now().Add(1 * time.Hour)
 
 	// Early exit if not a primary or performance secondary with a local mount.
-	if b.System().ReplicationState().HasState(consts.ReplicationDRSecondary|consts.ReplicationPerformanceStandby) ||
-		(!b.System().LocalMount() && b.System().ReplicationState().HasState(consts.ReplicationPerformanceSecondary)) {
+	if !b.canWriteStorage() {
 		return nil
 	}
 
@@ -258,6 +279,13 @@ func (b *backend) autoRotateKeys(ctx context.Context, req *logical.Request) erro
 	return errs.ErrorOrNil()
 }
 
+// canWriteStorage returns whether this node can write to the mount's
+// storage: it is a primary, or a performance secondary with a local mount.
+func (b *backend) canWriteStorage() bool {
+	return !b.System().ReplicationState().HasState(consts.ReplicationDRSecondary|consts.ReplicationPerformanceStandby) &&
+		(b.System().LocalMount() || !b.System().ReplicationState().HasState(consts.ReplicationPerformanceSecondary))
+}
+
 // rotateIfRequired rotates a key if it is due for autorotation.
 func (b *backend) rotateIfRequired(ctx context.Context, req *logical.Request, key string, p *keysutil.Policy) error {
 	if !b.System().CachingDisabled() {
@@ -270,9 +298,9 @@ func (b *backend) rotateIfRequired(ctx context.Context, req *logical.Request, ke
 		return nil
 	}
 
-	// If the policy's automatic rotation period is 0, it should not
-	// automatically rotate.
-	if p.AutoRotatePeriod == 0 {
+	// If neither the policy's automatic rotation period nor its operation
+	// limit is set, it should not automatically rotate.
+	if p.AutoRotatePeriod == 0 && p.AutoRotateAfterOperations == 0 {
 		return nil
 	}
 
@@ -283,12 +311,62 @@ func (b *backend) rotateIfRequired(ctx context.Context, req *logical.Request, ke
 
 	// Retrieve the latest version of the policy and determine if it is time to rotate.
 	latestKey := p.Keys[strconv.Itoa(p.LatestVersion)]
-	if time.Now().After(latestKey.CreationTime.Add(p.AutoRotatePeriod)) {
+	if p.AutoRotatePeriod != 0 && time.Now().After(latestKey.CreationTime.Add(p.AutoRotatePeriod)) {
 		if b.Logger().IsDebug() {
 			b.Logger().Debug("automatically rotating key", "key", key)
 		}
 		return p.Rotate(ctx, req.Storage, b.GetRandomReader())
 
 	}
+
+	if p.AutoRotateAfterOperations != 0 {
+		usage, err := b.lm.KeyUsage(ctx, req.Storage, p)
+		if err != nil {
+			return err
+		}
+		if usage[strconv.Itoa(p.LatestVersion)].Operations() >= p.AutoRotateAfterOperations {
+			if b.Logger().IsDebug() {
+				b.Logger().Debug("automatically rotating key after operation limit", "key", key)
+			}
+			return p.Rotate(ctx, req.Storage, b.GetRandomReader())
+		}
+	}
 	return nil
 }
+
+// checkKeyUsageWritable returns logical.ErrReadOnly for operations on keys
+// with an auto_rotate_after_operations limit on nodes which cannot write to
+// storage, so that the request is forwarded to a node which counts it
+// durably; counting only in memory would let the key exceed its limit.
+func (b *backend) checkKeyUsageWritable(p *keysutil.Policy) error {
+	if p.AutoRotateAfterOperations != 0 && p.Type != keysutil.KeyType_MANAGED_KEY && !b.canWriteStorage() {
+		return logical.ErrReadOnly
+	}
+	return nil
+}
+
+// recordKeyUsage counts successful operations against a key version and
+// flags the key for rotation by the periodic function once the latest
+// version reaches the key's auto_rotate_after_operations limit. Failures to
+// persist the counts are logged rather than failing the request, since the
+// operation has already been performed. Nodes which cannot write to storage
+// only count in memory, on top of the reservations written by the active
+// node; operations on keys with a limit are forwarded by
+// checkKeyUsageWritable instead.
+func (b *backend) recordKeyUsage(ctx context.Context, s logical.Storage, p *keysutil.Policy, ver int, usage keysutil.KeyUsageType, n int) {
+	if n <= 0 || p.Type == keysutil.KeyType_MANAGED_KEY {
+		return
+	}
+	if ver == 0 {
+		ver = p.LatestVersion
+	}
+
+	counts, err := b.lm.RecordKeyUsage(ctx, s, p, ver, usage, uint64(n), b.canWriteStorage())
+	if err != nil {
+		b.Logger().Warn("failed to record key usage", "key", p.Name, "error", err)
+	}
+
+	if p.AutoRotateAfterOperations != 0 && ver == p.LatestVersion && counts.Operations() >= p.AutoRotateAfterOperations {
+		b.usageRotationPending.Store(true)
+	}
+}
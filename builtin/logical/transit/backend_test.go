@@ -1759,6 +1759,173 @@ func TestTransit_AutoRotateKeys(t *testing.T) {
 	}
 }
 
+func TestTransit_AutoRotateAfterOperations(t *testing.T) {
+	b, storage := createBackendWithSysView(t)
+
+	doReq := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Storage:   storage,
+			Operation: op,
+			Path:      path,
+			Data:      data,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("request to %s failed: resp: %#v, err: %v", path, resp, err)
+		}
+		return resp
+	}
+
+	doReq(logical.UpdateOperation, "keys/aes", nil)
+	doReq(logical.UpdateOperation, "keys/ed", map[string]interface{}{"type": "ed25519"})
+	resp := doReq(logical.UpdateOperation, "keys/aes/config", map[string]interface{}{
+		"auto_rotate_after_operations": 3,
+	})
+	require.Equal(t, uint64(3), resp.Data["auto_rotate_after_operations"])
+
+	plaintext := base64.StdEncoding.EncodeToString([]byte("the quick brown fox"))
+	doReq(logical.UpdateOperation, "encrypt/aes", map[string]interface{}{"plaintext": plaintext})
+	doReq(logical.UpdateOperation, "datakey/plaintext/aes", nil)
+	doReq(logical.UpdateOperation, "sign/ed", map[string]interface{}{
+		"batch_input": []interface{}{
+			map[string]interface{}{"input": plaintext},
+			map[string]interface{}{"input": plaintext},
+		},
+	})
+
+	resp = doReq(logical.ReadOperation, "keys/aes", nil)
+	usage := resp.Data["usage"].(map[string]interface{})
+	require.Equal(t, uint64(2), usage["1"].(map[string]interface{})["encryptions"])
+	resp = doReq(logical.ReadOperation, "keys/ed", nil)
+	usage = resp.Data["usage"].(map[string]interface{})
+	require.Equal(t, uint64(2), usage["1"].(map[string]interface{})["signatures"])
+
+	// Below the limit nothing is flagged, so the hourly gate still applies.
+	require.False(t, b.usageRotationPending.Load())
+
+	doReq(logical.UpdateOperation, "encrypt/aes", map[string]interface{}{
+		"batch_input": []interface{}{
+			map[string]interface{}{"plaintext": plaintext},
+		},
+	})
+	require.True(t, b.usageRotationPending.Load())
+
+	// The periodic function rotates the key without waiting for the hourly
+	// check.
+	b.checkAutoRotateAfter = time.Now().Add(time.Hour)
+	if err := b.periodicFunc(context.Background(), &logical.Request{Storage: storage}); err != nil {
+		t.Fatal(err)
+	}
+	resp = doReq(logical.ReadOperation, "keys/aes", nil)
+	require.Equal(t, 2, resp.Data["latest_version"])
+	resp = doReq(logical.ReadOperation, "keys/ed", nil)
+	require.Equal(t, 1, resp.Data["latest_version"])
+
+	// Usage of the new version starts from zero.
+	resp = doReq(logical.UpdateOperation, "encrypt/aes", map[string]interface{}{"plaintext": plaintext})
+	require.Equal(t, 2, resp.Data["key_version"])
+	resp = doReq(logical.ReadOperation, "keys/aes", nil)
+	usage = resp.Data["usage"].(map[string]interface{})
+	require.Equal(t, uint64(3), usage["1"].(map[string]interface{})["encryptions"])
+	require.Equal(t, uint64(1), usage["2"].(map[string]interface{})["encryptions"])
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Storage:   storage,
+		Operation: logical.UpdateOperation,
+		Path:      "keys/aes/config",
+		Data:      map[string]interface{}{"auto_rotate_after_operations": -1},
+	})
+	require.NoError(t, err)
+	require.True(t, resp.IsError())
+}
+
+// Operations on keys with an operation limit must be forwarded from nodes
+// which cannot write to storage, rather than counted only in memory.
+func TestTransit_AutoRotateAfterOperations_Standby(t *testing.T) {
+	b, storage := createBackendWithSysView(t)
+
+	_, err := b.HandleRequest(context.Background(), &logical.Request{
+		Storage:   storage,
+		Operation: logical.UpdateOperation,
+		Path:      "keys/limited",
+	})
+	require.NoError(t, err)
+	_, err = b.HandleRequest(context.Background(), &logical.Request{
+		Storage:   storage,
+		Operation: logical.UpdateOperation,
+		Path:      "keys/limited/config",
+		Data:      map[string]interface{}{"auto_rotate_after_operations": 3},
+	})
+	require.NoError(t, err)
+	_, err = b.HandleRequest(context.Background(), &logical.Request{
+		Storage:   storage,
+		Operation: logical.UpdateOperation,
+		Path:      "keys/unlimited",
+	})
+	require.NoError(t, err)
+
+	plaintext := base64.StdEncoding.EncodeToString([]byte(testPlaintext))
+	tests := map[string]struct {
+		repState consts.ReplicationState
+		isLocal  bool
+		forward  bool
+	}{
+		"perf standby": {
+			repState: consts.ReplicationPerformanceStandby,
+			forward:  true,
+		},
+		"perf secondary, no local mount": {
+			repState: consts.ReplicationPerformanceSecondary,
+			forward:  true,
+		},
+		"perf secondary, local mount": {
+			repState: consts.ReplicationPerformanceSecondary,
+			isLocal:  true,
+			forward:  false,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			sysView := logical.TestSystemView()
+			sysView.ReplicationStateVal = test.repState
+			sysView.LocalMountVal = test.isLocal
+			conf := &logical.BackendConfig{
+				StorageView: storage,
+				System:      sysView,
+			}
+			node, _ := Backend(context.Background(), conf)
+			require.NotNil(t, node)
+			require.NoError(t, node.Backend.Setup(context.Background(), conf))
+
+			for _, path := range []string{"encrypt/limited", "datakey/plaintext/limited"} {
+				resp, err := node.HandleRequest(context.Background(), &logical.Request{
+					Storage:   storage,
+					Operation: logical.UpdateOperation,
+					Path:      path,
+					Data:      map[string]interface{}{"plaintext": plaintext},
+				})
+				if test.forward {
+					require.ErrorIs(t, err, logical.ErrReadOnly, path)
+				} else {
+					require.NoError(t, err, path)
+					require.False(t, resp.IsError(), path)
+				}
+			}
+
+			// Keys without a limit are still served locally.
+			resp, err := node.HandleRequest(context.Background(), &logical.Request{
+				Storage:   storage,
+				Operation: logical.UpdateOperation,
+				Path:      "encrypt/unlimited",
+				Data:      map[string]interface{}{"plaintext": plaintext},
+			})
+			require.NoError(t, err)
+			require.False(t, resp.IsError())
+		})
+	}
+}
+
 func TestTransit_AEAD(t *testing.T) {
 	testTransit_AEAD(t, "aes128-gcm96")
 	testTransit_AEAD(t, "aes256-gcm96")
@@ -0,0 +1,290 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package transit
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/helper/keysutil"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func TestTransit_CMAC(t *testing.T) {
+	b, storage := createBackendWithSysView(t)
+
+	// Import the RFC 4493 test key so we can check against the published
+	// test vectors.
+	rawKey, _ := hex.DecodeString("2b7e151628aed2a6abf7158809cf4f3c")
+	importTransitCMACKey(t, b, storage, "cmac-rfc", rawKey)
+
+	vectors := []struct {
+		input string
+		tag   string
+	}{
+		{"", "bb1d6929e95937287fa37d129b756746"},
+		{"6bc1bee22e409f96e93d7e117393172a", "070a16b46b4d4144f79bdd9dd04a287c"},
+		{"6bc1bee22e409f96e93d7e117393172aae2d8a571e03ac9c9eb76fac45af8e5130c81c46a35ce411", "dfa66747de9ae63030ca32611497c827"},
+		{"6bc1bee22e409f96e93d7e117393172aae2d8a571e03ac9c9eb76fac45af8e5130c81c46a35ce411e5fbc1191a0a52eff69f2445df4f9b17ad2b417be66c3710", "51f0bebf7e3b9d92fc49741779363cfe"},
+	}
+
+	for _, v := range vectors {
+		input, _ := hex.DecodeString(v.input)
+		tag, _ := hex.DecodeString(v.tag)
+		expected := "vault:v1:" + base64.StdEncoding.EncodeToString(tag)
+
+		req := &logical.Request{
+			Storage:   storage,
+			Operation: logical.UpdateOperation,
+			Path:      "cmac/cmac-rfc",
+			Data: map[string]interface{}{
+				"input": base64.StdEncoding.EncodeToString(input),
+			},
+		}
+		resp, err := b.HandleRequest(context.Background(), req)
+		if err != nil || resp == nil || resp.IsError() {
+			t.Fatalf("failed to generate CMAC: resp: %#v, err: %v", resp, err)
+		}
+		if resp.Data["cmac"] != expected {
+			t.Fatalf("CMAC mismatch for input %q: expected %s, got %s", v.input, expected, resp.Data["cmac"])
+		}
+
+		// Verify it
+		req.Path = "verify/cmac-rfc"
+		req.Data["cmac"] = expected
+		resp, err = b.HandleRequest(context.Background(), req)
+		if err != nil || resp == nil || resp.IsError() {
+			t.Fatalf("failed to verify CMAC: resp: %#v, err: %v", resp, err)
+		}
+		if !resp.Data["valid"].(bool) {
+			t.Fatalf("CMAC for input %q did not verify", v.input)
+		}
+	}
+
+	// Truncated CMACs are prefixes of the full value
+	input := base64.StdEncoding.EncodeToString([]byte("the quick brown fox"))
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Storage:   storage,
+		Operation: logical.UpdateOperation,
+		Path:      "cmac/cmac-rfc",
+		Data: map[string]interface{}{
+			"input":      input,
+			"mac_length": 8,
+		},
+	})
+	if err != nil || resp == nil || resp.IsError() {
+		t.Fatalf("failed to generate truncated CMAC: resp: %#v, err: %v", resp, err)
+	}
+	truncated := resp.Data["cmac"].(string)
+	if len(truncated) != len("vault:v1:")+12 {
+		t.Fatalf("unexpected truncated CMAC length: %s", truncated)
+	}
+
+	// Invalid lengths are rejected
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Storage:   storage,
+		Operation: logical.UpdateOperation,
+		Path:      "cmac/cmac-rfc",
+		Data: map[string]interface{}{
+			"input":      input,
+			"mac_length": 4,
+		},
+	})
+	if err == nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected error for short mac_length: resp: %#v, err: %v", resp, err)
+	}
+
+	// Batch generate and verify, including a tampered CMAC
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Storage:   storage,
+		Operation: logical.UpdateOperation,
+		Path:      "cmac/cmac-rfc",
+		Data: map[string]interface{}{
+			"batch_input": []interface{}{
+				map[string]interface{}{"input": input, "reference": "one"},
+				map[string]interface{}{"input": "Zm9vYmFy", "reference": "two"},
+				map[string]interface{}{"input": "not base64!"},
+			},
+		},
+	})
+	if err != nil || resp == nil || resp.IsError() {
+		t.Fatalf("failed to generate batch CMAC: resp: %#v, err: %v", resp, err)
+	}
+	results := resp.Data["batch_results"].([]batchResponseCMACItem)
+	if len(results) != 3 || results[0].Reference != "one" || results[1].Reference != "two" {
+		t.Fatalf("unexpected batch results: %#v", results)
+	}
+	if results[2].Error == "" {
+		t.Fatalf("expected error for invalid batch input: %#v", results[2])
+	}
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Storage:   storage,
+		Operation: logical.UpdateOperation,
+		Path:      "verify/cmac-rfc",
+		Data: map[string]interface{}{
+			"batch_input": []interface{}{
+				map[string]interface{}{"input": input, "cmac": results[0].CMAC},
+				map[string]interface{}{"input": input, "cmac": results[1].CMAC},
+				map[string]interface{}{"input": input, "cmac": truncated},
+			},
+		},
+	})
+	if err != nil || resp == nil || resp.IsError() {
+		t.Fatalf("failed to verify batch CMAC: resp: %#v, err: %v", resp, err)
+	}
+	verified := resp.Data["batch_results"].([]batchResponseCMACItem)
+	if !verified[0].Valid || verified[1].Valid || !verified[2].Valid {
+		t.Fatalf("unexpected batch verify results: %#v", verified)
+	}
+
+	// Truncated tags below the minimum length never verify, whatever
+	// their content.
+	fullTag, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(results[0].CMAC, "vault:v1:"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, size := range []int{1, 2, keysutil.CMACMinTagSize - 1} {
+		resp, err = b.HandleRequest(context.Background(), &logical.Request{
+			Storage:   storage,
+			Operation: logical.UpdateOperation,
+			Path:      "verify/cmac-rfc",
+			Data: map[string]interface{}{
+				"input": input,
+				"cmac":  "vault:v1:" + base64.StdEncoding.EncodeToString(fullTag[:size]),
+			},
+		})
+		if err == nil || resp == nil || !resp.IsError() {
+			t.Fatalf("expected error verifying %d byte CMAC: resp: %#v, err: %v", size, resp, err)
+		}
+	}
+
+	// Mixing hmac and cmac is rejected
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Storage:   storage,
+		Operation: logical.UpdateOperation,
+		Path:      "verify/cmac-rfc",
+		Data: map[string]interface{}{
+			"batch_input": []interface{}{
+				map[string]interface{}{"input": input, "cmac": results[0].CMAC},
+				map[string]interface{}{"input": input, "hmac": results[0].CMAC},
+			},
+		},
+	})
+	if err == nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected error mixing hmac and cmac: resp: %#v, err: %v", resp, err)
+	}
+
+	// Generated keys work after rotation, and older versions still verify
+	for _, keyType := range []string{"aes128-cmac", "aes256-cmac"} {
+		resp, err = b.HandleRequest(context.Background(), &logical.Request{
+			Storage:   storage,
+			Operation: logical.UpdateOperation,
+			Path:      "keys/" + keyType,
+			Data: map[string]interface{}{
+				"type": keyType,
+			},
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("failed to create key: resp: %#v, err: %v", resp, err)
+		}
+
+		resp, err = b.HandleRequest(context.Background(), &logical.Request{
+			Storage:   storage,
+			Operation: logical.UpdateOperation,
+			Path:      "cmac/" + keyType,
+			Data: map[string]interface{}{
+				"input": input,
+			},
+		})
+		if err != nil || resp == nil || resp.IsError() {
+			t.Fatalf("failed to generate CMAC: resp: %#v, err: %v", resp, err)
+		}
+		v1 := resp.Data["cmac"].(string)
+
+		_, err = b.HandleRequest(context.Background(), &logical.Request{
+			Storage:   storage,
+			Operation: logical.UpdateOperation,
+			Path:      "keys/" + keyType + "/rotate",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resp, err = b.HandleRequest(context.Background(), &logical.Request{
+			Storage:   storage,
+			Operation: logical.UpdateOperation,
+			Path:      "verify/" + keyType,
+			Data: map[string]interface{}{
+				"input": input,
+				"cmac":  v1,
+			},
+		})
+		if err != nil || resp == nil || resp.IsError() || !resp.Data["valid"].(bool) {
+			t.Fatalf("failed to verify CMAC from previous version: resp: %#v, err: %v", resp, err)
+		}
+
+		// Encryption is not supported with CMAC keys
+		resp, err = b.HandleRequest(context.Background(), &logical.Request{
+			Storage:   storage,
+			Operation: logical.UpdateOperation,
+			Path:      "encrypt/" + keyType,
+			Data: map[string]interface{}{
+				"plaintext": input,
+			},
+		})
+		if err == nil || resp == nil || !resp.IsError() {
+			t.Fatalf("expected error encrypting with CMAC key: resp: %#v, err: %v", resp, err)
+		}
+	}
+
+	// Non-CMAC keys are rejected
+	_, err = b.HandleRequest(context.Background(), &logical.Request{
+		Storage:   storage,
+		Operation: logical.UpdateOperation,
+		Path:      "keys/aes",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Storage:   storage,
+		Operation: logical.UpdateOperation,
+		Path:      "cmac/aes",
+		Data: map[string]interface{}{
+			"input": input,
+		},
+	})
+	if err == nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected error for non-CMAC key: resp: %#v, err: %v", resp, err)
+	}
+}
+
+func importTransitCMACKey(t *testing.T, b *backend, storage logical.Storage, name string, key []byte) {
+	t.Helper()
+
+	wrappingKey, err := b.getWrappingKey(context.Background(), storage)
+	if err != nil {
+		t.Fatalf("failed to get wrapping key: %s", err)
+	}
+	privWrappingKey := wrappingKey.Keys[strconv.Itoa(wrappingKey.LatestVersion)].RSAKey
+	ciphertext := wrapTargetKeyForImport(t, &privWrappingKey.PublicKey, key, "aes128-cmac", "SHA256")
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Storage:   storage,
+		Operation: logical.UpdateOperation,
+		Path:      "keys/" + name + "/import",
+		Data: map[string]interface{}{
+			"ciphertext": ciphertext,
+			"type":       "aes128-cmac",
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("failed to import key: resp: %#v, err: %v", resp, err)
+	}
+}
@@ -126,6 +126,10 @@ func (b *backend) pathDatakeyWrite(ctx context.Context, req *logical.Request, d
 	}
 	defer p.Unlock()
 
+	if err := b.checkKeyUsageWritable(p); err != nil {
+		return nil, err
+	}
+
 	newKey := make([]byte, 32)
 	bits := d.Get("bits").(int)
 	switch bits {
@@ -158,7 +162,7 @@ func (b *backend) pathDatakeyWrite(ctx context.Context, req *logical.Request, d
 		}
 	}
 
-	ciphertext, err := p.EncryptWithFactory(ver, context, nonce, base64.StdEncoding.EncodeToString(newKey), nil, managedKeyFactory)
+	ciphertext, err := p.EncryptWithFactory(ver, context, nonce, base64.StdEncoding.EncodeToString(newKey), nil, managedKeyFactory, RandomReaderFactory{b.GetRandomReader()})
 	if err != nil {
 		switch err.(type) {
 		case errutil.UserError:
@@ -178,6 +182,7 @@ func (b *backend) pathDatakeyWrite(ctx context.Context, req *logical.Request, d
 	if keyVersion == 0 {
 		keyVersion = p.LatestVersion
 	}
+	b.recordKeyUsage(ctx, req.Storage, p, keyVersion, keysutil.KeyUsageEncrypt, 1)
 
 	// Generate the response
 	resp := &logical.Response{
@@ -0,0 +1,178 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package transit
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/cloudflare/circl/kem"
+	"github.com/cloudflare/circl/kem/mlkem/mlkem1024"
+	"github.com/cloudflare/circl/kem/mlkem/mlkem512"
+	"github.com/cloudflare/circl/kem/mlkem/mlkem768"
+	"github.com/cloudflare/circl/kem/xwing"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func TestTransit_Datakey_KEM(t *testing.T) {
+	b, storage := createBackendWithSysView(t)
+
+	schemes := map[string]kem.Scheme{
+		"ml-kem-512":               mlkem512.Scheme(),
+		"ml-kem-768":               mlkem768.Scheme(),
+		"ml-kem-1024":              mlkem1024.Scheme(),
+		"hybrid-x25519-ml-kem-768": xwing.Scheme(),
+	}
+
+	doReq := func(t *testing.T, op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Storage:   storage,
+			Operation: op,
+			Path:      path,
+			Data:      data,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("request to %s failed: resp: %#v, err: %v", path, resp, err)
+		}
+		return resp
+	}
+
+	decrypt := func(t *testing.T, keyName, ciphertext string) string {
+		t.Helper()
+		resp := doReq(t, logical.UpdateOperation, "decrypt/"+keyName, map[string]interface{}{
+			"ciphertext": ciphertext,
+		})
+		return resp.Data["plaintext"].(string)
+	}
+
+	for keyType, scheme := range schemes {
+		t.Run(keyType, func(t *testing.T) {
+			keyName := "kem-" + keyType
+			doReq(t, logical.UpdateOperation, "keys/"+keyName, map[string]interface{}{
+				"type":       keyType,
+				"exportable": true,
+			})
+
+			resp := doReq(t, logical.ReadOperation, "keys/"+keyName, nil)
+			if resp.Data["type"] != keyType || !resp.Data["supports_encryption"].(bool) || !resp.Data["supports_decryption"].(bool) {
+				t.Fatalf("unexpected key read response: %#v", resp.Data)
+			}
+
+			resp = doReq(t, logical.UpdateOperation, "datakey/plaintext/"+keyName, map[string]interface{}{
+				"bits": 512,
+			})
+			plaintext := resp.Data["plaintext"].(string)
+			ciphertext := resp.Data["ciphertext"].(string)
+			if decoded, _ := base64.StdEncoding.DecodeString(plaintext); len(decoded) != 64 {
+				t.Fatalf("unexpected data key length %d", len(decoded))
+			}
+			if got := decrypt(t, keyName, ciphertext); got != plaintext {
+				t.Fatalf("decrypted data key does not match: got %q, expected %q", got, plaintext)
+			}
+
+			// A holder of the exported seed can decapsulate the data key
+			// without Vault.
+			resp = doReq(t, logical.ReadOperation, "export/encryption-key/"+keyName+"/1", nil)
+			seed, err := base64.StdEncoding.DecodeString(resp.Data["keys"].(map[string]string)["1"])
+			if err != nil {
+				t.Fatal(err)
+			}
+			raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(ciphertext, "vault:v1:"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, priv := scheme.DeriveKeyPair(seed)
+			sharedSecret, err := scheme.Decapsulate(priv, raw[:scheme.CiphertextSize()])
+			if err != nil {
+				t.Fatal(err)
+			}
+			aesCipher, err := aes.NewCipher(sharedSecret)
+			if err != nil {
+				t.Fatal(err)
+			}
+			gcm, err := cipher.NewGCM(aesCipher)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sealed := raw[scheme.CiphertextSize():]
+			dataKey, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+			if err != nil {
+				t.Fatalf("failed to open data key: %v", err)
+			}
+			if base64.StdEncoding.EncodeToString(dataKey) != plaintext {
+				t.Fatalf("decapsulated data key does not match")
+			}
+
+			// Encapsulate to an imported public key; only the original key
+			// can recover the data key.
+			resp = doReq(t, logical.ReadOperation, "export/public-key/"+keyName+"/1", nil)
+			publicKey := resp.Data["keys"].(map[string]string)["1"]
+			doReq(t, logical.UpdateOperation, "keys/"+keyName+"-pub/import", map[string]interface{}{
+				"type":       keyType,
+				"public_key": publicKey,
+			})
+			resp = doReq(t, logical.UpdateOperation, "datakey/wrapped/"+keyName+"-pub", nil)
+			if _, ok := resp.Data["plaintext"]; ok {
+				t.Fatalf("wrapped data key response contained plaintext")
+			}
+			wrapped := resp.Data["ciphertext"].(string)
+			if decoded, _ := base64.StdEncoding.DecodeString(decrypt(t, keyName, wrapped)); len(decoded) != 32 {
+				t.Fatalf("unexpected data key length %d", len(decoded))
+			}
+			_, err = b.HandleRequest(context.Background(), &logical.Request{
+				Storage:   storage,
+				Operation: logical.UpdateOperation,
+				Path:      "decrypt/" + keyName + "-pub",
+				Data:      map[string]interface{}{"ciphertext": wrapped},
+			})
+			if err == nil {
+				t.Fatalf("expected decryption with a public-only key to fail")
+			}
+
+			// Importing the seed restores the private key.
+			wrappingKey, err := b.getWrappingKey(context.Background(), storage)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pubWrappingKey := &wrappingKey.Keys[strconv.Itoa(wrappingKey.LatestVersion)].RSAKey.PublicKey
+			doReq(t, logical.UpdateOperation, "keys/"+keyName+"-priv/import", map[string]interface{}{
+				"type":       keyType,
+				"ciphertext": wrapTargetPKCS8ForImport(t, pubWrappingKey, seed, "SHA256"),
+			})
+			if got := decrypt(t, keyName+"-priv", ciphertext); got != plaintext {
+				t.Fatalf("imported key decrypted data key incorrectly")
+			}
+
+			// Associated data is bound to the ciphertext.
+			aad := base64.StdEncoding.EncodeToString([]byte("context"))
+			resp = doReq(t, logical.UpdateOperation, "encrypt/"+keyName, map[string]interface{}{
+				"plaintext":       plaintext,
+				"associated_data": aad,
+			})
+			encrypted := resp.Data["ciphertext"].(string)
+			resp = doReq(t, logical.UpdateOperation, "decrypt/"+keyName, map[string]interface{}{
+				"ciphertext":      encrypted,
+				"associated_data": aad,
+			})
+			if resp.Data["plaintext"] != plaintext {
+				t.Fatalf("decryption with associated data failed")
+			}
+			resp, err = b.HandleRequest(context.Background(), &logical.Request{
+				Storage:   storage,
+				Operation: logical.UpdateOperation,
+				Path:      "decrypt/" + keyName,
+				Data:      map[string]interface{}{"ciphertext": encrypted},
+			})
+			if err == nil || resp == nil || !resp.IsError() {
+				t.Fatalf("expected decryption without associated data to fail: resp: %#v, err: %v", resp, err)
+			}
+		})
+	}
+}
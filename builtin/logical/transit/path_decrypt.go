@@ -85,6 +85,27 @@ data are attested not to have been tampered with.
                 `,
 			},
 
+			"fpe_template": {
+				Type: framework.TypeString,
+				Description: `
+Name of the template used during encryption. Required for, and only valid
+with, aes256-ff1 and aes256-ff3-1 keys.`,
+			},
+
+			"tweak": {
+				Type: framework.TypeString,
+				Description: `
+Base64 encoded tweak used during format preserving encryption.`,
+			},
+
+			"key_version": {
+				Type: framework.TypeInt,
+				Description: `
+The version of the key used during format preserving encryption, as returned
+by encrypt. Required once the key has been rotated. Ignored for other key
+types, whose ciphertexts carry the key version.`,
+			},
+
 			"batch_input": {
 				Type: framework.TypeSlice,
 				Description: `
@@ -129,6 +150,9 @@ func (b *backend) pathDecryptWrite(ctx context.Context, req *logical.Request, d
 			Context:        d.Get("context").(string),
 			Nonce:          d.Get("nonce").(string),
 			AssociatedData: d.Get("associated_data").(string),
+			KeyVersion:     d.Get("key_version").(int),
+			FPETemplate:    d.Get("fpe_template").(string),
+			Tweak:          d.Get("tweak").(string),
 		}
 	}
 
@@ -186,12 +210,25 @@ func (b *backend) pathDecryptWrite(ctx context.Context, req *logical.Request, d
 	}
 	defer p.Unlock()
 
+	if err := b.checkKeyUsageWritable(p); err != nil {
+		return nil, err
+	}
+
 	successesInBatch := false
+	fpeTemplates := make(map[string]*keysutil.FPETemplate)
+	versionUsage := make(map[int]int)
 	for i, item := range batchInputItems {
 		if batchResponseItems[i].Error != "" {
 			continue
 		}
 
+		fpeTemplate, tweak, err := b.fpeItemParams(ctx, req.Storage, p, item, fpeTemplates)
+		if err != nil {
+			userErrorInBatch = true
+			batchResponseItems[i].Error = err.Error()
+			continue
+		}
+
 		var factory interface{}
 		if item.AssociatedData != "" {
 			if !p.Type.AssociatedDataSupported() {
@@ -218,7 +255,12 @@ func (b *backend) pathDecryptWrite(ctx context.Context, req *logical.Request, d
 			}
 		}
 
-		plaintext, err := p.DecryptWithFactory(item.DecodedContext, item.DecodedNonce, item.Ciphertext, factory, managedKeyFactory)
+		var plaintext string
+		if fpeTemplate != nil {
+			plaintext, err = p.DecryptFPE(item.KeyVersion, fpeTemplate, tweak, item.Ciphertext)
+		} else {
+			plaintext, err = p.DecryptWithFactory(item.DecodedContext, item.DecodedNonce, item.Ciphertext, factory, managedKeyFactory)
+		}
 		if err != nil {
 			switch err.(type) {
 			case errutil.InternalError:
@@ -231,6 +273,16 @@ func (b *backend) pathDecryptWrite(ctx context.Context, req *logical.Request, d
 		}
 		successesInBatch = true
 		batchResponseItems[i].Plaintext = plaintext
+
+		keyVersion := item.KeyVersion
+		if fpeTemplate == nil {
+			keyVersion, _ = p.CiphertextVersion(item.Ciphertext)
+		}
+		versionUsage[keyVersion]++
+	}
+
+	for ver, n := range versionUsage {
+		b.recordKeyUsage(ctx, req.Storage, p, ver, keysutil.KeyUsageDecrypt, n)
 	}
 
 	resp := &logical.Response{}
@@ -0,0 +1,209 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package transit
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/errutil"
+	"github.com/hashicorp/vault/sdk/helper/keysutil"
+	"github.com/hashicorp/vault/sdk/logical"
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	sharedSecretKDFNone = "none"
+	sharedSecretKDFHKDF = "hkdf"
+
+	// maxSharedSecretLength bounds the HKDF output; RFC 5869 limits the
+	// output to 255 hash lengths, and anything past a few keys' worth of
+	// material is almost certainly a mistake.
+	maxSharedSecretLength = 512
+)
+
+func (b *backend) pathDeriveSharedSecret() *framework.Path {
+	return &framework.Path{
+		Pattern: "derive-shared-secret/" + framework.GenericNameRegex("name"),
+
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixTransit,
+			OperationVerb:   "derive",
+			OperationSuffix: "shared-secret",
+		},
+
+		Fields: map[string]*framework.FieldSchema{
+			"name": {
+				Type:        framework.TypeString,
+				Description: "The key to use for key agreement",
+			},
+
+			"peer_public_key": {
+				Type: framework.TypeString,
+				Description: `The peer's public key. Either a PEM-encoded PKIX
+public key or, for x25519 keys, the base64-encoded raw 32-byte public key.`,
+			},
+
+			"key_version": {
+				Type: framework.TypeInt,
+				Description: `The version of the key to use for key agreement.
+Must be 0 (for latest) or a value greater than or equal
+to the min_encryption_version configured on the key.`,
+			},
+
+			"kdf": {
+				Type:    framework.TypeString,
+				Default: sharedSecretKDFNone,
+				Description: `The key derivation function to run the shared secret
+through. Valid values are "none" (return the raw ECDH output) and "hkdf".
+Defaults to "none".`,
+			},
+
+			"hash_algorithm": {
+				Type:        framework.TypeString,
+				Default:     defaultHashAlgorithm,
+				Description: `Hash algorithm to use with HKDF. Defaults to "sha2-256".`,
+			},
+
+			"salt": {
+				Type:        framework.TypeString,
+				Description: `Base64-encoded HKDF salt. Optional.`,
+			},
+
+			"info": {
+				Type:        framework.TypeString,
+				Description: `Base64-encoded HKDF context and application specific information. Optional.`,
+			},
+
+			"output_length": {
+				Type:        framework.TypeInt,
+				Default:     32,
+				Description: `Number of bytes of HKDF output to return. Defaults to 32.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathDeriveSharedSecretWrite,
+		},
+
+		HelpSynopsis:    pathDeriveSharedSecretHelpSyn,
+		HelpDescription: pathDeriveSharedSecretHelpDesc,
+	}
+}
+
+func (b *backend) pathDeriveSharedSecretWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+	ver := d.Get("key_version").(int)
+	kdf := d.Get("kdf").(string)
+
+	peerKeyRaw := d.Get("peer_public_key").(string)
+	if peerKeyRaw == "" {
+		return logical.ErrorResponse("missing peer_public_key"), logical.ErrInvalidRequest
+	}
+
+	peerKey := []byte(peerKeyRaw)
+	if decoded, err := base64.StdEncoding.DecodeString(peerKeyRaw); err == nil {
+		peerKey = decoded
+	}
+
+	switch kdf {
+	case sharedSecretKDFNone, sharedSecretKDFHKDF:
+	default:
+		return logical.ErrorResponse("unsupported kdf %q", kdf), logical.ErrInvalidRequest
+	}
+
+	p, _, err := b.GetPolicy(ctx, keysutil.PolicyRequest{
+		Storage: req.Storage,
+		Name:    name,
+	}, b.GetRandomReader())
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return logical.ErrorResponse("key not found"), logical.ErrInvalidRequest
+	}
+	if !b.System().CachingDisabled() {
+		p.Lock(false)
+	}
+	defer p.Unlock()
+
+	if !p.Type.KeyAgreementSupported() {
+		return logical.ErrorResponse("key type %v does not support key agreement", p.Type), logical.ErrInvalidRequest
+	}
+
+	if ver == 0 {
+		ver = p.LatestVersion
+	}
+
+	secret, err := p.DeriveSharedSecret(ver, peerKey)
+	if err != nil {
+		switch err.(type) {
+		case errutil.UserError:
+			return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+		default:
+			return nil, err
+		}
+	}
+
+	if kdf == sharedSecretKDFHKDF {
+		secret, err = hkdfSharedSecret(secret, d)
+		if err != nil {
+			return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+		}
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"shared_secret": base64.StdEncoding.EncodeToString(secret),
+			"key_version":   ver,
+		},
+	}, nil
+}
+
+func hkdfSharedSecret(secret []byte, d *framework.FieldData) ([]byte, error) {
+	algorithm := d.Get("hash_algorithm").(string)
+	hashType, ok := keysutil.HashTypeMap[algorithm]
+	if !ok || hashType == keysutil.HashTypeNone {
+		return nil, fmt.Errorf("unsupported hash_algorithm %q", algorithm)
+	}
+
+	length := d.Get("output_length").(int)
+	if length <= 0 || length > maxSharedSecretLength {
+		return nil, fmt.Errorf("output_length must be between 1 and %d", maxSharedSecretLength)
+	}
+
+	var salt, info []byte
+	var err error
+	if saltRaw := d.Get("salt").(string); saltRaw != "" {
+		salt, err = base64.StdEncoding.DecodeString(saltRaw)
+		if err != nil {
+			return nil, fmt.Errorf("failed to base64-decode salt")
+		}
+	}
+	if infoRaw := d.Get("info").(string); infoRaw != "" {
+		info, err = base64.StdEncoding.DecodeString(infoRaw)
+		if err != nil {
+			return nil, fmt.Errorf("failed to base64-decode info")
+		}
+	}
+
+	out := make([]byte, length)
+	reader := hkdf.New(keysutil.HashFuncMap[hashType], secret, salt, info)
+	if _, err := io.ReadFull(reader, out); err != nil {
+		return nil, fmt.Errorf("failed to derive shared secret: %w", err)
+	}
+
+	return out, nil
+}
+
+const pathDeriveSharedSecretHelpSyn = `Derive a shared secret from the named key and a peer's public key`
+
+const pathDeriveSharedSecretHelpDesc = `
+Performs ECDH (or X25519) key agreement between the private half of the
+named key and the supplied peer public key. The resulting shared secret
+can optionally be run through HKDF before being returned.
+`
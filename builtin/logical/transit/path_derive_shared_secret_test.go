@@ -0,0 +1,241 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package transit
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"io"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"golang.org/x/crypto/hkdf"
+)
+
+func TestTransit_DeriveSharedSecret(t *testing.T) {
+	b, storage := createBackendWithSysView(t)
+
+	cases := []struct {
+		keyType string
+		curve   ecdh.Curve
+	}{
+		{"ecdsa-p256", ecdh.P256()},
+		{"ecdsa-p384", ecdh.P384()},
+		{"ecdsa-p521", ecdh.P521()},
+		{"x25519", ecdh.X25519()},
+	}
+
+	for _, c := range cases {
+		t.Run(c.keyType, func(t *testing.T) {
+			keyName := "ecdh-" + c.keyType
+			resp, err := b.HandleRequest(context.Background(), &logical.Request{
+				Storage:   storage,
+				Operation: logical.UpdateOperation,
+				Path:      "keys/" + keyName,
+				Data: map[string]interface{}{
+					"type": c.keyType,
+				},
+			})
+			if err != nil || (resp != nil && resp.IsError()) {
+				t.Fatalf("failed to create key: resp: %#v, err: %v", resp, err)
+			}
+			if !resp.Data["supports_key_agreement"].(bool) {
+				t.Fatalf("expected key to support key agreement")
+			}
+
+			// Fetch the transit public key
+			resp, err = b.HandleRequest(context.Background(), &logical.Request{
+				Storage:   storage,
+				Operation: logical.ReadOperation,
+				Path:      "export/public-key/" + keyName + "/latest",
+			})
+			if err != nil || resp == nil || resp.IsError() {
+				t.Fatalf("failed to export public key: resp: %#v, err: %v", resp, err)
+			}
+			transitPubKey := parseExportedECDHPublicKey(t, c.curve, resp.Data["keys"].(map[string]string)["1"])
+
+			peerKey, err := c.curve.GenerateKey(rand.Reader)
+			if err != nil {
+				t.Fatal(err)
+			}
+			der, err := x509.MarshalPKIXPublicKey(peerKey.PublicKey())
+			if err != nil {
+				t.Fatal(err)
+			}
+			peerPEM := string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+
+			expected, err := peerKey.ECDH(transitPubKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			resp, err = b.HandleRequest(context.Background(), &logical.Request{
+				Storage:   storage,
+				Operation: logical.UpdateOperation,
+				Path:      "derive-shared-secret/" + keyName,
+				Data: map[string]interface{}{
+					"peer_public_key": peerPEM,
+				},
+			})
+			if err != nil || resp == nil || resp.IsError() {
+				t.Fatalf("failed to derive shared secret: resp: %#v, err: %v", resp, err)
+			}
+			secret, err := base64.StdEncoding.DecodeString(resp.Data["shared_secret"].(string))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(secret, expected) {
+				t.Fatalf("shared secret mismatch")
+			}
+
+			// Now run it through HKDF
+			salt := []byte("salt")
+			info := []byte("info")
+			resp, err = b.HandleRequest(context.Background(), &logical.Request{
+				Storage:   storage,
+				Operation: logical.UpdateOperation,
+				Path:      "derive-shared-secret/" + keyName,
+				Data: map[string]interface{}{
+					"peer_public_key": peerPEM,
+					"kdf":             "hkdf",
+					"salt":            base64.StdEncoding.EncodeToString(salt),
+					"info":            base64.StdEncoding.EncodeToString(info),
+					"output_length":   48,
+				},
+			})
+			if err != nil || resp == nil || resp.IsError() {
+				t.Fatalf("failed to derive shared secret with hkdf: resp: %#v, err: %v", resp, err)
+			}
+			derived, err := base64.StdEncoding.DecodeString(resp.Data["shared_secret"].(string))
+			if err != nil {
+				t.Fatal(err)
+			}
+			expectedDerived := make([]byte, 48)
+			if _, err := io.ReadFull(hkdf.New(sha256.New, expected, salt, info), expectedDerived); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(derived, expectedDerived) {
+				t.Fatalf("hkdf-derived shared secret mismatch")
+			}
+		})
+	}
+
+	t.Run("raw x25519 peer key", func(t *testing.T) {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Storage:   storage,
+			Operation: logical.ReadOperation,
+			Path:      "export/public-key/ecdh-x25519/latest",
+		})
+		if err != nil || resp == nil || resp.IsError() {
+			t.Fatalf("failed to export public key: resp: %#v, err: %v", resp, err)
+		}
+		transitPubKey := parseExportedECDHPublicKey(t, ecdh.X25519(), resp.Data["keys"].(map[string]string)["1"])
+
+		peerKey, err := ecdh.X25519().GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected, err := peerKey.ECDH(transitPubKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resp, err = b.HandleRequest(context.Background(), &logical.Request{
+			Storage:   storage,
+			Operation: logical.UpdateOperation,
+			Path:      "derive-shared-secret/ecdh-x25519",
+			Data: map[string]interface{}{
+				"peer_public_key": base64.StdEncoding.EncodeToString(peerKey.PublicKey().Bytes()),
+			},
+		})
+		if err != nil || resp == nil || resp.IsError() {
+			t.Fatalf("failed to derive shared secret: resp: %#v, err: %v", resp, err)
+		}
+		if resp.Data["shared_secret"].(string) != base64.StdEncoding.EncodeToString(expected) {
+			t.Fatalf("shared secret mismatch")
+		}
+	})
+
+	t.Run("mismatched curve", func(t *testing.T) {
+		peerKey, err := ecdh.P384().GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		der, err := x509.MarshalPKIXPublicKey(peerKey.PublicKey())
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Storage:   storage,
+			Operation: logical.UpdateOperation,
+			Path:      "derive-shared-secret/ecdh-ecdsa-p256",
+			Data: map[string]interface{}{
+				"peer_public_key": string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})),
+			},
+		})
+		if err == nil || resp == nil || !resp.IsError() {
+			t.Fatalf("expected error for mismatched curve: resp: %#v, err: %v", resp, err)
+		}
+	})
+
+	t.Run("unsupported key type", func(t *testing.T) {
+		_, err := b.HandleRequest(context.Background(), &logical.Request{
+			Storage:   storage,
+			Operation: logical.UpdateOperation,
+			Path:      "keys/aes",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Storage:   storage,
+			Operation: logical.UpdateOperation,
+			Path:      "derive-shared-secret/aes",
+			Data: map[string]interface{}{
+				"peer_public_key": "Zm9v",
+			},
+		})
+		if err == nil || resp == nil || !resp.IsError() {
+			t.Fatalf("expected error for unsupported key type: resp: %#v, err: %v", resp, err)
+		}
+	})
+}
+
+func parseExportedECDHPublicKey(t *testing.T, curve ecdh.Curve, exported string) *ecdh.PublicKey {
+	t.Helper()
+
+	if curve == ecdh.X25519() {
+		raw, err := base64.StdEncoding.DecodeString(exported)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pub, err := curve.NewPublicKey(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pub
+	}
+
+	block, _ := pem.Decode([]byte(exported))
+	if block == nil {
+		t.Fatalf("failed to decode exported public key")
+	}
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := parsed.(interface {
+		ECDH() (*ecdh.PublicKey, error)
+	}).ECDH()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pub
+}
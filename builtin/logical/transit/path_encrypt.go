@@ -9,6 +9,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"reflect"
 
@@ -49,6 +50,12 @@ type BatchRequestItem struct {
 	// Reference is an arbitrary caller supplied string value that will be placed on the
 	// batch response to ease correlation between inputs and outputs
 	Reference string `json:"reference" structs:"reference" mapstructure:"reference"`
+
+	// FPETemplate names the template used with format preserving encryption keys
+	FPETemplate string `json:"fpe_template" structs:"fpe_template" mapstructure:"fpe_template"`
+
+	// Tweak is the base64 encoded tweak used with format preserving encryption keys
+	Tweak string `json:"tweak" structs:"tweak" mapstructure:"tweak"`
 }
 
 // EncryptBatchResponseItem represents a response item for batch processing
@@ -85,6 +92,14 @@ func (m ManagedKeyFactory) GetManagedKeyParameters() keysutil.ManagedKeyParamete
 	return m.managedKeyParams
 }
 
+type RandomReaderFactory struct {
+	randReader io.Reader
+}
+
+func (r RandomReaderFactory) GetRandomReader() io.Reader {
+	return r.randReader
+}
+
 func (b *backend) pathEncrypt() *framework.Path {
 	return &framework.Path{
 		Pattern: "encrypt/" + framework.GenericNameRegex("name"),
@@ -170,6 +185,21 @@ data are attested not to have been tampered with.
 				`,
 			},
 
+			"fpe_template": {
+				Type: framework.TypeString,
+				Description: `
+Name of the template describing the format of the plaintext. Required for,
+and only valid with, aes256-ff1 and aes256-ff3-1 keys.`,
+			},
+
+			"tweak": {
+				Type: framework.TypeString,
+				Description: `
+Base64 encoded tweak for format preserving encryption. The same tweak must be
+supplied on decryption. aes256-ff3-1 keys require a 56-bit (7-byte) tweak if
+one is given.`,
+			},
+
 			"batch_input": {
 				Type: framework.TypeSlice,
 				Description: `
@@ -302,6 +332,24 @@ func decodeBatchRequestItems(src interface{}, requirePlaintext bool, requireCiph
 				errs.Errors = append(errs.Errors, fmt.Sprintf("'[%d].reference' expected type 'string', got unconvertible type '%T'", i, item["reference"]))
 			}
 		}
+
+		if v, has := item["fpe_template"]; has {
+			if !reflect.ValueOf(v).IsValid() {
+			} else if casted, ok := v.(string); ok {
+				(*dst)[i].FPETemplate = casted
+			} else {
+				errs.Errors = append(errs.Errors, fmt.Sprintf("'[%d].fpe_template' expected type 'string', got unconvertible type '%T'", i, item["fpe_template"]))
+			}
+		}
+
+		if v, has := item["tweak"]; has {
+			if !reflect.ValueOf(v).IsValid() {
+			} else if casted, ok := v.(string); ok {
+				(*dst)[i].Tweak = casted
+			} else {
+				errs.Errors = append(errs.Errors, fmt.Sprintf("'[%d].tweak' expected type 'string', got unconvertible type '%T'", i, item["tweak"]))
+			}
+		}
 	}
 
 	if len(errs.Errors) > 0 {
@@ -357,6 +405,8 @@ func (b *backend) pathEncryptWrite(ctx context.Context, req *logical.Request, d
 			Nonce:          d.Get("nonce").(string),
 			KeyVersion:     d.Get("key_version").(int),
 			AssociatedData: d.Get("associated_data").(string),
+			FPETemplate:    d.Get("fpe_template").(string),
+			Tweak:          d.Get("tweak").(string),
 		}
 	}
 
@@ -461,17 +511,30 @@ func (b *backend) pathEncryptWrite(ctx context.Context, req *logical.Request, d
 	}
 	defer p.Unlock()
 
+	if err := b.checkKeyUsageWritable(p); err != nil {
+		return nil, err
+	}
+
 	// Process batch request items. If encryption of any request
 	// item fails, respectively mark the error in the response
 	// collection and continue to process other items.
 	warnAboutNonceUsage := false
 	successesInBatch := false
+	fpeTemplates := make(map[string]*keysutil.FPETemplate)
+	versionUsage := make(map[int]int)
 	for i, item := range batchInputItems {
 		if batchResponseItems[i].Error != "" {
 			userErrorInBatch = true
 			continue
 		}
 
+		fpeTemplate, tweak, err := b.fpeItemParams(ctx, req.Storage, p, item, fpeTemplates)
+		if err != nil {
+			userErrorInBatch = true
+			batchResponseItems[i].Error = err.Error()
+			continue
+		}
+
 		if item.Nonce != "" && !nonceAllowed(p) {
 			userErrorInBatch = true
 			batchResponseItems[i].Error = ErrNonceNotAllowed.Error()
@@ -508,7 +571,12 @@ func (b *backend) pathEncryptWrite(ctx context.Context, req *logical.Request, d
 			}
 		}
 
-		ciphertext, err := p.EncryptWithFactory(item.KeyVersion, item.DecodedContext, item.DecodedNonce, item.Plaintext, factory, managedKeyFactory)
+		var ciphertext string
+		if fpeTemplate != nil {
+			ciphertext, err = p.EncryptFPE(item.KeyVersion, fpeTemplate, tweak, item.Plaintext)
+		} else {
+			ciphertext, err = p.EncryptWithFactory(item.KeyVersion, item.DecodedContext, item.DecodedNonce, item.Plaintext, factory, managedKeyFactory, RandomReaderFactory{b.GetRandomReader()})
+		}
 		if err != nil {
 			switch err.(type) {
 			case errutil.InternalError:
@@ -534,6 +602,11 @@ func (b *backend) pathEncryptWrite(ctx context.Context, req *logical.Request, d
 
 		batchResponseItems[i].Ciphertext = ciphertext
 		batchResponseItems[i].KeyVersion = keyVersion
+		versionUsage[keyVersion]++
+	}
+
+	for ver, n := range versionUsage {
+		b.recordKeyUsage(ctx, req.Storage, p, ver, keysutil.KeyUsageEncrypt, n)
 	}
 
 	resp := &logical.Response{}
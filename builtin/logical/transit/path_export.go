@@ -25,6 +25,7 @@ const (
 	exportTypeEncryptionKey    = "encryption-key"
 	exportTypeSigningKey       = "signing-key"
 	exportTypeHMACKey          = "hmac-key"
+	exportTypeCMACKey          = "cmac-key"
 	exportTypePublicKey        = "public-key"
 	exportTypeCertificateChain = "certificate-chain"
 )
@@ -42,7 +43,7 @@ func (b *backend) pathExportKeys() *framework.Path {
 		Fields: map[string]*framework.FieldSchema{
 			"type": {
 				Type:        framework.TypeString,
-				Description: "Type of key to export (encryption-key, signing-key, hmac-key, public-key)",
+				Description: "Type of key to export (encryption-key, signing-key, hmac-key, cmac-key, public-key)",
 			},
 			"name": {
 				Type:        framework.TypeString,
@@ -72,6 +73,7 @@ func (b *backend) pathPolicyExportRead(ctx context.Context, req *logical.Request
 	case exportTypeEncryptionKey:
 	case exportTypeSigningKey:
 	case exportTypeHMACKey:
+	case exportTypeCMACKey:
 	case exportTypePublicKey:
 	case exportTypeCertificateChain:
 	default:
@@ -106,6 +108,10 @@ func (b *backend) pathPolicyExportRead(ctx context.Context, req *logical.Request
 		if !p.Type.SigningSupported() {
 			return logical.ErrorResponse("signing not supported for the key"), logical.ErrInvalidRequest
 		}
+	case exportTypeCMACKey:
+		if !p.Type.CMACSupported() {
+			return logical.ErrorResponse("CMAC not supported for the key"), logical.ErrInvalidRequest
+		}
 	case exportTypeCertificateChain:
 		if !p.Type.SigningSupported() {
 			return logical.ErrorResponse("certificate chain not supported for keys that do not support signing"), logical.ErrInvalidRequest
@@ -175,9 +181,15 @@ func getExportKey(policy *keysutil.Policy, key *keysutil.KeyEntry, exportType st
 		}
 		return strings.TrimSpace(base64.StdEncoding.EncodeToString(src)), nil
 
+	case exportTypeCMACKey:
+		if policy.Type.CMACSupported() {
+			return strings.TrimSpace(base64.StdEncoding.EncodeToString(key.Key)), nil
+		}
+
 	case exportTypeEncryptionKey:
 		switch policy.Type {
-		case keysutil.KeyType_AES128_GCM96, keysutil.KeyType_AES256_GCM96, keysutil.KeyType_ChaCha20_Poly1305:
+		case keysutil.KeyType_AES128_GCM96, keysutil.KeyType_AES256_GCM96, keysutil.KeyType_ChaCha20_Poly1305,
+			keysutil.KeyType_AES256_FF1, keysutil.KeyType_AES256_FF3_1:
 			return strings.TrimSpace(base64.StdEncoding.EncodeToString(key.Key)), nil
 
 		case keysutil.KeyType_RSA2048, keysutil.KeyType_RSA3072, keysutil.KeyType_RSA4096:
@@ -186,6 +198,14 @@ func getExportKey(policy *keysutil.Policy, key *keysutil.KeyEntry, exportType st
 				return "", err
 			}
 			return rsaKey, nil
+
+		case keysutil.KeyType_ML_KEM_512, keysutil.KeyType_ML_KEM_768, keysutil.KeyType_ML_KEM_1024, keysutil.KeyType_HYBRID_X25519_ML_KEM_768:
+			// Key encapsulation private keys are exported as their seed.
+			if len(key.Key) == 0 {
+				return "", nil
+			}
+
+			return base64.StdEncoding.EncodeToString(key.Key), nil
 		}
 
 	case exportTypeSigningKey:
@@ -213,6 +233,17 @@ func getExportKey(policy *keysutil.Policy, key *keysutil.KeyEntry, exportType st
 
 			return strings.TrimSpace(base64.StdEncoding.EncodeToString(key.Key)), nil
 
+		case keysutil.KeyType_ML_DSA_44, keysutil.KeyType_ML_DSA_65, keysutil.KeyType_ML_DSA_87,
+			keysutil.KeyType_SLH_DSA_SHA2_128S, keysutil.KeyType_SLH_DSA_SHA2_128F, keysutil.KeyType_SLH_DSA_SHA2_192S,
+			keysutil.KeyType_SLH_DSA_SHA2_192F, keysutil.KeyType_SLH_DSA_SHA2_256S, keysutil.KeyType_SLH_DSA_SHA2_256F,
+			keysutil.KeyType_HYBRID_ED25519_ML_DSA_65:
+			// Post-quantum private keys are exported as their seed.
+			if len(key.Key) == 0 {
+				return "", nil
+			}
+
+			return base64.StdEncoding.EncodeToString(key.Key), nil
+
 		case keysutil.KeyType_RSA2048, keysutil.KeyType_RSA3072, keysutil.KeyType_RSA4096:
 			rsaKey, err := encodeRSAPrivateKey(key)
 			if err != nil {
@@ -238,7 +269,12 @@ func getExportKey(policy *keysutil.Policy, key *keysutil.KeyEntry, exportType st
 			}
 			return ecKey, nil
 
-		case keysutil.KeyType_ED25519:
+		case keysutil.KeyType_ED25519, keysutil.KeyType_X25519,
+			keysutil.KeyType_ML_DSA_44, keysutil.KeyType_ML_DSA_65, keysutil.KeyType_ML_DSA_87,
+			keysutil.KeyType_SLH_DSA_SHA2_128S, keysutil.KeyType_SLH_DSA_SHA2_128F, keysutil.KeyType_SLH_DSA_SHA2_192S,
+			keysutil.KeyType_SLH_DSA_SHA2_192F, keysutil.KeyType_SLH_DSA_SHA2_256S, keysutil.KeyType_SLH_DSA_SHA2_256F,
+			keysutil.KeyType_HYBRID_ED25519_ML_DSA_65,
+			keysutil.KeyType_ML_KEM_512, keysutil.KeyType_ML_KEM_768, keysutil.KeyType_ML_KEM_1024, keysutil.KeyType_HYBRID_X25519_ML_KEM_768:
 			return strings.TrimSpace(key.FormattedPublicKey), nil
 
 		case keysutil.KeyType_RSA2048, keysutil.KeyType_RSA3072, keysutil.KeyType_RSA4096:
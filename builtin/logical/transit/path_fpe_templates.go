@@ -0,0 +1,217 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package transit
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/keysutil"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const fpeTemplateStoragePrefix = "fpe_template/"
+
+// builtinFPETemplates are always available and cannot be overwritten.
+var builtinFPETemplates = map[string]keysutil.FPETemplate{
+	"numeric":            {Alphabet: "0123456789"},
+	"alphanumeric-lower": {Alphabet: "0123456789abcdefghijklmnopqrstuvwxyz"},
+	"alphanumeric-upper": {Alphabet: "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ"},
+	"alphanumeric":       {Alphabet: "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"},
+}
+
+func (b *backend) pathListFPETemplates() *framework.Path {
+	return &framework.Path{
+		Pattern: "fpe/templates/?$",
+
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixTransit,
+			OperationSuffix: "fpe-templates",
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ListOperation: b.pathFPETemplatesList,
+		},
+
+		HelpSynopsis:    pathFPETemplatesHelpSyn,
+		HelpDescription: pathFPETemplatesHelpDesc,
+	}
+}
+
+func (b *backend) pathFPETemplates() *framework.Path {
+	return &framework.Path{
+		Pattern: "fpe/templates/" + framework.GenericNameRegex("name"),
+
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixTransit,
+			OperationSuffix: "fpe-template",
+		},
+
+		Fields: map[string]*framework.FieldSchema{
+			"name": {
+				Type:        framework.TypeString,
+				Description: "Name of the template",
+			},
+
+			"alphabet": {
+				Type: framework.TypeString,
+				Description: `The characters that are encrypted, in order. The radix
+used for encryption is the number of characters.`,
+			},
+
+			"pattern": {
+				Type: framework.TypeString,
+				Description: `Optional regular expression that must match the whole
+value. Only the characters matched by its capture groups are encrypted. If
+unset, all characters in the alphabet are encrypted and other characters
+are left in place.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathFPETemplateWrite,
+			logical.ReadOperation:   b.pathFPETemplateRead,
+			logical.DeleteOperation: b.pathFPETemplateDelete,
+		},
+
+		HelpSynopsis:    pathFPETemplatesHelpSyn,
+		HelpDescription: pathFPETemplatesHelpDesc,
+	}
+}
+
+func (b *backend) pathFPETemplatesList(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	entries, err := req.Storage.List(ctx, fpeTemplateStoragePrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	return logical.ListResponse(entries), nil
+}
+
+func (b *backend) pathFPETemplateWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+	if _, ok := builtinFPETemplates[name]; ok {
+		return logical.ErrorResponse("cannot overwrite built-in template %q", name), logical.ErrInvalidRequest
+	}
+
+	tmpl := &keysutil.FPETemplate{
+		Alphabet: d.Get("alphabet").(string),
+		Pattern:  d.Get("pattern").(string),
+	}
+	if err := tmpl.Validate(); err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+
+	entry, err := logical.StorageEntryJSON(fpeTemplateStoragePrefix+name, tmpl)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal template: %w", err)
+	}
+	if err := req.Storage.Put(ctx, entry); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (b *backend) pathFPETemplateRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	tmpl, err := b.getFPETemplate(ctx, req.Storage, d.Get("name").(string))
+	if err != nil {
+		return nil, err
+	}
+	if tmpl == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"alphabet": tmpl.Alphabet,
+			"pattern":  tmpl.Pattern,
+		},
+	}, nil
+}
+
+func (b *backend) pathFPETemplateDelete(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+	if _, ok := builtinFPETemplates[name]; ok {
+		return logical.ErrorResponse("cannot delete built-in template %q", name), logical.ErrInvalidRequest
+	}
+
+	return nil, req.Storage.Delete(ctx, fpeTemplateStoragePrefix+name)
+}
+
+// getFPETemplate returns the named built-in or stored template, or nil if
+// it does not exist.
+func (b *backend) getFPETemplate(ctx context.Context, s logical.Storage, name string) (*keysutil.FPETemplate, error) {
+	if builtin, ok := builtinFPETemplates[name]; ok {
+		return &builtin, nil
+	}
+
+	entry, err := s.Get(ctx, fpeTemplateStoragePrefix+name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template: %w", err)
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var tmpl keysutil.FPETemplate
+	if err := entry.DecodeJSON(&tmpl); err != nil {
+		return nil, fmt.Errorf("failed to decode template: %w", err)
+	}
+	return &tmpl, nil
+}
+
+// fpeItemParams resolves the template and decodes the tweak for a batch
+// item, caching templates by name for the duration of the request.
+func (b *backend) fpeItemParams(ctx context.Context, s logical.Storage, p *keysutil.Policy, item BatchRequestItem, cache map[string]*keysutil.FPETemplate) (*keysutil.FPETemplate, []byte, error) {
+	if !p.Type.FPESupported() {
+		if item.FPETemplate != "" || item.Tweak != "" {
+			return nil, nil, fmt.Errorf("fpe_template and tweak are only supported for format preserving encryption keys")
+		}
+		return nil, nil, nil
+	}
+
+	if item.FPETemplate == "" {
+		return nil, nil, fmt.Errorf("fpe_template is required for key type %v", p.Type)
+	}
+
+	tmpl, ok := cache[item.FPETemplate]
+	if !ok {
+		var err error
+		tmpl, err = b.getFPETemplate(ctx, s, item.FPETemplate)
+		if err != nil {
+			return nil, nil, err
+		}
+		if tmpl == nil {
+			return nil, nil, fmt.Errorf("fpe_template %q not found", item.FPETemplate)
+		}
+		if err := tmpl.Validate(); err != nil {
+			return nil, nil, fmt.Errorf("invalid fpe_template %q: %w", item.FPETemplate, err)
+		}
+		cache[item.FPETemplate] = tmpl
+	}
+
+	var tweak []byte
+	if item.Tweak != "" {
+		var err error
+		tweak, err = base64.StdEncoding.DecodeString(item.Tweak)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to base64-decode tweak: %w", err)
+		}
+	}
+
+	return tmpl, tweak, nil
+}
+
+const pathFPETemplatesHelpSyn = `Manage templates for format preserving encryption`
+
+const pathFPETemplatesHelpDesc = `
+Templates describe the alphabet, and optionally the layout, of values
+encrypted with aes256-ff1 and aes256-ff3-1 keys. They are referenced by the
+fpe_template parameter of the encrypt and decrypt endpoints. The built-in
+templates "numeric", "alphanumeric-lower", "alphanumeric-upper" and
+"alphanumeric" are always available.
+`
@@ -0,0 +1,253 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package transit
+
+import (
+	"context"
+	"encoding/base64"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func TestTransit_FPETemplates(t *testing.T) {
+	b, storage := createBackendWithSysView(t)
+
+	req := &logical.Request{
+		Storage:   storage,
+		Operation: logical.UpdateOperation,
+		Path:      "fpe/templates/ccn",
+		Data: map[string]interface{}{
+			"alphabet": "0123456789",
+			"pattern":  `(\d{4})-(\d{4})-(\d{4})-\d{4}`,
+		},
+	}
+	resp, err := b.HandleRequest(context.Background(), req)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v, err: %v", resp, err)
+	}
+
+	req.Operation = logical.ReadOperation
+	resp, err = b.HandleRequest(context.Background(), req)
+	if err != nil || resp == nil || resp.IsError() {
+		t.Fatalf("bad: resp: %#v, err: %v", resp, err)
+	}
+	if resp.Data["alphabet"] != "0123456789" || resp.Data["pattern"] != `(\d{4})-(\d{4})-(\d{4})-\d{4}` {
+		t.Fatalf("unexpected template: %#v", resp.Data)
+	}
+
+	req.Operation = logical.ListOperation
+	req.Path = "fpe/templates"
+	resp, err = b.HandleRequest(context.Background(), req)
+	if err != nil || resp == nil || resp.IsError() {
+		t.Fatalf("bad: resp: %#v, err: %v", resp, err)
+	}
+	if !reflect.DeepEqual(resp.Data["keys"], []string{"ccn"}) {
+		t.Fatalf("unexpected list response: %#v", resp.Data)
+	}
+
+	// Built-in templates are readable but cannot be changed.
+	req.Operation = logical.ReadOperation
+	req.Path = "fpe/templates/numeric"
+	resp, err = b.HandleRequest(context.Background(), req)
+	if err != nil || resp == nil || resp.Data["alphabet"] != "0123456789" {
+		t.Fatalf("bad: resp: %#v, err: %v", resp, err)
+	}
+	for _, op := range []logical.Operation{logical.UpdateOperation, logical.DeleteOperation} {
+		req.Operation = op
+		req.Data = map[string]interface{}{"alphabet": "01"}
+		resp, err = b.HandleRequest(context.Background(), req)
+		if err == nil || resp == nil || !resp.IsError() {
+			t.Fatalf("expected %s of a built-in template to fail", op)
+		}
+	}
+
+	// Invalid templates are rejected.
+	for _, data := range []map[string]interface{}{
+		{"alphabet": "0"},
+		{"alphabet": "0123456789", "pattern": `\d+`},
+		{"alphabet": "0123456789", "pattern": `(\d+`},
+	} {
+		req.Operation = logical.UpdateOperation
+		req.Path = "fpe/templates/invalid"
+		req.Data = data
+		resp, err = b.HandleRequest(context.Background(), req)
+		if err == nil || resp == nil || !resp.IsError() {
+			t.Fatalf("expected template %v to be rejected", data)
+		}
+	}
+
+	req.Operation = logical.DeleteOperation
+	req.Path = "fpe/templates/ccn"
+	resp, err = b.HandleRequest(context.Background(), req)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v, err: %v", resp, err)
+	}
+	req.Operation = logical.ReadOperation
+	resp, err = b.HandleRequest(context.Background(), req)
+	if err != nil || resp != nil {
+		t.Fatalf("expected deleted template to be gone: resp: %#v, err: %v", resp, err)
+	}
+}
+
+func TestTransit_FPE(t *testing.T) {
+	b, storage := createBackendWithSysView(t)
+
+	doReq := func(t *testing.T, path string, data map[string]interface{}) *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Storage:   storage,
+			Operation: logical.UpdateOperation,
+			Path:      path,
+			Data:      data,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("request to %s failed: resp: %#v, err: %v", path, resp, err)
+		}
+		return resp
+	}
+
+	doErrReq := func(t *testing.T, path string, data map[string]interface{}) {
+		t.Helper()
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Storage:   storage,
+			Operation: logical.UpdateOperation,
+			Path:      path,
+			Data:      data,
+		})
+		if err == nil || resp == nil || !resp.IsError() {
+			t.Fatalf("expected request to %s to fail: resp: %#v, err: %v", path, resp, err)
+		}
+	}
+
+	doReq(t, "fpe/templates/ccn", map[string]interface{}{
+		"alphabet": "0123456789",
+		"pattern":  `(\d{4})-(\d{4})-(\d{4})-\d{4}`,
+	})
+
+	plaintext := base64.StdEncoding.EncodeToString([]byte("4111-2222-3333-4444"))
+	tweak := base64.StdEncoding.EncodeToString([]byte("tweak77"))
+
+	for _, keyType := range []string{"aes256-ff1", "aes256-ff3-1"} {
+		t.Run(keyType, func(t *testing.T) {
+			keyName := "fpe-" + keyType
+			doReq(t, "keys/"+keyName, map[string]interface{}{
+				"type": keyType,
+			})
+
+			resp := doReq(t, "encrypt/"+keyName, map[string]interface{}{
+				"plaintext":    plaintext,
+				"fpe_template": "ccn",
+				"tweak":        tweak,
+			})
+			ciphertext := resp.Data["ciphertext"].(string)
+			if len(ciphertext) != 19 || ciphertext[14:] != "-4444" || ciphertext[:4] == "4111" {
+				t.Fatalf("unexpected ciphertext %q", ciphertext)
+			}
+			if resp.Data["key_version"] != 1 {
+				t.Fatalf("unexpected key version %v", resp.Data["key_version"])
+			}
+
+			// Encryption is deterministic for a given tweak.
+			resp = doReq(t, "encrypt/"+keyName, map[string]interface{}{
+				"plaintext":    plaintext,
+				"fpe_template": "ccn",
+				"tweak":        tweak,
+			})
+			if resp.Data["ciphertext"] != ciphertext {
+				t.Fatalf("expected deterministic ciphertext")
+			}
+
+			resp = doReq(t, "decrypt/"+keyName, map[string]interface{}{
+				"ciphertext":   ciphertext,
+				"fpe_template": "ccn",
+				"tweak":        tweak,
+			})
+			if resp.Data["plaintext"] != plaintext {
+				t.Fatalf("unexpected plaintext %v", resp.Data["plaintext"])
+			}
+
+			// Rotation keeps older versions decryptable when the version is
+			// supplied, and the version is required from then on.
+			doReq(t, "keys/"+keyName+"/rotate", nil)
+			doErrReq(t, "decrypt/"+keyName, map[string]interface{}{
+				"ciphertext":   ciphertext,
+				"fpe_template": "ccn",
+				"tweak":        tweak,
+			})
+			resp = doReq(t, "decrypt/"+keyName, map[string]interface{}{
+				"ciphertext":   ciphertext,
+				"fpe_template": "ccn",
+				"tweak":        tweak,
+				"key_version":  1,
+			})
+			if resp.Data["plaintext"] != plaintext {
+				t.Fatalf("unexpected plaintext %v", resp.Data["plaintext"])
+			}
+
+			// Batch input with a built-in template.
+			digits := base64.StdEncoding.EncodeToString([]byte("0123456789"))
+			resp = doReq(t, "encrypt/"+keyName, map[string]interface{}{
+				"batch_input": []interface{}{
+					map[string]interface{}{"plaintext": digits, "fpe_template": "numeric"},
+					map[string]interface{}{"plaintext": digits, "fpe_template": "alphanumeric"},
+				},
+			})
+			results := resp.Data["batch_results"].([]EncryptBatchResponseItem)
+			batchInput := make([]interface{}, len(results))
+			for i, result := range results {
+				if result.Error != "" || result.KeyVersion != 2 {
+					t.Fatalf("unexpected batch result %#v", result)
+				}
+				template := "numeric"
+				if i == 1 {
+					template = "alphanumeric"
+				}
+				batchInput[i] = map[string]interface{}{"ciphertext": result.Ciphertext, "fpe_template": template, "key_version": result.KeyVersion}
+			}
+			resp = doReq(t, "decrypt/"+keyName, map[string]interface{}{
+				"batch_input": batchInput,
+			})
+			for _, result := range resp.Data["batch_results"].([]DecryptBatchResponseItem) {
+				if result.Error != "" || result.Plaintext != digits {
+					t.Fatalf("unexpected batch result %#v", result)
+				}
+			}
+
+			// The template is required.
+			doErrReq(t, "encrypt/"+keyName, map[string]interface{}{
+				"plaintext": plaintext,
+			})
+			doErrReq(t, "encrypt/"+keyName, map[string]interface{}{
+				"plaintext":    plaintext,
+				"fpe_template": "missing",
+			})
+			// Values outside the template are rejected.
+			doErrReq(t, "encrypt/"+keyName, map[string]interface{}{
+				"plaintext":    base64.StdEncoding.EncodeToString([]byte("4111222233334444")),
+				"fpe_template": "ccn",
+			})
+		})
+	}
+
+	// FF3-1 tweaks are exactly 56 bits.
+	doErrReq(t, "encrypt/fpe-aes256-ff3-1", map[string]interface{}{
+		"plaintext":    plaintext,
+		"fpe_template": "ccn",
+		"tweak":        base64.StdEncoding.EncodeToString([]byte("tweak")),
+	})
+
+	// Templates are not accepted for other key types.
+	doReq(t, "keys/aes", nil)
+	doErrReq(t, "encrypt/aes", map[string]interface{}{
+		"plaintext":    plaintext,
+		"fpe_template": "ccn",
+	})
+
+	// Format preserving keys cannot be used for regular encryption.
+	doErrReq(t, "rewrap/fpe-aes256-ff1", map[string]interface{}{
+		"ciphertext": "vault:v1:AAAA",
+	})
+}
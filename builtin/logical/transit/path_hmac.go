@@ -79,6 +79,8 @@ func (b *backend) pathHMAC() *framework.Path {
 * sha3-256
 * sha3-384
 * sha3-512
+* kmac128
+* kmac256
 
 Defaults to "sha2-256".`,
 			},
@@ -157,8 +159,13 @@ func (b *backend) pathHMACWrite(ctx context.Context, req *logical.Request, d *fr
 		return nil, fmt.Errorf("HMAC key value could not be computed")
 	}
 
+	kmac := keysutil.KMACAlgorithm(algorithm)
+	if kmac && p.Type == keysutil.KeyType_MANAGED_KEY {
+		return logical.ErrorResponse("KMAC is not supported with managed keys"), logical.ErrInvalidRequest
+	}
+
 	hashAlgorithm, ok := keysutil.HashTypeMap[algorithm]
-	if !ok {
+	if !ok && !kmac {
 		return logical.ErrorResponse("unsupported algorithm %q", hashAlgorithm), nil
 	}
 
@@ -216,6 +223,11 @@ func (b *backend) pathHMACWrite(ctx context.Context, req *logical.Request, d *fr
 			if err != nil {
 				response[i].err = err
 			}
+		} else if kmac {
+			retBytes, err = keysutil.ComputeKMAC(algorithm, key, input)
+			if err != nil {
+				response[i].err = err
+			}
 		} else {
 			hf := hmac.New(hashAlg, key)
 			hf.Write(input)
@@ -276,8 +288,13 @@ func (b *backend) pathHMACVerify(ctx context.Context, req *logical.Request, d *f
 	}
 	defer p.Unlock()
 
+	if err := b.checkKeyUsageWritable(p); err != nil {
+		return nil, err
+	}
+
+	kmac := keysutil.KMACAlgorithm(algorithm)
 	hashAlgorithm, ok := keysutil.HashTypeMap[algorithm]
-	if !ok {
+	if !ok && !kmac {
 		return logical.ErrorResponse("unsupported algorithm %q", hashAlgorithm), nil
 	}
 
@@ -307,6 +324,7 @@ func (b *backend) pathHMACVerify(ctx context.Context, req *logical.Request, d *f
 	}
 
 	response := make([]batchResponseHMACItem, len(batchInputItems))
+	versionUsage := make(map[int]int)
 
 	for i, item := range batchInputItems {
 		rawInput, ok := item["input"]
@@ -382,10 +400,24 @@ func (b *backend) pathHMACVerify(ctx context.Context, req *logical.Request, d *f
 			continue
 		}
 
-		hf := hmac.New(hashAlg, key)
-		hf.Write(input)
-		retBytes := hf.Sum(nil)
+		var retBytes []byte
+		if kmac {
+			retBytes, err = keysutil.ComputeKMAC(algorithm, key, input)
+			if err != nil {
+				response[i].err = err
+				continue
+			}
+		} else {
+			hf := hmac.New(hashAlg, key)
+			hf.Write(input)
+			retBytes = hf.Sum(nil)
+		}
 		response[i].Valid = hmac.Equal(retBytes, verBytes)
+		versionUsage[ver]++
+	}
+
+	for ver, n := range versionUsage {
+		b.recordKeyUsage(ctx, req.Storage, p, ver, keysutil.KeyUsageVerify, n)
 	}
 
 	// Generate the response
@@ -150,6 +150,13 @@ func TestTransit_HMAC(t *testing.T) {
 		req.Data["format"] = "base64"
 		doRequest(req, false, "vault:v1:GrNA8sU88naMPEQ7UZGj9EJl7YJhl03AFHfxcEURFrtvnobdea9ZlZHePpxAx/oCaC7R2HkrAO+Tu3uXPIl3lg==")
 
+		// Test KMAC
+		req.Data["algorithm"] = "kmac128"
+		doRequest(req, false, "vault:v1:gjJbDEHonmb8KeJvflRp148ZtBJ7FTCd6SYNQSim9VA=")
+
+		req.Data["algorithm"] = "kmac256"
+		doRequest(req, false, "vault:v1:NMafGyLwLshMKNa0o2mRJKwLB+qm+J/FUfXDYEqrrw2YCIdLyfkYg7Ni8aDluHszjgr9STs5MJKttCSCtregKg==")
+
 		req.Data["algorithm"] = "foobar"
 		doRequest(req, true, "")
 
@@ -45,7 +45,10 @@ func (b *backend) pathImport() *framework.Path {
 				Default: "aes256-gcm96",
 				Description: `The type of key being imported. Currently, "aes128-gcm96" (symmetric), "aes256-gcm96" (symmetric), "ecdsa-p256"
 (asymmetric), "ecdsa-p384" (asymmetric), "ecdsa-p521" (asymmetric), "ed25519" (asymmetric), "rsa-2048" (asymmetric), "rsa-3072"
-(asymmetric), "rsa-4096" (asymmetric) are supported.  Defaults to "aes256-gcm96".
+(asymmetric), "rsa-4096" (asymmetric), "hmac", "aes128-cmac", "aes256-cmac",
+"ml-dsa-44", "ml-dsa-65", "ml-dsa-87", "slh-dsa-sha2-128s", "slh-dsa-sha2-128f", "slh-dsa-sha2-192s", "slh-dsa-sha2-192f",
+"slh-dsa-sha2-256s", "slh-dsa-sha2-256f", "hybrid-ed25519-ml-dsa-65", "ml-kem-512", "ml-kem-768", "ml-kem-1024",
+"hybrid-x25519-ml-kem-768", "aes256-ff1" and "aes256-ff3-1" are supported. Post-quantum private keys are imported as their raw seed.  Defaults to "aes256-gcm96".
 `,
 			},
 			"hash_function": {
@@ -214,6 +217,42 @@ func (b *backend) pathImportWrite(ctx context.Context, req *logical.Request, d *
 		polReq.KeyType = keysutil.KeyType_RSA4096
 	case "hmac":
 		polReq.KeyType = keysutil.KeyType_HMAC
+	case "aes128-cmac":
+		polReq.KeyType = keysutil.KeyType_AES128_CMAC
+	case "aes256-cmac":
+		polReq.KeyType = keysutil.KeyType_AES256_CMAC
+	case "aes256-ff1":
+		polReq.KeyType = keysutil.KeyType_AES256_FF1
+	case "aes256-ff3-1":
+		polReq.KeyType = keysutil.KeyType_AES256_FF3_1
+	case "ml-dsa-44":
+		polReq.KeyType = keysutil.KeyType_ML_DSA_44
+	case "ml-dsa-65":
+		polReq.KeyType = keysutil.KeyType_ML_DSA_65
+	case "ml-dsa-87":
+		polReq.KeyType = keysutil.KeyType_ML_DSA_87
+	case "slh-dsa-sha2-128s":
+		polReq.KeyType = keysutil.KeyType_SLH_DSA_SHA2_128S
+	case "slh-dsa-sha2-128f":
+		polReq.KeyType = keysutil.KeyType_SLH_DSA_SHA2_128F
+	case "slh-dsa-sha2-192s":
+		polReq.KeyType = keysutil.KeyType_SLH_DSA_SHA2_192S
+	case "slh-dsa-sha2-192f":
+		polReq.KeyType = keysutil.KeyType_SLH_DSA_SHA2_192F
+	case "slh-dsa-sha2-256s":
+		polReq.KeyType = keysutil.KeyType_SLH_DSA_SHA2_256S
+	case "slh-dsa-sha2-256f":
+		polReq.KeyType = keysutil.KeyType_SLH_DSA_SHA2_256F
+	case "hybrid-ed25519-ml-dsa-65":
+		polReq.KeyType = keysutil.KeyType_HYBRID_ED25519_ML_DSA_65
+	case "ml-kem-512":
+		polReq.KeyType = keysutil.KeyType_ML_KEM_512
+	case "ml-kem-768":
+		polReq.KeyType = keysutil.KeyType_ML_KEM_768
+	case "ml-kem-1024":
+		polReq.KeyType = keysutil.KeyType_ML_KEM_1024
+	case "hybrid-x25519-ml-kem-768":
+		polReq.KeyType = keysutil.KeyType_HYBRID_X25519_ML_KEM_768
 	default:
 		return logical.ErrorResponse(fmt.Sprintf("unknown key type: %v", keyType)), logical.ErrInvalidRequest
 	}
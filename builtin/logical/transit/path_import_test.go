@@ -956,7 +956,7 @@ func wrapTargetKeyForImport(t *testing.T, wrappingKey *rsa.PublicKey, targetKey
 	var ok bool
 	var err error
 	switch targetKeyType {
-	case "aes128-gcm96", "aes256-gcm96", "chacha20-poly1305", "hmac":
+	case "aes128-gcm96", "aes256-gcm96", "chacha20-poly1305", "hmac", "aes128-cmac", "aes256-cmac", "aes256-ff1", "aes256-ff3-1":
 		preppedTargetKey, ok = targetKey.([]byte)
 		if !ok {
 			t.Fatal("failed to wrap target key for import: symmetric key not provided in byte format")
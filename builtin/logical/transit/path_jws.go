@@ -0,0 +1,410 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package transit
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/errutil"
+	"github.com/hashicorp/vault/sdk/helper/keysutil"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// jwsReservedHeaders are set by Vault and may not be supplied by callers.
+var jwsReservedHeaders = []string{"alg", "kid", "crit"}
+
+func (b *backend) pathJWSSign() *framework.Path {
+	return &framework.Path{
+		Pattern: "jws/sign/" + framework.GenericNameRegex("name"),
+
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixTransit,
+			OperationVerb:   "sign",
+			OperationSuffix: "jws",
+		},
+
+		Fields: map[string]*framework.FieldSchema{
+			"name": {
+				Type:        framework.TypeString,
+				Description: "The key to use",
+			},
+
+			"claims": {
+				Type:        framework.TypeMap,
+				Description: "The JWT claims set to sign, as a JSON object.",
+			},
+
+			"header": {
+				Type: framework.TypeMap,
+				Description: `Additional protected header parameters. The "alg" and
+"kid" parameters are always set by Vault and may not be supplied.`,
+			},
+
+			"algorithm": {
+				Type: framework.TypeString,
+				Description: `The JWS algorithm to use. Defaults to ES256, ES384 or ES512
+for ECDSA keys, EdDSA for ed25519 keys and RS256 for RSA keys. RSA keys
+additionally support RS384, RS512, PS256, PS384 and PS512.`,
+			},
+
+			"key_version": {
+				Type: framework.TypeInt,
+				Description: `The version of the key to use for signing.
+If not set, uses the latest version. Must be greater than or equal
+to the min_encryption_version configured on the key.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathJWSSignWrite,
+		},
+
+		HelpSynopsis:    pathJWSSignHelpSyn,
+		HelpDescription: pathJWSSignHelpDesc,
+	}
+}
+
+func (b *backend) pathJWSVerify() *framework.Path {
+	return &framework.Path{
+		Pattern: "jws/verify/" + framework.GenericNameRegex("name"),
+
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixTransit,
+			OperationVerb:   "verify",
+			OperationSuffix: "jws",
+		},
+
+		Fields: map[string]*framework.FieldSchema{
+			"name": {
+				Type:        framework.TypeString,
+				Description: "The key to use",
+			},
+
+			"token": {
+				Type:        framework.TypeString,
+				Description: "The compact-serialized JWS to verify.",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathJWSVerifyWrite,
+		},
+
+		HelpSynopsis:    pathJWSVerifyHelpSyn,
+		HelpDescription: pathJWSVerifyHelpDesc,
+	}
+}
+
+func (b *backend) pathJWKS() *framework.Path {
+	return &framework.Path{
+		Pattern: "jws/jwks/" + framework.GenericNameRegex("name"),
+
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixTransit,
+			OperationVerb:   "read",
+			OperationSuffix: "jwks",
+		},
+
+		Fields: map[string]*framework.FieldSchema{
+			"name": {
+				Type:        framework.TypeString,
+				Description: "The key to use",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathJWKSRead,
+		},
+
+		HelpSynopsis:    pathJWKSHelpSyn,
+		HelpDescription: pathJWKSHelpDesc,
+	}
+}
+
+func (b *backend) pathJWSSignWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+	ver := d.Get("key_version").(int)
+	alg := d.Get("algorithm").(string)
+
+	claimsRaw, ok := d.GetOk("claims")
+	if !ok {
+		return logical.ErrorResponse("missing claims"), logical.ErrInvalidRequest
+	}
+
+	header := map[string]interface{}{
+		"typ": "JWT",
+	}
+	for k, v := range d.Get("header").(map[string]interface{}) {
+		for _, reserved := range jwsReservedHeaders {
+			if k == reserved {
+				return logical.ErrorResponse("header parameter %q may not be set", k), logical.ErrInvalidRequest
+			}
+		}
+		header[k] = v
+	}
+
+	// Get the policy
+	p, _, err := b.GetPolicy(ctx, keysutil.PolicyRequest{
+		Storage: req.Storage,
+		Name:    name,
+	}, b.GetRandomReader())
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return logical.ErrorResponse("signing key not found"), logical.ErrInvalidRequest
+	}
+	if !b.System().CachingDisabled() {
+		p.Lock(false)
+	}
+	defer p.Unlock()
+
+	if err := b.checkKeyUsageWritable(p); err != nil {
+		return nil, err
+	}
+
+	if alg == "" {
+		alg = p.Type.DefaultJWSAlgorithm()
+		if alg == "" {
+			return logical.ErrorResponse("key type %v does not support JWS", p.Type), logical.ErrInvalidRequest
+		}
+	}
+	if ver == 0 {
+		ver = p.LatestVersion
+	}
+
+	header["alg"] = alg
+	header["kid"] = strconv.Itoa(ver)
+
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return logical.ErrorResponse("failed to encode header: %s", err), logical.ErrInvalidRequest
+	}
+	claimsJSON, err := json.Marshal(claimsRaw)
+	if err != nil {
+		return logical.ErrorResponse("failed to encode claims: %s", err), logical.ErrInvalidRequest
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(headerJSON) + "." + base64.RawURLEncoding.EncodeToString(claimsJSON)
+	sig, err := p.SignJWS(ver, alg, []byte(signingInput))
+	if err != nil {
+		switch err.(type) {
+		case errutil.UserError:
+			return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+		default:
+			return nil, err
+		}
+	}
+	b.recordKeyUsage(ctx, req.Storage, p, ver, keysutil.KeyUsageSign, 1)
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"token":       signingInput + "." + base64.RawURLEncoding.EncodeToString(sig),
+			"key_version": ver,
+		},
+	}, nil
+}
+
+func (b *backend) pathJWSVerifyWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+	token := d.Get("token").(string)
+	if token == "" {
+		return logical.ErrorResponse("missing token"), logical.ErrInvalidRequest
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return logical.ErrorResponse("invalid token: expected three dot-separated parts"), logical.ErrInvalidRequest
+	}
+
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return logical.ErrorResponse("invalid token: unable to decode header: %s", err), logical.ErrInvalidRequest
+	}
+	var header map[string]interface{}
+	if err := json.Unmarshal(headerJSON, &header); err != nil {
+		return logical.ErrorResponse("invalid token: unable to parse header: %s", err), logical.ErrInvalidRequest
+	}
+
+	alg, _ := header["alg"].(string)
+	if alg == "" {
+		return logical.ErrorResponse("invalid token: missing alg header"), logical.ErrInvalidRequest
+	}
+	if _, ok := header["crit"]; ok {
+		return logical.ErrorResponse("invalid token: critical header extensions are not supported"), logical.ErrInvalidRequest
+	}
+	kid, _ := header["kid"].(string)
+	ver, err := strconv.Atoi(kid)
+	if err != nil || ver <= 0 {
+		return logical.ErrorResponse("invalid token: kid is not a key version"), logical.ErrInvalidRequest
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return logical.ErrorResponse("invalid token: unable to decode signature: %s", err), logical.ErrInvalidRequest
+	}
+
+	// Get the policy
+	p, _, err := b.GetPolicy(ctx, keysutil.PolicyRequest{
+		Storage: req.Storage,
+		Name:    name,
+	}, b.GetRandomReader())
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return logical.ErrorResponse("signature verification key not found"), logical.ErrInvalidRequest
+	}
+	if !b.System().CachingDisabled() {
+		p.Lock(false)
+	}
+	defer p.Unlock()
+
+	if err := b.checkKeyUsageWritable(p); err != nil {
+		return nil, err
+	}
+
+	valid, err := p.VerifyJWS(ver, alg, []byte(parts[0]+"."+parts[1]), sig)
+	if err != nil {
+		switch err.(type) {
+		case errutil.UserError:
+			return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+		default:
+			return nil, err
+		}
+	}
+	b.recordKeyUsage(ctx, req.Storage, p, ver, keysutil.KeyUsageVerify, 1)
+
+	resp := &logical.Response{
+		Data: map[string]interface{}{
+			"valid":       valid,
+			"key_version": ver,
+		},
+	}
+	if !valid {
+		return resp, nil
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return logical.ErrorResponse("invalid token: unable to decode payload: %s", err), logical.ErrInvalidRequest
+	}
+	var claims map[string]interface{}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		// Not a JWT; return the raw payload instead.
+		resp.Data["payload"] = base64.StdEncoding.EncodeToString(payload)
+	} else {
+		resp.Data["claims"] = claims
+	}
+	resp.Data["header"] = header
+
+	return resp, nil
+}
+
+func (b *backend) pathJWKSRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	// Get the policy
+	p, _, err := b.GetPolicy(ctx, keysutil.PolicyRequest{
+		Storage: req.Storage,
+		Name:    name,
+	}, b.GetRandomReader())
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return nil, nil
+	}
+	if !b.System().CachingDisabled() {
+		p.Lock(false)
+	}
+	defer p.Unlock()
+
+	// This endpoint is unauthenticated, so keys which are not published are
+	// indistinguishable from missing ones.
+	if !p.PublishJWKS || p.Type.DefaultJWSAlgorithm() == "" {
+		return nil, nil
+	}
+
+	minVersion := p.MinDecryptionVersion
+	if minVersion < 1 {
+		minVersion = 1
+	}
+
+	var versions []int
+	for k := range p.Keys {
+		ver, err := strconv.Atoi(k)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key version %q: %w", k, err)
+		}
+		if ver >= minVersion {
+			versions = append(versions, ver)
+		}
+	}
+	sort.Ints(versions)
+
+	keys := make([]interface{}, 0, len(versions))
+	for _, ver := range versions {
+		jwk, err := p.PublicJWK(ver)
+		if err != nil {
+			switch err.(type) {
+			case errutil.UserError:
+				return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+			default:
+				return nil, err
+			}
+		}
+		keys = append(keys, jwk)
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"keys": keys,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Serve the key set as a standard JWKS document, so relying parties can
+	// fetch it directly.
+	return &logical.Response{
+		Data: map[string]interface{}{
+			logical.HTTPStatusCode:  200,
+			logical.HTTPRawBody:     body,
+			logical.HTTPContentType: "application/jwk-set+json",
+		},
+	}, nil
+}
+
+const pathJWSSignHelpSyn = `Sign a JWT claims set as a compact JWS using the named key`
+
+const pathJWSSignHelpDesc = `
+Signs the given claims with the named key and returns a compact-serialized
+JWS. The protected header's "alg" is derived from the key type (or the
+algorithm parameter) and its "kid" is set to the key version used.
+`
+
+const pathJWSVerifyHelpSyn = `Verify a compact JWS using the named key`
+
+const pathJWSVerifyHelpDesc = `
+Verifies a compact-serialized JWS produced by the jws/sign endpoint, using
+the key version named by its "kid" header. Only the signature is checked;
+time-based claims such as "exp" and "nbf" are not validated.
+`
+
+const pathJWKSHelpSyn = `Read the JSON Web Key Set for the named key`
+
+const pathJWKSHelpDesc = `
+Returns the public keys of all usable versions of the named key as a JSON
+Web Key Set (RFC 7517), with each key's "kid" set to its key version. The
+key set is served as a raw JWKS document and does not require
+authentication, so it can be published to relying parties. Only keys with
+"publish_jwks" enabled in their configuration are served.
+`
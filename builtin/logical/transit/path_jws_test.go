@@ -0,0 +1,243 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package transit
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/go-jose/go-jose/v3"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func TestTransit_JWS(t *testing.T) {
+	b, storage := createBackendWithSysView(t)
+
+	cases := []struct {
+		keyType   string
+		algorithm string
+	}{
+		{"ecdsa-p256", ""},
+		{"ecdsa-p384", ""},
+		{"ecdsa-p521", ""},
+		{"ed25519", ""},
+		{"rsa-2048", ""},
+		{"rsa-2048", "PS384"},
+		{"rsa-3072", "RS512"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.keyType+tc.algorithm, func(t *testing.T) {
+			keyName := "jws-" + tc.keyType + strings.ToLower(tc.algorithm)
+			resp, err := b.HandleRequest(context.Background(), &logical.Request{
+				Storage:   storage,
+				Operation: logical.UpdateOperation,
+				Path:      "keys/" + keyName,
+				Data: map[string]interface{}{
+					"type": tc.keyType,
+				},
+			})
+			if err != nil || (resp != nil && resp.IsError()) {
+				t.Fatalf("failed to create key: resp: %#v, err: %v", resp, err)
+			}
+
+			// Rotate so that kid selection is exercised
+			_, err = b.HandleRequest(context.Background(), &logical.Request{
+				Storage:   storage,
+				Operation: logical.UpdateOperation,
+				Path:      "keys/" + keyName + "/rotate",
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			data := map[string]interface{}{
+				"claims": map[string]interface{}{
+					"sub": "vault",
+					"aud": "tests",
+				},
+				"header":      map[string]interface{}{"cty": "example"},
+				"key_version": 1,
+			}
+			if tc.algorithm != "" {
+				data["algorithm"] = tc.algorithm
+			}
+			resp, err = b.HandleRequest(context.Background(), &logical.Request{
+				Storage:   storage,
+				Operation: logical.UpdateOperation,
+				Path:      "jws/sign/" + keyName,
+				Data:      data,
+			})
+			if err != nil || resp == nil || resp.IsError() {
+				t.Fatalf("failed to sign: resp: %#v, err: %v", resp, err)
+			}
+			token := resp.Data["token"].(string)
+
+			// Verify through Vault
+			resp, err = b.HandleRequest(context.Background(), &logical.Request{
+				Storage:   storage,
+				Operation: logical.UpdateOperation,
+				Path:      "jws/verify/" + keyName,
+				Data: map[string]interface{}{
+					"token": token,
+				},
+			})
+			if err != nil || resp == nil || resp.IsError() {
+				t.Fatalf("failed to verify: resp: %#v, err: %v", resp, err)
+			}
+			if !resp.Data["valid"].(bool) {
+				t.Fatalf("token did not verify")
+			}
+			if resp.Data["claims"].(map[string]interface{})["sub"] != "vault" {
+				t.Fatalf("unexpected claims: %#v", resp.Data["claims"])
+			}
+			if resp.Data["header"].(map[string]interface{})["kid"] != "1" {
+				t.Fatalf("unexpected header: %#v", resp.Data["header"])
+			}
+
+			// The key set is only served once published.
+			resp, err = b.HandleRequest(context.Background(), &logical.Request{
+				Storage:   storage,
+				Operation: logical.ReadOperation,
+				Path:      "jws/jwks/" + keyName,
+			})
+			if err != nil || resp != nil {
+				t.Fatalf("expected no JWKS for unpublished key: resp: %#v, err: %v", resp, err)
+			}
+			resp, err = b.HandleRequest(context.Background(), &logical.Request{
+				Storage:   storage,
+				Operation: logical.UpdateOperation,
+				Path:      "keys/" + keyName + "/config",
+				Data: map[string]interface{}{
+					"publish_jwks": true,
+				},
+			})
+			if err != nil || resp == nil || resp.IsError() || resp.Data["publish_jwks"] != true {
+				t.Fatalf("failed to publish JWKS: resp: %#v, err: %v", resp, err)
+			}
+
+			// Verify independently against the published JWKS
+			resp, err = b.HandleRequest(context.Background(), &logical.Request{
+				Storage:   storage,
+				Operation: logical.ReadOperation,
+				Path:      "jws/jwks/" + keyName,
+			})
+			if err != nil || resp == nil || resp.IsError() {
+				t.Fatalf("failed to read JWKS: resp: %#v, err: %v", resp, err)
+			}
+			if resp.Data[logical.HTTPContentType] != "application/jwk-set+json" {
+				t.Fatalf("unexpected JWKS content type: %#v", resp.Data)
+			}
+			raw := resp.Data[logical.HTTPRawBody].([]byte)
+			var jwks jose.JSONWebKeySet
+			if err := json.Unmarshal(raw, &jwks); err != nil {
+				t.Fatalf("failed to parse JWKS: %v", err)
+			}
+			if len(jwks.Keys) != 2 {
+				t.Fatalf("expected 2 keys in JWKS, got %d", len(jwks.Keys))
+			}
+			keys := jwks.Key("1")
+			if len(keys) != 1 {
+				t.Fatalf("expected kid 1 in JWKS: %s", raw)
+			}
+
+			parsed, err := jose.ParseSigned(token)
+			if err != nil {
+				t.Fatalf("failed to parse token: %v", err)
+			}
+			if _, err := parsed.Verify(keys[0]); err != nil {
+				t.Fatalf("failed to verify token with JWKS: %v", err)
+			}
+			if _, err := parsed.Verify(jwks.Key("2")[0]); err == nil {
+				t.Fatalf("token verified against the wrong key version")
+			}
+
+			// A tampered payload must not verify
+			parts := strings.Split(token, ".")
+			parts[1] = parts[1][:len(parts[1])-2] + "AA"
+			resp, err = b.HandleRequest(context.Background(), &logical.Request{
+				Storage:   storage,
+				Operation: logical.UpdateOperation,
+				Path:      "jws/verify/" + keyName,
+				Data: map[string]interface{}{
+					"token": strings.Join(parts, "."),
+				},
+			})
+			if err != nil || resp == nil || resp.IsError() {
+				t.Fatalf("failed to verify: resp: %#v, err: %v", resp, err)
+			}
+			if resp.Data["valid"].(bool) {
+				t.Fatalf("tampered token verified")
+			}
+		})
+	}
+
+	t.Run("errors", func(t *testing.T) {
+		_, err := b.HandleRequest(context.Background(), &logical.Request{
+			Storage:   storage,
+			Operation: logical.UpdateOperation,
+			Path:      "keys/jws-aes",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, tc := range []struct {
+			path string
+			data map[string]interface{}
+		}{
+			{"jws/sign/jws-aes", map[string]interface{}{"claims": map[string]interface{}{"sub": "x"}}},
+			{"jws/sign/jws-ecdsa-p256", map[string]interface{}{"claims": map[string]interface{}{"sub": "x"}, "algorithm": "RS256"}},
+			{"jws/sign/jws-ecdsa-p256", map[string]interface{}{"claims": map[string]interface{}{"sub": "x"}, "algorithm": "none"}},
+			{"jws/sign/jws-ecdsa-p256", map[string]interface{}{"claims": map[string]interface{}{"sub": "x"}, "header": map[string]interface{}{"kid": "9"}}},
+			{"jws/sign/jws-ecdsa-p256", map[string]interface{}{}},
+			{"jws/verify/jws-ecdsa-p256", map[string]interface{}{"token": "not-a-token"}},
+			{"jws/verify/jws-ecdsa-p256", map[string]interface{}{"token": "eyJhbGciOiJub25lIiwia2lkIjoiMSJ9.e30."}},
+		} {
+			resp, err := b.HandleRequest(context.Background(), &logical.Request{
+				Storage:   storage,
+				Operation: logical.UpdateOperation,
+				Path:      tc.path,
+				Data:      tc.data,
+			})
+			if err == nil || resp == nil || !resp.IsError() {
+				t.Fatalf("expected error for %s with %#v: resp: %#v, err: %v", tc.path, tc.data, resp, err)
+			}
+		}
+
+		// Only keys supporting JWS can be published.
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Storage:   storage,
+			Operation: logical.UpdateOperation,
+			Path:      "keys/jws-aes/config",
+			Data:      map[string]interface{}{"publish_jwks": true},
+		})
+		if err != nil || resp == nil || !resp.IsError() {
+			t.Fatalf("expected error publishing JWKS of AES key: resp: %#v, err: %v", resp, err)
+		}
+
+		// Missing, non-JWS and unpublished keys are all reported alike.
+		_, err = b.HandleRequest(context.Background(), &logical.Request{
+			Storage:   storage,
+			Operation: logical.UpdateOperation,
+			Path:      "keys/jws-unpublished",
+			Data:      map[string]interface{}{"type": "ed25519"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range []string{"jws-missing", "jws-aes", "jws-unpublished"} {
+			resp, err := b.HandleRequest(context.Background(), &logical.Request{
+				Storage:   storage,
+				Operation: logical.ReadOperation,
+				Path:      "jws/jwks/" + name,
+			})
+			if err != nil || resp != nil {
+				t.Fatalf("expected no JWKS for %s: resp: %#v, err: %v", name, resp, err)
+			}
+		}
+	})
+}
@@ -59,7 +59,12 @@ func (b *backend) pathKeys() *framework.Path {
 				Description: `
 The type of key to create. Currently, "aes128-gcm96" (symmetric), "aes256-gcm96" (symmetric), "ecdsa-p256"
 (asymmetric), "ecdsa-p384" (asymmetric), "ecdsa-p521" (asymmetric), "ed25519" (asymmetric), "rsa-2048" (asymmetric), "rsa-3072"
-(asymmetric), "rsa-4096" (asymmetric) are supported.  Defaults to "aes256-gcm96".
+(asymmetric), "rsa-4096" (asymmetric), "x25519" (asymmetric, key agreement only), "hmac", "aes128-cmac",
+"aes256-cmac", "ml-dsa-44", "ml-dsa-65", "ml-dsa-87" (asymmetric, post-quantum), "slh-dsa-sha2-128s",
+"slh-dsa-sha2-128f", "slh-dsa-sha2-192s", "slh-dsa-sha2-192f", "slh-dsa-sha2-256s", "slh-dsa-sha2-256f"
+(asymmetric, post-quantum), "hybrid-ed25519-ml-dsa-65" (asymmetric, composite), "ml-kem-512", "ml-kem-768",
+"ml-kem-1024" (asymmetric, post-quantum key encapsulation), "hybrid-x25519-ml-kem-768" (asymmetric, composite key
+encapsulation), "aes256-ff1" and "aes256-ff3-1" (symmetric, format preserving) are supported.  Defaults to "aes256-gcm96".
 `,
 			},
 
@@ -220,6 +225,44 @@ func (b *backend) pathPolicyWrite(ctx context.Context, req *logical.Request, d *
 		polReq.KeyType = keysutil.KeyType_RSA4096
 	case "hmac":
 		polReq.KeyType = keysutil.KeyType_HMAC
+	case "x25519":
+		polReq.KeyType = keysutil.KeyType_X25519
+	case "aes128-cmac":
+		polReq.KeyType = keysutil.KeyType_AES128_CMAC
+	case "aes256-cmac":
+		polReq.KeyType = keysutil.KeyType_AES256_CMAC
+	case "aes256-ff1":
+		polReq.KeyType = keysutil.KeyType_AES256_FF1
+	case "aes256-ff3-1":
+		polReq.KeyType = keysutil.KeyType_AES256_FF3_1
+	case "ml-dsa-44":
+		polReq.KeyType = keysutil.KeyType_ML_DSA_44
+	case "ml-dsa-65":
+		polReq.KeyType = keysutil.KeyType_ML_DSA_65
+	case "ml-dsa-87":
+		polReq.KeyType = keysutil.KeyType_ML_DSA_87
+	case "slh-dsa-sha2-128s":
+		polReq.KeyType = keysutil.KeyType_SLH_DSA_SHA2_128S
+	case "slh-dsa-sha2-128f":
+		polReq.KeyType = keysutil.KeyType_SLH_DSA_SHA2_128F
+	case "slh-dsa-sha2-192s":
+		polReq.KeyType = keysutil.KeyType_SLH_DSA_SHA2_192S
+	case "slh-dsa-sha2-192f":
+		polReq.KeyType = keysutil.KeyType_SLH_DSA_SHA2_192F
+	case "slh-dsa-sha2-256s":
+		polReq.KeyType = keysutil.KeyType_SLH_DSA_SHA2_256S
+	case "slh-dsa-sha2-256f":
+		polReq.KeyType = keysutil.KeyType_SLH_DSA_SHA2_256F
+	case "hybrid-ed25519-ml-dsa-65":
+		polReq.KeyType = keysutil.KeyType_HYBRID_ED25519_ML_DSA_65
+	case "ml-kem-512":
+		polReq.KeyType = keysutil.KeyType_ML_KEM_512
+	case "ml-kem-768":
+		polReq.KeyType = keysutil.KeyType_ML_KEM_768
+	case "ml-kem-1024":
+		polReq.KeyType = keysutil.KeyType_ML_KEM_1024
+	case "hybrid-x25519-ml-kem-768":
+		polReq.KeyType = keysutil.KeyType_HYBRID_X25519_ML_KEM_768
 	case "managed_key":
 		polReq.KeyType = keysutil.KeyType_MANAGED_KEY
 	default:
@@ -300,7 +343,22 @@ func (b *backend) pathPolicyRead(ctx context.Context, req *logical.Request, d *f
 		}
 	}
 
-	return b.formatKeyPolicy(p, context)
+	resp, err := b.formatKeyPolicy(p, context)
+	if err != nil {
+		return nil, err
+	}
+
+	usage, err := b.lm.KeyUsage(ctx, req.Storage, p)
+	if err != nil {
+		return nil, err
+	}
+	formattedUsage := make(map[string]interface{}, len(usage))
+	for ver, versionUsage := range usage {
+		formattedUsage[ver] = formatKeyVersionUsage(versionUsage)
+	}
+	resp.Data["usage"] = formattedUsage
+
+	return resp, nil
 }
 
 func (b *backend) formatKeyPolicy(p *keysutil.Policy, context []byte) (*logical.Response, error) {
@@ -321,8 +379,12 @@ func (b *backend) formatKeyPolicy(p *keysutil.Policy, context []byte) (*logical.
 			"supports_decryption":    p.Type.DecryptionSupported(),
 			"supports_signing":       p.Type.SigningSupported(),
 			"supports_derivation":    p.Type.DerivationSupported(),
+			"supports_key_agreement": p.Type.KeyAgreementSupported(),
 			"auto_rotate_period":     int64(p.AutoRotatePeriod.Seconds()),
 			"imported_key":           p.Imported,
+
+			"auto_rotate_after_operations": p.AutoRotateAfterOperations,
+			"publish_jwks":                 p.PublishJWKS,
 		},
 	}
 	if p.KeySize != 0 {
@@ -361,14 +423,20 @@ func (b *backend) formatKeyPolicy(p *keysutil.Policy, context []byte) (*logical.
 	}
 
 	switch p.Type {
-	case keysutil.KeyType_AES128_GCM96, keysutil.KeyType_AES256_GCM96, keysutil.KeyType_ChaCha20_Poly1305:
+	case keysutil.KeyType_AES128_GCM96, keysutil.KeyType_AES256_GCM96, keysutil.KeyType_ChaCha20_Poly1305, keysutil.KeyType_AES128_CMAC, keysutil.KeyType_AES256_CMAC,
+		keysutil.KeyType_AES256_FF1, keysutil.KeyType_AES256_FF3_1:
 		retKeys := map[string]int64{}
 		for k, v := range p.Keys {
 			retKeys[k] = v.DeprecatedCreationTime
 		}
 		resp.Data["keys"] = retKeys
 
-	case keysutil.KeyType_ECDSA_P256, keysutil.KeyType_ECDSA_P384, keysutil.KeyType_ECDSA_P521, keysutil.KeyType_ED25519, keysutil.KeyType_RSA2048, keysutil.KeyType_RSA3072, keysutil.KeyType_RSA4096:
+	case keysutil.KeyType_ECDSA_P256, keysutil.KeyType_ECDSA_P384, keysutil.KeyType_ECDSA_P521, keysutil.KeyType_ED25519, keysutil.KeyType_RSA2048, keysutil.KeyType_RSA3072, keysutil.KeyType_RSA4096, keysutil.KeyType_X25519,
+		keysutil.KeyType_ML_DSA_44, keysutil.KeyType_ML_DSA_65, keysutil.KeyType_ML_DSA_87,
+		keysutil.KeyType_SLH_DSA_SHA2_128S, keysutil.KeyType_SLH_DSA_SHA2_128F, keysutil.KeyType_SLH_DSA_SHA2_192S,
+		keysutil.KeyType_SLH_DSA_SHA2_192F, keysutil.KeyType_SLH_DSA_SHA2_256S, keysutil.KeyType_SLH_DSA_SHA2_256F,
+		keysutil.KeyType_HYBRID_ED25519_ML_DSA_65,
+		keysutil.KeyType_ML_KEM_512, keysutil.KeyType_ML_KEM_768, keysutil.KeyType_ML_KEM_1024, keysutil.KeyType_HYBRID_X25519_ML_KEM_768:
 		retKeys := map[string]map[string]interface{}{}
 		for k, v := range p.Keys {
 			key := asymKey{
@@ -416,6 +484,14 @@ func (b *backend) formatKeyPolicy(p *keysutil.Policy, context []byte) (*logical.
 					}
 				}
 				key.Name = "ed25519"
+			case keysutil.KeyType_X25519:
+				key.Name = "x25519"
+			case keysutil.KeyType_ML_DSA_44, keysutil.KeyType_ML_DSA_65, keysutil.KeyType_ML_DSA_87,
+				keysutil.KeyType_SLH_DSA_SHA2_128S, keysutil.KeyType_SLH_DSA_SHA2_128F, keysutil.KeyType_SLH_DSA_SHA2_192S,
+				keysutil.KeyType_SLH_DSA_SHA2_192F, keysutil.KeyType_SLH_DSA_SHA2_256S, keysutil.KeyType_SLH_DSA_SHA2_256F,
+				keysutil.KeyType_HYBRID_ED25519_ML_DSA_65,
+				keysutil.KeyType_ML_KEM_512, keysutil.KeyType_ML_KEM_768, keysutil.KeyType_ML_KEM_1024, keysutil.KeyType_HYBRID_X25519_ML_KEM_768:
+				key.Name = p.Type.String()
 			case keysutil.KeyType_RSA2048, keysutil.KeyType_RSA3072, keysutil.KeyType_RSA4096:
 				key.Name = "rsa-2048"
 				if p.Type == keysutil.KeyType_RSA3072 {
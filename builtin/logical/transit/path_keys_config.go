@@ -65,6 +65,20 @@ the latest version of the key is allowed.`,
 being automatically rotated. A value of 0
 disables automatic rotation for the key.`,
 			},
+
+			"auto_rotate_after_operations": {
+				Type: framework.TypeInt,
+				Description: `Number of encryption and signing operations
+performed with the latest key version after which the key should be
+automatically rotated. A value of 0 disables usage-based rotation.`,
+			},
+
+			"publish_jwks": {
+				Type: framework.TypeBool,
+				Description: `Whether the public keys of the key are published
+without authentication at jws/jwks/:name. Only valid for keys which
+support JWS. Defaults to false.`,
+			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -224,6 +238,39 @@ func (b *backend) pathKeysConfigWrite(ctx context.Context, req *logical.Request,
 		}
 	}
 
+	autoRotateAfterOperationsRaw, ok, err := d.GetOkErr("auto_rotate_after_operations")
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		autoRotateAfterOperations := autoRotateAfterOperationsRaw.(int)
+		if autoRotateAfterOperations < 0 {
+			return logical.ErrorResponse("auto rotate after operations must be 0 to disable or a positive number"), nil
+		}
+
+		if uint64(autoRotateAfterOperations) != p.AutoRotateAfterOperations {
+			p.AutoRotateAfterOperations = uint64(autoRotateAfterOperations)
+			persistNeeded = true
+		}
+
+		if p.Type == keysutil.KeyType_MANAGED_KEY && autoRotateAfterOperations != 0 {
+			return logical.ErrorResponse("Auto rotation can not be set for managed keys"), nil
+		}
+	}
+
+	publishJWKSRaw, ok := d.GetOk("publish_jwks")
+	if ok {
+		publishJWKS := publishJWKSRaw.(bool)
+		if publishJWKS && p.Type.DefaultJWSAlgorithm() == "" {
+			return logical.ErrorResponse("key type %v does not support JWS", p.Type), nil
+		}
+
+		if publishJWKS != p.PublishJWKS {
+			p.PublishJWKS = publishJWKS
+			persistNeeded = true
+		}
+	}
+
 	if !persistNeeded {
 		resp, err := b.formatKeyPolicy(p, nil)
 		if err != nil {
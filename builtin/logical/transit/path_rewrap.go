@@ -150,7 +150,13 @@ func (b *backend) pathRewrapWrite(ctx context.Context, req *logical.Request, d *
 	}
 	defer p.Unlock()
 
+	if err := b.checkKeyUsageWritable(p); err != nil {
+		return nil, err
+	}
+
 	warnAboutNonceUsage := false
+	versionUsage := make(map[int]int)
+	decryptUsage := make(map[int]int)
 	for i, item := range batchInputItems {
 		if batchResponseItems[i].Error != "" {
 			continue
@@ -172,11 +178,14 @@ func (b *backend) pathRewrapWrite(ctx context.Context, req *logical.Request, d *
 			}
 		}
 
+		decryptedVersion, _ := p.CiphertextVersion(item.Ciphertext)
+		decryptUsage[decryptedVersion]++
+
 		if !warnAboutNonceUsage && shouldWarnAboutNonceUsage(p, item.DecodedNonce) {
 			warnAboutNonceUsage = true
 		}
 
-		ciphertext, err := p.Encrypt(item.KeyVersion, item.DecodedContext, item.DecodedNonce, plaintext)
+		ciphertext, err := p.EncryptWithFactory(item.KeyVersion, item.DecodedContext, item.DecodedNonce, plaintext, RandomReaderFactory{b.GetRandomReader()})
 		if err != nil {
 			switch err.(type) {
 			case errutil.UserError:
@@ -200,6 +209,14 @@ func (b *backend) pathRewrapWrite(ctx context.Context, req *logical.Request, d *
 
 		batchResponseItems[i].Ciphertext = ciphertext
 		batchResponseItems[i].KeyVersion = keyVersion
+		versionUsage[keyVersion]++
+	}
+
+	for ver, n := range versionUsage {
+		b.recordKeyUsage(ctx, req.Storage, p, ver, keysutil.KeyUsageEncrypt, n)
+	}
+	for ver, n := range decryptUsage {
+		b.recordKeyUsage(ctx, req.Storage, p, ver, keysutil.KeyUsageDecrypt, n)
 	}
 
 	resp := &logical.Response{}
@@ -216,6 +216,11 @@ derivation is enabled; currently only available with ed25519 keys.`,
 				Description: "The HMAC, including vault header/key version",
 			},
 
+			"cmac": {
+				Type:        framework.TypeString,
+				Description: "The CMAC, including vault header/key version",
+			},
+
 			"input": {
 				Type:        framework.TypeString,
 				Description: "The base64-encoded input data to verify",
@@ -279,7 +284,7 @@ Options are 'auto' (the default used by Golang, causing the salt to be as large
 			"batch_input": {
 				Type: framework.TypeSlice,
 				Description: `Specifies a list of items for processing. When this parameter is set,
-any supplied  'input', 'hmac' or 'signature' parameters will be ignored. Responses are returned in the
+any supplied  'input', 'hmac', 'cmac' or 'signature' parameters will be ignored. Responses are returned in the
 'batch_results' array component of the 'data' element of the response. Any batch output will
 preserve the order of the batch input`,
 			},
@@ -369,6 +374,10 @@ func (b *backend) pathSignWrite(ctx context.Context, req *logical.Request, d *fr
 	}
 	defer p.Unlock()
 
+	if err := b.checkKeyUsageWritable(p); err != nil {
+		return nil, err
+	}
+
 	if !p.Type.SigningSupported() {
 		return logical.ErrorResponse(fmt.Sprintf("key type %v does not support signing", p.Type)), logical.ErrInvalidRequest
 	}
@@ -401,6 +410,7 @@ func (b *backend) pathSignWrite(ctx context.Context, req *logical.Request, d *fr
 	}
 
 	response := make([]batchResponseSignItem, len(batchInputItems))
+	signatures := 0
 	for i, item := range batchInputItems {
 
 		rawInput, ok := item["input"]
@@ -473,9 +483,12 @@ func (b *backend) pathSignWrite(ctx context.Context, req *logical.Request, d *fr
 			response[i].Signature = sig.Signature
 			response[i].PublicKey = sig.PublicKey
 			response[i].KeyVersion = keyVersion
+			signatures++
 		}
 	}
 
+	b.recordKeyUsage(ctx, req.Storage, p, ver, keysutil.KeyUsageSign, signatures)
+
 	// Generate the response
 	resp := &logical.Response{}
 	if batchInputRaw != nil {
@@ -534,46 +547,70 @@ func (b *backend) pathVerifyWrite(ctx context.Context, req *logical.Request, d *
 		if hmac, ok := d.GetOk("hmac"); ok {
 			batchInputItems[0]["hmac"] = hmac.(string)
 		}
+		if cmac, ok := d.GetOk("cmac"); ok {
+			batchInputItems[0]["cmac"] = cmac.(string)
+		}
 		batchInputItems[0]["context"] = d.Get("context").(string)
 	}
 
-	// For simplicity, 'signature' and 'hmac' cannot be mixed across batch_input elements.
-	// If one batch_input item is 'signature', they all must be 'signature'.
-	// If one batch_input item is 'hmac', they all must be 'hmac'.
+	// For simplicity, 'signature', 'hmac' and 'cmac' cannot be mixed across
+	// batch_input elements. If one batch_input item is 'signature', they all
+	// must be 'signature'; likewise for 'hmac' and 'cmac'.
 	sigFound := false
 	hmacFound := false
+	cmacFound := false
 	missing := false
 	for _, v := range batchInputItems {
 		if _, ok := v["signature"]; ok {
 			sigFound = true
 		} else if _, ok := v["hmac"]; ok {
 			hmacFound = true
+		} else if _, ok := v["cmac"]; ok {
+			cmacFound = true
 		} else {
 			missing = true
 		}
 	}
+	found := 0
+	for _, f := range []bool{sigFound, hmacFound, cmacFound} {
+		if f {
+			found++
+		}
+	}
 
 	switch {
 	case batchInputRaw == nil && sigFound && hmacFound:
 		return logical.ErrorResponse("provide one of 'signature' or 'hmac'"), logical.ErrInvalidRequest
 
-	case batchInputRaw == nil && !sigFound && !hmacFound:
+	case batchInputRaw == nil && found > 1:
+		return logical.ErrorResponse("provide one of 'signature', 'hmac' or 'cmac'"), logical.ErrInvalidRequest
+
+	case batchInputRaw == nil && found == 0:
 		return logical.ErrorResponse("neither a 'signature' nor an 'hmac' were given to verify"), logical.ErrInvalidRequest
 
 	case sigFound && hmacFound:
 		return logical.ErrorResponse("elements of batch_input must all provide 'signature' or all provide 'hmac'"), logical.ErrInvalidRequest
 
+	case found > 1:
+		return logical.ErrorResponse("elements of batch_input must all provide 'signature', all provide 'hmac' or all provide 'cmac'"), logical.ErrInvalidRequest
+
 	case missing && sigFound:
 		return logical.ErrorResponse("some elements of batch_input are missing 'signature'"), logical.ErrInvalidRequest
 
 	case missing && hmacFound:
 		return logical.ErrorResponse("some elements of batch_input are missing 'hmac'"), logical.ErrInvalidRequest
 
+	case missing && cmacFound:
+		return logical.ErrorResponse("some elements of batch_input are missing 'cmac'"), logical.ErrInvalidRequest
+
 	case missing:
 		return logical.ErrorResponse("no batch_input elements have 'signature' or 'hmac'"), logical.ErrInvalidRequest
 
 	case hmacFound:
 		return b.pathHMACVerify(ctx, req, d)
+
+	case cmacFound:
+		return b.pathCMACVerify(ctx, req, d)
 	}
 
 	name := d.Get("name").(string)
@@ -622,6 +659,10 @@ func (b *backend) pathVerifyWrite(ctx context.Context, req *logical.Request, d *
 	}
 	defer p.Unlock()
 
+	if err := b.checkKeyUsageWritable(p); err != nil {
+		return nil, err
+	}
+
 	if !p.Type.SigningSupported() {
 		return logical.ErrorResponse(fmt.Sprintf("key type %v does not support verification", p.Type)), logical.ErrInvalidRequest
 	}
@@ -634,6 +675,7 @@ func (b *backend) pathVerifyWrite(ctx context.Context, req *logical.Request, d *
 	}
 
 	response := make([]batchResponseVerifyItem, len(batchInputItems))
+	versionUsage := make(map[int]int)
 
 	for i, item := range batchInputItems {
 
@@ -712,9 +754,16 @@ func (b *backend) pathVerifyWrite(ctx context.Context, req *logical.Request, d *
 			}
 		} else {
 			response[i].Valid = valid
+			if ver, err := p.CiphertextVersion(sig); err == nil {
+				versionUsage[ver]++
+			}
 		}
 	}
 
+	for ver, n := range versionUsage {
+		b.recordKeyUsage(ctx, req.Storage, p, ver, keysutil.KeyUsageVerify, n)
+	}
+
 	// Generate the response
 	resp := &logical.Response{}
 	if batchInputRaw != nil {
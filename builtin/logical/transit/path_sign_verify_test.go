@@ -977,3 +977,120 @@ func testTransit_SignVerify_RSA_PSS(t *testing.T, bits int) {
 		}
 	}
 }
+
+func TestTransit_SignVerify_PostQuantum(t *testing.T) {
+	b, storage := createBackendWithSysView(t)
+
+	// The slower SLH-DSA "s" parameter sets share all code paths with the
+	// "f" sets, so only the latter are exercised here.
+	keyTypes := []string{"ml-dsa-44", "ml-dsa-65", "ml-dsa-87", "slh-dsa-sha2-128f", "hybrid-ed25519-ml-dsa-65"}
+	input := base64.StdEncoding.EncodeToString([]byte("the quick brown fox"))
+
+	doReq := func(t *testing.T, op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Storage:   storage,
+			Operation: op,
+			Path:      path,
+			Data:      data,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("request to %s failed: resp: %#v, err: %v", path, resp, err)
+		}
+		return resp
+	}
+
+	verify := func(t *testing.T, keyName, sig, in string) bool {
+		t.Helper()
+		resp := doReq(t, logical.UpdateOperation, "verify/"+keyName, map[string]interface{}{
+			"input":     in,
+			"signature": sig,
+		})
+		return resp.Data["valid"].(bool)
+	}
+
+	for _, keyType := range keyTypes {
+		t.Run(keyType, func(t *testing.T) {
+			keyName := "pq-" + keyType
+			doReq(t, logical.UpdateOperation, "keys/"+keyName, map[string]interface{}{
+				"type":       keyType,
+				"exportable": true,
+			})
+
+			resp := doReq(t, logical.ReadOperation, "keys/"+keyName, nil)
+			if resp.Data["type"] != keyType || !resp.Data["supports_signing"].(bool) {
+				t.Fatalf("unexpected key read response: %#v", resp.Data)
+			}
+
+			resp = doReq(t, logical.UpdateOperation, "sign/"+keyName, map[string]interface{}{
+				"input": input,
+			})
+			sig := resp.Data["signature"].(string)
+
+			if !verify(t, keyName, sig, input) {
+				t.Fatalf("signature did not verify")
+			}
+			if verify(t, keyName, sig, base64.StdEncoding.EncodeToString([]byte("tampered"))) {
+				t.Fatalf("signature verified over the wrong input")
+			}
+
+			// Export the public key and seed, then import each into new
+			// keys and check they verify the same signature.
+			resp = doReq(t, logical.ReadOperation, "export/public-key/"+keyName+"/1", nil)
+			publicKey := resp.Data["keys"].(map[string]string)["1"]
+			resp = doReq(t, logical.ReadOperation, "export/signing-key/"+keyName+"/1", nil)
+			seed, err := base64.StdEncoding.DecodeString(resp.Data["keys"].(map[string]string)["1"])
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if keyType != "hybrid-ed25519-ml-dsa-65" {
+				doReq(t, logical.UpdateOperation, "keys/"+keyName+"-pub/import", map[string]interface{}{
+					"type":       keyType,
+					"public_key": publicKey,
+				})
+				if !verify(t, keyName+"-pub", sig, input) {
+					t.Fatalf("signature did not verify with imported public key")
+				}
+				_, err = b.HandleRequest(context.Background(), &logical.Request{
+					Storage:   storage,
+					Operation: logical.UpdateOperation,
+					Path:      "sign/" + keyName + "-pub",
+					Data:      map[string]interface{}{"input": input},
+				})
+				if err == nil {
+					t.Fatalf("expected signing with a public-only key to fail")
+				}
+			}
+
+			wrappingKey, err := b.getWrappingKey(context.Background(), storage)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pubWrappingKey := &wrappingKey.Keys[strconv.Itoa(wrappingKey.LatestVersion)].RSAKey.PublicKey
+			doReq(t, logical.UpdateOperation, "keys/"+keyName+"-priv/import", map[string]interface{}{
+				"type":       keyType,
+				"ciphertext": wrapTargetPKCS8ForImport(t, pubWrappingKey, seed, "SHA256"),
+			})
+			if !verify(t, keyName+"-priv", sig, input) {
+				t.Fatalf("signature did not verify with imported private key")
+			}
+			resp = doReq(t, logical.UpdateOperation, "sign/"+keyName+"-priv", map[string]interface{}{
+				"input": input,
+			})
+			if !verify(t, keyName, resp.Data["signature"].(string), input) {
+				t.Fatalf("signature from imported key did not verify with original key")
+			}
+
+			// CSRs are not supported for post-quantum keys.
+			resp, err = b.HandleRequest(context.Background(), &logical.Request{
+				Storage:   storage,
+				Operation: logical.UpdateOperation,
+				Path:      "keys/" + keyName + "/csr",
+			})
+			if err == nil || resp == nil || !resp.IsError() {
+				t.Fatalf("expected CSR creation to fail: resp: %#v, err: %v", resp, err)
+			}
+		})
+	}
+}
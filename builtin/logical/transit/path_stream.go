@@ -0,0 +1,331 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package transit
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"math"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/errutil"
+	"github.com/hashicorp/vault/sdk/helper/keysutil"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func streamFields() map[string]*framework.FieldSchema {
+	return map[string]*framework.FieldSchema{
+		"name": {
+			Type:        framework.TypeString,
+			Description: "Name of the key",
+		},
+
+		"context": {
+			Type:        framework.TypeString,
+			Description: "Base64 encoded context for key derivation. Required if key derivation is enabled",
+		},
+
+		"segments": {
+			Type: framework.TypeStringSlice,
+			Description: `List of base64 encoded segments to process, in order,
+starting at segment_index.`,
+		},
+
+		"segment_index": {
+			Type:        framework.TypeInt,
+			Description: `Index within the stream of the first entry in segments.`,
+		},
+
+		"final": {
+			Type: framework.TypeBool,
+			Description: `Whether the last entry in segments is the final segment
+of the stream.`,
+		},
+
+		"associated_data": {
+			Type: framework.TypeString,
+			Description: `Base64 encoded associated data which is authenticated
+(but not encrypted) with every segment.`,
+		},
+	}
+}
+
+func (b *backend) pathEncryptStream() *framework.Path {
+	fields := streamFields()
+	fields["stream_id"] = &framework.FieldSchema{
+		Type: framework.TypeString,
+		Description: `The stream ID returned when the stream was started. If
+omitted, a new stream is started.`,
+	}
+	fields["key_version"] = &framework.FieldSchema{
+		Type: framework.TypeInt,
+		Description: `The version of the key to use when starting a new stream.
+Must be 0 (for latest) or a value greater than or equal
+to the min_encryption_version configured on the key.`,
+	}
+
+	return &framework.Path{
+		Pattern: "encrypt-stream/" + framework.GenericNameRegex("name"),
+
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixTransit,
+			OperationVerb:   "encrypt",
+			OperationSuffix: "stream",
+		},
+
+		Fields: fields,
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathEncryptStreamWrite,
+		},
+
+		HelpSynopsis:    pathEncryptStreamHelpSyn,
+		HelpDescription: pathEncryptStreamHelpDesc,
+	}
+}
+
+func (b *backend) pathDecryptStream() *framework.Path {
+	return &framework.Path{
+		Pattern: "decrypt-stream/" + framework.GenericNameRegex("name"),
+
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixTransit,
+			OperationVerb:   "decrypt",
+			OperationSuffix: "stream",
+		},
+
+		Fields: decryptStreamFields(),
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathDecryptStreamWrite,
+		},
+
+		HelpSynopsis:    pathDecryptStreamHelpSyn,
+		HelpDescription: pathDecryptStreamHelpDesc,
+	}
+}
+
+func decryptStreamFields() map[string]*framework.FieldSchema {
+	fields := streamFields()
+	fields["header"] = &framework.FieldSchema{
+		Type: framework.TypeString,
+		Description: `The header returned with the segments by the encrypt-stream
+request which sealed them.`,
+	}
+	return fields
+}
+
+// streamRequest holds the decoded parameters common to the stream
+// encrypt/decrypt endpoints.
+type streamRequest struct {
+	context        []byte
+	associatedData []byte
+	segments       [][]byte
+	index          uint32
+	final          bool
+}
+
+func parseStreamRequest(d *framework.FieldData) (*streamRequest, error) {
+	var err error
+	sr := &streamRequest{
+		final: d.Get("final").(bool),
+	}
+
+	if contextRaw := d.Get("context").(string); contextRaw != "" {
+		sr.context, err = base64.StdEncoding.DecodeString(contextRaw)
+		if err != nil {
+			return nil, fmt.Errorf("failed to base64-decode context")
+		}
+	}
+
+	if adRaw := d.Get("associated_data").(string); adRaw != "" {
+		sr.associatedData, err = base64.StdEncoding.DecodeString(adRaw)
+		if err != nil {
+			return nil, fmt.Errorf("failed to base64-decode associated_data")
+		}
+	}
+
+	segmentsRaw := d.Get("segments").([]string)
+	if len(segmentsRaw) == 0 {
+		return nil, fmt.Errorf("missing segments to process")
+	}
+
+	index := d.Get("segment_index").(int)
+	if index < 0 || uint64(index)+uint64(len(segmentsRaw)) > math.MaxUint32 {
+		return nil, fmt.Errorf("segment_index out of range")
+	}
+	sr.index = uint32(index)
+
+	sr.segments = make([][]byte, len(segmentsRaw))
+	for i, segment := range segmentsRaw {
+		sr.segments[i], err = base64.StdEncoding.DecodeString(segment)
+		if err != nil {
+			return nil, fmt.Errorf("failed to base64-decode segment %d", index+i)
+		}
+	}
+
+	return sr, nil
+}
+
+func (b *backend) pathEncryptStreamWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+	ver := d.Get("key_version").(int)
+
+	sr, err := parseStreamRequest(d)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+
+	var streamID []byte
+	if streamIDRaw := d.Get("stream_id").(string); streamIDRaw != "" {
+		streamID, err = base64.StdEncoding.DecodeString(streamIDRaw)
+		if err != nil {
+			return logical.ErrorResponse("failed to base64-decode stream_id"), logical.ErrInvalidRequest
+		}
+	} else if sr.index != 0 {
+		return logical.ErrorResponse("a new stream must start at segment_index 0"), logical.ErrInvalidRequest
+	}
+
+	p, _, err := b.GetPolicy(ctx, keysutil.PolicyRequest{
+		Storage: req.Storage,
+		Name:    name,
+	}, b.GetRandomReader())
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return logical.ErrorResponse("encryption key not found"), logical.ErrInvalidRequest
+	}
+	if !b.System().CachingDisabled() {
+		p.Lock(false)
+	}
+	defer p.Unlock()
+
+	if err := b.checkKeyUsageWritable(p); err != nil {
+		return nil, err
+	}
+
+	if streamID == nil {
+		streamID, err = keysutil.NewStreamID(b.GetRandomReader())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sc, header, err := p.NewStreamEncrypter(sr.context, ver, streamID, b.GetRandomReader())
+	if err != nil {
+		return streamErrorResponse(err)
+	}
+
+	// Each stream counts as a single encryption, recorded when it starts.
+	if sr.index == 0 {
+		b.recordKeyUsage(ctx, req.Storage, p, ver, keysutil.KeyUsageEncrypt, 1)
+	}
+
+	segments := make([]string, len(sr.segments))
+	for i, plaintext := range sr.segments {
+		final := sr.final && i == len(sr.segments)-1
+		segments[i] = base64.StdEncoding.EncodeToString(sc.Seal(sr.index+uint32(i), final, plaintext, sr.associatedData))
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"stream_id":          base64.StdEncoding.EncodeToString(streamID),
+			"header":             header,
+			"segments":           segments,
+			"next_segment_index": int64(sr.index) + int64(len(segments)),
+		},
+	}, nil
+}
+
+func (b *backend) pathDecryptStreamWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+	header := d.Get("header").(string)
+	if header == "" {
+		return logical.ErrorResponse("missing stream header"), logical.ErrInvalidRequest
+	}
+
+	sr, err := parseStreamRequest(d)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+
+	p, _, err := b.GetPolicy(ctx, keysutil.PolicyRequest{
+		Storage: req.Storage,
+		Name:    name,
+	}, b.GetRandomReader())
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return logical.ErrorResponse("encryption key not found"), logical.ErrInvalidRequest
+	}
+	if !b.System().CachingDisabled() {
+		p.Lock(false)
+	}
+	defer p.Unlock()
+
+	if err := b.checkKeyUsageWritable(p); err != nil {
+		return nil, err
+	}
+
+	sc, err := p.NewStreamDecrypter(sr.context, header)
+	if err != nil {
+		return streamErrorResponse(err)
+	}
+
+	segments := make([]string, len(sr.segments))
+	for i, ciphertext := range sr.segments {
+		final := sr.final && i == len(sr.segments)-1
+		plaintext, err := sc.Open(sr.index+uint32(i), final, ciphertext, sr.associatedData)
+		if err != nil {
+			return streamErrorResponse(err)
+		}
+		segments[i] = base64.StdEncoding.EncodeToString(plaintext)
+	}
+
+	// Each request counts as a decryption, so that resumed streams keep the
+	// version's last decryption time current.
+	if parsed, err := p.ParseStreamHeader(header); err == nil {
+		b.recordKeyUsage(ctx, req.Storage, p, parsed.KeyVersion, keysutil.KeyUsageDecrypt, 1)
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"segments":           segments,
+			"next_segment_index": int64(sr.index) + int64(len(segments)),
+		},
+	}, nil
+}
+
+func streamErrorResponse(err error) (*logical.Response, error) {
+	switch err.(type) {
+	case errutil.UserError:
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	default:
+		return nil, err
+	}
+}
+
+const pathEncryptStreamHelpSyn = `Encrypt a large payload as a stream of segments`
+
+const pathEncryptStreamHelpDesc = `
+This path encrypts a payload that has been split into segments using a
+chunked AEAD construction. Omitting the stream ID starts a new stream; the
+returned stream ID must be supplied with every subsequent request of that
+stream. Every request returns a fresh header, which must be supplied when
+decrypting the segments it returned. Each segment is bound to its stream,
+its position in the stream and whether it is the final segment, so spliced,
+reordered, duplicated or truncated streams fail to decrypt.
+`
+
+const pathDecryptStreamHelpSyn = `Decrypt segments of a stream encrypted by encrypt-stream`
+
+const pathDecryptStreamHelpDesc = `
+This path decrypts segments produced by the encrypt-stream endpoint. All
+segments of a request must have been returned with the given header, and
+their segment indexes and final flag must match those used during
+encryption.
+`
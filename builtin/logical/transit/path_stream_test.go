@@ -0,0 +1,178 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package transit
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func TestTransit_EncryptDecryptStream(t *testing.T) {
+	b, storage := createBackendWithSysView(t)
+
+	for _, keyType := range []string{"aes128-gcm96", "aes256-gcm96", "chacha20-poly1305"} {
+		t.Run(keyType, func(t *testing.T) {
+			keyName := "stream-" + keyType
+			resp, err := b.HandleRequest(context.Background(), &logical.Request{
+				Storage:   storage,
+				Operation: logical.UpdateOperation,
+				Path:      "keys/" + keyName,
+				Data: map[string]interface{}{
+					"type": keyType,
+				},
+			})
+			if err != nil || (resp != nil && resp.IsError()) {
+				t.Fatalf("failed to create key: resp: %#v, err: %v", resp, err)
+			}
+
+			plaintexts := []string{"first segment", "second segment", "third segment", "last"}
+			encoded := make([]string, len(plaintexts))
+			for i, pt := range plaintexts {
+				encoded[i] = base64.StdEncoding.EncodeToString([]byte(pt))
+			}
+
+			// Start the stream with the first two segments
+			resp, err = b.HandleRequest(context.Background(), &logical.Request{
+				Storage:   storage,
+				Operation: logical.UpdateOperation,
+				Path:      "encrypt-stream/" + keyName,
+				Data: map[string]interface{}{
+					"segments": encoded[:2],
+				},
+			})
+			if err != nil || resp == nil || resp.IsError() {
+				t.Fatalf("failed to start stream: resp: %#v, err: %v", resp, err)
+			}
+			streamID := resp.Data["stream_id"].(string)
+			header := resp.Data["header"].(string)
+			ciphertexts := resp.Data["segments"].([]string)
+			if resp.Data["next_segment_index"].(int64) != 2 {
+				t.Fatalf("unexpected next_segment_index: %v", resp.Data["next_segment_index"])
+			}
+
+			// Continue the stream and finish it
+			resp, err = b.HandleRequest(context.Background(), &logical.Request{
+				Storage:   storage,
+				Operation: logical.UpdateOperation,
+				Path:      "encrypt-stream/" + keyName,
+				Data: map[string]interface{}{
+					"stream_id":     streamID,
+					"segments":      encoded[2:],
+					"segment_index": 2,
+					"final":         true,
+				},
+			})
+			if err != nil || resp == nil || resp.IsError() {
+				t.Fatalf("failed to continue stream: resp: %#v, err: %v", resp, err)
+			}
+			if resp.Data["stream_id"].(string) != streamID {
+				t.Fatalf("stream ID changed mid-stream")
+			}
+			finalHeader := resp.Data["header"].(string)
+			if finalHeader == header {
+				t.Fatalf("header reused across requests")
+			}
+			ciphertexts = append(ciphertexts, resp.Data["segments"].([]string)...)
+
+			// Decrypt the stream, one header at a time
+			decrypt := func(header string, segments []string, index int, final bool) (*logical.Response, error) {
+				return b.HandleRequest(context.Background(), &logical.Request{
+					Storage:   storage,
+					Operation: logical.UpdateOperation,
+					Path:      "decrypt-stream/" + keyName,
+					Data: map[string]interface{}{
+						"header":        header,
+						"segments":      segments,
+						"segment_index": index,
+						"final":         final,
+					},
+				})
+			}
+			var decrypted []string
+			resp, err = decrypt(header, ciphertexts[:2], 0, false)
+			if err != nil || resp == nil || resp.IsError() {
+				t.Fatalf("failed to decrypt stream: resp: %#v, err: %v", resp, err)
+			}
+			decrypted = append(decrypted, resp.Data["segments"].([]string)...)
+			resp, err = decrypt(finalHeader, ciphertexts[2:], 2, true)
+			if err != nil || resp == nil || resp.IsError() {
+				t.Fatalf("failed to decrypt stream: resp: %#v, err: %v", resp, err)
+			}
+			decrypted = append(decrypted, resp.Data["segments"].([]string)...)
+			for i, segment := range decrypted {
+				if segment != encoded[i] {
+					t.Fatalf("segment %d mismatch: expected %q, got %q", i, encoded[i], segment)
+				}
+			}
+
+			// Segments can only be opened with the header they were sealed
+			// with.
+			resp, err = decrypt(header, ciphertexts[2:], 2, true)
+			if err == nil || resp == nil || !resp.IsError() {
+				t.Fatalf("expected decryption with the wrong header to fail: resp: %#v, err: %v", resp, err)
+			}
+
+			// Truncating the stream must fail: the new last segment was not
+			// sealed as final.
+			resp, err = decrypt(finalHeader, ciphertexts[2:3], 2, true)
+			if err == nil || resp == nil || !resp.IsError() {
+				t.Fatalf("expected truncated stream to fail: resp: %#v, err: %v", resp, err)
+			}
+
+			// Reordering segments must fail.
+			resp, err = decrypt(header, []string{ciphertexts[1], ciphertexts[0]}, 0, false)
+			if err == nil || resp == nil || !resp.IsError() {
+				t.Fatalf("expected reordered stream to fail: resp: %#v, err: %v", resp, err)
+			}
+
+			// Replaying a stream ID and segment index seals under a fresh
+			// header, so the segment is never encrypted under the same key
+			// and nonce twice.
+			resp, err = b.HandleRequest(context.Background(), &logical.Request{
+				Storage:   storage,
+				Operation: logical.UpdateOperation,
+				Path:      "encrypt-stream/" + keyName,
+				Data: map[string]interface{}{
+					"stream_id":     streamID,
+					"segments":      encoded[2:3],
+					"segment_index": 2,
+				},
+			})
+			if err != nil || resp == nil || resp.IsError() {
+				t.Fatalf("failed to replay stream: resp: %#v, err: %v", resp, err)
+			}
+			if resp.Data["header"].(string) == finalHeader || resp.Data["segments"].([]string)[0] == ciphertexts[2] {
+				t.Fatalf("replayed stream reused a header")
+			}
+		})
+	}
+
+	t.Run("unsupported key type", func(t *testing.T) {
+		_, err := b.HandleRequest(context.Background(), &logical.Request{
+			Storage:   storage,
+			Operation: logical.UpdateOperation,
+			Path:      "keys/stream-rsa",
+			Data: map[string]interface{}{
+				"type": "rsa-2048",
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Storage:   storage,
+			Operation: logical.UpdateOperation,
+			Path:      "encrypt-stream/stream-rsa",
+			Data: map[string]interface{}{
+				"segments": []string{"Zm9v"},
+			},
+		})
+		if err == nil || resp == nil || !resp.IsError() {
+			t.Fatalf("expected error for unsupported key type: resp: %#v, err: %v", resp, err)
+		}
+	})
+}
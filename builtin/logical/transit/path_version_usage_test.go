@@ -0,0 +1,173 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package transit
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransit_KeyVersionUsage(t *testing.T) {
+	b, storage := createBackendWithSysView(t)
+
+	doReq := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Storage:   storage,
+			Operation: op,
+			Path:      path,
+			Data:      data,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("request to %s failed: resp: %#v, err: %v", path, resp, err)
+		}
+		return resp
+	}
+
+	encrypt := func() string {
+		t.Helper()
+		resp := doReq(logical.UpdateOperation, "encrypt/test", map[string]interface{}{
+			"plaintext": base64.StdEncoding.EncodeToString([]byte("the quick brown fox")),
+		})
+		return resp.Data["ciphertext"].(string)
+	}
+
+	doReq(logical.UpdateOperation, "keys/test", nil)
+	v1 := encrypt()
+	doReq(logical.UpdateOperation, "keys/test/rotate", nil)
+	v2 := encrypt()
+	doReq(logical.UpdateOperation, "keys/test/rotate", nil)
+	encrypt()
+
+	// Only version 2 is in use: it is decrypted directly and rewrapped.
+	doReq(logical.UpdateOperation, "decrypt/test", map[string]interface{}{"ciphertext": v2})
+	doReq(logical.UpdateOperation, "rewrap/test", map[string]interface{}{"ciphertext": v2})
+
+	resp := doReq(logical.ReadOperation, "keys/test/version-usage", map[string]interface{}{"since": "24h"})
+	versions := resp.Data["versions"].(map[string]interface{})
+	require.Len(t, versions, 3)
+
+	usage := versions["1"].(map[string]interface{})
+	require.Equal(t, uint64(1), usage["encryptions"])
+	require.Equal(t, uint64(0), usage["decryptions"])
+	require.Equal(t, "", usage["last_decrypted"])
+
+	usage = versions["2"].(map[string]interface{})
+	require.Equal(t, uint64(1), usage["encryptions"])
+	require.Equal(t, uint64(2), usage["decryptions"])
+	require.NotEmpty(t, usage["last_decrypted"])
+
+	// The rewrap encrypted under the latest version.
+	usage = versions["3"].(map[string]interface{})
+	require.Equal(t, uint64(2), usage["encryptions"])
+	require.Equal(t, uint64(0), usage["decryptions"])
+
+	require.Equal(t, 2, resp.Data["min_used_version"])
+
+	// Without "since", min_used_version is omitted.
+	resp = doReq(logical.ReadOperation, "keys/test/version-usage", nil)
+	require.NotContains(t, resp.Data, "min_used_version")
+
+	// Version 1 can be retired safely; its usage is dropped once trimmed.
+	doReq(logical.UpdateOperation, "keys/test/config", map[string]interface{}{
+		"min_decryption_version": 2,
+		"min_encryption_version": 3,
+	})
+	doReq(logical.UpdateOperation, "keys/test/trim", map[string]interface{}{
+		"min_available_version": 2,
+	})
+	resp = doReq(logical.ReadOperation, "keys/test/version-usage", nil)
+	versions = resp.Data["versions"].(map[string]interface{})
+	require.Len(t, versions, 2)
+	require.NotContains(t, versions, "1")
+
+	// Failed decryptions are not counted.
+	_, err := b.HandleRequest(context.Background(), &logical.Request{
+		Storage:   storage,
+		Operation: logical.UpdateOperation,
+		Path:      "decrypt/test",
+		Data:      map[string]interface{}{"ciphertext": v1},
+	})
+	require.Error(t, err)
+	resp = doReq(logical.ReadOperation, "keys/test/version-usage", nil)
+	require.Equal(t, uint64(2), resp.Data["versions"].(map[string]interface{})["2"].(map[string]interface{})["decryptions"])
+}
+
+func TestTransit_KeyVersionUsage_Verify(t *testing.T) {
+	b, storage := createBackendWithSysView(t)
+
+	doReq := func(path string, data map[string]interface{}) *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Storage:   storage,
+			Operation: logical.UpdateOperation,
+			Path:      path,
+			Data:      data,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("request to %s failed: resp: %#v, err: %v", path, resp, err)
+		}
+		return resp
+	}
+	versionUsage := func(name string) map[string]interface{} {
+		t.Helper()
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Storage:   storage,
+			Operation: logical.ReadOperation,
+			Path:      "keys/" + name + "/version-usage",
+			Data:      map[string]interface{}{"since": "24h"},
+		})
+		require.NoError(t, err)
+		return resp.Data
+	}
+
+	input := base64.StdEncoding.EncodeToString([]byte("the quick brown fox"))
+
+	// Verifying with an old version keeps it in use, whichever path is
+	// used to verify.
+	for _, tc := range []struct {
+		keyType string
+		sign    func(name string) map[string]interface{}
+		verify  string
+	}{
+		{"ecdsa-p256", func(name string) map[string]interface{} {
+			sig := doReq("sign/"+name, map[string]interface{}{"input": input}).Data["signature"]
+			return map[string]interface{}{"input": input, "signature": sig}
+		}, "verify/"},
+		{"aes256-gcm96", func(name string) map[string]interface{} {
+			mac := doReq("hmac/"+name, map[string]interface{}{"input": input}).Data["hmac"]
+			return map[string]interface{}{"input": input, "hmac": mac}
+		}, "verify/"},
+		{"aes256-cmac", func(name string) map[string]interface{} {
+			mac := doReq("cmac/"+name, map[string]interface{}{"input": input}).Data["cmac"]
+			return map[string]interface{}{"input": input, "cmac": mac}
+		}, "verify/"},
+		{"ed25519", func(name string) map[string]interface{} {
+			token := doReq("jws/sign/"+name, map[string]interface{}{
+				"claims": map[string]interface{}{"sub": "vault"},
+			}).Data["token"]
+			return map[string]interface{}{"token": token}
+		}, "jws/verify/"},
+	} {
+		t.Run(tc.keyType, func(t *testing.T) {
+			name := "verify-" + tc.keyType
+			doReq("keys/"+name, map[string]interface{}{"type": tc.keyType})
+			verifyData := tc.sign(name)
+			doReq("keys/"+name+"/rotate", nil)
+
+			resp := doReq(tc.verify+name, verifyData)
+			require.True(t, resp.Data["valid"].(bool))
+
+			data := versionUsage(name)
+			usage := data["versions"].(map[string]interface{})["1"].(map[string]interface{})
+			require.Equal(t, uint64(1), usage["verifications"])
+			require.NotEmpty(t, usage["last_verified"])
+			require.Equal(t, 1, data["min_used_version"])
+		})
+	}
+}
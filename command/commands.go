@@ -775,6 +775,16 @@ func initCommands(ui, serverCmdUi cli.Ui, runOpts *RunOptions) map[string]cli.Co
 				BaseCommand: getBaseCommand(),
 			}, nil
 		},
+		"transit decrypt-file": func() (cli.Command, error) {
+			return &TransitDecryptFileCommand{
+				BaseCommand: getBaseCommand(),
+			}, nil
+		},
+		"transit encrypt-file": func() (cli.Command, error) {
+			return &TransitEncryptFileCommand{
+				BaseCommand: getBaseCommand(),
+			}, nil
+		},
 		"transit import": func() (cli.Command, error) {
 			return &TransitImportCommand{
 				BaseCommand: getBaseCommand(),
@@ -0,0 +1,225 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package command
+
+import (
+	"bufio"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/hashicorp/cli"
+	"github.com/hashicorp/vault/api"
+	"github.com/posener/complete"
+)
+
+var (
+	_ cli.Command             = (*TransitDecryptFileCommand)(nil)
+	_ cli.CommandAutocomplete = (*TransitDecryptFileCommand)(nil)
+)
+
+type TransitDecryptFileCommand struct {
+	*BaseCommand
+
+	flagSegmentsPerRequest int
+	flagContext            string
+}
+
+func (c *TransitDecryptFileCommand) Synopsis() string {
+	return "Decrypt a file encrypted with \"vault transit encrypt-file\"."
+}
+
+func (c *TransitDecryptFileCommand) Help() string {
+	helpText := `
+Usage: vault transit decrypt-file [options] PATH INPUT OUTPUT
+
+  Decrypts the file INPUT, previously written by "vault transit encrypt-file",
+  with the Transit key at PATH (in the form :mount:/keys/:name:) and writes
+  the plaintext to OUTPUT. Use "-" as INPUT or OUTPUT to read from stdin or
+  write to stdout.
+
+  Decryption fails if the file has been truncated, reordered or otherwise
+  modified. The plaintext is written to a temporary file next to OUTPUT, which
+  only replaces OUTPUT once the whole file has been decrypted. When writing to
+  stdout, plaintext from earlier segments may already have been written when a
+  later segment fails to decrypt.
+
+      $ vault transit decrypt-file transit/keys/backups backup.tar.enc backup.tar
+
+` + c.Flags().Help()
+
+	return strings.TrimSpace(helpText)
+}
+
+func (c *TransitDecryptFileCommand) Flags() *FlagSets {
+	set := c.flagSet(FlagSetHTTP)
+	f := set.NewFlagSet("Command Options")
+
+	f.IntVar(&IntVar{
+		Name:    "segments-per-request",
+		Target:  &c.flagSegmentsPerRequest,
+		Default: defaultTransitStreamSegmentsPerRequest,
+		Usage:   "Number of segments sent to Vault in each request.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:    "context",
+		Target:  &c.flagContext,
+		Default: "",
+		Usage:   "Base64 encoded context for keys with derivation enabled.",
+	})
+
+	return set
+}
+
+func (c *TransitDecryptFileCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictFiles("*")
+}
+
+func (c *TransitDecryptFileCommand) AutocompleteFlags() complete.Flags {
+	return c.Flags().Completions()
+}
+
+func (c *TransitDecryptFileCommand) Run(args []string) int {
+	f := c.Flags()
+	if err := f.Parse(args); err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	args = f.Args()
+	if len(args) != 3 {
+		c.UI.Error(fmt.Sprintf("Incorrect argument count (expected 3, got %d). Wanted PATH, INPUT and OUTPUT.", len(args)))
+		return 1
+	}
+
+	if c.flagSegmentsPerRequest <= 0 {
+		c.UI.Error("segments-per-request must be positive")
+		return 1
+	}
+
+	apiPath, err := transitStreamPath(args[0], "decrypt-stream")
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	client, err := c.Client()
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 2
+	}
+
+	in, closeIn, err := openTransitStreamInput(args[1])
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+	defer closeIn()
+
+	out, commitOut, discardOut, err := openTransitStreamOutput(args[2])
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	if err := c.decrypt(client, apiPath, bufio.NewReader(in), out); err != nil {
+		discardOut()
+		c.UI.Error(fmt.Sprintf("Error decrypting file: %s", err))
+		return 2
+	}
+
+	if err := commitOut(); err != nil {
+		c.UI.Error(fmt.Sprintf("Error writing output: %s", err))
+		return 2
+	}
+
+	return 0
+}
+
+func (c *TransitDecryptFileCommand) decrypt(client *api.Client, apiPath string, in io.Reader, out io.Writer) error {
+	if err := readTransitStreamMagic(in); err != nil {
+		return err
+	}
+
+	header, remaining, err := readTransitStreamRecord(in)
+	if err == io.EOF {
+		return errors.New("stream contains no segments")
+	}
+	if err != nil {
+		return err
+	}
+
+	index := 0
+	for {
+		// A request can only contain segments sealed with the same header.
+		n := remaining
+		if n > c.flagSegmentsPerRequest {
+			n = c.flagSegmentsPerRequest
+		}
+		batch := make([]string, 0, n)
+		for len(batch) < n {
+			segment, err := readTransitStreamFileSegment(in)
+			if err == io.EOF {
+				return errors.New("stream ends in the middle of a record")
+			}
+			if err != nil {
+				return err
+			}
+			batch = append(batch, base64.StdEncoding.EncodeToString(segment))
+		}
+		remaining -= n
+
+		final := false
+		var nextHeader string
+		var nextRemaining int
+		if remaining == 0 {
+			nextHeader, nextRemaining, err = readTransitStreamRecord(in)
+			switch {
+			case err == io.EOF:
+				final = true
+			case err != nil:
+				return err
+			}
+		}
+
+		data := map[string]interface{}{
+			"header":        header,
+			"segments":      batch,
+			"segment_index": index,
+			"final":         final,
+		}
+		if c.flagContext != "" {
+			data["context"] = c.flagContext
+		}
+
+		secret, err := client.Logical().Write(apiPath, data)
+		if err != nil {
+			return err
+		}
+		if secret == nil || secret.Data == nil {
+			return errors.New("empty response from decrypt-stream")
+		}
+
+		segments, err := transitStreamSegmentsFromResponse(secret, index, len(batch))
+		if err != nil {
+			return err
+		}
+		for _, segment := range segments {
+			if _, err := out.Write(segment); err != nil {
+				return err
+			}
+		}
+
+		index += len(batch)
+		if final {
+			return nil
+		}
+		if remaining == 0 {
+			header, remaining = nextHeader, nextRemaining
+		}
+	}
+}
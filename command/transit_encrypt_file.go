@@ -0,0 +1,457 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package command
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/cli"
+	"github.com/hashicorp/vault/api"
+	"github.com/posener/complete"
+)
+
+var (
+	_ cli.Command             = (*TransitEncryptFileCommand)(nil)
+	_ cli.CommandAutocomplete = (*TransitEncryptFileCommand)(nil)
+)
+
+const (
+	// transitStreamFileMagic identifies files written by encrypt-file.
+	transitStreamFileMagic = "VTS1"
+
+	// transitStreamMaxSegmentSize bounds the segment size accepted when
+	// reading a stream file so a corrupt length cannot exhaust memory.
+	transitStreamMaxSegmentSize = 16 * 1024 * 1024
+
+	defaultTransitStreamSegmentSize        = 64 * 1024
+	defaultTransitStreamSegmentsPerRequest = 64
+)
+
+type TransitEncryptFileCommand struct {
+	*BaseCommand
+
+	flagSegmentSize        int
+	flagSegmentsPerRequest int
+	flagContext            string
+	flagKeyVersion         int
+}
+
+func (c *TransitEncryptFileCommand) Synopsis() string {
+	return "Encrypt a file using the Transit streaming encryption endpoint."
+}
+
+func (c *TransitEncryptFileCommand) Help() string {
+	helpText := `
+Usage: vault transit encrypt-file [options] PATH INPUT OUTPUT
+
+  Encrypts the file INPUT with the Transit key at PATH (in the form
+  :mount:/keys/:name:) and writes the result to OUTPUT. The file is split
+  into segments which are encrypted as a single stream, so arbitrarily
+  large files can be encrypted without ever sending them to Vault in one
+  request. Use "-" as INPUT or OUTPUT to read from stdin or write to stdout.
+
+  The output can be decrypted with "vault transit decrypt-file".
+
+      $ vault transit encrypt-file transit/keys/backups backup.tar backup.tar.enc
+
+` + c.Flags().Help()
+
+	return strings.TrimSpace(helpText)
+}
+
+func (c *TransitEncryptFileCommand) Flags() *FlagSets {
+	set := c.flagSet(FlagSetHTTP)
+	f := set.NewFlagSet("Command Options")
+
+	f.IntVar(&IntVar{
+		Name:    "segment-size",
+		Target:  &c.flagSegmentSize,
+		Default: defaultTransitStreamSegmentSize,
+		Usage:   "Size in bytes of each plaintext segment.",
+	})
+
+	f.IntVar(&IntVar{
+		Name:    "segments-per-request",
+		Target:  &c.flagSegmentsPerRequest,
+		Default: defaultTransitStreamSegmentsPerRequest,
+		Usage:   "Number of segments sent to Vault in each request.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:    "context",
+		Target:  &c.flagContext,
+		Default: "",
+		Usage:   "Base64 encoded context for keys with derivation enabled.",
+	})
+
+	f.IntVar(&IntVar{
+		Name:    "key-version",
+		Target:  &c.flagKeyVersion,
+		Default: 0,
+		Usage:   "Version of the key to encrypt with. Defaults to the latest version.",
+	})
+
+	return set
+}
+
+func (c *TransitEncryptFileCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictFiles("*")
+}
+
+func (c *TransitEncryptFileCommand) AutocompleteFlags() complete.Flags {
+	return c.Flags().Completions()
+}
+
+func (c *TransitEncryptFileCommand) Run(args []string) int {
+	f := c.Flags()
+	if err := f.Parse(args); err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	args = f.Args()
+	if len(args) != 3 {
+		c.UI.Error(fmt.Sprintf("Incorrect argument count (expected 3, got %d). Wanted PATH, INPUT and OUTPUT.", len(args)))
+		return 1
+	}
+
+	if c.flagSegmentSize <= 0 || c.flagSegmentSize > transitStreamMaxSegmentSize {
+		c.UI.Error(fmt.Sprintf("segment-size must be between 1 and %d", transitStreamMaxSegmentSize))
+		return 1
+	}
+	if c.flagSegmentsPerRequest <= 0 {
+		c.UI.Error("segments-per-request must be positive")
+		return 1
+	}
+
+	apiPath, err := transitStreamPath(args[0], "encrypt-stream")
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	client, err := c.Client()
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 2
+	}
+
+	in, closeIn, err := openTransitStreamInput(args[1])
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+	defer closeIn()
+
+	out, commitOut, discardOut, err := openTransitStreamOutput(args[2])
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	if err := c.encrypt(client, apiPath, bufio.NewReader(in), out); err != nil {
+		discardOut()
+		c.UI.Error(fmt.Sprintf("Error encrypting file: %s", err))
+		return 2
+	}
+
+	if err := commitOut(); err != nil {
+		c.UI.Error(fmt.Sprintf("Error writing output: %s", err))
+		return 2
+	}
+
+	return 0
+}
+
+func (c *TransitEncryptFileCommand) encrypt(client *api.Client, apiPath string, in io.Reader, out io.Writer) error {
+	next, err := readTransitStreamSegment(in, c.flagSegmentSize)
+	if err != nil && err != io.EOF {
+		return err
+	}
+	if next == nil {
+		// An empty input is still encrypted as a single, empty, final segment.
+		next = []byte{}
+	}
+
+	if _, err := io.WriteString(out, transitStreamFileMagic); err != nil {
+		return err
+	}
+
+	var streamID string
+	index := 0
+	for {
+		var batch []string
+		final := false
+		for len(batch) < c.flagSegmentsPerRequest {
+			batch = append(batch, base64.StdEncoding.EncodeToString(next))
+			next, err = readTransitStreamSegment(in, c.flagSegmentSize)
+			if err == io.EOF {
+				final = true
+				break
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		data := map[string]interface{}{
+			"segments":      batch,
+			"segment_index": index,
+			"final":         final,
+			"key_version":   c.flagKeyVersion,
+		}
+		if streamID != "" {
+			data["stream_id"] = streamID
+		}
+		if c.flagContext != "" {
+			data["context"] = c.flagContext
+		}
+
+		secret, err := client.Logical().Write(apiPath, data)
+		if err != nil {
+			return err
+		}
+		if secret == nil || secret.Data == nil {
+			return errors.New("empty response from encrypt-stream")
+		}
+
+		if streamID == "" {
+			streamID, _ = secret.Data["stream_id"].(string)
+			if streamID == "" {
+				return errors.New("missing stream ID in response")
+			}
+		}
+
+		// Every request returns a fresh header, which is written before the
+		// segments sealed with it.
+		header, _ := secret.Data["header"].(string)
+		if header == "" {
+			return errors.New("missing stream header in response")
+		}
+		if err := writeTransitStreamRecord(out, header, len(batch)); err != nil {
+			return err
+		}
+
+		segments, err := transitStreamSegmentsFromResponse(secret, index, len(batch))
+		if err != nil {
+			return err
+		}
+		for _, segment := range segments {
+			if err := writeTransitStreamSegment(out, segment); err != nil {
+				return err
+			}
+		}
+
+		index += len(batch)
+		if final {
+			return nil
+		}
+	}
+}
+
+// transitStreamPath converts a :mount:/keys/:name: path into the API path of
+// the given stream operation.
+func transitStreamPath(s string, operation string) (string, error) {
+	parts := keyPath.FindStringSubmatch(s)
+	if len(parts) != 3 {
+		return "", errors.New("expected transit path and key name in the form :path:/keys/:name:")
+	}
+
+	return parts[1] + "/" + operation + "/" + parts[2], nil
+}
+
+func openTransitStreamInput(name string) (io.Reader, func(), error) {
+	if name == "-" {
+		return os.Stdin, func() {}, nil
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error opening input file: %w", err)
+	}
+
+	return f, func() { f.Close() }, nil
+}
+
+// openTransitStreamOutput returns a writer for the named output along with
+// functions to commit or discard what was written. Files are written to a
+// temporary file in the same directory, which only replaces the output once
+// committed, so that nothing is left at the output should the stream fail to
+// authenticate; output written to stdout cannot be withdrawn.
+func openTransitStreamOutput(name string) (io.Writer, func() error, func(), error) {
+	if name == "-" {
+		return os.Stdout, func() error { return nil }, func() {}, nil
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".tmp-*")
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("error creating output file: %w", err)
+	}
+
+	discard := func() {
+		f.Close()
+		os.Remove(f.Name())
+	}
+
+	w := bufio.NewWriter(f)
+	return w, func() error {
+		if err := w.Flush(); err != nil {
+			discard()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			os.Remove(f.Name())
+			return err
+		}
+		if err := os.Rename(f.Name(), name); err != nil {
+			os.Remove(f.Name())
+			return err
+		}
+		return nil
+	}, discard, nil
+}
+
+// readTransitStreamSegment reads up to size bytes, returning io.EOF only once
+// no more data is available.
+func readTransitStreamSegment(r io.Reader, size int) ([]byte, error) {
+	buf := make([]byte, size)
+	n, err := io.ReadFull(r, buf)
+	switch {
+	case err == io.EOF:
+		return nil, io.EOF
+	case err == io.ErrUnexpectedEOF:
+		return buf[:n], nil
+	case err != nil:
+		return nil, err
+	}
+
+	return buf, nil
+}
+
+// writeTransitStreamRecord writes the header which the following count
+// segments were sealed with.
+func writeTransitStreamRecord(w io.Writer, header string, count int) error {
+	if len(header) > 0xffff {
+		return errors.New("stream header too long")
+	}
+
+	buf := make([]byte, 0, 2+len(header)+4)
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(header)))
+	buf = append(buf, header...)
+	buf = binary.BigEndian.AppendUint32(buf, uint32(count))
+	_, err := w.Write(buf)
+	return err
+}
+
+func readTransitStreamMagic(r io.Reader) error {
+	magic := make([]byte, len(transitStreamFileMagic))
+	if _, err := io.ReadFull(r, magic); err != nil {
+		return fmt.Errorf("error reading stream header: %w", err)
+	}
+	if string(magic) != transitStreamFileMagic {
+		return errors.New("input is not a Transit encrypted stream")
+	}
+
+	return nil
+}
+
+// readTransitStreamRecord reads a header and the number of segments sealed
+// with it, returning io.EOF only at a clean record boundary.
+func readTransitStreamRecord(r io.Reader) (string, int, error) {
+	var length [2]byte
+	if _, err := io.ReadFull(r, length[:]); err != nil {
+		if err == io.EOF {
+			return "", 0, io.EOF
+		}
+		return "", 0, fmt.Errorf("error reading stream header: %w", err)
+	}
+
+	header := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(r, header); err != nil {
+		return "", 0, fmt.Errorf("error reading stream header: %w", err)
+	}
+
+	var count [4]byte
+	if _, err := io.ReadFull(r, count[:]); err != nil {
+		return "", 0, fmt.Errorf("error reading stream header: %w", err)
+	}
+	if binary.BigEndian.Uint32(count[:]) == 0 {
+		return "", 0, errors.New("stream header is not followed by any segments")
+	}
+
+	return string(header), int(binary.BigEndian.Uint32(count[:])), nil
+}
+
+func writeTransitStreamSegment(w io.Writer, segment []byte) error {
+	if _, err := w.Write(binary.BigEndian.AppendUint32(nil, uint32(len(segment)))); err != nil {
+		return err
+	}
+	_, err := w.Write(segment)
+	return err
+}
+
+// readTransitStreamFileSegment reads one length-prefixed segment, returning
+// io.EOF only at a clean segment boundary.
+func readTransitStreamFileSegment(r io.Reader) ([]byte, error) {
+	var length [4]byte
+	if _, err := io.ReadFull(r, length[:]); err != nil {
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("error reading segment: %w", err)
+	}
+
+	size := binary.BigEndian.Uint32(length[:])
+	if size > transitStreamMaxSegmentSize+64 {
+		return nil, fmt.Errorf("segment length %d exceeds maximum", size)
+	}
+
+	segment := make([]byte, size)
+	if _, err := io.ReadFull(r, segment); err != nil {
+		return nil, fmt.Errorf("error reading segment: %w", err)
+	}
+
+	return segment, nil
+}
+
+// transitStreamSegmentsFromResponse decodes the segments returned by the
+// stream endpoints, checking they cover exactly the requested range.
+func transitStreamSegmentsFromResponse(secret *api.Secret, index int, expected int) ([][]byte, error) {
+	raw, ok := secret.Data["segments"].([]interface{})
+	if !ok || len(raw) != expected {
+		return nil, fmt.Errorf("unexpected segments in response")
+	}
+
+	next, ok := secret.Data["next_segment_index"].(json.Number)
+	if !ok {
+		return nil, errors.New("missing next_segment_index in response")
+	}
+	if n, err := next.Int64(); err != nil || n != int64(index+expected) {
+		return nil, fmt.Errorf("unexpected next_segment_index %q in response", next)
+	}
+
+	segments := make([][]byte, len(raw))
+	for i, r := range raw {
+		s, ok := r.(string)
+		if !ok {
+			return nil, fmt.Errorf("unexpected segment type %T in response", r)
+		}
+		decoded, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding segment: %w", err)
+		}
+		segments[i] = decoded
+	}
+
+	return segments, nil
+}
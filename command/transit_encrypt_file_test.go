@@ -0,0 +1,101 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package command
+
+import (
+	"bytes"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/stretchr/testify/require"
+)
+
+// Validate the `vault transit encrypt-file` and `decrypt-file` commands
+// round-trip files of various sizes.
+func TestTransitEncryptDecryptFile(t *testing.T) {
+	t.Parallel()
+
+	client, closer := testVaultServer(t)
+	defer closer()
+
+	if err := client.Sys().Mount("transit", &api.MountInput{
+		Type: "transit",
+	}); err != nil {
+		t.Fatalf("transit mount error: %#v", err)
+	}
+	if _, err := client.Logical().Write("transit/keys/files", nil); err != nil {
+		t.Fatalf("failed creating key: %v", err)
+	}
+
+	dir := t.TempDir()
+	for _, size := range []int{0, 1, 100, 1024, 4096 + 17} {
+		plaintext := make([]byte, size)
+		_, err := rand.Read(plaintext)
+		require.NoError(t, err)
+
+		plainPath := filepath.Join(dir, "plain")
+		encPath := filepath.Join(dir, "enc")
+		decPath := filepath.Join(dir, "dec")
+		require.NoError(t, os.WriteFile(plainPath, plaintext, 0o600))
+
+		execTransitStreamFile(t, client, "encrypt-file", []string{"-segment-size=256", "-segments-per-request=3", "transit/keys/files", plainPath, encPath}, false)
+		execTransitStreamFile(t, client, "decrypt-file", []string{"-segments-per-request=2", "transit/keys/files", encPath, decPath}, false)
+
+		decrypted, err := os.ReadFile(decPath)
+		require.NoError(t, err)
+		require.True(t, bytes.Equal(plaintext, decrypted), "round trip mismatch for size %d", size)
+
+		if size > 256 {
+			// Dropping the final segment must be detected.
+			encrypted, err := os.ReadFile(encPath)
+			require.NoError(t, err)
+			truncated := encrypted[:len(encrypted)-(4+(size%256)+16)]
+			require.NoError(t, os.WriteFile(encPath, truncated, 0o600))
+			execTransitStreamFile(t, client, "decrypt-file", []string{"transit/keys/files", encPath, decPath}, true)
+
+			// No plaintext from the unauthenticated stream may be written,
+			// and the previous output is left in place.
+			decrypted, err = os.ReadFile(decPath)
+			require.NoError(t, err)
+			require.True(t, bytes.Equal(plaintext, decrypted), "output replaced for size %d", size)
+
+			failedPath := filepath.Join(dir, "failed")
+			execTransitStreamFile(t, client, "decrypt-file", []string{"-segments-per-request=1", "transit/keys/files", encPath, failedPath}, true)
+			_, err = os.Stat(failedPath)
+			require.True(t, os.IsNotExist(err), "output written for size %d", size)
+
+			entries, err := os.ReadDir(dir)
+			require.NoError(t, err)
+			for _, entry := range entries {
+				require.NotContains(t, entry.Name(), ".tmp-")
+			}
+		}
+	}
+}
+
+func execTransitStreamFile(t *testing.T, client *api.Client, method string, args []string, expectFailure bool) {
+	t.Helper()
+
+	stdout := bytes.NewBuffer(nil)
+	stderr := bytes.NewBuffer(nil)
+	runOpts := &RunOptions{
+		Stdout: stdout,
+		Stderr: stderr,
+		Client: client,
+	}
+
+	code := RunCustom(append([]string{"transit", method}, args...), runOpts)
+	combined := stdout.String() + stderr.String()
+
+	if code != 0 {
+		if !expectFailure {
+			t.Fatalf("Got unexpected failure from %s (ret %d): %v", method, code, combined)
+		}
+	} else if expectFailure {
+		t.Fatalf("Expected failure from %s, got success (ret %d): %v", method, code, combined)
+	}
+}
@@ -97,6 +97,11 @@ var CRLNumberOID = asn1.ObjectIdentifier([]int{2, 5, 29, 20})
 // > id-ce-deltaCRLIndicator OBJECT IDENTIFIER ::= { id-ce 27 }
 var DeltaCRLIndicatorOID = asn1.ObjectIdentifier([]int{2, 5, 29, 27})
 
+// OID for RFC 5280 Issuing Distribution Point CRL extension.
+//
+// > id-ce-issuingDistributionPoint OBJECT IDENTIFIER ::= { id-ce 28 }
+var IssuingDistributionPointOID = asn1.ObjectIdentifier([]int{2, 5, 29, 28})
+
 // OID for KeyUsage from RFC 2459 : https://www.rfc-editor.org/rfc/rfc2459.html#section-4.2.1.3
 //
 // > id-ce-keyUsage OBJECT IDENTIFIER ::=  { id-ce 15 }
@@ -427,6 +432,15 @@ func GenerateSerialNumberWithRandomSource(randReader io.Reader) (*big.Int, error
 	return generateSerialNumber(randReader)
 }
 
+// creationSerialNumber returns the serial number requested in the creation
+// parameters, generating a random one if none was set.
+func creationSerialNumber(params *CreationParameters) (*big.Int, error) {
+	if params.SerialNumber != nil {
+		return params.SerialNumber, nil
+	}
+	return GenerateSerialNumber()
+}
+
 func generateSerialNumber(randReader io.Reader) (*big.Int, error) {
 	serial, err := rand.Int(randReader, (&big.Int{}).Exp(big.NewInt(2), big.NewInt(159), nil))
 	if err != nil {
@@ -895,7 +909,7 @@ func createCertificate(data *CreationBundle, randReader io.Reader, privateKeyGen
 	var err error
 	result := &ParsedCertBundle{}
 
-	serialNumber, err := GenerateSerialNumber()
+	serialNumber, err := creationSerialNumber(data.Params)
 	if err != nil {
 		return nil, err
 	}
@@ -1194,7 +1208,7 @@ func signCertificate(data *CreationBundle, randReader io.Reader) (*ParsedCertBun
 
 	result := &ParsedCertBundle{}
 
-	serialNumber, err := GenerateSerialNumber()
+	serialNumber, err := creationSerialNumber(data.Params)
 	if err != nil {
 		return nil, err
 	}
@@ -1420,6 +1434,51 @@ func CreateDeltaCRLIndicatorExt(completeCRLNumber int64) (pkix.Extension, error)
 	}, nil
 }
 
+// issuingDistributionPoint mirrors the RFC 5280 IssuingDistributionPoint
+// structure, restricted to the distributionPoint field:
+//
+// > IssuingDistributionPoint ::= SEQUENCE {
+// >      distributionPoint          [0] DistributionPointName OPTIONAL,
+// >      ... }
+// >
+// > DistributionPointName ::= CHOICE {
+// >      fullName                [0]     GeneralNames,
+// >      nameRelativeToCRLIssuer [1]     RelativeDistinguishedName }
+type issuingDistributionPoint struct {
+	DistributionPoint issuingDistributionPointName `asn1:"optional,tag:0"`
+}
+
+type issuingDistributionPointName struct {
+	FullName []asn1.RawValue `asn1:"optional,tag:0"`
+}
+
+// CreateIssuingDistributionPointExt allows creating partitioned CRLs, whose
+// scope is limited to the certificates pointing at one of the given URLs in
+// their CRL distribution points.
+func CreateIssuingDistributionPointExt(urls []string) (pkix.Extension, error) {
+	var idp issuingDistributionPoint
+	for _, uri := range urls {
+		// > uniformResourceIdentifier       [6]     IA5String,
+		idp.DistributionPoint.FullName = append(idp.DistributionPoint.FullName, asn1.RawValue{
+			Tag:   6,
+			Class: asn1.ClassContextSpecific,
+			Bytes: []byte(uri),
+		})
+	}
+
+	value, err := asn1.Marshal(idp)
+	if err != nil {
+		return pkix.Extension{}, fmt.Errorf("unable to marshal issuing distribution point (%v): %v", urls, err)
+	}
+	return pkix.Extension{
+		Id: IssuingDistributionPointOID,
+		// > Although the extension is critical, conforming implementations
+		// > are not required to support this extension.
+		Critical: true,
+		Value:    value,
+	}, nil
+}
+
 // ParseBasicConstraintExtension parses a basic constraint pkix.Extension, useful if attempting to validate
 // CSRs are requesting CA privileges as Go does not expose its implementation. Values returned are
 // IsCA, MaxPathLen or error. If MaxPathLen was not set, a value of -1 will be returned.
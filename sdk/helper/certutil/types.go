@@ -729,6 +729,7 @@ type CAInfoBundle struct {
 	URLs                 *URLEntries
 	LeafNotAfterBehavior NotAfterBehavior
 	RevocationSigAlg     x509.SignatureAlgorithm
+	CRLPartitions        int
 }
 
 func (b *CAInfoBundle) GetCAChain() []*CertBlock {
@@ -819,6 +820,10 @@ type CreationParameters struct {
 
 	// The explicit SKID to use; especially useful for cross-signing.
 	SKID []byte
+
+	// The explicit serial number to use; when nil, a random one is
+	// generated.
+	SerialNumber *big.Int
 }
 
 type CreationBundle struct {
@@ -0,0 +1,138 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package keysutil
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/subtle"
+	"fmt"
+
+	"github.com/hashicorp/vault/sdk/helper/errutil"
+)
+
+const (
+	// CMACTagSize is the full AES-CMAC tag size; shorter tags are produced
+	// by truncation as allowed by NIST SP 800-38B.
+	CMACTagSize = aes.BlockSize
+
+	// CMACMinTagSize is the shortest truncated tag we allow. SP 800-38B
+	// recommends at least 64 bits for general use.
+	CMACMinTagSize = 8
+
+	// cmacRb is the constant used when generating subkeys for a 128-bit
+	// block cipher (RFC 4493, section 2.3).
+	cmacRb = 0x87
+)
+
+func (kt KeyType) CMACSupported() bool {
+	switch kt {
+	case KeyType_AES128_CMAC, KeyType_AES256_CMAC:
+		return true
+	}
+	return false
+}
+
+// CMACKey returns the AES key used for CMAC operations at the given version.
+func (p *Policy) CMACKey(version int) ([]byte, error) {
+	if !p.Type.CMACSupported() {
+		return nil, errutil.UserError{Err: fmt.Sprintf("CMAC not supported for key type %v", p.Type)}
+	}
+
+	switch {
+	case version < 0:
+		return nil, fmt.Errorf("key version does not exist (cannot be negative)")
+	case version > p.LatestVersion:
+		return nil, fmt.Errorf("key version does not exist; latest key version is %d", p.LatestVersion)
+	}
+	keyEntry, err := p.safeGetKeyEntry(version)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(keyEntry.Key) == 0 {
+		return nil, fmt.Errorf("no CMAC key exists for that key version")
+	}
+	return keyEntry.Key, nil
+}
+
+// ComputeCMAC computes an AES-CMAC tag (NIST SP 800-38B, RFC 4493) of the
+// input, truncated to tagSize bytes.
+func ComputeCMAC(key, input []byte, tagSize int) ([]byte, error) {
+	if tagSize < CMACMinTagSize || tagSize > CMACTagSize {
+		return nil, errutil.UserError{Err: fmt.Sprintf("CMAC length must be between %d and %d bytes", CMACMinTagSize, CMACTagSize)}
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	k1, k2 := cmacSubkeys(block)
+
+	// Process all blocks but the last, which is treated specially.
+	var x [aes.BlockSize]byte
+	n := (len(input) + aes.BlockSize - 1) / aes.BlockSize
+	if n == 0 {
+		n = 1
+	}
+	for i := 0; i < n-1; i++ {
+		subtle.XORBytes(x[:], x[:], input[i*aes.BlockSize:(i+1)*aes.BlockSize])
+		block.Encrypt(x[:], x[:])
+	}
+
+	var last [aes.BlockSize]byte
+	rest := input[(n-1)*aes.BlockSize:]
+	if len(rest) == aes.BlockSize {
+		subtle.XORBytes(last[:], rest, k1[:])
+	} else {
+		copy(last[:], rest)
+		last[len(rest)] = 0x80
+		subtle.XORBytes(last[:], last[:], k2[:])
+	}
+
+	subtle.XORBytes(x[:], x[:], last[:])
+	block.Encrypt(x[:], x[:])
+
+	return x[:tagSize], nil
+}
+
+// VerifyCMAC checks an AES-CMAC tag in constant time. Tags truncated below
+// CMACMinTagSize are rejected, so callers can't weaken verification by
+// presenting a short tag.
+func VerifyCMAC(key, input, tag []byte) (bool, error) {
+	if len(tag) < CMACMinTagSize || len(tag) > CMACTagSize {
+		return false, errutil.UserError{Err: fmt.Sprintf("invalid CMAC: length must be between %d and %d bytes", CMACMinTagSize, CMACTagSize)}
+	}
+
+	computed, err := ComputeCMAC(key, input, len(tag))
+	if err != nil {
+		return false, err
+	}
+
+	return subtle.ConstantTimeCompare(computed, tag) == 1, nil
+}
+
+func cmacSubkeys(block cipher.Block) (k1, k2 [aes.BlockSize]byte) {
+	var l [aes.BlockSize]byte
+	block.Encrypt(l[:], l[:])
+
+	k1 = cmacShift(l)
+	k2 = cmacShift(k1)
+	return k1, k2
+}
+
+// cmacShift doubles the input in GF(2^128).
+func cmacShift(in [aes.BlockSize]byte) [aes.BlockSize]byte {
+	var out [aes.BlockSize]byte
+	var carry byte
+	for i := aes.BlockSize - 1; i >= 0; i-- {
+		out[i] = in[i]<<1 | carry
+		carry = in[i] >> 7
+	}
+	if carry != 0 {
+		out[aes.BlockSize-1] ^= cmacRb
+	}
+	return out
+}
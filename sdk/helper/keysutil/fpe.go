@@ -0,0 +1,488 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package keysutil
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/big"
+	"regexp"
+
+	"github.com/hashicorp/vault/sdk/helper/errutil"
+)
+
+const (
+	// FF31TweakSize is the tweak length required by FF3-1, in bytes.
+	FF31TweakSize = 7
+
+	// fpeMaxRadix is the largest radix allowed by NIST SP 800-38G.
+	fpeMaxRadix = 1 << 16
+
+	// fpeMinDomainSize is the smallest number of possible inputs allowed
+	// by SP 800-38G Rev. 1, i.e. radix^minlen >= 1,000,000.
+	fpeMinDomainSize = 1000000
+)
+
+func (kt KeyType) FPESupported() bool {
+	switch kt {
+	case KeyType_AES256_FF1, KeyType_AES256_FF3_1:
+		return true
+	}
+	return false
+}
+
+// FPETemplate describes the format of values protected with format
+// preserving encryption. Only characters in Alphabet are encrypted, and
+// the radix used is the number of characters in Alphabet.
+//
+// If Pattern is set, it must match the whole value; the characters matched
+// by its capture groups are encrypted together and all other characters are
+// left unchanged. Otherwise, every character in the alphabet is encrypted
+// and any other characters are left in place.
+type FPETemplate struct {
+	Alphabet string `json:"alphabet"`
+	Pattern  string `json:"pattern,omitempty"`
+
+	alphabet []rune
+	index    map[rune]int
+	pattern  *regexp.Regexp
+}
+
+// Validate checks the template and prepares it for use.
+func (t *FPETemplate) Validate() error {
+	t.alphabet = []rune(t.Alphabet)
+	if len(t.alphabet) < 2 {
+		return fmt.Errorf("alphabet must contain at least two characters")
+	}
+	if len(t.alphabet) > fpeMaxRadix {
+		return fmt.Errorf("alphabet must contain at most %d characters", fpeMaxRadix)
+	}
+
+	t.index = make(map[rune]int, len(t.alphabet))
+	for i, r := range t.alphabet {
+		if _, ok := t.index[r]; ok {
+			return fmt.Errorf("alphabet contains duplicate character %q", r)
+		}
+		t.index[r] = i
+	}
+
+	t.pattern = nil
+	if t.Pattern != "" {
+		re, err := regexp.Compile("^(?:" + t.Pattern + ")$")
+		if err != nil {
+			return fmt.Errorf("invalid pattern: %w", err)
+		}
+		if re.NumSubexp() == 0 {
+			return fmt.Errorf("pattern must contain at least one capture group")
+		}
+		t.pattern = re
+	}
+
+	return nil
+}
+
+// split returns the numerals to encrypt and the positions (in runes) they
+// were taken from.
+func (t *FPETemplate) split(value []rune) ([]uint16, []int, error) {
+	var positions []int
+	if t.pattern == nil {
+		for i, r := range value {
+			if _, ok := t.index[r]; ok {
+				positions = append(positions, i)
+			}
+		}
+	} else {
+		// Matching is done on the UTF-8 form, so byte offsets have to be
+		// translated back into rune positions.
+		str := string(value)
+		match := t.pattern.FindStringSubmatchIndex(str)
+		if match == nil {
+			return nil, nil, errutil.UserError{Err: "value does not match the template pattern"}
+		}
+		runeAt := make(map[int]int, len(value))
+		offset := 0
+		for i, r := range value {
+			runeAt[offset] = i
+			offset += len(string(r))
+		}
+		for g := 1; g <= t.pattern.NumSubexp(); g++ {
+			start, end := match[2*g], match[2*g+1]
+			if start < 0 {
+				continue
+			}
+			for off := start; off < end; {
+				i := runeAt[off]
+				if _, ok := t.index[value[i]]; !ok {
+					return nil, nil, errutil.UserError{Err: fmt.Sprintf("character %q is not in the template alphabet", value[i])}
+				}
+				positions = append(positions, i)
+				off += len(string(value[i]))
+			}
+		}
+	}
+
+	numerals := make([]uint16, len(positions))
+	for i, pos := range positions {
+		numerals[i] = uint16(t.index[value[pos]])
+	}
+	return numerals, positions, nil
+}
+
+// transform applies the given FPE cipher function to the value, keeping
+// characters outside of the template unchanged.
+func (t *FPETemplate) transform(value string, fn func(radix int, numerals []uint16) ([]uint16, error)) (string, error) {
+	if t.index == nil {
+		if err := t.Validate(); err != nil {
+			return "", errutil.UserError{Err: err.Error()}
+		}
+	}
+
+	runes := []rune(value)
+	numerals, positions, err := t.split(runes)
+	if err != nil {
+		return "", err
+	}
+
+	out, err := fn(len(t.alphabet), numerals)
+	if err != nil {
+		return "", err
+	}
+
+	for i, pos := range positions {
+		runes[pos] = t.alphabet[out[i]]
+	}
+	return string(runes), nil
+}
+
+// EncryptFPE encrypts the base64-encoded plaintext using format preserving
+// encryption. The returned ciphertext has the same length and character set
+// as the plaintext and carries no version information.
+func (p *Policy) EncryptFPE(ver int, tmpl *FPETemplate, tweak []byte, value string) (string, error) {
+	plaintext, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return "", errutil.UserError{Err: err.Error()}
+	}
+
+	switch {
+	case ver == 0:
+		ver = p.LatestVersion
+	case ver < 0:
+		return "", errutil.UserError{Err: "requested version for encryption is negative"}
+	case ver > p.LatestVersion:
+		return "", errutil.UserError{Err: "requested version for encryption is higher than the latest key version"}
+	case ver < p.MinEncryptionVersion:
+		return "", errutil.UserError{Err: "requested version for encryption is less than the minimum encryption key version"}
+	}
+
+	block, err := p.fpeCipher(ver)
+	if err != nil {
+		return "", err
+	}
+
+	return tmpl.transform(string(plaintext), func(radix int, numerals []uint16) ([]uint16, error) {
+		if p.Type == KeyType_AES256_FF3_1 {
+			return ff31(block, tweak, radix, numerals, true)
+		}
+		return ff1(block, tweak, radix, numerals, true)
+	})
+}
+
+// DecryptFPE decrypts a value produced by EncryptFPE with the given key
+// version, returning the base64-encoded plaintext.
+func (p *Policy) DecryptFPE(ver int, tmpl *FPETemplate, tweak []byte, value string) (string, error) {
+	if ver == 0 {
+		// Format preserving ciphertexts don't carry their key version, so
+		// defaulting to the latest version would silently return garbage for
+		// values encrypted before a rotation.
+		if p.LatestVersion > 1 {
+			return "", errutil.UserError{Err: "key_version is required to decrypt format preserving ciphertexts once the key has been rotated"}
+		}
+		ver = p.LatestVersion
+	}
+	if ver < 0 || ver > p.LatestVersion {
+		return "", errutil.UserError{Err: "invalid key version"}
+	}
+	if p.MinDecryptionVersion > 0 && ver < p.MinDecryptionVersion {
+		return "", errutil.UserError{Err: ErrTooOld}
+	}
+
+	block, err := p.fpeCipher(ver)
+	if err != nil {
+		return "", err
+	}
+
+	plaintext, err := tmpl.transform(value, func(radix int, numerals []uint16) ([]uint16, error) {
+		if p.Type == KeyType_AES256_FF3_1 {
+			return ff31(block, tweak, radix, numerals, false)
+		}
+		return ff1(block, tweak, radix, numerals, false)
+	})
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString([]byte(plaintext)), nil
+}
+
+func (p *Policy) fpeCipher(ver int) (cipher.Block, error) {
+	if !p.Type.FPESupported() {
+		return nil, errutil.UserError{Err: fmt.Sprintf("format preserving encryption not supported for key type %v", p.Type)}
+	}
+
+	keyEntry, err := p.safeGetKeyEntry(ver)
+	if err != nil {
+		return nil, err
+	}
+
+	key := keyEntry.Key
+	if p.Type == KeyType_AES256_FF3_1 {
+		// FF3-1 uses the byte-reversed key with the block cipher.
+		key = reverseBytes(key)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errutil.InternalError{Err: err.Error()}
+	}
+	return block, nil
+}
+
+// fpeCheckLength enforces the minimum domain size of SP 800-38G Rev. 1.
+func fpeCheckLength(radix, n, maxLen int) error {
+	minLen := 2
+	for domain := big.NewInt(int64(radix * radix)); domain.Cmp(big.NewInt(fpeMinDomainSize)) < 0; minLen++ {
+		domain.Mul(domain, big.NewInt(int64(radix)))
+	}
+	if n < minLen {
+		return errutil.UserError{Err: fmt.Sprintf("value is too short for format preserving encryption: at least %d characters are required with radix %d", minLen, radix)}
+	}
+	if maxLen > 0 && n > maxLen {
+		return errutil.UserError{Err: fmt.Sprintf("value is too long for format preserving encryption: at most %d characters are supported with radix %d", maxLen, radix)}
+	}
+	return nil
+}
+
+// ff1 implements the FF1 mode of NIST SP 800-38G.
+func ff1(block cipher.Block, tweak []byte, radix int, x []uint16, encrypt bool) ([]uint16, error) {
+	n := len(x)
+	if err := fpeCheckLength(radix, n, 0); err != nil {
+		return nil, err
+	}
+
+	u := n / 2
+	v := n - u
+	a, b := x[:u], x[u:]
+
+	bigRadix := big.NewInt(int64(radix))
+	maxV := new(big.Int).Exp(bigRadix, big.NewInt(int64(v)), nil)
+	byteLen := (new(big.Int).Sub(maxV, big.NewInt(1)).BitLen() + 7) / 8
+	d := 4*((byteLen+3)/4) + 4
+
+	p := make([]byte, aes.BlockSize)
+	p[0], p[1], p[2] = 1, 2, 1
+	p[3], p[4], p[5] = byte(radix>>16), byte(radix>>8), byte(radix)
+	p[6] = 10
+	p[7] = byte(u)
+	binary.BigEndian.PutUint32(p[8:], uint32(n))
+	binary.BigEndian.PutUint32(p[12:], uint32(len(tweak)))
+
+	padLen := (16 - (len(tweak)+byteLen+1)%16) % 16
+	q := make([]byte, len(tweak)+padLen+1+byteLen)
+	copy(q, tweak)
+
+	modU := new(big.Int).Exp(bigRadix, big.NewInt(int64(u)), nil)
+	modV := maxV
+
+	numA := fpeNum(a, radix)
+	numB := fpeNum(b, radix)
+	for round := 0; round < 10; round++ {
+		i := round
+		if !encrypt {
+			i = 9 - round
+		}
+
+		// On decryption the halves are processed in the opposite order.
+		in := numB
+		if !encrypt {
+			in = numA
+		}
+		q[len(tweak)+padLen] = byte(i)
+		in.FillBytes(q[len(q)-byteLen:])
+
+		y := new(big.Int).SetBytes(ff1PRF(block, p, q, d))
+
+		mod := modU
+		if i%2 == 1 {
+			mod = modV
+		}
+
+		c := new(big.Int)
+		if encrypt {
+			c.Add(numA, y)
+		} else {
+			c.Sub(numB, y)
+		}
+		c.Mod(c, mod)
+
+		if encrypt {
+			numA, numB = numB, c
+		} else {
+			numA, numB = c, numA
+		}
+	}
+
+	out := append(fpeStr(numA, radix, u), fpeStr(numB, radix, v)...)
+	return out, nil
+}
+
+// ff1PRF computes the CBC-MAC of P || Q and expands it to d bytes.
+func ff1PRF(block cipher.Block, p, q []byte, d int) []byte {
+	r := make([]byte, aes.BlockSize)
+	for _, in := range [][]byte{p, q} {
+		for off := 0; off < len(in); off += aes.BlockSize {
+			for j := 0; j < aes.BlockSize; j++ {
+				r[j] ^= in[off+j]
+			}
+			block.Encrypt(r, r)
+		}
+	}
+
+	s := make([]byte, 0, d+aes.BlockSize)
+	s = append(s, r...)
+	tmp := make([]byte, aes.BlockSize)
+	for j := 1; len(s) < d; j++ {
+		copy(tmp, r)
+		ctr := binary.BigEndian.Uint64(tmp[8:]) ^ uint64(j)
+		binary.BigEndian.PutUint64(tmp[8:], ctr)
+		block.Encrypt(tmp, tmp)
+		s = append(s, tmp...)
+	}
+	return s[:d]
+}
+
+// ff31 implements the FF3-1 mode of NIST SP 800-38G Rev. 1. The block
+// cipher must have been created with the byte-reversed key.
+func ff31(block cipher.Block, tweak []byte, radix int, x []uint16, encrypt bool) ([]uint16, error) {
+	if len(tweak) == 0 {
+		tweak = make([]byte, FF31TweakSize)
+	}
+	if len(tweak) != FF31TweakSize {
+		return nil, errutil.UserError{Err: fmt.Sprintf("FF3-1 tweak must be exactly %d bytes", FF31TweakSize)}
+	}
+
+	// Split the 56-bit tweak into the two 32-bit halves.
+	tl := []byte{tweak[0], tweak[1], tweak[2], tweak[3] & 0xf0}
+	tr := []byte{tweak[4], tweak[5], tweak[6], tweak[3] << 4}
+	return ff3(block, tl, tr, radix, x, encrypt)
+}
+
+// ff3 is the Feistel network shared by FF3 and FF3-1, taking the tweak
+// already split into its left and right halves.
+func ff3(block cipher.Block, tl, tr []byte, radix int, x []uint16, encrypt bool) ([]uint16, error) {
+	n := len(x)
+	maxLen := 2 * int(math.Floor(96/math.Log2(float64(radix))))
+	if err := fpeCheckLength(radix, n, maxLen); err != nil {
+		return nil, err
+	}
+
+	u := (n + 1) / 2
+	v := n - u
+
+	bigRadix := big.NewInt(int64(radix))
+	modU := new(big.Int).Exp(bigRadix, big.NewInt(int64(u)), nil)
+	modV := new(big.Int).Exp(bigRadix, big.NewInt(int64(v)), nil)
+
+	// FF3 interprets numeral strings least significant numeral first.
+	numA := fpeNum(reverseNumerals(x[:u]), radix)
+	numB := fpeNum(reverseNumerals(x[u:]), radix)
+
+	p := make([]byte, aes.BlockSize)
+	for round := 0; round < 8; round++ {
+		i := round
+		if !encrypt {
+			i = 7 - round
+		}
+
+		w, mod := tr, modU
+		if i%2 == 1 {
+			w, mod = tl, modV
+		}
+
+		in := numB
+		if !encrypt {
+			in = numA
+		}
+		copy(p, w)
+		p[3] ^= byte(i)
+		for j := 4; j < aes.BlockSize; j++ {
+			p[j] = 0
+		}
+		in.FillBytes(p[4:])
+
+		s := reverseBytes(p)
+		block.Encrypt(s, s)
+		y := new(big.Int).SetBytes(reverseBytes(s))
+
+		c := new(big.Int)
+		if encrypt {
+			c.Add(numA, y)
+		} else {
+			c.Sub(numB, y)
+		}
+		c.Mod(c, mod)
+
+		if encrypt {
+			numA, numB = numB, c
+		} else {
+			numA, numB = c, numA
+		}
+	}
+
+	out := append(reverseNumerals(fpeStr(numA, radix, u)), reverseNumerals(fpeStr(numB, radix, v))...)
+	return out, nil
+}
+
+// fpeNum returns the number represented by the numeral string, most
+// significant numeral first.
+func fpeNum(x []uint16, radix int) *big.Int {
+	r := big.NewInt(int64(radix))
+	n := new(big.Int)
+	for _, d := range x {
+		n.Mul(n, r)
+		n.Add(n, big.NewInt(int64(d)))
+	}
+	return n
+}
+
+// fpeStr returns the m-numeral representation of n, most significant
+// numeral first.
+func fpeStr(n *big.Int, radix, m int) []uint16 {
+	r := big.NewInt(int64(radix))
+	n = new(big.Int).Set(n)
+	out := make([]uint16, m)
+	d := new(big.Int)
+	for i := m - 1; i >= 0; i-- {
+		n.DivMod(n, r, d)
+		out[i] = uint16(d.Int64())
+	}
+	return out
+}
+
+func reverseNumerals(x []uint16) []uint16 {
+	out := make([]uint16, len(x))
+	for i, d := range x {
+		out[len(x)-1-i] = d
+	}
+	return out
+}
+
+func reverseBytes(b []byte) []byte {
+	out := make([]byte, len(b))
+	for i, c := range b {
+		out[len(b)-1-i] = c
+	}
+	return out
+}
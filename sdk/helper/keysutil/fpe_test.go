@@ -0,0 +1,176 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package keysutil
+
+import (
+	"crypto/aes"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+const fpeTestAlphabet = "0123456789abcdefghijklmnopqrstuvwxyz"
+
+func fpeTestNumerals(t *testing.T, s string) []uint16 {
+	t.Helper()
+	out := make([]uint16, len(s))
+	for i, c := range s {
+		idx := strings.IndexRune(fpeTestAlphabet, c)
+		if idx < 0 {
+			t.Fatalf("invalid numeral %q", c)
+		}
+		out[i] = uint16(idx)
+	}
+	return out
+}
+
+func fpeTestString(x []uint16) string {
+	var sb strings.Builder
+	for _, d := range x {
+		sb.WriteByte(fpeTestAlphabet[d])
+	}
+	return sb.String()
+}
+
+// Sample vectors from NIST SP 800-38G.
+func TestFF1_NISTVectors(t *testing.T) {
+	cases := []struct {
+		key, tweak    string
+		radix         int
+		plain, cipher string
+	}{
+		{"2B7E151628AED2A6ABF7158809CF4F3C", "", 10, "0123456789", "2433477484"},
+		{"2B7E151628AED2A6ABF7158809CF4F3C", "39383736353433323130", 10, "0123456789", "6124200773"},
+		{"2B7E151628AED2A6ABF7158809CF4F3C", "3737373770717273373737", 36, "0123456789abcdefghi", "a9tv40mll9kdu509eum"},
+		{"2B7E151628AED2A6ABF7158809CF4F3CEF4359D8D580AA4F7F036D6F04FC6A94", "", 10, "0123456789", "6657667009"},
+		{"2B7E151628AED2A6ABF7158809CF4F3CEF4359D8D580AA4F7F036D6F04FC6A94", "39383736353433323130", 10, "0123456789", "1001623463"},
+		{"2B7E151628AED2A6ABF7158809CF4F3CEF4359D8D580AA4F7F036D6F04FC6A94", "3737373770717273373737", 36, "0123456789abcdefghi", "xs8a0azh2avyalyzuwd"},
+	}
+
+	for i, tc := range cases {
+		key, _ := hex.DecodeString(tc.key)
+		tweak, _ := hex.DecodeString(tc.tweak)
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ct, err := ff1(block, tweak, tc.radix, fpeTestNumerals(t, tc.plain), true)
+		if err != nil {
+			t.Fatalf("case %d: %v", i, err)
+		}
+		if got := fpeTestString(ct); got != tc.cipher {
+			t.Fatalf("case %d: encrypt: got %s, expected %s", i, got, tc.cipher)
+		}
+
+		pt, err := ff1(block, tweak, tc.radix, ct, false)
+		if err != nil {
+			t.Fatalf("case %d: %v", i, err)
+		}
+		if got := fpeTestString(pt); got != tc.plain {
+			t.Fatalf("case %d: decrypt: got %s, expected %s", i, got, tc.plain)
+		}
+	}
+}
+
+// FF3-1 only changes how the tweak is split, so the Feistel network is
+// checked against the FF3 sample vectors from NIST SP 800-38G.
+func TestFF3_NISTVectors(t *testing.T) {
+	cases := []struct {
+		key, tweak    string
+		radix         int
+		plain, cipher string
+	}{
+		{"EF4359D8D580AA4F7F036D6F04FC6A94", "D8E7920AFA330A73", 10, "890121234567890000", "750918814058654607"},
+		{"EF4359D8D580AA4F7F036D6F04FC6A94", "9A768A92F60E12D8", 10, "890121234567890000", "018989839189395384"},
+		{"EF4359D8D580AA4F7F036D6F04FC6A94", "D8E7920AFA330A73", 10, "89012123456789000000789000000", "48598367162252569629397416226"},
+		{"EF4359D8D580AA4F7F036D6F04FC6A94", "0000000000000000", 10, "89012123456789000000789000000", "34695224821734535122613701434"},
+		{"EF4359D8D580AA4F7F036D6F04FC6A94", "9A768A92F60E12D8", 26, "0123456789abcdefghi", "g2pk40i992fn20cjakb"},
+	}
+
+	for i, tc := range cases {
+		key, _ := hex.DecodeString(tc.key)
+		tweak, _ := hex.DecodeString(tc.tweak)
+		block, err := aes.NewCipher(reverseBytes(key))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ct, err := ff3(block, tweak[:4], tweak[4:], tc.radix, fpeTestNumerals(t, tc.plain), true)
+		if err != nil {
+			t.Fatalf("case %d: %v", i, err)
+		}
+		if got := fpeTestString(ct); got != tc.cipher {
+			t.Fatalf("case %d: encrypt: got %s, expected %s", i, got, tc.cipher)
+		}
+
+		pt, err := ff3(block, tweak[:4], tweak[4:], tc.radix, ct, false)
+		if err != nil {
+			t.Fatalf("case %d: %v", i, err)
+		}
+		if got := fpeTestString(pt); got != tc.plain {
+			t.Fatalf("case %d: decrypt: got %s, expected %s", i, got, tc.plain)
+		}
+	}
+}
+
+func TestFPETemplate(t *testing.T) {
+	key, _ := hex.DecodeString("2B7E151628AED2A6ABF7158809CF4F3CEF4359D8D580AA4F7F036D6F04FC6A94")
+
+	for _, keyType := range []KeyType{KeyType_AES256_FF1, KeyType_AES256_FF3_1} {
+		p := &Policy{
+			Name:          "fpe",
+			Type:          keyType,
+			LatestVersion: 1,
+			Keys:          keyEntryMap{"1": {Key: key}},
+		}
+
+		for _, tc := range []struct {
+			tmpl  FPETemplate
+			value string
+			// kept is the ciphertext with encrypted characters replaced by
+			// asterisks.
+			kept string
+		}{
+			// Separators are kept in place.
+			{FPETemplate{Alphabet: "0123456789"}, "4111-1111-1111-1111", "****-****-****-****"},
+			// Only the capture groups are encrypted.
+			{FPETemplate{Alphabet: "0123456789", Pattern: `(\d{4})-(\d{4})-(\d{4})-\d{4}`}, "4111-2222-3333-1111", "****-****-****-1111"},
+			{FPETemplate{Alphabet: "abcdefghijklmnopqrstuvwxyzéü"}, "über-café", "****-****"},
+		} {
+			if err := tc.tmpl.Validate(); err != nil {
+				t.Fatal(err)
+			}
+
+			encoded := base64.StdEncoding.EncodeToString([]byte(tc.value))
+			ct, err := p.EncryptFPE(0, &tc.tmpl, nil, encoded)
+			if err != nil {
+				t.Fatalf("%v: %v", keyType, err)
+			}
+			if len([]rune(ct)) != len([]rune(tc.value)) || ct == tc.value {
+				t.Fatalf("%v: unexpected ciphertext %q for %q", keyType, ct, tc.value)
+			}
+			for i, r := range []rune(tc.kept) {
+				if r != '*' && []rune(ct)[i] != r {
+					t.Fatalf("%v: ciphertext %q does not match %q", keyType, ct, tc.kept)
+				}
+			}
+
+			pt, err := p.DecryptFPE(0, &tc.tmpl, nil, ct)
+			if err != nil {
+				t.Fatalf("%v: %v", keyType, err)
+			}
+			if pt != encoded {
+				t.Fatalf("%v: decrypted %q, expected %q", keyType, pt, encoded)
+			}
+		}
+
+		// Values below the minimum domain size are rejected.
+		tmpl := FPETemplate{Alphabet: "0123456789"}
+		if _, err := p.EncryptFPE(0, &tmpl, nil, base64.StdEncoding.EncodeToString([]byte("12345"))); err == nil {
+			t.Fatalf("%v: expected error for short input", keyType)
+		}
+	}
+}
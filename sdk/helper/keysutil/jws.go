@@ -0,0 +1,203 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package keysutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"strconv"
+
+	"github.com/hashicorp/vault/sdk/helper/errutil"
+)
+
+// jwsAlgorithmParams describes how a JOSE "alg" value maps onto the
+// signing options used by SignWithOptions.
+type jwsAlgorithmParams struct {
+	keyTypes      []KeyType
+	hashAlgorithm HashType
+	sigAlgorithm  string
+}
+
+var jwsAlgorithms = map[string]jwsAlgorithmParams{
+	"ES256": {keyTypes: []KeyType{KeyType_ECDSA_P256}, hashAlgorithm: HashTypeSHA2256},
+	"ES384": {keyTypes: []KeyType{KeyType_ECDSA_P384}, hashAlgorithm: HashTypeSHA2384},
+	"ES512": {keyTypes: []KeyType{KeyType_ECDSA_P521}, hashAlgorithm: HashTypeSHA2512},
+	"EdDSA": {keyTypes: []KeyType{KeyType_ED25519}, hashAlgorithm: HashTypeNone},
+	"RS256": {keyTypes: rsaKeyTypes, hashAlgorithm: HashTypeSHA2256, sigAlgorithm: "pkcs1v15"},
+	"RS384": {keyTypes: rsaKeyTypes, hashAlgorithm: HashTypeSHA2384, sigAlgorithm: "pkcs1v15"},
+	"RS512": {keyTypes: rsaKeyTypes, hashAlgorithm: HashTypeSHA2512, sigAlgorithm: "pkcs1v15"},
+	"PS256": {keyTypes: rsaKeyTypes, hashAlgorithm: HashTypeSHA2256, sigAlgorithm: "pss"},
+	"PS384": {keyTypes: rsaKeyTypes, hashAlgorithm: HashTypeSHA2384, sigAlgorithm: "pss"},
+	"PS512": {keyTypes: rsaKeyTypes, hashAlgorithm: HashTypeSHA2512, sigAlgorithm: "pss"},
+}
+
+var rsaKeyTypes = []KeyType{KeyType_RSA2048, KeyType_RSA3072, KeyType_RSA4096}
+
+// DefaultJWSAlgorithm returns the JOSE "alg" used for the key type when none
+// is requested, or an empty string if JWS is not supported.
+func (kt KeyType) DefaultJWSAlgorithm() string {
+	switch kt {
+	case KeyType_ECDSA_P256:
+		return "ES256"
+	case KeyType_ECDSA_P384:
+		return "ES384"
+	case KeyType_ECDSA_P521:
+		return "ES512"
+	case KeyType_ED25519:
+		return "EdDSA"
+	case KeyType_RSA2048, KeyType_RSA3072, KeyType_RSA4096:
+		return "RS256"
+	}
+	return ""
+}
+
+func (p *Policy) jwsParams(alg string) (*jwsAlgorithmParams, error) {
+	if p.Type.DefaultJWSAlgorithm() == "" {
+		return nil, errutil.UserError{Err: fmt.Sprintf("JWS not supported for key type %v", p.Type)}
+	}
+	if p.Derived {
+		return nil, errutil.UserError{Err: "JWS not supported for keys with derivation enabled"}
+	}
+
+	params, ok := jwsAlgorithms[alg]
+	if !ok {
+		return nil, errutil.UserError{Err: fmt.Sprintf("unsupported JWS algorithm %q", alg)}
+	}
+	for _, kt := range params.keyTypes {
+		if kt == p.Type {
+			return &params, nil
+		}
+	}
+	return nil, errutil.UserError{Err: fmt.Sprintf("JWS algorithm %q cannot be used with key type %v", alg, p.Type)}
+}
+
+func (p *Policy) jwsSigningInput(params *jwsAlgorithmParams, signingInput []byte) ([]byte, *SigningOptions) {
+	options := &SigningOptions{
+		HashAlgorithm: params.hashAlgorithm,
+		Marshaling:    MarshalingTypeJWS,
+		SigAlgorithm:  params.sigAlgorithm,
+		SaltLength:    rsa.PSSSaltLengthEqualsHash,
+	}
+
+	if params.hashAlgorithm == HashTypeNone {
+		return signingInput, options
+	}
+
+	hf := HashFuncMap[params.hashAlgorithm]()
+	hf.Write(signingInput)
+	return hf.Sum(nil), options
+}
+
+// SignJWS signs the JWS signing input (the encoded protected header and
+// payload joined by a period) with the given JOSE algorithm, returning the
+// raw signature bytes in JWS format.
+func (p *Policy) SignJWS(ver int, alg string, signingInput []byte) ([]byte, error) {
+	params, err := p.jwsParams(alg)
+	if err != nil {
+		return nil, err
+	}
+
+	if ver == 0 {
+		ver = p.LatestVersion
+	}
+
+	input, options := p.jwsSigningInput(params, signingInput)
+	sig, err := p.SignWithOptions(ver, nil, input, options)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := p.getVersionPrefix(ver)
+	return base64.RawURLEncoding.DecodeString(sig.Signature[len(prefix):])
+}
+
+// VerifyJWS verifies a raw JWS signature over the signing input using the
+// given key version and JOSE algorithm.
+func (p *Policy) VerifyJWS(ver int, alg string, signingInput, sig []byte) (bool, error) {
+	params, err := p.jwsParams(alg)
+	if err != nil {
+		return false, err
+	}
+
+	input, options := p.jwsSigningInput(params, signingInput)
+	return p.VerifySignatureWithOptions(nil, input, p.getVersionPrefix(ver)+base64.RawURLEncoding.EncodeToString(sig), options)
+}
+
+// PublicJWK returns the public key of the given version as a JSON Web Key
+// (RFC 7517), with the key version as its "kid".
+func (p *Policy) PublicJWK(ver int) (map[string]interface{}, error) {
+	if p.Type.DefaultJWSAlgorithm() == "" {
+		return nil, errutil.UserError{Err: fmt.Sprintf("JWS not supported for key type %v", p.Type)}
+	}
+	if p.Derived {
+		return nil, errutil.UserError{Err: "JWS not supported for keys with derivation enabled"}
+	}
+
+	keyEntry, err := p.safeGetKeyEntry(ver)
+	if err != nil {
+		return nil, err
+	}
+
+	jwk := map[string]interface{}{
+		"kid": strconv.Itoa(ver),
+		"use": "sig",
+	}
+
+	switch p.Type {
+	case KeyType_ECDSA_P256, KeyType_ECDSA_P384, KeyType_ECDSA_P521:
+		var curve elliptic.Curve
+		switch p.Type {
+		case KeyType_ECDSA_P384:
+			curve = elliptic.P384()
+		case KeyType_ECDSA_P521:
+			curve = elliptic.P521()
+		default:
+			curve = elliptic.P256()
+		}
+		pub := &ecdsa.PublicKey{Curve: curve, X: keyEntry.EC_X, Y: keyEntry.EC_Y}
+		size := (pub.Curve.Params().BitSize + 7) / 8
+
+		jwk["kty"] = "EC"
+		jwk["crv"] = pub.Curve.Params().Name
+		jwk["x"] = base64.RawURLEncoding.EncodeToString(pub.X.FillBytes(make([]byte, size)))
+		jwk["y"] = base64.RawURLEncoding.EncodeToString(pub.Y.FillBytes(make([]byte, size)))
+		jwk["alg"] = p.Type.DefaultJWSAlgorithm()
+
+	case KeyType_ED25519:
+		raw, err := base64.StdEncoding.DecodeString(keyEntry.FormattedPublicKey)
+		if err != nil {
+			return nil, err
+		}
+		if len(raw) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid ed25519 public key for version %d", ver)
+		}
+
+		jwk["kty"] = "OKP"
+		jwk["crv"] = "Ed25519"
+		jwk["x"] = base64.RawURLEncoding.EncodeToString(raw)
+		jwk["alg"] = p.Type.DefaultJWSAlgorithm()
+
+	case KeyType_RSA2048, KeyType_RSA3072, KeyType_RSA4096:
+		pub := keyEntry.RSAPublicKey
+		if keyEntry.RSAKey != nil {
+			pub = &keyEntry.RSAKey.PublicKey
+		}
+		if pub == nil {
+			return nil, fmt.Errorf("no public key for version %d", ver)
+		}
+
+		// RSA keys may be used with several algorithms, so "alg" is left
+		// unset.
+		jwk["kty"] = "RSA"
+		jwk["n"] = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+		jwk["e"] = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+	}
+
+	return jwk, nil
+}
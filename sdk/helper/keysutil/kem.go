@@ -0,0 +1,201 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package keysutil
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/asn1"
+	"fmt"
+	"io"
+
+	"github.com/cloudflare/circl/kem"
+	"github.com/cloudflare/circl/kem/mlkem/mlkem1024"
+	"github.com/cloudflare/circl/kem/mlkem/mlkem512"
+	"github.com/cloudflare/circl/kem/mlkem/mlkem768"
+	"github.com/cloudflare/circl/kem/xwing"
+	"github.com/hashicorp/vault/sdk/helper/errutil"
+)
+
+// kemParams describes a key encapsulation key type. As with post-quantum
+// signing keys, private keys are stored as the scheme's seed.
+type kemParams struct {
+	scheme kem.Scheme
+	oid    asn1.ObjectIdentifier
+}
+
+// ML-KEM OIDs are from the NIST Computer Security Objects Register; the
+// X-Wing OID is the one assigned by draft-connolly-cfrg-xwing-kem.
+var kemSchemes = map[KeyType]kemParams{
+	KeyType_ML_KEM_512:               {mlkem512.Scheme(), asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 4, 1}},
+	KeyType_ML_KEM_768:               {mlkem768.Scheme(), asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 4, 2}},
+	KeyType_ML_KEM_1024:              {mlkem1024.Scheme(), asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 4, 3}},
+	KeyType_HYBRID_X25519_ML_KEM_768: {xwing.Scheme(), asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 62253, 25722}},
+}
+
+// KeyEncapsulationSupported returns whether the key type is an ML-KEM or
+// hybrid X25519 + ML-KEM (X-Wing) key.
+func (kt KeyType) KeyEncapsulationSupported() bool {
+	_, ok := kemSchemes[kt]
+	return ok
+}
+
+// generateKEMKey creates a new seed for the key type and fills in the key
+// entry from it.
+func (ke *KeyEntry) generateKEMKey(kt KeyType, randReader io.Reader) error {
+	seed := make([]byte, kemSchemes[kt].scheme.SeedSize())
+	if _, err := io.ReadFull(randReader, seed); err != nil {
+		return err
+	}
+	return ke.setKEMSeed(kt, seed)
+}
+
+// setKEMSeed stores the private key seed and the corresponding public key.
+func (ke *KeyEntry) setKEMSeed(kt KeyType, seed []byte) error {
+	params := kemSchemes[kt]
+	if len(seed) != params.scheme.SeedSize() {
+		return fmt.Errorf("invalid key size %d bytes for key type %s", len(seed), kt)
+	}
+
+	pub, _ := params.scheme.DeriveKeyPair(seed)
+	pubBytes, err := pub.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	formatted, err := marshalPQPublicKeyPEM(params.oid, pubBytes)
+	if err != nil {
+		return err
+	}
+
+	ke.Key = seed
+	ke.FormattedPublicKey = formatted
+	return nil
+}
+
+// parseKEMPublicKey parses a PEM-encoded SubjectPublicKeyInfo for a key
+// encapsulation key, returning the raw public key.
+func parseKEMPublicKey(kt KeyType, key []byte) ([]byte, error) {
+	params := kemSchemes[kt]
+	pub, err := unmarshalPQPublicKeyPEM(kt, params.oid, key)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := params.scheme.UnmarshalBinaryPublicKey(pub); err != nil {
+		return nil, fmt.Errorf("error parsing public key: %w", err)
+	}
+
+	return pub, nil
+}
+
+// kemEncrypt encapsulates a fresh shared secret to the key entry's public
+// key, using randReader for the encapsulation seed and nonce, and uses it to
+// seal the plaintext with AES-256-GCM. The result is the
+// KEM ciphertext followed by the GCM nonce and sealed plaintext.
+func (ke *KeyEntry) kemEncrypt(kt KeyType, plaintext, additionalData []byte, randReader io.Reader) ([]byte, error) {
+	params := kemSchemes[kt]
+	raw, err := parseKEMPublicKey(kt, []byte(ke.FormattedPublicKey))
+	if err != nil {
+		return nil, errutil.InternalError{Err: err.Error()}
+	}
+	pub, err := params.scheme.UnmarshalBinaryPublicKey(raw)
+	if err != nil {
+		return nil, errutil.InternalError{Err: err.Error()}
+	}
+
+	seed := make([]byte, params.scheme.EncapsulationSeedSize())
+	if _, err := io.ReadFull(randReader, seed); err != nil {
+		return nil, errutil.InternalError{Err: fmt.Sprintf("failed to generate encapsulation seed: %v", err)}
+	}
+	encapsulated, sharedSecret, err := params.scheme.EncapsulateDeterministically(pub, seed)
+	if err != nil {
+		return nil, errutil.InternalError{Err: fmt.Sprintf("failed to encapsulate key: %v", err)}
+	}
+
+	aead, err := kemAEAD(sharedSecret)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(randReader, nonce); err != nil {
+		return nil, errutil.InternalError{Err: fmt.Sprintf("failed to generate nonce: %v", err)}
+	}
+
+	out := make([]byte, 0, len(encapsulated)+len(nonce)+len(plaintext)+aead.Overhead())
+	out = append(out, encapsulated...)
+	out = append(out, nonce...)
+	return aead.Seal(out, nonce, plaintext, additionalData), nil
+}
+
+// kemDecrypt reverses kemEncrypt using the key entry's private key seed.
+func (ke *KeyEntry) kemDecrypt(kt KeyType, ciphertext, additionalData []byte) ([]byte, error) {
+	params := kemSchemes[kt]
+	if len(ke.Key) != params.scheme.SeedSize() {
+		return nil, errutil.InternalError{Err: "cannot decrypt ciphertext, key version does not have a private counterpart"}
+	}
+
+	ctSize := params.scheme.CiphertextSize()
+	if len(ciphertext) < ctSize {
+		return nil, errutil.UserError{Err: "invalid ciphertext length"}
+	}
+
+	_, priv := params.scheme.DeriveKeyPair(ke.Key)
+	sharedSecret, err := params.scheme.Decapsulate(priv, ciphertext[:ctSize])
+	if err != nil {
+		return nil, errutil.UserError{Err: fmt.Sprintf("failed to decapsulate key: %v", err)}
+	}
+
+	aead, err := kemAEAD(sharedSecret)
+	if err != nil {
+		return nil, err
+	}
+	sealed := ciphertext[ctSize:]
+	if len(sealed) < aead.NonceSize() {
+		return nil, errutil.UserError{Err: "invalid ciphertext length"}
+	}
+
+	plain, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], additionalData)
+	if err != nil {
+		return nil, errutil.UserError{Err: "invalid ciphertext: unable to decrypt"}
+	}
+	return plain, nil
+}
+
+func kemAEAD(sharedSecret []byte) (cipher.AEAD, error) {
+	aesCipher, err := aes.NewCipher(sharedSecret)
+	if err != nil {
+		return nil, errutil.InternalError{Err: err.Error()}
+	}
+	gcm, err := cipher.NewGCM(aesCipher)
+	if err != nil {
+		return nil, errutil.InternalError{Err: err.Error()}
+	}
+	return gcm, nil
+}
+
+// kemRandomReader returns the random source supplied through a
+// RandomReaderFactory, falling back to crypto/rand.
+func kemRandomReader(factories []interface{}) io.Reader {
+	for _, rawFactory := range factories {
+		if factory, ok := rawFactory.(RandomReaderFactory); ok {
+			return factory.GetRandomReader()
+		}
+	}
+	return rand.Reader
+}
+
+// kemAssociatedData returns the additional authenticated data supplied
+// through an AssociatedDataFactory, if any.
+func kemAssociatedData(factories []interface{}) ([]byte, error) {
+	for index, rawFactory := range factories {
+		if factory, ok := rawFactory.(AssociatedDataFactory); ok {
+			aad, err := factory.GetAssociatedData()
+			if err != nil {
+				return nil, errutil.InternalError{Err: fmt.Sprintf("unable to get associated_data/additional_data from factory[%d]: %v", index, err)}
+			}
+			return aad, nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,149 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package keysutil
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+
+	"github.com/hashicorp/vault/sdk/helper/errutil"
+)
+
+// ecdhCurve returns the crypto/ecdh curve matching the policy's key type.
+func (kt KeyType) ecdhCurve() (ecdh.Curve, error) {
+	switch kt {
+	case KeyType_ECDSA_P256:
+		return ecdh.P256(), nil
+	case KeyType_ECDSA_P384:
+		return ecdh.P384(), nil
+	case KeyType_ECDSA_P521:
+		return ecdh.P521(), nil
+	case KeyType_X25519:
+		return ecdh.X25519(), nil
+	}
+
+	return nil, fmt.Errorf("key agreement not supported for key type %v", kt)
+}
+
+// DeriveSharedSecret performs an ECDH (or X25519) key agreement between the
+// private key of the given version and the supplied peer public key. The
+// peer key may be a PEM or DER-encoded PKIX public key; for X25519 keys a raw
+// 32-byte public key is accepted as well. The raw shared secret is returned
+// and it is up to the caller to run it through a suitable KDF.
+func (p *Policy) DeriveSharedSecret(ver int, peerPublicKey []byte) ([]byte, error) {
+	if !p.Type.KeyAgreementSupported() {
+		return nil, errutil.UserError{Err: fmt.Sprintf("key agreement not supported for key type %v", p.Type)}
+	}
+
+	switch {
+	case ver == 0:
+		ver = p.LatestVersion
+	case ver < 0:
+		return nil, errutil.UserError{Err: "requested version for key agreement is negative"}
+	case ver > p.LatestVersion:
+		return nil, errutil.UserError{Err: "requested version for key agreement is higher than the latest key version"}
+	case p.MinEncryptionVersion > 0 && ver < p.MinEncryptionVersion:
+		return nil, errutil.UserError{Err: "requested version for key agreement is less than the minimum encryption key version"}
+	}
+
+	keyEntry, err := p.safeGetKeyEntry(ver)
+	if err != nil {
+		return nil, err
+	}
+
+	if keyEntry.IsPrivateKeyMissing() {
+		return nil, errutil.UserError{Err: "requested version for key agreement does not contain a private part"}
+	}
+
+	curve, err := p.Type.ecdhCurve()
+	if err != nil {
+		return nil, err
+	}
+
+	peerKey, err := parseKeyAgreementPublicKey(curve, peerPublicKey)
+	if err != nil {
+		return nil, errutil.UserError{Err: err.Error()}
+	}
+
+	var privKey *ecdh.PrivateKey
+	switch p.Type {
+	case KeyType_ECDSA_P256, KeyType_ECDSA_P384, KeyType_ECDSA_P521:
+		var ellipticCurve elliptic.Curve
+		switch p.Type {
+		case KeyType_ECDSA_P384:
+			ellipticCurve = elliptic.P384()
+		case KeyType_ECDSA_P521:
+			ellipticCurve = elliptic.P521()
+		default:
+			ellipticCurve = elliptic.P256()
+		}
+
+		ecKey := &ecdsa.PrivateKey{
+			PublicKey: ecdsa.PublicKey{
+				Curve: ellipticCurve,
+				X:     keyEntry.EC_X,
+				Y:     keyEntry.EC_Y,
+			},
+			D: keyEntry.EC_D,
+		}
+		privKey, err = ecKey.ECDH()
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert private key for key agreement: %w", err)
+		}
+
+	case KeyType_X25519:
+		privKey, err = ecdh.X25519().NewPrivateKey(keyEntry.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load X25519 private key: %w", err)
+		}
+	}
+
+	secret, err := privKey.ECDH(peerKey)
+	if err != nil {
+		return nil, errutil.UserError{Err: fmt.Sprintf("failed to perform key agreement: %v", err)}
+	}
+
+	return secret, nil
+}
+
+func parseKeyAgreementPublicKey(curve ecdh.Curve, raw []byte) (*ecdh.PublicKey, error) {
+	if len(raw) == 0 {
+		return nil, fmt.Errorf("missing peer public key")
+	}
+
+	der := raw
+	if block, _ := pem.Decode(raw); block != nil {
+		der = block.Bytes
+	} else if curve == ecdh.X25519() && len(raw) == 32 {
+		return curve.NewPublicKey(raw)
+	}
+
+	parsed, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse peer public key: %w", err)
+	}
+
+	var pubKey *ecdh.PublicKey
+	switch key := parsed.(type) {
+	case *ecdsa.PublicKey:
+		pubKey, err = key.ECDH()
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert peer public key: %w", err)
+		}
+	case *ecdh.PublicKey:
+		pubKey = key
+	default:
+		return nil, fmt.Errorf("unsupported peer public key type %T", parsed)
+	}
+
+	if pubKey.Curve() != curve {
+		return nil, fmt.Errorf("peer public key curve does not match the key's curve")
+	}
+
+	return pubKey, nil
+}
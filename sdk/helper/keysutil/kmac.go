@@ -0,0 +1,78 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package keysutil
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/hashicorp/vault/sdk/helper/errutil"
+	"golang.org/x/crypto/sha3"
+)
+
+const (
+	KMACAlgorithm128 = "kmac128"
+	KMACAlgorithm256 = "kmac256"
+)
+
+// KMACAlgorithm returns whether the given HMAC algorithm name refers to
+// KMAC rather than an HMAC hash function.
+func KMACAlgorithm(algorithm string) bool {
+	return algorithm == KMACAlgorithm128 || algorithm == KMACAlgorithm256
+}
+
+// ComputeKMAC computes a KMAC (NIST SP 800-185) of the input with an empty
+// customization string. KMAC128 produces 256 bits and KMAC256 512 bits of
+// output, twice their security strength.
+func ComputeKMAC(algorithm string, key, input []byte) ([]byte, error) {
+	var h sha3.ShakeHash
+	var rate, outputSize int
+	switch algorithm {
+	case KMACAlgorithm128:
+		h, rate, outputSize = sha3.NewCShake128([]byte("KMAC"), nil), 168, 32
+	case KMACAlgorithm256:
+		h, rate, outputSize = sha3.NewCShake256([]byte("KMAC"), nil), 136, 64
+	default:
+		return nil, errutil.UserError{Err: fmt.Sprintf("unsupported KMAC algorithm %q", algorithm)}
+	}
+
+	// bytepad(encode_string(K), rate)
+	encodedKey := append(kmacLeftEncode(uint64(len(key))*8), key...)
+	padded := append(kmacLeftEncode(uint64(rate)), encodedKey...)
+	if rem := len(padded) % rate; rem != 0 {
+		padded = append(padded, make([]byte, rate-rem)...)
+	}
+
+	h.Write(padded)
+	h.Write(input)
+	h.Write(kmacRightEncode(uint64(outputSize) * 8))
+
+	out := make([]byte, outputSize)
+	h.Read(out)
+	return out, nil
+}
+
+// kmacLeftEncode implements left_encode from NIST SP 800-185.
+func kmacLeftEncode(x uint64) []byte {
+	encoded := kmacEncode(x)
+	return append([]byte{byte(len(encoded))}, encoded...)
+}
+
+// kmacRightEncode implements right_encode from NIST SP 800-185.
+func kmacRightEncode(x uint64) []byte {
+	encoded := kmacEncode(x)
+	return append(encoded, byte(len(encoded)))
+}
+
+// kmacEncode returns the minimal big-endian encoding of x, which is at least
+// one byte long.
+func kmacEncode(x uint64) []byte {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], x)
+	i := 0
+	for i < 7 && buf[i] == 0 {
+		i++
+	}
+	return buf[i:]
+}
@@ -0,0 +1,39 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package keysutil
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+// TestComputeKMAC checks KMAC against the NIST SP 800-185 samples which use
+// an empty customization string.
+func TestComputeKMAC(t *testing.T) {
+	key, _ := hex.DecodeString("404142434445464748494a4b4c4d4e4f505152535455565758595a5b5c5d5e5f")
+
+	out, err := ComputeKMAC(KMACAlgorithm128, key, []byte{0, 1, 2, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "e5780b0d3ea6f7d3a429c5706aa43a00fadbd7d49628839e3187243f456ee14e"; hex.EncodeToString(out) != expected {
+		t.Fatalf("unexpected KMAC128 output %x", out)
+	}
+
+	input := make([]byte, 200)
+	for i := range input {
+		input[i] = byte(i)
+	}
+	out, err = ComputeKMAC(KMACAlgorithm256, key, input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "75358cf39e41494e949707927cee0af20a3ff553904c86b08f21cc414bcfd691589d27cf5e15369cbbff8b9a4c2eb17800855d0235ff635da82533ec6b759b69"; hex.EncodeToString(out) != expected {
+		t.Fatalf("unexpected KMAC256 output %x", out)
+	}
+
+	if _, err := ComputeKMAC("kmac512", key, input); err == nil {
+		t.Fatal("expected error for unknown algorithm")
+	}
+}
@@ -74,6 +74,8 @@ type LockManager struct {
 	useCache bool
 	cache    Cache
 	keyLocks []*locksutil.LockEntry
+	// usage tracks per-version operation counts, keyed by storage path
+	usage sync.Map
 }
 
 func NewLockManager(useCache bool, cacheSize int) (*LockManager, error) {
@@ -368,7 +370,7 @@ func (lm *LockManager) GetPolicy(ctx context.Context, req PolicyRequest, rand io
 				return nil, false, fmt.Errorf("convergent encryption requires derivation to be enabled")
 			}
 
-		case KeyType_ECDSA_P256, KeyType_ECDSA_P384, KeyType_ECDSA_P521:
+		case KeyType_ECDSA_P256, KeyType_ECDSA_P384, KeyType_ECDSA_P521, KeyType_X25519:
 			if req.Derived || req.Convergent {
 				cleanup()
 				return nil, false, fmt.Errorf("key derivation and convergent encryption not supported for keys of type %v", req.KeyType)
@@ -380,12 +382,17 @@ func (lm *LockManager) GetPolicy(ctx context.Context, req PolicyRequest, rand io
 				return nil, false, fmt.Errorf("convergent encryption not supported for keys of type %v", req.KeyType)
 			}
 
-		case KeyType_RSA2048, KeyType_RSA3072, KeyType_RSA4096:
+		case KeyType_RSA2048, KeyType_RSA3072, KeyType_RSA4096,
+			KeyType_ML_DSA_44, KeyType_ML_DSA_65, KeyType_ML_DSA_87,
+			KeyType_SLH_DSA_SHA2_128S, KeyType_SLH_DSA_SHA2_128F, KeyType_SLH_DSA_SHA2_192S,
+			KeyType_SLH_DSA_SHA2_192F, KeyType_SLH_DSA_SHA2_256S, KeyType_SLH_DSA_SHA2_256F,
+			KeyType_HYBRID_ED25519_ML_DSA_65,
+			KeyType_ML_KEM_512, KeyType_ML_KEM_768, KeyType_ML_KEM_1024, KeyType_HYBRID_X25519_ML_KEM_768:
 			if req.Derived || req.Convergent {
 				cleanup()
 				return nil, false, fmt.Errorf("key derivation and convergent encryption not supported for keys of type %v", req.KeyType)
 			}
-		case KeyType_HMAC:
+		case KeyType_HMAC, KeyType_AES128_CMAC, KeyType_AES256_CMAC, KeyType_AES256_FF1, KeyType_AES256_FF3_1:
 			if req.Derived || req.Convergent {
 				cleanup()
 				return nil, false, fmt.Errorf("key derivation and convergent encryption not supported for keys of type %v", req.KeyType)
@@ -578,6 +585,11 @@ func (lm *LockManager) DeletePolicy(ctx context.Context, storage logical.Storage
 		return errwrap.Wrapf(fmt.Sprintf("error deleting key %q archive: {{err}}", name), err)
 	}
 
+	err = lm.deleteKeyUsage(ctx, storage, p)
+	if err != nil {
+		return errwrap.Wrapf(fmt.Sprintf("error deleting key %q usage: {{err}}", name), err)
+	}
+
 	return nil
 }
 
@@ -9,6 +9,7 @@ import (
 	"crypto"
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/ecdh"
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/hmac"
@@ -68,6 +69,25 @@ const (
 	KeyType_RSA3072
 	KeyType_MANAGED_KEY
 	KeyType_HMAC
+	KeyType_X25519
+	KeyType_AES128_CMAC
+	KeyType_AES256_CMAC
+	KeyType_ML_DSA_44
+	KeyType_ML_DSA_65
+	KeyType_ML_DSA_87
+	KeyType_SLH_DSA_SHA2_128S
+	KeyType_SLH_DSA_SHA2_128F
+	KeyType_SLH_DSA_SHA2_192S
+	KeyType_SLH_DSA_SHA2_192F
+	KeyType_SLH_DSA_SHA2_256S
+	KeyType_SLH_DSA_SHA2_256F
+	KeyType_HYBRID_ED25519_ML_DSA_65
+	KeyType_ML_KEM_512
+	KeyType_ML_KEM_768
+	KeyType_ML_KEM_1024
+	KeyType_HYBRID_X25519_ML_KEM_768
+	KeyType_AES256_FF1
+	KeyType_AES256_FF3_1
 )
 
 const (
@@ -91,6 +111,12 @@ type ManagedKeyFactory interface {
 	GetManagedKeyParameters() ManagedKeyParameters
 }
 
+// RandomReaderFactory supplies the random source for encryption operations
+// that generate ephemeral key material, such as KEM encapsulation.
+type RandomReaderFactory interface {
+	GetRandomReader() io.Reader
+}
+
 type RestoreInfo struct {
 	Time    time.Time `json:"time"`
 	Version int       `json:"version"`
@@ -122,18 +148,20 @@ type KeyType int
 
 func (kt KeyType) EncryptionSupported() bool {
 	switch kt {
-	case KeyType_AES128_GCM96, KeyType_AES256_GCM96, KeyType_ChaCha20_Poly1305, KeyType_RSA2048, KeyType_RSA3072, KeyType_RSA4096, KeyType_MANAGED_KEY:
+	case KeyType_AES128_GCM96, KeyType_AES256_GCM96, KeyType_ChaCha20_Poly1305, KeyType_RSA2048, KeyType_RSA3072, KeyType_RSA4096, KeyType_MANAGED_KEY,
+		KeyType_AES256_FF1, KeyType_AES256_FF3_1:
 		return true
 	}
-	return false
+	return kt.KeyEncapsulationSupported()
 }
 
 func (kt KeyType) DecryptionSupported() bool {
 	switch kt {
-	case KeyType_AES128_GCM96, KeyType_AES256_GCM96, KeyType_ChaCha20_Poly1305, KeyType_RSA2048, KeyType_RSA3072, KeyType_RSA4096, KeyType_MANAGED_KEY:
+	case KeyType_AES128_GCM96, KeyType_AES256_GCM96, KeyType_ChaCha20_Poly1305, KeyType_RSA2048, KeyType_RSA3072, KeyType_RSA4096, KeyType_MANAGED_KEY,
+		KeyType_AES256_FF1, KeyType_AES256_FF3_1:
 		return true
 	}
-	return false
+	return kt.KeyEncapsulationSupported()
 }
 
 func (kt KeyType) SigningSupported() bool {
@@ -141,6 +169,14 @@ func (kt KeyType) SigningSupported() bool {
 	case KeyType_ECDSA_P256, KeyType_ECDSA_P384, KeyType_ECDSA_P521, KeyType_ED25519, KeyType_RSA2048, KeyType_RSA3072, KeyType_RSA4096, KeyType_MANAGED_KEY:
 		return true
 	}
+	return kt.PostQuantumSigningSupported()
+}
+
+func (kt KeyType) KeyAgreementSupported() bool {
+	switch kt {
+	case KeyType_ECDSA_P256, KeyType_ECDSA_P384, KeyType_ECDSA_P521, KeyType_X25519:
+		return true
+	}
 	return false
 }
 
@@ -165,7 +201,7 @@ func (kt KeyType) AssociatedDataSupported() bool {
 	case KeyType_AES128_GCM96, KeyType_AES256_GCM96, KeyType_ChaCha20_Poly1305, KeyType_MANAGED_KEY:
 		return true
 	}
-	return false
+	return kt.KeyEncapsulationSupported()
 }
 
 func (kt KeyType) ImportPublicKeySupported() bool {
@@ -173,7 +209,10 @@ func (kt KeyType) ImportPublicKeySupported() bool {
 	case KeyType_RSA2048, KeyType_RSA3072, KeyType_RSA4096, KeyType_ECDSA_P256, KeyType_ECDSA_P384, KeyType_ECDSA_P521, KeyType_ED25519:
 		return true
 	}
-	return false
+	if _, ok := pqSchemes[kt]; ok {
+		return true
+	}
+	return kt.KeyEncapsulationSupported()
 }
 
 func (kt KeyType) String() string {
@@ -200,6 +239,44 @@ func (kt KeyType) String() string {
 		return "rsa-4096"
 	case KeyType_HMAC:
 		return "hmac"
+	case KeyType_X25519:
+		return "x25519"
+	case KeyType_AES128_CMAC:
+		return "aes128-cmac"
+	case KeyType_AES256_CMAC:
+		return "aes256-cmac"
+	case KeyType_ML_DSA_44:
+		return "ml-dsa-44"
+	case KeyType_ML_DSA_65:
+		return "ml-dsa-65"
+	case KeyType_ML_DSA_87:
+		return "ml-dsa-87"
+	case KeyType_SLH_DSA_SHA2_128S:
+		return "slh-dsa-sha2-128s"
+	case KeyType_SLH_DSA_SHA2_128F:
+		return "slh-dsa-sha2-128f"
+	case KeyType_SLH_DSA_SHA2_192S:
+		return "slh-dsa-sha2-192s"
+	case KeyType_SLH_DSA_SHA2_192F:
+		return "slh-dsa-sha2-192f"
+	case KeyType_SLH_DSA_SHA2_256S:
+		return "slh-dsa-sha2-256s"
+	case KeyType_SLH_DSA_SHA2_256F:
+		return "slh-dsa-sha2-256f"
+	case KeyType_HYBRID_ED25519_ML_DSA_65:
+		return "hybrid-ed25519-ml-dsa-65"
+	case KeyType_ML_KEM_512:
+		return "ml-kem-512"
+	case KeyType_ML_KEM_768:
+		return "ml-kem-768"
+	case KeyType_ML_KEM_1024:
+		return "ml-kem-1024"
+	case KeyType_HYBRID_X25519_ML_KEM_768:
+		return "hybrid-x25519-ml-kem-768"
+	case KeyType_AES256_FF1:
+		return "aes256-ff1"
+	case KeyType_AES256_FF3_1:
+		return "aes256-ff3-1"
 	case KeyType_MANAGED_KEY:
 		return "managed_key"
 	}
@@ -456,6 +533,15 @@ type Policy struct {
 	// rotate. Setting this to zero disables automatic rotation for the key.
 	AutoRotatePeriod time.Duration `json:"auto_rotate_period"`
 
+	// AutoRotateAfterOperations is the number of encryption and signing
+	// operations after which the latest key version should automatically
+	// rotate. Setting this to zero disables usage-based rotation.
+	AutoRotateAfterOperations uint64 `json:"auto_rotate_after_operations,omitempty"`
+
+	// PublishJWKS allows the public keys of a JWS signing key to be read
+	// without authentication from the JWKS endpoint.
+	PublishJWKS bool `json:"publish_jwks,omitempty"`
+
 	// versionPrefixCache stores caches of version prefix strings and the split
 	// version template.
 	versionPrefixCache sync.Map
@@ -908,29 +994,34 @@ func (p *Policy) Decrypt(context, nonce []byte, value string) (string, error) {
 	return p.DecryptWithFactory(context, nonce, value, nil)
 }
 
-func (p *Policy) DecryptWithFactory(context, nonce []byte, value string, factories ...interface{}) (string, error) {
-	if !p.Type.DecryptionSupported() {
-		return "", errutil.UserError{Err: fmt.Sprintf("message decryption not supported for key type %v", p.Type)}
-	}
+// CiphertextVersion returns the key version recorded in a ciphertext's
+// version prefix, without decrypting it.
+func (p *Policy) CiphertextVersion(value string) (int, error) {
+	ver, _, err := p.splitCiphertext(value)
+	return ver, err
+}
 
+// splitCiphertext separates a ciphertext into its key version and base64
+// encoded body.
+func (p *Policy) splitCiphertext(value string) (int, string, error) {
 	tplParts, err := p.getTemplateParts()
 	if err != nil {
-		return "", err
+		return 0, "", err
 	}
 
 	// Verify the prefix
 	if !strings.HasPrefix(value, tplParts[0]) {
-		return "", errutil.UserError{Err: "invalid ciphertext: no prefix"}
+		return 0, "", errutil.UserError{Err: "invalid ciphertext: no prefix"}
 	}
 
 	splitVerCiphertext := strings.SplitN(strings.TrimPrefix(value, tplParts[0]), tplParts[1], 2)
 	if len(splitVerCiphertext) != 2 {
-		return "", errutil.UserError{Err: "invalid ciphertext: wrong number of fields"}
+		return 0, "", errutil.UserError{Err: "invalid ciphertext: wrong number of fields"}
 	}
 
 	ver, err := strconv.Atoi(splitVerCiphertext[0])
 	if err != nil {
-		return "", errutil.UserError{Err: "invalid ciphertext: version number could not be decoded"}
+		return 0, "", errutil.UserError{Err: "invalid ciphertext: version number could not be decoded"}
 	}
 
 	if ver == 0 {
@@ -939,6 +1030,19 @@ func (p *Policy) DecryptWithFactory(context, nonce []byte, value string, factori
 		ver = 1
 	}
 
+	return ver, splitVerCiphertext[1], nil
+}
+
+func (p *Policy) DecryptWithFactory(context, nonce []byte, value string, factories ...interface{}) (string, error) {
+	if !p.Type.DecryptionSupported() {
+		return "", errutil.UserError{Err: fmt.Sprintf("message decryption not supported for key type %v", p.Type)}
+	}
+
+	ver, encoded, err := p.splitCiphertext(value)
+	if err != nil {
+		return "", err
+	}
+
 	if ver > p.LatestVersion {
 		return "", errutil.UserError{Err: "invalid ciphertext: version is too new"}
 	}
@@ -953,7 +1057,7 @@ func (p *Policy) DecryptWithFactory(context, nonce []byte, value string, factori
 	}
 
 	// Decode the base64
-	decoded, err := base64.StdEncoding.DecodeString(splitVerCiphertext[1])
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
 	if err != nil {
 		return "", errutil.UserError{Err: "invalid ciphertext: could not decode base64"}
 	}
@@ -992,7 +1096,7 @@ func (p *Policy) DecryptWithFactory(context, nonce []byte, value string, factori
 				if err != nil {
 					return "", errutil.InternalError{Err: fmt.Sprintf("unable to get associated_data/additional_data from factory[%d]: %v", index, err)}
 				}
-			case ManagedKeyFactory:
+			case ManagedKeyFactory, RandomReaderFactory:
 			default:
 				return "", errutil.InternalError{Err: fmt.Sprintf("unknown type of factory[%d]: %T", index, rawFactory)}
 			}
@@ -1015,6 +1119,21 @@ func (p *Policy) DecryptWithFactory(context, nonce []byte, value string, factori
 		if err != nil {
 			return "", errutil.InternalError{Err: fmt.Sprintf("failed to RSA decrypt the ciphertext: %v", err)}
 		}
+	case KeyType_AES256_FF1, KeyType_AES256_FF3_1:
+		return "", errutil.UserError{Err: fmt.Sprintf("key type %v only supports format preserving decryption", p.Type)}
+	case KeyType_ML_KEM_512, KeyType_ML_KEM_768, KeyType_ML_KEM_1024, KeyType_HYBRID_X25519_ML_KEM_768:
+		keyEntry, err := p.safeGetKeyEntry(ver)
+		if err != nil {
+			return "", err
+		}
+		aad, err := kemAssociatedData(factories)
+		if err != nil {
+			return "", err
+		}
+		plain, err = keyEntry.kemDecrypt(p.Type, decoded, aad)
+		if err != nil {
+			return "", err
+		}
 	case KeyType_MANAGED_KEY:
 		keyEntry, err := p.safeGetKeyEntry(ver)
 		if err != nil {
@@ -1245,6 +1364,17 @@ func (p *Policy) SignWithOptions(ver int, context, input []byte, options *Signin
 			return nil, errutil.InternalError{Err: fmt.Sprintf("unsupported rsa signature algorithm %s", sigAlgorithm)}
 		}
 
+	case KeyType_ML_DSA_44, KeyType_ML_DSA_65, KeyType_ML_DSA_87,
+		KeyType_SLH_DSA_SHA2_128S, KeyType_SLH_DSA_SHA2_128F, KeyType_SLH_DSA_SHA2_192S,
+		KeyType_SLH_DSA_SHA2_192F, KeyType_SLH_DSA_SHA2_256S, KeyType_SLH_DSA_SHA2_256F,
+		KeyType_HYBRID_ED25519_ML_DSA_65:
+		// Post-quantum schemes sign the message directly; any hashing is
+		// part of the scheme itself.
+		sig, err = pqSign(p.Type, keyParams.Key, input)
+		if err != nil {
+			return nil, err
+		}
+
 	case KeyType_MANAGED_KEY:
 		keyEntry, err := p.safeGetKeyEntry(ver)
 		if err != nil {
@@ -1445,6 +1575,21 @@ func (p *Policy) VerifySignatureWithOptions(context, input []byte, sig string, o
 
 		return err == nil, nil
 
+	case KeyType_ML_DSA_44, KeyType_ML_DSA_65, KeyType_ML_DSA_87,
+		KeyType_SLH_DSA_SHA2_128S, KeyType_SLH_DSA_SHA2_128F, KeyType_SLH_DSA_SHA2_192S,
+		KeyType_SLH_DSA_SHA2_192F, KeyType_SLH_DSA_SHA2_256S, KeyType_SLH_DSA_SHA2_256F,
+		KeyType_HYBRID_ED25519_ML_DSA_65:
+		keyEntry, err := p.safeGetKeyEntry(ver)
+		if err != nil {
+			return false, err
+		}
+		pub, err := keyEntry.rawPQPublicKey(p.Type)
+		if err != nil {
+			return false, err
+		}
+
+		return pqVerify(p.Type, pub, input, sigBytes)
+
 	case KeyType_MANAGED_KEY:
 		keyEntry, err := p.safeGetKeyEntry(ver)
 		if err != nil {
@@ -1493,18 +1638,48 @@ func (p *Policy) ImportPublicOrPrivate(ctx context.Context, storage logical.Stor
 		return fmt.Errorf("unable to import only public key for derived Ed25519 key: imported key should not be an Ed25519 key pair but is instead an HKDF key")
 	}
 
-	if (p.Type == KeyType_AES128_GCM96 && len(key) != 16) ||
-		((p.Type == KeyType_AES256_GCM96 || p.Type == KeyType_ChaCha20_Poly1305) && len(key) != 32) ||
+	if ((p.Type == KeyType_AES128_GCM96 || p.Type == KeyType_AES128_CMAC) && len(key) != 16) ||
+		((p.Type == KeyType_AES256_GCM96 || p.Type == KeyType_ChaCha20_Poly1305 || p.Type == KeyType_AES256_CMAC || p.Type.FPESupported()) && len(key) != 32) ||
 		(p.Type == KeyType_HMAC && (len(key) < HmacMinKeySize || len(key) > HmacMaxKeySize)) {
 		return fmt.Errorf("invalid key size %d bytes for key type %s", len(key), p.Type)
 	}
 
-	if p.Type == KeyType_AES128_GCM96 || p.Type == KeyType_AES256_GCM96 || p.Type == KeyType_ChaCha20_Poly1305 || p.Type == KeyType_HMAC {
+	if p.Type == KeyType_AES128_GCM96 || p.Type == KeyType_AES256_GCM96 || p.Type == KeyType_ChaCha20_Poly1305 || p.Type == KeyType_HMAC || p.Type.CMACSupported() || p.Type.FPESupported() {
 		entry.Key = key
 		if p.Type == KeyType_HMAC {
 			p.KeySize = len(key)
 			entry.HMACKey = key
 		}
+	} else if p.Type.PostQuantumSigningSupported() {
+		if isPrivateKey {
+			if err := entry.setPQSeed(p.Type, key); err != nil {
+				return err
+			}
+		} else {
+			pub, err := parsePQPublicKey(p.Type, key)
+			if err != nil {
+				return err
+			}
+			entry.FormattedPublicKey, err = formatPQPublicKey(p.Type, pub)
+			if err != nil {
+				return err
+			}
+		}
+	} else if p.Type.KeyEncapsulationSupported() {
+		if isPrivateKey {
+			if err := entry.setKEMSeed(p.Type, key); err != nil {
+				return err
+			}
+		} else {
+			pub, err := parseKEMPublicKey(p.Type, key)
+			if err != nil {
+				return err
+			}
+			entry.FormattedPublicKey, err = marshalPQPublicKeyPEM(kemSchemes[p.Type].oid, pub)
+			if err != nil {
+				return err
+			}
+		}
 	} else {
 		var parsedKey any
 		var err error
@@ -1618,10 +1793,11 @@ func (p *Policy) RotateInMemory(randReader io.Reader) (retErr error) {
 	entry.HMACKey = hmacKey
 
 	switch p.Type {
-	case KeyType_AES128_GCM96, KeyType_AES256_GCM96, KeyType_ChaCha20_Poly1305, KeyType_HMAC:
+	case KeyType_AES128_GCM96, KeyType_AES256_GCM96, KeyType_ChaCha20_Poly1305, KeyType_HMAC, KeyType_AES128_CMAC, KeyType_AES256_CMAC,
+		KeyType_AES256_FF1, KeyType_AES256_FF3_1:
 		// Default to 256 bit key
 		numBytes := 32
-		if p.Type == KeyType_AES128_GCM96 {
+		if p.Type == KeyType_AES128_GCM96 || p.Type == KeyType_AES128_CMAC {
 			numBytes = 16
 		} else if p.Type == KeyType_HMAC {
 			numBytes = p.KeySize
@@ -1686,6 +1862,13 @@ func (p *Policy) RotateInMemory(randReader io.Reader) (retErr error) {
 		}
 		entry.Key = pri
 		entry.FormattedPublicKey = base64.StdEncoding.EncodeToString(pub)
+	case KeyType_X25519:
+		privKey, err := ecdh.X25519().GenerateKey(randReader)
+		if err != nil {
+			return err
+		}
+		entry.Key = privKey.Bytes()
+		entry.FormattedPublicKey = base64.StdEncoding.EncodeToString(privKey.PublicKey().Bytes())
 	case KeyType_RSA2048, KeyType_RSA3072, KeyType_RSA4096:
 		bitSize := 2048
 		if p.Type == KeyType_RSA3072 {
@@ -1699,6 +1882,19 @@ func (p *Policy) RotateInMemory(randReader io.Reader) (retErr error) {
 		if err != nil {
 			return err
 		}
+
+	case KeyType_ML_DSA_44, KeyType_ML_DSA_65, KeyType_ML_DSA_87,
+		KeyType_SLH_DSA_SHA2_128S, KeyType_SLH_DSA_SHA2_128F, KeyType_SLH_DSA_SHA2_192S,
+		KeyType_SLH_DSA_SHA2_192F, KeyType_SLH_DSA_SHA2_256S, KeyType_SLH_DSA_SHA2_256F,
+		KeyType_HYBRID_ED25519_ML_DSA_65:
+		if err := entry.generatePQKey(p.Type, randReader); err != nil {
+			return err
+		}
+
+	case KeyType_ML_KEM_512, KeyType_ML_KEM_768, KeyType_ML_KEM_1024, KeyType_HYBRID_X25519_ML_KEM_768:
+		if err := entry.generateKEMKey(p.Type, randReader); err != nil {
+			return err
+		}
 	}
 
 	if p.ConvergentEncryption {
@@ -2062,7 +2258,7 @@ func (p *Policy) EncryptWithFactory(ver int, context []byte, nonce []byte, value
 				if err != nil {
 					return "", errutil.InternalError{Err: fmt.Sprintf("unable to get associated_data/additional_data from factory[%d]: %v", index, err)}
 				}
-			case ManagedKeyFactory:
+			case ManagedKeyFactory, RandomReaderFactory:
 			default:
 				return "", errutil.InternalError{Err: fmt.Sprintf("unknown type of factory[%d]: %T", index, rawFactory)}
 			}
@@ -2087,6 +2283,21 @@ func (p *Policy) EncryptWithFactory(ver int, context []byte, nonce []byte, value
 		if err != nil {
 			return "", errutil.InternalError{Err: fmt.Sprintf("failed to RSA encrypt the plaintext: %v", err)}
 		}
+	case KeyType_AES256_FF1, KeyType_AES256_FF3_1:
+		return "", errutil.UserError{Err: fmt.Sprintf("key type %v only supports format preserving encryption", p.Type)}
+	case KeyType_ML_KEM_512, KeyType_ML_KEM_768, KeyType_ML_KEM_1024, KeyType_HYBRID_X25519_ML_KEM_768:
+		keyEntry, err := p.safeGetKeyEntry(ver)
+		if err != nil {
+			return "", err
+		}
+		aad, err := kemAssociatedData(factories)
+		if err != nil {
+			return "", err
+		}
+		ciphertext, err = keyEntry.kemEncrypt(p.Type, plaintext, aad, kemRandomReader(factories))
+		if err != nil {
+			return "", err
+		}
 	case KeyType_MANAGED_KEY:
 		keyEntry, err := p.safeGetKeyEntry(ver)
 		if err != nil {
@@ -2157,6 +2368,24 @@ func (p *Policy) ImportPrivateKeyForVersion(ctx context.Context, storage logical
 		return err
 	}
 
+	if p.Type.PostQuantumSigningSupported() || p.Type.KeyEncapsulationSupported() {
+		expected := keyEntry.FormattedPublicKey
+		if p.Type.KeyEncapsulationSupported() {
+			err = keyEntry.setKEMSeed(p.Type, key)
+		} else {
+			err = keyEntry.setPQSeed(p.Type, key)
+		}
+		if err != nil {
+			return err
+		}
+		if keyEntry.FormattedPublicKey != expected {
+			return fmt.Errorf("cannot import key, key pair does not match")
+		}
+
+		p.Keys[strconv.Itoa(keyVersion)] = keyEntry
+		return p.Persist(ctx, storage)
+	}
+
 	// Parse key
 	parsedPrivateKey, err := x509.ParsePKCS8PrivateKey(key)
 	if err != nil {
@@ -2357,7 +2586,8 @@ func (ke *KeyEntry) WrapKey(targetKey interface{}, targetKeyType KeyType, hash h
 
 	var preppedTargetKey []byte
 	switch targetKeyType {
-	case KeyType_AES128_GCM96, KeyType_AES256_GCM96, KeyType_ChaCha20_Poly1305, KeyType_HMAC:
+	case KeyType_AES128_GCM96, KeyType_AES256_GCM96, KeyType_ChaCha20_Poly1305, KeyType_HMAC, KeyType_AES128_CMAC, KeyType_AES256_CMAC,
+		KeyType_AES256_FF1, KeyType_AES256_FF3_1:
 		var ok bool
 		preppedTargetKey, ok = targetKey.([]byte)
 		if !ok {
@@ -2457,6 +2687,12 @@ func (p *Policy) CreateCsr(keyVersion int, csrTemplate *x509.CertificateRequest)
 	case KeyType_RSA2048, KeyType_RSA3072, KeyType_RSA4096:
 		key = keyEntry.RSAKey
 
+	case KeyType_ML_DSA_44, KeyType_ML_DSA_65, KeyType_ML_DSA_87,
+		KeyType_SLH_DSA_SHA2_128S, KeyType_SLH_DSA_SHA2_128F, KeyType_SLH_DSA_SHA2_192S,
+		KeyType_SLH_DSA_SHA2_192F, KeyType_SLH_DSA_SHA2_256S, KeyType_SLH_DSA_SHA2_256F,
+		KeyType_HYBRID_ED25519_ML_DSA_65:
+		return nil, errutil.UserError{Err: fmt.Sprintf("CSR generation is not supported for key type %s", p.Type)}
+
 	default:
 		return nil, errutil.InternalError{Err: fmt.Sprintf("selected key type '%s' does not support signing", p.Type.String())}
 	}
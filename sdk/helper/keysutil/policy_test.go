@@ -11,8 +11,10 @@ import (
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
+	"encoding/base64"
 	"errors"
 	"fmt"
+	"io"
 	mathrand "math/rand"
 	"reflect"
 	"strconv"
@@ -1066,3 +1068,58 @@ func isUnsupportedGoHashType(hashType HashType, err error) bool {
 
 	return false
 }
+
+type testRandomReaderFactory struct {
+	randReader io.Reader
+}
+
+func (f testRandomReaderFactory) GetRandomReader() io.Reader {
+	return f.randReader
+}
+
+func Test_KEMRandomReader(t *testing.T) {
+	ctx := context.Background()
+	lm, _ := NewLockManager(false, 0)
+	storage := &logical.InmemStorage{}
+
+	for _, keyType := range []KeyType{KeyType_ML_KEM_768, KeyType_HYBRID_X25519_ML_KEM_768} {
+		p, _, err := lm.GetPolicy(ctx, PolicyRequest{
+			Upsert:  true,
+			Storage: storage,
+			KeyType: keyType,
+			Name:    keyType.String(),
+		}, rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p.Unlock()
+
+		plaintext := base64.StdEncoding.EncodeToString([]byte("the quick brown fox"))
+
+		// The encapsulation and nonce must come from the supplied reader, so
+		// two encryptions with identically seeded readers agree.
+		encrypt := func(seed int64) string {
+			ciphertext, err := p.EncryptWithFactory(0, nil, nil, plaintext,
+				testRandomReaderFactory{mathrand.New(mathrand.NewSource(seed))})
+			if err != nil {
+				t.Fatal(err)
+			}
+			return ciphertext
+		}
+		first := encrypt(1)
+		if second := encrypt(1); first != second {
+			t.Fatalf("%s: encryption did not use the supplied random reader", keyType)
+		}
+		if other := encrypt(2); first == other {
+			t.Fatalf("%s: expected different ciphertexts for different random sources", keyType)
+		}
+
+		decrypted, err := p.Decrypt(nil, nil, first)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decrypted != plaintext {
+			t.Fatalf("%s: bad decryption result: %q", keyType, decrypted)
+		}
+	}
+}
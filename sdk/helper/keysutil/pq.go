@@ -0,0 +1,240 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package keysutil
+
+import (
+	"crypto/ed25519"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io"
+
+	"github.com/cloudflare/circl/sign"
+	"github.com/cloudflare/circl/sign/mldsa/mldsa44"
+	"github.com/cloudflare/circl/sign/mldsa/mldsa65"
+	"github.com/cloudflare/circl/sign/mldsa/mldsa87"
+	"github.com/cloudflare/circl/sign/slhdsa"
+	"github.com/hashicorp/vault/sdk/helper/errutil"
+)
+
+// hybridMLDSAContext is the FIPS 204 context string used for the ML-DSA
+// half of a hybrid signature, so that it cannot be mistaken for a plain
+// ML-DSA-65 signature.
+const hybridMLDSAContext = "hybrid-ed25519-ml-dsa-65"
+
+// pqParams describes a post-quantum signature key type. Private keys are
+// stored as the scheme's seed, from which the full key pair is derived.
+type pqParams struct {
+	scheme sign.Scheme
+	oid    asn1.ObjectIdentifier
+}
+
+// OIDs from the NIST Computer Security Objects Register.
+var pqSchemes = map[KeyType]pqParams{
+	KeyType_ML_DSA_44:         {mldsa44.Scheme(), asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 3, 17}},
+	KeyType_ML_DSA_65:         {mldsa65.Scheme(), asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 3, 18}},
+	KeyType_ML_DSA_87:         {mldsa87.Scheme(), asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 3, 19}},
+	KeyType_SLH_DSA_SHA2_128S: {slhdsa.SHA2_128s.Scheme(), asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 3, 20}},
+	KeyType_SLH_DSA_SHA2_128F: {slhdsa.SHA2_128f.Scheme(), asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 3, 21}},
+	KeyType_SLH_DSA_SHA2_192S: {slhdsa.SHA2_192s.Scheme(), asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 3, 22}},
+	KeyType_SLH_DSA_SHA2_192F: {slhdsa.SHA2_192f.Scheme(), asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 3, 23}},
+	KeyType_SLH_DSA_SHA2_256S: {slhdsa.SHA2_256s.Scheme(), asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 3, 24}},
+	KeyType_SLH_DSA_SHA2_256F: {slhdsa.SHA2_256f.Scheme(), asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 3, 25}},
+}
+
+// PostQuantumSigningSupported returns whether the key type is an ML-DSA,
+// SLH-DSA or hybrid Ed25519 + ML-DSA signing key.
+func (kt KeyType) PostQuantumSigningSupported() bool {
+	if kt == KeyType_HYBRID_ED25519_ML_DSA_65 {
+		return true
+	}
+	_, ok := pqSchemes[kt]
+	return ok
+}
+
+// pqSeedSize returns the length of the stored private key seed.
+func (kt KeyType) pqSeedSize() int {
+	if kt == KeyType_HYBRID_ED25519_ML_DSA_65 {
+		return ed25519.SeedSize + mldsa65.SeedSize
+	}
+	return pqSchemes[kt].scheme.SeedSize()
+}
+
+// generatePQKey creates a new seed for the key type and fills in the key
+// entry from it.
+func (ke *KeyEntry) generatePQKey(kt KeyType, randReader io.Reader) error {
+	seed := make([]byte, kt.pqSeedSize())
+	if _, err := io.ReadFull(randReader, seed); err != nil {
+		return err
+	}
+	return ke.setPQSeed(kt, seed)
+}
+
+// setPQSeed stores the private key seed and the corresponding public key.
+func (ke *KeyEntry) setPQSeed(kt KeyType, seed []byte) error {
+	if len(seed) != kt.pqSeedSize() {
+		return fmt.Errorf("invalid key size %d bytes for key type %s", len(seed), kt)
+	}
+
+	pub, err := pqPublicKeyFromSeed(kt, seed)
+	if err != nil {
+		return err
+	}
+	formatted, err := formatPQPublicKey(kt, pub)
+	if err != nil {
+		return err
+	}
+
+	ke.Key = seed
+	ke.FormattedPublicKey = formatted
+	return nil
+}
+
+func pqPublicKeyFromSeed(kt KeyType, seed []byte) ([]byte, error) {
+	if kt == KeyType_HYBRID_ED25519_ML_DSA_65 {
+		edPub := ed25519.NewKeyFromSeed(seed[:ed25519.SeedSize]).Public().(ed25519.PublicKey)
+		mlPub, _ := mldsa65.Scheme().DeriveKey(seed[ed25519.SeedSize:])
+		mlPubBytes, err := mlPub.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		return append(append([]byte{}, edPub...), mlPubBytes...), nil
+	}
+
+	pub, _ := pqSchemes[kt].scheme.DeriveKey(seed)
+	return pub.MarshalBinary()
+}
+
+// formatPQPublicKey returns the PEM-encoded SubjectPublicKeyInfo for ML-DSA
+// and SLH-DSA keys. Hybrid keys have no standard encoding, so their public
+// key is the base64 encoding of the Ed25519 public key followed by the
+// ML-DSA-65 public key.
+func formatPQPublicKey(kt KeyType, pub []byte) (string, error) {
+	if kt == KeyType_HYBRID_ED25519_ML_DSA_65 {
+		return base64.StdEncoding.EncodeToString(pub), nil
+	}
+
+	return marshalPQPublicKeyPEM(pqSchemes[kt].oid, pub)
+}
+
+type pqSubjectPublicKeyInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	PublicKey asn1.BitString
+}
+
+// marshalPQPublicKeyPEM wraps a raw public key in a PEM-encoded
+// SubjectPublicKeyInfo with the given algorithm OID and no parameters.
+func marshalPQPublicKeyPEM(oid asn1.ObjectIdentifier, pub []byte) (string, error) {
+	der, err := asn1.Marshal(pqSubjectPublicKeyInfo{
+		Algorithm: pkix.AlgorithmIdentifier{Algorithm: oid},
+		PublicKey: asn1.BitString{Bytes: pub, BitLength: 8 * len(pub)},
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})), nil
+}
+
+// unmarshalPQPublicKeyPEM returns the raw public key from a PEM-encoded
+// SubjectPublicKeyInfo, checking that its algorithm OID matches the key type.
+func unmarshalPQPublicKeyPEM(kt KeyType, oid asn1.ObjectIdentifier, key []byte) ([]byte, error) {
+	block, _ := pem.Decode(key)
+	if block == nil {
+		return nil, fmt.Errorf("error parsing public key: not in PEM format")
+	}
+
+	var spki pqSubjectPublicKeyInfo
+	rest, err := asn1.Unmarshal(block.Bytes, &spki)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing public key: %w", err)
+	}
+	if len(rest) != 0 {
+		return nil, fmt.Errorf("error parsing public key: trailing data")
+	}
+	if !spki.Algorithm.Algorithm.Equal(oid) {
+		return nil, fmt.Errorf("error parsing public key: algorithm %v does not match key type %s", spki.Algorithm.Algorithm, kt)
+	}
+
+	return spki.PublicKey.Bytes, nil
+}
+
+// parsePQPublicKey parses a PEM-encoded SubjectPublicKeyInfo for an ML-DSA
+// or SLH-DSA key, returning the raw public key.
+func parsePQPublicKey(kt KeyType, key []byte) ([]byte, error) {
+	params, ok := pqSchemes[kt]
+	if !ok {
+		return nil, fmt.Errorf("importing only a public key is not supported for key type %s", kt)
+	}
+
+	pub, err := unmarshalPQPublicKeyPEM(kt, params.oid, key)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := params.scheme.UnmarshalBinaryPublicKey(pub); err != nil {
+		return nil, fmt.Errorf("error parsing public key: %w", err)
+	}
+
+	return pub, nil
+}
+
+// rawPQPublicKey returns the raw public key stored in the key entry.
+func (ke *KeyEntry) rawPQPublicKey(kt KeyType) ([]byte, error) {
+	if kt == KeyType_HYBRID_ED25519_ML_DSA_65 {
+		return base64.StdEncoding.DecodeString(ke.FormattedPublicKey)
+	}
+	return parsePQPublicKey(kt, []byte(ke.FormattedPublicKey))
+}
+
+func pqSign(kt KeyType, seed, input []byte) ([]byte, error) {
+	if len(seed) != kt.pqSeedSize() {
+		return nil, errutil.InternalError{Err: "invalid post-quantum private key"}
+	}
+
+	if kt == KeyType_HYBRID_ED25519_ML_DSA_65 {
+		edSig := ed25519.Sign(ed25519.NewKeyFromSeed(seed[:ed25519.SeedSize]), input)
+		_, mlPriv := mldsa65.Scheme().DeriveKey(seed[ed25519.SeedSize:])
+		mlSig := mldsa65.Scheme().Sign(mlPriv, input, &sign.SignatureOpts{Context: hybridMLDSAContext})
+		return append(edSig, mlSig...), nil
+	}
+
+	scheme := pqSchemes[kt].scheme
+	_, priv := scheme.DeriveKey(seed)
+	sig := scheme.Sign(priv, input, nil)
+	if len(sig) == 0 {
+		return nil, fmt.Errorf("failed to sign with %s", kt)
+	}
+	return sig, nil
+}
+
+func pqVerify(kt KeyType, pub, input, sig []byte) (bool, error) {
+	if kt == KeyType_HYBRID_ED25519_ML_DSA_65 {
+		if len(pub) != ed25519.PublicKeySize+mldsa65.PublicKeySize {
+			return false, errutil.InternalError{Err: "invalid hybrid public key"}
+		}
+		if len(sig) != ed25519.SignatureSize+mldsa65.SignatureSize {
+			return false, nil
+		}
+		mlPub, err := mldsa65.Scheme().UnmarshalBinaryPublicKey(pub[ed25519.PublicKeySize:])
+		if err != nil {
+			return false, err
+		}
+
+		// Both signatures must be valid.
+		edValid := ed25519.Verify(pub[:ed25519.PublicKeySize], input, sig[:ed25519.SignatureSize])
+		mlValid := mldsa65.Scheme().Verify(mlPub, input, sig[ed25519.SignatureSize:], &sign.SignatureOpts{Context: hybridMLDSAContext})
+		return edValid && mlValid, nil
+	}
+
+	scheme := pqSchemes[kt].scheme
+	pk, err := scheme.UnmarshalBinaryPublicKey(pub)
+	if err != nil {
+		return false, err
+	}
+	if len(sig) != scheme.SignatureSize() {
+		return false, nil
+	}
+	return scheme.Verify(pk, input, sig, nil), nil
+}
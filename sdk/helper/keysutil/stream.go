@@ -0,0 +1,262 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package keysutil
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/vault/sdk/helper/errutil"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	// StreamIDSize is the size of the random identifier shared by every
+	// request of a stream. It is authenticated with each segment so segments
+	// cannot be moved between streams.
+	StreamIDSize = 16
+
+	// StreamSaltSize is the size of the random salt carried in a stream
+	// header. Every encryption request draws a fresh salt, and so a fresh
+	// segment key, so segment nonces can never repeat under the same key even
+	// if a caller replays a stream ID and segment index.
+	StreamSaltSize = 32
+
+	streamNoncePrefixSize = 7
+	streamKeyInfo         = "vault-transit-stream-v1"
+)
+
+// StreamHeader identifies the segments sealed by a single encryption request:
+// the key version used, the stream they belong to and the random salt the
+// segment key is derived from.
+type StreamHeader struct {
+	KeyVersion int
+	StreamID   []byte
+	Salt       []byte
+}
+
+func (kt KeyType) StreamingSupported() bool {
+	switch kt {
+	case KeyType_AES128_GCM96, KeyType_AES256_GCM96, KeyType_ChaCha20_Poly1305:
+		return true
+	}
+	return false
+}
+
+// NewStreamID returns a random identifier for a new stream.
+func NewStreamID(randReader io.Reader) ([]byte, error) {
+	streamID := make([]byte, StreamIDSize)
+	if _, err := io.ReadFull(randReader, streamID); err != nil {
+		return nil, errutil.InternalError{Err: fmt.Sprintf("failed to generate stream ID: %v", err)}
+	}
+	return streamID, nil
+}
+
+// ParseStreamHeader decodes a header produced by NewStreamEncrypter.
+func (p *Policy) ParseStreamHeader(header string) (*StreamHeader, error) {
+	tplParts, err := p.getTemplateParts()
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.HasPrefix(header, tplParts[0]) {
+		return nil, errutil.UserError{Err: "invalid stream header: no prefix"}
+	}
+
+	splitVerHeader := strings.SplitN(strings.TrimPrefix(header, tplParts[0]), tplParts[1], 2)
+	if len(splitVerHeader) != 2 {
+		return nil, errutil.UserError{Err: "invalid stream header: wrong number of fields"}
+	}
+
+	ver, err := strconv.Atoi(splitVerHeader[0])
+	if err != nil {
+		return nil, errutil.UserError{Err: "invalid stream header: version number could not be decoded"}
+	}
+
+	if ver <= 0 || ver > p.LatestVersion {
+		return nil, errutil.UserError{Err: "invalid stream header: unknown key version"}
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(splitVerHeader[1])
+	if err != nil {
+		return nil, errutil.UserError{Err: "invalid stream header: could not decode base64"}
+	}
+	if len(decoded) != StreamIDSize+StreamSaltSize {
+		return nil, errutil.UserError{Err: "invalid stream header: wrong length"}
+	}
+
+	return &StreamHeader{
+		KeyVersion: ver,
+		StreamID:   decoded[:StreamIDSize],
+		Salt:       decoded[StreamIDSize:],
+	}, nil
+}
+
+// streamAEAD derives the segment key and nonce prefix from the key version's
+// encryption key and the header's salt.
+func (p *Policy) streamAEAD(context []byte, header *StreamHeader) (cipher.AEAD, []byte, error) {
+	numBytes := 32
+	if p.Type == KeyType_AES128_GCM96 {
+		numBytes = 16
+	}
+
+	encKey, err := p.GetKey(context, header.KeyVersion, numBytes)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(encKey) != numBytes {
+		return nil, nil, errutil.InternalError{Err: "could not derive enc key, length not correct"}
+	}
+
+	derived := make([]byte, numBytes+streamNoncePrefixSize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, encKey, header.Salt, []byte(streamKeyInfo)), derived); err != nil {
+		return nil, nil, errutil.InternalError{Err: fmt.Sprintf("failed to derive stream key: %v", err)}
+	}
+	segmentKey, noncePrefix := derived[:numBytes], derived[numBytes:]
+
+	var aead cipher.AEAD
+	switch p.Type {
+	case KeyType_AES128_GCM96, KeyType_AES256_GCM96:
+		aesCipher, err := aes.NewCipher(segmentKey)
+		if err != nil {
+			return nil, nil, errutil.InternalError{Err: err.Error()}
+		}
+		aead, err = cipher.NewGCM(aesCipher)
+		if err != nil {
+			return nil, nil, errutil.InternalError{Err: err.Error()}
+		}
+	case KeyType_ChaCha20_Poly1305:
+		aead, err = chacha20poly1305.New(segmentKey)
+		if err != nil {
+			return nil, nil, errutil.InternalError{Err: err.Error()}
+		}
+	default:
+		return nil, nil, errutil.UserError{Err: fmt.Sprintf("streaming encryption not supported for key type %v", p.Type)}
+	}
+
+	return aead, noncePrefix, nil
+}
+
+// streamNonce builds the STREAM segment nonce: the per-header prefix, the
+// big-endian segment counter and a final-segment flag byte.
+func streamNonce(prefix []byte, index uint32, final bool) []byte {
+	nonce := make([]byte, 0, streamNoncePrefixSize+5)
+	nonce = append(nonce, prefix...)
+	nonce = binary.BigEndian.AppendUint32(nonce, index)
+	if final {
+		nonce = append(nonce, 1)
+	} else {
+		nonce = append(nonce, 0)
+	}
+	return nonce
+}
+
+// streamAAD binds a segment to its stream, its index and whether it is the
+// final segment, in addition to the caller's associated data.
+func streamAAD(streamID []byte, index uint32, final bool, additionalData []byte) []byte {
+	aad := make([]byte, 0, StreamIDSize+5+len(additionalData))
+	aad = append(aad, streamID...)
+	aad = binary.BigEndian.AppendUint32(aad, index)
+	if final {
+		aad = append(aad, 1)
+	} else {
+		aad = append(aad, 0)
+	}
+	return append(aad, additionalData...)
+}
+
+// StreamCipher seals or opens the segments of a single stream.
+type StreamCipher struct {
+	aead        cipher.AEAD
+	noncePrefix []byte
+	streamID    []byte
+}
+
+// NewStreamEncrypter returns a StreamCipher for encrypting segments of the
+// given stream with the given key version, along with the header which must
+// accompany those segments on decryption. Every call draws a fresh random
+// salt, so segment keys and nonces are never taken from caller input.
+func (p *Policy) NewStreamEncrypter(context []byte, ver int, streamID []byte, randReader io.Reader) (*StreamCipher, string, error) {
+	if !p.Type.StreamingSupported() {
+		return nil, "", errutil.UserError{Err: fmt.Sprintf("streaming encryption not supported for key type %v", p.Type)}
+	}
+
+	switch {
+	case ver == 0:
+		ver = p.LatestVersion
+	case ver < 0:
+		return nil, "", errutil.UserError{Err: "requested version for encryption is negative"}
+	case ver > p.LatestVersion:
+		return nil, "", errutil.UserError{Err: "requested version for encryption is higher than the latest key version"}
+	case p.MinEncryptionVersion > 0 && ver < p.MinEncryptionVersion:
+		return nil, "", errutil.UserError{Err: "requested version for encryption is less than the minimum encryption key version"}
+	}
+
+	if len(streamID) != StreamIDSize {
+		return nil, "", errutil.UserError{Err: "invalid stream ID: wrong length"}
+	}
+
+	salt := make([]byte, StreamSaltSize)
+	if _, err := io.ReadFull(randReader, salt); err != nil {
+		return nil, "", errutil.InternalError{Err: fmt.Sprintf("failed to generate stream salt: %v", err)}
+	}
+
+	parsed := &StreamHeader{
+		KeyVersion: ver,
+		StreamID:   streamID,
+		Salt:       salt,
+	}
+	aead, prefix, err := p.streamAEAD(context, parsed)
+	if err != nil {
+		return nil, "", err
+	}
+
+	header := p.getVersionPrefix(ver) + base64.StdEncoding.EncodeToString(append(append([]byte{}, streamID...), salt...))
+	return &StreamCipher{aead: aead, noncePrefix: prefix, streamID: streamID}, header, nil
+}
+
+// NewStreamDecrypter returns a StreamCipher for decrypting the segments
+// sealed with the given header.
+func (p *Policy) NewStreamDecrypter(context []byte, header string) (*StreamCipher, error) {
+	parsed, err := p.ParseStreamHeader(header)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.MinDecryptionVersion > 0 && parsed.KeyVersion < p.MinDecryptionVersion {
+		return nil, errutil.UserError{Err: ErrTooOld}
+	}
+
+	aead, prefix, err := p.streamAEAD(context, parsed)
+	if err != nil {
+		return nil, err
+	}
+
+	return &StreamCipher{aead: aead, noncePrefix: prefix, streamID: parsed.StreamID}, nil
+}
+
+// Seal encrypts the segment at the given index.
+func (sc *StreamCipher) Seal(index uint32, final bool, plaintext, additionalData []byte) []byte {
+	return sc.aead.Seal(nil, streamNonce(sc.noncePrefix, index, final), plaintext, streamAAD(sc.streamID, index, final, additionalData))
+}
+
+// Open decrypts the segment at the given index. It fails if the stream, index
+// or final flag do not match those used when sealing, which detects splicing,
+// reordering and truncation of the stream.
+func (sc *StreamCipher) Open(index uint32, final bool, ciphertext, additionalData []byte) ([]byte, error) {
+	plain, err := sc.aead.Open(nil, streamNonce(sc.noncePrefix, index, final), ciphertext, streamAAD(sc.streamID, index, final, additionalData))
+	if err != nil {
+		return nil, errutil.UserError{Err: fmt.Sprintf("failed to decrypt segment %d: %v", index, err)}
+	}
+
+	return plain, nil
+}
@@ -0,0 +1,88 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package keysutil
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func TestStream_NoNonceReuse(t *testing.T) {
+	ctx := context.Background()
+	storage := &logical.InmemStorage{}
+
+	for _, keyType := range []KeyType{KeyType_AES128_GCM96, KeyType_AES256_GCM96, KeyType_ChaCha20_Poly1305} {
+		t.Run(keyType.String(), func(t *testing.T) {
+			lm, _ := NewLockManager(true, 0)
+			p, _, err := lm.GetPolicy(ctx, PolicyRequest{
+				Upsert:  true,
+				Storage: storage,
+				KeyType: keyType,
+				Name:    "stream-" + keyType.String(),
+			}, rand.Reader)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			streamID, err := NewStreamID(rand.Reader)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Every call for the same stream and segment index must seal
+			// under a different header and nonce.
+			headers := map[string]bool{}
+			nonces := map[string]bool{}
+			var ciphertexts [][]byte
+			for i := 0; i < 100; i++ {
+				sc, header, err := p.NewStreamEncrypter(nil, 0, streamID, rand.Reader)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if headers[header] {
+					t.Fatalf("header %q reused", header)
+				}
+				headers[header] = true
+
+				nonce := string(streamNonce(sc.noncePrefix, 0, false))
+				if nonces[nonce] {
+					t.Fatalf("nonce reused on call %d", i)
+				}
+				nonces[nonce] = true
+
+				ct := sc.Seal(0, false, []byte("segment"), nil)
+				for _, other := range ciphertexts {
+					if bytes.Equal(ct, other) {
+						t.Fatalf("ciphertext repeated on call %d", i)
+					}
+				}
+				ciphertexts = append(ciphertexts, ct)
+
+				dec, err := p.NewStreamDecrypter(nil, header)
+				if err != nil {
+					t.Fatal(err)
+				}
+				pt, err := dec.Open(0, false, ct, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(pt) != "segment" {
+					t.Fatalf("unexpected plaintext %q", pt)
+				}
+
+				// The index and final flag are authenticated.
+				if _, err := dec.Open(0, true, ct, nil); err == nil {
+					t.Fatal("expected opening with the wrong final flag to fail")
+				}
+				if _, err := dec.Open(1, false, ct, nil); err == nil {
+					t.Fatal("expected opening with the wrong index to fail")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,246 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package keysutil
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// KeyUsageReservation is the number of operations reserved in storage ahead
+// of use. Counts are only written when a version exceeds its reservation, so
+// at most one write happens per KeyUsageReservation operations, and a
+// restart can overstate a version's count by at most this much but never
+// understate it.
+const KeyUsageReservation = 1024
+
+// KeyUsageTimestampGranularity bounds how stale a stored last-decrypted or
+// last-verified time may be. A newer time is only written once it is this
+// far ahead of the stored one, so a restart can understate it by at most
+// this much.
+const KeyUsageTimestampGranularity = time.Hour
+
+// KeyUsageRetryInterval is how long to wait before writing a reservation
+// again after a failed write. Counts keep accumulating in memory meanwhile.
+const KeyUsageRetryInterval = time.Minute
+
+// KeyUsageType identifies the kind of operation being counted.
+type KeyUsageType int
+
+const (
+	KeyUsageEncrypt KeyUsageType = iota
+	KeyUsageSign
+	KeyUsageDecrypt
+	KeyUsageVerify
+)
+
+// KeyVersionUsage holds the operation counts for a single key version.
+type KeyVersionUsage struct {
+	Encryptions   uint64    `json:"encryptions"`
+	Signatures    uint64    `json:"signatures"`
+	Decryptions   uint64    `json:"decryptions"`
+	LastDecrypted time.Time `json:"last_decrypted"`
+	Verifications uint64    `json:"verifications"`
+	LastVerified  time.Time `json:"last_verified"`
+}
+
+// Operations returns the total number of encryption and signing operations,
+// which are the ones that consume a key version.
+func (u KeyVersionUsage) Operations() uint64 {
+	return u.Encryptions + u.Signatures
+}
+
+func (u *KeyVersionUsage) add(usage KeyUsageType, n uint64, now time.Time) {
+	switch usage {
+	case KeyUsageEncrypt:
+		u.Encryptions += n
+	case KeyUsageSign:
+		u.Signatures += n
+	case KeyUsageDecrypt:
+		u.Decryptions += n
+		u.LastDecrypted = now
+	case KeyUsageVerify:
+		u.Verifications += n
+		u.LastVerified = now
+	}
+}
+
+// LastUsed returns the later of the last decryption and verification times,
+// the operations which need an old version to still be available.
+func (u KeyVersionUsage) LastUsed() time.Time {
+	if u.LastVerified.After(u.LastDecrypted) {
+		return u.LastVerified
+	}
+	return u.LastDecrypted
+}
+
+// needsReservation returns whether counts have outgrown what is stored.
+func (u KeyVersionUsage) needsReservation(reserved KeyVersionUsage) bool {
+	return u.Encryptions > reserved.Encryptions ||
+		u.Signatures > reserved.Signatures ||
+		u.Decryptions > reserved.Decryptions ||
+		u.Verifications > reserved.Verifications ||
+		u.LastDecrypted.Sub(reserved.LastDecrypted) >= KeyUsageTimestampGranularity ||
+		u.LastVerified.Sub(reserved.LastVerified) >= KeyUsageTimestampGranularity
+}
+
+// usageVersionExists returns whether a version has not been trimmed. Usage
+// of versions below the minimum decryption version is kept, since that
+// minimum can be lowered again.
+func usageVersionExists(p *Policy, ver string) bool {
+	v, err := strconv.Atoi(ver)
+	if err != nil {
+		return false
+	}
+	return v >= p.MinAvailableVersion && v <= p.LatestVersion
+}
+
+// keyUsage tracks the usage of a single key. counts holds the in-memory
+// counts, while reserved mirrors what has been written to storage.
+// retryAfter holds off further writes after a failed one.
+type keyUsage struct {
+	l          sync.Mutex
+	loaded     bool
+	counts     map[string]KeyVersionUsage
+	reserved   map[string]KeyVersionUsage
+	retryAfter time.Time
+}
+
+func keyUsagePath(p *Policy) string {
+	return path.Join(p.StoragePrefix, "usage", p.Name)
+}
+
+func (lm *LockManager) keyUsage(p *Policy) *keyUsage {
+	u, _ := lm.usage.LoadOrStore(keyUsagePath(p), &keyUsage{})
+	return u.(*keyUsage)
+}
+
+// load reads the stored reservations, which become the starting counts.
+// The caller must hold u.l.
+func (u *keyUsage) load(ctx context.Context, storage logical.Storage, p *Policy) error {
+	if u.loaded {
+		return nil
+	}
+
+	u.counts = map[string]KeyVersionUsage{}
+	u.reserved = map[string]KeyVersionUsage{}
+
+	entry, err := storage.Get(ctx, keyUsagePath(p))
+	if err != nil {
+		return fmt.Errorf("error reading key usage: %w", err)
+	}
+	if entry != nil {
+		if err := entry.DecodeJSON(&u.reserved); err != nil {
+			return fmt.Errorf("error decoding key usage: %w", err)
+		}
+		for ver, reserved := range u.reserved {
+			u.counts[ver] = reserved
+		}
+	}
+
+	u.loaded = true
+	return nil
+}
+
+// RecordKeyUsage adds n operations of the given type against a key version,
+// persisting a new reservation when the count exceeds the current one. It
+// returns the updated usage of the version.
+//
+// When persist is false, as on nodes which cannot write to storage, the
+// counts are only kept in memory on top of the stored reservation. A failed
+// write is not retried until KeyUsageRetryInterval has passed.
+func (lm *LockManager) RecordKeyUsage(ctx context.Context, storage logical.Storage, p *Policy, ver int, usage KeyUsageType, n uint64, persist bool) (KeyVersionUsage, error) {
+	u := lm.keyUsage(p)
+	u.l.Lock()
+	defer u.l.Unlock()
+
+	if err := u.load(ctx, storage, p); err != nil {
+		return KeyVersionUsage{}, err
+	}
+
+	now := time.Now().UTC()
+	verStr := strconv.Itoa(ver)
+	counts := u.counts[verStr]
+	counts.add(usage, n, now)
+	u.counts[verStr] = counts
+
+	reserved := u.reserved[verStr]
+	if !persist || now.Before(u.retryAfter) || !counts.needsReservation(reserved) {
+		return counts, nil
+	}
+
+	// Reserve ahead for whichever counters have been exhausted, dropping
+	// versions that have been trimmed so the entry does not grow unbounded.
+	newReserved := make(map[string]KeyVersionUsage, len(u.reserved)+1)
+	for k, v := range u.reserved {
+		if usageVersionExists(p, k) {
+			newReserved[k] = v
+		}
+	}
+	if counts.Encryptions > reserved.Encryptions {
+		reserved.Encryptions = counts.Encryptions + KeyUsageReservation
+	}
+	if counts.Signatures > reserved.Signatures {
+		reserved.Signatures = counts.Signatures + KeyUsageReservation
+	}
+	if counts.Decryptions > reserved.Decryptions {
+		reserved.Decryptions = counts.Decryptions + KeyUsageReservation
+	}
+	if counts.Verifications > reserved.Verifications {
+		reserved.Verifications = counts.Verifications + KeyUsageReservation
+	}
+	reserved.LastDecrypted = counts.LastDecrypted
+	reserved.LastVerified = counts.LastVerified
+	newReserved[verStr] = reserved
+
+	entry, err := logical.StorageEntryJSON(keyUsagePath(p), newReserved)
+	if err != nil {
+		return counts, fmt.Errorf("error encoding key usage: %w", err)
+	}
+	if err := storage.Put(ctx, entry); err != nil {
+		u.retryAfter = now.Add(KeyUsageRetryInterval)
+		return counts, fmt.Errorf("error writing key usage: %w", err)
+	}
+	u.reserved = newReserved
+	u.retryAfter = time.Time{}
+
+	return counts, nil
+}
+
+// KeyUsage returns the usage of every untrimmed version of the key that has
+// been used since the counters were introduced.
+func (lm *LockManager) KeyUsage(ctx context.Context, storage logical.Storage, p *Policy) (map[string]KeyVersionUsage, error) {
+	u := lm.keyUsage(p)
+	u.l.Lock()
+	defer u.l.Unlock()
+
+	if err := u.load(ctx, storage, p); err != nil {
+		return nil, err
+	}
+
+	ret := make(map[string]KeyVersionUsage, len(u.counts))
+	for ver, counts := range u.counts {
+		if usageVersionExists(p, ver) {
+			ret[ver] = counts
+		}
+	}
+	return ret, nil
+}
+
+// InvalidateKeyUsage drops the in-memory counts for a key so they are
+// reloaded from storage on next use.
+func (lm *LockManager) InvalidateKeyUsage(name string) {
+	lm.usage.Delete(path.Join("usage", name))
+}
+
+func (lm *LockManager) deleteKeyUsage(ctx context.Context, storage logical.Storage, p *Policy) error {
+	lm.usage.Delete(keyUsagePath(p))
+	return storage.Delete(ctx, keyUsagePath(p))
+}
@@ -0,0 +1,133 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package keysutil
+
+import (
+	"context"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func TestKeyUsage(t *testing.T) {
+	ctx := context.Background()
+	storage := &logical.InmemStorage{}
+
+	lm, _ := NewLockManager(true, 0)
+	p, _, err := lm.GetPolicy(ctx, PolicyRequest{
+		Upsert:  true,
+		Storage: storage,
+		KeyType: KeyType_AES256_GCM96,
+		Name:    "test",
+	}, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.DeletionAllowed = true
+
+	usage, err := lm.RecordKeyUsage(ctx, storage, p, 1, KeyUsageEncrypt, 10, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage.Encryptions != 10 || usage.Operations() != 10 {
+		t.Fatalf("unexpected usage %#v", usage)
+	}
+
+	// The first use reserves ahead; further use within the reservation does
+	// not write to storage.
+	entry, err := storage.Get(ctx, "usage/test")
+	if err != nil || entry == nil {
+		t.Fatalf("expected usage entry: %v", err)
+	}
+	if _, err := lm.RecordKeyUsage(ctx, storage, p, 1, KeyUsageEncrypt, 100, true); err != nil {
+		t.Fatal(err)
+	}
+	after, _ := storage.Get(ctx, "usage/test")
+	if string(after.Value) != string(entry.Value) {
+		t.Fatalf("usage was persisted within the reservation")
+	}
+
+	usages, err := lm.KeyUsage(ctx, storage, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usages["1"].Encryptions != 110 {
+		t.Fatalf("unexpected usage %#v", usages)
+	}
+
+	// A fresh lock manager starts from the reservation, so counts are never
+	// understated.
+	lm2, _ := NewLockManager(false, 0)
+	usages, err = lm2.KeyUsage(ctx, storage, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usages["1"].Encryptions != 10+KeyUsageReservation {
+		t.Fatalf("unexpected usage after reload %#v", usages)
+	}
+
+	// Decryptions record when the version was last used.
+	before := time.Now().UTC()
+	usage, err = lm.RecordKeyUsage(ctx, storage, p, 1, KeyUsageDecrypt, 2, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage.Decryptions != 2 || usage.LastDecrypted.Before(before) || usage.Operations() != 110 {
+		t.Fatalf("unexpected usage %#v", usage)
+	}
+	lm3, _ := NewLockManager(false, 0)
+	usages, err = lm3.KeyUsage(ctx, storage, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !usages["1"].LastDecrypted.Equal(usage.LastDecrypted) {
+		t.Fatalf("last decryption time was not persisted: %#v", usages["1"])
+	}
+
+	// Nodes which cannot write only count in memory.
+	entry, _ = storage.Get(ctx, "usage/test")
+	usage, err = lm.RecordKeyUsage(ctx, storage, p, 1, KeyUsageEncrypt, 2*KeyUsageReservation, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage.Encryptions != 110+2*KeyUsageReservation {
+		t.Fatalf("unexpected usage %#v", usage)
+	}
+	if after, _ := storage.Get(ctx, "usage/test"); string(after.Value) != string(entry.Value) {
+		t.Fatalf("usage was persisted without persist set")
+	}
+
+	// A failed write is not retried on every operation, but the counts are
+	// kept and written once writes are retried.
+	storage.FailPut(true)
+	if _, err := lm.RecordKeyUsage(ctx, storage, p, 1, KeyUsageEncrypt, 1, true); err == nil {
+		t.Fatal("expected write failure")
+	}
+	if _, err := lm.RecordKeyUsage(ctx, storage, p, 1, KeyUsageEncrypt, 1, true); err != nil {
+		t.Fatalf("expected the write to be held off: %v", err)
+	}
+	storage.FailPut(false)
+	lm.keyUsage(p).retryAfter = time.Time{}
+	usage, err = lm.RecordKeyUsage(ctx, storage, p, 1, KeyUsageEncrypt, 1, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lm4, _ := NewLockManager(false, 0)
+	usages, err = lm4.KeyUsage(ctx, storage, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usages["1"].Encryptions != usage.Encryptions+KeyUsageReservation {
+		t.Fatalf("unexpected usage after failed write %#v", usages)
+	}
+
+	if err := lm.DeletePolicy(ctx, storage, "test"); err != nil {
+		t.Fatal(err)
+	}
+	if entry, _ := storage.Get(ctx, "usage/test"); entry != nil {
+		t.Fatalf("usage entry was not deleted")
+	}
+}